@@ -0,0 +1,155 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hpcloud/fissile/kube"
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+	extra "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// runKubectl runs kubectl with the given arguments and standard input, and
+// returns its standard output. Tests replace it to fake a cluster.
+var runKubectl = func(kubectl string, stdin io.Reader, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(kubectl, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Error running %s %s: %s: %s", kubectl, strings.Join(args, " "), err.Error(), strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// jobPollInterval is how often the status of jobs fissile runs is checked
+var jobPollInterval = 5 * time.Second
+
+// RunBBR runs the backup or restore scripts of roles in a Kubernetes cluster.
+// For each role a job is created from the pod template of the deployed role;
+// it runs the scripts with the artifacts volume of the role mounted. When no
+// role names are given, all roles with a backup are used.
+func (f *Fissile) RunBBR(rolesManifestPath string, roleNames []string, action, kubectl, namespace string, timeout time.Duration) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	if !model.IsBBRAction(action) {
+		return fmt.Errorf("Unknown backup action %s", action)
+	}
+
+	rolesManifest, err := f.loadRoleManifest(rolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	var roles model.Roles
+	if len(roleNames) == 0 {
+		for _, role := range rolesManifest.Roles {
+			if role.Run != nil && role.Run.Backup != nil {
+				roles = append(roles, role)
+			}
+		}
+		if len(roles) == 0 {
+			return fmt.Errorf("No role in the roles manifest has a backup")
+		}
+	} else {
+		for _, roleName := range roleNames {
+			role := rolesManifest.LookupRole(roleName)
+			if role == nil {
+				return fmt.Errorf("Role %s not found in the roles manifest", roleName)
+			}
+			if role.Run == nil || role.Run.Backup == nil {
+				return fmt.Errorf("Role %s does not have a backup", roleName)
+			}
+			roles = append(roles, role)
+		}
+	}
+
+	var namespaceArgs []string
+	if namespace != "" {
+		namespaceArgs = []string{"--namespace", namespace}
+	}
+	kubectlRun := func(stdin io.Reader, args ...string) ([]byte, error) {
+		return runKubectl(kubectl, stdin, append(namespaceArgs, args...)...)
+	}
+
+	for _, role := range roles {
+		f.UI.Printf("Running %s for role %s\n", action, color.YellowString(role.Name))
+
+		workload, err := kubectlRun(nil, "get", strings.ToLower(kube.WorkloadKind(role)), role.Name, "--output", "json")
+		if err != nil {
+			return err
+		}
+		podTemplate, err := kube.PodTemplateFromWorkload(workload)
+		if err != nil {
+			return fmt.Errorf("Error reading deployed role %s: %s", role.Name, err.Error())
+		}
+
+		job, err := kube.NewBBRJob(role, podTemplate, action)
+		if err != nil {
+			return err
+		}
+
+		var jobConfig bytes.Buffer
+		if err := kube.WriteYamlConfig(job, &jobConfig); err != nil {
+			return err
+		}
+
+		// Jobs can't be run twice, so drop the one from the last run
+		if _, err := kubectlRun(nil, "delete", "job", job.Name, "--ignore-not-found"); err != nil {
+			return err
+		}
+		if _, err := kubectlRun(&jobConfig, "create", "--filename", "-"); err != nil {
+			return err
+		}
+
+		if err := f.waitForJob(kubectlRun, job.Name, timeout); err != nil {
+			return err
+		}
+
+		f.UI.Printf("Finished %s for role %s\n", action, color.GreenString(role.Name))
+	}
+
+	return nil
+}
+
+// waitForJob polls a job until it either succeeded or failed
+func (f *Fissile) waitForJob(kubectlRun func(io.Reader, ...string) ([]byte, error), jobName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		output, err := kubectlRun(nil, "get", "job", jobName, "--output", "json")
+		if err != nil {
+			return err
+		}
+
+		var job extra.Job
+		if err := json.Unmarshal(output, &job); err != nil {
+			return fmt.Errorf("Error reading status of job %s: %s", jobName, err.Error())
+		}
+
+		if job.Status.Succeeded > 0 {
+			return nil
+		}
+		if job.Status.Failed > 0 {
+			return fmt.Errorf("Job %s failed, see the logs of its pods for details", jobName)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out waiting for job %s to finish", jobName)
+		}
+
+		time.Sleep(jobPollInterval)
+	}
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBBR(t *testing.T) {
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/backup.yml")
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	var calls []string
+	var created string
+	jobStatus := `{"status": {"succeeded": 1}}`
+
+	savedRunKubectl := runKubectl
+	defer func() { runKubectl = savedRunKubectl }()
+	runKubectl = func(kubectl string, stdin io.Reader, args ...string) ([]byte, error) {
+		call := strings.Join(append([]string{kubectl}, args...), " ")
+		calls = append(calls, call)
+		switch {
+		case strings.Contains(call, " get statefulset myrole "):
+			return []byte(`{"spec": {"template": {"spec": {"containers": [{"name": "myrole", "image": "deployed-image"}]}}}}`), nil
+		case strings.Contains(call, " create "):
+			data, err := ioutil.ReadAll(stdin)
+			created = string(data)
+			return nil, err
+		case strings.Contains(call, " get job "):
+			return []byte(jobStatus), nil
+		}
+		return nil, nil
+	}
+
+	err = f.RunBBR(roleManifestPath, nil, "backup", "kubectl", "cf", time.Minute)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal([]string{
+		"kubectl --namespace cf get statefulset myrole --output json",
+		"kubectl --namespace cf delete job myrole-backup --ignore-not-found",
+		"kubectl --namespace cf create --filename -",
+		"kubectl --namespace cf get job myrole-backup --output json",
+	}, calls)
+	assert.Contains(created, "image: deployed-image")
+	assert.Contains(created, "claimName: myrole-bbr-artifacts")
+	assert.Contains(created, "claimName: store-myrole-0")
+
+	jobStatus = `{"status": {"failed": 1}}`
+	err = f.RunBBR(roleManifestPath, []string{"myrole"}, "restore", "kubectl", "", time.Minute)
+	assert.EqualError(err, "Job myrole-restore failed, see the logs of its pods for details")
+
+	err = f.RunBBR(roleManifestPath, []string{"otherrole"}, "backup", "kubectl", "", time.Minute)
+	assert.EqualError(err, "Role otherrole does not have a backup")
+
+	err = f.RunBBR(roleManifestPath, nil, "explode", "kubectl", "", time.Minute)
+	assert.EqualError(err, fmt.Sprintf("Unknown backup action %s", "explode"))
+}
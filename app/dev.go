@@ -0,0 +1,155 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hpcloud/fissile/builder"
+	"github.com/hpcloud/fissile/kube"
+
+	"github.com/fatih/color"
+)
+
+// devSyncCommand replaces the jobs and role scripts in a container with the
+// archive on standard input
+const devSyncCommand = "rm -rf /var/vcap/jobs-src/* /opt/hcf/startup/* && tar -xf - -C /"
+
+// devReloadCommand renders the job templates again and restarts the jobs, the
+// same way run.sh does on startup
+const devReloadCommand = `export IP_ADDRESS=$(/bin/hostname -i | awk '{print $1}')
+export DNS_RECORD_NAME=$(/bin/hostname)
+export MONIT_ADMIN_USER=$(cat /proc/sys/kernel/random/uuid)
+export MONIT_ADMIN_PASSWORD=$(cat /proc/sys/kernel/random/uuid)
+/opt/hcf/configgin/configgin --jobs /opt/hcf/job_config.json --env2conf /opt/hcf/env2conf.yml
+chmod 0600 /etc/monitrc
+monit reload
+sleep 5
+monit restart all`
+
+// DevSync copies the jobs and scripts of a role, as they are in the loaded
+// releases, into the running pods of the role. The pods are expected to run
+// images built with dev mounts, which wait for the first sync before they
+// start. With restart, the jobs of pods that already run are restarted to
+// pick up the changes.
+func (f *Fissile) DevSync(rolesManifestPath, roleName, lightManifestPath, darkManifestPath, kubectl, namespace string, restart bool) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	rolesManifest, err := f.loadRoleManifest(rolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	role := rolesManifest.LookupRole(roleName)
+	if role == nil {
+		return fmt.Errorf("Role %s not found in the roles manifest", roleName)
+	}
+
+	syncDir, err := ioutil.TempDir("", "fissile-dev-sync")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(syncDir)
+
+	roleBuilder, err := builder.NewRoleImageBuilder("", "", syncDir, lightManifestPath, darkManifestPath, "", "", f.Version, f.UI)
+	if err != nil {
+		return err
+	}
+	rootDir := filepath.Join(syncDir, "root")
+	if err := roleBuilder.WriteDevMounts(role, rootDir); err != nil {
+		return fmt.Errorf("Error writing the jobs of role %s: %s", role.Name, err.Error())
+	}
+
+	var archive bytes.Buffer
+	if err := writeDirArchive(rootDir, &archive); err != nil {
+		return err
+	}
+
+	var namespaceArgs []string
+	if namespace != "" {
+		namespaceArgs = []string{"--namespace", namespace}
+	}
+	kubectlRun := func(stdin io.Reader, args ...string) ([]byte, error) {
+		return runKubectl(kubectl, stdin, append(namespaceArgs, args...)...)
+	}
+
+	output, err := kubectlRun(nil, "get", "pods",
+		"--selector", fmt.Sprintf("%s=%s", kube.RoleNameLabel, role.Name),
+		"--output", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return err
+	}
+	pods := strings.Fields(string(output))
+	if len(pods) == 0 {
+		return fmt.Errorf("No pods of role %s are running", role.Name)
+	}
+
+	for _, pod := range pods {
+		f.UI.Printf("Syncing jobs of role %s into pod %s\n", color.YellowString(role.Name), color.CyanString(pod))
+
+		if _, err := kubectlRun(bytes.NewReader(archive.Bytes()), "exec", "--stdin", pod, "--", "bash", "-c", devSyncCommand); err != nil {
+			return err
+		}
+
+		if restart {
+			f.UI.Printf("Restarting jobs in pod %s\n", color.CyanString(pod))
+			if _, err := kubectlRun(nil, "exec", pod, "--", "bash", "-c", devReloadCommand); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeDirArchive writes a tar archive of the contents of a directory, with
+// paths relative to it
+func writeDirArchive(dir string, writer io.Writer) error {
+	tarWriter := tar.NewWriter(writer)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relativePath)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Error archiving %s: %s", dir, err.Error())
+	}
+
+	return tarWriter.Close()
+}
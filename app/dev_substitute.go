@@ -0,0 +1,257 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"github.com/hpcloud/fissile/kube"
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	extra "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// startDevProcess starts a helper process that runs until it is stopped, like
+// kubectl port-forward. Tests replace it.
+var startDevProcess = func(name string, args ...string) (func(), error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = ioutil.Discard
+	cmd.Stderr = ioutil.Discard
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error running %s %s: %s", name, strings.Join(args, " "), err.Error())
+	}
+
+	return func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}, nil
+}
+
+// runDevProcess runs a process attached to the terminal, with the given
+// environment added to the one of fissile. Tests replace it.
+var runDevProcess = func(env []string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), env...)
+
+	return cmd.Run()
+}
+
+// DevSubstitute replaces the pods of a role in a cluster with a container of
+// the role run locally. The Deployment of the role is scaled down, the other
+// roles of the manifest are made reachable from the container through kubectl
+// port-forward, and the container runs with the environment of the deployed
+// pods until it exits. The Deployment is then scaled back up. An empty image
+// runs the one that is deployed.
+func (f *Fissile) DevSubstitute(rolesManifestPath, roleName, image, kubectl, namespace string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	rolesManifest, err := f.loadRoleManifest(rolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	role := rolesManifest.LookupRole(roleName)
+	if role == nil {
+		return fmt.Errorf("Role %s not found in the roles manifest", roleName)
+	}
+	if !role.IsLongRunning() {
+		return fmt.Errorf("Role %s is a task; only roles with pods that keep running can be substituted", role.Name)
+	}
+	if kind := kube.WorkloadKind(role); kind != "Deployment" {
+		return fmt.Errorf("Role %s runs as a %s; only roles deployed as a Deployment can be substituted", role.Name, kind)
+	}
+
+	var namespaceArgs []string
+	if namespace != "" {
+		namespaceArgs = []string{"--namespace", namespace}
+	}
+	kubectlRun := func(stdin io.Reader, args ...string) ([]byte, error) {
+		return runKubectl(kubectl, stdin, append(namespaceArgs, args...)...)
+	}
+
+	output, err := kubectlRun(nil, "get", "deployment", role.Name, "--output", "json")
+	if err != nil {
+		return err
+	}
+	var deployment extra.Deployment
+	if err := json.Unmarshal(output, &deployment); err != nil {
+		return fmt.Errorf("Error reading deployed role %s: %s", role.Name, err.Error())
+	}
+	if len(deployment.Spec.Template.Spec.Containers) == 0 {
+		return fmt.Errorf("Deployment of role %s has no containers", role.Name)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if image == "" {
+		image = container.Image
+	}
+
+	env, err := f.devSubstituteEnv(kubectlRun, container.Env, namespace)
+	if err != nil {
+		return err
+	}
+
+	forwards, err := devSubstituteForwards(kubectlRun, rolesManifest, role)
+	if err != nil {
+		return err
+	}
+
+	var replicas int32 = 1
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	f.UI.Printf("Scaling down role %s\n", color.YellowString(role.Name))
+	if _, err := kubectlRun(nil, "scale", "deployment", role.Name, "--replicas=0"); err != nil {
+		return err
+	}
+	defer func() {
+		f.UI.Printf("Scaling role %s back to %d replicas\n", color.YellowString(role.Name), replicas)
+		if _, err := kubectlRun(nil, "scale", "deployment", role.Name, fmt.Sprintf("--replicas=%d", replicas)); err != nil {
+			f.UI.Printf("%s\n", color.RedString(err.Error()))
+		}
+	}()
+
+	dockerArgs := []string{
+		"run", "--rm", "--interactive", "--tty",
+		"--name", fmt.Sprintf("fissile-dev-%s", role.Name),
+		"--network", "host",
+	}
+	if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+		dockerArgs = append(dockerArgs, "--privileged")
+	}
+	if container.SecurityContext != nil && container.SecurityContext.Capabilities != nil {
+		for _, capability := range container.SecurityContext.Capabilities.Add {
+			dockerArgs = append(dockerArgs, "--cap-add", string(capability))
+		}
+	}
+
+	for _, forward := range forwards {
+		f.UI.Printf("Forwarding role %s from pod %s\n", color.YellowString(forward.role), color.CyanString(forward.pod))
+		stop, err := startDevProcess(kubectl, append(namespaceArgs, append([]string{"port-forward", forward.pod}, forward.ports...)...)...)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		dockerArgs = append(dockerArgs, "--add-host", fmt.Sprintf("%s:127.0.0.1", forward.role))
+	}
+
+	// The values are passed through the environment of docker, so secrets
+	// don't show up in the process list
+	for _, variable := range env {
+		dockerArgs = append(dockerArgs, "--env", strings.SplitN(variable, "=", 2)[0])
+	}
+	dockerArgs = append(dockerArgs, image)
+
+	// Interrupts go to the container; the role is scaled back up once it exits
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	f.UI.Printf("Running role %s locally from %s\n", color.YellowString(role.Name), color.CyanString(image))
+	if err := runDevProcess(env, "docker", dockerArgs...); err != nil {
+		return fmt.Errorf("Error running role %s: %s", role.Name, err.Error())
+	}
+
+	return nil
+}
+
+// devSubstituteEnv returns the environment of a deployed container as
+// NAME=value, reading values from secrets as the cluster does
+func (f *Fissile) devSubstituteEnv(kubectlRun func(io.Reader, ...string) ([]byte, error), vars []apiv1.EnvVar, namespace string) ([]string, error) {
+	var result []string
+	for _, variable := range vars {
+		value := variable.Value
+		switch {
+		case variable.ValueFrom == nil:
+		case variable.ValueFrom.SecretKeyRef != nil:
+			ref := variable.ValueFrom.SecretKeyRef
+			output, err := kubectlRun(nil, "get", "secret", ref.Name, "--output", fmt.Sprintf("jsonpath={.data.%s}", ref.Key))
+			if err != nil {
+				return nil, err
+			}
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(output)))
+			if err != nil {
+				return nil, fmt.Errorf("Error reading key %s of secret %s: %s", ref.Key, ref.Name, err.Error())
+			}
+			value = string(decoded)
+		case variable.ValueFrom.FieldRef != nil && variable.ValueFrom.FieldRef.FieldPath == "metadata.namespace" && namespace != "":
+			value = namespace
+		default:
+			f.UI.Printf("Skipping variable %s; it can't be read outside of the cluster\n", color.YellowString(variable.Name))
+			continue
+		}
+		result = append(result, fmt.Sprintf("%s=%s", variable.Name, value))
+	}
+	return result, nil
+}
+
+// devSubstituteForward is a kubectl port-forward to a pod of a role
+type devSubstituteForward struct {
+	role  string
+	pod   string
+	ports []string // local:remote
+}
+
+// devSubstituteForwards returns the port-forwards making the services of the
+// other roles reachable on their service ports on localhost. Roles without
+// running pods, and UDP ports, are left out.
+func devSubstituteForwards(kubectlRun func(io.Reader, ...string) ([]byte, error), rolesManifest *model.RoleManifest, substituted *model.Role) ([]devSubstituteForward, error) {
+	var result []devSubstituteForward
+	for _, role := range rolesManifest.Roles {
+		if role == substituted || role.Run == nil {
+			continue
+		}
+
+		var ports []string
+		for _, port := range role.Run.ExposedPorts {
+			if strings.ToUpper(port.Protocol) == "UDP" {
+				continue
+			}
+			externalMin, externalMax, err := model.ParsePortRange(port.External, port.Name, "external")
+			if err != nil {
+				return nil, err
+			}
+			internalMin, _, err := model.ParsePortRange(port.Internal, port.Name, "internal")
+			if err != nil {
+				return nil, err
+			}
+			for offset := int32(0); externalMin+offset <= externalMax; offset++ {
+				ports = append(ports, fmt.Sprintf("%d:%d", externalMin+offset, internalMin+offset))
+			}
+		}
+		if len(ports) == 0 {
+			continue
+		}
+
+		output, err := kubectlRun(nil, "get", "pods",
+			"--selector", fmt.Sprintf("%s=%s", kube.RoleNameLabel, role.Name),
+			"--output", "jsonpath={.items[*].metadata.name}")
+		if err != nil {
+			return nil, err
+		}
+		pods := strings.Fields(string(output))
+		if len(pods) == 0 {
+			continue
+		}
+
+		result = append(result, devSubstituteForward{
+			role:  role.Name,
+			pod:   pods[0],
+			ports: ports,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevSync(t *testing.T) {
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml")
+	torOpinionsDir := filepath.Join(workDir, "../test-assets/tor-opinions")
+	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
+	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	var calls []string
+	var synced []string
+	pods := "myrole-0 myrole-1"
+
+	savedRunKubectl := runKubectl
+	defer func() { runKubectl = savedRunKubectl }()
+	runKubectl = func(kubectl string, stdin io.Reader, args ...string) ([]byte, error) {
+		call := strings.Join(append([]string{kubectl}, args[:len(args)-1]...), " ")
+		calls = append(calls, call)
+		switch {
+		case strings.Contains(call, " get pods "):
+			return []byte(pods), nil
+		case strings.Contains(call, " exec --stdin "):
+			synced = nil
+			reader := tar.NewReader(stdin)
+			for {
+				header, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return nil, err
+				}
+				synced = append(synced, header.Name)
+			}
+		}
+		return nil, nil
+	}
+
+	err = f.DevSync(roleManifestPath, "myrole", lightOpinionsPath, darkOpinionsPath, "kubectl", "cf", true)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal([]string{
+		"kubectl --namespace cf get pods --selector skiff-role-name=myrole --output",
+		"kubectl --namespace cf exec --stdin myrole-0 -- bash -c",
+		"kubectl --namespace cf exec myrole-0 -- bash -c",
+		"kubectl --namespace cf exec --stdin myrole-1 -- bash -c",
+		"kubectl --namespace cf exec myrole-1 -- bash -c",
+	}, calls)
+	assert.Contains(synced, "opt/hcf/startup/myrole.sh")
+	assert.Contains(synced, "var/vcap/jobs-src/tor/config_spec.json")
+	assert.Contains(synced, "var/vcap/jobs-src/.fissile-dev-synced")
+
+	pods = ""
+	err = f.DevSync(roleManifestPath, "myrole", lightOpinionsPath, darkOpinionsPath, "kubectl", "", false)
+	assert.EqualError(err, "No pods of role myrole are running")
+
+	err = f.DevSync(roleManifestPath, "otherrole", lightOpinionsPath, darkOpinionsPath, "kubectl", "", false)
+	assert.EqualError(err, "Role otherrole not found in the roles manifest")
+}
+
+func TestDevSubstitute(t *testing.T) {
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/kube-generators.yml")
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	deployment := `{"spec": {"replicas": 2, "template": {"spec": {"containers": [{
+		"image": "fissile-deployment-role:1234",
+		"env": [
+			{"name": "HOSTNAME", "value": "tor.example.com"},
+			{"name": "PASSWORD", "valueFrom": {"secretKeyRef": {"name": "creds", "key": "password"}}},
+			{"name": "KUBERNETES_NAMESPACE", "valueFrom": {"fieldRef": {"fieldPath": "metadata.namespace"}}},
+			{"name": "POD_IP", "valueFrom": {"fieldRef": {"fieldPath": "status.podIP"}}}
+		]
+	}]}}}}`
+
+	var calls []string
+	savedRunKubectl := runKubectl
+	defer func() { runKubectl = savedRunKubectl }()
+	runKubectl = func(kubectl string, stdin io.Reader, args ...string) ([]byte, error) {
+		call := strings.Join(append([]string{kubectl}, args...), " ")
+		calls = append(calls, call)
+		switch {
+		case strings.Contains(call, " get deployment deployment-role "):
+			return []byte(deployment), nil
+		case strings.Contains(call, " get secret creds "):
+			return []byte("czNjcjN0"), nil
+		case strings.Contains(call, " get pods --selector skiff-role-name=clustered-role "):
+			return []byte("clustered-role-0 clustered-role-1"), nil
+		}
+		return nil, nil
+	}
+
+	var started, stopped []string
+	savedStartDevProcess := startDevProcess
+	defer func() { startDevProcess = savedStartDevProcess }()
+	startDevProcess = func(name string, args ...string) (func(), error) {
+		process := strings.Join(append([]string{name}, args...), " ")
+		started = append(started, process)
+		return func() { stopped = append(stopped, process) }, nil
+	}
+
+	var run string
+	var runEnv []string
+	savedRunDevProcess := runDevProcess
+	defer func() { runDevProcess = savedRunDevProcess }()
+	runDevProcess = func(env []string, name string, args ...string) error {
+		run = strings.Join(append([]string{name}, args...), " ")
+		runEnv = env
+		// The role is scaled down while it runs locally
+		assert.Contains(calls, "kubectl --namespace cf scale deployment deployment-role --replicas=0")
+		assert.Empty(stopped)
+		return nil
+	}
+
+	err = f.DevSubstitute(roleManifestPath, "deployment-role", "", "kubectl", "cf")
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal([]string{"kubectl --namespace cf port-forward clustered-role-0 7000:7000"}, started)
+	assert.Equal(started, stopped)
+	assert.Equal("docker run --rm --interactive --tty --name fissile-dev-deployment-role --network host "+
+		"--add-host clustered-role:127.0.0.1 "+
+		"--env HOSTNAME --env PASSWORD --env KUBERNETES_NAMESPACE fissile-deployment-role:1234", run)
+	assert.Equal([]string{"HOSTNAME=tor.example.com", "PASSWORD=s3cr3t", "KUBERNETES_NAMESPACE=cf"}, runEnv)
+	if assert.NotEmpty(calls) {
+		assert.Equal("kubectl --namespace cf scale deployment deployment-role --replicas=2", calls[len(calls)-1])
+	}
+
+	started, stopped = nil, nil
+	err = f.DevSubstitute(roleManifestPath, "deployment-role", "fissile-deployment-role:dev", "kubectl", "")
+	if assert.NoError(err) {
+		assert.Contains(run, " fissile-deployment-role:dev")
+	}
+
+	err = f.DevSubstitute(roleManifestPath, "clustered-role", "", "kubectl", "")
+	assert.EqualError(err, "Role clustered-role runs as a StatefulSet; only roles deployed as a Deployment can be substituted")
+
+	err = f.DevSubstitute(roleManifestPath, "task-role", "", "kubectl", "")
+	assert.EqualError(err, "Role task-role is a task; only roles with pods that keep running can be substituted")
+}
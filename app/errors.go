@@ -0,0 +1,60 @@
+package app
+
+import (
+	"fmt"
+)
+
+// ErrorCategory is the kind of failure of a fissile command. Its value is the
+// exit code of fissile, so scripts can tell failures apart.
+type ErrorCategory int
+
+// These are the error categories. Anything not listed exits with 1.
+const (
+	ErrorCategoryOther    = ErrorCategory(1)
+	ErrorCategoryManifest = ErrorCategory(2) // The role manifest is invalid, or fails linting
+	ErrorCategoryRelease  = ErrorCategory(3) // A release failed to load
+	ErrorCategoryCompile  = ErrorCategory(4) // A package failed to compile
+	ErrorCategoryDocker   = ErrorCategory(5) // Talking to docker or building an image failed
+	ErrorCategoryPush     = ErrorCategory(6) // Pushing an image failed
+	ErrorCategoryKube     = ErrorCategory(7) // Generating the Kubernetes configuration failed
+)
+
+// CategorizedError is an error of a known category
+type CategorizedError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+// Error implements error
+func (e *CategorizedError) Error() string {
+	return e.Err.Error()
+}
+
+// categorize marks an error as being of a category. Errors that already have
+// a category keep it, as it comes from closer to the failure.
+func categorize(category ErrorCategory, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*CategorizedError); ok {
+		return err
+	}
+	return &CategorizedError{Category: category, Err: err}
+}
+
+// categorizedErrorf is fmt.Errorf for an error of a category
+func categorizedErrorf(category ErrorCategory, format string, args ...interface{}) error {
+	return &CategorizedError{Category: category, Err: fmt.Errorf(format, args...)}
+}
+
+// ExitCode returns the exit code of fissile for the error of a command; 0 for
+// no error, and 1 for errors without a category
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if categorized, ok := err.(*CategorizedError); ok {
+		return int(categorized.Category)
+	}
+	return int(ErrorCategoryOther)
+}
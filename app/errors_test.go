@@ -0,0 +1,64 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, ExitCode(nil))
+	assert.Equal(1, ExitCode(fmt.Errorf("uncategorized")))
+	assert.Equal(7, ExitCode(categorizedErrorf(ErrorCategoryKube, "Failed %s", "here")))
+
+	// The category closest to the failure wins
+	err := categorize(ErrorCategoryKube, categorize(ErrorCategoryManifest, fmt.Errorf("invalid")))
+	assert.Equal(2, ExitCode(err))
+	assert.EqualError(err, "invalid")
+
+	assert.Nil(categorize(ErrorCategoryKube, nil))
+}
+
+func TestExitCodeOfCommands(t *testing.T) {
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	badReleasePath := filepath.Join(workDir, "../test-assets/bad-release")
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{badReleasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	assert.Equal(int(ErrorCategoryRelease), ExitCode(err))
+
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	err = f.ShowStats(filepath.Join(workDir, "../test-assets/role-manifests/tor-bad.yml"), "")
+	assert.Equal(int(ErrorCategoryManifest), ExitCode(err))
+
+	outputDir, err := ioutil.TempDir("", "fissile-exit-code")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(outputDir)
+	err = f.GenerateKube(KubeOptions{
+		RolesManifestPath: filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml"),
+		OutputDir:         outputDir,
+		OnlyKinds:         []string{"Unknown"},
+	})
+	assert.Equal(int(ErrorCategoryKube), ExitCode(err))
+}
@@ -19,8 +19,9 @@ import (
 	"github.com/fatih/color"
 	"github.com/hpcloud/stampy"
 	"github.com/hpcloud/termui"
-	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v2"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
 )
 
 // Fissile represents a fissile application
@@ -28,9 +29,11 @@ type Fissile struct {
 	Version                    string
 	UI                         *termui.UI
 	cmdErr                     error
-	releases                   []*model.Release // Only applies for some commands
-	patchPropertiesReleaseName string           // Only applies for some commands
-	patchPropertiesJobName     string           // Only applies for some commands
+	releases                   []*model.Release          // Only applies for some commands
+	patchPropertiesReleaseName string                    // Only applies for some commands
+	patchPropertiesJobName     string                    // Only applies for some commands
+	manifestOptions            model.RoleManifestOptions // See SetRoleManifestOptions
+	releaseOptions             model.ReleaseOptions      // See SetReleaseOptions
 }
 
 // NewFissileApplication creates a new app.Fissile
@@ -62,11 +65,27 @@ func (f *Fissile) SetPatchPropertiesDirective(patchPropertiesDirective string) e
 	return nil
 }
 
+// SetRoleManifestOptions sets the options role manifests are loaded with
+func (f *Fissile) SetRoleManifestOptions(options model.RoleManifestOptions) {
+	f.manifestOptions = options
+}
+
+// SetReleaseOptions sets the options releases are loaded with
+func (f *Fissile) SetReleaseOptions(options model.ReleaseOptions) {
+	f.releaseOptions = options
+}
+
+// loadRoleManifest loads the role manifest for the loaded releases, with the
+// options of the application
+func (f *Fissile) loadRoleManifest(rolesManifestPath string) (*model.RoleManifest, error) {
+	return model.LoadRoleManifestWithOptions(rolesManifestPath, f.releases, f.manifestOptions)
+}
+
 // ShowBaseImage will show details about the base BOSH images
 func (f *Fissile) ShowBaseImage(repository string) error {
 	dockerManager, err := docker.NewImageManager()
 	if err != nil {
-		return fmt.Errorf("Error connecting to docker: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
 	}
 
 	comp, err := compilator.NewCompilator(dockerManager, "", "", repository, compilation.UbuntuBase, f.Version, false, f.UI)
@@ -92,16 +111,21 @@ func (f *Fissile) ShowBaseImage(repository string) error {
 	return nil
 }
 
-// CreateBaseCompilationImage will recompile the base BOSH image for a release
-func (f *Fissile) CreateBaseCompilationImage(baseImageName, repository, metricsPath string, keepContainer bool) error {
+// CreateBaseCompilationImage will recompile the base BOSH image for a release;
+// the compilation scripts are written to the scratch directory, or to the
+// system temporary directory without one
+func (f *Fissile) CreateBaseCompilationImage(baseImageName, repository, metricsPath, scratchDir string, keepContainer bool) (err error) {
 	if metricsPath != "" {
 		stampy.Stamp(metricsPath, "fissile", "create-compilation-image", "start")
 		defer stampy.Stamp(metricsPath, "fissile", "create-compilation-image", "done")
 	}
 
+	phase := util.Tracing.StartPhase("create-compilation-image")
+	defer func() { phase.End(err) }()
+
 	dockerManager, err := docker.NewImageManager()
 	if err != nil {
-		return fmt.Errorf("Error connecting to docker: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
 	}
 
 	baseImage, err := dockerManager.FindImage(baseImageName)
@@ -115,6 +139,7 @@ func (f *Fissile) CreateBaseCompilationImage(baseImageName, repository, metricsP
 	if err != nil {
 		return fmt.Errorf("Error creating a new compilator: %s", err.Error())
 	}
+	comp.SetScratchDir(scratchDir)
 
 	if _, err := comp.CreateCompilationBase(baseImageName); err != nil {
 		return fmt.Errorf("Error creating compilation base image: %s", err.Error())
@@ -124,15 +149,18 @@ func (f *Fissile) CreateBaseCompilationImage(baseImageName, repository, metricsP
 }
 
 // GenerateBaseDockerImage generates a base docker image to be used as a FROM for role images
-func (f *Fissile) GenerateBaseDockerImage(targetPath, baseImage, metricsPath string, noBuild bool, repository string) error {
+func (f *Fissile) GenerateBaseDockerImage(targetPath, baseImage, metricsPath string, noBuild bool, repository string) (err error) {
 	if metricsPath != "" {
 		stampy.Stamp(metricsPath, "fissile", "create-role-base", "start")
 		defer stampy.Stamp(metricsPath, "fissile", "create-role-base", "done")
 	}
 
+	phase := util.Tracing.StartPhase("create-role-base")
+	defer func() { phase.End(err) }()
+
 	dockerManager, err := docker.NewImageManager()
 	if err != nil {
-		return fmt.Errorf("Error connecting to docker: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
 	}
 
 	baseImageName := builder.GetBaseImageName(repository, f.Version)
@@ -173,7 +201,7 @@ func (f *Fissile) GenerateBaseDockerImage(targetPath, baseImage, metricsPath str
 	err = dockerManager.BuildImageFromCallback(baseImageName, stdoutWriter, tarPopulator)
 	if err != nil {
 		log.WriteTo(f.UI)
-		return fmt.Errorf("Error building base image: %s", err)
+		return categorizedErrorf(ErrorCategoryDocker, "Error building base image: %s", err)
 	}
 	f.UI.Println(color.GreenString("Done."))
 
@@ -189,8 +217,19 @@ func (f *Fissile) ListPackages() error {
 	for _, release := range f.releases {
 		f.UI.Println(color.GreenString("Dev release %s (%s)", color.YellowString(release.Name), color.MagentaString(release.Version)))
 
-		for _, pkg := range release.Packages {
+		// Packages are listed after their dependencies
+		for _, pkg := range release.Packages.DependencyOrder() {
 			f.UI.Printf("%s (%s)\n", color.YellowString(pkg.Name), color.WhiteString(pkg.Version))
+			for _, dependency := range pkg.Dependencies {
+				f.UI.Printf("  depends on %s\n", dependency.Name)
+			}
+			for _, blob := range pkg.Blobs {
+				f.UI.Printf("  blob %s (sha1 %s)", blob.Path, blob.SHA1)
+				if blob.URL != "" {
+					f.UI.Printf(" from %s", color.CyanString(blob.URL))
+				}
+				f.UI.Println()
+			}
 		}
 
 		f.UI.Printf(
@@ -296,8 +335,10 @@ func (f *Fissile) collectProperties() map[string]map[string]map[string]interface
 	return result
 }
 
-// Compile will compile a list of dev BOSH releases
-func (f *Fissile) Compile(repository, targetPath, roleManifestPath, metricsPath string, workerCount int) error {
+// Compile will compile a list of dev BOSH releases. The triage bundles of
+// packages that fail to compile are written to triageDir, unless it is empty.
+// With explain, it prints why each package is compiled or skipped.
+func (f *Fissile) Compile(repository, targetPath, roleManifestPath, metricsPath, triageDir string, workerCount int, limits compilator.ResourceLimits, explain bool) (err error) {
 	if len(f.releases) == 0 {
 		return fmt.Errorf("Releases not loaded")
 	}
@@ -307,14 +348,17 @@ func (f *Fissile) Compile(repository, targetPath, roleManifestPath, metricsPath
 		defer stampy.Stamp(metricsPath, "fissile", "compile-packages", "done")
 	}
 
+	phase := util.Tracing.StartPhase("compile-packages")
+	defer func() { phase.End(err) }()
+
 	dockerManager, err := docker.NewImageManager()
 	if err != nil {
-		return fmt.Errorf("Error connecting to docker: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
 	}
 
-	roleManifest, err := model.LoadRoleManifest(roleManifestPath, f.releases)
+	roleManifest, err := f.loadRoleManifest(roleManifestPath)
 	if err != nil {
-		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
 	}
 
 	f.UI.Println(color.GreenString("Compiling packages for dev releases:"))
@@ -327,8 +371,12 @@ func (f *Fissile) Compile(repository, targetPath, roleManifestPath, metricsPath
 		return fmt.Errorf("Error creating a new compilator: %s", err.Error())
 	}
 
+	comp.SetResourceLimits(limits)
+	comp.SetTriageDir(triageDir)
+	comp.SetExplain(explain)
+
 	if err := comp.Compile(workerCount, f.releases, roleManifest); err != nil {
-		return fmt.Errorf("Error compiling packages: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryCompile, "Error compiling packages: %s", err.Error())
 	}
 
 	return nil
@@ -401,7 +449,7 @@ func (f *Fissile) GeneratePackagesRoleImage(repository string, roleManifest *mod
 
 	dockerManager, err := docker.NewImageManager()
 	if err != nil {
-		return fmt.Errorf("Error connecting to docker: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
 	}
 
 	packagesLayerImageName := packagesImageBuilder.GetRolePackageImageName(roleManifest)
@@ -436,15 +484,17 @@ func (f *Fissile) GeneratePackagesRoleImage(repository string, roleManifest *mod
 	err = dockerManager.BuildImageFromCallback(packagesLayerImageName, stdoutWriter, tarPopulator)
 	if err != nil {
 		log.WriteTo(f.UI)
-		return fmt.Errorf("Error building packages layer docker image: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryDocker, "Error building packages layer docker image: %s", err.Error())
 	}
 	f.UI.Println(color.GreenString("Done."))
 
 	return nil
 }
 
-// GenerateRoleImages generates all role images using dev releases
-func (f *Fissile) GenerateRoleImages(targetPath, repository, metricsPath string, noBuild, force bool, workerCount int, rolesManifestPath, compiledPackagesPath, lightManifestPath, darkManifestPath string) error {
+// GenerateRoleImages generates all role images using dev releases. With
+// traceStartup, the images trace their startup scripts by default. With
+// explain, it prints why each role image is built or skipped.
+func (f *Fissile) GenerateRoleImages(targetPath, repository, metricsPath string, noBuild, force, traceStartup, devMounts, explain bool, workerCount int, rolesManifestPath, compiledPackagesPath, lightManifestPath, darkManifestPath string) (err error) {
 	if len(f.releases) == 0 {
 		return fmt.Errorf("Releases not loaded")
 	}
@@ -454,11 +504,16 @@ func (f *Fissile) GenerateRoleImages(targetPath, repository, metricsPath string,
 		defer stampy.Stamp(metricsPath, "fissile", "create-role-images", "done")
 	}
 
-	roleManifest, err := model.LoadRoleManifest(rolesManifestPath, f.releases)
+	phase := util.Tracing.StartPhase("create-role-images")
+	defer func() { phase.End(err) }()
+
+	roleManifest, err := f.loadRoleManifest(rolesManifestPath)
 	if err != nil {
-		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
 	}
 
+	roleManifest.SetImageOptions(model.RoleImageOptions{TraceStartup: traceStartup, DevMounts: devMounts})
+
 	packagesImageBuilder, err := builder.NewPackagesImageBuilder(
 		repository,
 		compiledPackagesPath,
@@ -475,6 +530,12 @@ func (f *Fissile) GenerateRoleImages(targetPath, repository, metricsPath string,
 		return err
 	}
 
+	for _, role := range roleManifest.Roles {
+		if err := role.CheckImageSizeBudget(compiledPackagesPath); err != nil {
+			return err
+		}
+	}
+
 	packagesLayerImageName := packagesImageBuilder.GetRolePackageImageName(roleManifest)
 
 	roleBuilder, err := builder.NewRoleImageBuilder(
@@ -492,8 +553,10 @@ func (f *Fissile) GenerateRoleImages(targetPath, repository, metricsPath string,
 		return err
 	}
 
+	roleBuilder.SetExplain(explain)
+
 	if err := roleBuilder.BuildRoleImages(roleManifest.Roles, repository, packagesLayerImageName, force, noBuild, workerCount); err != nil {
-		return err
+		return categorize(ErrorCategoryDocker, err)
 	}
 
 	return nil
@@ -515,16 +578,20 @@ func (f *Fissile) ListRoleImages(repository string, rolesManifestPath string, ex
 	if existingOnDocker {
 		dockerManager, err = docker.NewImageManager()
 		if err != nil {
-			return fmt.Errorf("Error connecting to docker: %s", err.Error())
+			return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
 		}
 	}
 
-	rolesManifest, err := model.LoadRoleManifest(rolesManifestPath, f.releases)
+	rolesManifest, err := f.loadRoleManifest(rolesManifestPath)
 	if err != nil {
-		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
 	}
 
 	for _, role := range rolesManifest.Roles {
+		if role.Type == model.RoleTypeDocker {
+			// Not built by fissile
+			continue
+		}
 		imageName := builder.GetRoleDevImageName(repository, role, role.GetRoleDevVersion())
 
 		if !existingOnDocker {
@@ -554,6 +621,218 @@ func (f *Fissile) ListRoleImages(repository string, rolesManifestPath string, ex
 	return nil
 }
 
+// PushRoleImages pushes the dev role images to a registry, under the names
+// the generated Kubernetes configuration uses for them
+func (f *Fissile) PushRoleImages(repository, registry, organization, rolesManifestPath string) (err error) {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	if registry == "" {
+		return categorizedErrorf(ErrorCategoryPush, "A docker registry is needed to push images")
+	}
+
+	phase := util.Tracing.StartPhase("push-role-images")
+	defer func() { phase.End(err) }()
+
+	dockerManager, err := docker.NewImageManager()
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
+	}
+
+	rolesManifest, err := f.loadRoleManifest(rolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	settings := &kube.ExportSettings{
+		Registry:     registry,
+		Organization: organization,
+		Repository:   repository,
+	}
+	for _, role := range rolesManifest.Roles {
+		if role.Type == model.RoleTypeDocker {
+			// Pulled from where it is by the cluster
+			continue
+		}
+		imageName := builder.GetRoleDevImageName(repository, role, role.GetRoleDevVersion())
+		pushName := kube.ContainerImageName(role, settings)
+
+		f.UI.Printf("Pushing image %s\n", color.YellowString(pushName))
+		log := new(bytes.Buffer)
+		span := util.Tracing.StartSpan("push-image")
+		span.SetAttribute("role", role.Name)
+		span.SetAttribute("image", pushName)
+		err := dockerManager.PushImage(imageName, pushName, log)
+		span.End(err)
+		if err != nil {
+			log.WriteTo(f.UI)
+			return categorize(ErrorCategoryPush, err)
+		}
+	}
+
+	return nil
+}
+
+// ShowRoles displays information about the given roles from the role
+// manifest (or all of them), including the probes their pods will use and the
+// size of their compiled packages
+func (f *Fissile) ShowRoles(rolesManifestPath, compiledPackagesPath string, roleNames []string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	rolesManifest, err := f.loadRoleManifest(rolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	roles := rolesManifest.Roles
+	if len(roleNames) > 0 {
+		roles = make(model.Roles, 0, len(roleNames))
+		for _, roleName := range roleNames {
+			role := rolesManifest.LookupRole(roleName)
+			if role == nil {
+				return fmt.Errorf("Role %s not found in the roles manifest", roleName)
+			}
+			roles = append(roles, role)
+		}
+	}
+
+	for _, role := range roles {
+		f.UI.Printf("%s (%s)\n", color.GreenString(role.Name), color.MagentaString(string(role.Type)))
+
+		jobNames := make([]string, 0, len(role.JobNameList))
+		for _, roleJob := range role.JobNameList {
+			jobNames = append(jobNames, fmt.Sprintf("%s/%s", roleJob.ReleaseName, roleJob.Name))
+		}
+		f.UI.Printf("  Jobs: %s\n", color.YellowString(strings.Join(jobNames, ", ")))
+
+		if len(role.Tags) > 0 {
+			f.UI.Printf("  Tags: %s\n", color.YellowString(strings.Join(role.Tags, ", ")))
+		}
+
+		f.describePackageSizes(role, compiledPackagesPath)
+
+		if role.Run == nil {
+			f.UI.Println()
+			continue
+		}
+
+		probes, err := kube.ResolveProbes(role)
+		if err != nil {
+			return err
+		}
+		f.UI.Printf("  Liveness probe: %s (from %s)\n", describeProbe(probes.Liveness), color.CyanString(string(probes.LivenessSource)))
+		f.UI.Printf("  Readiness probe: %s (from %s)\n\n", describeProbe(probes.Readiness), color.CyanString(string(probes.ReadinessSource)))
+	}
+
+	return nil
+}
+
+// describePackageSizes shows the sizes of the compiled packages of a role,
+// for the packages that have been compiled
+func (f *Fissile) describePackageSizes(role *model.Role, compiledPackagesPath string) {
+	var total int64
+	var sizes []string
+	uncompiled := 0
+
+	for _, pkg := range role.GetPackages() {
+		if _, err := os.Stat(pkg.GetPackageCompiledDir(compiledPackagesPath)); err != nil {
+			uncompiled++
+			continue
+		}
+		size, err := pkg.GetCompiledSize(compiledPackagesPath)
+		if err != nil {
+			uncompiled++
+			continue
+		}
+		total += size
+		sizes = append(sizes, fmt.Sprintf("%s (%s)", pkg.Name, util.FormatBytes(uint64(size))))
+	}
+
+	if len(sizes) > 0 {
+		f.UI.Printf("  Packages: %s\n", color.YellowString(strings.Join(sizes, ", ")))
+	}
+
+	summary := util.FormatBytes(uint64(total))
+	if role.Run != nil && role.Run.ImageSizeBudget > 0 {
+		summary = fmt.Sprintf("%s of %dMB budget", summary, role.Run.ImageSizeBudget)
+	}
+	if uncompiled > 0 {
+		summary = fmt.Sprintf("%s, %d packages not compiled", summary, uncompiled)
+	}
+	f.UI.Printf("  Compiled size: %s\n", color.YellowString(summary))
+}
+
+// describeProbe returns a short human readable description of a probe
+func describeProbe(probe *v1.Probe) string {
+	switch {
+	case probe == nil:
+		return "none"
+	case probe.Exec != nil:
+		return fmt.Sprintf("exec %s", strings.Join(probe.Exec.Command, " "))
+	case probe.TCPSocket != nil:
+		return fmt.Sprintf("tcp port %s", probe.TCPSocket.Port.String())
+	case probe.HTTPGet != nil:
+		host := probe.HTTPGet.Host
+		if host == "" {
+			host = "container-ip"
+		}
+		return fmt.Sprintf("%s %s:%s%s", strings.ToLower(string(probe.HTTPGet.Scheme)), host, probe.HTTPGet.Port.String(), probe.HTTPGet.Path)
+	default:
+		return "unknown"
+	}
+}
+
+// ShowConfigurationTemplate displays, for every role (or only the given one),
+// the effective value of a configuration template key and the level of the
+// role manifest that supplied it
+func (f *Fissile) ShowConfigurationTemplate(rolesManifestPath, key, roleName string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	rolesManifest, err := f.loadRoleManifest(rolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	roles := rolesManifest.Roles
+	if roleName != "" {
+		role := rolesManifest.LookupRole(roleName)
+		if role == nil {
+			return fmt.Errorf("Role %s not found in the roles manifest", roleName)
+		}
+		roles = model.Roles{role}
+	}
+
+	for _, role := range roles {
+		f.UI.Printf("%s:\n", color.GreenString(role.Name))
+
+		origins := role.ConfigurationTemplateOrigins(key)
+		if len(origins) == 0 {
+			f.UI.Printf("  %s\n", color.RedString("not defined"))
+			continue
+		}
+
+		for i := len(origins) - 1; i >= 0; i-- {
+			origin := origins[i]
+			level := string(origin.Level)
+			if origin.Job != "" {
+				level = fmt.Sprintf("%s of %s", level, origin.Job)
+			}
+			if i == len(origins)-1 {
+				f.UI.Printf("  %s (from %s)\n", color.YellowString(origin.Value), color.CyanString(level))
+			} else {
+				f.UI.Printf("  overrides %s (from %s)\n", origin.Value, level)
+			}
+		}
+	}
+
+	return nil
+}
+
 //LoadReleases loads information about BOSH releases
 func (f *Fissile) LoadReleases(releasePaths, releaseNames, releaseVersions []string, cacheDir string) error {
 	releases := make([]*model.Release, len(releasePaths))
@@ -569,9 +848,9 @@ func (f *Fissile) LoadReleases(releasePaths, releaseNames, releaseVersions []str
 			releaseVersion = releaseVersions[idx]
 		}
 
-		release, err := model.NewDevRelease(releasePath, releaseName, releaseVersion, cacheDir)
+		release, err := model.NewDevReleaseWithOptions(releasePath, releaseName, releaseVersion, cacheDir, f.releaseOptions)
 		if err != nil {
-			return fmt.Errorf("Error loading release information: %s", err.Error())
+			return categorizedErrorf(ErrorCategoryRelease, "Error loading release information: %s", err.Error())
 		}
 
 		releases[idx] = release
@@ -580,7 +859,7 @@ func (f *Fissile) LoadReleases(releasePaths, releaseNames, releaseVersions []str
 	f.releases = releases
 	err := f.injectPatchPropertiesJobSpec()
 	if err != nil {
-		return fmt.Errorf("Error loading release information: %s", err)
+		return categorizedErrorf(ErrorCategoryRelease, "Error loading release information: %s", err)
 	}
 	return nil
 }
@@ -716,36 +995,100 @@ func compareHashes(v1Hash, v2Hash keyHash) *HashDiffs {
 	return &HashDiffs{AddedKeys: added, DeletedKeys: deleted, ChangedValues: changed}
 }
 
+// KubeOptions are the inputs of GenerateKube
+type KubeOptions struct {
+	RolesManifestPath string
+	OutputDir         string
+	Repository        string
+	Registry          string
+	Organization      string
+	Values            VariableValues // Values of the configuration variables, in place of their defaults
+	UseMemoryLimits   bool
+	ConfigChecksums   bool     // Annotate pod templates with the checksum of the configuration they read
+	OnlyKinds         []string // Kinds of objects to generate; all of them when empty
+	SkipKinds         []string // Kinds of objects not to generate
+	DeployScript      bool     // Write a script deploying the configurations in order
+	MergeExisting     bool     // Keep the annotations and labels users added to the configurations in OutputDir
+	DiscoveryName     string   // How roles find each other; see kube.NewDiscovery
+	StaticHosts       string   // Hosts of the static discovery
+	PausedRoles       []string // Roles whose objects are generated without any pods
+	PlatformName      string   // Cloud platform the hints of the roles are translated for; see kube.NewPlatformMapper
+}
+
 // GenerateKube will create a set of configuration files suitable for deployment
 // on Kubernetes
-func (f *Fissile) GenerateKube(rolesManifestPath, outputDir, repository, registry, organization string, defaultFiles []string, useMemoryLimits bool) error {
+func (f *Fissile) GenerateKube(opts KubeOptions) error {
+
+	kinds, err := kube.NewKindFilter(opts.OnlyKinds, opts.SkipKinds)
+	if err != nil {
+		return categorize(ErrorCategoryKube, err)
+	}
+	discovery, err := kube.NewDiscovery(opts.DiscoveryName, opts.StaticHosts)
+	if err != nil {
+		return categorize(ErrorCategoryKube, err)
+	}
+	platform, err := kube.NewPlatformMapper(opts.PlatformName)
+	if err != nil {
+		return categorize(ErrorCategoryKube, err)
+	}
 
-	rolesManifest, err := model.LoadRoleManifest(rolesManifestPath, f.releases)
+	rolesManifest, err := f.loadRoleManifest(opts.RolesManifestPath)
 	if err != nil {
-		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	paused := map[string]bool{}
+	for _, roleName := range opts.PausedRoles {
+		if rolesManifest.LookupRole(roleName) == nil {
+			return categorizedErrorf(ErrorCategoryKube, "Paused role %s is not in the role manifest", roleName)
+		}
+		paused[roleName] = true
 	}
 
 	f.UI.Println("Loading defaults from env files")
-	defaults, err := godotenv.Read(defaultFiles...)
+	defaults, err := opts.Values.Read(rolesManifest)
 	if err != nil {
 		return err
 	}
 
 	settings := &kube.ExportSettings{
 		Defaults:        defaults,
-		Registry:        registry,
-		Organization:    organization,
-		Repository:      repository,
-		UseMemoryLimits: useMemoryLimits,
+		Registry:        opts.Registry,
+		Organization:    opts.Organization,
+		Repository:      opts.Repository,
+		UseMemoryLimits: opts.UseMemoryLimits,
+		ConfigChecksums: opts.ConfigChecksums,
+		Kinds:           kinds,
+		Discovery:       discovery,
+		PausedRoles:     paused,
+		Platform:        platform,
 	}
 
+	generators := kube.NewGenerators()
+	written := map[string]bool{}
+
 	for _, role := range rolesManifest.Roles {
-		roleTypeDir := filepath.Join(outputDir, string(role.Type))
+		objects, err := kube.GenerateRoleObjects(role, settings, generators)
+		if err != nil {
+			return categorize(ErrorCategoryKube, err)
+		}
+		if len(objects) == 0 {
+			// All objects of the role are of kinds that are not selected
+			continue
+		}
+
+		roleTypeDir := filepath.Join(opts.OutputDir, string(role.Type))
 		if err = os.MkdirAll(roleTypeDir, 0755); err != nil {
 			return err
 		}
 		outputPath := filepath.Join(roleTypeDir, fmt.Sprintf("%s.yml", role.Name))
 
+		if opts.MergeExisting {
+			if err := mergeExistingConfig(objects, outputPath); err != nil {
+				return categorize(ErrorCategoryKube, err)
+			}
+		}
+
 		f.UI.Printf("Writing config %s for role %s\n",
 			color.CyanString(outputPath),
 			color.CyanString(role.Name),
@@ -757,53 +1100,139 @@ func (f *Fissile) GenerateKube(rolesManifestPath, outputDir, repository, registr
 		}
 		defer outputFile.Close()
 
-		switch role.Type {
-		case model.RoleTypeBoshTask:
-			job, err := kube.NewJob(role, settings)
-			if err != nil {
-				return err
+		for _, object := range objects {
+			if err := kube.WriteYamlConfig(object, outputFile); err != nil {
+				return categorize(ErrorCategoryKube, err)
 			}
+		}
+		written[role.Name] = true
+	}
 
-			if err := kube.WriteYamlConfig(job, outputFile); err != nil {
-				return err
-			}
+	if opts.DeployScript {
+		if err := f.writeDeployScript(rolesManifest, written, paused, opts.OutputDir); err != nil {
+			return categorize(ErrorCategoryKube, err)
+		}
+	}
 
-		case model.RoleTypeBosh:
-			needsStorage := len(role.Run.PersistentVolumes) != 0 || len(role.Run.SharedVolumes) != 0
+	f.reportDevices(rolesManifest.Roles)
+	f.reportExternalObjects(rolesManifest.Roles)
+	f.reportUnsupportedPlatformHints(rolesManifest.Roles, platform)
 
-			if role.HasTag("clustered") || needsStorage {
-				statefulSet, deps, err := kube.NewStatefulSet(role, settings)
-				if err != nil {
-					return err
-				}
+	return nil
+}
 
-				if err := kube.WriteYamlConfig(statefulSet, outputFile); err != nil {
-					return err
-				}
+// mergeExistingConfig keeps the annotations and labels users added to the
+// objects of a role configuration written before; see
+// kube.MergeExistingMetadata
+func mergeExistingConfig(objects []runtime.Object, outputPath string) error {
+	existingFile, err := os.Open(outputPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer existingFile.Close()
 
-				if err := kube.WriteYamlConfig(deps, outputFile); err != nil {
-					return err
-				}
+	existing, err := kube.ReadExistingMetadata(existingFile)
+	if err != nil {
+		return fmt.Errorf("Error reading existing config %s: %s", outputPath, err.Error())
+	}
+	return kube.MergeExistingMetadata(objects, existing)
+}
 
-				continue
-			}
+// writeDeployScript writes the script applying the written role
+// configurations in the order of their dependencies
+func (f *Fissile) writeDeployScript(rolesManifest *model.RoleManifest, written, paused map[string]bool, outputDir string) error {
+	waves, err := rolesManifest.DeployWaves()
+	if err != nil {
+		return err
+	}
 
-			deployment, svc, err := kube.NewDeployment(role, settings)
-			if err != nil {
-				return err
+	var writtenWaves []model.Roles
+	for _, wave := range waves {
+		var writtenWave model.Roles
+		for _, role := range wave {
+			if written[role.Name] {
+				writtenWave = append(writtenWave, role)
 			}
+		}
+		writtenWaves = append(writtenWaves, writtenWave)
+	}
 
-			if err := kube.WriteYamlConfig(deployment, outputFile); err != nil {
-				return err
-			}
+	scriptPath := filepath.Join(outputDir, kube.DeployScriptName)
+	f.UI.Printf("Writing deploy script %s\n", color.CyanString(scriptPath))
 
-			if svc != nil {
-				if err := kube.WriteYamlConfig(svc, outputFile); err != nil {
-					return err
-				}
+	scriptFile, err := os.OpenFile(scriptPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer scriptFile.Close()
+
+	return kube.WriteDeployScript(writtenWaves, paused, scriptFile)
+}
+
+// reportUnsupportedPlatformHints warns about the platform hints of roles the
+// platform has no equivalent for, which are left out of their services
+func (f *Fissile) reportUnsupportedPlatformHints(roles model.Roles, platform kube.PlatformMapper) {
+	for _, role := range roles {
+		if role.Run == nil || len(role.Run.PlatformHints) == 0 {
+			continue
+		}
+		if _, unsupported := platform.ServiceAnnotations(role); len(unsupported) > 0 {
+			f.UI.Println(color.YellowString("Warning: platform %s doesn't support the platform hints of role %s: %s",
+				platform.Name(), role.Name, strings.Join(unsupported, ", ")))
+		}
+	}
+}
+
+// reportDevices lists the roles that use host devices, so cluster operators
+// know which nodes need device plugins installed, and which roles run
+// privileged to get to device nodes
+func (f *Fissile) reportDevices(roles model.Roles) {
+	var resources, privilegedRoles []string
+	plugins := map[string][]string{}
+	for _, role := range roles {
+		if role.Run == nil {
+			continue
+		}
+		if role.Run.NeedsPrivileged() {
+			privilegedRoles = append(privilegedRoles, role.Name)
+		}
+		for resource := range role.Run.GetExtendedResources() {
+			if len(plugins[resource]) == 0 {
+				resources = append(resources, resource)
 			}
+			plugins[resource] = append(plugins[resource], role.Name)
 		}
 	}
 
-	return nil
+	if len(resources) > 0 {
+		f.UI.Println("Device plugins needed on the cluster:")
+		sort.Strings(resources)
+		for _, resource := range resources {
+			f.UI.Printf("  %s: %s\n", color.YellowString(resource), strings.Join(plugins[resource], ", "))
+		}
+	}
+
+	if len(privilegedRoles) > 0 {
+		f.UI.Printf("Roles running privileged to use host device nodes: %s\n", color.YellowString(strings.Join(privilegedRoles, ", ")))
+	}
+}
+
+// reportExternalObjects lists the ConfigMaps and Secrets the roles mount but
+// fissile does not create, so cluster operators can provide them
+func (f *Fissile) reportExternalObjects(roles model.Roles) {
+	objects := model.ExternalObjects(roles)
+	if len(objects) == 0 {
+		return
+	}
+
+	f.UI.Println("ConfigMaps and Secrets to provide on the cluster:")
+	for _, object := range objects {
+		need := color.RedString("required")
+		if object.Optional {
+			need = color.GreenString("optional")
+		}
+		f.UI.Printf("  %s %s (%s): %s\n", object.Kind, color.YellowString(object.Name), need, strings.Join(object.Roles, ", "))
+	}
 }
@@ -177,3 +177,30 @@ func TestDevDiffConfigurations(t *testing.T) {
 	v, ok = hashDiffs.ChangedValues["cf.bogus.key"]
 	assert.False(ok)
 }
+
+func TestShowStats(t *testing.T) {
+	output := &bytes.Buffer{}
+	ui := termui.New(&bytes.Buffer{}, output, nil)
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/kube-generators.yml")
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	err = f.ShowStats(roleManifestPath, filepath.Join(workDir, "../test-assets/no-compiled-packages"))
+	if assert.NoError(err) {
+		assert.Contains(output.String(), "Roles: 4 (2 bosh, 2 bosh-task)\n")
+		assert.Contains(output.String(), "Memory: 128MB at minimum scale, 384MB at maximum scale\n")
+		assert.Contains(output.String(), "Exposed ports: 2 (0 public)\n")
+		assert.Contains(output.String(), "Role images: 4 (0B of compiled packages, 8 packages not compiled)\n")
+	}
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+)
+
+// GenerateFixture writes the minimal BOSH dev release described by a fixture
+// spec into outputDir. The directory must not exist yet, so that existing
+// releases can't be clobbered.
+func (f *Fissile) GenerateFixture(specPath, outputDir string) error {
+	spec, err := model.LoadFixtureSpec(specPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(outputDir); err == nil {
+		return fmt.Errorf("Output directory %s already exists", outputDir)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("Error accessing output directory: %s", err.Error())
+	}
+
+	if err := spec.Generate(outputDir); err != nil {
+		return fmt.Errorf("Error generating fixture: %s", err.Error())
+	}
+
+	f.UI.Printf("Generated release %s with %d jobs and %d packages in %s\n",
+		color.GreenString(spec.Name),
+		len(spec.Jobs),
+		len(spec.Packages),
+		color.CyanString(outputDir),
+	)
+	f.UI.Printf("Use %s as the cache directory when loading it\n",
+		color.CyanString(filepath.Join(outputDir, "bosh-cache")),
+	)
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v2"
+)
+
+// LintRoleManifest checks the role manifest against the lint rules, with the
+// defaults overridden by the lint config if one is given. When releases are
+// loaded, it also reports those the role manifest doesn't use, and the jobs
+// of them it doesn't use. With fix set, the
+// mechanical renames are written back to the role manifest first. It fails if
+// any finding has error severity.
+func (f *Fissile) LintRoleManifest(rolesManifestPath, lintConfigPath string, fix bool, outputFormat string) error {
+	config, err := model.LoadLintConfig(lintConfigPath)
+	if err != nil {
+		return err
+	}
+
+	findings, err := model.LintRoleManifest(rolesManifestPath, config, f.manifestOptions.Environment)
+	if err != nil {
+		return fmt.Errorf("Error linting roles manifest: %s", err.Error())
+	}
+	if len(f.releases) > 0 {
+		releaseFindings, err := model.LintReleases(rolesManifestPath, f.releases, config, f.manifestOptions.Environment)
+		if err != nil {
+			return fmt.Errorf("Error linting roles manifest: %s", err.Error())
+		}
+		findings = append(findings, releaseFindings...)
+	}
+
+	if fix {
+		contents, err := ioutil.ReadFile(rolesManifestPath)
+		if err != nil {
+			return err
+		}
+
+		fixed, unfixed := model.FixLintFindings(contents, findings)
+		if len(unfixed) < len(findings) {
+			if err := ioutil.WriteFile(rolesManifestPath, fixed, 0644); err != nil {
+				return fmt.Errorf("Error writing fixed roles manifest: %s", err.Error())
+			}
+			if outputFormat == "human" {
+				f.UI.Println(color.GreenString("Fixed %d findings in %s", len(findings)-len(unfixed), rolesManifestPath))
+			}
+		}
+		findings = unfixed
+	}
+
+	switch outputFormat {
+	case "human":
+		for _, finding := range findings {
+			line := finding.String()
+			if finding.Fix != "" {
+				line = fmt.Sprintf("%s (--fix renames it to %s)", line, finding.Fix)
+			}
+			switch finding.Severity {
+			case model.LintSeverityError:
+				f.UI.Println(color.RedString(line))
+			case model.LintSeverityInfo:
+				f.UI.Println(line)
+			default:
+				f.UI.Println(color.YellowString(line))
+			}
+		}
+		if len(findings) == 0 {
+			f.UI.Println(color.GreenString("No lint findings"))
+		}
+	case "json":
+		if findings == nil {
+			findings = []*model.LintFinding{}
+		}
+		buf, err := json.Marshal(findings)
+		if err != nil {
+			return err
+		}
+		f.UI.Printf("%s", buf)
+	case "yaml":
+		buf, err := yaml.Marshal(findings)
+		if err != nil {
+			return err
+		}
+		f.UI.Printf("%s", buf)
+	default:
+		return fmt.Errorf("Invalid output format '%s', expected one of human, json, or yaml", outputFormat)
+	}
+
+	errorCount := 0
+	for _, finding := range findings {
+		if finding.Severity == model.LintSeverityError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return categorizedErrorf(ErrorCategoryManifest, "The roles manifest has %d lint errors", errorCount)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hpcloud/fissile/docker"
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+)
+
+// CheckLock verifies the loaded releases, and the stemcell image if one is
+// given, against the lock file. A missing lock file is created. If updateLock
+// is set, the lock file is rewritten instead of failing on drift.
+func (f *Fissile) CheckLock(lockPath string, updateLock bool, stemcellImageName string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	stemcell := ""
+	if stemcellImageName != "" {
+		var err error
+		if stemcell, err = getStemcellDigest(stemcellImageName); err != nil {
+			return err
+		}
+	}
+
+	current := model.NewLock(f.releases, stemcell)
+
+	locked, err := model.LoadLock(lockPath)
+	if os.IsNotExist(err) {
+		f.UI.Println(color.GreenString("Creating lock file %s", color.YellowString(lockPath)))
+		return current.Write(lockPath)
+	} else if err != nil {
+		return fmt.Errorf("Error loading lock file: %s", err.Error())
+	}
+
+	if drift := locked.Drift(current); len(drift) > 0 {
+		if !updateLock {
+			return fmt.Errorf("Build inputs differ from lock file %s (use --update-lock to accept them):\n  %s",
+				lockPath, strings.Join(drift, "\n  "))
+		}
+		f.UI.Println(color.YellowString("Updating lock file %s:\n  %s", lockPath, strings.Join(drift, "\n  ")))
+		return current.Write(lockPath)
+	}
+
+	if locked.Stemcell == "" && current.Stemcell != "" {
+		// The lock was created by a command that does not use the stemcell;
+		// record it now that it is known
+		locked.Stemcell = current.Stemcell
+		return locked.Write(lockPath)
+	}
+
+	f.UI.Println(color.GreenString("Build inputs match lock file %s", color.YellowString(lockPath)))
+	return nil
+}
+
+// getStemcellDigest returns the ID of the stemcell image, or an empty string
+// if the image does not exist
+func getStemcellDigest(imageName string) (string, error) {
+	dockerManager, err := docker.NewImageManager()
+	if err != nil {
+		return "", categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
+	}
+
+	image, err := dockerManager.FindImage(imageName)
+	if err == docker.ErrImageNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return image.ID, nil
+}
+
+// ShowLockDiff prints what changed between two lock files: the releases whose
+// versions or commits changed, the packages and jobs whose fingerprints
+// changed, and the stemcell. It then lists the roles of the role manifest,
+// as built from the loaded releases, whose images are affected, with the
+// changes affecting them.
+func (f *Fissile) ShowLockDiff(oldLockPath, newLockPath, rolesManifestPath string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	oldLock, err := model.LoadLock(oldLockPath)
+	if err != nil {
+		return fmt.Errorf("Error loading lock file: %s", err.Error())
+	}
+	newLock, err := model.LoadLock(newLockPath)
+	if err != nil {
+		return fmt.Errorf("Error loading lock file: %s", err.Error())
+	}
+
+	rolesManifest, err := f.loadRoleManifest(rolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	diff := oldLock.Diff(newLock)
+	if diff.IsEmpty() {
+		f.UI.Println(color.GreenString("No changes between %s and %s", oldLockPath, newLockPath))
+		return nil
+	}
+
+	for _, release := range diff.Releases {
+		switch {
+		case release.OldVersion == "":
+			f.UI.Printf("Release %s: added at version %s\n", color.YellowString(release.Name), release.NewVersion)
+		case release.NewVersion == "":
+			f.UI.Printf("Release %s: removed, was at version %s\n", color.YellowString(release.Name), release.OldVersion)
+		case release.OldVersion != release.NewVersion:
+			f.UI.Printf("Release %s: version %s -> %s\n", color.YellowString(release.Name), release.OldVersion, color.GreenString(release.NewVersion))
+		default:
+			f.UI.Printf("Release %s: version %s\n", color.YellowString(release.Name), release.NewVersion)
+		}
+		if release.OldCommitHash != release.NewCommitHash && release.OldVersion != "" && release.NewVersion != "" {
+			f.UI.Printf("  commit %s -> %s\n", release.OldCommitHash, release.NewCommitHash)
+		}
+		for _, change := range release.Packages {
+			f.UI.Printf("  package %s: %s\n", change.Name, describeFingerprintDiff(change))
+		}
+		for _, change := range release.Jobs {
+			f.UI.Printf("  job %s: %s\n", change.Name, describeFingerprintDiff(change))
+		}
+	}
+	if diff.NewStemcell != "" {
+		f.UI.Printf("Stemcell: %s -> %s\n", diff.OldStemcell, color.GreenString(diff.NewStemcell))
+	}
+
+	f.UI.Println("Affected roles:")
+	affected := false
+	for _, role := range rolesManifest.Roles {
+		if changes := diff.RoleChanges(role); len(changes) > 0 {
+			f.UI.Printf("  %s: %s\n", color.CyanString(role.Name), strings.Join(changes, ", "))
+			affected = true
+		}
+	}
+	if !affected {
+		f.UI.Println("  none")
+	}
+
+	return nil
+}
+
+// describeFingerprintDiff describes how the fingerprint of a package or job
+// changed
+func describeFingerprintDiff(change *model.FingerprintDiff) string {
+	switch {
+	case change.Old == "":
+		return fmt.Sprintf("added (%s)", change.New)
+	case change.New == "":
+		return fmt.Sprintf("removed (was %s)", change.Old)
+	}
+	return fmt.Sprintf("%s -> %s", change.Old, change.New)
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLock(t *testing.T) {
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/ntp-release")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+
+	tempDir, err := ioutil.TempDir("", "fissile-lock")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+	lockPath := filepath.Join(tempDir, "fissile.lock")
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	// The first run creates the lock, the second one verifies it
+	assert.NoError(f.CheckLock(lockPath, false, ""))
+	assert.NoError(f.CheckLock(lockPath, false, ""))
+
+	lock, err := model.LoadLock(lockPath)
+	if !assert.NoError(err) {
+		return
+	}
+	lock.Releases[0].Version = "0"
+	assert.NoError(lock.Write(lockPath))
+
+	err = f.CheckLock(lockPath, false, "")
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "locked 0")
+	}
+
+	assert.NoError(f.CheckLock(lockPath, true, ""))
+	assert.NoError(f.CheckLock(lockPath, false, ""))
+}
+
+func TestShowLockDiff(t *testing.T) {
+	output := &bytes.Buffer{}
+	ui := termui.New(&bytes.Buffer{}, output, nil)
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/deploy-order.yml")
+
+	tempDir, err := ioutil.TempDir("", "fissile-lock")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	newLockPath := filepath.Join(tempDir, "new.lock")
+	if !assert.NoError(model.NewLock(f.releases, "").Write(newLockPath)) {
+		return
+	}
+	oldLock := model.NewLock(f.releases, "")
+	oldLock.Releases[0].Version = "0"
+	oldLock.Releases[0].Jobs["tor"] = "old-fingerprint"
+	oldLockPath := filepath.Join(tempDir, "old.lock")
+	if !assert.NoError(oldLock.Write(oldLockPath)) {
+		return
+	}
+
+	if assert.NoError(f.ShowLockDiff(oldLockPath, newLockPath, roleManifestPath)) {
+		assert.Contains(output.String(), "Release tor: version 0 -> ")
+		assert.Contains(output.String(), "  job tor: old-fingerprint -> ")
+		assert.Contains(output.String(), "Affected roles:\n  api: job tor\n")
+		assert.NotContains(output.String(), "myrole")
+	}
+
+	output.Reset()
+	if assert.NoError(f.ShowLockDiff(newLockPath, newLockPath, roleManifestPath)) {
+		assert.Contains(output.String(), "No changes between")
+	}
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hpcloud/fissile/kube"
+	"github.com/hpcloud/fissile/model"
+	"github.com/hpcloud/fissile/registry"
+	"github.com/hpcloud/fissile/util"
+
+	"github.com/fatih/color"
+	dockerclient "github.com/fsouza/go-dockerclient"
+)
+
+// newRegistryClient is a stub to be replaced by the unit test; credentials
+// for the registry are read from the docker configuration of the user, if it
+// has them
+var newRegistryClient = func(registryHost string) *registry.Client {
+	client := &registry.Client{}
+	if configs, err := dockerclient.NewAuthConfigurationsFromDockerCfg(); err == nil {
+		auth := configs.Configs[registryHost]
+		client.Username = auth.Username
+		client.Password = auth.Password
+	}
+	return client
+}
+
+// OrphanOptions are the inputs of FindOrphanedImages
+type OrphanOptions struct {
+	Repository        string
+	Registry          string
+	Organization      string
+	RolesManifestPath string
+	LockPath          string // Lock file the current images are built from; not checked when empty
+	Delete            bool   // Delete the orphaned images from the registry
+}
+
+// FindOrphanedImages lists the role images in the registry namespace of the
+// roles, the images carrying the role label of fissile, that are not the
+// image of a role at its current dev version. The current dev versions are
+// the ones of the loaded releases, which have to match the lock file. The
+// roles of every feature and environment of the manifest are current, whatever
+// the selected ones. If opts.Delete is set, the orphaned images are deleted
+// from the registry, except the ones that are also the image of a current role.
+func (f *Fissile) FindOrphanedImages(opts OrphanOptions) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+	if opts.Registry == "" {
+		return categorizedErrorf(ErrorCategoryPush, "A docker registry is needed to find orphaned images")
+	}
+
+	rolesManifests, err := model.LoadRoleManifestVariants(opts.RolesManifestPath, f.releases, f.manifestOptions)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	if opts.LockPath != "" {
+		locked, err := model.LoadLock(opts.LockPath)
+		if err != nil {
+			return fmt.Errorf("Error loading lock file: %s", err.Error())
+		}
+		if drift := locked.Drift(model.NewLock(f.releases, "")); len(drift) > 0 {
+			return fmt.Errorf("Build inputs differ from lock file %s, so the current images are not known:\n  %s",
+				opts.LockPath, strings.Join(drift, "\n  "))
+		}
+	}
+
+	settings := &kube.ExportSettings{
+		Registry:     opts.Registry,
+		Organization: opts.Organization,
+		Repository:   opts.Repository,
+	}
+	registryURL, namespace, err := registry.ParseReference(
+		path.Join(opts.Registry, opts.Organization, util.SanitizeDockerName(opts.Repository+"-")))
+	if err != nil {
+		return err
+	}
+
+	// The current image of every role, by repository and tag
+	current := map[string]map[string]bool{}
+	for _, rolesManifest := range rolesManifests {
+		for _, role := range rolesManifest.Roles {
+			if role.Type == model.RoleTypeDocker {
+				continue
+			}
+			imageName, tag := dockerclient.ParseRepositoryTag(kube.ContainerImageName(role, settings))
+			_, repository, err := registry.ParseReference(imageName)
+			if err != nil {
+				return err
+			}
+			if current[repository] == nil {
+				current[repository] = map[string]bool{}
+			}
+			current[repository][tag] = true
+		}
+	}
+
+	client := newRegistryClient(opts.Registry)
+	repositories, err := client.Catalog(registryURL)
+	if err != nil {
+		return categorize(ErrorCategoryPush, err)
+	}
+	sort.Strings(repositories)
+
+	type orphan struct {
+		repository, tag, digest string
+		labels                  map[string]string
+	}
+	var orphans []orphan
+	currentDigests := map[string]bool{} // By repository@digest
+	for _, repository := range repositories {
+		if !strings.HasPrefix(repository, namespace) {
+			continue
+		}
+		tags, err := client.Tags(registryURL, repository)
+		if err != nil {
+			return categorize(ErrorCategoryPush, err)
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			digest, labels, err := client.ImageLabels(registryURL, repository, tag)
+			if err != nil {
+				return categorize(ErrorCategoryPush, err)
+			}
+			if current[repository][tag] {
+				currentDigests[repository+"@"+digest] = true
+			} else if labels["role"] != "" {
+				orphans = append(orphans, orphan{repository, tag, digest, labels})
+			}
+		}
+	}
+
+	if len(orphans) == 0 {
+		f.UI.Println(color.GreenString("No orphaned images in %s", path.Join(opts.Registry, opts.Organization)))
+		return nil
+	}
+
+	f.UI.Println(color.YellowString("Orphaned images in %s:", path.Join(opts.Registry, opts.Organization)))
+	deleted := map[string]bool{}
+	for _, image := range orphans {
+		name := fmt.Sprintf("%s:%s", image.repository, image.tag)
+		f.UI.Printf("  %s (role %s, version %s)\n", color.YellowString(name), image.labels["role"], image.labels["version"])
+		if !opts.Delete {
+			continue
+		}
+		ref := image.repository + "@" + image.digest
+		if currentDigests[ref] {
+			// Deleting the manifest would delete the current image too
+			f.UI.Printf("    %s\n", color.RedString("kept, it is also the image of a current role"))
+			continue
+		}
+		// Deleting the manifest deletes all of its tags
+		if !deleted[ref] {
+			if err := client.DeleteManifest(registryURL, image.repository, image.digest); err != nil {
+				return categorize(ErrorCategoryPush, err)
+			}
+			deleted[ref] = true
+		}
+		f.UI.Printf("    %s\n", color.GreenString("deleted"))
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hpcloud/fissile/kube"
+	"github.com/hpcloud/fissile/model"
+	"github.com/hpcloud/fissile/registry"
+
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRegistryImage is an image served by newFakeImageRegistry
+type fakeRegistryImage struct {
+	digest string
+	labels map[string]string
+}
+
+// newFakeImageRegistry serves the given images, by repository and tag, and
+// records the manifests deleted from it
+func newFakeImageRegistry(images map[string]map[string]fakeRegistryImage, deleted *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/_catalog" {
+			var repositories []string
+			for repository := range images {
+				repositories = append(repositories, repository)
+			}
+			json.NewEncoder(w).Encode(map[string][]string{"repositories": repositories})
+			return
+		}
+
+		for repository, tags := range images {
+			prefix := fmt.Sprintf("/v2/%s/", repository)
+			if !strings.HasPrefix(r.URL.Path, prefix) {
+				continue
+			}
+			rest := strings.TrimPrefix(r.URL.Path, prefix)
+			switch {
+			case rest == "tags/list":
+				var names []string
+				for tag := range tags {
+					names = append(names, tag)
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"name": repository, "tags": names})
+				return
+			case strings.HasPrefix(rest, "manifests/") && r.Method == "DELETE":
+				*deleted = append(*deleted, fmt.Sprintf("%s@%s", repository, strings.TrimPrefix(rest, "manifests/")))
+				w.WriteHeader(http.StatusAccepted)
+				return
+			case strings.HasPrefix(rest, "manifests/"):
+				if image, ok := tags[strings.TrimPrefix(rest, "manifests/")]; ok {
+					w.Header().Set("Docker-Content-Digest", image.digest)
+					fmt.Fprintf(w, `{"schemaVersion": 2, "config": {"digest": "config-%s"}}`, image.digest)
+					return
+				}
+			case strings.HasPrefix(rest, "blobs/config-"):
+				for _, image := range tags {
+					if rest == "blobs/config-"+image.digest {
+						json.NewEncoder(w).Encode(map[string]interface{}{"config": map[string]interface{}{"Labels": image.labels}})
+						return
+					}
+				}
+			}
+		}
+		http.NotFound(w, r)
+	}))
+}
+
+func TestFindOrphanedImages(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml")
+
+	buffer := &bytes.Buffer{}
+	f := NewFissileApplication(".", termui.New(&bytes.Buffer{}, buffer, nil))
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+	rolesManifest, err := model.LoadRoleManifest(roleManifestPath, f.releases)
+	if !assert.NoError(err) {
+		return
+	}
+
+	// The current tags of the roles
+	currentTags := map[string]string{}
+	for _, role := range rolesManifest.Roles {
+		_, tag := dockerclient.ParseRepositoryTag(kube.ContainerImageName(role, &kube.ExportSettings{Repository: "fissile"}))
+		currentTags[role.Name] = tag
+	}
+
+	roleLabels := func(role, version string) map[string]string {
+		return map[string]string{"role": role, "version": version}
+	}
+	images := map[string]map[string]fakeRegistryImage{
+		"org/fissile-myrole": {
+			currentTags["myrole"]: {"sha256:current", roleLabels("myrole", "1")},
+			"old":                 {"sha256:old", roleLabels("myrole", "0")},
+			"older":               {"sha256:old", roleLabels("myrole", "0")},
+			"retagged":            {"sha256:current", roleLabels("myrole", "1")},
+			"unlabeled":           {"sha256:unlabeled", nil},
+		},
+		"org/fissile-foorole": {
+			currentTags["foorole"]: {"sha256:foorole", roleLabels("foorole", "1")},
+		},
+		"org/fissile-gone": {
+			"v1": {"sha256:gone", roleLabels("gone", "1")},
+		},
+		"org/other-myrole": {
+			"old": {"sha256:other", roleLabels("myrole", "0")},
+		},
+	}
+	var deleted []string
+	server := newFakeImageRegistry(images, &deleted)
+	defer server.Close()
+
+	savedNewRegistryClient := newRegistryClient
+	defer func() { newRegistryClient = savedNewRegistryClient }()
+	newRegistryClient = func(registryHost string) *registry.Client { return &registry.Client{} }
+
+	outDir, err := ioutil.TempDir("", "fissile-orphans-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(outDir)
+	lockPath := filepath.Join(outDir, "fissile.lock")
+	if !assert.NoError(model.NewLock(f.releases, "").Write(lockPath)) {
+		return
+	}
+
+	opts := OrphanOptions{
+		Repository:        "fissile",
+		Registry:          strings.TrimPrefix(server.URL, "http://"),
+		Organization:      "org",
+		RolesManifestPath: roleManifestPath,
+		LockPath:          lockPath,
+	}
+	if !assert.NoError(f.FindOrphanedImages(opts)) {
+		return
+	}
+	output := buffer.String()
+	assert.Contains(output, "org/fissile-gone:v1 (role gone, version 1)")
+	assert.Contains(output, "org/fissile-myrole:old (role myrole, version 0)")
+	assert.Contains(output, "org/fissile-myrole:older (role myrole, version 0)")
+	assert.Contains(output, "org/fissile-myrole:retagged (role myrole, version 1)")
+	assert.NotContains(output, "unlabeled", "Images without fissile labels are not role images")
+	assert.NotContains(output, currentTags["myrole"])
+	assert.NotContains(output, "other-myrole", "Other repositories are not in the namespace")
+	assert.Empty(deleted, "Nothing is deleted without opts.Delete")
+
+	buffer.Reset()
+	opts.Delete = true
+	if !assert.NoError(f.FindOrphanedImages(opts)) {
+		return
+	}
+	assert.Equal([]string{"org/fissile-gone@sha256:gone", "org/fissile-myrole@sha256:old"}, deleted,
+		"Manifests are deleted once, and not when they are the current image")
+	assert.Contains(buffer.String(), "kept, it is also the image of a current role")
+
+	// The current images are only known for the locked build inputs
+	lock := model.NewLock(f.releases, "")
+	lock.Releases[0].Version = "0.0.1"
+	if !assert.NoError(lock.Write(lockPath)) {
+		return
+	}
+	err = f.FindOrphanedImages(opts)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), fmt.Sprintf("Build inputs differ from lock file %s", lockPath))
+	}
+
+	opts.Registry = ""
+	assert.EqualError(f.FindOrphanedImages(opts), "A docker registry is needed to find orphaned images")
+}
+
+func TestFindOrphanedImagesFeatures(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/features.yml")
+
+	buffer := &bytes.Buffer{}
+	f := NewFissileApplication(".", termui.New(&bytes.Buffer{}, buffer, nil))
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	// The image of the role of a feature that is not enabled
+	rolesManifest, err := model.LoadRoleManifestWithOptions(roleManifestPath, f.releases, model.RoleManifestOptions{Features: []string{"ha"}})
+	if !assert.NoError(err) {
+		return
+	}
+	_, tag := dockerclient.ParseRepositoryTag(kube.ContainerImageName(rolesManifest.LookupRole("ha-proxy"), &kube.ExportSettings{Repository: "fissile"}))
+
+	images := map[string]map[string]fakeRegistryImage{
+		"org/fissile-ha-proxy": {
+			tag:   {"sha256:current", map[string]string{"role": "ha-proxy", "version": "1"}},
+			"old": {"sha256:old", map[string]string{"role": "ha-proxy", "version": "0"}},
+		},
+	}
+	var deleted []string
+	server := newFakeImageRegistry(images, &deleted)
+	defer server.Close()
+
+	savedNewRegistryClient := newRegistryClient
+	defer func() { newRegistryClient = savedNewRegistryClient }()
+	newRegistryClient = func(registryHost string) *registry.Client { return &registry.Client{} }
+
+	err = f.FindOrphanedImages(OrphanOptions{
+		Repository:        "fissile",
+		Registry:          strings.TrimPrefix(server.URL, "http://"),
+		Organization:      "org",
+		RolesManifestPath: roleManifestPath,
+		Delete:            true,
+	})
+	if assert.NoError(err) {
+		assert.Equal([]string{"org/fissile-ha-proxy@sha256:old"}, deleted, "the images of disabled features are current")
+	}
+}
@@ -0,0 +1,333 @@
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hpcloud/fissile/compilator"
+	"github.com/hpcloud/fissile/model"
+	"github.com/hpcloud/fissile/util"
+
+	"github.com/fatih/color"
+)
+
+// These are the stages of a pipeline run, in the order they run in
+const (
+	PipelineStageLoad     = "load"     // Load the releases
+	PipelineStageValidate = "validate" // Load the role manifest and check the lock file
+	PipelineStageCompile  = "compile"  // Compile the packages
+	PipelineStageBuild    = "build"    // Build the role images
+	PipelineStageGenerate = "generate" // Write the Kubernetes configuration
+	PipelineStagePush     = "push"     // Push the role images to the registry
+)
+
+// PipelineStages lists the stages of a pipeline run, in order
+var PipelineStages = []string{
+	PipelineStageLoad,
+	PipelineStageValidate,
+	PipelineStageCompile,
+	PipelineStageBuild,
+	PipelineStageGenerate,
+	PipelineStagePush,
+}
+
+// These are the statuses of the stages in a pipeline report
+const (
+	PipelineStatusDone         = "done"         // The stage ran
+	PipelineStatusCheckpointed = "checkpointed" // The stage already ran for the same inputs
+	PipelineStatusSkipped      = "skipped"      // The stage was not selected, or has nothing to do
+	PipelineStatusFailed       = "failed"       // The stage failed
+	PipelineStatusNotRun       = "not-run"      // An earlier stage failed
+)
+
+// PipelineOptions are the inputs of a pipeline run
+type PipelineOptions struct {
+	ReleasePaths      []string
+	ReleaseNames      []string
+	ReleaseVersions   []string
+	CacheDir          string
+	RolesManifestPath string
+	LightManifestPath string
+	DarkManifestPath  string
+	LockPath          string
+	UpdateLock        bool
+	Repository        string
+	Registry          string
+	Organization      string
+	CompilationDir    string // Compiled packages
+	DockerDir         string // Role image Dockerfiles
+	KubeOutputDir     string
+	Values            VariableValues // Values of the configuration variables in the kube configurations
+	UseMemoryLimits   bool
+	ConfigChecksums   bool // Annotate pod templates with the checksum of the configuration they read
+	Workers           int
+	Limits            compilator.ResourceLimits
+	MetricsPath       string
+	TriageDir         string // Triage bundles of failed compilations
+	Explain           bool   // Print why each package and role image is built or skipped
+
+	CheckpointDir string // Where finished stages are recorded
+	From          string // First stage to run; empty for the first one
+	Until         string // Last stage to run; empty for the last one
+	Force         bool   // Run the selected stages even if they are checkpointed
+}
+
+// PipelineStageReport is the outcome of a stage of a pipeline run
+type PipelineStageReport struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration"` // In seconds
+	Reason   string  `json:"reason,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// PipelineReport is the outcome of a pipeline run
+type PipelineReport struct {
+	Stages   []*PipelineStageReport `json:"stages"`
+	ExitCode int                    `json:"exit-code"`
+}
+
+// pipelineStage is a stage of a pipeline run. Stages that are required always
+// run, as the others depend on them; only the others have checkpoints. A stage
+// with a skip reason does not run.
+type pipelineStage struct {
+	name     string
+	required bool
+	skip     func() string
+	run      func() error
+}
+
+// RunPipeline loads the releases and the role manifest, compiles the
+// packages, builds the role images, writes the Kubernetes configuration and
+// pushes the images, as the stages between opts.From and opts.Until. Stages
+// that already finished for the same inputs are not run again. The outcome of
+// every stage is written to reportPath as JSON, if it is given.
+func (f *Fissile) RunPipeline(opts PipelineOptions, reportPath string) error {
+	var fingerprint string
+
+	stages := []pipelineStage{
+		{
+			name:     PipelineStageLoad,
+			required: true,
+			run: func() error {
+				return f.LoadReleases(opts.ReleasePaths, opts.ReleaseNames, opts.ReleaseVersions, opts.CacheDir)
+			},
+		},
+		{
+			name:     PipelineStageValidate,
+			required: true,
+			run: func() error {
+				rolesManifest, err := f.loadRoleManifest(opts.RolesManifestPath)
+				if err != nil {
+					return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+				}
+				if err := f.CheckLock(opts.LockPath, opts.UpdateLock, ""); err != nil {
+					return err
+				}
+				fingerprint, err = f.pipelineFingerprint(rolesManifest, opts)
+				return err
+			},
+		},
+		{
+			name: PipelineStageCompile,
+			run: func() error {
+				return f.Compile(opts.Repository, opts.CompilationDir, opts.RolesManifestPath, opts.MetricsPath, opts.TriageDir, opts.Workers, opts.Limits, opts.Explain)
+			},
+		},
+		{
+			name: PipelineStageBuild,
+			run: func() error {
+				return f.GenerateRoleImages(opts.DockerDir, opts.Repository, opts.MetricsPath, false, false, false, false, opts.Explain, opts.Workers,
+					opts.RolesManifestPath, opts.CompilationDir, opts.LightManifestPath, opts.DarkManifestPath)
+			},
+		},
+		{
+			name: PipelineStageGenerate,
+			run: func() error {
+				return f.GenerateKube(KubeOptions{
+					RolesManifestPath: opts.RolesManifestPath,
+					OutputDir:         opts.KubeOutputDir,
+					Repository:        opts.Repository,
+					Registry:          opts.Registry,
+					Organization:      opts.Organization,
+					Values:            opts.Values,
+					UseMemoryLimits:   opts.UseMemoryLimits,
+					ConfigChecksums:   opts.ConfigChecksums,
+				})
+			},
+		},
+		{
+			name: PipelineStagePush,
+			skip: func() string {
+				if opts.Registry == "" {
+					return "no docker registry is set"
+				}
+				return ""
+			},
+			run: func() error {
+				return f.PushRoleImages(opts.Repository, opts.Registry, opts.Organization, opts.RolesManifestPath)
+			},
+		},
+	}
+
+	report, err := f.runPipeline(stages, opts, func() string { return fingerprint })
+	if report != nil {
+		f.reportPipeline(report)
+		if reportPath != "" {
+			if writeErr := writePipelineReport(report, reportPath); writeErr != nil && err == nil {
+				err = writeErr
+			}
+		}
+	}
+	return err
+}
+
+// runPipeline runs the selected stages. The fingerprint of the inputs is
+// asked for once the required stages ran; checkpoints record it.
+func (f *Fissile) runPipeline(stages []pipelineStage, opts PipelineOptions, fingerprint func() string) (*PipelineReport, error) {
+	from, until := 0, len(stages)-1
+	for i, stage := range stages {
+		if stage.name == opts.From {
+			from = i
+		}
+		if stage.name == opts.Until {
+			until = i
+		}
+	}
+	for _, name := range []string{opts.From, opts.Until} {
+		if name != "" && !isPipelineStage(name) {
+			return nil, fmt.Errorf("Invalid pipeline stage '%s', expected one of %s", name, strings.Join(PipelineStages, ", "))
+		}
+	}
+	if from > until {
+		return nil, fmt.Errorf("Pipeline stage %s comes after %s", opts.From, opts.Until)
+	}
+
+	report := &PipelineReport{}
+	var failure error
+	for i, stage := range stages {
+		stageReport := &PipelineStageReport{Name: stage.name, Status: PipelineStatusSkipped}
+		report.Stages = append(report.Stages, stageReport)
+
+		checkpointPath := filepath.Join(opts.CheckpointDir, fmt.Sprintf("%s.checkpoint", stage.name))
+		switch {
+		case failure != nil:
+			stageReport.Status = PipelineStatusNotRun
+			continue
+		case !stage.required && (i < from || i > until):
+			stageReport.Reason = "not selected"
+			continue
+		case stage.required && i > until:
+			stageReport.Reason = "not selected"
+			continue
+		case stage.skip != nil && stage.skip() != "":
+			stageReport.Reason = stage.skip()
+			continue
+		case !stage.required && !opts.Force:
+			if checkpoint, err := ioutil.ReadFile(checkpointPath); err == nil && string(checkpoint) == fingerprint() {
+				stageReport.Status = PipelineStatusCheckpointed
+				f.UI.Printf("Stage %s already ran for these inputs, skipping\n", color.CyanString(stage.name))
+				continue
+			}
+		}
+
+		f.UI.Printf("Running stage %s\n", color.CyanString(stage.name))
+		start := time.Now()
+		phase := util.Tracing.StartPhase(fmt.Sprintf("stage-%s", stage.name))
+		err := stage.run()
+		phase.End(err)
+		stageReport.Duration = time.Since(start).Seconds()
+		if err != nil {
+			stageReport.Status = PipelineStatusFailed
+			stageReport.Error = err.Error()
+			failure = err
+			continue
+		}
+		stageReport.Status = PipelineStatusDone
+
+		if !stage.required {
+			if err := os.MkdirAll(opts.CheckpointDir, 0755); err != nil {
+				return report, err
+			}
+			if err := ioutil.WriteFile(checkpointPath, []byte(fingerprint()), 0644); err != nil {
+				return report, fmt.Errorf("Error writing checkpoint of stage %s: %s", stage.name, err.Error())
+			}
+		}
+	}
+
+	report.ExitCode = ExitCode(failure)
+	return report, failure
+}
+
+// isPipelineStage tells if name is the name of a pipeline stage
+func isPipelineStage(name string) bool {
+	for _, stage := range PipelineStages {
+		if stage == name {
+			return true
+		}
+	}
+	return false
+}
+
+// pipelineFingerprint identifies the inputs of a pipeline run: the jobs and
+// packages of the roles, the files configuring them, and the options
+func (f *Fissile) pipelineFingerprint(rolesManifest *model.RoleManifest, opts PipelineOptions) (string, error) {
+	hasher := sha1.New()
+	hasher.Write([]byte(rolesManifest.GetRoleManifestDevPackageVersion(f.Version)))
+
+	files := append([]string{opts.RolesManifestPath, opts.LightManifestPath, opts.DarkManifestPath}, opts.Values.DefaultEnvFiles...)
+	files = append(files, opts.Values.EnvFiles...)
+	files = append(files, rolesManifest.IncludedFiles()...)
+	for _, path := range files {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		fmt.Fprintf(hasher, "\n%s\n%s", path, contents)
+	}
+
+	fmt.Fprintf(hasher, "\n%s\n%s\n%s\n%s\n%s\n%s\n%t\n%t\n%s\n%s\n%s",
+		opts.Repository, opts.Registry, opts.Organization,
+		opts.CompilationDir, opts.DockerDir, opts.KubeOutputDir, opts.UseMemoryLimits, opts.ConfigChecksums,
+		strings.Join(f.manifestOptions.Features, ","), f.manifestOptions.Environment, opts.Values.Set)
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// reportPipeline shows the outcome of every stage of a pipeline run
+func (f *Fissile) reportPipeline(report *PipelineReport) {
+	for _, stage := range report.Stages {
+		status := stage.Status
+		switch stage.Status {
+		case PipelineStatusDone:
+			status = color.GreenString("%s in %.1fs", status, stage.Duration)
+		case PipelineStatusFailed:
+			status = color.RedString(status)
+		default:
+			if stage.Reason != "" {
+				status = fmt.Sprintf("%s (%s)", status, stage.Reason)
+			}
+			status = color.YellowString(status)
+		}
+		f.UI.Printf("%-10s %s\n", stage.Name, status)
+	}
+}
+
+// writePipelineReport writes the report of a pipeline run as JSON
+func writePipelineReport(report *PipelineReport, reportPath string) error {
+	contents, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(reportPath, append(contents, '\n'), 0644); err != nil {
+		return fmt.Errorf("Error writing pipeline report: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePipeline returns stages named as the pipeline stages, recording the ones
+// that run; the stage named failing fails
+func fakePipeline(ran *[]string, failing string) []pipelineStage {
+	var stages []pipelineStage
+	for i, name := range PipelineStages {
+		name := name
+		stages = append(stages, pipelineStage{
+			name:     name,
+			required: i < 2,
+			run: func() error {
+				*ran = append(*ran, name)
+				if name == failing {
+					return categorizedErrorf(ErrorCategoryCompile, "Stage %s failed", name)
+				}
+				return nil
+			},
+		})
+	}
+	return stages
+}
+
+func TestRunPipeline(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	f := NewFissileApplication(".", ui)
+
+	checkpointDir, err := ioutil.TempDir("", "fissile-pipeline-tests")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(checkpointDir)
+
+	fingerprint := "first"
+	getFingerprint := func() string { return fingerprint }
+	opts := PipelineOptions{CheckpointDir: checkpointDir}
+
+	var ran []string
+	report, err := f.runPipeline(fakePipeline(&ran, ""), opts, getFingerprint)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(PipelineStages, ran)
+	assert.Equal(0, report.ExitCode)
+	for _, stage := range report.Stages {
+		assert.Equal(PipelineStatusDone, stage.Status, stage.Name)
+	}
+	_, err = os.Stat(filepath.Join(checkpointDir, "load.checkpoint"))
+	assert.True(os.IsNotExist(err), "Required stages should not be checkpointed")
+
+	// Checkpointed stages don't run again for the same inputs
+	ran = nil
+	report, err = f.runPipeline(fakePipeline(&ran, ""), opts, getFingerprint)
+	assert.NoError(err)
+	assert.Equal([]string{"load", "validate"}, ran)
+	assert.Equal(PipelineStatusCheckpointed, report.Stages[2].Status)
+
+	// Unless they are forced to
+	ran = nil
+	forced := opts
+	forced.Force = true
+	forced.Until = PipelineStageBuild
+	report, err = f.runPipeline(fakePipeline(&ran, ""), forced, getFingerprint)
+	assert.NoError(err)
+	assert.Equal([]string{"load", "validate", "compile", "build"}, ran)
+	assert.Equal(PipelineStatusSkipped, report.Stages[4].Status)
+	assert.Equal("not selected", report.Stages[4].Reason)
+
+	// Changed inputs run the stages again; a failure stops the run
+	ran = nil
+	fingerprint = "second"
+	partial := opts
+	partial.From = PipelineStageBuild
+	report, err = f.runPipeline(fakePipeline(&ran, "generate"), partial, getFingerprint)
+	assert.EqualError(err, "Stage generate failed")
+	assert.Equal([]string{"load", "validate", "build", "generate"}, ran)
+	assert.Equal(4, report.ExitCode)
+	assert.Equal(PipelineStatusSkipped, report.Stages[2].Status)
+	assert.Equal(PipelineStatusFailed, report.Stages[4].Status)
+	assert.Equal("Stage generate failed", report.Stages[4].Error)
+	assert.Equal(PipelineStatusNotRun, report.Stages[5].Status)
+
+	reportPath := filepath.Join(checkpointDir, "report.json")
+	if assert.NoError(writePipelineReport(report, reportPath)) {
+		contents, err := ioutil.ReadFile(reportPath)
+		assert.NoError(err)
+		var written PipelineReport
+		assert.NoError(json.Unmarshal(contents, &written))
+		assert.Equal(4, written.ExitCode)
+		assert.Equal("failed", written.Stages[4].Status)
+	}
+}
+
+func TestRunPipelineStageSelection(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	f := NewFissileApplication(".", ui)
+
+	var ran []string
+	_, err := f.runPipeline(fakePipeline(&ran, ""), PipelineOptions{From: "deploy"}, func() string { return "" })
+	assert.EqualError(err, "Invalid pipeline stage 'deploy', expected one of load, validate, compile, build, generate, push")
+
+	_, err = f.runPipeline(fakePipeline(&ran, ""), PipelineOptions{From: "push", Until: "compile"}, func() string { return "" })
+	assert.EqualError(err, "Pipeline stage push comes after compile")
+	assert.Empty(ran)
+
+	// Stages with a skip reason don't run
+	stages := fakePipeline(&ran, "")
+	stages[5].skip = func() string { return "no docker registry is set" }
+	report, err := f.runPipeline(stages, PipelineOptions{From: "push", Force: true}, func() string { return "" })
+	assert.NoError(err)
+	assert.Equal([]string{"load", "validate"}, ran)
+	assert.Equal(PipelineStatusSkipped, report.Stages[5].Status)
+	assert.Equal("no docker registry is set", report.Stages[5].Reason)
+}
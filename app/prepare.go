@@ -0,0 +1,122 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/hpcloud/fissile/builder"
+	"github.com/hpcloud/fissile/compilator"
+	"github.com/hpcloud/fissile/docker"
+	"github.com/hpcloud/fissile/scripts/compilation"
+	"github.com/hpcloud/fissile/util"
+
+	"github.com/fatih/color"
+	dockerclient "github.com/fsouza/go-dockerclient"
+)
+
+// buildPreparer is the part of docker.ImageManager used to prepare a build,
+// shimmed for the unit test
+type buildPreparer interface {
+	Ping() error
+	CheckVolumeAccess(name string) error
+	HasImage(imageName string) (bool, error)
+	FindImage(imageName string) (*dockerclient.Image, error)
+	PullImage(imageName string, stdoutWriter io.Writer) error
+}
+
+// newBuildPreparer is a stub to be replaced by the unit test
+var newBuildPreparer = func() (buildPreparer, error) { return docker.NewImageManager() }
+
+// PrepareBuild verifies that the docker environment is usable for a build,
+// pulls the stemcell image if needed, reports on the fissile layers and
+// creates the work and cache directories used by the other build commands
+func (f *Fissile) PrepareBuild(baseImageName, repository string, cacheDirs []string) error {
+	dockerManager, err := newBuildPreparer()
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
+	}
+
+	if err := dockerManager.Ping(); err != nil {
+		return fmt.Errorf("Error talking to the docker daemon, check that it is running and that you are allowed to use it: %s", err.Error())
+	}
+
+	volumeName := util.SanitizeDockerName(fmt.Sprintf("%s-prepare-%s", repository, f.Version))
+	if err := dockerManager.CheckVolumeAccess(volumeName); err != nil {
+		return fmt.Errorf("Error checking docker volume permissions: %s", err.Error())
+	}
+	f.UI.Println(color.GreenString("Docker daemon is reachable and volumes can be managed"))
+
+	hasBaseImage, err := dockerManager.HasImage(baseImageName)
+	if err != nil {
+		return fmt.Errorf("Error looking up base image %s: %s", baseImageName, err.Error())
+	}
+	if !hasBaseImage {
+		f.UI.Println(color.GreenString("Pulling base image %s ...", color.YellowString(baseImageName)))
+		stdoutWriter := docker.NewFormattingWriter(
+			f.UI,
+			docker.ColoredBuildStringFunc(baseImageName),
+		)
+		err := dockerManager.PullImage(baseImageName, stdoutWriter)
+		stdoutWriter.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	baseImage, err := dockerManager.FindImage(baseImageName)
+	if err != nil {
+		return fmt.Errorf("Error looking up base image %s: %s", baseImageName, err.Error())
+	}
+	f.UI.Println(color.GreenString("Base image %s with ID %s found", color.YellowString(baseImageName), color.YellowString(baseImage.ID)))
+
+	// The compilator only names the layer, it doesn't need docker
+	comp, err := compilator.NewCompilator(nil, "", "", repository, compilation.UbuntuBase, f.Version, false, f.UI)
+	if err != nil {
+		return fmt.Errorf("Error creating a new compilator: %s", err.Error())
+	}
+
+	layers := []struct {
+		kind, name string
+	}{
+		{"Compilation layer", comp.BaseImageName()},
+		{"Stemcell layer", builder.GetBaseImageName(repository, f.Version)},
+	}
+	for _, layer := range layers {
+		hasLayer, err := dockerManager.HasImage(layer.name)
+		if err != nil {
+			return fmt.Errorf("Error looking up image %s: %s", layer.name, err.Error())
+		}
+		if hasLayer {
+			f.UI.Println(color.GreenString("%s %s found", layer.kind, color.YellowString(layer.name)))
+		} else {
+			f.UI.Println(color.YellowString("%s %s is missing, it will be built by \"fissile build layer\"", layer.kind, layer.name))
+		}
+	}
+
+	if err := prepareWorkDirs(cacheDirs); err != nil {
+		return err
+	}
+	f.UI.Println(color.GreenString("Work and cache directories are ready"))
+
+	return nil
+}
+
+// prepareWorkDirs creates the work and cache directories, and checks that
+// they are writable
+func prepareWorkDirs(dirs []string) error {
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Error creating directory %s: %s", dir, err.Error())
+		}
+
+		probe, err := ioutil.TempFile(dir, ".fissile-prepare")
+		if err != nil {
+			return fmt.Errorf("Error writing to directory %s: %s", dir, err.Error())
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/fissile/docker"
+
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBuildPreparer records the calls of PrepareBuild
+type fakeBuildPreparer struct {
+	pingErr error
+	images  map[string]*dockerclient.Image
+	calls   []string
+}
+
+func (p *fakeBuildPreparer) Ping() error {
+	return p.pingErr
+}
+
+func (p *fakeBuildPreparer) CheckVolumeAccess(name string) error {
+	p.calls = append(p.calls, fmt.Sprintf("volume %s", name))
+	return nil
+}
+
+func (p *fakeBuildPreparer) HasImage(imageName string) (bool, error) {
+	_, ok := p.images[imageName]
+	return ok, nil
+}
+
+func (p *fakeBuildPreparer) FindImage(imageName string) (*dockerclient.Image, error) {
+	image, ok := p.images[imageName]
+	if !ok {
+		return nil, docker.ErrImageNotFound
+	}
+	return image, nil
+}
+
+func (p *fakeBuildPreparer) PullImage(imageName string, stdoutWriter io.Writer) error {
+	p.calls = append(p.calls, fmt.Sprintf("pull %s", imageName))
+	p.images[imageName] = &dockerclient.Image{ID: "sha256:pulled"}
+	return nil
+}
+
+func TestPrepareBuild(t *testing.T) {
+	assert := assert.New(t)
+
+	output := &bytes.Buffer{}
+	f := NewFissileApplication("1.2.3", termui.New(&bytes.Buffer{}, output, nil))
+
+	preparer := &fakeBuildPreparer{images: map[string]*dockerclient.Image{}}
+	savedNewBuildPreparer := newBuildPreparer
+	defer func() { newBuildPreparer = savedNewBuildPreparer }()
+	newBuildPreparer = func() (buildPreparer, error) { return preparer, nil }
+
+	workDir, err := ioutil.TempDir("", "fissile-prepare-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(workDir)
+	dirs := []string{filepath.Join(workDir, "cache"), filepath.Join(workDir, "work", "compilation")}
+
+	if !assert.NoError(f.PrepareBuild("ubuntu:14.04", "fissile", dirs)) {
+		return
+	}
+	assert.Equal([]string{"volume fissile-prepare-1.2.3", "pull ubuntu:14.04"}, preparer.calls, "the missing base image is pulled")
+	assert.Contains(output.String(), "Base image ubuntu:14.04 with ID sha256:pulled found")
+	assert.Contains(output.String(), "Stemcell layer fissile-role-base:1.2.3 is missing")
+	for _, dir := range dirs {
+		assert.True(isDir(dir), "%s is created", dir)
+	}
+
+	preparer.calls = nil
+	if assert.NoError(f.PrepareBuild("ubuntu:14.04", "fissile", dirs)) {
+		assert.Equal([]string{"volume fissile-prepare-1.2.3"}, preparer.calls, "the base image is only pulled once")
+	}
+
+	preparer.pingErr = fmt.Errorf("permission denied")
+	err = f.PrepareBuild("ubuntu:14.04", "fissile", dirs)
+	assert.EqualError(err, "Error talking to the docker daemon, check that it is running and that you are allowed to use it: permission denied")
+}
+
+func TestPrepareWorkDirs(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := ioutil.TempDir("", "fissile-prepare-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	blocker := filepath.Join(workDir, "file")
+	if !assert.NoError(ioutil.WriteFile(blocker, []byte{}, 0644)) {
+		return
+	}
+
+	assert.NoError(prepareWorkDirs([]string{filepath.Join(workDir, "a", "b")}))
+	err = prepareWorkDirs([]string{filepath.Join(blocker, "cache")})
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "Error creating directory "+filepath.Join(blocker, "cache"))
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(workDir, "a", "b"))
+	if assert.NoError(err) {
+		assert.Empty(entries, "the write probe is removed")
+	}
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
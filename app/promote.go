@@ -0,0 +1,178 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+	dockerclient "github.com/fsouza/go-dockerclient"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// runSignCommand runs the command signing a promoted image, with the image
+// reference as its last argument. Tests replace it.
+var runSignCommand = func(command []string, imageRef string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(command[0], append(command[1:], imageRef)...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error signing image %s: %s: %s", imageRef, err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// PromoteOptions are the inputs of PromoteRoleImages
+type PromoteOptions struct {
+	FromResultsPath string // Build results of the images to promote, from fissile tag or an earlier promotion
+	Channel         string // Channel the images are promoted to, e.g. prod
+	Registry        string // Registry of the channel
+	Organization    string
+	SignCommand     string // Command signing the promoted images, e.g. "cosign sign --key prod.key"; none when empty
+	ValuesPath      string // Values file of the channel, whose images are updated; not written when empty
+	ResultsPath     string // Where the build results of the channel are written; they are printed when empty
+}
+
+// PromoteRoleImages copies the images of the build results of a channel to
+// the registry of another channel, by digest, so the images that are promoted
+// are exactly the ones that were tested. The images are signed again if a
+// sign command is given, since signatures are kept per registry. The build
+// results of the new channel are written, and the images in its values file
+// are updated.
+func (f *Fissile) PromoteRoleImages(opts PromoteOptions) error {
+	if opts.Channel == "" {
+		return fmt.Errorf("A channel is needed to promote images to")
+	}
+	if opts.Registry == "" {
+		return categorizedErrorf(ErrorCategoryPush, "A docker registry is needed to push images")
+	}
+
+	contents, err := ioutil.ReadFile(opts.FromResultsPath)
+	if err != nil {
+		return fmt.Errorf("Error reading build results: %s", err.Error())
+	}
+	var from TagResults
+	if err := json.Unmarshal(contents, &from); err != nil {
+		return fmt.Errorf("Error reading build results %s: %s", opts.FromResultsPath, err.Error())
+	}
+	if from.Channel == opts.Channel {
+		return fmt.Errorf("The images of %s are already in channel %s", opts.FromResultsPath, opts.Channel)
+	}
+
+	signCommand := strings.Fields(opts.SignCommand)
+
+	tagger, err := newImageTagger()
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
+	}
+
+	results := &TagResults{
+		Channel:      opts.Channel,
+		Registry:     opts.Registry,
+		Organization: opts.Organization,
+		Images:       []*TaggedImage{},
+	}
+	for _, promoted := range from.Images {
+		source := promoted.Image
+		if promoted.Digest != "" {
+			repository, _ := dockerclient.ParseRepositoryTag(promoted.Image)
+			source = fmt.Sprintf("%s@%s", repository, promoted.Digest)
+		}
+		imageName := path.Join(opts.Registry, opts.Organization, path.Base(promoted.Image))
+
+		f.UI.Printf("Promoting image %s to %s\n", color.YellowString(source), color.GreenString(imageName))
+		log := new(bytes.Buffer)
+		if err := tagger.PullImage(source, log); err != nil {
+			log.WriteTo(f.UI)
+			return categorize(ErrorCategoryDocker, err)
+		}
+		image, err := tagger.FindImage(source)
+		if err != nil {
+			return categorize(ErrorCategoryDocker, err)
+		}
+		if promoted.ID != "" && image.ID != promoted.ID {
+			return categorizedErrorf(ErrorCategoryDocker, "Image %s of role %s is %s, not %s as in the build results", source, promoted.Role, image.ID, promoted.ID)
+		}
+		if err := tagger.PushImage(image.ID, imageName, log); err != nil {
+			log.WriteTo(f.UI)
+			return categorize(ErrorCategoryPush, err)
+		}
+
+		// The registry gives pushed images their digest
+		pushed, err := tagger.FindImage(imageName)
+		if err != nil {
+			return categorize(ErrorCategoryDocker, err)
+		}
+		tagged := &TaggedImage{
+			Role:   promoted.Role,
+			Image:  imageName,
+			Source: source,
+			ID:     image.ID,
+			Digest: repositoryDigest(pushed, imageName),
+		}
+
+		if len(signCommand) > 0 {
+			if tagged.Digest == "" {
+				return categorizedErrorf(ErrorCategoryPush, "Image %s has no digest to sign", imageName)
+			}
+			repository, _ := dockerclient.ParseRepositoryTag(imageName)
+			if err := runSignCommand(signCommand, fmt.Sprintf("%s@%s", repository, tagged.Digest)); err != nil {
+				return categorize(ErrorCategoryPush, err)
+			}
+		}
+
+		results.Images = append(results.Images, tagged)
+	}
+
+	if opts.ValuesPath != "" {
+		if err := updateChannelValues(opts.ValuesPath, results); err != nil {
+			return err
+		}
+	}
+
+	return f.writeTagResults(results, opts.ResultsPath)
+}
+
+// updateChannelValues sets the channel and the images of the roles, by
+// digest when they have one, in a YAML values file; its other values are
+// kept, along with its comments and their order
+func updateChannelValues(valuesPath string, results *TagResults) error {
+	values := map[string]interface{}{}
+	contents, err := ioutil.ReadFile(valuesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := yaml.Unmarshal(contents, &values); err != nil {
+		return fmt.Errorf("Error reading values file %s: %s", valuesPath, err.Error())
+	}
+
+	images := map[string]string{}
+	for _, image := range results.Images {
+		images[image.Role] = image.Image
+		if image.Digest != "" {
+			repository, _ := dockerclient.ParseRepositoryTag(image.Image)
+			images[image.Role] = fmt.Sprintf("%s@%s", repository, image.Digest)
+		}
+	}
+
+	contents, err = model.SetYAMLKeys(contents, yaml.MapSlice{
+		{Key: "channel", Value: results.Channel},
+		{Key: "images", Value: images},
+	})
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(valuesPath, contents, 0644); err != nil {
+		return fmt.Errorf("Error writing values file %s: %s", valuesPath, err.Error())
+	}
+	return nil
+}
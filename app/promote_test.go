@@ -0,0 +1,118 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestPromoteRoleImages(t *testing.T) {
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	assert := assert.New(t)
+
+	outDir, err := ioutil.TempDir("", "fissile-promote-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(outDir)
+
+	fromPath := filepath.Join(outDir, "staging.json")
+	from := &TagResults{
+		Channel:  "staging",
+		Registry: "staging.example.com",
+		Images: []*TaggedImage{
+			{
+				Role:   "myrole",
+				Image:  "staging.example.com/fissile-myrole:abc",
+				ID:     "sha256:1",
+				Digest: "sha256:staged",
+			},
+		},
+	}
+	contents, err := json.Marshal(from)
+	if !assert.NoError(err) || !assert.NoError(ioutil.WriteFile(fromPath, contents, 0644)) {
+		return
+	}
+	valuesPath := filepath.Join(outDir, "prod-values.yml")
+	if !assert.NoError(ioutil.WriteFile(valuesPath, []byte("# Production settings\nreplicas: 3 # Per zone\n"), 0644)) {
+		return
+	}
+
+	tagger := &fakeImageTagger{images: map[string]*dockerclient.Image{
+		"staging.example.com/fissile-myrole@sha256:staged": {ID: "sha256:1"},
+	}}
+	savedNewImageTagger := newImageTagger
+	defer func() { newImageTagger = savedNewImageTagger }()
+	newImageTagger = func() (imageTagger, error) { return tagger, nil }
+
+	var signed []string
+	savedRunSignCommand := runSignCommand
+	defer func() { runSignCommand = savedRunSignCommand }()
+	runSignCommand = func(command []string, imageRef string) error {
+		signed = append(signed, append(command, imageRef)...)
+		return nil
+	}
+
+	f := NewFissileApplication(".", ui)
+	resultsPath := filepath.Join(outDir, "prod.json")
+	opts := PromoteOptions{
+		FromResultsPath: fromPath,
+		Channel:         "prod",
+		Registry:        "prod.example.com",
+		Organization:    "cf",
+		SignCommand:     "cosign sign --key prod.key",
+		ValuesPath:      valuesPath,
+		ResultsPath:     resultsPath,
+	}
+	if !assert.NoError(f.PromoteRoleImages(opts)) {
+		return
+	}
+
+	assert.Equal([]string{
+		"pull staging.example.com/fissile-myrole@sha256:staged",
+		"push sha256:1 prod.example.com/cf/fissile-myrole:abc",
+	}, tagger.calls)
+	assert.Equal([]string{"cosign", "sign", "--key", "prod.key", "prod.example.com/cf/fissile-myrole@sha256:pushed-sha256:1"}, signed)
+
+	contents, err = ioutil.ReadFile(resultsPath)
+	if assert.NoError(err) {
+		var results TagResults
+		if assert.NoError(json.Unmarshal(contents, &results)) && assert.Len(results.Images, 1) {
+			assert.Equal("prod", results.Channel)
+			assert.Equal("prod.example.com/cf/fissile-myrole:abc", results.Images[0].Image)
+			assert.Equal("staging.example.com/fissile-myrole@sha256:staged", results.Images[0].Source)
+			assert.Equal("sha256:pushed-sha256:1", results.Images[0].Digest)
+		}
+	}
+
+	contents, err = ioutil.ReadFile(valuesPath)
+	if assert.NoError(err) {
+		var values struct {
+			Replicas int               `yaml:"replicas"`
+			Channel  string            `yaml:"channel"`
+			Images   map[string]string `yaml:"images"`
+		}
+		if assert.NoError(yaml.Unmarshal(contents, &values)) {
+			assert.Equal(3, values.Replicas)
+			assert.Equal("prod", values.Channel)
+			assert.Equal(map[string]string{"myrole": "prod.example.com/cf/fissile-myrole@sha256:pushed-sha256:1"}, values.Images)
+		}
+		assert.Contains(string(contents), "# Production settings\nreplicas: 3 # Per zone\n")
+	}
+
+	// The image must be the one of the build results
+	tagger.images["staging.example.com/fissile-myrole@sha256:staged"].ID = "sha256:2"
+	err = f.PromoteRoleImages(opts)
+	assert.EqualError(err, "Image staging.example.com/fissile-myrole@sha256:staged of role myrole is sha256:2, not sha256:1 as in the build results")
+
+	opts.Channel = "staging"
+	assert.EqualError(f.PromoteRoleImages(opts), "The images of "+fromPath+" are already in channel staging")
+}
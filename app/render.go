@@ -0,0 +1,460 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hpcloud/fissile/docker"
+	"github.com/hpcloud/fissile/erb"
+	"github.com/hpcloud/fissile/model"
+	"github.com/hpcloud/fissile/util"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/pborman/uuid"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// renderTemplateScript renders an ERB job template like BOSH does, with p,
+// if_p and spec; links are not known outside of a deployment. It is run with
+// the path of the JSON spec of the job, and the path and name of the template.
+const renderTemplateScript = `
+require 'erb'
+require 'json'
+require 'ostruct'
+
+class RenderContext
+  class ElseBlock
+    def initialize(active)
+      @active = active
+    end
+
+    def else
+      yield if @active
+    end
+  end
+
+  def initialize(spec)
+    @raw_spec = spec
+    @properties = spec['properties'] || {}
+  end
+
+  def spec
+    @spec ||= to_open_struct(@raw_spec)
+  end
+
+  def p(*args)
+    names = Array(args[0])
+    names.each do |name|
+      value = lookup(name)
+      return value unless value.nil?
+    end
+    return args[1] if args.length == 2
+    raise "Can't find property '#{names.join("', '")}'"
+  end
+
+  def if_p(*names)
+    values = names.map { |name| lookup(name) }
+    return ElseBlock.new(true) if values.any?(&:nil?)
+    yield(*values)
+    ElseBlock.new(false)
+  end
+
+  def link(name)
+    raise "Can't find link '#{name}'; links are only known in a deployment"
+  end
+
+  def if_link(name)
+    ElseBlock.new(true)
+  end
+
+  def get_binding
+    binding
+  end
+
+  # The fields of the spec are available by name too, like name and index
+  def method_missing(name, *args)
+    return spec.send(name) if args.empty? && @raw_spec.key?(name.to_s)
+    super
+  end
+
+  def respond_to_missing?(name, include_private = false)
+    @raw_spec.key?(name.to_s) || super
+  end
+
+  private
+
+  def lookup(name)
+    name.split('.').inject(@properties) do |value, key|
+      return nil unless value.is_a?(Hash)
+      value[key]
+    end
+  end
+
+  def to_open_struct(value)
+    case value
+    when Hash
+      OpenStruct.new(Hash[value.map { |k, v| [k, to_open_struct(v)] }])
+    when Array
+      value.map { |v| to_open_struct(v) }
+    else
+      value
+    end
+  end
+end
+
+spec_path, template_path, template_name = ARGV
+template = File.read(template_path)
+erb = if ERB.instance_method(:initialize).parameters.assoc(:key)
+        ERB.new(template, trim_mode: '-')
+      else
+        ERB.new(template, nil, '-')
+      end
+erb.filename = template_name
+
+begin
+  print erb.result(RenderContext.new(JSON.parse(File.read(spec_path))).get_binding)
+rescue Exception => e
+  line = e.backtrace.find { |frame| frame.start_with?("#{template_name}:") }
+  $stderr.puts(line ? "#{line.split(':in ').first}: #{e.message}" : e.message)
+  exit 1
+end
+`
+
+// The ways job templates can be rendered, see RenderOptions
+const (
+	// ERBRendererLocal renders templates with a local Ruby interpreter
+	ERBRendererLocal = "local"
+	// ERBRendererDocker renders templates with Ruby in a container of RubyImage
+	ERBRendererDocker = "docker"
+	// ERBRendererBuiltin renders templates without Ruby, knowing only the
+	// common patterns of job templates; see the erb package
+	ERBRendererBuiltin = "builtin"
+)
+
+// ERBRenderers lists the ways job templates can be rendered
+var ERBRenderers = []string{ERBRendererLocal, ERBRendererDocker, ERBRendererBuiltin}
+
+// DefaultRubyImage is the image templates are rendered in by the docker ERB
+// renderer; its Ruby matches the one of the BOSH agent
+const DefaultRubyImage = "ruby:2.3.8-slim"
+
+// runTemplateRenderer renders a job template with the JSON spec of its job,
+// the way the options select. Tests replace it.
+var runTemplateRenderer = func(opts RenderOptions, spec []byte, template *model.JobTemplate) ([]byte, error) {
+	switch opts.ERBRenderer {
+	case ERBRendererBuiltin:
+		var values map[string]interface{}
+		if err := json.Unmarshal(spec, &values); err != nil {
+			return nil, err
+		}
+		rendered, err := erb.Render(template.SourcePath, template.Content, values)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(rendered), nil
+	}
+
+	tempDir, err := util.TempDir(opts.ScratchDir, "fissile-render")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "spec.json"), spec, 0644); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "template.erb"), []byte(template.Content), 0644); err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	if opts.ERBRenderer == ERBRendererDocker {
+		err = renderInContainer(opts.RubyImage, tempDir, template.SourcePath, &stdout, &stderr)
+	} else {
+		cmd := exec.Command(opts.Ruby, "-e", renderTemplateScript,
+			filepath.Join(tempDir, "spec.json"), filepath.Join(tempDir, "template.erb"), template.SourcePath)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err = cmd.Run()
+	}
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s", message)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// renderInContainer runs the template rendering script in a container of the
+// Ruby image, pulled if needed, with the spec and the template written to the
+// input directory
+func renderInContainer(rubyImage, inputDir, templateName string, stdout, stderr io.Writer) error {
+	dockerManager, err := docker.NewImageManager()
+	if err != nil {
+		return fmt.Errorf("Error connecting to docker: %s", err.Error())
+	}
+
+	hasImage, err := dockerManager.HasImage(rubyImage)
+	if err != nil {
+		return fmt.Errorf("Error looking up image %s: %s", rubyImage, err.Error())
+	}
+	if !hasImage {
+		if err := dockerManager.PullImage(rubyImage, ioutil.Discard); err != nil {
+			return fmt.Errorf("Error pulling image %s: %s", rubyImage, err.Error())
+		}
+	}
+
+	exitCode, container, err := dockerManager.RunInContainer(docker.RunInContainerOpts{
+		ContainerName: fmt.Sprintf("fissile-render-%s", uuid.New()),
+		ImageName:     rubyImage,
+		Cmd: []string{"ruby", "-e", renderTemplateScript,
+			filepath.Join(docker.ContainerInPath, "spec.json"),
+			filepath.Join(docker.ContainerInPath, "template.erb"),
+			templateName},
+		Mounts:       map[string]string{inputDir: docker.ContainerInPath},
+		StdoutWriter: stdout,
+		StderrWriter: stderr,
+	})
+	if container != nil {
+		defer dockerManager.RemoveContainer(container.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("Error running container: %s", err.Error())
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("Rendering exited with code %d", exitCode)
+	}
+	return nil
+}
+
+// RenderOptions are the inputs of RenderJobTemplates
+type RenderOptions struct {
+	RolesManifestPath string
+	LightManifestPath string
+	DarkManifestPath  string
+	DefaultEnvFiles   []string // Env files with values of the configuration variables
+	Role              string
+	Job               string
+	Template          string // Source or destination of the template to render; all templates of the job when empty
+	OutputDir         string // Where the rendered templates are written, as <role>/<job>/<destination>; not written when empty
+	PreviousDir       string // Templates rendered before, laid out like OutputDir, to show the differences with
+	ERBRenderer       string // How templates are rendered, one of ERBRenderers; local when empty
+	Ruby              string // Ruby interpreter rendering the templates locally
+	RubyImage         string // Image rendering the templates in docker; DefaultRubyImage when empty
+	ScratchDir        string // Where templates are written to be rendered; the system temporary directory when empty
+}
+
+// RenderJobTemplates renders the templates of a job of a role locally, like
+// configgin does when the role starts, with the properties of the job: its
+// spec defaults, the opinions and the configuration templates of the role,
+// using the defaults of the configuration variables overridden by the env
+// files and then by the environment. The rendered templates are printed, or,
+// given the templates rendered before, the differences with those.
+func (f *Fissile) RenderJobTemplates(opts RenderOptions) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	switch opts.ERBRenderer {
+	case "":
+		opts.ERBRenderer = ERBRendererLocal
+	case ERBRendererLocal, ERBRendererDocker, ERBRendererBuiltin:
+	default:
+		return fmt.Errorf("Unknown ERB renderer %s, expected one of %s", opts.ERBRenderer, strings.Join(ERBRenderers, ", "))
+	}
+	if opts.RubyImage == "" {
+		opts.RubyImage = DefaultRubyImage
+	}
+
+	rolesManifest, err := f.loadRoleManifest(opts.RolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	role := rolesManifest.LookupRole(opts.Role)
+	if role == nil {
+		return fmt.Errorf("Role %s not found in the roles manifest", opts.Role)
+	}
+	var job *model.Job
+	for _, roleJob := range role.Jobs {
+		if roleJob.Name == opts.Job {
+			job = roleJob
+		}
+	}
+	if job == nil {
+		return fmt.Errorf("Job %s is not part of role %s", opts.Job, role.Name)
+	}
+
+	var templates []*model.JobTemplate
+	for _, template := range role.GetJobTemplates(job) {
+		if opts.Template == "" || opts.Template == template.SourcePath || opts.Template == template.DestinationPath {
+			templates = append(templates, template)
+		}
+	}
+	if len(templates) == 0 {
+		return fmt.Errorf("Job %s has no template %s", job.Name, opts.Template)
+	}
+	sort.Sort(jobTemplatesByDestination(templates))
+
+	values, err := renderValues(rolesManifest, opts.DefaultEnvFiles)
+	if err != nil {
+		return err
+	}
+	properties, err := role.JobProperties(job, opts.LightManifestPath, opts.DarkManifestPath, values)
+	if err != nil {
+		return categorize(ErrorCategoryManifest, err)
+	}
+	spec, err := json.MarshalIndent(renderSpec(role, properties), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	for _, template := range templates {
+		rendered, err := runTemplateRenderer(opts, spec, template)
+		if err != nil {
+			return categorizedErrorf(ErrorCategoryManifest, "Error rendering template %s of job %s: %s", template.SourcePath, job.Name, err.Error())
+		}
+
+		relativePath := filepath.Join(role.Name, job.Name, template.DestinationPath)
+		if opts.PreviousDir != "" {
+			if err := f.showRenderDiff(opts.PreviousDir, relativePath, template.DestinationPath, rendered); err != nil {
+				return err
+			}
+		} else {
+			f.UI.Printf("%s\n%s", color.GreenString("# %s (%s)", template.DestinationPath, template.SourcePath), rendered)
+			if len(rendered) > 0 && rendered[len(rendered)-1] != '\n' {
+				f.UI.Println()
+			}
+		}
+
+		if opts.OutputDir != "" {
+			outputPath := filepath.Join(opts.OutputDir, relativePath)
+			if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(outputPath, rendered, 0644); err != nil {
+				return fmt.Errorf("Error writing rendered template %s: %s", outputPath, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// showRenderDiff prints the differences between a rendered template and the
+// one rendered before
+func (f *Fissile) showRenderDiff(previousDir, relativePath, destination string, rendered []byte) error {
+	previous, err := ioutil.ReadFile(filepath.Join(previousDir, relativePath))
+	if os.IsNotExist(err) {
+		f.UI.Printf("%s: %s\n", color.GreenString(destination), color.YellowString("new"))
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if bytes.Equal(previous, rendered) {
+		f.UI.Printf("%s: unchanged\n", color.GreenString(destination))
+		return nil
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(previous)),
+		B:        difflib.SplitLines(string(rendered)),
+		FromFile: filepath.Join("previous", relativePath),
+		ToFile:   filepath.Join("rendered", relativePath),
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	f.UI.Printf("%s: %s\n%s", color.GreenString(destination), color.RedString("changed"), diff)
+	return nil
+}
+
+// renderValues returns the values of the configuration variables templates
+// are rendered with: their defaults, overridden by the env files, and by the
+// environment
+func renderValues(rolesManifest *model.RoleManifest, defaultEnvFiles []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, variable := range rolesManifest.Configuration.Variables {
+		if variable.Default != nil {
+			values[variable.Name] = fmt.Sprintf("%v", variable.Default)
+		}
+	}
+
+	if len(defaultEnvFiles) > 0 {
+		defaults, err := godotenv.Read(defaultEnvFiles...)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range defaults {
+			values[name] = value
+		}
+	}
+
+	for _, variable := range rolesManifest.Configuration.Variables {
+		if value, ok := os.LookupEnv(variable.Name); ok {
+			values[variable.Name] = value
+		}
+	}
+
+	return values, nil
+}
+
+// renderSpec returns the spec job templates are rendered with, as BOSH gives
+// it to the first instance of the role
+func renderSpec(role *model.Role, properties map[string]interface{}) map[string]interface{} {
+	var templates []map[string]string
+	for _, job := range role.Jobs {
+		templates = append(templates, map[string]string{"name": job.Name})
+	}
+
+	return map[string]interface{}{
+		"name":       role.Name,
+		"index":      0,
+		"id":         "0",
+		"az":         "az0",
+		"bootstrap":  true,
+		"deployment": role.Name,
+		"address":    role.Name,
+		"ip":         "127.0.0.1",
+		"networks": map[string]interface{}{
+			"default": map[string]interface{}{
+				"ip":              "127.0.0.1",
+				"dns_record_name": role.Name,
+			},
+		},
+		"job": map[string]interface{}{
+			"name":      role.Name,
+			"templates": templates,
+		},
+		"properties": properties,
+	}
+}
+
+// jobTemplatesByDestination sorts job templates by their destination
+type jobTemplatesByDestination []*model.JobTemplate
+
+// Len is the number of templates in the slice
+func (t jobTemplatesByDestination) Len() int {
+	return len(t)
+}
+
+// Less reports whether the template at i is written before the one at j
+func (t jobTemplatesByDestination) Less(i, j int) bool {
+	return t[i].DestinationPath < t[j].DestinationPath
+}
+
+// Swap exchanges the templates at i and j
+func (t jobTemplatesByDestination) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+}
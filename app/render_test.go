@@ -0,0 +1,140 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderJobTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	outDir, err := ioutil.TempDir("", "fissile-render-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(outDir)
+
+	savedRunTemplateRenderer := runTemplateRenderer
+	defer func() { runTemplateRenderer = savedRunTemplateRenderer }()
+	runTemplateRenderer = func(opts RenderOptions, spec []byte, template *model.JobTemplate) ([]byte, error) {
+		var parsed struct {
+			Name       string
+			Properties struct {
+				Tor struct {
+					Hostname string
+				}
+			}
+		}
+		if err := json.Unmarshal(spec, &parsed); err != nil {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf("%s %s on %s\n", opts.Ruby, parsed.Properties.Tor.Hostname, parsed.Name)), nil
+	}
+
+	defaultsPath := filepath.Join(outDir, "defaults.env")
+	if !assert.NoError(ioutil.WriteFile(defaultsPath, []byte("TOR_HOSTNAME=fromfile\n"), 0644)) {
+		return
+	}
+
+	opts := RenderOptions{
+		RolesManifestPath: filepath.Join(workDir, "../test-assets/role-manifests/render.yml"),
+		LightManifestPath: filepath.Join(workDir, "../test-assets/tor-opinions/opinions.yml"),
+		DarkManifestPath:  filepath.Join(workDir, "../test-assets/tor-opinions/dark-opinions.yml"),
+		Role:              "myrole",
+		Job:               "tor",
+		Template:          "config/torrc.erb",
+		OutputDir:         filepath.Join(outDir, "first"),
+		Ruby:              "ruby",
+	}
+
+	buffer := &bytes.Buffer{}
+	f := NewFissileApplication(".", termui.New(&bytes.Buffer{}, buffer, nil))
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathCache := filepath.Join(torReleasePath, "bosh-cache")
+	if !assert.NoError(f.LoadReleases([]string{torReleasePath}, []string{""}, []string{""}, torReleasePathCache)) {
+		return
+	}
+
+	if !assert.NoError(f.RenderJobTemplates(opts)) {
+		return
+	}
+	assert.Contains(buffer.String(), "config/torrc (config/torrc.erb)")
+	assert.Contains(buffer.String(), "ruby example.onion on myrole")
+	rendered, err := ioutil.ReadFile(filepath.Join(outDir, "first", "myrole", "tor", "config", "torrc"))
+	assert.NoError(err)
+	assert.Equal("ruby example.onion on myrole\n", string(rendered))
+
+	// Compare with the first render, with the defaults file
+	buffer.Reset()
+	opts.Template = "config/torrc"
+	opts.DefaultEnvFiles = []string{defaultsPath}
+	opts.OutputDir = ""
+	opts.PreviousDir = filepath.Join(outDir, "first")
+	if !assert.NoError(f.RenderJobTemplates(opts)) {
+		return
+	}
+	assert.Contains(buffer.String(), "config/torrc: changed")
+	assert.Contains(buffer.String(), "-ruby example.onion on myrole")
+	assert.Contains(buffer.String(), "+ruby fromfile.onion on myrole")
+
+	// The environment overrides the defaults file
+	buffer.Reset()
+	os.Setenv("TOR_HOSTNAME", "example")
+	defer os.Unsetenv("TOR_HOSTNAME")
+	if !assert.NoError(f.RenderJobTemplates(opts)) {
+		return
+	}
+	assert.Contains(buffer.String(), "config/torrc: unchanged")
+
+	opts.Job = "new_hostname"
+	assert.EqualError(f.RenderJobTemplates(opts), "Job new_hostname is not part of role myrole")
+	opts.Job = "tor"
+	opts.Template = "missing.erb"
+	assert.EqualError(f.RenderJobTemplates(opts), "Job tor has no template missing.erb")
+}
+
+func TestRenderJobTemplatesBuiltin(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	opts := RenderOptions{
+		RolesManifestPath: filepath.Join(workDir, "../test-assets/role-manifests/render.yml"),
+		LightManifestPath: filepath.Join(workDir, "../test-assets/tor-opinions/opinions.yml"),
+		DarkManifestPath:  filepath.Join(workDir, "../test-assets/tor-opinions/dark-opinions.yml"),
+		Role:              "myrole",
+		Job:               "tor",
+		ERBRenderer:       ERBRendererBuiltin,
+	}
+
+	buffer := &bytes.Buffer{}
+	f := NewFissileApplication(".", termui.New(&bytes.Buffer{}, buffer, nil))
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathCache := filepath.Join(torReleasePath, "bosh-cache")
+	if !assert.NoError(f.LoadReleases([]string{torReleasePath}, []string{""}, []string{""}, torReleasePathCache)) {
+		return
+	}
+
+	if !assert.NoError(f.RenderJobTemplates(opts)) {
+		return
+	}
+	assert.Contains(buffer.String(), "hidden_service/hostname (hidden_service/hostname.erb)\nexample.onion\n")
+	assert.Contains(buffer.String(), "export NAME='myrole'\nexport JOB_INDEX=0\n")
+
+	opts.ERBRenderer = "python"
+	assert.EqualError(f.RenderJobTemplates(opts), "Unknown ERB renderer python, expected one of local, docker, builtin")
+}
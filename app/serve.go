@@ -0,0 +1,188 @@
+package app
+
+import (
+	"encoding/json"
+	_ "expvar" // Registers /debug/vars with http.DefaultServeMux
+	"fmt"
+	"html/template"
+	"net/http"
+	_ "net/http/pprof" // Registers /debug/pprof/ with http.DefaultServeMux
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// artifact describes a file served by ServeArtifacts
+type artifact struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+var artifactsIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><title>fissile artifacts</title></head>
+<body>
+<h1>fissile artifacts</h1>
+<p>{{ .Root }}</p>
+<table>
+<tr><th>Path</th><th>Size</th><th>Modified</th></tr>
+{{ range .Artifacts }}<tr><td><a href="/{{ .Path }}">{{ .Path }}</a></td><td>{{ .Size }}</td><td>{{ .Modified.Format "2006-01-02 15:04:05" }}</td></tr>
+{{ end }}</table>
+</body>
+</html>
+`))
+
+// ServeArtifacts serves the files below rootDir (generated kube configs,
+// Dockerfiles, env files, reports, ...) over HTTP. The root URL has an index
+// of all files, and /index.json has the same list for use by scripts. With
+// debugEndpoints, the pprof and expvar endpoints are served below /debug/.
+func (f *Fissile) ServeArtifacts(rootDir, address string, debugEndpoints bool) error {
+	info, err := os.Stat(rootDir)
+	if err != nil {
+		return fmt.Errorf("Error accessing artifacts directory: %s", err.Error())
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("Artifacts path %s is not a directory", rootDir)
+	}
+
+	f.UI.Printf("Serving artifacts from %s on %s\n",
+		color.CyanString(rootDir),
+		color.CyanString("http://%s/", address),
+	)
+
+	if debugEndpoints {
+		f.UI.Printf("Serving debug endpoints on %s\n", color.CyanString("http://%s/debug/pprof/", address))
+	}
+
+	return http.ListenAndServe(address, newArtifactsHandler(rootDir, debugEndpoints))
+}
+
+// newArtifactsHandler returns the HTTP handler used by ServeArtifacts
+func newArtifactsHandler(rootDir string, debugEndpoints bool) http.Handler {
+	mux := http.NewServeMux()
+	fileServer := http.FileServer(visibleFilesDir{http.Dir(rootDir)})
+
+	if debugEndpoints {
+		// The pprof and expvar packages only register with the default mux
+		mux.Handle("/debug/", http.DefaultServeMux)
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			if isHiddenPath(r.URL.Path) {
+				http.NotFound(w, r)
+				return
+			}
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		artifacts, err := listArtifacts(rootDir)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		artifactsIndexTemplate.Execute(w, map[string]interface{}{
+			"Root":      rootDir,
+			"Artifacts": artifacts,
+		})
+	})
+
+	mux.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) {
+		artifacts, err := listArtifacts(rootDir)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(artifacts)
+	})
+
+	return mux
+}
+
+// isHiddenPath reports whether any segment of the URL path urlPath starts
+// with a dot; those files are neither listed nor served
+func isHiddenPath(urlPath string) bool {
+	for _, segment := range strings.Split(urlPath, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleFilesDir is an http.Dir whose directory listings leave out hidden
+// files
+type visibleFilesDir struct {
+	http.Dir
+}
+
+// Open opens the file at name in the directory
+func (d visibleFilesDir) Open(name string) (http.File, error) {
+	file, err := d.Dir.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return visibleFilesFile{file}, nil
+}
+
+// visibleFilesFile is a file of a visibleFilesDir
+type visibleFilesFile struct {
+	http.File
+}
+
+// Readdir returns the entries of the directory, without the hidden ones
+func (f visibleFilesFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	visible := infos[:0]
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), ".") {
+			visible = append(visible, info)
+		}
+	}
+	return visible, err
+}
+
+// listArtifacts returns all non-hidden files below rootDir, in lexical order
+func listArtifacts(rootDir string) ([]artifact, error) {
+	artifacts := []artifact{}
+
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != rootDir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, artifact{
+			Path:     filepath.ToSlash(relPath),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return artifacts, nil
+}
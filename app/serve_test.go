@@ -0,0 +1,114 @@
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifactsHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	rootDir, err := ioutil.TempDir("", "fissile-serve")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(rootDir)
+
+	assert.NoError(os.MkdirAll(filepath.Join(rootDir, "kube", "bosh"), 0755))
+	assert.NoError(os.MkdirAll(filepath.Join(rootDir, ".hidden"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(rootDir, "kube", "bosh", "myrole.yml"), []byte("---\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(rootDir, "defaults.env"), []byte("FOO=bar\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(rootDir, ".hidden", "secret"), []byte("secret"), 0644))
+
+	server := httptest.NewServer(newArtifactsHandler(rootDir, false))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/index.json")
+	if !assert.NoError(err) {
+		return
+	}
+	var artifacts []artifact
+	assert.NoError(json.NewDecoder(response.Body).Decode(&artifacts))
+	response.Body.Close()
+	if assert.Len(artifacts, 2) {
+		assert.Equal("defaults.env", artifacts[0].Path)
+		assert.Equal("kube/bosh/myrole.yml", artifacts[1].Path)
+		assert.Equal(int64(4), artifacts[1].Size)
+	}
+
+	response, err = http.Get(server.URL + "/")
+	if !assert.NoError(err) {
+		return
+	}
+	index, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.NoError(err)
+	assert.Contains(string(index), `<a href="/kube/bosh/myrole.yml">kube/bosh/myrole.yml</a>`)
+	assert.NotContains(string(index), "secret")
+
+	response, err = http.Get(server.URL + "/kube/bosh/myrole.yml")
+	if !assert.NoError(err) {
+		return
+	}
+	contents, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal("---\n", string(contents))
+
+	for _, path := range []string{"/.hidden/secret", "/.hidden/", "/kube/../.hidden/secret"} {
+		response, err = http.Get(server.URL + path)
+		if !assert.NoError(err) {
+			return
+		}
+		response.Body.Close()
+		assert.Equal(http.StatusNotFound, response.StatusCode, "Hidden path %s served", path)
+	}
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(rootDir, "kube", ".secret"), []byte("secret"), 0644))
+	response, err = http.Get(server.URL + "/kube/")
+	if !assert.NoError(err) {
+		return
+	}
+	listing, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.NoError(err)
+	assert.Contains(string(listing), "bosh/")
+	assert.NotContains(string(listing), ".secret")
+}
+
+func TestServeArtifactsDebugEndpoints(t *testing.T) {
+	assert := assert.New(t)
+
+	rootDir, err := ioutil.TempDir("", "fissile-serve")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(rootDir)
+
+	for _, debugEndpoints := range []bool{false, true} {
+		server := httptest.NewServer(newArtifactsHandler(rootDir, debugEndpoints))
+
+		for _, path := range []string{"/debug/pprof/heap", "/debug/vars"} {
+			response, err := http.Get(server.URL + path)
+			if !assert.NoError(err) {
+				continue
+			}
+			response.Body.Close()
+			if debugEndpoints {
+				assert.Equal(http.StatusOK, response.StatusCode, "Debug endpoint %s not served", path)
+			} else {
+				assert.Equal(http.StatusNotFound, response.StatusCode, "Debug endpoint %s served", path)
+			}
+		}
+
+		server.Close()
+	}
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+	"github.com/hpcloud/fissile/util"
+
+	"github.com/fatih/color"
+)
+
+// ShowStats displays a summary of the role manifest: the roles by type, their
+// jobs and packages, the resources they request, their ports and the images
+// fissile builds for them. Image sizes only count compiled packages.
+func (f *Fissile) ShowStats(rolesManifestPath, compiledPackagesPath string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	rolesManifest, err := f.loadRoleManifest(rolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	stats := rolesManifest.Stats()
+
+	var roleTypes []string
+	for roleType, count := range stats.RolesByType {
+		roleTypes = append(roleTypes, fmt.Sprintf("%d %s", count, roleType))
+	}
+	sort.Strings(roleTypes)
+	f.UI.Printf("Roles: %s (%s)\n", color.GreenString("%d", len(rolesManifest.Roles)), strings.Join(roleTypes, ", "))
+	f.UI.Printf("Jobs: %s\n", color.YellowString("%d", stats.Jobs))
+	f.UI.Printf("Packages: %s (%s unique)\n", color.YellowString("%d", stats.Packages), color.YellowString("%d", stats.UniquePackages))
+
+	f.UI.Printf("Memory: %s\n", describeResourceTotals(stats.Memory, "MB"))
+	f.UI.Printf("Virtual CPUs: %s\n", describeResourceTotals(stats.VirtualCPUs, ""))
+	var resourceNames []string
+	for name := range stats.Resources {
+		resourceNames = append(resourceNames, name)
+	}
+	sort.Strings(resourceNames)
+	for _, name := range resourceNames {
+		f.UI.Printf("%s: %s\n", name, describeResourceTotals(stats.Resources[name], ""))
+	}
+
+	f.UI.Printf("Exposed ports: %s (%s public)\n", color.YellowString("%d", stats.ExposedPorts), color.YellowString("%d", stats.PublicPorts))
+
+	var total int64
+	uncompiled := 0
+	for _, role := range rolesManifest.Roles {
+		for _, pkg := range role.GetPackages() {
+			if _, err := os.Stat(pkg.GetPackageCompiledDir(compiledPackagesPath)); err != nil {
+				uncompiled++
+				continue
+			}
+			size, err := pkg.GetCompiledSize(compiledPackagesPath)
+			if err != nil {
+				uncompiled++
+				continue
+			}
+			total += size
+		}
+	}
+	summary := fmt.Sprintf("%s of compiled packages", util.FormatBytes(uint64(total)))
+	if uncompiled > 0 {
+		summary = fmt.Sprintf("%s, %d packages not compiled", summary, uncompiled)
+	}
+	f.UI.Printf("Role images: %s (%s)\n", color.YellowString("%d", stats.Images), summary)
+
+	return nil
+}
+
+// describeResourceTotals describes the amount of a resource requested by all
+// roles at their minimum and maximum scale
+func describeResourceTotals(totals model.ResourceTotals, unit string) string {
+	return fmt.Sprintf("%s%s at minimum scale, %s%s at maximum scale",
+		color.YellowString("%d", totals.Min), unit, color.YellowString("%d", totals.Max), unit)
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpcloud/fissile/docker"
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+)
+
+// These labels on the stemcell image (and thus on the role base image built
+// from it) name the OS and version of the stemcell
+const (
+	stemcellOSLabel      = "stemcell-flavor"
+	stemcellVersionLabel = "stemcell-version"
+)
+
+// CheckStemcellConstraints warns about releases built for stemcells that the
+// stemcell used for the images doesn't satisfy; such images tend to fail at
+// runtime with glibc or kernel mismatches. The stemcell is given as
+// <os>/<version>; if it is empty, it is read from the labels of the image.
+func (f *Fissile) CheckStemcellConstraints(stemcellName, imageName string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	var constrained []*model.Release
+	for _, release := range f.releases {
+		if len(release.StemcellConstraints) > 0 {
+			constrained = append(constrained, release)
+		}
+	}
+	if len(constrained) == 0 {
+		return nil
+	}
+
+	var stemcell *model.Stemcell
+	var err error
+	if stemcellName != "" {
+		stemcell, err = model.ParseStemcell(stemcellName)
+	} else {
+		stemcell, err = getImageStemcell(imageName)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range stemcellWarnings(constrained, stemcell) {
+		f.UI.Println(color.YellowString("Warning: %s", warning))
+	}
+
+	return nil
+}
+
+// stemcellWarnings returns a warning for each release none of whose stemcell
+// constraints are satisfied by the stemcell. A nil stemcell is unknown.
+func stemcellWarnings(releases []*model.Release, stemcell *model.Stemcell) []string {
+	var warnings []string
+
+	for _, release := range releases {
+		constraints := make([]string, 0, len(release.StemcellConstraints))
+		satisfied := false
+		for _, constraint := range release.StemcellConstraints {
+			constraints = append(constraints, constraint.String())
+			if stemcell != nil && constraint.SatisfiedBy(stemcell) {
+				satisfied = true
+			}
+		}
+		if satisfied {
+			continue
+		}
+
+		if stemcell == nil {
+			warnings = append(warnings, fmt.Sprintf(
+				"Release %s was built for stemcell %s, but the stemcell in use is unknown; use --stemcell-os to check it",
+				release.Name, strings.Join(constraints, " or ")))
+		} else {
+			warnings = append(warnings, fmt.Sprintf(
+				"Release %s was built for stemcell %s, but the stemcell in use is %s",
+				release.Name, strings.Join(constraints, " or "), stemcell.String()))
+		}
+	}
+
+	return warnings
+}
+
+// getImageStemcell reads the stemcell from the labels of an image. It returns
+// nil if the image doesn't exist or has no stemcell labels.
+func getImageStemcell(imageName string) (*model.Stemcell, error) {
+	dockerManager, err := docker.NewImageManager()
+	if err != nil {
+		return nil, categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
+	}
+
+	image, err := dockerManager.FindImage(imageName)
+	if err == docker.ErrImageNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if image.Config == nil || image.Config.Labels[stemcellOSLabel] == "" {
+		return nil, nil
+	}
+
+	return &model.Stemcell{
+		OS:      image.Config.Labels[stemcellOSLabel],
+		Version: image.Config.Labels[stemcellVersionLabel],
+	}, nil
+}
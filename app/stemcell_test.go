@@ -0,0 +1,40 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStemcellWarnings(t *testing.T) {
+	assert := assert.New(t)
+
+	releases := []*model.Release{
+		{
+			Name:                "trusty",
+			StemcellConstraints: []*model.Stemcell{{OS: "ubuntu-trusty", Version: "3421.11"}},
+		},
+		{
+			Name: "either",
+			StemcellConstraints: []*model.Stemcell{
+				{OS: "ubuntu-trusty", Version: "3445"},
+				{OS: "opensuse-42"},
+			},
+		},
+	}
+
+	assert.Equal([]string{
+		"Release either was built for stemcell ubuntu-trusty/3445 or opensuse-42, but the stemcell in use is ubuntu-trusty/3421.26",
+	}, stemcellWarnings(releases, &model.Stemcell{OS: "ubuntu-trusty", Version: "3421.26"}))
+
+	assert.Equal([]string{
+		"Release trusty was built for stemcell ubuntu-trusty/3421.11, but the stemcell in use is opensuse-42/13.2",
+	}, stemcellWarnings(releases, &model.Stemcell{OS: "opensuse-42", Version: "13.2"}))
+
+	warnings := stemcellWarnings(releases[:1], nil)
+	if assert.Len(warnings, 1) {
+		assert.Contains(warnings[0], "the stemcell in use is unknown; use --stemcell-os to check it")
+	}
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hpcloud/fissile/docker"
+	"github.com/hpcloud/fissile/kube"
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+	dockerclient "github.com/fsouza/go-dockerclient"
+)
+
+// imageTagger is the part of docker.ImageManager used to retag role images,
+// shimmed for the unit test
+type imageTagger interface {
+	FindImage(imageName string) (*dockerclient.Image, error)
+	PullImage(imageName string, stdoutWriter io.Writer) error
+	TagImage(imageName, newName string) error
+	PushImage(imageName, pushName string, stdoutWriter io.Writer) error
+}
+
+// newImageTagger is a stub to be replaced by the unit test
+var newImageTagger = func() (imageTagger, error) { return docker.NewImageManager() }
+
+// TagOptions are the inputs of TagRoleImages
+type TagOptions struct {
+	Repository        string
+	FromRegistry      string // Registry of the existing images; the local dev images if it and FromOrganization are empty
+	FromOrganization  string
+	Registry          string // Registry of the new names
+	Organization      string
+	RolesManifestPath string
+	Pull              bool   // Pull the existing images first
+	Push              bool   // Push the images under the new names
+	Channel           string // Channel the images are in, e.g. dev; see PromoteRoleImages
+	ResultsPath       string // Where the build results are written; they are printed when empty
+}
+
+// TaggedImage is a role image in the build results of TagRoleImages
+type TaggedImage struct {
+	Role   string `json:"role"`
+	Image  string `json:"image"`
+	Source string `json:"source"`
+	ID     string `json:"id"`
+	Digest string `json:"digest,omitempty"` // The repository digest, if known; the one of the source for tagged images
+}
+
+// TagResults are the build results of TagRoleImages
+type TagResults struct {
+	Channel      string         `json:"channel,omitempty"`
+	Registry     string         `json:"registry"`
+	Organization string         `json:"organization"`
+	Images       []*TaggedImage `json:"images"`
+}
+
+// TagRoleImages gives the role images built before the names they have in
+// another registry or organization, without building anything, and writes
+// the resulting names as build results. The new names point to the same
+// images as the existing ones, so promoted images are the ones that were
+// tested. Docker roles are not built by fissile, and are left out.
+func (f *Fissile) TagRoleImages(opts TagOptions) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	if opts.Push && opts.Registry == "" {
+		return categorizedErrorf(ErrorCategoryPush, "A docker registry is needed to push images")
+	}
+
+	rolesManifest, err := f.loadRoleManifest(opts.RolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	tagger, err := newImageTagger()
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryDocker, "Error connecting to docker: %s", err.Error())
+	}
+
+	from := &kube.ExportSettings{
+		Registry:     opts.FromRegistry,
+		Organization: opts.FromOrganization,
+		Repository:   opts.Repository,
+	}
+	to := &kube.ExportSettings{
+		Registry:     opts.Registry,
+		Organization: opts.Organization,
+		Repository:   opts.Repository,
+	}
+
+	results := &TagResults{
+		Channel:      opts.Channel,
+		Registry:     opts.Registry,
+		Organization: opts.Organization,
+		Images:       []*TaggedImage{},
+	}
+	for _, role := range rolesManifest.Roles {
+		if role.Type == model.RoleTypeDocker {
+			continue
+		}
+		source := kube.ContainerImageName(role, from)
+		imageName := kube.ContainerImageName(role, to)
+
+		log := new(bytes.Buffer)
+		if opts.Pull {
+			f.UI.Printf("Pulling image %s\n", color.YellowString(source))
+			if err := tagger.PullImage(source, log); err != nil {
+				log.WriteTo(f.UI)
+				return categorize(ErrorCategoryDocker, err)
+			}
+		}
+		image, err := tagger.FindImage(source)
+		if err == docker.ErrImageNotFound {
+			return categorizedErrorf(ErrorCategoryDocker, "Image %s of role %s not found", source, role.Name)
+		} else if err != nil {
+			return categorize(ErrorCategoryDocker, err)
+		}
+
+		// Tag the image itself, so that the source can't change in between
+		f.UI.Printf("Tagging image %s as %s\n", color.YellowString(source), color.GreenString(imageName))
+		if opts.Push {
+			err = tagger.PushImage(image.ID, imageName, log)
+		} else {
+			err = tagger.TagImage(image.ID, imageName)
+		}
+		if err != nil {
+			log.WriteTo(f.UI)
+			return categorize(ErrorCategoryPush, err)
+		}
+
+		results.Images = append(results.Images, &TaggedImage{
+			Role:   role.Name,
+			Image:  imageName,
+			Source: source,
+			ID:     image.ID,
+			Digest: repositoryDigest(image, source),
+		})
+	}
+
+	return f.writeTagResults(results, opts.ResultsPath)
+}
+
+// writeTagResults writes build results as JSON, or prints them if no path is
+// given
+func (f *Fissile) writeTagResults(results *TagResults, resultsPath string) error {
+	contents, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	contents = append(contents, '\n')
+	if resultsPath == "" {
+		f.UI.Printf("%s", contents)
+		return nil
+	}
+	if err := ioutil.WriteFile(resultsPath, contents, 0644); err != nil {
+		return fmt.Errorf("Error writing build results: %s", err.Error())
+	}
+	return nil
+}
+
+// repositoryDigest returns the digest an image has in the repository of the
+// given image name, as found in the repository digests of images that were
+// pulled or pushed
+func repositoryDigest(image *dockerclient.Image, imageName string) string {
+	repository, _ := dockerclient.ParseRepositoryTag(imageName)
+	for _, repoDigest := range image.RepoDigests {
+		if strings.HasPrefix(repoDigest, repository+"@") {
+			return strings.TrimPrefix(repoDigest, repository+"@")
+		}
+	}
+	return ""
+}
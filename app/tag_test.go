@@ -0,0 +1,146 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hpcloud/fissile/docker"
+	"github.com/hpcloud/fissile/model"
+
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeImageTagger records the calls of TagRoleImages
+type fakeImageTagger struct {
+	images map[string]*dockerclient.Image
+	calls  []string
+}
+
+func (t *fakeImageTagger) FindImage(imageName string) (*dockerclient.Image, error) {
+	image, ok := t.images[imageName]
+	if !ok {
+		return nil, docker.ErrImageNotFound
+	}
+	return image, nil
+}
+
+func (t *fakeImageTagger) PullImage(imageName string, stdoutWriter io.Writer) error {
+	t.calls = append(t.calls, fmt.Sprintf("pull %s", imageName))
+	return nil
+}
+
+func (t *fakeImageTagger) TagImage(imageName, newName string) error {
+	t.calls = append(t.calls, fmt.Sprintf("tag %s %s", imageName, newName))
+	return nil
+}
+
+func (t *fakeImageTagger) PushImage(imageName, pushName string, stdoutWriter io.Writer) error {
+	t.calls = append(t.calls, fmt.Sprintf("push %s %s", imageName, pushName))
+	// The registry gives pushed images a digest
+	repository, _ := dockerclient.ParseRepositoryTag(pushName)
+	t.images[pushName] = &dockerclient.Image{
+		ID:          imageName,
+		RepoDigests: []string{fmt.Sprintf("%s@sha256:pushed-%s", repository, imageName)},
+	}
+	return nil
+}
+
+func TestTagRoleImages(t *testing.T) {
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml")
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	tagger := &fakeImageTagger{images: map[string]*dockerclient.Image{}}
+	savedNewImageTagger := newImageTagger
+	defer func() { newImageTagger = savedNewImageTagger }()
+	newImageTagger = func() (imageTagger, error) { return tagger, nil }
+
+	outDir, err := ioutil.TempDir("", "fissile-tag-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(outDir)
+	resultsPath := filepath.Join(outDir, "images.json")
+
+	opts := TagOptions{
+		Repository:        "fissile",
+		FromRegistry:      "staging.example.com",
+		Registry:          "prod.example.com",
+		Organization:      "cf",
+		RolesManifestPath: roleManifestPath,
+		ResultsPath:       resultsPath,
+	}
+
+	err = f.TagRoleImages(opts)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "Image staging.example.com/fissile-myrole:")
+		assert.Contains(err.Error(), "of role myrole not found")
+	}
+
+	rolesManifest, err := model.LoadRoleManifest(roleManifestPath, f.releases)
+	if !assert.NoError(err) {
+		return
+	}
+	for i, role := range rolesManifest.Roles {
+		tagger.images[fmt.Sprintf("staging.example.com/fissile-%s:%s", role.Name, role.GetRoleDevVersion())] = &dockerclient.Image{
+			ID:          fmt.Sprintf("sha256:%d", i),
+			RepoDigests: []string{fmt.Sprintf("staging.example.com/fissile-%s@sha256:digest%d", role.Name, i)},
+		}
+	}
+
+	opts.Pull = true
+	opts.Push = true
+	if !assert.NoError(f.TagRoleImages(opts)) {
+		return
+	}
+	assert.Len(tagger.calls, 4)
+	assert.True(strings.HasPrefix(tagger.calls[0], "pull staging.example.com/fissile-myrole:"))
+	assert.True(strings.HasPrefix(tagger.calls[1], "push sha256:0 prod.example.com/cf/fissile-myrole:"))
+
+	contents, err := ioutil.ReadFile(resultsPath)
+	if !assert.NoError(err) {
+		return
+	}
+	var results TagResults
+	if assert.NoError(json.Unmarshal(contents, &results)) && assert.Len(results.Images, 2) {
+		assert.Equal("prod.example.com", results.Registry)
+		assert.Equal("foorole", results.Images[1].Role)
+		assert.True(strings.HasPrefix(results.Images[1].Image, "prod.example.com/cf/fissile-foorole:"))
+		assert.True(strings.HasPrefix(results.Images[1].Source, "staging.example.com/fissile-foorole:"))
+		assert.Equal("sha256:1", results.Images[1].ID)
+		assert.Equal("sha256:digest1", results.Images[1].Digest)
+	}
+
+	tagger.calls = nil
+	opts.Pull = false
+	opts.Push = false
+	if assert.NoError(f.TagRoleImages(opts)) {
+		assert.Len(tagger.calls, 2)
+		assert.True(strings.HasPrefix(tagger.calls[0], "tag sha256:0 prod.example.com/cf/fissile-myrole:"))
+	}
+
+	opts.Push = true
+	opts.Registry = ""
+	assert.EqualError(f.TagRoleImages(opts), "A docker registry is needed to push images")
+}
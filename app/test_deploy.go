@@ -0,0 +1,270 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hpcloud/fissile/kube"
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+)
+
+// TestDeployOptions are the inputs of TestDeploy
+type TestDeployOptions struct {
+	RolesManifestPath string
+	Roles             []string       // Roles to deploy, with the roles they depend on; all roles when empty
+	Values            VariableValues // Values of the configuration variables
+	Repository        string
+	Registry          string
+	Organization      string
+	Kubectl           string        // Path to the kubectl binary
+	Kind              string        // Path to the kind binary
+	Cluster           string        // kind cluster to deploy to, created when missing; the kubectl context when empty
+	Namespace         string        // Created for the deployment, and deleted with it
+	Timeout           time.Duration // How long to wait for each wave of roles, and each smoke test
+	Keep              bool          // Leave the namespace, and the cluster fissile created, when done
+}
+
+// TestDeploy deploys roles to a Kubernetes cluster, runs their smoke tests and
+// tears the deployment down again. Roles are deployed wave by wave, like the
+// deploy script of build kube does, with small resource footprints so they fit
+// a cluster on a single machine: they request no memory nor CPUs, and long
+// running roles have a single instance. The smoke tests are the manual tasks
+// with the smoke-test tag among the roles; they run once all other roles are
+// ready, and all have to succeed.
+//
+// Given a kind cluster, the cluster is created if it doesn't exist, and the
+// role images are loaded into it, so they need not be pushed to a registry.
+func (f *Fissile) TestDeploy(opts TestDeployOptions) (err error) {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+	if opts.Namespace == "" {
+		return fmt.Errorf("Please specify the namespace to deploy to")
+	}
+
+	rolesManifest, err := f.loadRoleManifest(opts.RolesManifestPath)
+	if err != nil {
+		return categorizedErrorf(ErrorCategoryManifest, "Error loading roles manifest: %s", err.Error())
+	}
+
+	selected, err := testDeploySelectRoles(rolesManifest, opts.Roles)
+	if err != nil {
+		return err
+	}
+	waves, err := rolesManifest.DeployWaves()
+	if err != nil {
+		return err
+	}
+	var smokeTests model.Roles
+	for _, role := range rolesManifest.Roles {
+		if selected[role.Name] && role.HasTag(model.RoleTagSmokeTest) {
+			smokeTests = append(smokeTests, role)
+		}
+	}
+
+	defaults, err := opts.Values.Read(rolesManifest)
+	if err != nil {
+		return err
+	}
+	kinds, err := kube.NewKindFilter(nil, nil)
+	if err != nil {
+		return err
+	}
+	discovery, err := kube.NewDiscovery("", "")
+	if err != nil {
+		return err
+	}
+	settings := &kube.ExportSettings{
+		Defaults:     defaults,
+		Registry:     opts.Registry,
+		Organization: opts.Organization,
+		Repository:   opts.Repository,
+		Kinds:        kinds,
+		Discovery:    discovery,
+	}
+
+	var contextArgs []string
+	if opts.Cluster != "" {
+		created, err := f.testDeployCluster(opts)
+		if err != nil {
+			return err
+		}
+		if created && !opts.Keep {
+			defer func() {
+				f.UI.Printf("Deleting kind cluster %s\n", color.CyanString(opts.Cluster))
+				if _, deleteErr := runKubectl(opts.Kind, nil, "delete", "cluster", "--name", opts.Cluster); deleteErr != nil && err == nil {
+					err = deleteErr
+				}
+			}()
+		}
+		contextArgs = []string{"--context", "kind-" + opts.Cluster}
+	}
+	kubectlRun := func(stdin io.Reader, args ...string) ([]byte, error) {
+		return runKubectl(opts.Kubectl, stdin, append(contextArgs, args...)...)
+	}
+	namespacedRun := func(stdin io.Reader, args ...string) ([]byte, error) {
+		return kubectlRun(stdin, append([]string{"--namespace", opts.Namespace}, args...)...)
+	}
+
+	if _, err := kubectlRun(nil, "create", "namespace", opts.Namespace); err != nil {
+		return err
+	}
+	if !opts.Keep {
+		defer func() {
+			f.UI.Printf("Deleting namespace %s\n", color.CyanString(opts.Namespace))
+			if _, deleteErr := kubectlRun(nil, "delete", "namespace", opts.Namespace); deleteErr != nil && err == nil {
+				err = deleteErr
+			}
+		}()
+	}
+
+	for index, wave := range waves {
+		var roles model.Roles
+		var names []string
+		for _, role := range wave {
+			if selected[role.Name] {
+				roles = append(roles, role)
+				names = append(names, role.Name)
+			}
+		}
+		if len(roles) == 0 {
+			continue
+		}
+
+		f.UI.Printf("Deploying wave %d: %s\n", index+1, color.YellowString(strings.Join(names, " ")))
+		for _, role := range roles {
+			if err := f.testDeployApply(namespacedRun, role, settings, opts); err != nil {
+				return err
+			}
+		}
+		for _, role := range roles {
+			if err := f.testDeployWait(namespacedRun, role, opts.Timeout); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, role := range smokeTests {
+		f.UI.Printf("Running smoke test %s\n", color.YellowString(role.Name))
+		if err := f.testDeployApply(namespacedRun, role, settings, opts); err != nil {
+			return err
+		}
+		// Manual tasks are applied suspended
+		if _, err := namespacedRun(nil, "patch", "job", role.Name, "--patch", kube.ManualTaskRunPatch); err != nil {
+			return err
+		}
+		if err := f.waitForJob(namespacedRun, role.Name, opts.Timeout); err != nil {
+			if logs, logsErr := namespacedRun(nil, "logs", "job/"+role.Name); logsErr == nil {
+				f.UI.Printf("%s", logs)
+			}
+			return fmt.Errorf("Smoke test %s failed: %s", role.Name, err.Error())
+		}
+		f.UI.Printf("Smoke test %s passed\n", color.GreenString(role.Name))
+	}
+
+	return nil
+}
+
+// testDeploySelectRoles returns the names of the given roles and of the roles
+// they depend on, or of all roles when none are given
+func testDeploySelectRoles(rolesManifest *model.RoleManifest, names []string) (map[string]bool, error) {
+	selected := map[string]bool{}
+	if len(names) == 0 {
+		for _, role := range rolesManifest.Roles {
+			selected[role.Name] = true
+		}
+		return selected, nil
+	}
+
+	pending := append([]string{}, names...)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if selected[name] {
+			continue
+		}
+		role := rolesManifest.LookupRole(name)
+		if role == nil {
+			return nil, fmt.Errorf("Role %s not found in the roles manifest", name)
+		}
+		selected[name] = true
+		pending = append(pending, role.RoleDependencies()...)
+	}
+	return selected, nil
+}
+
+// testDeployCluster creates the kind cluster of the options if it doesn't
+// exist, and returns whether it was created
+func (f *Fissile) testDeployCluster(opts TestDeployOptions) (bool, error) {
+	// runKubectl runs any binary; kind is faked along with kubectl in tests
+	output, err := runKubectl(opts.Kind, nil, "get", "clusters")
+	if err != nil {
+		return false, err
+	}
+	created := true
+	for _, cluster := range strings.Fields(string(output)) {
+		if cluster == opts.Cluster {
+			created = false
+		}
+	}
+	if created {
+		f.UI.Printf("Creating kind cluster %s\n", color.CyanString(opts.Cluster))
+		if _, err := runKubectl(opts.Kind, nil, "create", "cluster", "--name", opts.Cluster, "--wait", opts.Timeout.String()); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
+}
+
+// testDeployApply creates the objects of a role with a small resource
+// footprint, after loading its image into the kind cluster of the options
+func (f *Fissile) testDeployApply(kubectlRun func(io.Reader, ...string) ([]byte, error), role *model.Role, settings *kube.ExportSettings, opts TestDeployOptions) error {
+	if role.Run != nil {
+		role.Run.Memory = 0
+		role.Run.VirtualCPUs = 0
+		role.Run.QoS = ""
+		role.Run.CPUPinning = false
+		if role.IsLongRunning() {
+			role.Run.Instances = 1
+		}
+	}
+
+	if opts.Cluster != "" && role.Type != model.RoleTypeDocker {
+		image := kube.ContainerImageName(role, settings)
+		if _, err := runKubectl(opts.Kind, nil, "load", "docker-image", image, "--name", opts.Cluster); err != nil {
+			return err
+		}
+	}
+
+	objects, err := kube.GenerateRoleObjects(role, settings, kube.NewGenerators())
+	if err != nil {
+		return categorize(ErrorCategoryKube, err)
+	}
+	var config bytes.Buffer
+	for _, object := range objects {
+		if err := kube.WriteYamlConfig(object, &config); err != nil {
+			return categorize(ErrorCategoryKube, err)
+		}
+	}
+	_, err = kubectlRun(&config, "apply", "--filename", "-")
+	return err
+}
+
+// testDeployWait waits for the pods of a long running role to be ready, or for
+// a task role to finish; scheduled tasks run later on their own
+func (f *Fissile) testDeployWait(kubectlRun func(io.Reader, ...string) ([]byte, error), role *model.Role, timeout time.Duration) error {
+	switch {
+	case role.IsLongRunning():
+		workload := fmt.Sprintf("%s/%s", strings.ToLower(kube.WorkloadKind(role)), role.Name)
+		_, err := kubectlRun(nil, "rollout", "status", workload, "--timeout", timeout.String())
+		return err
+	case role.Run != nil && role.Run.Schedule != nil:
+		return nil
+	}
+	return f.waitForJob(kubectlRun, role.Name, timeout)
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestDeploy(t *testing.T) {
+	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/test-deploy.yml")
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	var calls, applied []string
+	smokeStatus := `{"status": {"succeeded": 1}}`
+
+	savedRunKubectl := runKubectl
+	defer func() { runKubectl = savedRunKubectl }()
+	runKubectl = func(kubectl string, stdin io.Reader, args ...string) ([]byte, error) {
+		call := strings.Join(append([]string{kubectl}, args...), " ")
+		if strings.Contains(call, " load docker-image ") {
+			call = "kind load docker-image"
+		}
+		calls = append(calls, call)
+		switch {
+		case call == "kind get clusters":
+			return []byte("other\n"), nil
+		case strings.Contains(call, " apply "):
+			data, err := ioutil.ReadAll(stdin)
+			applied = append(applied, string(data))
+			return nil, err
+		case strings.Contains(call, " get job smoke "):
+			return []byte(smokeStatus), nil
+		case strings.Contains(call, " get job "):
+			return []byte(`{"status": {"succeeded": 1}}`), nil
+		}
+		return nil, nil
+	}
+
+	opts := TestDeployOptions{
+		RolesManifestPath: roleManifestPath,
+		Roles:             []string{"smoke"},
+		Kubectl:           "kubectl",
+		Kind:              "kind",
+		Cluster:           "ci",
+		Namespace:         "test",
+		Timeout:           time.Minute,
+	}
+	err = f.TestDeploy(opts)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal([]string{
+		"kind get clusters",
+		"kind create cluster --name ci --wait 1m0s",
+		"kubectl --context kind-ci create namespace test",
+		"kind load docker-image",
+		"kubectl --context kind-ci --namespace test apply --filename -",
+		"kubectl --context kind-ci --namespace test rollout status deployment/myrole --timeout 1m0s",
+		"kind load docker-image",
+		"kubectl --context kind-ci --namespace test apply --filename -",
+		`kubectl --context kind-ci --namespace test patch job smoke --patch {"spec":{"parallelism":1}}`,
+		"kubectl --context kind-ci --namespace test get job smoke --output json",
+		"kubectl --context kind-ci delete namespace test",
+		"kind delete cluster --name ci",
+	}, calls)
+	if assert.Len(applied, 2) {
+		assert.Contains(applied[0], "replicas: 1")
+		assert.NotContains(applied[0], "memory:")
+		assert.NotContains(applied[0], "cpu:")
+		assert.Contains(applied[1], "kind: Job")
+		assert.Contains(applied[1], "parallelism: 0", "the smoke test is applied suspended, then run")
+	}
+
+	calls = nil
+	smokeStatus = `{"status": {"failed": 1}}`
+	opts.Cluster = ""
+	opts.Keep = true
+	err = f.TestDeploy(opts)
+	assert.EqualError(err, "Smoke test smoke failed: Job smoke failed, see the logs of its pods for details")
+	assert.Equal("kubectl --namespace test logs job/smoke", calls[len(calls)-1], "The logs of failed smoke tests are shown, and the namespace is kept")
+
+	opts.Roles = []string{"missing"}
+	err = f.TestDeploy(opts)
+	assert.EqualError(err, "Role missing not found in the roles manifest")
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+)
+
+// releaseIndexEntry is a single release version, as listed by the bosh.io API
+type releaseIndexEntry struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+// releaseUpdate is a newer version found for a loaded release
+type releaseUpdate struct {
+	Release   string
+	Current   string
+	Available string
+	Changelog string
+}
+
+// ShowReleaseUpdates checks the release indexes (bosh.io, or mirrors with the
+// same API) for newer versions of the loaded releases and prints them. Each
+// release is looked up by its source (e.g. github.com/cloudfoundry/cf-release),
+// taken from sources or from the git remote of the release directory. If pin
+// is set, the release versions the role manifest pins are updated to the
+// newest versions found, so that builds fail until the releases are upgraded.
+func (f *Fissile) ShowReleaseUpdates(indexURLs []string, sources map[string]string, rolesManifestPath string, pin bool) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+	if len(indexURLs) == 0 {
+		return fmt.Errorf("No release index given")
+	}
+
+	var updates []releaseUpdate
+
+	for _, release := range f.releases {
+		source, ok := sources[release.Name]
+		if !ok {
+			source = getReleaseGitSource(release.Path)
+		}
+		if source == "" {
+			f.UI.Printf("%s: %s\n", color.YellowString(release.Name), color.RedString("unknown source, skipped"))
+			continue
+		}
+
+		entries, err := fetchReleaseIndex(indexURLs, source)
+		if err != nil {
+			return err
+		}
+
+		newest := ""
+		for _, entry := range entries {
+			if compareReleaseVersions(entry.Version, release.Version) > 0 &&
+				(newest == "" || compareReleaseVersions(entry.Version, newest) > 0) {
+				newest = entry.Version
+			}
+		}
+
+		if newest == "" {
+			f.UI.Printf("%s (%s): %s\n", color.YellowString(release.Name), release.Version, color.GreenString("up to date"))
+			continue
+		}
+
+		update := releaseUpdate{
+			Release:   release.Name,
+			Current:   release.Version,
+			Available: newest,
+			Changelog: getReleaseChangelogURL(source, newest),
+		}
+		updates = append(updates, update)
+		f.UI.Printf("%s (%s): %s available, see %s\n",
+			color.YellowString(update.Release),
+			update.Current,
+			color.GreenString(update.Available),
+			color.CyanString(update.Changelog),
+		)
+	}
+
+	if !pin || len(updates) == 0 {
+		return nil
+	}
+
+	versions := make(map[string]string, len(updates))
+	for _, update := range updates {
+		versions[update.Release] = update.Available
+	}
+	pinned, err := model.UpdateReleaseVersions(rolesManifestPath, versions)
+	if err != nil {
+		return fmt.Errorf("Error updating the release versions of the role manifest: %s", err.Error())
+	}
+	if len(pinned) == 0 {
+		f.UI.Println(color.YellowString("The role manifest %s doesn't pin any of the releases with updates", rolesManifestPath))
+		return nil
+	}
+	f.UI.Println(color.GreenString("Pinned the new versions of %s in %s; builds will fail until the releases are upgraded",
+		color.YellowString(strings.Join(pinned, ", ")), color.YellowString(rolesManifestPath)))
+
+	return nil
+}
+
+// releaseIndexClient looks up releases in the indexes; a slow index fails the
+// lookup instead of hanging it
+var releaseIndexClient = &http.Client{Timeout: 30 * time.Second}
+
+// fetchReleaseIndex returns the versions of a release from the first index
+// that knows about it
+func fetchReleaseIndex(indexURLs []string, source string) ([]releaseIndexEntry, error) {
+	var errors []string
+
+	for _, indexURL := range indexURLs {
+		url := fmt.Sprintf("%s/%s", strings.TrimSuffix(indexURL, "/"), source)
+
+		response, err := releaseIndexClient.Get(url)
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			errors = append(errors, fmt.Sprintf("%s: %s", url, response.Status))
+			continue
+		}
+
+		var entries []releaseIndexEntry
+		err = json.NewDecoder(response.Body).Decode(&entries)
+		response.Body.Close()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", url, err.Error()))
+			continue
+		}
+
+		return entries, nil
+	}
+
+	return nil, fmt.Errorf("Error looking up release %s: %s", source, strings.Join(errors, "; "))
+}
+
+// gitRemotePattern extracts host and path from git remote URLs such as
+// https://github.com/org/repo.git or git@github.com:org/repo.git
+var gitRemotePattern = regexp.MustCompile(`^(?:[a-z+]+://)?(?:[^@/]+@)?([^:/]+)[:/](.+?)(?:\.git)?/?$`)
+
+// getReleaseGitSource returns the source of a release (host/org/repo) based
+// on the "origin" remote of its git repository, or an empty string
+func getReleaseGitSource(releasePath string) string {
+	file, err := os.Open(filepath.Join(releasePath, ".git", "config"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	inOrigin := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inOrigin = line == `[remote "origin"]`
+			continue
+		}
+		if !inOrigin || !strings.HasPrefix(line, "url") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		match := gitRemotePattern.FindStringSubmatch(strings.TrimSpace(parts[1]))
+		if match == nil {
+			return ""
+		}
+		return fmt.Sprintf("%s/%s", match[1], match[2])
+	}
+
+	return ""
+}
+
+// getReleaseChangelogURL returns a link to the release notes of a version
+func getReleaseChangelogURL(source, version string) string {
+	if strings.HasPrefix(source, "github.com/") {
+		return fmt.Sprintf("https://%s/releases/tag/v%s", source, version)
+	}
+	return fmt.Sprintf("https://bosh.io/releases/%s?version=%s", source, version)
+}
+
+// compareReleaseVersions compares two release versions numerically, segment
+// by segment. Dev versions (e.g. 12+dev.3) compare as their final version.
+func compareReleaseVersions(a, b string) int {
+	aParts := strings.Split(strings.SplitN(a, "+", 2)[0], ".")
+	bParts := strings.Split(strings.SplitN(b, "+", 2)[0], ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum != bNum {
+			if aNum < bNum {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareReleaseVersions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, compareReleaseVersions("1.2", "1.2"))
+	assert.Equal(0, compareReleaseVersions("1.2", "1.2.0"))
+	assert.Equal(-1, compareReleaseVersions("1.2", "1.10"))
+	assert.Equal(1, compareReleaseVersions("250", "249"))
+	assert.Equal(1, compareReleaseVersions("3", "2+dev.7"))
+	assert.Equal(0, compareReleaseVersions("2", "2+dev.7"))
+}
+
+func TestGetReleaseChangelogURL(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("https://github.com/cloudfoundry/cf-release/releases/tag/v250",
+		getReleaseChangelogURL("github.com/cloudfoundry/cf-release", "250"))
+	assert.Equal("https://bosh.io/releases/example.com/foo?version=1",
+		getReleaseChangelogURL("example.com/foo", "1"))
+}
+
+func TestGetReleaseGitSource(t *testing.T) {
+	assert := assert.New(t)
+
+	releasePath, err := ioutil.TempDir("", "fissile-updates")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(releasePath)
+
+	assert.Equal("", getReleaseGitSource(releasePath))
+
+	assert.NoError(os.MkdirAll(filepath.Join(releasePath, ".git"), 0755))
+	for _, url := range []string{
+		"https://github.com/cloudfoundry/cf-release.git",
+		"git@github.com:cloudfoundry/cf-release.git",
+		"ssh://git@github.com/cloudfoundry/cf-release",
+	} {
+		config := fmt.Sprintf("[core]\n\tbare = false\n[remote \"upstream\"]\n\turl = https://example.com/other\n[remote \"origin\"]\n\turl = %s\n", url)
+		assert.NoError(ioutil.WriteFile(filepath.Join(releasePath, ".git", "config"), []byte(config), 0644))
+		assert.Equal("github.com/cloudfoundry/cf-release", getReleaseGitSource(releasePath), url)
+	}
+}
+
+func TestShowReleaseUpdates(t *testing.T) {
+	assert := assert.New(t)
+
+	output := &bytes.Buffer{}
+	ui := termui.New(&bytes.Buffer{}, output, nil)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/ntp-release")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+
+	f := NewFissileApplication(".", ui)
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/github.com/cloudfoundry-community/ntp-release" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "ntp", "version": "100"}, {"name": "ntp", "version": "99"}]`)
+	}))
+	defer server.Close()
+
+	tempDir, err := ioutil.TempDir("", "fissile-updates")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+	manifestPath := filepath.Join(tempDir, "role-manifest.yml")
+	manifest := fmt.Sprintf("# Pins\nrelease-versions:\n  ntp: %s # current\nroles: []\n", f.releases[0].Version)
+	if !assert.NoError(ioutil.WriteFile(manifestPath, []byte(manifest), 0644)) {
+		return
+	}
+
+	sources := map[string]string{"ntp": "github.com/cloudfoundry-community/ntp-release"}
+	err = f.ShowReleaseUpdates([]string{server.URL + "/missing", server.URL}, sources, manifestPath, true)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Contains(output.String(), "100 available")
+	assert.Contains(output.String(), "https://github.com/cloudfoundry-community/ntp-release/releases/tag/v100")
+
+	contents, err := ioutil.ReadFile(manifestPath)
+	if assert.NoError(err) {
+		assert.Equal("# Pins\nrelease-versions:\n  ntp: \"100\" # current\nroles: []\n", string(contents))
+	}
+	_, err = model.LoadRoleManifest(manifestPath, f.releases)
+	assert.EqualError(err, fmt.Sprintf("Release ntp is pinned to version 100 by the role manifest, but version %s is loaded", f.releases[0].Version))
+}
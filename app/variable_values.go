@@ -0,0 +1,118 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/joho/godotenv"
+)
+
+// VariableValues are the values of configuration variables given when
+// generating configurations, overriding the defaults of the role manifest.
+// When a variable has several values, the env files given in DefaultEnvFiles
+// come first, then the ones in EnvFiles, then Set; the last value wins, and
+// the last file wins among the files of each list.
+type VariableValues struct {
+	DefaultEnvFiles []string // Env files with defaults, e.g. shared by all environments
+	EnvFiles        []string // Env files with the values of an environment
+	Set             string   // Comma separated NAME=VALUE pairs; commas in values are escaped as \,
+}
+
+// Read returns the values of the configuration variables, from their env
+// files and then from Set. The variables have to be in the role manifest.
+func (v VariableValues) Read(rolesManifest *model.RoleManifest) (map[string]string, error) {
+	values, err := v.readFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	sets, err := v.parseSet(rolesManifest)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range sets {
+		values[name] = value
+	}
+	return values, nil
+}
+
+// readFiles returns the values of the env files, as godotenv reads them
+func (v VariableValues) readFiles() (map[string]string, error) {
+	values := map[string]string{}
+	for _, files := range [][]string{v.DefaultEnvFiles, v.EnvFiles} {
+		if len(files) == 0 {
+			// godotenv reads .env without files
+			continue
+		}
+		fileValues, err := godotenv.Read(files...)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range fileValues {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// parseSet returns the values given by Set, checking that they are for
+// variables of the role manifest, to catch misspelled names
+func (v VariableValues) parseSet(rolesManifest *model.RoleManifest) (map[string]string, error) {
+	values := map[string]string{}
+	for _, pair := range splitEscaped(v.Set, ',') {
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid value %s, expected NAME=VALUE", pair)
+		}
+		name := strings.TrimSpace(parts[0])
+		if !hasVariable(rolesManifest, name) {
+			return nil, fmt.Errorf("Can't set %s, it is not a configuration variable of the role manifest", name)
+		}
+		values[name] = parts[1]
+	}
+	return values, nil
+}
+
+// hasVariable reports whether the role manifest has a configuration variable
+func hasVariable(rolesManifest *model.RoleManifest, name string) bool {
+	for _, variable := range rolesManifest.Configuration.Variables {
+		if variable.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// splitEscaped splits a string at a separator, except where the separator is
+// escaped by a backslash; the backslash is dropped
+func splitEscaped(value string, separator rune) []string {
+	var parts []string
+	var part []rune
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			if r != separator {
+				part = append(part, '\\')
+			}
+			part = append(part, r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == separator:
+			parts = append(parts, string(part))
+			part = nil
+		default:
+			part = append(part, r)
+		}
+	}
+	if escaped {
+		part = append(part, '\\')
+	}
+	return append(parts, string(part))
+}
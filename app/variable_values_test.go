@@ -0,0 +1,92 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariableValuesRead(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "fissile-values-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	defaultsPath := filepath.Join(dir, "defaults.env")
+	envPath := filepath.Join(dir, "prod.env")
+	assert.NoError(ioutil.WriteFile(defaultsPath, []byte("DOMAIN=example.com\nLOG_LEVEL=info\nREPLICAS=1\n"), 0644))
+	assert.NoError(ioutil.WriteFile(envPath, []byte("LOG_LEVEL=warn\nREPLICAS=3\n"), 0644))
+
+	rolesManifest := &model.RoleManifest{
+		Configuration: &model.Configuration{
+			Variables: model.ConfigurationVariableSlice{
+				{Name: "DOMAIN"},
+				{Name: "LOG_LEVEL"},
+				{Name: "REPLICAS"},
+				{Name: "ALLOWED"},
+			},
+		},
+	}
+
+	values, err := VariableValues{}.Read(rolesManifest)
+	if assert.NoError(err) {
+		assert.Empty(values, "Without values, there is nothing to read")
+	}
+
+	values, err = VariableValues{
+		DefaultEnvFiles: []string{defaultsPath},
+		EnvFiles:        []string{envPath},
+		Set:             `REPLICAS=5,ALLOWED=a\,b=c`,
+	}.Read(rolesManifest)
+	if assert.NoError(err) {
+		assert.Equal(map[string]string{
+			"DOMAIN":    "example.com",
+			"LOG_LEVEL": "warn",
+			"REPLICAS":  "5",
+			"ALLOWED":   "a,b=c",
+		}, values)
+	}
+
+	samples := []struct {
+		desc   string
+		values VariableValues
+		err    string
+	}{
+		{
+			desc:   "Values are pairs",
+			values: VariableValues{Set: "DOMAIN=example.com,LOG_LEVEL"},
+			err:    "Invalid value LOG_LEVEL, expected NAME=VALUE",
+		},
+		{
+			desc:   "Values are for variables of the role manifest",
+			values: VariableValues{Set: "DOMIAN=example.com"},
+			err:    "Can't set DOMIAN, it is not a configuration variable of the role manifest",
+		},
+		{
+			desc:   "Env files exist",
+			values: VariableValues{EnvFiles: []string{filepath.Join(dir, "missing.env")}},
+			err:    "open " + filepath.Join(dir, "missing.env") + ": no such file or directory",
+		},
+	}
+
+	for _, sample := range samples {
+		_, err := sample.values.Read(rolesManifest)
+		assert.EqualError(err, sample.err, sample.desc)
+	}
+}
+
+func TestSplitEscaped(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{""}, splitEscaped("", ','))
+	assert.Equal([]string{"a", "b"}, splitEscaped("a,b", ','))
+	assert.Equal([]string{"a,b", `c\d`, `e\`}, splitEscaped(`a\,b,c\d,e\`, ','))
+}
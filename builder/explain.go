@@ -0,0 +1,116 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+)
+
+// explainDirName is the directory below the target path the inputs of the
+// last build of each role image are recorded in, for --explain
+const explainDirName = ".explain"
+
+// SetExplain sets whether the builder prints why each role image is built or
+// skipped
+func (r *RoleImageBuilder) SetExplain(enabled bool) {
+	r.explain = enabled
+}
+
+// roleInputsPath returns the path the inputs of the last build of a role
+// image are recorded at
+func (r *RoleImageBuilder) roleInputsPath(role *model.Role) string {
+	return filepath.Join(r.targetPath, explainDirName, fmt.Sprintf("%s.json", role.Name))
+}
+
+// recordRoleInputs records the inputs of the image of a role, once it is
+// built or found, so later builds can tell which of them changed. Recording
+// is best effort; builds don't fail over it.
+func (r *RoleImageBuilder) recordRoleInputs(role *model.Role) {
+	contents, err := json.Marshal(role.GetRoleDevVersionInputs())
+	if err != nil {
+		return
+	}
+	path := r.roleInputsPath(role)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	ioutil.WriteFile(path, contents, 0644)
+}
+
+// explainRole prints why the image of a role is built or skipped: whether
+// docker has the image, and otherwise which inputs changed since the image
+// was last built
+func (r *RoleImageBuilder) explainRole(role *model.Role, imageName string, hasImage, force bool) {
+	if !r.explain {
+		return
+	}
+	name := color.YellowString(role.Name)
+
+	switch {
+	case hasImage:
+		r.ui.Printf("explain: role %s skipped, docker has its image %s\n", name, imageName)
+		return
+	case force:
+		r.ui.Printf("explain: role %s built, as --force was given\n", name)
+		return
+	}
+
+	reason := "there is no record of an earlier build"
+	if contents, err := ioutil.ReadFile(r.roleInputsPath(role)); err == nil {
+		var previous []model.RoleVersionInput
+		if err := json.Unmarshal(contents, &previous); err == nil {
+			current := role.GetRoleDevVersionInputs()
+			changes := diffRoleVersionInputs(previous, current)
+			switch {
+			case len(changes) > 0:
+				reason = fmt.Sprintf("changed since the last build: %s", strings.Join(changes, ", "))
+			case !reflect.DeepEqual(previous, current):
+				reason = "the order of its jobs changed since the last build"
+			default:
+				reason = "its inputs are the same as in the last build, so the image was removed"
+			}
+		}
+	}
+	r.ui.Printf("explain: role %s built, docker has no image %s; %s\n", name, imageName, reason)
+}
+
+// diffRoleVersionInputs describes the inputs of a role image that changed,
+// were added or were removed, in the order of the current inputs and then of
+// the previous ones
+func diffRoleVersionInputs(previous, current []model.RoleVersionInput) []string {
+	previousValues := map[string]string{}
+	for _, input := range previous {
+		previousValues[input.Name] = input.Value
+	}
+	currentNames := map[string]bool{}
+
+	var changes []string
+	for _, input := range current {
+		if currentNames[input.Name] {
+			continue
+		}
+		currentNames[input.Name] = true
+		value, ok := previousValues[input.Name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s added", input.Name))
+		case value != input.Value:
+			changes = append(changes, fmt.Sprintf("%s changed", input.Name))
+		}
+	}
+	for _, input := range previous {
+		if !currentNames[input.Name] {
+			currentNames[input.Name] = true
+			changes = append(changes, fmt.Sprintf("%s removed", input.Name))
+		}
+	}
+	return changes
+}
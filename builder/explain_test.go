@@ -0,0 +1,93 @@
+package builder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRoleVersionInputs(t *testing.T) {
+	assert := assert.New(t)
+
+	previous := []model.RoleVersionInput{
+		{Name: "job tor", Value: "1"},
+		{Name: "package tor", Value: "2"},
+		{Name: "os-packages", Value: "curl"},
+	}
+
+	samples := []struct {
+		desc     string
+		current  []model.RoleVersionInput
+		expected []string
+	}{
+		{
+			desc:    "the same inputs",
+			current: previous,
+		},
+		{
+			desc: "changed, added and removed inputs",
+			current: []model.RoleVersionInput{
+				{Name: "job tor", Value: "1"},
+				{Name: "package tor", Value: "3"},
+				{Name: "image", Value: "alpine"},
+			},
+			expected: []string{"package tor changed", "image added", "os-packages removed"},
+		},
+		{
+			desc: "reordered inputs",
+			current: []model.RoleVersionInput{
+				{Name: "package tor", Value: "2"},
+				{Name: "os-packages", Value: "curl"},
+				{Name: "job tor", Value: "1"},
+			},
+		},
+	}
+
+	for _, sample := range samples {
+		assert.Equal(sample.expected, diffRoleVersionInputs(previous, sample.current), sample.desc)
+	}
+}
+
+func TestExplainRole(t *testing.T) {
+	assert := assert.New(t)
+
+	targetPath, err := ioutil.TempDir("", "fissile-test")
+	assert.NoError(err)
+	defer os.RemoveAll(targetPath)
+
+	var output bytes.Buffer
+	builder := &RoleImageBuilder{
+		targetPath: targetPath,
+		ui:         termui.New(&bytes.Buffer{}, &output, nil),
+		explain:    true,
+	}
+	role := &model.Role{Name: "myrole", Image: "alpine:3.6"}
+
+	builder.explainRole(role, "fissile-myrole:1", false, false)
+	assert.Contains(output.String(), "there is no record of an earlier build")
+
+	builder.recordRoleInputs(role)
+	output.Reset()
+	builder.explainRole(role, "fissile-myrole:1", false, false)
+	assert.Contains(output.String(), "its inputs are the same as in the last build")
+
+	role.Image = "alpine:3.7"
+	output.Reset()
+	builder.explainRole(role, "fissile-myrole:2", false, false)
+	assert.Contains(output.String(), "changed since the last build: image changed")
+
+	output.Reset()
+	builder.explainRole(role, "fissile-myrole:2", true, false)
+	assert.Contains(output.String(), "skipped, docker has its image fissile-myrole:2")
+
+	builder.explain = false
+	output.Reset()
+	builder.explainRole(role, "fissile-myrole:2", false, false)
+	assert.Empty(output.String())
+}
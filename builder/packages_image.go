@@ -135,6 +135,27 @@ func (p *PackagesImageBuilder) determinePackagesLayerBaseImage(packages model.Pa
 	return matchedImage, packages, nil
 }
 
+// collectLayerPackages returns the compiled packages the roles use, once per
+// fingerprint; packages several releases ship are added as the package
+// compiled for all of them, so they share a single layer
+func collectLayerPackages(roles model.Roles) model.Packages {
+	foundFingerprints := make(map[string]struct{})
+	var packages model.Packages
+	for _, role := range roles {
+		for _, job := range role.Jobs {
+			for _, pkg := range job.Packages {
+				if _, ok := foundFingerprints[pkg.Fingerprint]; ok {
+					// Package has already been found (possibly due to a different role)
+					continue
+				}
+				packages = append(packages, pkg.Canonical())
+				foundFingerprints[pkg.Fingerprint] = struct{}{}
+			}
+		}
+	}
+	return packages
+}
+
 // NewDockerPopulator returns a function which can populate a tar stream with the docker context to build the packages layer image with
 func (p *PackagesImageBuilder) NewDockerPopulator(roleManifest *model.RoleManifest, forceBuildAll bool) func(*tar.Writer) error {
 	return func(tarWriter *tar.Writer) error {
@@ -144,20 +165,7 @@ func (p *PackagesImageBuilder) NewDockerPopulator(roleManifest *model.RoleManife
 		}
 
 		// Collect compiled packages
-		foundFingerprints := make(map[string]struct{})
-		var packages model.Packages
-		for _, role := range roleManifest.Roles {
-			for _, job := range role.Jobs {
-				for _, pkg := range job.Packages {
-					if _, ok := foundFingerprints[pkg.Fingerprint]; ok {
-						// Package has already been found (possibly due to a different role)
-						continue
-					}
-					packages = append(packages, pkg)
-					foundFingerprints[pkg.Fingerprint] = struct{}{}
-				}
-			}
-		}
+		packages := collectLayerPackages(roleManifest.Roles)
 
 		// Generate dockerfile
 		dockerfile := bytes.Buffer{}
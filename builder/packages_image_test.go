@@ -165,3 +165,38 @@ func TestNewDockerPopulator(t *testing.T) {
 	}
 	assert.Empty(testFunctions, "Missing files in tar stream")
 }
+
+func TestCollectLayerPackagesShared(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	// Two releases shipping the same packages; the roles only use the jobs of
+	// the second one
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+	shared, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	if !assert.NoError(err) {
+		return
+	}
+	shared.Name = "shared"
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml")
+	rolesManifest, err := model.LoadRoleManifest(roleManifestPath, []*model.Release{shared, release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	packages := collectLayerPackages(rolesManifest.Roles)
+	if assert.Len(packages, 2) {
+		for _, pkg := range packages {
+			assert.Equal("shared", pkg.Release.Name, "the layer has the packages compiled for the shared ones")
+		}
+	}
+	assert.Equal("tor", getPackage(rolesManifest.Roles, "myrole", "tor", "tor").Release.Name)
+}
@@ -27,6 +27,14 @@ import (
 const (
 	binPrefix             = "bin"
 	jobConfigSpecFilename = "config_spec.json"
+
+	// DevMountsSyncedMarker is the file that tells the containers of images
+	// built with dev mounts that their jobs and scripts are in place
+	DevMountsSyncedMarker = "/var/vcap/jobs-src/.fissile-dev-synced"
+
+	// caCertificatesDir is where the CA certificates of the role manifest are
+	// put in the image, before they are added to its trust store
+	caCertificatesDir = "opt/hcf/ca-certificates"
 )
 
 var (
@@ -50,6 +58,7 @@ type RoleImageBuilder struct {
 	fissileVersion       string
 	lightOpinionsPath    string
 	darkOpinionsPath     string
+	explain              bool
 	ui                   *termui.UI
 }
 
@@ -100,7 +109,7 @@ func (r *RoleImageBuilder) CreateDockerfileDir(role *model.Role, baseImageName s
 		docDir := filepath.Join(rootDir, "opt/hcf/share/doc")
 
 		if _, ok := releaseLicensesWritten[job.Release.Name]; !ok {
-			if len(job.Release.License.Files) == 0 {
+			if len(job.Release.License.Files) == 0 && len(job.Release.License.Hashes) == 0 {
 				continue
 			}
 
@@ -115,9 +124,31 @@ func (r *RoleImageBuilder) CreateDockerfileDir(role *model.Role, baseImageName s
 					return "", fmt.Errorf("failed to write out release license file %s: %v", filename, err)
 				}
 			}
+
+			// Files too large or binary to load are recorded by their hash
+			for filename, hash := range job.Release.License.Hashes {
+				contents := fmt.Sprintf("%s was not included; its SHA1 is %s\n", filename, hash)
+				err := ioutil.WriteFile(filepath.Join(releaseDir, filename+".sha1"), []byte(contents), 0644)
+				if err != nil {
+					return "", fmt.Errorf("failed to write out release license hash %s: %v", filename, err)
+				}
+			}
 		}
 	}
 
+	// Record the source archives the packages were compiled from
+	provenanceContents, err := json.MarshalIndent(role.GetPackageProvenance(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	provenancePath := filepath.Join(rootDir, model.PackageProvenancePath)
+	if err := os.MkdirAll(filepath.Dir(provenancePath), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(provenancePath, provenanceContents, 0644); err != nil {
+		return "", fmt.Errorf("failed to write out package provenance: %v", err)
+	}
+
 	// Symlink compiled packages
 	packagesDir := filepath.Join(rootDir, "var/vcap/packages")
 	if err := os.MkdirAll(packagesDir, 0755); err != nil {
@@ -143,53 +174,34 @@ func (r *RoleImageBuilder) CreateDockerfileDir(role *model.Role, baseImageName s
 		}
 	}
 
-	// Copy jobs templates, spec configs and monit
-	jobsDir := filepath.Join(rootDir, "var/vcap/jobs-src")
-	if err := os.MkdirAll(jobsDir, 0755); err != nil {
-		return "", err
-	}
-	for _, job := range role.Jobs {
-		jobDir, err := job.Extract(jobsDir)
-		if err != nil {
+	if !role.GetImageOptions().DevMounts {
+		if err := r.writeJobs(role, rootDir); err != nil {
 			return "", err
 		}
-
-		jobManifestFile := filepath.Join(jobDir, "job.MF")
-		if err := os.Remove(jobManifestFile); err != nil {
+		if err := r.writeStartupScripts(role, rootDir); err != nil {
 			return "", err
 		}
+	}
 
-		for _, template := range job.Templates {
-			templatePath := filepath.Join(jobDir, "templates", template.SourcePath)
-			if strings.HasPrefix(template.DestinationPath, fmt.Sprintf("%s%c", binPrefix, os.PathSeparator)) {
-				os.Chmod(templatePath, 0755)
-			} else {
-				os.Chmod(templatePath, 0644)
-			}
-		}
-
-		// Write spec into <ROOT_DIR>/var/vcap/job-src/<JOB>/config_spec.json
-		specConfigDestination := filepath.Join(jobDir, jobConfigSpecFilename)
-		err = job.WriteConfigs(role, specConfigDestination, r.lightOpinionsPath, r.darkOpinionsPath)
-		if err != nil {
+	// Write out the CA certificates for the trust store, one per file
+	if certificates := role.GetCACertificates(); len(certificates) > 0 {
+		certificatesDir := filepath.Join(rootDir, caCertificatesDir)
+		if err := os.MkdirAll(certificatesDir, 0755); err != nil {
 			return "", err
 		}
+		for i, certificate := range certificates {
+			certificatePath := filepath.Join(certificatesDir, fmt.Sprintf("fissile-ca-%d.crt", i+1))
+			if err := ioutil.WriteFile(certificatePath, certificate, 0644); err != nil {
+				return "", fmt.Errorf("failed to write out CA certificate: %v", err)
+			}
+		}
 	}
 
-	// Copy role startup scripts
-	startupDir := filepath.Join(rootDir, "opt/hcf/startup")
-	if err := os.MkdirAll(startupDir, 0755); err != nil {
-		return "", err
-	}
-	for script, sourceScriptPath := range role.GetScriptPaths() {
-		destScriptPath := filepath.Join(startupDir, script)
-		destDir := filepath.Dir(destScriptPath)
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return "", err
-
-		}
-		if err := shutil.CopyFile(sourceScriptPath, destScriptPath, true); err != nil {
-			return "", err
+	// Copy the files the Dockerfile snippet adds
+	if role.GetDockerfileSnippet() != "" {
+		contextDir := filepath.Join(roleDir, model.RoleDockerfileContext)
+		if err := copyDockerfileContext(filepath.Dir(role.GetDockerfilePath()), role.GetDockerfileFiles(), contextDir); err != nil {
+			return "", fmt.Errorf("failed to copy the dockerfile directory of role %s: %v", role.Name, err)
 		}
 	}
 
@@ -203,6 +215,28 @@ func (r *RoleImageBuilder) CreateDockerfileDir(role *model.Role, baseImageName s
 		return "", err
 	}
 
+	// Add the health shim run.sh starts, if the role uses it
+	if role.HealthShimPort() != 0 {
+		healthShimContents, err := dockerfiles.Asset("health-shim.rb")
+		if err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(filepath.Join(rootDir, "opt/hcf/health-shim.rb"), healthShimContents, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	// Add the script stopping the jobs in order, if they depend on each other
+	if role.HasJobDependencies() {
+		preStopScriptContents, err := r.generatePreStopScript(role)
+		if err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(filepath.Join(rootDir, model.PreStopScriptPath), preStopScriptContents, 0744); err != nil {
+			return "", err
+		}
+	}
+
 	jobsConfigFile, err := os.Create(filepath.Join(rootDir, "opt/hcf/job_config.json"))
 	if err != nil {
 		return "", err
@@ -247,6 +281,77 @@ func (r *RoleImageBuilder) CreateDockerfileDir(role *model.Role, baseImageName s
 	return roleDir, nil
 }
 
+// WriteDevMounts writes the jobs and role scripts that images built with dev
+// mounts leave out into rootDir, along with the marker telling the containers
+// they are in place. Copy the contents of rootDir into the root of the
+// containers.
+func (r *RoleImageBuilder) WriteDevMounts(role *model.Role, rootDir string) error {
+	if err := r.writeJobs(role, rootDir); err != nil {
+		return err
+	}
+	if err := r.writeStartupScripts(role, rootDir); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(rootDir, DevMountsSyncedMarker), nil, 0644)
+}
+
+// writeJobs copies the job templates, spec configs and monit files of a role
+// below rootDir
+func (r *RoleImageBuilder) writeJobs(role *model.Role, rootDir string) error {
+	jobsDir := filepath.Join(rootDir, "var/vcap/jobs-src")
+	if err := os.MkdirAll(jobsDir, 0755); err != nil {
+		return err
+	}
+	for _, job := range role.Jobs {
+		jobDir, err := job.Extract(jobsDir)
+		if err != nil {
+			return err
+		}
+
+		jobManifestFile := filepath.Join(jobDir, "job.MF")
+		if err := os.Remove(jobManifestFile); err != nil {
+			return err
+		}
+
+		for _, template := range job.Templates {
+			templatePath := filepath.Join(jobDir, "templates", template.SourcePath)
+			if strings.HasPrefix(template.DestinationPath, fmt.Sprintf("%s%c", binPrefix, os.PathSeparator)) {
+				os.Chmod(templatePath, 0755)
+			} else {
+				os.Chmod(templatePath, 0644)
+			}
+		}
+
+		// Write spec into <ROOT_DIR>/var/vcap/job-src/<JOB>/config_spec.json
+		specConfigDestination := filepath.Join(jobDir, jobConfigSpecFilename)
+		err = job.WriteConfigs(role, specConfigDestination, r.lightOpinionsPath, r.darkOpinionsPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStartupScripts copies the startup scripts of a role below rootDir
+func (r *RoleImageBuilder) writeStartupScripts(role *model.Role, rootDir string) error {
+	startupDir := filepath.Join(rootDir, "opt/hcf/startup")
+	if err := os.MkdirAll(startupDir, 0755); err != nil {
+		return err
+	}
+	for script, sourceScriptPath := range role.GetScriptPaths() {
+		destScriptPath := filepath.Join(startupDir, script)
+		destDir := filepath.Dir(destScriptPath)
+		if err := os.MkdirAll(destDir, 0755); err != nil {
+			return err
+
+		}
+		if err := shutil.CopyFile(sourceScriptPath, destScriptPath, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func isPreStart(s string) bool {
 	return strings.HasSuffix(s, "/bin/pre-start")
 }
@@ -262,8 +367,29 @@ func (r *RoleImageBuilder) generateRunScript(role *model.Role) ([]byte, error) {
 		"is_abs":       filepath.IsAbs,
 		"is_pre_start": isPreStart,
 	})
+	startJobs, err := role.JobStartupOrder()
+	if err != nil {
+		return nil, err
+	}
+
 	context := map[string]interface{}{
-		"role": role,
+		"role":               role,
+		"start_jobs":         startJobs,
+		"bbr_artifacts_path": model.BBRArtifactsPath,
+		"dev_mounts":         role.GetImageOptions().DevMounts,
+		"dev_synced_marker":  DevMountsSyncedMarker,
+		"health_shim_port":   role.HealthShimPort(),
+		"health_shim_path":   model.HealthShimPath,
+		"pre_stop_script":    "",
+		"stop_timeout":       0,
+		"stop_signals":       []*model.RoleRunStopSignal{},
+	}
+	if role.Run != nil {
+		context["stop_timeout"] = role.Run.StopTimeout
+		context["stop_signals"] = role.Run.StopSignals
+	}
+	if role.HasJobDependencies() {
+		context["pre_stop_script"] = model.PreStopScriptPath
 	}
 	runScriptTemplate, err = runScriptTemplate.Parse(string(asset))
 	if err != nil {
@@ -279,6 +405,32 @@ func (r *RoleImageBuilder) generateRunScript(role *model.Role) ([]byte, error) {
 	return output.Bytes(), nil
 }
 
+// generatePreStopScript generates the script stopping the jobs of a role in
+// their shutdown order
+func (r *RoleImageBuilder) generatePreStopScript(role *model.Role) ([]byte, error) {
+	asset, err := dockerfiles.Asset("pre-stop.sh")
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := role.JobShutdownOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	preStopScriptTemplate, err := template.New("role-prestopscript").Parse(string(asset))
+	if err != nil {
+		return nil, err
+	}
+
+	var output bytes.Buffer
+	if err := preStopScriptTemplate.Execute(&output, map[string]interface{}{"jobs": jobs}); err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
+
 func (r *RoleImageBuilder) generateJobsConfig(role *model.Role) ([]byte, error) {
 	jobsConfig := make(map[string]map[string]interface{})
 
@@ -288,7 +440,7 @@ func (r *RoleImageBuilder) generateJobsConfig(role *model.Role) ([]byte, error)
 
 		files := make(map[string]string)
 
-		for _, file := range job.Templates {
+		for _, file := range role.GetJobTemplates(job) {
 			src := fmt.Sprintf("/var/vcap/jobs-src/%s/templates/%s",
 				job.Name, file.SourcePath)
 			dest := fmt.Sprintf("/var/vcap/jobs/%s/%s",
@@ -297,9 +449,11 @@ func (r *RoleImageBuilder) generateJobsConfig(role *model.Role) ([]byte, error)
 		}
 
 		if role.Type != "bosh-task" {
-			src := fmt.Sprintf("/var/vcap/jobs-src/%s/monit", job.Name)
-			dest := fmt.Sprintf("/var/vcap/monit/%s.monitrc", job.Name)
-			files[src] = dest
+			if role.HasJobMonit(job) {
+				src := fmt.Sprintf("/var/vcap/jobs-src/%s/monit", job.Name)
+				dest := fmt.Sprintf("/var/vcap/monit/%s.monitrc", job.Name)
+				files[src] = dest
+			}
 
 			if index == 0 {
 				files["/opt/hcf/monitrc.erb"] = "/etc/monitrc"
@@ -331,7 +485,20 @@ func (r *RoleImageBuilder) generateDockerfile(role *model.Role, baseImageName st
 		"image_version": r.version,
 		"role":          role,
 		"licenses":      role.Jobs[0].Release.License.Files,
+		"trace_startup": role.GetImageOptions().TraceStartup,
+		"dev_mounts":    role.GetImageOptions().DevMounts,
 	}
+	if len(role.GetCACertificates()) > 0 {
+		context["ca_certificates"] = "/" + caCertificatesDir
+	}
+	if snippet := role.GetDockerfileSnippet(); snippet != "" {
+		context["dockerfile_snippet"] = snippet
+	}
+	entrypoint, err := json.Marshal(role.GetEntrypoint())
+	if err != nil {
+		return err
+	}
+	context["entrypoint"] = string(entrypoint)
 
 	dockerfileTemplate, err = dockerfileTemplate.Parse(string(asset))
 	if err != nil {
@@ -345,6 +512,44 @@ func (r *RoleImageBuilder) generateDockerfile(role *model.Role, baseImageName st
 	return nil
 }
 
+// copyDockerfileContext copies the files and directories a Dockerfile snippet
+// adds, relative to the directory of the snippet, into the build context
+func copyDockerfileContext(sourceDir string, paths []string, targetDir string) error {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	for _, sourcePath := range paths {
+		err := filepath.Walk(filepath.Join(sourceDir, sourcePath), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			relativePath, err := filepath.Rel(sourceDir, path)
+			if err != nil {
+				return err
+			}
+			targetPath := filepath.Join(targetDir, relativePath)
+			if info.IsDir() {
+				return os.MkdirAll(targetPath, 0755)
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+				return err
+			}
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(targetPath, contents, info.Mode().Perm())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type roleBuildJob struct {
 	role          *model.Role
 	builder       *RoleImageBuilder
@@ -366,16 +571,31 @@ func (j roleBuildJob) Run() {
 	default:
 	}
 
-	j.resultsCh <- func() error {
+	j.resultsCh <- func() (err error) {
+		if j.role.Type == model.RoleTypeDocker {
+			j.ui.Printf("Skipping build of role image %s because it runs the pre-built image %s\n", color.YellowString(j.role.Name), j.role.Image)
+			return nil
+		}
+
 		roleImageName := GetRoleDevImageName(j.repository, j.role, j.role.GetRoleDevVersion())
+		span := util.Tracing.StartSpan("build-role-image")
+		span.SetAttribute("role", j.role.Name)
+		span.SetAttribute("image", roleImageName)
+		span.SetAttribute("cache-hit", false)
+		defer func() { span.End(err) }()
+
 		if !j.force {
 			if hasImage, err := j.dockerManager.HasImage(roleImageName); err != nil {
 				return err
 			} else if hasImage {
 				j.ui.Printf("Skipping build of role image %s because it exists\n", color.YellowString(j.role.Name))
+				j.builder.explainRole(j.role, roleImageName, true, false)
+				j.builder.recordRoleInputs(j.role)
+				span.SetAttribute("cache-hit", true)
 				return nil
 			}
 		}
+		j.builder.explainRole(j.role, roleImageName, false, j.force)
 
 		if j.builder.metricsPath != "" {
 			seriesName := fmt.Sprintf("create-role-images::%s", roleImageName)
@@ -412,6 +632,7 @@ func (j roleBuildJob) Run() {
 			log.WriteTo(j.ui)
 			return fmt.Errorf("Error building image: %s", err.Error())
 		}
+		j.builder.recordRoleInputs(j.role)
 		return nil
 	}()
 }
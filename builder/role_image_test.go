@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -72,6 +73,44 @@ func TestGenerateRoleImageDockerfile(t *testing.T) {
 	assert.NoError(err)
 	dockerfileString = dockerfileContents.String()
 	assert.Contains(dockerfileString, "MAINTAINER", "dev mode should generate a maintainer layer")
+	assert.NotContains(dockerfileString, "ENV TZ=")
+
+	role := rolesManifest.Roles[0]
+	role.Run = &model.RoleRun{
+		TimeZone: "Europe/Bucharest",
+		Locale:   "en_US.UTF-8",
+	}
+	dockerfileContents.Reset()
+	err = roleImageBuilder.generateDockerfile(role, baseImage, &dockerfileContents)
+	assert.NoError(err)
+	dockerfileString = dockerfileContents.String()
+	assert.Contains(dockerfileString, `ENV TZ="Europe/Bucharest"`)
+	assert.Contains(dockerfileString, `ENV LANG="en_US.UTF-8" LC_ALL="en_US.UTF-8"`)
+	assert.NotContains(dockerfileString, "FISSILE_TRACE")
+
+	rolesManifest.SetImageOptions(model.RoleImageOptions{TraceStartup: true})
+	dockerfileContents.Reset()
+	err = roleImageBuilder.generateDockerfile(role, baseImage, &dockerfileContents)
+	assert.NoError(err)
+	assert.Contains(dockerfileContents.String(), `ENV FISSILE_TRACE="true"`)
+	assert.NotContains(dockerfileContents.String(), "apt-get")
+
+	role.OSPackages = &model.RoleOSPackages{
+		Manager:  model.OSPackageManagerApt,
+		Packages: []string{"libfuse2", "fuse"},
+	}
+	dockerfileContents.Reset()
+	err = roleImageBuilder.generateDockerfile(role, baseImage, &dockerfileContents)
+	assert.NoError(err)
+	dockerfileString = dockerfileContents.String()
+	assert.Contains(dockerfileString, `LABEL "os-packages"="apt:fuse,libfuse2"`)
+	assert.Contains(dockerfileString, "apt-get install -y --no-install-recommends fuse libfuse2")
+
+	role.Run.Init = model.RoleRunInitNone
+	dockerfileContents.Reset()
+	err = roleImageBuilder.generateDockerfile(role, baseImage, &dockerfileContents)
+	assert.NoError(err)
+	assert.Contains(dockerfileContents.String(), `ENTRYPOINT ["/bin/bash","/opt/hcf/run.sh"]`)
 }
 
 func TestGenerateRoleImageRunScript(t *testing.T) {
@@ -120,12 +159,64 @@ func TestGenerateRoleImageRunScript(t *testing.T) {
 	assert.Contains(string(runScriptContents), "bash /var/vcap/jobs/myrole/pre-start")
 	assert.NotContains(string(runScriptContents), "/opt/hcf/startup/var/vcap/jobs/myrole/pre-start")
 	assert.NotContains(string(runScriptContents), "/opt/hcf//startup/var/vcap/jobs/myrole/pre-start")
+	assert.Contains(string(runScriptContents), "touch /var/vcap/monit/rendered")
 	assert.Contains(string(runScriptContents), "monit -vI &")
+	assert.Contains(string(runScriptContents), `timeline "monit started"`)
+	assert.NotContains(string(runScriptContents), "running-job-pids")
+
+	role := *rolesManifest.Roles[0]
+	role.Run = &model.RoleRun{
+		StopTimeout: 30,
+		StopSignals: []*model.RoleRunStopSignal{{Signal: "INT", Wait: 60}, {Signal: "KILL"}},
+	}
+	runScriptContents, err = roleImageBuilder.generateRunScript(&role)
+	assert.NoError(err)
+	assert.Contains(string(runScriptContents), `[ ${waited} -lt 30 ]`)
+	assert.Contains(string(runScriptContents), "kill -s INT ${pids}")
+	assert.Contains(string(runScriptContents), `[ ${waited} -lt 60 ]`)
+	assert.Contains(string(runScriptContents), "kill -s KILL ${pids}")
+	testRunningJobPids(assert, string(runScriptContents))
 
 	runScriptContents, err = roleImageBuilder.generateRunScript(rolesManifest.Roles[1])
 	assert.NoError(err)
 	assert.NotContains(string(runScriptContents), "monit -vI")
 	assert.Contains(string(runScriptContents), "/var/vcap/jobs/tor/bin/run")
+	assert.Contains(string(runScriptContents), `timeline "task tor done"`)
+}
+
+// testRunningJobPids runs the running-job-pids function of a run script
+// against monit files like the ones of BOSH jobs, whose pidfile is on the line
+// after the check
+func testRunningJobPids(assert *assert.Assertions, runScript string) {
+	function := regexp.MustCompile(`(?s)  running-job-pids\(\) \{.*?\n  \}\n`).FindString(runScript)
+	if !assert.NotEmpty(function, "running-job-pids is defined") {
+		return
+	}
+
+	monitDir, err := ioutil.TempDir("", "fissile-monit")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(monitDir)
+
+	// The test itself is the running process
+	pidfile := filepath.Join(monitDir, "tor.pid")
+	files := map[string]string{
+		"tor.monitrc":   fmt.Sprintf("check process tor\n  with pidfile %s\n  group vcap\n", pidfile),
+		"other.monitrc": fmt.Sprintf("check file config with path %s\ncheck process down with pidfile %s\n", pidfile, filepath.Join(monitDir, "down.pid")),
+		"tor.pid":       fmt.Sprintf("%d\n", os.Getpid()),
+	}
+	for name, contents := range files {
+		if !assert.NoError(ioutil.WriteFile(filepath.Join(monitDir, name), []byte(contents), 0644)) {
+			return
+		}
+	}
+
+	function = strings.Replace(function, "/var/vcap/monit", monitDir, -1)
+	output, err := exec.Command("bash", "-c", function+"running-job-pids\n").CombinedOutput()
+	if assert.NoError(err, string(output)) {
+		assert.Equal(fmt.Sprintf("%d", os.Getpid()), strings.TrimSpace(string(output)))
+	}
 }
 
 func TestGenerateRoleImageJobsConfig(t *testing.T) {
@@ -175,6 +266,48 @@ func TestGenerateRoleImageJobsConfig(t *testing.T) {
 	assert.NotContains(string(jobsConfigContents), "/var/vcap/jobs/new_hostname/bin/run")
 }
 
+func TestGenerateRoleImageJobsConfigExcludeTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(
+		&bytes.Buffer{},
+		ioutil.Discard,
+		nil,
+	)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+	compiledPackagesDir := filepath.Join(workDir, "../test-assets/tor-boshrelease-fake-compiled")
+	targetPath, err := ioutil.TempDir("", "fissile-test")
+	assert.NoError(err)
+	defer os.RemoveAll(targetPath)
+
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	assert.NoError(err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/exclude-templates.yml")
+	rolesManifest, err := model.LoadRoleManifest(roleManifestPath, []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, "", "", "", "3.14.15", "6.28.30", ui)
+	assert.NoError(err)
+
+	jobsConfigContents, err := roleImageBuilder.generateJobsConfig(rolesManifest.Roles[0])
+	assert.NoError(err)
+	assert.Contains(string(jobsConfigContents), "/var/vcap/jobs/tor/bin/tor_ctl")
+	assert.NotContains(string(jobsConfigContents), "/var/vcap/jobs/tor/bin/monit_debugger")
+	assert.NotContains(string(jobsConfigContents), "/var/vcap/jobs/tor/hidden_service/hostname")
+	assert.Contains(string(jobsConfigContents), "/var/vcap/monit/tor.monitrc")
+	assert.Contains(string(jobsConfigContents), "/var/vcap/jobs/new_hostname/bin/run")
+	assert.NotContains(string(jobsConfigContents), "/var/vcap/monit/new_hostname.monitrc")
+	assert.Contains(string(jobsConfigContents), "/etc/monitrc")
+}
+
 func TestGenerateRoleImageDockerfileDir(t *testing.T) {
 	assert := assert.New(t)
 
@@ -228,6 +361,7 @@ func TestGenerateRoleImageDockerfileDir(t *testing.T) {
 		{path: "Dockerfile", isDir: false, desc: "Dockerfile"},
 		{path: "root", isDir: true, desc: "image root"},
 		{path: "root/opt/hcf/share/doc/tor/LICENSE", isDir: false, desc: "release license file"},
+		{path: "root/opt/hcf/share/doc/packages.json", isDir: false, desc: "package provenance"},
 		{path: "root/opt/hcf/run.sh", isDir: false, desc: "run script"},
 		{path: "root/opt/hcf/startup/", isDir: true, desc: "role startup scripts dir"},
 		{path: "root/opt/hcf/startup/myrole.sh", isDir: false, desc: "role specific startup script"},
@@ -254,6 +388,17 @@ func TestGenerateRoleImageDockerfileDir(t *testing.T) {
 		}
 	}
 
+	// The packages are traced back to their blobs
+	var provenance []*model.PackageProvenance
+	if buf, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "root", model.PackageProvenancePath)); assert.NoError(err) {
+		if assert.NoError(json.Unmarshal(buf, &provenance)) && assert.Len(provenance, 2) {
+			assert.Equal("tor", provenance[1].Name)
+			if assert.Len(provenance[1].Blobs, 1) {
+				assert.Equal("tor/tor-0.2.6.10.tar.gz", provenance[1].Blobs[0].Path)
+			}
+		}
+	}
+
 	// job.MF should not be there
 	assert.Error(util.ValidatePath(filepath.ToSlash(filepath.Join(dockerfileDir, "root/var/vcap/jobs-src/tor/job.MF")), false, "job manifest file"))
 
@@ -300,6 +445,81 @@ func TestGenerateRoleImageDockerfileDir(t *testing.T) {
 	assert.JSONEq(expectedString, string(buf))
 }
 
+func TestGenerateRoleImageDevMounts(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(
+		&bytes.Buffer{},
+		ioutil.Discard,
+		nil,
+	)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+
+	compiledPackagesDir := filepath.Join(workDir, "../test-assets/tor-boshrelease-fake-compiled")
+	targetPath, err := ioutil.TempDir("", "fissile-test")
+	assert.NoError(err)
+	defer os.RemoveAll(targetPath)
+
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	assert.NoError(err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml")
+	rolesManifest, err := model.LoadRoleManifest(roleManifestPath, []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	torOpinionsDir := filepath.Join(workDir, "../test-assets/tor-opinions")
+	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
+	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
+
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "", "3.14.15", "6.28.30", ui)
+	assert.NoError(err)
+	rolesManifest.SetImageOptions(model.RoleImageOptions{DevMounts: true})
+
+	dockerfileDir, err := roleImageBuilder.CreateDockerfileDir(rolesManifest.Roles[0], "base")
+	if !assert.NoError(err) {
+		return
+	}
+
+	// The jobs and role scripts are left out of the image
+	assert.NoError(util.ValidatePath(filepath.ToSlash(filepath.Join(dockerfileDir, "root/opt/hcf/run.sh")), false, "run script"))
+	assert.Error(util.ValidatePath(filepath.ToSlash(filepath.Join(dockerfileDir, "root/var/vcap/jobs-src")), true, "jobs dir"))
+	assert.Error(util.ValidatePath(filepath.ToSlash(filepath.Join(dockerfileDir, "root/opt/hcf/startup")), true, "role startup scripts dir"))
+
+	dockerfile, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "Dockerfile"))
+	if assert.NoError(err) {
+		assert.Contains(string(dockerfile), `VOLUME ["/var/vcap/jobs-src", "/opt/hcf/startup"]`)
+	}
+	runScript, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "root/opt/hcf/run.sh"))
+	if assert.NoError(err) {
+		assert.Contains(string(runScript), "until [ -e /var/vcap/jobs-src/.fissile-dev-synced ]")
+	}
+
+	// They are written for dev sync instead
+	syncDir := filepath.Join(targetPath, "sync")
+	if !assert.NoError(roleImageBuilder.WriteDevMounts(rolesManifest.Roles[0], syncDir)) {
+		return
+	}
+	for _, info := range []struct {
+		path  string
+		isDir bool
+		desc  string
+	}{
+		{path: "opt/hcf/startup/myrole.sh", isDir: false, desc: "role specific startup script"},
+		{path: "var/vcap/jobs-src/tor/monit", isDir: false, desc: "job monit file"},
+		{path: "var/vcap/jobs-src/tor/config_spec.json", isDir: false, desc: "tor config spec"},
+		{path: "var/vcap/jobs-src/.fissile-dev-synced", isDir: false, desc: "sync marker"},
+	} {
+		assert.NoError(util.ValidatePath(filepath.ToSlash(filepath.Join(syncDir, info.path)), info.isDir, info.desc))
+	}
+}
+
 // getPackage is a helper to get a package from a list of roles
 func getPackage(roles model.Roles, role, job, pkg string) *model.Package {
 	for _, r := range roles {
@@ -519,3 +739,246 @@ func TestBuildRoleImages(t *testing.T) {
 	assert.NoError(err)
 	assert.Regexp(regexp.MustCompile(expected), string(contents))
 }
+
+func TestGenerateRoleImageCACertificates(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(
+		&bytes.Buffer{},
+		ioutil.Discard,
+		nil,
+	)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+
+	compiledPackagesDir := filepath.Join(workDir, "../test-assets/tor-boshrelease-fake-compiled")
+	targetPath, err := ioutil.TempDir("", "fissile-test")
+	assert.NoError(err)
+	defer os.RemoveAll(targetPath)
+
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	assert.NoError(err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/ca-bundle.yml")
+	rolesManifest, err := model.LoadRoleManifest(roleManifestPath, []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	torOpinionsDir := filepath.Join(workDir, "../test-assets/tor-opinions")
+	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
+	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
+
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "", "3.14.15", "6.28.30", ui)
+	assert.NoError(err)
+	rolesManifest.SetImageOptions(model.RoleImageOptions{DevMounts: true})
+
+	dockerfileDir, err := roleImageBuilder.CreateDockerfileDir(rolesManifest.Roles[0], "base")
+	if !assert.NoError(err) {
+		return
+	}
+
+	for _, name := range []string{"fissile-ca-1.crt", "fissile-ca-2.crt"} {
+		certificate, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "root/opt/hcf/ca-certificates", name))
+		if assert.NoError(err) {
+			assert.Contains(string(certificate), "-----BEGIN CERTIFICATE-----")
+		}
+	}
+
+	dockerfile, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "Dockerfile"))
+	if assert.NoError(err) {
+		assert.Contains(string(dockerfile), "cp /opt/hcf/ca-certificates/*.crt /usr/local/share/ca-certificates/ && update-ca-certificates")
+		assert.Contains(string(dockerfile), "update-ca-trust extract")
+	}
+}
+
+func TestGenerateRoleImageDockerfileSnippet(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(
+		&bytes.Buffer{},
+		ioutil.Discard,
+		nil,
+	)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+
+	compiledPackagesDir := filepath.Join(workDir, "../test-assets/tor-boshrelease-fake-compiled")
+	targetPath, err := ioutil.TempDir("", "fissile-test")
+	assert.NoError(err)
+	defer os.RemoveAll(targetPath)
+
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	assert.NoError(err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/dockerfile.yml")
+	rolesManifest, err := model.LoadRoleManifest(roleManifestPath, []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	torOpinionsDir := filepath.Join(workDir, "../test-assets/tor-opinions")
+	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
+	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
+
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "", "3.14.15", "6.28.30", ui)
+	assert.NoError(err)
+	rolesManifest.SetImageOptions(model.RoleImageOptions{DevMounts: true})
+
+	dockerfileDir, err := roleImageBuilder.CreateDockerfileDir(rolesManifest.Roles[0], "base")
+	if !assert.NoError(err) {
+		return
+	}
+
+	extraConfig, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "role-dockerfile/torrc.extra"))
+	if assert.NoError(err) {
+		assert.Equal("ExitPolicy reject *:*\n", string(extraConfig))
+	}
+	_, err = os.Stat(filepath.Join(dockerfileDir, "role-dockerfile/Dockerfile.myrole"))
+	assert.True(os.IsNotExist(err), "only the files the snippet adds are copied")
+
+	dockerfile, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "Dockerfile"))
+	if assert.NoError(err) {
+		assert.Contains(string(dockerfile), "RUN apt-get update && \\\n    apt-get install -y tor-arm\n")
+		assert.Contains(string(dockerfile), "COPY role-dockerfile/torrc.extra /etc/tor/torrc.extra\n")
+		// The snippet can't replace the entrypoint
+		assert.True(strings.HasSuffix(strings.TrimSpace(string(dockerfile)), `ENTRYPOINT ["/usr/bin/dumb-init","--single-child","--","/bin/bash","/opt/hcf/run.sh"]`))
+	}
+}
+
+func TestGenerateRoleImageHealthShim(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(
+		&bytes.Buffer{},
+		ioutil.Discard,
+		nil,
+	)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+
+	compiledPackagesDir := filepath.Join(workDir, "../test-assets/tor-boshrelease-fake-compiled")
+	targetPath, err := ioutil.TempDir("", "fissile-test")
+	assert.NoError(err)
+	defer os.RemoveAll(targetPath)
+
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	assert.NoError(err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml")
+	rolesManifest, err := model.LoadRoleManifest(roleManifestPath, []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	torOpinionsDir := filepath.Join(workDir, "../test-assets/tor-opinions")
+	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
+	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
+
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "", "3.14.15", "6.28.30", ui)
+	assert.NoError(err)
+
+	role := rolesManifest.Roles[0]
+	dockerfileDir, err := roleImageBuilder.CreateDockerfileDir(role, "base")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Error(util.ValidatePath(filepath.Join(dockerfileDir, "root/opt/hcf/health-shim.rb"), false, "health shim"))
+	runScript, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "root/opt/hcf/run.sh"))
+	if assert.NoError(err) {
+		assert.NotContains(string(runScript), "health-shim.rb")
+	}
+	os.RemoveAll(dockerfileDir)
+
+	role.Run = &model.RoleRun{HealthShim: &model.RoleRunHealthShim{Port: 9000}}
+	dockerfileDir, err = roleImageBuilder.CreateDockerfileDir(role, "base")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.NoError(util.ValidatePath(filepath.Join(dockerfileDir, "root/opt/hcf/health-shim.rb"), false, "health shim"))
+	runScript, err = ioutil.ReadFile(filepath.Join(dockerfileDir, "root/opt/hcf/run.sh"))
+	if assert.NoError(err) {
+		assert.Contains(string(runScript), `"${health_shim_ruby}" /opt/hcf/health-shim.rb 9000 &`)
+	}
+}
+
+func TestGenerateRoleImageJobOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(
+		&bytes.Buffer{},
+		ioutil.Discard,
+		nil,
+	)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+
+	compiledPackagesDir := filepath.Join(workDir, "../test-assets/tor-boshrelease-fake-compiled")
+	targetPath, err := ioutil.TempDir("", "fissile-test")
+	assert.NoError(err)
+	defer os.RemoveAll(targetPath)
+
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	assert.NoError(err)
+
+	torOpinionsDir := filepath.Join(workDir, "../test-assets/tor-opinions")
+	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
+	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
+
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "", "3.14.15", "6.28.30", ui)
+	assert.NoError(err)
+
+	// Roles without job dependencies stop all jobs at once
+	rolesManifest, err := model.LoadRoleManifest(filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml"), []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+	dockerfileDir, err := roleImageBuilder.CreateDockerfileDir(rolesManifest.Roles[0], "base")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Error(util.ValidatePath(filepath.Join(dockerfileDir, "root/opt/hcf/pre-stop.sh"), false, "pre-stop script"))
+	runScript, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "root/opt/hcf/run.sh"))
+	if assert.NoError(err) {
+		assert.NotContains(string(runScript), "pre-stop.sh")
+		assert.NotContains(string(runScript), "monit-depends-on")
+	}
+	os.RemoveAll(dockerfileDir)
+
+	rolesManifest, err = model.LoadRoleManifest(filepath.Join(workDir, "../test-assets/role-manifests/shutdown-order.yml"), []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+	dockerfileDir, err = roleImageBuilder.CreateDockerfileDir(rolesManifest.Roles[0], "base")
+	if !assert.NoError(err) {
+		return
+	}
+	preStopScript, err := ioutil.ReadFile(filepath.Join(dockerfileDir, "root/opt/hcf/pre-stop.sh"))
+	if assert.NoError(err) {
+		// new_hostname is listed last, but tor uses it
+		assert.Contains(string(preStopScript),
+			"    drain-job tor\n    stop-job tor\n\n    drain-job new_hostname\n    stop-job new_hostname\n")
+	}
+	runScript, err = ioutil.ReadFile(filepath.Join(dockerfileDir, "root/opt/hcf/run.sh"))
+	if assert.NoError(err) {
+		assert.Contains(string(runScript), "    /opt/hcf/pre-stop.sh\n")
+		// monit starts tor after new_hostname
+		assert.Contains(string(runScript), "\nmonit-depends-on tor new_hostname\n")
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/hpcloud/fissile/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagBBRKubectl   string
+	flagBBRNamespace string
+	flagBBRTimeout   time.Duration
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup [ROLE...]",
+	Short: "Backs up roles running in a Kubernetes cluster.",
+	Long: `
+Runs the BOSH Backup and Restore (BBR) backup scripts of the given roles, or of
+all roles with a ` + "`backup`" + ` section in the role manifest if none are given.
+
+A job whose templates include ` + "`bin/bbr/backup`" + ` and ` + "`bin/bbr/restore`" + ` takes part
+in backups. For each role, fissile creates a Kubernetes job from the pod template
+of the deployed role. It runs the scripts with the artifacts volume of the role
+mounted; every job writes its artifacts to its own directory on that volume.
+
+This uses ` + "`kubectl`" + `, with its current context, to talk to the cluster.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBBR(cmd, args, model.BBRActionBackup)
+	},
+}
+
+// runBBR runs a BBR action for the roles given on the command line
+func runBBR(cmd *cobra.Command, args []string, action string) error {
+	// The backup and restore commands have the same flags; bind the ones of
+	// the command that is running
+	viper.BindPFlags(cmd.PersistentFlags())
+
+	flagBBRKubectl = viper.GetString("kubectl")
+	flagBBRNamespace = viper.GetString("kube-namespace")
+	flagBBRTimeout = viper.GetDuration("bbr-timeout")
+
+	err := fissile.LoadReleases(
+		flagRelease,
+		flagReleaseName,
+		flagReleaseVersion,
+		flagCacheDir,
+	)
+	if err != nil {
+		return err
+	}
+
+	return fissile.RunBBR(
+		flagRoleManifest,
+		args,
+		action,
+		flagBBRKubectl,
+		flagBBRNamespace,
+		flagBBRTimeout,
+	)
+}
+
+// addBBRFlags adds the flags of the backup and restore commands
+func addBBRFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringP(
+		"kubectl",
+		"",
+		"kubectl",
+		"Path to the kubectl binary",
+	)
+
+	cmd.PersistentFlags().StringP(
+		"kube-namespace",
+		"",
+		"",
+		"Kubernetes namespace the roles are deployed in; defaults to the one of the current kubectl context",
+	)
+
+	cmd.PersistentFlags().DurationP(
+		"bbr-timeout",
+		"",
+		30*time.Minute,
+		"How long to wait for the scripts of each role to finish",
+	)
+}
+
+func init() {
+	RootCmd.AddCommand(backupCmd)
+
+	addBBRFlags(backupCmd)
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"github.com/hpcloud/fissile/builder"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -8,6 +10,10 @@ import (
 var (
 	flagBuildImagesNoBuild       bool
 	flagBuildImagesForce         bool
+	flagBuildImagesTraceStartup  bool
+	flagBuildImagesDevMounts     bool
+	flagBuildImagesExplain       bool
+	flagBuildImagesStemcellOS    string
 	flagPatchPropertiesDirective string
 )
 
@@ -33,13 +39,43 @@ The images will be tagged: ` + "`<repository>-<role_name>:<SIGNATURE>`" + `.
 The SIGNATURE is based on the hashes of all jobs and packages that are included in
 the image.
 
+The release versions, package fingerprints and stemcell layer image are checked
+against the lock file (see --lock-file); the build fails if they differ, unless
+--update-lock is given. The lock file is created if it does not exist.
+
+Containers started with FISSILE_TRACE=true trace their startup scripts with
+timestamps, and print a timeline of the startup steps; --trace-startup makes
+that the default for the images. Images built with it are tagged differently
+from the others, as their SIGNATURE includes it.
+
+Images built with --dev-mounts leave out the job templates and role scripts.
+Their containers wait for ` + "`fissile dev sync`" + ` to copy them in before starting,
+so changes to the jobs can be tried without rebuilding images. These images are
+tagged differently from the others too.
+
+Releases whose manifest names the stemcell they were built for (compiled
+releases do) are checked against the stemcell of the role base image, as given
+by its ` + "`stemcell-flavor`" + ` and ` + "`stemcell-version`" + ` labels, or by --stemcell-os.
+Mismatches are reported as warnings.
+
+Roles with an ` + "`image-size-budget`" + ` (in MB) in the role manifest fail the build if
+their compiled packages are larger than that.
+
 The --patch-properties-release flag is used to distinguish the patchProperties release/job spec
 from other specs.  At most one is allowed.  Its syntax is --patch-properties-release=<RELEASE>/<JOB>.
 	`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
+		// The force and explain flags are shared with other commands; bind
+		// the ones of this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
 		flagBuildImagesNoBuild = viper.GetBool("no-build")
 		flagBuildImagesForce = viper.GetBool("force")
+		flagBuildImagesTraceStartup = viper.GetBool("trace-startup")
+		flagBuildImagesDevMounts = viper.GetBool("dev-mounts")
+		flagBuildImagesExplain = viper.GetBool("explain")
+		flagBuildImagesStemcellOS = viper.GetString("stemcell-os")
 		flagPatchPropertiesDirective = viper.GetString("patch-properties-release")
 
 		err := fissile.SetPatchPropertiesDirective(flagPatchPropertiesDirective)
@@ -56,12 +92,24 @@ from other specs.  At most one is allowed.  Its syntax is --patch-properties-rel
 			return err
 		}
 
+		baseImageName := builder.GetBaseImageName(flagRepository, fissile.Version)
+		if err := fissile.CheckLock(flagLockFile, flagUpdateLock, baseImageName); err != nil {
+			return err
+		}
+
+		if err := fissile.CheckStemcellConstraints(flagBuildImagesStemcellOS, baseImageName); err != nil {
+			return err
+		}
+
 		return fissile.GenerateRoleImages(
 			workPathDockerDir,
 			flagRepository,
 			flagMetrics,
 			flagBuildImagesNoBuild,
 			flagBuildImagesForce,
+			flagBuildImagesTraceStartup,
+			flagBuildImagesDevMounts,
+			flagBuildImagesExplain,
 			flagWorkers,
 			flagRoleManifest,
 			workPathCompilationDir,
@@ -88,6 +136,34 @@ func init() {
 		"If specified, image creation will proceed even when images already exist.",
 	)
 
+	buildImagesCmd.PersistentFlags().BoolP(
+		"trace-startup",
+		"",
+		false,
+		"If specified, the images trace their startup scripts and report a startup timeline; they get their own tags.",
+	)
+
+	buildImagesCmd.PersistentFlags().BoolP(
+		"dev-mounts",
+		"",
+		false,
+		"If specified, the images leave out the job templates and role scripts, which come from `fissile dev sync` instead; they get their own tags.",
+	)
+
+	buildImagesCmd.PersistentFlags().BoolP(
+		"explain",
+		"",
+		false,
+		"If specified, print why each role image is built or skipped: whether docker has it, and which of its inputs changed since it was last built.",
+	)
+
+	buildImagesCmd.PersistentFlags().StringP(
+		"stemcell-os",
+		"",
+		"",
+		"The stemcell of the base image as <os>/<version>, to check the stemcell constraints of releases against; defaults to the image labels.",
+	)
+
 	buildImagesCmd.PersistentFlags().StringP(
 		"patch-properties-release",
 		"P",
@@ -1,6 +1,12 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/hpcloud/fissile/app"
+	"github.com/hpcloud/fissile/kube"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -8,23 +14,88 @@ import (
 var (
 	flagBuildKubeOutputDir          string
 	flagBuildKubeDefaultEnvFiles    []string
+	flagBuildKubeEnvFiles           []string
+	flagBuildKubeSet                string
 	flagBuildKubeDockerRegistry     string
 	flagBuildKubeDockerOrganization string
 	flagBuildKubeUseMemoryLimits    bool
+	flagBuildKubeConfigChecksums    bool
+	flagBuildKubeOnlyKinds          []string
+	flagBuildKubeSkipKinds          []string
+	flagBuildKubeDeployScript       bool
+	flagBuildKubeMergeExisting      bool
+	flagBuildKubeDiscovery          string
+	flagBuildKubeDiscoveryHosts     string
+	flagBuildKubePausedRoles        []string
+	flagBuildKubePlatform           string
 )
 
 // buildKubeCmd represents the kube command
 var buildKubeCmd = &cobra.Command{
 	Use:   "kube",
 	Short: "Creates Kubernetes configuration files.",
-	Long:  ``,
+	Long: `
+Writes the Kubernetes objects of each role to <kube-output-dir>/<role type>/<role name>.yml.
+
+With --merge-existing, the annotations and labels added to the objects (and to
+their pod templates) of files already in the output directory are kept, so
+regenerating does not undo them. Fissile records the annotations and labels it
+sets in the skiff-managed-annotations and skiff-managed-labels annotations;
+the ones it set before but does not set anymore are removed, and the values it
+sets win over edited ones. Other changes to the files are overwritten.
+
+The connection info of roles, which the roles linking to them read, has the
+host found by the --discovery strategy: the Kubernetes service of the role
+(kube), its Consul service (consul), or the host given for the role in
+--discovery-hosts, e.g. mysql=10.0.0.5,nats=10.0.0.6 (static). The hosts can
+also be set through the FISSILE_DISCOVERY_HOSTS environment variable.
+
+The platform hints of the roles, e.g. that their load balancer is internal, are
+translated for the cloud given by --platform (aws, gcp or azure) into the
+annotations of the services of the roles; with none, they are left out. Roles
+with hints need the LoadBalancer service type. Hints the cloud has no equivalent
+for, like the idle timeout on gcp, are reported and left out.
+
+The configuration variables get the values of the env files given by
+--defaults-file, overridden by the ones given by --env-from-file, overridden by
+the ones given by --set, e.g. --set DOMAIN=example.com,LOG_LEVEL=debug. Variables
+without any of those get their default from the role manifest. This way one
+role manifest serves many environments, each with its env file.
+
+Pods only read the ConfigMaps and Secrets fissile generates (the connection
+info of linked roles, and the certificates of TLS ports) when they start. The
+pod templates get the checksum of those they read in the skiff-config-checksum
+annotation, so a change rolls out new pods like an image change does. Use
+--config-checksums=false when restarts are handled otherwise.
+
+The roles given by --paused-roles are generated dormant: their Deployments and
+StatefulSets have no replicas, their Jobs start no pods, and their CronJobs are
+suspended. Their objects get the skiff-paused annotation, and the deploy script
+does not wait for them. Scale them up, or regenerate without pausing them, to
+run them.
+
+The Jobs of manual tasks, the bosh-task roles with the manual flight stage, are
+generated suspended too, so that applying them doesn't run them. Run one with
+kubectl patch job <role> --patch '{"spec":{"parallelism":1}}'.
+`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		flagBuildKubeOutputDir = viper.GetString("kube-output-dir")
 		flagBuildKubeDefaultEnvFiles = splitNonEmpty(viper.GetString("defaults-file"), ",")
+		flagBuildKubeEnvFiles = splitNonEmpty(viper.GetString("env-from-file"), ",")
+		flagBuildKubeSet = viper.GetString("set")
 		flagBuildKubeDockerRegistry = viper.GetString("docker-registry")
 		flagBuildKubeDockerOrganization = viper.GetString("docker-organization")
 		flagBuildKubeUseMemoryLimits = viper.GetBool("use-memory-limits")
+		flagBuildKubeConfigChecksums = viper.GetBool("config-checksums")
+		flagBuildKubeOnlyKinds = splitNonEmpty(viper.GetString("only-kinds"), ",")
+		flagBuildKubeSkipKinds = splitNonEmpty(viper.GetString("skip-kinds"), ",")
+		flagBuildKubeDeployScript = viper.GetBool("deploy-script")
+		flagBuildKubeMergeExisting = viper.GetBool("merge-existing")
+		flagBuildKubeDiscovery = viper.GetString("discovery")
+		flagBuildKubeDiscoveryHosts = viper.GetString("discovery-hosts")
+		flagBuildKubePausedRoles = splitNonEmpty(viper.GetString("paused-roles"), ",")
+		flagBuildKubePlatform = viper.GetString("platform")
 
 		err := fissile.LoadReleases(
 			flagRelease,
@@ -36,15 +107,28 @@ var buildKubeCmd = &cobra.Command{
 			return err
 		}
 
-		return fissile.GenerateKube(
-			flagRoleManifest,
-			flagBuildKubeOutputDir,
-			flagRepository,
-			flagBuildKubeDockerRegistry,
-			flagBuildKubeDockerOrganization,
-			flagBuildKubeDefaultEnvFiles,
-			flagBuildKubeUseMemoryLimits,
-		)
+		return fissile.GenerateKube(app.KubeOptions{
+			RolesManifestPath: flagRoleManifest,
+			OutputDir:         flagBuildKubeOutputDir,
+			Repository:        flagRepository,
+			Registry:          flagBuildKubeDockerRegistry,
+			Organization:      flagBuildKubeDockerOrganization,
+			Values: app.VariableValues{
+				DefaultEnvFiles: flagBuildKubeDefaultEnvFiles,
+				EnvFiles:        flagBuildKubeEnvFiles,
+				Set:             flagBuildKubeSet,
+			},
+			UseMemoryLimits: flagBuildKubeUseMemoryLimits,
+			ConfigChecksums: flagBuildKubeConfigChecksums,
+			OnlyKinds:       flagBuildKubeOnlyKinds,
+			SkipKinds:       flagBuildKubeSkipKinds,
+			DeployScript:    flagBuildKubeDeployScript,
+			MergeExisting:   flagBuildKubeMergeExisting,
+			DiscoveryName:   flagBuildKubeDiscovery,
+			StaticHosts:     flagBuildKubeDiscoveryHosts,
+			PausedRoles:     flagBuildKubePausedRoles,
+			PlatformName:    flagBuildKubePlatform,
+		})
 
 	},
 }
@@ -66,6 +150,20 @@ func init() {
 		"Env files that contain defaults for the parameters generated by kube",
 	)
 
+	buildKubeCmd.PersistentFlags().StringP(
+		"env-from-file",
+		"",
+		"",
+		"Env files with the values of the configuration variables, overriding the defaults files",
+	)
+
+	buildKubeCmd.PersistentFlags().StringP(
+		"set",
+		"",
+		"",
+		"Comma separated NAME=VALUE configuration variable values, overriding the env files; escape commas in values as \\,",
+	)
+
 	buildKubeCmd.PersistentFlags().StringP(
 		"docker-registry",
 		"",
@@ -87,5 +185,68 @@ func init() {
 		"Include memory limits when generating kube configurations",
 	)
 
+	buildKubeCmd.PersistentFlags().BoolP(
+		"config-checksums",
+		"",
+		true,
+		"Annotate the pod templates with checksums of the generated ConfigMaps and Secrets the roles read, so their pods are replaced when those change",
+	)
+
+	buildKubeCmd.PersistentFlags().StringP(
+		"only-kinds",
+		"",
+		"",
+		"Comma separated kinds of objects to generate (e.g. Service,StatefulSet); defaults to all kinds",
+	)
+
+	buildKubeCmd.PersistentFlags().StringP(
+		"skip-kinds",
+		"",
+		"",
+		"Comma separated kinds of objects not to generate; cannot be combined with --only-kinds",
+	)
+
+	buildKubeCmd.PersistentFlags().BoolP(
+		"deploy-script",
+		"",
+		false,
+		"Also write deploy.sh, which applies the configurations in waves following the role dependencies",
+	)
+
+	buildKubeCmd.PersistentFlags().BoolP(
+		"merge-existing",
+		"",
+		false,
+		"Keep the annotations and labels added to the objects of existing configuration files",
+	)
+
+	buildKubeCmd.PersistentFlags().StringP(
+		"discovery",
+		"",
+		"kube",
+		"How roles find the roles they link to: kube, consul or static",
+	)
+
+	buildKubeCmd.PersistentFlags().StringP(
+		"discovery-hosts",
+		"",
+		"",
+		"Comma separated role=host pairs for the static discovery strategy",
+	)
+
+	buildKubeCmd.PersistentFlags().StringP(
+		"paused-roles",
+		"",
+		"",
+		"Comma separated roles to generate without any pods",
+	)
+
+	buildKubeCmd.PersistentFlags().StringP(
+		"platform",
+		"",
+		kube.PlatformNone,
+		fmt.Sprintf("Cloud platform to translate the platform hints of the roles for, one of %s", strings.Join(kube.Platforms, ", ")),
+	)
+
 	viper.BindPFlags(buildKubeCmd.PersistentFlags())
 }
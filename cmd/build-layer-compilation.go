@@ -32,6 +32,7 @@ If the compilation base image already exists, this command does not do anything.
 			flagBuildLayerFrom,
 			flagRepository,
 			flagMetrics,
+			flagScratchDir,
 			flagBuildLayerCompilationDebug,
 		)
 
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"github.com/hpcloud/fissile/model"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -25,6 +27,9 @@ var buildLayerCmd = &cobra.Command{
 		flagBuildLayerFrom = viper.GetString("from")
 		flagBuildLayerNoBuild = viper.GetBool("no-build")
 
+		if flagStrictProvenance {
+			return model.CheckImagePinned("Base image", flagBuildLayerFrom)
+		}
 		return nil
 	},
 }
@@ -1,7 +1,20 @@
 package cmd
 
 import (
+	"path/filepath"
+
+	"github.com/hpcloud/fissile/compilator"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagBuildPackagesMemoryLimit  int64
+	flagBuildPackagesCPUShares    int64
+	flagBuildPackagesMemoryBudget int64
+	flagBuildPackagesTriageDir    string
+	flagBuildPackagesExplain      bool
 )
 
 // buildPackagesCmd represents the packages command
@@ -22,9 +35,44 @@ Compiled packages are stored in ` + "`<work-dir>/compilation`" + `. Fissile uses
 package's fingerprint as part of the directory structure. This means that if the 
 same package (with the same version) is used by multiple releases, it will only be 
 compiled once.
+
+Each compilation container can be limited in memory and CPU usage. When a total
+memory budget is given, fewer packages are compiled concurrently if the memory
+limits of all workers would not fit within the budget. These limits can also be
+set in the fissile configuration file.
+
+When a package fails to compile, a triage bundle is written to --triage-dir,
+` + "`<work-dir>/triage`" + ` by default: a tarball with the package spec and its
+dependencies, the packaging script, the compilation log and environment, and a
+summary to paste into bug reports.
+
+The release versions and package fingerprints are checked against the lock file
+(see --lock-file); the build fails if they differ, unless --update-lock is given.
+The lock file is created if it does not exist.
+
+Compiled packages are cached in the work directory by their fingerprint, the
+hash of their sources. With --explain, fissile prints for every package whether
+the cache has it, and otherwise whether its fingerprint changed since it was
+last compiled.
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
+		// The explain flag is shared with other commands; bind the one of
+		// this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		flagBuildPackagesMemoryLimit = viper.GetInt64("compile-memory-limit")
+		flagBuildPackagesCPUShares = viper.GetInt64("compile-cpu-shares")
+		flagBuildPackagesMemoryBudget = viper.GetInt64("compile-memory-budget")
+		flagBuildPackagesTriageDir = viper.GetString("triage-dir")
+		flagBuildPackagesExplain = viper.GetBool("explain")
+
+		if flagBuildPackagesTriageDir == "" {
+			flagBuildPackagesTriageDir = filepath.Join(flagWorkDir, "triage")
+		} else if err := absolutePaths(&flagBuildPackagesTriageDir); err != nil {
+			return err
+		}
+
 		err := fissile.LoadReleases(
 			flagRelease,
 			flagReleaseName,
@@ -35,16 +83,64 @@ compiled once.
 			return err
 		}
 
+		if err := fissile.CheckLock(flagLockFile, flagUpdateLock, ""); err != nil {
+			return err
+		}
+
 		return fissile.Compile(
 			flagRepository,
 			workPathCompilationDir,
 			flagRoleManifest,
 			flagMetrics,
+			flagBuildPackagesTriageDir,
 			flagWorkers,
+			compilator.ResourceLimits{
+				Memory:       flagBuildPackagesMemoryLimit,
+				CPUShares:    flagBuildPackagesCPUShares,
+				MemoryBudget: flagBuildPackagesMemoryBudget,
+			},
+			flagBuildPackagesExplain,
 		)
 	},
 }
 
 func init() {
 	buildCmd.AddCommand(buildPackagesCmd)
+
+	buildPackagesCmd.PersistentFlags().Int64P(
+		"compile-memory-limit",
+		"",
+		0,
+		"Memory limit, in MB, of each compilation container; 0 for unlimited.",
+	)
+
+	buildPackagesCmd.PersistentFlags().Int64P(
+		"compile-cpu-shares",
+		"",
+		0,
+		"Relative CPU weight of each compilation container; 0 for the docker default.",
+	)
+
+	buildPackagesCmd.PersistentFlags().Int64P(
+		"compile-memory-budget",
+		"",
+		0,
+		"Total memory, in MB, that concurrent compilations may use; 0 for unlimited.",
+	)
+
+	buildPackagesCmd.PersistentFlags().StringP(
+		"triage-dir",
+		"",
+		"",
+		"Directory the triage bundles of failed compilations are written to; defaults to <work-dir>/triage.",
+	)
+
+	buildPackagesCmd.PersistentFlags().BoolP(
+		"explain",
+		"",
+		false,
+		"Print why each package is compiled or skipped: its fingerprint, the cache consulted, and what changed since it was last compiled.",
+	)
+
+	viper.BindPFlags(buildPackagesCmd.PersistentFlags())
 }
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagBuildPrepareFrom string
+)
+
+// buildPrepareCmd represents the prepare command
+var buildPrepareCmd = &cobra.Command{
+	Use:   "prepare",
+	Short: "Checks the docker environment and warms up caches before a build.",
+	Long: `
+This command verifies that the docker daemon is reachable and that the current
+user is allowed to manage containers and volumes. It then pulls the base image
+used for the layers if it is not available locally, reports whether the
+compilation and stemcell layers have already been built, and creates the work
+and cache directories used by the other build commands.
+
+Running it before ` + "`fissile build packages`" + ` surfaces environment problems
+early instead of in the middle of a compilation.
+	`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Inline the parts of the RootCmd.PersistentPreRunE we need.
+		// Exclude the validateReleaseArgs(), releases are not needed here.
+
+		// The from flag is shared with other commands; bind the one of this
+		// command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		if err := validateBasicFlags(); err != nil {
+			return err
+		}
+
+		flagBuildPrepareFrom = viper.GetString("from")
+
+		if flagStrictProvenance {
+			return model.CheckImagePinned("Base image", flagBuildPrepareFrom)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return fissile.PrepareBuild(
+			flagBuildPrepareFrom,
+			flagRepository,
+			[]string{
+				flagCacheDir,
+				workPathCompilationDir,
+				workPathBaseDockerfile,
+				workPathDockerDir,
+			},
+		)
+	},
+}
+
+func init() {
+	buildCmd.AddCommand(buildPrepareCmd)
+
+	buildPrepareCmd.PersistentFlags().StringP(
+		"from",
+		"F",
+		"ubuntu:14.04",
+		"Docker image used as a base for the layers",
+	)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagDevSubstituteImage     string
+	flagDevSubstituteKubectl   string
+	flagDevSubstituteNamespace string
+)
+
+// devSubstituteCmd represents the dev substitute command
+var devSubstituteCmd = &cobra.Command{
+	Use:   "substitute ROLE",
+	Short: "Runs a role locally in place of its pods in a cluster.",
+	Long: `
+Scales the Deployment of a role down to no pods, and runs the role in a local
+docker container instead, so it can be debugged against a live deployment.
+
+The container gets the environment of the deployed pods, with values read from
+secrets, and runs on the host network. The other roles of the manifest are
+reachable from it by their service names, through ` + "`kubectl port-forward`" + `
+to one of their pods on their service ports; UDP ports are not forwarded. The
+local role is not reachable from the cluster.
+
+The deployed image is run, unless another one is set with --image. Once the
+container exits, the Deployment is scaled back to its replicas.
+
+This uses ` + "`kubectl`" + `, with its current context, to talk to the cluster and
+` + "`docker`" + ` to run the container. Roles that run as a StatefulSet can't be
+substituted.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("Please specify the role to substitute")
+		}
+
+		// The kubectl flags are shared with other commands; bind the ones of
+		// this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		flagDevSubstituteImage = viper.GetString("image")
+		flagDevSubstituteKubectl = viper.GetString("kubectl")
+		flagDevSubstituteNamespace = viper.GetString("kube-namespace")
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.DevSubstitute(
+			flagRoleManifest,
+			args[0],
+			flagDevSubstituteImage,
+			flagDevSubstituteKubectl,
+			flagDevSubstituteNamespace,
+		)
+	},
+}
+
+func init() {
+	devCmd.AddCommand(devSubstituteCmd)
+
+	devSubstituteCmd.PersistentFlags().StringP(
+		"image",
+		"",
+		"",
+		"Image to run for the role; defaults to the deployed one",
+	)
+
+	devSubstituteCmd.PersistentFlags().StringP(
+		"kubectl",
+		"",
+		"kubectl",
+		"Path to the kubectl binary",
+	)
+
+	devSubstituteCmd.PersistentFlags().StringP(
+		"kube-namespace",
+		"",
+		"",
+		"Kubernetes namespace the role is deployed in; defaults to the one of the current kubectl context",
+	)
+}
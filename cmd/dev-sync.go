@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagDevSyncKubectl   string
+	flagDevSyncNamespace string
+	flagDevSyncRestart   bool
+)
+
+// devSyncCmd represents the dev sync command
+var devSyncCmd = &cobra.Command{
+	Use:   "sync ROLE",
+	Short: "Copies the jobs of a role into its running pods.",
+	Long: `
+Copies the job templates, specs and monit files of a role, as they are in the
+releases, and its role scripts into the running pods of the role.
+
+The pods should run images built with ` + "`fissile build images --dev-mounts`" + `;
+they leave out the jobs and role scripts, and wait for the first sync before
+starting. Use --restart to render the templates again and restart the jobs of
+pods that are already running, so changes to dev releases can be tried without
+building images.
+
+This uses ` + "`kubectl`" + `, with its current context, to talk to the cluster.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("Please specify the role to sync")
+		}
+
+		// The kubectl flags are shared with other commands; bind the ones of
+		// this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		flagDevSyncKubectl = viper.GetString("kubectl")
+		flagDevSyncNamespace = viper.GetString("kube-namespace")
+		flagDevSyncRestart = viper.GetBool("restart")
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.DevSync(
+			flagRoleManifest,
+			args[0],
+			flagLightOpinions,
+			flagDarkOpinions,
+			flagDevSyncKubectl,
+			flagDevSyncNamespace,
+			flagDevSyncRestart,
+		)
+	},
+}
+
+func init() {
+	devCmd.AddCommand(devSyncCmd)
+
+	devSyncCmd.PersistentFlags().StringP(
+		"kubectl",
+		"",
+		"kubectl",
+		"Path to the kubectl binary",
+	)
+
+	devSyncCmd.PersistentFlags().StringP(
+		"kube-namespace",
+		"",
+		"",
+		"Kubernetes namespace the role is deployed in; defaults to the one of the current kubectl context",
+	)
+
+	devSyncCmd.PersistentFlags().BoolP(
+		"restart",
+		"",
+		false,
+		"Render the job templates again and restart the jobs after syncing",
+	)
+}
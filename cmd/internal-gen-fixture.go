@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagGenFixtureSpec   string
+	flagGenFixtureOutput string
+)
+
+// internalGenFixtureCmd represents the gen-fixture command
+var internalGenFixtureCmd = &cobra.Command{
+	Use:   "gen-fixture",
+	Short: "Generates a minimal BOSH release from a fixture spec.",
+	Long: `
+Synthesizes a BOSH dev release directory tree from a small YAML spec listing
+the release name and version, and its jobs and packages with their
+fingerprints, dependencies, templates and properties.
+
+The job and package archives are written to ` + "`bosh-cache`" + ` in the output
+directory; pass it as ` + "`--cache-dir`" + ` when using the release. This is useful
+to reproduce bugs with tiny cases; see ` + "`test-assets/fixtures`" + ` for examples.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flagGenFixtureSpec = viper.GetString("fixture-spec")
+		flagGenFixtureOutput = viper.GetString("fixture-output")
+
+		if flagGenFixtureSpec == "" || flagGenFixtureOutput == "" {
+			return fmt.Errorf("Please specify both the fixture spec and the output directory")
+		}
+
+		if err := absolutePaths(&flagGenFixtureSpec, &flagGenFixtureOutput); err != nil {
+			return err
+		}
+
+		return fissile.GenerateFixture(flagGenFixtureSpec, flagGenFixtureOutput)
+	},
+}
+
+func init() {
+	internalCmd.AddCommand(internalGenFixtureCmd)
+
+	internalGenFixtureCmd.PersistentFlags().StringP(
+		"fixture-spec",
+		"",
+		"",
+		"Path to the YAML spec of the release to generate",
+	)
+
+	internalGenFixtureCmd.PersistentFlags().StringP(
+		"fixture-output",
+		"",
+		"",
+		"Directory to write the release to; must not exist",
+	)
+
+	viper.BindPFlags(internalGenFixtureCmd.PersistentFlags())
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// internalCmd represents the internal command
+var internalCmd = &cobra.Command{
+	Use:   "internal",
+	Short: "Has subcommands that help with developing and debugging fissile.",
+}
+
+func init() {
+	RootCmd.AddCommand(internalCmd)
+}
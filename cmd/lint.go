@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagLintConfig string
+	flagLintFix    bool
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Checks the role manifest for naming conventions and reserved words.",
+	Long: `
+Checks the role manifest against lint rules; the releases are not needed, but
+when given, fissile reports the ones the role manifest doesn't use as well. The
+rules, and their default severity, are:
+
+  role-name           (error)   role names match ` + "`^[a-z]([a-z0-9-]*[a-z0-9])?$`" + `
+  port-name           (warning) exposed port names match ` + "`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`" + `
+  variable-name       (warning) configuration variables match ` + "`^[A-Z][A-Z0-9_]*$`" + `
+  forbidden-env-name  (error)   variables don't replace environment variables like PATH or HOSTNAME
+  reserved-label-key  (warning) node selectors don't use kubernetes.io/, k8s.io/ or skiff- labels
+  unused-release      (warning) every release given is used by a role
+  unused-job          (info)    every job of the used releases is used by a role
+
+The lint config (see --lint-config) changes the severity (error, warning, info or off),
+the pattern, or the forbidden names of rules:
+
+  rules:
+    variable-name:
+      severity: error
+    forbidden-env-name:
+      names: [PATH, HOME]
+
+With --fix, port names are renamed in the role manifest when the rename is
+mechanical and the name is defined only once. Use --output json or yaml for
+machine-readable findings. The command fails if there are any errors.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flagLintConfig = viper.GetString("lint-config")
+		flagLintFix = viper.GetBool("fix")
+
+		if flagLintConfig != "" {
+			if err := absolutePaths(&flagLintConfig); err != nil {
+				return err
+			}
+		}
+
+		if len(flagRelease) > 0 {
+			err := fissile.LoadReleases(
+				flagRelease,
+				flagReleaseName,
+				flagReleaseVersion,
+				flagCacheDir,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return fissile.LintRoleManifest(flagRoleManifest, flagLintConfig, flagLintFix, flagOutputFormat)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lintCmd)
+
+	lintCmd.PersistentFlags().StringP(
+		"lint-config",
+		"",
+		"",
+		"Path to a YAML file overriding the lint rules",
+	)
+
+	lintCmd.PersistentFlags().BoolP(
+		"fix",
+		"",
+		false,
+		"Rename names in the role manifest where the fix is mechanical",
+	)
+
+	viper.BindPFlags(lintCmd.PersistentFlags())
+}
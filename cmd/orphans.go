@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"github.com/hpcloud/fissile/app"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagOrphansDelete bool
+)
+
+// orphansCmd represents the orphans command
+var orphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "Lists the stale role images in the registry, and deletes them.",
+	Long: `
+Lists the images in --docker-registry and --docker-organization that carry the
+role label of fissile but are not the image of a role of the role manifest at
+its current version, e.g. the images of older builds and of removed roles.
+Retagging or rebuilding leaves those behind in the registry. The roles of every
+feature and environment of the manifest count, whatever --features and --env
+select, so that the images of disabled features are not deleted.
+
+The current versions are the ones of the releases, which have to match the lock
+file (see --lock-file). The registry has to list its repositories, as
+registries other than Docker Hub do. Credentials for the registry are read from
+the docker configuration of the user.
+
+With --delete, the orphaned images are deleted from the registry. Images that
+are also tagged as the image of a current role are kept.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// The registry flags are shared with other commands; bind the ones of
+		// this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		flagOrphansDelete = viper.GetBool("delete")
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.FindOrphanedImages(app.OrphanOptions{
+			Repository:        flagRepository,
+			Registry:          viper.GetString("docker-registry"),
+			Organization:      viper.GetString("docker-organization"),
+			RolesManifestPath: flagRoleManifest,
+			LockPath:          flagLockFile,
+			Delete:            flagOrphansDelete,
+		})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(orphansCmd)
+
+	orphansCmd.PersistentFlags().StringP(
+		"docker-registry",
+		"",
+		"",
+		"Docker registry of the role images",
+	)
+
+	orphansCmd.PersistentFlags().StringP(
+		"docker-organization",
+		"",
+		"",
+		"Docker organization of the role images",
+	)
+
+	orphansCmd.PersistentFlags().BoolP(
+		"delete",
+		"",
+		false,
+		"Delete the orphaned images from the registry",
+	)
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hpcloud/fissile/app"
+	"github.com/hpcloud/fissile/compilator"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagPipelineRunFrom   string
+	flagPipelineRunUntil  string
+	flagPipelineRunForce  bool
+	flagPipelineRunReport string
+)
+
+// pipelineRunCmd represents the pipeline run command
+var pipelineRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Compiles packages, builds images, writes Kubernetes configuration and pushes the images.",
+	Long: `
+Runs the stages of a build one after the other:
+
+  load      loads the releases
+  validate  loads the role manifest and checks the lock file (see --lock-file)
+  compile   compiles the packages, as ` + "`fissile build packages`" + `
+  build     builds the role images, as ` + "`fissile build images`" + `
+  generate  writes the Kubernetes configuration, as ` + "`fissile build kube`" + `
+  push      pushes the role images to --docker-registry; skipped without one
+
+The layers must have been built with ` + "`fissile build layer`" + ` first.
+
+The load and validate stages always run, as the others depend on them. When one
+of the other stages finishes, a checkpoint is kept in ` + "`<work-dir>/pipeline`" + `;
+the stage is not run again while the releases, the role manifest, the opinions,
+the defaults files and the options stay the same, unless --force is given. Use
+--from and --until to only run some of the stages.
+
+The outcome of every stage is shown once the run is done; --report also writes
+it as JSON to a file.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// The kube and compilation flags are shared with other commands; bind
+		// the ones of this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		flagPipelineRunFrom = viper.GetString("from")
+		flagPipelineRunUntil = viper.GetString("until")
+		flagPipelineRunForce = viper.GetBool("force")
+		flagPipelineRunReport = viper.GetString("report")
+
+		if flagPipelineRunReport != "" {
+			if err := absolutePaths(&flagPipelineRunReport); err != nil {
+				return err
+			}
+		}
+
+		return fissile.RunPipeline(app.PipelineOptions{
+			ReleasePaths:      flagRelease,
+			ReleaseNames:      flagReleaseName,
+			ReleaseVersions:   flagReleaseVersion,
+			CacheDir:          flagCacheDir,
+			RolesManifestPath: flagRoleManifest,
+			LightManifestPath: flagLightOpinions,
+			DarkManifestPath:  flagDarkOpinions,
+			LockPath:          flagLockFile,
+			UpdateLock:        flagUpdateLock,
+			Repository:        flagRepository,
+			Registry:          viper.GetString("docker-registry"),
+			Organization:      viper.GetString("docker-organization"),
+			CompilationDir:    workPathCompilationDir,
+			DockerDir:         workPathDockerDir,
+			KubeOutputDir:     viper.GetString("kube-output-dir"),
+			Values: app.VariableValues{
+				DefaultEnvFiles: splitNonEmpty(viper.GetString("defaults-file"), ","),
+				EnvFiles:        splitNonEmpty(viper.GetString("env-from-file"), ","),
+				Set:             viper.GetString("set"),
+			},
+			UseMemoryLimits: viper.GetBool("use-memory-limits"),
+			ConfigChecksums: viper.GetBool("config-checksums"),
+			Workers:         flagWorkers,
+			Limits: compilator.ResourceLimits{
+				Memory:       viper.GetInt64("compile-memory-limit"),
+				CPUShares:    viper.GetInt64("compile-cpu-shares"),
+				MemoryBudget: viper.GetInt64("compile-memory-budget"),
+			},
+			MetricsPath:   flagMetrics,
+			TriageDir:     filepath.Join(flagWorkDir, "triage"),
+			CheckpointDir: filepath.Join(flagWorkDir, "pipeline"),
+			From:          flagPipelineRunFrom,
+			Until:         flagPipelineRunUntil,
+			Force:         flagPipelineRunForce,
+			Explain:       viper.GetBool("explain"),
+		}, flagPipelineRunReport)
+	},
+}
+
+func init() {
+	pipelineCmd.AddCommand(pipelineRunCmd)
+
+	stages := strings.Join(app.PipelineStages, ", ")
+
+	pipelineRunCmd.PersistentFlags().StringP(
+		"from",
+		"",
+		"",
+		fmt.Sprintf("First stage to run, one of %s; defaults to the first one", stages),
+	)
+
+	pipelineRunCmd.PersistentFlags().StringP(
+		"until",
+		"",
+		"",
+		fmt.Sprintf("Last stage to run, one of %s; defaults to the last one", stages),
+	)
+
+	pipelineRunCmd.PersistentFlags().BoolP(
+		"force",
+		"",
+		false,
+		"Run the selected stages even if they already ran for the same inputs",
+	)
+
+	pipelineRunCmd.PersistentFlags().BoolP(
+		"explain",
+		"",
+		false,
+		"Print why each package and role image is built or skipped",
+	)
+
+	pipelineRunCmd.PersistentFlags().StringP(
+		"report",
+		"",
+		"",
+		"Write the outcome of every stage as JSON to this file",
+	)
+
+	pipelineRunCmd.PersistentFlags().StringP(
+		"kube-output-dir",
+		"k",
+		".",
+		"Kubernetes configuration files will be written to this directory",
+	)
+
+	pipelineRunCmd.PersistentFlags().StringP(
+		"defaults-file",
+		"D",
+		"",
+		"Env files that contain defaults for the parameters generated by kube",
+	)
+
+	pipelineRunCmd.PersistentFlags().StringP(
+		"env-from-file",
+		"",
+		"",
+		"Env files with the values of the configuration variables, overriding the defaults files",
+	)
+
+	pipelineRunCmd.PersistentFlags().StringP(
+		"set",
+		"",
+		"",
+		"Comma separated NAME=VALUE configuration variable values, overriding the env files; escape commas in values as \\,",
+	)
+
+	pipelineRunCmd.PersistentFlags().StringP(
+		"docker-registry",
+		"",
+		"",
+		"Docker registry the images are pushed to, and referenced from",
+	)
+
+	pipelineRunCmd.PersistentFlags().StringP(
+		"docker-organization",
+		"",
+		"",
+		"Docker organization used when referencing image names",
+	)
+
+	pipelineRunCmd.PersistentFlags().BoolP(
+		"use-memory-limits",
+		"",
+		true,
+		"Include memory limits when generating kube configurations",
+	)
+
+	pipelineRunCmd.PersistentFlags().BoolP(
+		"config-checksums",
+		"",
+		true,
+		"Annotate the pod templates with checksums of the generated ConfigMaps and Secrets the roles read",
+	)
+
+	pipelineRunCmd.PersistentFlags().Int64P(
+		"compile-memory-limit",
+		"",
+		0,
+		"Memory limit, in MB, of each compilation container; 0 for unlimited.",
+	)
+
+	pipelineRunCmd.PersistentFlags().Int64P(
+		"compile-cpu-shares",
+		"",
+		0,
+		"Relative CPU weight of each compilation container; 0 for the docker default.",
+	)
+
+	pipelineRunCmd.PersistentFlags().Int64P(
+		"compile-memory-budget",
+		"",
+		0,
+		"Total memory, in MB, that concurrent compilations may use; 0 for unlimited.",
+	)
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// pipelineCmd represents the pipeline command
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Has subcommands to run all the build steps in one go.",
+}
+
+func init() {
+	RootCmd.AddCommand(pipelineCmd)
+}
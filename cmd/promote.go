@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"github.com/hpcloud/fissile/app"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagPromoteFrom        string
+	flagPromoteChannel     string
+	flagPromoteSignCommand string
+	flagPromoteValues      string
+	flagPromoteResults     string
+)
+
+// promoteCmd represents the promote command
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Copies role images to the registry of another channel.",
+	Long: `
+Promotes the role images of a channel, e.g. staging, to another one, e.g. prod.
+The images are read from the build results given by --from, as written by
+` + "`fissile tag`" + ` or an earlier promotion, and copied by digest to --docker-registry
+and --docker-organization, the registry of the channel given by --channel.
+Nothing is built, and the releases are not needed.
+
+With --sign-command, every promoted image is signed again in its new registry;
+the command is run with the image reference, ` + "`<repository>@<digest>`" + `, as its last
+argument.
+
+The build results of the channel are written as JSON to --results, or printed.
+With --values, the ` + "`channel`" + ` and ` + "`images`" + ` (by role) of that YAML values file are
+updated, keeping its other values.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// The registry flags are shared with other commands; bind the ones of
+		// this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		flagPromoteFrom = viper.GetString("from")
+		flagPromoteChannel = viper.GetString("channel")
+		flagPromoteSignCommand = viper.GetString("sign-command")
+		flagPromoteValues = viper.GetString("values")
+		flagPromoteResults = viper.GetString("results")
+
+		for _, path := range []*string{&flagPromoteFrom, &flagPromoteValues, &flagPromoteResults} {
+			if *path != "" {
+				if err := absolutePaths(path); err != nil {
+					return err
+				}
+			}
+		}
+
+		return fissile.PromoteRoleImages(app.PromoteOptions{
+			FromResultsPath: flagPromoteFrom,
+			Channel:         flagPromoteChannel,
+			Registry:        viper.GetString("docker-registry"),
+			Organization:    viper.GetString("docker-organization"),
+			SignCommand:     flagPromoteSignCommand,
+			ValuesPath:      flagPromoteValues,
+			ResultsPath:     flagPromoteResults,
+		})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(promoteCmd)
+
+	promoteCmd.PersistentFlags().StringP(
+		"from",
+		"",
+		"images.json",
+		"Build results of the images to promote",
+	)
+
+	promoteCmd.PersistentFlags().StringP(
+		"channel",
+		"",
+		"",
+		"Channel the images are promoted to, e.g. prod",
+	)
+
+	promoteCmd.PersistentFlags().StringP(
+		"docker-registry",
+		"",
+		"",
+		"Docker registry of the channel",
+	)
+
+	promoteCmd.PersistentFlags().StringP(
+		"docker-organization",
+		"",
+		"",
+		"Docker organization of the channel",
+	)
+
+	promoteCmd.PersistentFlags().StringP(
+		"sign-command",
+		"",
+		"",
+		"Command signing the promoted images, e.g. \"cosign sign --key prod.key\"",
+	)
+
+	promoteCmd.PersistentFlags().StringP(
+		"values",
+		"",
+		"",
+		"YAML values file of the channel, whose images are updated",
+	)
+
+	promoteCmd.PersistentFlags().StringP(
+		"results",
+		"",
+		"",
+		"Write the build results of the channel as JSON to this file, instead of printing them",
+	)
+}
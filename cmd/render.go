@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpcloud/fissile/app"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagRenderDefaultEnvFiles []string
+	flagRenderOutputDir       string
+	flagRenderPrevious        string
+	flagRenderERBRenderer     string
+	flagRenderRuby            string
+	flagRenderRubyImage       string
+)
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render ROLE JOB [TEMPLATE]",
+	Short: "Renders the templates of a job locally.",
+	Long: `
+Renders the templates of a job of a role the way configgin does when the role
+starts, to catch template errors before any container runs. TEMPLATE is the
+source or the destination of one template of the job; all templates of the job
+are rendered when it is not given.
+
+The properties of the job are the defaults of its spec, overridden by the light
+opinions unless they are dark, and by the configuration templates of the role.
+Those use the defaults of the configuration variables, overridden by the env
+files given by --defaults-file and then by the environment of this command.
+Templates are rendered for the first instance of the role; links are not known.
+
+The rendered templates are printed. With --output-dir, they are also written as
+` + "`<output-dir>/<role>/<job>/<destination>`" + `; with --previous, a directory written
+that way before, the differences with the templates rendered then are printed
+instead.
+
+--erb-renderer selects how the ERB templates are evaluated:
+
+- local: by the Ruby interpreter given by --ruby; the default.
+- docker: by Ruby in a container of --ruby-image, pulled if needed, for hosts
+  without Ruby, or without the Ruby BOSH uses.
+- builtin: by fissile itself, without Ruby. It only knows the common patterns of
+  job templates: p, if_p, spec, if/elsif/else/unless, comparisons (==, !=, <, >,
+  <= and >=), and methods like to_json and join. Templates using other Ruby code
+  fail to render, naming the line and the code.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("Expected a role, a job and optionally a template")
+		}
+		template := ""
+		if len(args) == 3 {
+			template = args[2]
+		}
+
+		// The defaults flag is shared with other commands; bind the one of
+		// this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		flagRenderDefaultEnvFiles = splitNonEmpty(viper.GetString("defaults-file"), ",")
+		flagRenderOutputDir = viper.GetString("output-dir")
+		flagRenderPrevious = viper.GetString("previous")
+		flagRenderERBRenderer = viper.GetString("erb-renderer")
+		flagRenderRuby = viper.GetString("ruby")
+		flagRenderRubyImage = viper.GetString("ruby-image")
+
+		for _, path := range []*string{&flagRenderOutputDir, &flagRenderPrevious} {
+			if *path != "" {
+				if err := absolutePaths(path); err != nil {
+					return err
+				}
+			}
+		}
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.RenderJobTemplates(app.RenderOptions{
+			RolesManifestPath: flagRoleManifest,
+			LightManifestPath: flagLightOpinions,
+			DarkManifestPath:  flagDarkOpinions,
+			DefaultEnvFiles:   flagRenderDefaultEnvFiles,
+			Role:              args[0],
+			Job:               args[1],
+			Template:          template,
+			OutputDir:         flagRenderOutputDir,
+			PreviousDir:       flagRenderPrevious,
+			ERBRenderer:       flagRenderERBRenderer,
+			Ruby:              flagRenderRuby,
+			RubyImage:         flagRenderRubyImage,
+			ScratchDir:        flagScratchDir,
+		})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(renderCmd)
+
+	renderCmd.PersistentFlags().StringP(
+		"defaults-file",
+		"D",
+		"",
+		"Env files that contain values for the configuration variables",
+	)
+
+	renderCmd.PersistentFlags().StringP(
+		"output-dir",
+		"",
+		"",
+		"Also write the rendered templates to this directory",
+	)
+
+	renderCmd.PersistentFlags().StringP(
+		"previous",
+		"",
+		"",
+		"Directory of templates rendered before with --output-dir; print the differences with those",
+	)
+
+	renderCmd.PersistentFlags().StringP(
+		"erb-renderer",
+		"",
+		app.ERBRendererLocal,
+		fmt.Sprintf("How the templates are rendered, one of: %s", strings.Join(app.ERBRenderers, ", ")),
+	)
+
+	renderCmd.PersistentFlags().StringP(
+		"ruby",
+		"",
+		"ruby",
+		"Ruby interpreter rendering the templates with the local ERB renderer",
+	)
+
+	renderCmd.PersistentFlags().StringP(
+		"ruby-image",
+		"",
+		app.DefaultRubyImage,
+		"Image rendering the templates with the docker ERB renderer",
+	)
+}
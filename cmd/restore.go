@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/hpcloud/fissile/model"
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [ROLE...]",
+	Short: "Restores roles running in a Kubernetes cluster.",
+	Long: `
+Runs the BOSH Backup and Restore (BBR) restore scripts of the given roles, or of
+all roles with a ` + "`backup`" + ` section in the role manifest if none are given.
+
+The scripts read the artifacts written by ` + "`fissile backup`" + ` from the artifacts
+volume of each role. See the help of ` + "`fissile backup`" + ` for details.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBBR(cmd, args, model.BBRActionRestore)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(restoreCmd)
+
+	addBBRFlags(restoreCmd)
+}
@@ -6,10 +6,13 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/hpcloud/fissile/app"
+	"github.com/hpcloud/fissile/model"
+	"github.com/hpcloud/fissile/util"
 )
 
 var (
@@ -17,18 +20,32 @@ var (
 	fissile *app.Fissile
 	version string
 
-	flagRoleManifest   string
-	flagRelease        []string
-	flagReleaseName    []string
-	flagReleaseVersion []string
-	flagCacheDir       string
-	flagWorkDir        string
-	flagRepository     string
-	flagWorkers        int
-	flagLightOpinions  string
-	flagDarkOpinions   string
-	flagOutputFormat   string
-	flagMetrics        string
+	// stopProfiling finishes the profiles requested with --profile
+	stopProfiling = func() error { return nil }
+
+	// outputWriter prints the output of fissile for the --output-profile
+	outputWriter util.OutputWriter
+
+	flagRoleManifest     string
+	flagRelease          []string
+	flagReleaseName      []string
+	flagReleaseVersion   []string
+	flagCacheDir         string
+	flagWorkDir          string
+	flagRepository       string
+	flagWorkers          int
+	flagLightOpinions    string
+	flagDarkOpinions     string
+	flagOutputFormat     string
+	flagMetrics          string
+	flagLockFile         string
+	flagUpdateLock       bool
+	flagLicenseLimit     int
+	flagScratchDir       string
+	flagFeatures         []string
+	flagEnvironment      string
+	flagArchiveMirrors   []string
+	flagStrictProvenance bool
 
 	// workPath* variables contain paths derived from flagWorkDir
 	workPathCompilationDir string
@@ -46,6 +63,21 @@ Fissile converts existing BOSH dev releases into docker images.
 
 It does this using just the releases, without a BOSH deployment, CPIs, or a BOSH 
 agent.
+
+The --output-profile flag changes how all commands print their output: "ci"
+prints it without colors, "quiet" only prints the last line of output and
+errors, and "json" prints a JSON event for each line of output and one for the
+result of the command.
+
+Fissile exits with a code telling what kind of failure stopped it:
+
+  1  any other failure, including invalid flags
+  2  the role manifest is invalid, or has lint errors
+  3  a release failed to load
+  4  a package failed to compile
+  5  talking to docker or building an image failed
+  6  pushing an image failed
+  7  generating the Kubernetes configuration failed
 `,
 	SilenceErrors: true,
 	SilenceUsage:  true,
@@ -66,11 +98,22 @@ func Execute(f *app.Fissile, v string) error {
 	fissile = f
 	version = v
 
-	return RootCmd.Execute()
+	// Errors from before the flags are parsed use the default profile
+	outputWriter, _ = util.NewOutputWriter(util.OutputProfileDefault, f.UI.Writer)
+
+	cmd, err := RootCmd.ExecuteC()
+	if stopErr := stopProfiling(); err == nil {
+		err = stopErr
+	}
+	if exportErr := util.Tracing.Export(otlpEndpoint(), cmd.CommandPath(), err); exportErr != nil {
+		fmt.Fprintln(os.Stderr, exportErr.Error())
+	}
+	outputWriter.Finish(err, app.ExitCode(err))
+	return err
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, initProfiling, initTracing)
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports Persistent Flags, which, if defined here,
@@ -158,11 +201,88 @@ func init() {
 		"Path to a CSV file to store timing metrics into.",
 	)
 
+	RootCmd.PersistentFlags().StringP(
+		"lock-file",
+		"",
+		"",
+		"Path to the lock file recording release versions, package and job fingerprints and the stemcell; defaults to fissile.lock next to the role manifest.",
+	)
+
+	RootCmd.PersistentFlags().BoolP(
+		"update-lock",
+		"",
+		false,
+		"Rewrite the lock file instead of failing when the build inputs differ from it.",
+	)
+
+	RootCmd.PersistentFlags().IntP(
+		"license-size-limit",
+		"",
+		model.DefaultLicenseSizeLimit,
+		"Size in bytes above which release license files are only recorded by their hash.",
+	)
+
+	RootCmd.PersistentFlags().StringP(
+		"scratch-dir",
+		"",
+		"",
+		"Directory for temporary files, such as extracted jobs and compilation scripts; defaults to the system temporary directory.",
+	)
+
+	RootCmd.PersistentFlags().StringP(
+		"features",
+		"",
+		"",
+		"Comma separated list of features to enable; roles with a feature are only loaded when it is enabled.",
+	)
+
+	RootCmd.PersistentFlags().StringP(
+		"env",
+		"",
+		"",
+		"Environment to load the role manifest for; the overlay its environments section names for it patches the manifest.",
+	)
+
+	RootCmd.PersistentFlags().StringP(
+		"archive-mirror",
+		"",
+		"",
+		"Comma separated URLs of HTTP(S) mirrors serving job and package archives by sha1; archives missing from the BOSH cache are downloaded from them.",
+	)
+
+	RootCmd.PersistentFlags().BoolP(
+		"strict-provenance",
+		"",
+		false,
+		"Fail on any input not pinned by digest or fingerprint: images by tag only, apt packages without a version, jobs and packages without a sha1, releases with uncommitted changes.",
+	)
+
+	RootCmd.PersistentFlags().StringP(
+		"profile",
+		"",
+		"",
+		"Directory to write CPU and heap profiles of this run to, for troubleshooting performance.",
+	)
+
+	RootCmd.PersistentFlags().StringP(
+		"otlp-endpoint",
+		"",
+		"",
+		"OTLP/HTTP endpoint to export the phases of this run to as OpenTelemetry traces, e.g. http://localhost:4318; OTEL_EXPORTER_OTLP_ENDPOINT by default.",
+	)
+
+	RootCmd.PersistentFlags().StringP(
+		"output-profile",
+		"",
+		util.OutputProfileDefault,
+		fmt.Sprintf("How to print output, one of %s", strings.Join(util.OutputProfiles, ", ")),
+	)
+
 	RootCmd.PersistentFlags().StringP(
 		"output",
 		"o",
 		"human",
-		"Choose output format, one of human, json, or yaml (currently only for 'show properties')",
+		"Choose output format, one of human, json, or yaml (currently only for 'show properties' and 'lint')",
 	)
 
 	viper.BindPFlags(RootCmd.PersistentFlags())
@@ -187,6 +307,52 @@ func initConfig() {
 	}
 }
 
+// initProfiling starts profiling if a profile directory is given
+func initProfiling() {
+	profileDir := viper.GetString("profile")
+	if profileDir == "" {
+		return
+	}
+
+	stop, err := util.StartProfiling(profileDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Profiling disabled: %s\n", err.Error())
+		return
+	}
+	stopProfiling = stop
+}
+
+// otlpEndpoint returns the endpoint to export traces to, if any
+func otlpEndpoint() string {
+	if endpoint := viper.GetString("otlp-endpoint"); endpoint != "" {
+		return endpoint
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+}
+
+// initTracing starts tracing the phases of the run if traces are exported
+func initTracing() {
+	if otlpEndpoint() != "" {
+		util.Tracing = util.NewTracer("fissile")
+	}
+}
+
+// setOutputProfile makes all output of fissile go through the writer of an
+// output profile. Profiles other than the default print no colors.
+func setOutputProfile(profile string) error {
+	writer, err := util.NewOutputWriter(profile, fissile.UI.Writer)
+	if err != nil {
+		return err
+	}
+
+	fissile.UI.Writer = writer
+	outputWriter = writer
+	if profile != util.OutputProfileDefault {
+		color.NoColor = true
+	}
+	return nil
+}
+
 // extendPathsFromWorkDirectory sets some directory defaults derived from the
 // --work-dir.
 func extendPathsFromWorkDirectory() {
@@ -206,6 +372,10 @@ func extendPathsFromWorkDirectory() {
 		flagRoleManifest = filepath.Join(workDir, "role-manifest.yml")
 	}
 
+	if flagLockFile == "" {
+		flagLockFile = filepath.Join(filepath.Dir(flagRoleManifest), "fissile.lock")
+	}
+
 	if flagLightOpinions == "" {
 		flagLightOpinions = filepath.Join(workDir, "opinions.yml")
 	}
@@ -230,6 +400,31 @@ func validateBasicFlags() error {
 	flagDarkOpinions = viper.GetString("dark-opinions")
 	flagOutputFormat = viper.GetString("output")
 	flagMetrics = viper.GetString("metrics")
+	flagLockFile = viper.GetString("lock-file")
+	flagUpdateLock = viper.GetBool("update-lock")
+	flagLicenseLimit = viper.GetInt("license-size-limit")
+	flagScratchDir = viper.GetString("scratch-dir")
+	flagFeatures = splitNonEmpty(viper.GetString("features"), ",")
+	flagEnvironment = viper.GetString("env")
+	flagArchiveMirrors = splitNonEmpty(viper.GetString("archive-mirror"), ",")
+	flagStrictProvenance = viper.GetBool("strict-provenance")
+
+	if err = setOutputProfile(viper.GetString("output-profile")); err != nil {
+		return err
+	}
+
+	if flagLicenseLimit <= 0 {
+		return fmt.Errorf("The license size limit must be positive")
+	}
+
+	if flagScratchDir != "" {
+		if err = absolutePaths(&flagScratchDir); err != nil {
+			return err
+		}
+		if err = os.MkdirAll(flagScratchDir, 0755); err != nil {
+			return fmt.Errorf("Error creating scratch directory: %s", err.Error())
+		}
+	}
 
 	extendPathsFromWorkDirectory()
 
@@ -240,6 +435,7 @@ func validateBasicFlags() error {
 		&flagLightOpinions,
 		&flagDarkOpinions,
 		&flagMetrics,
+		&flagLockFile,
 		&workPathCompilationDir,
 		&workPathConfigDir,
 		&workPathBaseDockerfile,
@@ -252,6 +448,19 @@ func validateBasicFlags() error {
 		return err
 	}
 
+	fissile.SetReleaseOptions(model.ReleaseOptions{
+		ArchiveMirrors:   flagArchiveMirrors,
+		LicenseSizeLimit: int64(flagLicenseLimit),
+		ScratchDir:       flagScratchDir,
+	})
+	fissile.SetRoleManifestOptions(model.RoleManifestOptions{
+		Features:             flagFeatures,
+		Environment:          flagEnvironment,
+		StrictProvenance:     flagStrictProvenance,
+		BundleCacheDir:       filepath.Join(flagCacheDir, "fissile-bundles"),
+		RemoteScriptCacheDir: filepath.Join(flagCacheDir, "fissile-scripts"),
+	})
+
 	return nil
 }
 
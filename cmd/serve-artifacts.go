@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagServeArtifactsDir  string
+	flagServeListenAddress string
+	flagServeDebug         bool
+)
+
+// serveArtifactsCmd represents the artifacts command
+var serveArtifactsCmd = &cobra.Command{
+	Use:   "artifacts",
+	Short: "Serves the generated artifacts over HTTP.",
+	Long: `
+Serves the files in the work directory (generated kube configs, Dockerfiles,
+env files, reports, ...) over HTTP, so CI systems and humans can fetch them
+without access to the filesystem of the build agent.
+
+The root URL shows an index of all the files; ` + "`/index.json`" + ` returns the same
+list as JSON. Hidden files are not listed.
+
+With ` + "`--debug-endpoints`" + `, the Go pprof and expvar endpoints are served below
+` + "`/debug/pprof/`" + ` and ` + "`/debug/vars`" + `. Use ` + "`--profile`" + ` to profile other commands.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flagServeArtifactsDir = viper.GetString("artifacts-dir")
+		flagServeListenAddress = viper.GetString("listen-address")
+		flagServeDebug = viper.GetBool("debug-endpoints")
+
+		if flagServeArtifactsDir == "" {
+			flagServeArtifactsDir = flagWorkDir
+		}
+		if err := absolutePaths(&flagServeArtifactsDir); err != nil {
+			return err
+		}
+
+		return fissile.ServeArtifacts(flagServeArtifactsDir, flagServeListenAddress, flagServeDebug)
+	},
+}
+
+func init() {
+	serveCmd.AddCommand(serveArtifactsCmd)
+
+	serveArtifactsCmd.PersistentFlags().StringP(
+		"artifacts-dir",
+		"",
+		"",
+		"Directory to serve; defaults to the work directory",
+	)
+
+	serveArtifactsCmd.PersistentFlags().StringP(
+		"listen-address",
+		"",
+		"127.0.0.1:8080",
+		"Address to listen on for HTTP requests",
+	)
+
+	serveArtifactsCmd.PersistentFlags().BoolP(
+		"debug-endpoints",
+		"",
+		false,
+		"Also serve the pprof and expvar debugging endpoints",
+	)
+
+	viper.BindPFlags(serveArtifactsCmd.PersistentFlags())
+}
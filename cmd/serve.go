@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Has subcommands to serve fissile outputs over HTTP.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Inline the parts of the RootCmd.PersistentPreRunE we need.
+		// Exclude the validateReleaseArgs(), serving needs no releases.
+		return validateBasicFlags()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// showLockDiffCmd represents the lock-diff command
+var showLockDiffCmd = &cobra.Command{
+	Use:   "lock-diff OLD_LOCK NEW_LOCK",
+	Short: "Displays what changed between two lock files, and the roles it affects.",
+	Long: `
+Compares two lock files, e.g. the fissile.lock of the deployed build and the one
+of the build about to replace it, and displays the releases whose version or
+commit changed, and the packages and jobs whose fingerprints changed. The jobs
+are only compared when both lock files record them, as the ones written by
+older versions of fissile don't.
+
+It then lists the roles of the role manifest whose images are affected: the
+ones with a changed job, or a job using a changed package, and all roles when
+the stemcell changed. The jobs of the roles are the ones of the given releases.
+The output is meant to be pasted into change tickets.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return fmt.Errorf("Expected the old and the new lock files")
+		}
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.ShowLockDiff(args[0], args[1], flagRoleManifest)
+	},
+}
+
+func init() {
+	showCmd.AddCommand(showLockDiffCmd)
+}
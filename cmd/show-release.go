@@ -10,7 +10,8 @@ var showReleaseCmd = &cobra.Command{
 	Short: "Displays information about BOSH releases.",
 	Long: `
 Displays a report of all jobs and packages in all referenced releases.
-The report contains the name, version, description and counts of jobs and packages.
+The report contains the name, version, description and counts of jobs and packages,
+and the blobs of each package, the source archives it is compiled from.
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Show job information
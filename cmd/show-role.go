@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// showRoleCmd represents the role command
+var showRoleCmd = &cobra.Command{
+	Use:   "role [ROLE...]",
+	Short: "Displays information about roles.",
+	Long: `
+Displays a report of the given roles from the role manifest, or of all roles if
+none are given. The report contains the jobs and tags of each role, as well as
+the liveness and readiness probes used for its pods, and where they come from.
+It also lists the sizes of the compiled packages of each role, and its image
+size budget from ` + "`image-size-budget`" + ` (in MB) if it has one.
+
+For readiness, an explicit ` + "`healthcheck`" + ` in the role manifest takes precedence
+over the probe fissile derives from monit; roles that are not of the "bosh" type
+have no monit-derived probes.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.ShowRoles(flagRoleManifest, workPathCompilationDir, args)
+	},
+}
+
+func init() {
+	showCmd.AddCommand(showRoleCmd)
+}
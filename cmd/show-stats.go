@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// showStatsCmd represents the stats command
+var showStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Displays summary statistics of the role manifest.",
+	Long: `
+Displays a short summary of the role manifest, as a sanity check for reviews:
+
+- the number of roles, by type
+- the number of jobs and packages of all roles, and of unique packages
+- the memory, virtual CPUs and extended resources requested by all roles, at
+  their minimum and their maximum scale
+- the number of exposed and public ports; port ranges count as one port
+- the number of role images, and the size of the compiled packages in them
+
+Packages shared by roles are counted once per role, except for the unique
+packages. Packages that have not been compiled yet are not part of the sizes.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.ShowStats(flagRoleManifest, workPathCompilationDir)
+	},
+}
+
+func init() {
+	showCmd.AddCommand(showStatsCmd)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagShowTemplateRole string
+)
+
+// showTemplateCmd represents the template command
+var showTemplateCmd = &cobra.Command{
+	Use:   "template KEY",
+	Short: "Displays which level of the role manifest supplies a configuration template.",
+	Long: `
+Configuration templates can be defined in these sections of the role manifest,
+in increasing order of precedence:
+
+  - the ` + "`configuration.templates`" + ` sections of the jobs of a role, which are
+    defaults for the whole role, not only for the job
+  - the global ` + "`configuration.templates`" + ` section
+  - the ` + "`configuration.templates`" + ` section of a role
+
+This command displays, for each role, the effective value of the given key, the
+level it was taken from, and the values it overrides.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("Expected exactly one configuration template key")
+		}
+
+		flagShowTemplateRole = viper.GetString("template-role")
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.ShowConfigurationTemplate(flagRoleManifest, args[0], flagShowTemplateRole)
+	},
+}
+
+func init() {
+	showCmd.AddCommand(showTemplateCmd)
+
+	showTemplateCmd.PersistentFlags().StringP(
+		"template-role",
+		"",
+		"",
+		"Only display the template for this role",
+	)
+
+	viper.BindPFlags(showTemplateCmd.PersistentFlags())
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagShowUpdatesIndex   []string
+	flagShowUpdatesSources map[string]string
+	flagShowUpdatesPin     bool
+)
+
+// showUpdatesCmd represents the updates command
+var showUpdatesCmd = &cobra.Command{
+	Use:   "updates",
+	Short: "Displays newer versions available for the BOSH releases.",
+	Long: `
+Checks bosh.io, or the mirrors given with --release-index, for newer versions of
+the referenced releases, and displays them with a link to their release notes.
+
+Releases are looked up by their source (e.g. ` + "`github.com/cloudfoundry/cf-release`" + `),
+which is taken from the "origin" git remote of the release directory. Use
+--release-source to give it explicitly, as a comma separated list of
+` + "`<RELEASE_NAME>=<SOURCE>`" + ` pairs.
+
+The role manifest can pin the versions of releases, as a map of release names
+to versions under ` + "`release-versions`" + `; loading it fails when other versions of
+the releases are loaded. With --pin, the pinned versions are set to the newest
+versions found, keeping the rest of the manifest as it is, so that builds fail
+until the releases are upgraded.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flagShowUpdatesIndex = splitNonEmpty(viper.GetString("release-index"), ",")
+		flagShowUpdatesPin = viper.GetBool("pin")
+
+		flagShowUpdatesSources = map[string]string{}
+		for _, pair := range splitNonEmpty(viper.GetString("release-source"), ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return fmt.Errorf("Invalid release source %s, expected <RELEASE_NAME>=<SOURCE>", pair)
+			}
+			flagShowUpdatesSources[parts[0]] = parts[1]
+		}
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.ShowReleaseUpdates(
+			flagShowUpdatesIndex,
+			flagShowUpdatesSources,
+			flagRoleManifest,
+			flagShowUpdatesPin,
+		)
+	},
+}
+
+func init() {
+	showCmd.AddCommand(showUpdatesCmd)
+
+	showUpdatesCmd.PersistentFlags().StringP(
+		"release-index",
+		"",
+		"https://bosh.io/api/v1/releases",
+		"Comma separated URLs of release indexes with the bosh.io API; the first one that knows a release is used",
+	)
+
+	showUpdatesCmd.PersistentFlags().StringP(
+		"release-source",
+		"",
+		"",
+		"Comma separated list of <RELEASE_NAME>=<SOURCE> pairs, e.g. cf=github.com/cloudfoundry/cf-release",
+	)
+
+	showUpdatesCmd.PersistentFlags().BoolP(
+		"pin",
+		"",
+		false,
+		"Set the release versions pinned by the role manifest to the newest versions found",
+	)
+
+	viper.BindPFlags(showUpdatesCmd.PersistentFlags())
+}
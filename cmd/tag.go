@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"github.com/hpcloud/fissile/app"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagTagFromRegistry     string
+	flagTagFromOrganization string
+	flagTagPull             bool
+	flagTagPush             bool
+	flagTagChannel          string
+	flagTagResults          string
+)
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Gives built role images the names they have in another registry.",
+	Long: `
+Tags the role images that were built before with the names they get in
+--docker-registry and --docker-organization, as ` + "`fissile build kube`" + ` references
+them, without building anything. This promotes a tested set of images, e.g.
+from a staging registry to the production one.
+
+The existing images are the local images built by ` + "`fissile build images`" + `, or the
+ones in --from-registry and --from-organization when either is given; --pull
+pulls those first. The new names are given to the image IDs of the existing
+images, so they refer to exactly the same images. --push pushes them.
+
+The build results, the new name of the image of every role along with the
+existing name, the image ID and the repository digest of the existing image,
+are written as JSON to --results, or printed. They record --channel, the
+channel the images are in; ` + "`fissile promote`" + ` promotes them to other channels.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// The registry flags are shared with other commands; bind the ones of
+		// this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		flagTagFromRegistry = viper.GetString("from-registry")
+		flagTagFromOrganization = viper.GetString("from-organization")
+		flagTagPull = viper.GetBool("pull")
+		flagTagPush = viper.GetBool("push")
+		flagTagChannel = viper.GetString("channel")
+		flagTagResults = viper.GetString("results")
+
+		if flagTagResults != "" {
+			if err := absolutePaths(&flagTagResults); err != nil {
+				return err
+			}
+		}
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.TagRoleImages(app.TagOptions{
+			Repository:        flagRepository,
+			FromRegistry:      flagTagFromRegistry,
+			FromOrganization:  flagTagFromOrganization,
+			Registry:          viper.GetString("docker-registry"),
+			Organization:      viper.GetString("docker-organization"),
+			RolesManifestPath: flagRoleManifest,
+			Pull:              flagTagPull,
+			Push:              flagTagPush,
+			Channel:           flagTagChannel,
+			ResultsPath:       flagTagResults,
+		})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(tagCmd)
+
+	tagCmd.PersistentFlags().StringP(
+		"from-registry",
+		"",
+		"",
+		"Docker registry of the existing images; the local images when it and --from-organization are empty",
+	)
+
+	tagCmd.PersistentFlags().StringP(
+		"from-organization",
+		"",
+		"",
+		"Docker organization of the existing images",
+	)
+
+	tagCmd.PersistentFlags().StringP(
+		"docker-registry",
+		"",
+		"",
+		"Docker registry of the new image names",
+	)
+
+	tagCmd.PersistentFlags().StringP(
+		"docker-organization",
+		"",
+		"",
+		"Docker organization of the new image names",
+	)
+
+	tagCmd.PersistentFlags().BoolP(
+		"pull",
+		"",
+		false,
+		"Pull the existing images first",
+	)
+
+	tagCmd.PersistentFlags().BoolP(
+		"push",
+		"",
+		false,
+		"Push the images under their new names",
+	)
+
+	tagCmd.PersistentFlags().StringP(
+		"channel",
+		"",
+		"",
+		"Channel the images are in, e.g. dev, recorded in the build results",
+	)
+
+	tagCmd.PersistentFlags().StringP(
+		"results",
+		"",
+		"",
+		"Write the build results as JSON to this file, instead of printing them",
+	)
+}
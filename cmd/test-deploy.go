@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/hpcloud/fissile/app"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// testDeployCmd represents the test deploy command
+var testDeployCmd = &cobra.Command{
+	Use:   "deploy [ROLE...]",
+	Short: "Deploys roles to a throwaway cluster and runs their smoke tests.",
+	Long: `
+Deploys the given roles, and the roles they depend on, or all roles if none are
+given, into a new namespace of a Kubernetes cluster. The roles are deployed in
+the order of the deploy script of ` + "`fissile build kube`" + `, with small resource
+footprints: they request no memory nor CPUs, and long running roles have a
+single instance.
+
+Once all roles are ready, the manual tasks with the ` + "`smoke-test`" + ` tag among the
+roles are run one after the other; the command fails if any of them fails,
+after showing its logs. The namespace is deleted when done, unless --keep is
+given.
+
+With --kind-cluster, fissile uses a kind cluster, creating it if it doesn't
+exist, and loads the role images into it, so they need not be pushed to a
+registry; the clusters it creates are deleted when done as well. Otherwise the
+cluster of the current kubectl context is used.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// The kube flags are shared with other commands; bind the ones of
+		// this command
+		viper.BindPFlags(cmd.PersistentFlags())
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.TestDeploy(app.TestDeployOptions{
+			RolesManifestPath: flagRoleManifest,
+			Roles:             args,
+			Values: app.VariableValues{
+				DefaultEnvFiles: splitNonEmpty(viper.GetString("defaults-file"), ","),
+				EnvFiles:        splitNonEmpty(viper.GetString("env-from-file"), ","),
+				Set:             viper.GetString("set"),
+			},
+			Repository:   flagRepository,
+			Registry:     viper.GetString("docker-registry"),
+			Organization: viper.GetString("docker-organization"),
+			Kubectl:      viper.GetString("kubectl"),
+			Kind:         viper.GetString("kind"),
+			Cluster:      viper.GetString("kind-cluster"),
+			Namespace:    viper.GetString("kube-namespace"),
+			Timeout:      viper.GetDuration("timeout"),
+			Keep:         viper.GetBool("keep"),
+		})
+	},
+}
+
+func init() {
+	testCmd.AddCommand(testDeployCmd)
+
+	testDeployCmd.PersistentFlags().StringP(
+		"defaults-file",
+		"D",
+		"",
+		"Env files that contain defaults for the parameters generated by kube",
+	)
+
+	testDeployCmd.PersistentFlags().StringP(
+		"env-from-file",
+		"",
+		"",
+		"Env files with the values of the configuration variables, overriding the defaults files",
+	)
+
+	testDeployCmd.PersistentFlags().StringP(
+		"set",
+		"",
+		"",
+		"Comma separated NAME=VALUE configuration variable values, overriding the env files; escape commas in values as \\,",
+	)
+
+	testDeployCmd.PersistentFlags().StringP(
+		"docker-registry",
+		"",
+		"",
+		"Docker registry used when referencing image names",
+	)
+
+	testDeployCmd.PersistentFlags().StringP(
+		"docker-organization",
+		"",
+		"",
+		"Docker organization used when referencing image names",
+	)
+
+	testDeployCmd.PersistentFlags().StringP(
+		"kubectl",
+		"",
+		"kubectl",
+		"Path to the kubectl binary",
+	)
+
+	testDeployCmd.PersistentFlags().StringP(
+		"kind",
+		"",
+		"kind",
+		"Path to the kind binary",
+	)
+
+	testDeployCmd.PersistentFlags().StringP(
+		"kind-cluster",
+		"",
+		"",
+		"kind cluster to deploy to, created if it doesn't exist; defaults to the cluster of the current kubectl context",
+	)
+
+	testDeployCmd.PersistentFlags().StringP(
+		"kube-namespace",
+		"",
+		"fissile-test",
+		"Kubernetes namespace to create for the deployment",
+	)
+
+	testDeployCmd.PersistentFlags().DurationP(
+		"timeout",
+		"",
+		10*time.Minute,
+		"How long to wait for each wave of roles to be ready, and for each smoke test to finish",
+	)
+
+	testDeployCmd.PersistentFlags().BoolP(
+		"keep",
+		"",
+		false,
+		"Keep the namespace, and the kind cluster if it was created, when done",
+	)
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// testCmd represents the test command
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Has subcommands that test roles end to end.",
+}
+
+func init() {
+	RootCmd.AddCommand(testCmd)
+}
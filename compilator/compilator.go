@@ -9,7 +9,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hpcloud/fissile/docker"
@@ -33,6 +35,15 @@ const (
 	// compile them.  We will add a volume mount there in the container to work around
 	// issues with AUFS not emulating a normal filesystem correctly.
 	ContainerSourceDir = "/var/vcap/source"
+
+	// peakMemoryMarker prefixes the line the compilation script prints to
+	// report the peak memory usage (in bytes) of the compilation container
+	peakMemoryMarker = "fissile-peak-memory: "
+
+	// compilationSpaceFactor estimates the disk space needed to compile a
+	// package from the size of its archive: extracted sources, build
+	// intermediates and compiled output together take a multiple of it
+	compilationSpaceFactor = 5
 )
 
 // mocked out in tests
@@ -65,6 +76,31 @@ type Compilator struct {
 	signalDependencies map[string]chan struct{}
 	keepContainer      bool
 	ui                 *termui.UI
+
+	limits ResourceLimits
+
+	// triageDir is where the triage bundles of failed compilations are
+	// written; see SetTriageDir
+	triageDir string
+
+	// explain is whether to print why each package is compiled or skipped;
+	// see SetExplain
+	explain bool
+
+	// scratchDir is where temporary files are created; see SetScratchDir
+	scratchDir string
+
+	// peakMemory records the peak memory usage (in bytes) reported by each
+	// compilation container, keyed by "<release>/<package>"
+	peakMemory      map[string]int64
+	peakMemoryMutex sync.Mutex
+}
+
+// ResourceLimits describes the resources available to compilation containers
+type ResourceLimits struct {
+	Memory       int64 // Memory limit of each compilation container, in MB; 0 for unlimited
+	CPUShares    int64 // Relative CPU weight of each compilation container; 0 for the docker default
+	MemoryBudget int64 // Total memory, in MB, all concurrent compilations may use; 0 for unlimited
 }
 
 type compileJob struct {
@@ -98,11 +134,44 @@ func NewCompilator(
 		ui:               ui,
 
 		signalDependencies: make(map[string]chan struct{}),
+		peakMemory:         make(map[string]int64),
 	}
 
 	return compilator, nil
 }
 
+// SetResourceLimits sets the resource limits applied to compilation containers
+func (c *Compilator) SetResourceLimits(limits ResourceLimits) {
+	c.limits = limits
+}
+
+// SetScratchDir sets the directory temporary files, such as the compilation
+// scripts, are created in; the system temporary directory is used if it is
+// empty
+func (c *Compilator) SetScratchDir(scratchDir string) {
+	c.scratchDir = scratchDir
+}
+
+// budgetWorkerCount limits the number of concurrent compilations so that
+// their combined memory limits fit within the memory budget
+func (c *Compilator) budgetWorkerCount(workerCount int) (int, error) {
+	if c.limits.MemoryBudget <= 0 {
+		return workerCount, nil
+	}
+	if c.limits.Memory <= 0 {
+		return 0, fmt.Errorf("A compilation memory budget of %dMB requires a per-container memory limit", c.limits.MemoryBudget)
+	}
+	maxWorkers := int(c.limits.MemoryBudget / c.limits.Memory)
+	if maxWorkers < 1 {
+		return 0, fmt.Errorf("Compilation memory budget of %dMB is smaller than the per-container memory limit of %dMB",
+			c.limits.MemoryBudget, c.limits.Memory)
+	}
+	if workerCount > maxWorkers {
+		return maxWorkers, nil
+	}
+	return workerCount, nil
+}
+
 var errWorkerAbort = errors.New("worker aborted")
 
 type compileResult struct {
@@ -147,11 +216,25 @@ func (c *Compilator) Compile(workerCount int, releases []*model.Release, roleMan
 	}
 	sort.Sort(packages)
 
+	if err := c.checkDiskSpace(packages); err != nil {
+		return err
+	}
+
+	budgetedWorkerCount, err := c.budgetWorkerCount(workerCount)
+	if err != nil {
+		return err
+	}
+	if budgetedWorkerCount != workerCount {
+		c.ui.Printf("Limiting compilation to %s workers to stay within the memory budget of %sMB\n",
+			color.YellowString("%d", budgetedWorkerCount),
+			color.YellowString("%d", c.limits.MemoryBudget))
+	}
+
 	// Setup the queuing system ...
 	doneCh := make(chan compileResult)
 	killCh := make(chan struct{})
 
-	workerLib.MaxJobs = workerCount
+	workerLib.MaxJobs = budgetedWorkerCount
 
 	worker := workerLib.NewWorker()
 	buckets := createDepBuckets(packages)
@@ -185,6 +268,7 @@ func (c *Compilator) Compile(workerCount int, releases []*model.Release, roleMan
 	killed := false
 	for result := range doneCh {
 		if result.err == nil {
+			c.recordPackageFingerprint(result.pkg)
 			close(c.signalDependencies[result.pkg.Fingerprint])
 			c.ui.Printf("%s   > success: %s/%s\n",
 				color.YellowString("result"),
@@ -208,9 +292,78 @@ func (c *Compilator) Compile(workerCount int, releases []*model.Release, roleMan
 		}
 	}
 
+	c.reportPeakMemory()
+
 	return err
 }
 
+// estimateCompilationSpace returns the estimated disk space, in bytes, needed
+// to compile the packages
+func estimateCompilationSpace(packages model.Packages) (uint64, error) {
+	var total uint64
+	for _, pkg := range packages {
+		if pkg.Path == "" {
+			// Nothing to extract
+			continue
+		}
+		info, err := os.Stat(pkg.Path)
+		if err != nil {
+			return 0, fmt.Errorf("Error getting size of package %s/%s: %s", pkg.Release.Name, pkg.Name, err.Error())
+		}
+		total += uint64(info.Size()) * compilationSpaceFactor
+	}
+	return total, nil
+}
+
+// checkDiskSpace fails early if the work directory doesn't have room for
+// compiling the packages, rather than having compilation die midway
+func (c *Compilator) checkDiskSpace(packages model.Packages) error {
+	required, err := estimateCompilationSpace(packages)
+	if err != nil {
+		return err
+	}
+
+	return util.CheckFreeDiskSpace(c.hostWorkDir, required, fmt.Sprintf("compiling %d packages", len(packages)))
+}
+
+// recordPeakMemory parses a line of compilation output, and records the peak
+// memory usage of the package compilation if the line reports it
+func (c *Compilator) recordPeakMemory(pkg *model.Package, line string) {
+	if !strings.HasPrefix(line, peakMemoryMarker) {
+		return
+	}
+	peak, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, peakMemoryMarker)), 10, 64)
+	if err != nil {
+		return
+	}
+	c.peakMemoryMutex.Lock()
+	defer c.peakMemoryMutex.Unlock()
+	c.peakMemory[fmt.Sprintf("%s/%s", pkg.Release.Name, pkg.Name)] = peak
+}
+
+// reportPeakMemory prints the peak memory usage of all compiled packages
+func (c *Compilator) reportPeakMemory() {
+	c.peakMemoryMutex.Lock()
+	defer c.peakMemoryMutex.Unlock()
+
+	if len(c.peakMemory) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(c.peakMemory))
+	for name := range c.peakMemory {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.ui.Println(color.GreenString("Peak memory usage of compiled packages:"))
+	for _, name := range names {
+		c.ui.Printf("  %s: %sMB\n",
+			color.YellowString(name),
+			color.MagentaString("%.2f", float64(c.peakMemory[name])/(1024*1024)))
+	}
+}
+
 func (c *Compilator) gatherPackages(releases []*model.Release, roleManifest *model.RoleManifest) model.Packages {
 	var packages []*model.Package
 
@@ -226,8 +379,11 @@ func (c *Compilator) gatherPackages(releases []*model.Release, roleManifest *mod
 
 		// .. and collect for compilation. (%%) Here we ensure
 		// via the source fingerprints that only the first of
-		// several equivalent packages is taken.
+		// several equivalent packages is taken; packages shared
+		// with an earlier release compile as the package of that
+		// release.
 		for _, pkg := range releasePackages {
+			pkg = pkg.Canonical()
 			if _, known := c.signalDependencies[pkg.Fingerprint]; !known {
 				c.signalDependencies[pkg.Fingerprint] = make(chan struct{})
 				packages = append(packages, pkg)
@@ -302,8 +458,19 @@ func (j compileJob) Run() {
 		stampy.Stamp(c.metricsPath, "fissile", runSeriesName, "start")
 	}
 
+	span := util.Tracing.StartSpan("compile-package")
+	span.SetAttribute("release", j.pkg.Release.Name)
+	span.SetAttribute("package", j.pkg.Name)
+	span.SetAttribute("fingerprint", j.pkg.Fingerprint)
+	span.SetAttribute("cache-hit", false)
+
 	workerErr := compilePackageHarness(c, j.pkg)
 
+	if size, err := j.pkg.GetCompiledSize(c.hostWorkDir); workerErr == nil && err == nil {
+		span.SetAttribute("compiled-size", size)
+	}
+	span.End(workerErr)
+
 	if c.metricsPath != "" {
 		stampy.Stamp(c.metricsPath, "fissile", runSeriesName, "done")
 	}
@@ -383,9 +550,9 @@ func createDepBuckets(packages []*model.Package) []*model.Package {
 
 	// Iterate until we have handled all packages.  We expect each
 	// iteration to handle at least one package, because the input
-	// is a DAG, i.e. has no cycles. Therefore each iteration will
-	// have at least one package with no dependencies, and being
-	// handled.
+	// is a DAG, i.e. has no cycles, as checked when the releases are
+	// loaded. Therefore each iteration will have at least one package
+	// with no dependencies, and being handled.
 
 	keepRunning := true
 	for keepRunning {
@@ -451,7 +618,7 @@ func (c *Compilator) CreateCompilationBase(baseImageName string) (image *dockerC
 		return image, nil
 	}
 
-	tempScriptDir, err := util.TempDir("", "fissile-compilation")
+	tempScriptDir, err := util.TempDir(c.scratchDir, "fissile-compilation")
 	if err != nil {
 		return nil, fmt.Errorf("Could not create temp dir %s: %s", tempScriptDir, err.Error())
 	}
@@ -568,6 +735,7 @@ func (c *Compilator) compilePackage(pkg *model.Package) (err error) {
 	stdoutWriter := docker.NewFormattingWriter(
 		log,
 		func(line string) string {
+			c.recordPeakMemory(pkg, line)
 			return color.GreenString("compilation-%s > %s", color.MagentaString("%s", pkg.Name), color.WhiteString("%s", line))
 		},
 	)
@@ -596,6 +764,8 @@ func (c *Compilator) compilePackage(pkg *model.Package) (err error) {
 		KeepContainer: c.keepContainer,
 		StdoutWriter:  stdoutWriter,
 		StderrWriter:  stderrWriter,
+		Memory:        c.limits.Memory * 1024 * 1024,
+		CPUShares:     c.limits.CPUShares,
 	})
 
 	if container != nil && (!c.keepContainer || err == nil || exitCode == 0) {
@@ -626,18 +796,26 @@ func (c *Compilator) compilePackage(pkg *model.Package) (err error) {
 	}
 
 	if err != nil {
-		log.WriteTo(c.ui)
-		return fmt.Errorf("Error compiling package %s: %s", pkg.Name, err.Error())
+		err = fmt.Errorf("Error compiling package %s: %s", pkg.Name, err.Error())
+	} else if exitCode != 0 {
+		err = fmt.Errorf("Error - compilation for package %s exited with code %d", pkg.Name, exitCode)
 	}
-
-	if exitCode != 0 {
+	if err != nil {
+		if c.triageDir != "" {
+			c.reportTriageBundle(pkg, log.Bytes(), err)
+		}
 		log.WriteTo(c.ui)
-		return fmt.Errorf("Error - compilation for package %s exited with code %d", pkg.Name, exitCode)
+		return err
 	}
 
-	return os.Rename(
+	if err := os.Rename(
 		pkg.GetPackageCompiledTempDir(c.hostWorkDir),
-		pkg.GetPackageCompiledDir(c.hostWorkDir))
+		pkg.GetPackageCompiledDir(c.hostWorkDir)); err != nil {
+		return err
+	}
+
+	_, err = pkg.RecordCompiledSize(c.hostWorkDir)
+	return err
 }
 
 func (c *Compilator) isPackageCompiled(pkg *model.Package) (bool, error) {
@@ -794,8 +972,20 @@ func (c *Compilator) removeCompiledPackages(packages model.Packages) (model.Pack
 		if err != nil {
 			return nil, err
 		}
+		c.explainPackage(pkg, compiled)
 
 		if compiled {
+			span := util.Tracing.StartSpan("compile-package")
+			span.SetAttribute("release", pkg.Release.Name)
+			span.SetAttribute("package", pkg.Name)
+			span.SetAttribute("fingerprint", pkg.Fingerprint)
+			span.SetAttribute("cache-hit", true)
+			if size, err := pkg.GetCompiledSize(c.hostWorkDir); err == nil {
+				span.SetAttribute("compiled-size", size)
+			}
+			span.End(nil)
+
+			c.recordPackageFingerprint(pkg)
 			close(c.signalDependencies[pkg.Fingerprint])
 		} else {
 			culledPackages = append(culledPackages, pkg)
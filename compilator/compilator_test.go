@@ -1,11 +1,13 @@
 package compilator
 
 import (
+	"archive/tar"
 	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -49,7 +51,11 @@ func TestMain(m *testing.M) {
 func TestCompilationEmpty(t *testing.T) {
 	assert := assert.New(t)
 
-	c, err := NewCompilator(nil, "", "", "", "", "", false, ui)
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(compilationWorkDir)
+
+	c, err := NewCompilator(nil, compilationWorkDir, "", "", "", "", false, ui)
 	assert.NoError(err)
 
 	waitCh := make(chan struct{})
@@ -82,7 +88,11 @@ func TestCompilationBasic(t *testing.T) {
 		return nil
 	}
 
-	c, err := NewCompilator(nil, "", metrics, "", "", "", false, ui)
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(compilationWorkDir)
+
+	c, err := NewCompilator(nil, compilationWorkDir, metrics, "", "", "", false, ui)
 	assert.NoError(err)
 
 	release := genTestCase("ruby-2.5", "consul>go-1.4", "go-1.4")
@@ -163,7 +173,11 @@ func TestCompilationSkipCompiled(t *testing.T) {
 
 	assert := assert.New(t)
 
-	c, err := NewCompilator(nil, "", "", "", "", "", false, ui)
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(compilationWorkDir)
+
+	c, err := NewCompilator(nil, compilationWorkDir, "", "", "", "", false, ui)
 	assert.NoError(err)
 
 	release := genTestCase("ruby-2.5", "consul>go-1.4", "go-1.4")
@@ -193,7 +207,11 @@ func TestCompilationRoleManifest(t *testing.T) {
 
 	assert := assert.New(t)
 
-	c, err := NewCompilator(nil, "", "", "", "", "", false, ui)
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(compilationWorkDir)
+
+	c, err := NewCompilator(nil, compilationWorkDir, "", "", "", "", false, ui)
 	assert.NoError(err)
 
 	workDir, err := os.Getwd()
@@ -234,6 +252,66 @@ func TestCompilationRoleManifest(t *testing.T) {
 	}
 }
 
+func TestCompilationSharedPackages(t *testing.T) {
+	saveCompilePackage := compilePackageHarness
+	defer func() {
+		compilePackageHarness = saveCompilePackage
+	}()
+
+	var compiled []string
+	var compiledLock sync.Mutex
+	compilePackageHarness = func(c *Compilator, pkg *model.Package) error {
+		compiledLock.Lock()
+		defer compiledLock.Unlock()
+		compiled = append(compiled, pkg.Release.Name+"/"+pkg.Name)
+		return nil
+	}
+
+	assert := assert.New(t)
+
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(compilationWorkDir)
+
+	c, err := NewCompilator(nil, compilationWorkDir, "", "", "", "", false, ui)
+	assert.NoError(err)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	// Two releases shipping the same packages; the roles only use the jobs of
+	// the second one
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathBoshCache := filepath.Join(releasePath, "bosh-cache")
+	shared, err := model.NewDevRelease(releasePath, "", "", releasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+	shared.Name = "shared"
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml")
+	roleManifest, err := model.LoadRoleManifest(roleManifestPath, []*model.Release{shared, release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	if !assert.NoError(c.Compile(1, []*model.Release{shared, release}, roleManifest)) {
+		return
+	}
+	sort.Strings(compiled)
+	assert.Equal([]string{"shared/libevent", "shared/tor"}, compiled, "shared packages are compiled once, as the package of the first release")
+
+	for _, job := range roleManifest.Roles[0].Jobs {
+		for _, pkg := range job.Packages {
+			assert.Equal("tor", pkg.Release.Name, "jobs keep the packages of their own release")
+		}
+	}
+}
+
 // getContainerIDs returns all (running or not) containers with the given image
 func getContainerIDs(imageName string) ([]string, error) {
 	var results []string
@@ -383,7 +461,11 @@ func TestCompilationMultipleErrors(t *testing.T) {
 
 	assert := assert.New(t)
 
-	c, err := NewCompilator(nil, "", "", "", "", "", false, ui)
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(compilationWorkDir)
+
+	c, err := NewCompilator(nil, compilationWorkDir, "", "", "", "", false, ui)
 	assert.NoError(err)
 
 	release := genTestCase("ruby-2.5", "consul>go-1.4", "go-1.4")
@@ -488,7 +570,11 @@ func TestCompilationParallel(t *testing.T) {
 
 	assert := assert.New(t)
 
-	c, err := NewCompilator(nil, "", "", "", "", "", false, ui)
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(compilationWorkDir)
+
+	c, err := NewCompilator(nil, compilationWorkDir, "", "", "", "", false, ui)
 	assert.NoError(err)
 
 	testDoneCh := make(chan struct{})
@@ -748,7 +834,11 @@ func TestRemoveCompiledPackages(t *testing.T) {
 
 	assert := assert.New(t)
 
-	c, err := NewCompilator(nil, "", "", "", "", "", false, ui)
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(compilationWorkDir)
+
+	c, err := NewCompilator(nil, compilationWorkDir, "", "", "", "", false, ui)
 	assert.NoError(err)
 
 	releases := genTestCase("ruby-2.5", "consul>go-1.4", "go-1.4")
@@ -761,6 +851,114 @@ func TestRemoveCompiledPackages(t *testing.T) {
 	assert.Equal(packages[1].Name, "go-1.4")
 }
 
+func TestBudgetWorkerCount(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := NewCompilator(nil, "", "", "", "", "", false, ui)
+	assert.NoError(err)
+
+	workers, err := c.budgetWorkerCount(4)
+	assert.NoError(err)
+	assert.Equal(4, workers, "no budget should not limit the worker count")
+
+	c.SetResourceLimits(ResourceLimits{Memory: 1024, MemoryBudget: 3000})
+	workers, err = c.budgetWorkerCount(4)
+	assert.NoError(err)
+	assert.Equal(2, workers, "worker count should be limited by the memory budget")
+
+	workers, err = c.budgetWorkerCount(1)
+	assert.NoError(err)
+	assert.Equal(1, workers, "worker count below the budget should be unchanged")
+
+	c.SetResourceLimits(ResourceLimits{Memory: 4096, MemoryBudget: 2048})
+	_, err = c.budgetWorkerCount(2)
+	assert.Error(err, "budget smaller than a single container should fail")
+
+	c.SetResourceLimits(ResourceLimits{MemoryBudget: 2048})
+	_, err = c.budgetWorkerCount(2)
+	assert.Error(err, "budget without a per-container limit should fail")
+}
+
+func TestRecordPeakMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := NewCompilator(nil, "", "", "", "", "", false, ui)
+	assert.NoError(err)
+
+	pkg := genTestCase("ruby-2.5")[0].Packages[0]
+	c.recordPeakMemory(pkg, "Compiling to /var/vcap/packages/ruby-2.5")
+	assert.Empty(c.peakMemory)
+
+	c.recordPeakMemory(pkg, peakMemoryMarker+"not-a-number")
+	assert.Empty(c.peakMemory)
+
+	c.recordPeakMemory(pkg, peakMemoryMarker+"1048576")
+	assert.Equal(map[string]int64{"test-release/ruby-2.5": 1048576}, c.peakMemory)
+}
+
+func TestWriteTriageBundle(t *testing.T) {
+	assert := assert.New(t)
+
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(compilationWorkDir)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := model.NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	c, err := NewCompilator(nil, compilationWorkDir, "", "fissile-test-compilator", compilation.UbuntuBase, "3.14.15", false, ui)
+	assert.NoError(err)
+	c.SetTriageDir(filepath.Join(compilationWorkDir, "triage"))
+
+	pkg, err := release.LookupPackage("tor")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.NoError(c.createCompilationDirStructure(pkg))
+	_, err = pkg.Extract(c.getSourcePackageDir(pkg))
+	assert.NoError(err)
+
+	log := []byte("\x1b[32mcompilation-tor > ./configure\x1b[0m\ncompilation-tor > make: *** [all] Error 2\n")
+	bundlePath, err := c.writeTriageBundle(pkg, log, fmt.Errorf("Error - compilation for package tor exited with code 2"))
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(filepath.Join(compilationWorkDir, "triage", util.SanitizeDockerName("tor-tor-"+pkg.Fingerprint)+".tgz"), bundlePath)
+
+	bundle, err := os.Open(bundlePath)
+	if !assert.NoError(err) {
+		return
+	}
+	defer bundle.Close()
+
+	files := map[string]string{}
+	err = util.TargzIterate(bundlePath, bundle, func(reader *tar.Reader, header *tar.Header) error {
+		contents, err := ioutil.ReadAll(reader)
+		files[filepath.Base(header.Name)] = string(contents)
+		return err
+	})
+	assert.NoError(err)
+
+	for _, name := range []string{"SUMMARY.md", "package.yml", "packaging", "compile.sh", "compile.log", "env.txt"} {
+		assert.Contains(files, name)
+	}
+	assert.Equal("compilation-tor > ./configure\ncompilation-tor > make: *** [all] Error 2\n", files["compile.log"])
+	assert.Contains(files["SUMMARY.md"], "# Compilation of tor/tor failed")
+	assert.Contains(files["SUMMARY.md"], "exited with code 2")
+	assert.Contains(files["SUMMARY.md"], "make: *** [all] Error 2")
+	assert.Contains(files["SUMMARY.md"], fmt.Sprintf("- tor/%s %s", pkg.Dependencies[0].Name, pkg.Dependencies[0].Version))
+	assert.Contains(files["package.yml"], "fingerprint: "+pkg.Fingerprint)
+	assert.Contains(files["env.txt"], "fissile-version: 3.14.15")
+}
+
 func genTestCase(args ...string) []*model.Release {
 	var packages []*model.Package
 	release := model.Release{
@@ -803,3 +1001,32 @@ func genTestCase(args ...string) []*model.Release {
 
 	return []*model.Release{&release}
 }
+
+func TestEstimateCompilationSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	archivePath := filepath.Join(workDir, "../test-assets/tarReadTest.tar.gz")
+	info, err := os.Stat(archivePath)
+	if !assert.NoError(err) {
+		return
+	}
+
+	release := &model.Release{Name: "release"}
+	packages := model.Packages{
+		&model.Package{Name: "archived", Release: release, Path: archivePath},
+		&model.Package{Name: "extracted", Release: release},
+	}
+
+	required, err := estimateCompilationSpace(packages)
+	assert.NoError(err)
+	assert.Equal(uint64(info.Size())*compilationSpaceFactor, required)
+
+	packages = append(packages, &model.Package{Name: "missing", Release: release, Path: filepath.Join(workDir, "missing.tgz")})
+	_, err = estimateCompilationSpace(packages)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "Error getting size of package release/missing")
+	}
+}
@@ -0,0 +1,66 @@
+package compilator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/fatih/color"
+)
+
+// explainDirName is the directory below the work dir the fingerprint each
+// package was last compiled with is recorded in, for --explain
+const explainDirName = ".explain"
+
+// SetExplain sets whether the compilator prints why each package is compiled
+// or skipped
+func (c *Compilator) SetExplain(enabled bool) {
+	c.explain = enabled
+}
+
+// packageFingerprintPath returns the path the fingerprint a package was last
+// compiled with is recorded at
+func (c *Compilator) packageFingerprintPath(pkg *model.Package) string {
+	return filepath.Join(c.hostWorkDir, explainDirName, pkg.Release.Name, pkg.Name)
+}
+
+// recordPackageFingerprint records the fingerprint of a package, once it is
+// compiled or found compiled, so later compilations can tell whether it
+// changed. Recording is best effort; compilations don't fail over it.
+func (c *Compilator) recordPackageFingerprint(pkg *model.Package) {
+	path := c.packageFingerprintPath(pkg)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	ioutil.WriteFile(path, []byte(pkg.Fingerprint), 0644)
+}
+
+// explainPackage prints why a package is compiled or skipped: the compiled
+// packages are cached in the work dir by the fingerprint of the package, the
+// hash of its sources
+func (c *Compilator) explainPackage(pkg *model.Package, compiled bool) {
+	if !c.explain {
+		return
+	}
+	name := color.YellowString("%s/%s", pkg.Release.Name, pkg.Name)
+	compiledDir := pkg.GetPackageCompiledDir(c.hostWorkDir)
+
+	if compiled {
+		c.ui.Printf("explain: package %s skipped, the cache has it compiled with fingerprint %s in %s\n", name, pkg.Fingerprint, compiledDir)
+		return
+	}
+
+	reason := "it was never compiled"
+	if last, err := ioutil.ReadFile(c.packageFingerprintPath(pkg)); err == nil {
+		if lastFingerprint := strings.TrimSpace(string(last)); lastFingerprint != pkg.Fingerprint {
+			reason = fmt.Sprintf("its sources changed since it was last compiled with fingerprint %s", lastFingerprint)
+		} else {
+			reason = "it was compiled with this fingerprint before, but was removed from the cache"
+		}
+	}
+	c.ui.Printf("explain: package %s compiled, the cache has nothing for fingerprint %s in %s; %s\n", name, pkg.Fingerprint, compiledDir, reason)
+}
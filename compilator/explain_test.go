@@ -0,0 +1,59 @@
+package compilator
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/hpcloud/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainPackage(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := ioutil.TempDir("", "fissile-test")
+	assert.NoError(err)
+	defer os.RemoveAll(workDir)
+
+	var output bytes.Buffer
+	c := &Compilator{
+		hostWorkDir: workDir,
+		ui:          termui.New(&bytes.Buffer{}, &output, nil),
+		explain:     true,
+	}
+	pkg := &model.Package{
+		Name:        "tor",
+		Fingerprint: "abc",
+		Release:     &model.Release{Name: "tor"},
+	}
+
+	c.explainPackage(pkg, false)
+	assert.Contains(output.String(), "it was never compiled")
+
+	c.recordPackageFingerprint(pkg)
+	output.Reset()
+	c.explainPackage(pkg, true)
+	assert.Contains(output.String(), "skipped, the cache has it compiled with fingerprint abc")
+
+	output.Reset()
+	c.explainPackage(pkg, false)
+	assert.Contains(output.String(), "was removed from the cache")
+
+	pkg.Fingerprint = "def"
+	output.Reset()
+	c.explainPackage(pkg, false)
+	assert.Contains(output.String(), "its sources changed since it was last compiled with fingerprint abc")
+
+	// Fingerprints are recorded without explain too, so that the first
+	// compilation with explain can tell what changed
+	c.explain = false
+	c.recordPackageFingerprint(pkg)
+	recorded, err := ioutil.ReadFile(c.packageFingerprintPath(pkg))
+	if assert.NoError(err) {
+		assert.Equal("def", string(recorded))
+	}
+}
@@ -0,0 +1,204 @@
+package compilator
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hpcloud/fissile/model"
+	"github.com/hpcloud/fissile/scripts/compilation"
+	"github.com/hpcloud/fissile/util"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v2"
+)
+
+// triageLogTail is the number of lines of the compilation log quoted in the
+// summary of a triage bundle; the full log is in the bundle
+const triageLogTail = 40
+
+// colorCodes matches the terminal color codes of the compilation log
+var colorCodes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// triagePackageSpec describes a failed package in its triage bundle
+type triagePackageSpec struct {
+	Name         string                 `yaml:"name"`
+	Version      string                 `yaml:"version"`
+	Fingerprint  string                 `yaml:"fingerprint"`
+	SHA1         string                 `yaml:"sha1"`
+	Release      string                 `yaml:"release"`
+	Dependencies []triageDependencySpec `yaml:"dependencies"`
+}
+
+// triageDependencySpec describes a dependency of a failed package
+type triageDependencySpec struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	Fingerprint string `yaml:"fingerprint"`
+	Release     string `yaml:"release"`
+}
+
+// SetTriageDir sets the directory the triage bundles of failed compilations
+// are written to; none are written if it is empty
+func (c *Compilator) SetTriageDir(triageDir string) {
+	c.triageDir = triageDir
+}
+
+// writeTriageBundle collects what is needed to reproduce the failed
+// compilation of a package into a tarball in the triage directory: an
+// issue-ready summary, the package spec and its dependencies, the packaging
+// and compilation scripts, the compilation log and the environment it ran in.
+// It returns the path of the tarball.
+func (c *Compilator) writeTriageBundle(pkg *model.Package, log []byte, compileErr error) (string, error) {
+	if err := os.MkdirAll(c.triageDir, 0755); err != nil {
+		return "", fmt.Errorf("Error creating triage directory %s: %s", c.triageDir, err.Error())
+	}
+
+	name := util.SanitizeDockerName(fmt.Sprintf("%s-%s-%s", pkg.Release.Name, pkg.Name, pkg.Fingerprint))
+	bundlePath := filepath.Join(c.triageDir, name+".tgz")
+
+	plainLog := colorCodes.ReplaceAll(log, nil)
+
+	spec := triagePackageSpec{
+		Name:         pkg.Name,
+		Version:      pkg.Version,
+		Fingerprint:  pkg.Fingerprint,
+		SHA1:         pkg.SHA1,
+		Release:      fmt.Sprintf("%s/%s", pkg.Release.Name, pkg.Release.Version),
+		Dependencies: []triageDependencySpec{},
+	}
+	for _, dep := range pkg.Dependencies {
+		spec.Dependencies = append(spec.Dependencies, triageDependencySpec{
+			Name:        dep.Name,
+			Version:     dep.Version,
+			Fingerprint: dep.Fingerprint,
+			Release:     fmt.Sprintf("%s/%s", dep.Release.Name, dep.Release.Version),
+		})
+	}
+	specContents, err := yaml.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+
+	files := map[string][]byte{
+		"SUMMARY.md":  c.triageSummary(pkg, plainLog, compileErr),
+		"package.yml": specContents,
+		"compile.log": plainLog,
+		"env.txt":     c.triageEnvironment(),
+	}
+
+	// The packaging script, as extracted from the package archive
+	packaging, err := ioutil.ReadFile(filepath.Join(c.getSourcePackageDir(pkg), pkg.Name, "packaging"))
+	if err == nil {
+		files["packaging"] = packaging
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	compileScript, err := compilation.GetScript(c.baseType, compilation.CompilationScript)
+	if err == nil {
+		files["compile.sh"] = compileScript
+	}
+
+	buf := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	now := time.Now()
+	for _, fileName := range []string{"SUMMARY.md", "package.yml", "packaging", "compile.sh", "compile.log", "env.txt"} {
+		contents, ok := files[fileName]
+		if !ok {
+			continue
+		}
+		header := tar.Header{
+			Name:    filepath.Join(name, fileName),
+			ModTime: now,
+		}
+		if err := util.WriteToTarStream(tarWriter, contents, header); err != nil {
+			return "", err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return "", err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(bundlePath, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("Error writing triage bundle %s: %s", bundlePath, err.Error())
+	}
+
+	return bundlePath, nil
+}
+
+// triageSummary returns the summary of a triage bundle, in markdown, to be
+// pasted into an issue
+func (c *Compilator) triageSummary(pkg *model.Package, log []byte, compileErr error) []byte {
+	summary := new(bytes.Buffer)
+
+	fmt.Fprintf(summary, "# Compilation of %s/%s failed\n\n", pkg.Release.Name, pkg.Name)
+	fmt.Fprintf(summary, "```\n%s\n```\n\n", compileErr.Error())
+
+	fmt.Fprintf(summary, "## Package\n\n")
+	fmt.Fprintf(summary, "- release: %s %s\n", pkg.Release.Name, pkg.Release.Version)
+	fmt.Fprintf(summary, "- package: %s\n", pkg.Name)
+	fmt.Fprintf(summary, "- version: %s\n", pkg.Version)
+	fmt.Fprintf(summary, "- fingerprint: %s\n", pkg.Fingerprint)
+	fmt.Fprintf(summary, "- sha1: %s\n\n", pkg.SHA1)
+
+	fmt.Fprintf(summary, "## Dependencies\n\n")
+	if len(pkg.Dependencies) == 0 {
+		fmt.Fprintf(summary, "none\n")
+	}
+	for _, dep := range pkg.Dependencies {
+		fmt.Fprintf(summary, "- %s/%s %s (%s)\n", dep.Release.Name, dep.Name, dep.Version, dep.Fingerprint)
+	}
+	fmt.Fprintf(summary, "\n")
+
+	fmt.Fprintf(summary, "## Environment\n\n")
+	fmt.Fprintf(summary, "```\n%s```\n\n", c.triageEnvironment())
+
+	lines := strings.Split(strings.TrimRight(string(log), "\n"), "\n")
+	if len(lines) > triageLogTail {
+		lines = lines[len(lines)-triageLogTail:]
+	}
+	fmt.Fprintf(summary, "## Compilation log (last %d lines)\n\n", len(lines))
+	fmt.Fprintf(summary, "```\n%s\n```\n", strings.Join(lines, "\n"))
+
+	return summary.Bytes()
+}
+
+// triageEnvironment returns the environment failed compilations ran in
+func (c *Compilator) triageEnvironment() []byte {
+	env := new(bytes.Buffer)
+
+	fmt.Fprintf(env, "fissile-version: %s\n", c.fissileVersion)
+	fmt.Fprintf(env, "compilation-image: %s\n", c.BaseImageName())
+	fmt.Fprintf(env, "compilation-base: %s\n", c.baseType)
+	fmt.Fprintf(env, "host: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(env, "memory-limit: %d\n", c.limits.Memory)
+	fmt.Fprintf(env, "cpu-shares: %d\n", c.limits.CPUShares)
+
+	return env.Bytes()
+}
+
+// reportTriageBundle writes the triage bundle of a failed compilation and
+// tells the user where it is. Failing to write it is reported, but doesn't
+// replace the compilation error.
+func (c *Compilator) reportTriageBundle(pkg *model.Package, log []byte, compileErr error) {
+	bundlePath, err := c.writeTriageBundle(pkg, log, compileErr)
+	if err != nil {
+		c.ui.Printf("%s: Error writing triage bundle for package %s: %s\n", color.RedString("triage"), pkg.Name, err.Error())
+		return
+	}
+	c.ui.Printf("%s: Attach %s to bug reports about package %s\n", color.YellowString("triage"), color.MagentaString(bundlePath), pkg.Name)
+}
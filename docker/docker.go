@@ -42,10 +42,14 @@ type dockerClient interface {
 	InspectImage(string) (*dockerclient.Image, error)
 	ListImages(dockerclient.ListImagesOptions) ([]dockerclient.APIImages, error)
 	ListVolumes(dockerclient.ListVolumesOptions) ([]dockerclient.Volume, error)
+	Ping() error
+	PullImage(dockerclient.PullImageOptions, dockerclient.AuthConfiguration) error
+	PushImage(dockerclient.PushImageOptions, dockerclient.AuthConfiguration) error
 	RemoveContainer(dockerclient.RemoveContainerOptions) error
 	RemoveImage(string) error
 	RemoveVolume(string) error
 	StartContainer(string, *dockerclient.HostConfig) error
+	TagImage(string, dockerclient.TagImageOptions) error
 	WaitContainer(string) (int, error)
 }
 
@@ -354,6 +358,96 @@ func (d *ImageManager) FindBestImageWithLabels(baseImageName string, labels []st
 	return bestMatch.ID, matchedLabels, nil
 }
 
+// PullImage pulls an image from its registry, writing progress to
+// stdoutWriter. Images can be given by digest, as <repository>@<digest>.
+func (d *ImageManager) PullImage(imageName string, stdoutWriter io.Writer) error {
+	repository, tag := imageName, ""
+	if !strings.Contains(imageName, "@") {
+		repository, tag = dockerclient.ParseRepositoryTag(imageName)
+		if tag == "" {
+			tag = "latest"
+		}
+	}
+
+	err := d.client.PullImage(dockerclient.PullImageOptions{
+		Repository:   repository,
+		Tag:          tag,
+		OutputStream: stdoutWriter,
+	}, dockerclient.AuthConfiguration{})
+	if err != nil {
+		return fmt.Errorf("Error pulling image %s: %s", imageName, err.Error())
+	}
+
+	return nil
+}
+
+// TagImage gives an image another name, replacing the image that had it
+func (d *ImageManager) TagImage(imageName, newName string) error {
+	repository, tag := dockerclient.ParseRepositoryTag(newName)
+	if tag == "" {
+		tag = "latest"
+	}
+
+	err := d.client.TagImage(imageName, dockerclient.TagImageOptions{
+		Repo:  repository,
+		Tag:   tag,
+		Force: true,
+	})
+	if err != nil {
+		return fmt.Errorf("Error tagging image %s as %s: %s", imageName, newName, err.Error())
+	}
+
+	return nil
+}
+
+// PushImage tags an image with a new name and pushes it to the registry of
+// that name, writing progress to stdoutWriter. Credentials for the registry
+// are read from the docker configuration of the user, if it has them.
+func (d *ImageManager) PushImage(imageName, pushName string, stdoutWriter io.Writer) error {
+	if err := d.TagImage(imageName, pushName); err != nil {
+		return err
+	}
+
+	repository, tag := dockerclient.ParseRepositoryTag(pushName)
+	if tag == "" {
+		tag = "latest"
+	}
+
+	var auth dockerclient.AuthConfiguration
+	if configs, err := dockerclient.NewAuthConfigurationsFromDockerCfg(); err == nil {
+		registry := strings.SplitN(repository, "/", 2)[0]
+		auth = configs.Configs[registry]
+	}
+
+	err := d.client.PushImage(dockerclient.PushImageOptions{
+		Name:         repository,
+		Tag:          tag,
+		OutputStream: stdoutWriter,
+	}, auth)
+	if err != nil {
+		return fmt.Errorf("Error pushing image %s: %s", pushName, err.Error())
+	}
+
+	return nil
+}
+
+// Ping checks that the Docker daemon is reachable with the current credentials
+func (d *ImageManager) Ping() error {
+	return d.client.Ping()
+}
+
+// CheckVolumeAccess creates and removes a named volume, to verify that the
+// current user is allowed to manage Docker volumes
+func (d *ImageManager) CheckVolumeAccess(name string) error {
+	if _, err := d.client.CreateVolume(dockerclient.CreateVolumeOptions{Name: name}); err != nil {
+		return fmt.Errorf("Error creating volume %s: %s", name, err.Error())
+	}
+	if err := d.client.RemoveVolume(name); err != nil {
+		return fmt.Errorf("Error removing volume %s: %s", name, err.Error())
+	}
+	return nil
+}
+
 // HasImage determines if the given image already exists in Docker
 func (d *ImageManager) HasImage(imageName string) (bool, error) {
 	if _, err := d.FindImage(imageName); err == ErrImageNotFound {
@@ -406,6 +500,9 @@ type RunInContainerOpts struct {
 	KeepContainer bool
 	StdoutWriter  io.Writer
 	StderrWriter  io.Writer
+	// Resource limits for the container; zero means unlimited
+	Memory    int64 // In bytes
+	CPUShares int64 // Relative weight, see `docker run --cpu-shares`
 }
 
 // RunInContainer will execute a set of commands within a running Docker container
@@ -470,6 +567,8 @@ func (d *ImageManager) RunInContainer(opts RunInContainerOpts) (exitCode int, co
 			Privileged:     false,
 			Binds:          []string{},
 			ReadonlyRootfs: false,
+			Memory:         opts.Memory,
+			CPUShares:      opts.CPUShares,
 		},
 		Name: opts.ContainerName,
 	}
@@ -0,0 +1,447 @@
+// Package erb renders the ERB templates of BOSH jobs without a Ruby
+// interpreter. It only knows the patterns job templates commonly use:
+// property lookups with p and if_p, the spec, conditionals, and a few methods
+// of the values; templates using any other Ruby code fail to render, naming
+// the code that isn't supported.
+package erb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Render renders an ERB template of a BOSH job with the spec of the job, as
+// BOSH passes it to templates; its properties are looked up by p and if_p.
+// The name of the template is used in errors. The template is trimmed like
+// BOSH does, as with the "-" trim mode of ERB.
+func Render(name, template string, spec map[string]interface{}) (string, error) {
+	nodes, err := parse(template)
+	if err != nil {
+		return "", fmt.Errorf("%s:%s", name, err.Error())
+	}
+
+	properties, _ := spec["properties"].(map[string]interface{})
+	ctx := &context{
+		spec:       toOpenStruct(spec),
+		properties: properties,
+		vars:       map[string]interface{}{},
+	}
+
+	var buf bytes.Buffer
+	if err := ctx.render(nodes, &buf); err != nil {
+		return "", fmt.Errorf("%s:%s", name, err.Error())
+	}
+	return buf.String(), nil
+}
+
+// lineError is an error at a line of a template
+type lineError struct {
+	line    int
+	message string
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("%d: %s", e.line, e.message)
+}
+
+func errorf(line int, format string, args ...interface{}) error {
+	return &lineError{line: line, message: fmt.Sprintf(format, args...)}
+}
+
+// unsupported is the error for Ruby code this package can't evaluate
+func unsupported(line int, code string) error {
+	return errorf(line, "unsupported ERB code %q; render the template with ruby instead", strings.TrimSpace(code))
+}
+
+type node interface{}
+
+// textNode is text outside of ERB tags
+type textNode struct {
+	text string
+}
+
+// outputNode is a <%= %> tag
+type outputNode struct {
+	line int
+	expr expr
+}
+
+// statementNode is a <% %> tag evaluating an expression, whose value is dropped
+type statementNode struct {
+	line int
+	expr expr
+}
+
+// ifNode is an if or unless statement, with its elsif and else branches
+type ifNode struct {
+	conditions []expr
+	bodies     [][]node
+	elseBody   []node
+}
+
+// ifPNode is an if_p block, with its else block
+type ifPNode struct {
+	line     int
+	names    []expr
+	params   []string
+	body     []node
+	elseBody []node
+}
+
+// frame is an open block while parsing
+type frame struct {
+	ifNode  *ifNode
+	ifPNode *ifPNode
+	inElse  bool
+	nodes   []node
+	line    int
+}
+
+// add adds a node to the body the frame is parsing
+func (f *frame) add(n node) {
+	switch {
+	case f.ifNode != nil && f.inElse:
+		f.ifNode.elseBody = append(f.ifNode.elseBody, n)
+	case f.ifNode != nil:
+		last := len(f.ifNode.bodies) - 1
+		f.ifNode.bodies[last] = append(f.ifNode.bodies[last], n)
+	case f.ifPNode != nil && f.inElse:
+		f.ifPNode.elseBody = append(f.ifPNode.elseBody, n)
+	case f.ifPNode != nil:
+		f.ifPNode.body = append(f.ifPNode.body, n)
+	default:
+		f.nodes = append(f.nodes, n)
+	}
+}
+
+// parse parses a template into its nodes
+func parse(template string) ([]node, error) {
+	stack := []*frame{{}}
+	current := func() *frame { return stack[len(stack)-1] }
+
+	line := 1
+	rest := template
+	for len(rest) > 0 {
+		start := strings.Index(rest, "<%")
+		if start < 0 {
+			current().add(&textNode{text: rest})
+			break
+		}
+
+		text := rest[:start]
+		rest = rest[start+2:]
+
+		// <%% is a literal <%
+		if strings.HasPrefix(rest, "%") {
+			current().add(&textNode{text: text + "<%"})
+			line += strings.Count(text, "\n")
+			rest = rest[1:]
+			continue
+		}
+
+		// <%- drops the indentation of the tag
+		if strings.HasPrefix(rest, "-") {
+			rest = rest[1:]
+			lineStart := strings.LastIndex(text, "\n") + 1
+			if strings.Trim(text[lineStart:], " \t") == "" {
+				text = text[:lineStart]
+			}
+		}
+		current().add(&textNode{text: text})
+		line += strings.Count(text, "\n")
+
+		end := strings.Index(rest, "%>")
+		if end < 0 {
+			return nil, errorf(line, "unterminated ERB tag")
+		}
+		code := rest[:end]
+		rest = rest[end+2:]
+		tagLine := line
+		line += strings.Count(code, "\n")
+
+		// -%> drops the newline after the tag
+		if strings.HasSuffix(code, "-") {
+			code = code[:len(code)-1]
+			if strings.HasPrefix(rest, "\n") {
+				rest = rest[1:]
+				line++
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(code, "#"):
+			// A comment
+		case strings.HasPrefix(code, "="):
+			e, err := parseExpression(code[1:], tagLine)
+			if err != nil {
+				return nil, err
+			}
+			current().add(&outputNode{line: tagLine, expr: e})
+		default:
+			var err error
+			stack, err = parseStatement(code, tagLine, stack)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(stack) > 1 {
+		return nil, errorf(current().line, "block is never closed with end")
+	}
+	return stack[0].nodes, nil
+}
+
+// parseStatement parses the code of a <% %> tag, which may open, continue
+// or close a block
+func parseStatement(code string, line int, stack []*frame) ([]*frame, error) {
+	current := stack[len(stack)-1]
+	statement := strings.TrimSpace(code)
+	tokens, err := tokenize(statement, line)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return stack, nil
+	}
+
+	keyword := ""
+	if tokens[0].kind == tokenIdent {
+		keyword = tokens[0].text
+	}
+
+	switch {
+	case keyword == "end" && len(tokens) == 1:
+		if len(stack) == 1 {
+			return nil, errorf(line, "end without a block")
+		}
+		return stack[:len(stack)-1], nil
+
+	case keyword == "end" && statement != "":
+		// end.else do
+		p := &parser{tokens: tokens[1:], line: line, code: code}
+		if !p.accept(tokenPunct, ".") || !p.accept(tokenIdent, "else") || !p.accept(tokenIdent, "do") || !p.done() {
+			return nil, unsupported(line, code)
+		}
+		if current.ifPNode == nil || current.inElse {
+			return nil, errorf(line, "end.else without an if_p block")
+		}
+		current.inElse = true
+		return stack, nil
+
+	case keyword == "else" && len(tokens) == 1:
+		if current.ifNode == nil || current.inElse {
+			return nil, errorf(line, "else without an if")
+		}
+		current.inElse = true
+		return stack, nil
+
+	case keyword == "elsif":
+		if current.ifNode == nil || current.inElse {
+			return nil, errorf(line, "elsif without an if")
+		}
+		condition, err := parseCondition(tokens[1:], line, code)
+		if err != nil {
+			return nil, err
+		}
+		current.ifNode.conditions = append(current.ifNode.conditions, condition)
+		current.ifNode.bodies = append(current.ifNode.bodies, nil)
+		return stack, nil
+
+	case keyword == "if" || keyword == "unless":
+		condition, err := parseCondition(tokens[1:], line, code)
+		if err != nil {
+			return nil, err
+		}
+		if keyword == "unless" {
+			condition = &notExpr{operand: condition}
+		}
+		n := &ifNode{conditions: []expr{condition}, bodies: [][]node{nil}}
+		current.add(n)
+		return append(stack, &frame{ifNode: n, line: line}), nil
+
+	case keyword == "if_p":
+		p := &parser{tokens: tokens[1:], line: line, code: code}
+		if !p.accept(tokenPunct, "(") {
+			return nil, unsupported(line, code)
+		}
+		names, err := p.parseArguments(")")
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 || !p.accept(tokenIdent, "do") {
+			return nil, unsupported(line, code)
+		}
+		var params []string
+		if p.accept(tokenPunct, "|") {
+			for !p.accept(tokenPunct, "|") {
+				param := p.next()
+				if param.kind != tokenIdent {
+					return nil, unsupported(line, code)
+				}
+				params = append(params, param.text)
+				p.accept(tokenPunct, ",")
+			}
+		}
+		if !p.done() {
+			return nil, unsupported(line, code)
+		}
+		n := &ifPNode{line: line, names: names, params: params}
+		current.add(n)
+		return append(stack, &frame{ifPNode: n, line: line}), nil
+	}
+
+	e, err := parseExpression(code, line)
+	if err != nil {
+		return nil, err
+	}
+	current.add(&statementNode{line: line, expr: e})
+	return stack, nil
+}
+
+// parseCondition parses the condition of an if, unless or elsif
+func parseCondition(tokens []token, line int, code string) (expr, error) {
+	if len(tokens) > 0 && tokens[len(tokens)-1].kind == tokenIdent && tokens[len(tokens)-1].text == "then" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return nil, unsupported(line, code)
+	}
+	p := &parser{tokens: tokens, line: line, code: code}
+	condition, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, unsupported(line, code)
+	}
+	return condition, nil
+}
+
+// parseExpression parses the code of a tag that is a single expression
+func parseExpression(code string, line int) (expr, error) {
+	tokens, err := tokenize(code, line)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return &literalExpr{}, nil
+	}
+	p := &parser{tokens: tokens, line: line, code: code}
+	e, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, unsupported(line, code)
+	}
+	return e, nil
+}
+
+// context is the state templates are rendered in
+type context struct {
+	spec       openStruct
+	properties map[string]interface{}
+	vars       map[string]interface{} // The parameters of the enclosing if_p blocks
+}
+
+// render renders nodes into the buffer
+func (c *context) render(nodes []node, buf *bytes.Buffer) error {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *textNode:
+			buf.WriteString(n.text)
+
+		case *outputNode:
+			value, err := n.expr.eval(c, n.line)
+			if err != nil {
+				return err
+			}
+			buf.WriteString(toS(value))
+
+		case *statementNode:
+			if _, err := n.expr.eval(c, n.line); err != nil {
+				return err
+			}
+
+		case *ifNode:
+			taken := false
+			for i, condition := range n.conditions {
+				value, err := condition.eval(c, 0)
+				if err != nil {
+					return err
+				}
+				if truthy(value) {
+					if err := c.render(n.bodies[i], buf); err != nil {
+						return err
+					}
+					taken = true
+					break
+				}
+			}
+			if !taken {
+				if err := c.render(n.elseBody, buf); err != nil {
+					return err
+				}
+			}
+
+		case *ifPNode:
+			var values []interface{}
+			found := true
+			for _, nameExpr := range n.names {
+				name, err := nameExpr.eval(c, n.line)
+				if err != nil {
+					return err
+				}
+				nameString, ok := name.(string)
+				if !ok {
+					return errorf(n.line, "if_p needs property names")
+				}
+				value := c.lookupProperty(nameString)
+				if value == nil {
+					found = false
+				}
+				values = append(values, value)
+			}
+			if !found {
+				if err := c.render(n.elseBody, buf); err != nil {
+					return err
+				}
+				continue
+			}
+
+			saved := map[string]interface{}{}
+			for name, value := range c.vars {
+				saved[name] = value
+			}
+			for i, param := range n.params {
+				if i < len(values) {
+					c.vars[param] = values[i]
+				} else {
+					c.vars[param] = nil
+				}
+			}
+			err := c.render(n.body, buf)
+			c.vars = saved
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupProperty returns the value of a property given by its dotted name,
+// or nil if it is not set
+func (c *context) lookupProperty(name string) interface{} {
+	var value interface{} = c.properties
+	for _, key := range strings.Split(name, ".") {
+		parent, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = parent[key]
+	}
+	return value
+}
@@ -0,0 +1,86 @@
+package erb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSpec() map[string]interface{} {
+	return map[string]interface{}{
+		"name":  "myrole",
+		"index": float64(0),
+		"networks": map[string]interface{}{
+			"default": map[string]interface{}{
+				"ip": "127.0.0.1",
+			},
+		},
+		"properties": map[string]interface{}{
+			"tor": map[string]interface{}{
+				"hostname":      "example.onion",
+				"ports":         []interface{}{float64(80), float64(443)},
+				"hidden":        false,
+				"client_keys":   nil,
+				"settings":      map[string]interface{}{"b": "two", "a": float64(1)},
+				"control_token": "<secret & token>",
+			},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		template string
+		expected string
+	}{
+		{`<%= p("tor.hostname") %>`, "example.onion"},
+		{`<%= p('tor.client_keys', "") %>|`, "|"},
+		{`<%= p(["tor.missing", "tor.hostname"]) %>`, "example.onion"},
+		{`<%= p("tor.missing", 9050) %>`, "9050"},
+		{`<%= p("tor.ports").join(",") %> <%= p("tor.ports") %>`, "80,443 [80, 443]"},
+		{`<%= p("tor.settings") %> <%= p("tor.settings").to_json %>`, `{"a"=>1, "b"=>"two"} {"a":1,"b":"two"}`},
+		{`<%= p("tor.control_token").to_json %>`, `"<secret & token>"`},
+		{`<%= p("tor.settings")["b"] %><%= p("tor.ports")[-1] %>`, "two443"},
+		{`<%= name %>/<%= index %> <%= spec.networks.default.ip %> <%= spec.az.nil? %>`, "myrole/0 127.0.0.1 true"},
+		{`<%# a comment %>a<%% b`, "a<% b"},
+		{"<% if_p(\"tor.hostname\", \"tor.ports\") do |host, ports| %>\n<%= host %>:<%= ports[0] %>\n<% end %>\n", "\nexample.onion:80\n\n"},
+		{"<% if_p(\"tor.client_keys\") do |keys| %>\n<%= keys %>\n<% end.else do %>\nnone\n<% end %>\n", "\nnone\n\n"},
+		{"<%- if p(\"tor.hidden\") -%>\n  hidden\n<%- elsif p(\"tor.ports\").include?(443) -%>\n  tls\n<%- else -%>\n  plain\n<%- end -%>\n", "  tls\n"},
+		{`<% unless p("tor.hidden") || !(p("tor.hostname") == "example.onion") %>shown<% end %>`, "shown"},
+		{`<% if p("tor.hostname") != 'example.onion' && true then %>x<% else %>y<% end %>`, "y"},
+		{`<%= p("tor.ports")[0] < 443 %> <%= p("tor.ports").size >= 2 %> <%= index > 0.5 %> <%= 443 <= p("tor.ports")[1] %>`, "true true false true"},
+		{`<% if p("tor.hostname") > "a" && "b" >= "c" then %>x<% else %>y<% end %>`, "y"},
+	} {
+		rendered, err := Render("test.erb", test.template, testSpec())
+		if assert.NoError(err, test.template) {
+			assert.Equal(test.expected, rendered, test.template)
+		}
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		template string
+		expected string
+	}{
+		{"a\n<%= p(\"tor.missing\") %>", "test.erb:2: Can't find property 'tor.missing'"},
+		{"<% if p('tor.hidden') %>\n", "test.erb:1: block is never closed with end"},
+		{"<% end %>", "test.erb:1: end without a block"},
+		{"<%= p('tor.hostname'", "test.erb:1: unterminated ERB tag"},
+		{"\n\n<%= unknown %>", "test.erb:3: undefined local variable or method `unknown'"},
+		{"<% p('tor.ports').each do |port| %><% end %>", `test.erb:1: unsupported ERB code "p('tor.ports').each do |port|"; render the template with ruby instead`},
+		{"<%= p('tor.hostname').sub('a', 'b') %>", "test.erb:1: unsupported method `sub' for String; render the template with ruby instead"},
+		{"<%= \"#{name}\" %>", `test.erb:1: unsupported ERB code "\"#{name}\""; render the template with ruby instead`},
+		{"<%= p('tor.ports')[0] < 'a' %>", "test.erb:1: comparison of Float with String failed"},
+		{"<%= 'a' >= 1 %>", "test.erb:1: comparison of String with 1 failed"},
+		{"<%= spec.az > 1 %>", "test.erb:1: undefined method `>' for nil:NilClass"},
+		{"<%= link('db').address %>", `test.erb:1: unsupported ERB code "link('db').address"; render the template with ruby instead`},
+	} {
+		_, err := Render("test.erb", test.template, testSpec())
+		assert.EqualError(err, test.expected, test.template)
+	}
+}
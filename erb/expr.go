@@ -0,0 +1,730 @@
+package erb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// punctuation lists the operators and delimiters Ruby code may use, longest
+// first
+var punctuation = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "(", ")", "[", "]", ",", ".", "!", "|"}
+
+// tokenize splits Ruby code into tokens
+func tokenize(code string, line int) ([]token, error) {
+	var tokens []token
+	runes := []rune(code)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			if i < len(runes) && (runes[i] == '?' || runes[i] == '!') && (i+1 == len(runes) || runes[i+1] != '=') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i])})
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || (runes[i] == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i])})
+
+		case r == '\'' || r == '"':
+			var text []rune
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					if r == '"' {
+						switch runes[i] {
+						case 'n':
+							text = append(text, '\n')
+							continue
+						case 't':
+							text = append(text, '\t')
+							continue
+						}
+					} else if runes[i] != '\'' && runes[i] != '\\' {
+						text = append(text, '\\')
+					}
+				} else if r == '"' && runes[i] == '#' && i+1 < len(runes) && runes[i+1] == '{' {
+					return nil, unsupported(line, code)
+				}
+				text = append(text, runes[i])
+			}
+			if i == len(runes) {
+				return nil, errorf(line, "unterminated string in %q", strings.TrimSpace(code))
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: string(text)})
+
+		default:
+			found := false
+			for _, punct := range punctuation {
+				if strings.HasPrefix(string(runes[i:]), punct) {
+					tokens = append(tokens, token{kind: tokenPunct, text: punct})
+					i += len([]rune(punct))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, unsupported(line, code)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// parser parses Ruby expressions from tokens
+type parser struct {
+	tokens []token
+	pos    int
+	line   int
+	code   string // The code the tokens are from, for errors
+}
+
+// peek returns the next token without consuming it; its text is empty at the end
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: tokenPunct}
+}
+
+// next consumes the next token
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// accept consumes the next token if it is the given one
+func (p *parser) accept(kind tokenKind, text string) bool {
+	t := p.peek()
+	if p.pos < len(p.tokens) && t.kind == kind && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// done reports whether all tokens have been consumed
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// fail returns the error for the code that can't be parsed
+func (p *parser) fail() error {
+	return unsupported(p.line, p.code)
+}
+
+// parseExpression parses an expression: operands joined by || and &&,
+// negated by !, and compared by ==, !=, <, >, <= and >=
+func (p *parser) parseExpression() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenPunct, "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{operator: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenPunct, "&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{operator: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.accept(tokenPunct, "!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{operand: operand}, nil
+	}
+
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(tokenPunct, operator) {
+			right, err := p.parsePostfix()
+			if err != nil {
+				return nil, err
+			}
+			return &binaryExpr{operator: operator, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// parsePostfix parses an operand with the methods called on it and the
+// indexes taken of it
+func (p *parser) parsePostfix() (expr, error) {
+	e, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept(tokenPunct, "."):
+			method := p.next()
+			if method.kind != tokenIdent {
+				return nil, p.fail()
+			}
+			call := &methodExpr{receiver: e, method: method.text}
+			if p.accept(tokenPunct, "(") {
+				call.args, err = p.parseArguments(")")
+				if err != nil {
+					return nil, err
+				}
+			}
+			e = call
+		case p.accept(tokenPunct, "["):
+			index, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(tokenPunct, "]") {
+				return nil, p.fail()
+			}
+			e = &indexExpr{receiver: e, index: index}
+		default:
+			return e, nil
+		}
+	}
+}
+
+// parsePrimary parses a literal, a variable, a call of p, or a parenthesized
+// expression
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &literalExpr{value: t.text}, nil
+
+	case tokenNumber:
+		if value, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literalExpr{value: value}, nil
+		}
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.fail()
+		}
+		return &literalExpr{value: value}, nil
+
+	case tokenIdent:
+		switch t.text {
+		case "nil":
+			return &literalExpr{}, nil
+		case "true":
+			return &literalExpr{value: true}, nil
+		case "false":
+			return &literalExpr{value: false}, nil
+		case "p":
+			if !p.accept(tokenPunct, "(") {
+				return nil, p.fail()
+			}
+			args, err := p.parseArguments(")")
+			if err != nil {
+				return nil, err
+			}
+			if len(args) < 1 || len(args) > 2 {
+				return nil, errorf(p.line, "p takes a property name and optionally a default")
+			}
+			return &propertyExpr{args: args}, nil
+		}
+		if next := p.peek(); p.pos < len(p.tokens) && next.kind == tokenPunct && next.text == "(" {
+			// Calls of anything but p are not supported
+			return nil, p.fail()
+		}
+		return &variableExpr{name: t.text}, nil
+
+	case tokenPunct:
+		switch t.text {
+		case "(":
+			e, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(tokenPunct, ")") {
+				return nil, p.fail()
+			}
+			return e, nil
+		case "[":
+			elements, err := p.parseArguments("]")
+			if err != nil {
+				return nil, err
+			}
+			return &arrayExpr{elements: elements}, nil
+		}
+	}
+	return nil, p.fail()
+}
+
+// parseArguments parses expressions separated by commas, up to the closing
+// delimiter, which has been opened already
+func (p *parser) parseArguments(closing string) ([]expr, error) {
+	var args []expr
+	if p.accept(tokenPunct, closing) {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(tokenPunct, closing) {
+			return args, nil
+		}
+		if !p.accept(tokenPunct, ",") {
+			return nil, p.fail()
+		}
+	}
+}
+
+// expr is a Ruby expression
+type expr interface {
+	eval(c *context, line int) (interface{}, error)
+}
+
+type literalExpr struct {
+	value interface{}
+}
+
+func (e *literalExpr) eval(c *context, line int) (interface{}, error) {
+	return e.value, nil
+}
+
+type arrayExpr struct {
+	elements []expr
+}
+
+func (e *arrayExpr) eval(c *context, line int) (interface{}, error) {
+	values := make([]interface{}, 0, len(e.elements))
+	for _, element := range e.elements {
+		value, err := element.eval(c, line)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// variableExpr is a parameter of an if_p block, spec, or a field of the spec,
+// which BOSH makes available by name too
+type variableExpr struct {
+	name string
+}
+
+func (e *variableExpr) eval(c *context, line int) (interface{}, error) {
+	if value, ok := c.vars[e.name]; ok {
+		return value, nil
+	}
+	if e.name == "spec" {
+		return c.spec, nil
+	}
+	if value, ok := c.spec[e.name]; ok {
+		return value, nil
+	}
+	return nil, errorf(line, "undefined local variable or method `%s'", e.name)
+}
+
+// propertyExpr is a call of p, returning the first of the given properties
+// that is set, or else the default
+type propertyExpr struct {
+	args []expr
+}
+
+func (e *propertyExpr) eval(c *context, line int) (interface{}, error) {
+	namesValue, err := e.args[0].eval(c, line)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	switch value := namesValue.(type) {
+	case string:
+		names = []string{value}
+	case []interface{}:
+		for _, name := range value {
+			nameString, ok := name.(string)
+			if !ok {
+				return nil, errorf(line, "p needs property names")
+			}
+			names = append(names, nameString)
+		}
+	default:
+		return nil, errorf(line, "p needs property names")
+	}
+
+	for _, name := range names {
+		if value := c.lookupProperty(name); value != nil {
+			return value, nil
+		}
+	}
+	if len(e.args) == 2 {
+		return e.args[1].eval(c, line)
+	}
+	return nil, errorf(line, "Can't find property '%s'", strings.Join(names, "', '"))
+}
+
+type notExpr struct {
+	operand expr
+}
+
+func (e *notExpr) eval(c *context, line int) (interface{}, error) {
+	value, err := e.operand.eval(c, line)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type binaryExpr struct {
+	operator    string
+	left, right expr
+}
+
+func (e *binaryExpr) eval(c *context, line int) (interface{}, error) {
+	left, err := e.left.eval(c, line)
+	if err != nil {
+		return nil, err
+	}
+	switch e.operator {
+	case "||":
+		if truthy(left) {
+			return left, nil
+		}
+		return e.right.eval(c, line)
+	case "&&":
+		if !truthy(left) {
+			return left, nil
+		}
+		return e.right.eval(c, line)
+	}
+
+	right, err := e.right.eval(c, line)
+	if err != nil {
+		return nil, err
+	}
+	switch e.operator {
+	case "==":
+		return inspect(left) == inspect(right), nil
+	case "!=":
+		return inspect(left) != inspect(right), nil
+	}
+
+	order, err := compare(e.operator, left, right)
+	if err != nil {
+		return nil, errorf(line, "%s", err.Error())
+	}
+	switch e.operator {
+	case "<":
+		return order < 0, nil
+	case ">":
+		return order > 0, nil
+	case "<=":
+		return order <= 0, nil
+	}
+	return order >= 0, nil
+}
+
+// compare orders two numbers, or two strings, the way the Ruby operator
+// does: negative if left comes first, zero if they are equal, and positive
+// otherwise
+func compare(operator string, left, right interface{}) (int, error) {
+	if leftString, ok := left.(string); ok {
+		if rightString, ok := right.(string); ok {
+			return strings.Compare(leftString, rightString), nil
+		}
+		return 0, fmt.Errorf("comparison of String with %s failed", inspect(right))
+	}
+	leftNumber, ok := toFloat(left)
+	if !ok {
+		return 0, fmt.Errorf("undefined method `%s' for %s", operator, className(left))
+	}
+	rightNumber, ok := toFloat(right)
+	if !ok {
+		return 0, fmt.Errorf("comparison of %s with %s failed", className(left), className(right))
+	}
+	switch {
+	case leftNumber < rightNumber:
+		return -1, nil
+	case leftNumber > rightNumber:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+type indexExpr struct {
+	receiver, index expr
+}
+
+func (e *indexExpr) eval(c *context, line int) (interface{}, error) {
+	receiver, err := e.receiver.eval(c, line)
+	if err != nil {
+		return nil, err
+	}
+	index, err := e.index.eval(c, line)
+	if err != nil {
+		return nil, err
+	}
+
+	switch receiver := receiver.(type) {
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, nil
+		}
+		return receiver[key], nil
+	case openStruct:
+		key, ok := index.(string)
+		if !ok {
+			return nil, errorf(line, "no implicit conversion of %s into String", className(index))
+		}
+		return receiver[key], nil
+	case []interface{}:
+		i, ok := toInteger(index)
+		if !ok {
+			return nil, errorf(line, "no implicit conversion of %s into Integer", className(index))
+		}
+		if i < 0 {
+			i += int64(len(receiver))
+		}
+		if i < 0 || i >= int64(len(receiver)) {
+			return nil, nil
+		}
+		return receiver[i], nil
+	case nil:
+		return nil, errorf(line, "undefined method `[]' for nil:NilClass")
+	}
+	return nil, errorf(line, "undefined method `[]' for %s", className(receiver))
+}
+
+// methodExpr is a call of a method of a value
+type methodExpr struct {
+	receiver expr
+	method   string
+	args     []expr
+}
+
+func (e *methodExpr) eval(c *context, line int) (interface{}, error) {
+	receiver, err := e.receiver.eval(c, line)
+	if err != nil {
+		return nil, err
+	}
+	var args []interface{}
+	for _, argExpr := range e.args {
+		arg, err := argExpr.eval(c, line)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	// Fields of the spec are methods of its OpenStruct
+	if fields, ok := receiver.(openStruct); ok && len(args) == 0 {
+		if value, ok := fields[e.method]; ok {
+			return value, nil
+		}
+	}
+
+	value, ok, err := callMethod(receiver, e.method, args)
+	if err != nil {
+		return nil, errorf(line, "%s", err.Error())
+	}
+	if !ok {
+		if _, isStruct := receiver.(openStruct); isStruct && len(args) == 0 {
+			// Unset fields of an OpenStruct are nil
+			return nil, nil
+		}
+		return nil, errorf(line, "unsupported method `%s' for %s; render the template with ruby instead", e.method, className(receiver))
+	}
+	return value, nil
+}
+
+// callMethod calls one of the methods of Ruby values this package knows.
+// It reports whether the method is known.
+func callMethod(receiver interface{}, method string, args []interface{}) (interface{}, bool, error) {
+	switch method {
+	case "to_s":
+		return toS(receiver), true, nil
+	case "to_json":
+		return toJSON(receiver), true, nil
+	case "inspect":
+		return inspect(receiver), true, nil
+	case "nil?":
+		return receiver == nil, true, nil
+	case "to_i":
+		switch value := receiver.(type) {
+		case int64:
+			return value, true, nil
+		case float64:
+			return int64(value), true, nil
+		case string:
+			i, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			return i, true, nil
+		case nil:
+			return int64(0), true, nil
+		}
+	case "empty?", "size", "length":
+		var size int
+		switch value := receiver.(type) {
+		case string:
+			size = len([]rune(value))
+		case []interface{}:
+			size = len(value)
+		case map[string]interface{}:
+			size = len(value)
+		default:
+			return nil, false, nil
+		}
+		if method == "empty?" {
+			return size == 0, true, nil
+		}
+		return int64(size), true, nil
+	case "join":
+		values, ok := receiver.([]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		separator := ""
+		if len(args) > 0 {
+			separator = toS(args[0])
+		}
+		parts := make([]string, 0, len(values))
+		for _, value := range values {
+			parts = append(parts, toS(value))
+		}
+		return strings.Join(parts, separator), true, nil
+	case "include?":
+		if len(args) != 1 {
+			return nil, false, nil
+		}
+		switch value := receiver.(type) {
+		case string:
+			return strings.Contains(value, toS(args[0])), true, nil
+		case []interface{}:
+			for _, element := range value {
+				if inspect(element) == inspect(args[0]) {
+					return true, true, nil
+				}
+			}
+			return false, true, nil
+		case map[string]interface{}:
+			key, _ := args[0].(string)
+			_, found := value[key]
+			return found, true, nil
+		}
+	case "keys":
+		if value, ok := receiver.(map[string]interface{}); ok {
+			var keys []interface{}
+			for _, key := range sortedKeys(value) {
+				keys = append(keys, key)
+			}
+			return keys, true, nil
+		}
+	case "upcase", "downcase", "strip":
+		if value, ok := receiver.(string); ok {
+			switch method {
+			case "upcase":
+				return strings.ToUpper(value), true, nil
+			case "downcase":
+				return strings.ToLower(value), true, nil
+			}
+			return strings.TrimSpace(value), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// toInteger returns a number as an integer, if it is one
+func toInteger(value interface{}) (int64, bool) {
+	switch value := value.(type) {
+	case int64:
+		return value, true
+	case float64:
+		if value == float64(int64(value)) {
+			return int64(value), true
+		}
+	}
+	return 0, false
+}
+
+// toFloat returns a number as a float, if it is one
+func toFloat(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case int64:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+// className returns the name of the Ruby class of a value, for errors
+func className(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil:NilClass"
+	case bool:
+		return "Boolean"
+	case string:
+		return "String"
+	case int64:
+		return "Integer"
+	case float64:
+		return "Float"
+	case []interface{}:
+		return "Array"
+	case map[string]interface{}:
+		return "Hash"
+	case openStruct:
+		return "OpenStruct"
+	}
+	return fmt.Sprintf("%T", value)
+}
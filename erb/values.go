@@ -0,0 +1,129 @@
+package erb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// openStruct is a hash of the spec, whose keys are methods, like the
+// OpenStruct BOSH wraps the spec in
+type openStruct map[string]interface{}
+
+// toOpenStruct wraps the hashes of a spec in openStructs, except for its
+// properties, which templates get as hashes
+func toOpenStruct(spec map[string]interface{}) openStruct {
+	var convert func(value interface{}) interface{}
+	convert = func(value interface{}) interface{} {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			fields := make(openStruct, len(value))
+			for key, field := range value {
+				fields[key] = convert(field)
+			}
+			return fields
+		case []interface{}:
+			values := make([]interface{}, 0, len(value))
+			for _, element := range value {
+				values = append(values, convert(element))
+			}
+			return values
+		}
+		return value
+	}
+
+	fields := convert(spec).(openStruct)
+	if properties, ok := spec["properties"]; ok {
+		fields["properties"] = properties
+	}
+	return fields
+}
+
+// truthy reports whether Ruby considers a value true: anything but nil and false
+func truthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return true
+}
+
+// toS converts a value to a string like its Ruby to_s method does
+func toS(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	}
+	return inspect(value)
+}
+
+// inspect converts a value to a string like its Ruby inspect method does.
+// Hashes list their keys in order, as Go doesn't keep the order they were
+// given in.
+func inspect(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(value)
+	case string:
+		return strconv.Quote(value)
+	case int:
+		return strconv.Itoa(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		// JSON doesn't tell integers from floats; whole numbers are integers
+		if i, ok := toInteger(value); ok {
+			return strconv.FormatInt(i, 10)
+		}
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case []interface{}:
+		elements := make([]string, 0, len(value))
+		for _, element := range value {
+			elements = append(elements, inspect(element))
+		}
+		return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+	case map[string]interface{}:
+		entries := make([]string, 0, len(value))
+		for _, key := range sortedKeys(value) {
+			entries = append(entries, fmt.Sprintf("%s=>%s", strconv.Quote(key), inspect(value[key])))
+		}
+		return fmt.Sprintf("{%s}", strings.Join(entries, ", "))
+	case openStruct:
+		fields := make([]string, 0, len(value))
+		for _, key := range sortedKeys(value) {
+			fields = append(fields, fmt.Sprintf(" %s=%s", key, inspect(value[key])))
+		}
+		return fmt.Sprintf("#<OpenStruct%s>", strings.Join(fields, ","))
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// toJSON converts a value to JSON like its Ruby to_json method does
+func toJSON(value interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return toS(value)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// sortedKeys returns the keys of a hash in order
+func sortedKeys(value map[string]interface{}) []string {
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,187 @@
+// Package golden renders the outputs fissile generates for a role manifest
+// and compares them against checked-in golden files, so that changes in the
+// generated artifacts show up as test failures. It can be used by downstream
+// projects to test their own role manifests.
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hpcloud/fissile/kube"
+	"github.com/hpcloud/fissile/model"
+)
+
+// Update makes Check rewrite the golden files instead of comparing against
+// them; pass -update to `go test` to regenerate fixtures.
+var Update = flag.Bool("update", false, "Rewrite golden files instead of comparing against them")
+
+// Outputs maps the path of a generated file, relative to the golden
+// directory, to its contents
+type Outputs map[string][]byte
+
+// Renderer renders one kind of output for a role manifest
+type Renderer func(rolesManifest *model.RoleManifest) (Outputs, error)
+
+// KubeRenderer returns a Renderer for the Kubernetes configuration written by
+// `fissile build kube`, using the same layout of one file per role grouped by
+// role type
+func KubeRenderer(settings *kube.ExportSettings) Renderer {
+	return func(rolesManifest *model.RoleManifest) (Outputs, error) {
+		outputs := Outputs{}
+		generators := kube.NewGenerators()
+
+		for _, role := range rolesManifest.Roles {
+			var buf bytes.Buffer
+			if err := kube.WriteRoleConfig(role, settings, generators, &buf); err != nil {
+				return nil, fmt.Errorf("Error generating kube config for role %s: %s", role.Name, err.Error())
+			}
+			outputs[filepath.Join("kube", string(role.Type), fmt.Sprintf("%s.yml", role.Name))] = buf.Bytes()
+		}
+
+		return outputs, nil
+	}
+}
+
+// Render runs all renderers on a role manifest and merges their outputs
+func Render(rolesManifest *model.RoleManifest, renderers ...Renderer) (Outputs, error) {
+	result := Outputs{}
+
+	for _, renderer := range renderers {
+		outputs, err := renderer(rolesManifest)
+		if err != nil {
+			return nil, err
+		}
+		for path, contents := range outputs {
+			if _, ok := result[path]; ok {
+				return nil, fmt.Errorf("Output %s was rendered more than once", path)
+			}
+			result[path] = contents
+		}
+	}
+
+	return result, nil
+}
+
+// Check compares the outputs against the golden files in dir. Files in dir
+// that are not part of the outputs are reported as stale. If Update is set,
+// dir is replaced with the outputs instead.
+func Check(dir string, outputs Outputs) error {
+	if *Update {
+		return write(dir, outputs)
+	}
+
+	var problems []string
+
+	paths := make([]string, 0, len(outputs))
+	for path := range outputs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		expected, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if os.IsNotExist(err) {
+			problems = append(problems, fmt.Sprintf("%s: missing golden file", path))
+			continue
+		} else if err != nil {
+			return err
+		}
+		if diff := firstDifference(string(expected), string(outputs[path])); diff != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", path, diff))
+		}
+	}
+
+	existing, err := listFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range existing {
+		if _, ok := outputs[path]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: stale golden file", path))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Outputs differ from golden files in %s (rerun the tests with -update to accept the changes):\n  %s",
+			dir, strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// write replaces the contents of dir with the outputs
+func write(dir string, outputs Outputs) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	for path, contents := range outputs {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(fullPath, contents, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listFiles returns the paths of all files below dir, relative to dir
+func listFiles(dir string) ([]string, error) {
+	var result []string
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		result = append(result, relPath)
+		return nil
+	})
+
+	return result, err
+}
+
+// firstDifference describes the first line that differs between expected and
+// actual, or returns an empty string if they are the same
+func firstDifference(expected, actual string) string {
+	if expected == actual {
+		return ""
+	}
+
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var expectedLine, actualLine string
+		if i < len(expectedLines) {
+			expectedLine = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			actualLine = actualLines[i]
+		}
+		if expectedLine != actualLine {
+			return fmt.Sprintf("line %d: expected %q, got %q", i+1, expectedLine, actualLine)
+		}
+	}
+
+	return "contents differ"
+}
@@ -0,0 +1,103 @@
+package golden
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hpcloud/fissile/kube"
+	"github.com/hpcloud/fissile/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// goldenManifests are the test-assets role manifests whose outputs are
+// checked against test-assets/golden/<manifest name>
+var goldenManifests = []string{
+	"exposed-ports.yml",
+	"kube-generators.yml",
+	"volumes.yml",
+}
+
+func TestGoldenFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	if !assert.NoError(err) {
+		return
+	}
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathBoshCache := filepath.Join(releasePath, "bosh-cache")
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	settings := &kube.ExportSettings{
+		Repository:   "fissile",
+		Registry:     "docker.example.com",
+		Organization: "golden",
+	}
+
+	for _, manifestName := range goldenManifests {
+		manifestPath := filepath.Join(workDir, "../test-assets/role-manifests", manifestName)
+		rolesManifest, err := model.LoadRoleManifest(manifestPath, []*model.Release{release})
+		if !assert.NoError(err, manifestName) {
+			continue
+		}
+
+		outputs, err := Render(rolesManifest, KubeRenderer(settings))
+		if !assert.NoError(err, manifestName) {
+			continue
+		}
+
+		goldenName := strings.TrimSuffix(manifestName, filepath.Ext(manifestName))
+		goldenDir := filepath.Join(workDir, "../test-assets/golden", goldenName)
+		assert.NoError(Check(goldenDir, outputs))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "fissile-golden")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	update := *Update
+	*Update = false
+	defer func() { *Update = update }()
+
+	outputs := Outputs{
+		"kube/bosh/role.yml": []byte("---\nkind: Deployment\n"),
+	}
+
+	err = Check(dir, outputs)
+	assert.Contains(err.Error(), "kube/bosh/role.yml: missing golden file")
+
+	assert.NoError(write(dir, outputs))
+	assert.NoError(Check(dir, outputs))
+
+	err = Check(dir, Outputs{
+		"kube/bosh/role.yml": []byte("---\nkind: StatefulSet\n"),
+	})
+	assert.Contains(err.Error(), `kube/bosh/role.yml: line 2: expected "kind: Deployment", got "kind: StatefulSet"`)
+
+	err = Check(dir, Outputs{})
+	assert.Contains(err.Error(), "kube/bosh/role.yml: stale golden file")
+}
+
+func TestRenderDuplicateOutputs(t *testing.T) {
+	assert := assert.New(t)
+
+	renderer := func(*model.RoleManifest) (Outputs, error) {
+		return Outputs{"a.yml": []byte{}}, nil
+	}
+
+	_, err := Render(&model.RoleManifest{}, renderer, renderer)
+	assert.EqualError(err, "Output a.yml was rendered more than once")
+}
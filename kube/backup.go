@@ -0,0 +1,208 @@
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hpcloud/fissile/model"
+	"k8s.io/client-go/pkg/api/resource"
+	meta "k8s.io/client-go/pkg/api/unversioned"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	extra "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/runtime"
+)
+
+const (
+	// BBRActionLabel is the label with the action of backup and restore jobs
+	BBRActionLabel = "skiff-bbr-action"
+	// BBRRoleLabel is the label with the role of backup and restore jobs. The
+	// jobs don't use RoleNameLabel; services of the role must not select them.
+	BBRRoleLabel = "skiff-bbr-role"
+
+	// bbrArtifactsVolume is the name of the artifacts volume in the pods
+	bbrArtifactsVolume = "bbr-artifacts"
+)
+
+// BBRArtifactsClaimName returns the name of the persistent volume claim that
+// holds the backup artifacts of a role
+func BBRArtifactsClaimName(role *model.Role) string {
+	return fmt.Sprintf("%s-bbr-artifacts", role.Name)
+}
+
+// BBRJobName returns the name of the job running a BBR action for a role
+func BBRJobName(role *model.Role, action string) string {
+	return fmt.Sprintf("%s-%s", role.Name, action)
+}
+
+// WorkloadKind returns the kind of the object that runs the pods of a role
+func WorkloadKind(role *model.Role) string {
+	if needsStatefulSet(role) {
+		return "StatefulSet"
+	}
+	return "Deployment"
+}
+
+// NewBBRArtifactsClaim creates the persistent volume claim for the backup
+// artifacts of a role
+func NewBBRArtifactsClaim(role *model.Role) *apiv1.PersistentVolumeClaim {
+	return &apiv1.PersistentVolumeClaim{
+		TypeMeta: meta.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+		},
+		ObjectMeta: apiv1.ObjectMeta{
+			Name: BBRArtifactsClaimName(role),
+			Annotations: map[string]string{
+				"volume.beta.kubernetes.io/storage-class": "persistent",
+			},
+			Labels: map[string]string{
+				RoleNameLabel: role.Name,
+			},
+		},
+		Spec: apiv1.PersistentVolumeClaimSpec{
+			AccessModes: []apiv1.PersistentVolumeAccessMode{
+				apiv1.ReadWriteOnce,
+			},
+			Resources: apiv1.ResourceRequirements{
+				Requests: apiv1.ResourceList{
+					apiv1.ResourceStorage: *resource.NewScaledQuantity(int64(role.Run.Backup.Size), resource.Giga),
+				},
+			},
+		},
+	}
+}
+
+// NewBBRJob creates a job that runs the BBR scripts for the given action in a
+// pod based on the pod template of the role. The pod mounts the artifacts
+// volume, and the volumes of the first pod of the role so the scripts can get
+// to its data.
+func NewBBRJob(role *model.Role, podTemplate apiv1.PodTemplateSpec, action string) (*extra.Job, error) {
+	if role.Run == nil || role.Run.Backup == nil {
+		return nil, fmt.Errorf("Role %s does not have a backup", role.Name)
+	}
+	if !model.IsBBRAction(action) {
+		return nil, fmt.Errorf("Unknown backup action %s", action)
+	}
+	if len(podTemplate.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("Role %s has no containers to run %s in", role.Name, action)
+	}
+
+	name := BBRJobName(role, action)
+
+	podTemplate.ObjectMeta.Name = name
+	podTemplate.ObjectMeta.Labels = map[string]string{
+		BBRRoleLabel:   role.Name,
+		BBRActionLabel: action,
+	}
+	podTemplate.Spec.RestartPolicy = apiv1.RestartPolicyNever
+
+	// The scripts run to completion and serve nothing; ports and probes would
+	// only get in the way
+	container := podTemplate.Spec.Containers[0]
+	container.Name = name
+	container.Args = []string{"--bbr", action}
+	container.Ports = nil
+	container.LivenessProbe = nil
+	container.ReadinessProbe = nil
+	container.VolumeMounts = append(container.VolumeMounts, apiv1.VolumeMount{
+		Name:      bbrArtifactsVolume,
+		MountPath: model.BBRArtifactsPath,
+	})
+	podTemplate.Spec.Containers = []apiv1.Container{container}
+
+	// Keep the volumes of the pod template that aren't claims; those are
+	// replaced with the claims of the first pod below
+	var volumes []apiv1.Volume
+	for _, volume := range podTemplate.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			volumes = append(volumes, volume)
+		}
+	}
+	volumes = append(volumes,
+		apiv1.Volume{
+			Name: bbrArtifactsVolume,
+			VolumeSource: apiv1.VolumeSource{
+				PersistentVolumeClaim: &apiv1.PersistentVolumeClaimVolumeSource{
+					ClaimName: BBRArtifactsClaimName(role),
+				},
+			},
+		},
+	)
+	for _, claim := range getVolumeClaims(role) {
+		volumes = append(volumes, apiv1.Volume{
+			Name: claim.Name,
+			VolumeSource: apiv1.VolumeSource{
+				PersistentVolumeClaim: &apiv1.PersistentVolumeClaimVolumeSource{
+					// Claims created from the templates of a StatefulSet are
+					// named <template>-<stateful set>-<ordinal>
+					ClaimName: fmt.Sprintf("%s-%s-0", claim.Name, role.Name),
+				},
+			},
+		})
+	}
+	podTemplate.Spec.Volumes = volumes
+
+	return &extra.Job{
+		TypeMeta: meta.TypeMeta{
+			APIVersion: "extensions/v1beta1",
+			Kind:       "Job",
+		},
+		ObjectMeta: apiv1.ObjectMeta{
+			Name:   name,
+			Labels: podTemplate.ObjectMeta.Labels,
+		},
+		Spec: extra.JobSpec{
+			Template: podTemplate,
+		},
+	}, nil
+}
+
+// NewBBRCronJob creates the CronJob that runs the scheduled backups of a role
+func NewBBRCronJob(role *model.Role, settings *ExportSettings) (*CronJob, error) {
+	podTemplate, err := NewPodTemplate(role, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := NewBBRJob(role, podTemplate, model.BBRActionBackup)
+	if err != nil {
+		return nil, err
+	}
+
+	cronJob := newCronJob(job.ObjectMeta.Name, role.Run.Backup.Schedule, job.Spec)
+	pauseCronJob(cronJob, role, settings)
+	return cronJob, nil
+}
+
+// PodTemplateFromWorkload extracts the pod template from the JSON of a
+// deployed Deployment or StatefulSet
+func PodTemplateFromWorkload(data []byte) (apiv1.PodTemplateSpec, error) {
+	var workload struct {
+		Spec struct {
+			Template apiv1.PodTemplateSpec `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &workload); err != nil {
+		return apiv1.PodTemplateSpec{}, fmt.Errorf("Error reading workload: %s", err.Error())
+	}
+
+	return workload.Spec.Template, nil
+}
+
+// bbrArtifactsClaimGenerator creates the artifact volume claims for bosh roles
+// with a backup
+type bbrArtifactsClaimGenerator struct{}
+
+// Kind implements Generator
+func (g *bbrArtifactsClaimGenerator) Kind() string {
+	return "PersistentVolumeClaim"
+}
+
+// Generate implements Generator
+func (g *bbrArtifactsClaimGenerator) Generate(role *model.Role, settings *ExportSettings) ([]runtime.Object, error) {
+	if role.Type != model.RoleTypeBosh || role.Run.Backup == nil {
+		return nil, nil
+	}
+
+	return []runtime.Object{NewBBRArtifactsClaim(role)}, nil
+}
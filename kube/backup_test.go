@@ -0,0 +1,54 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+)
+
+func TestNewBBRJob(t *testing.T) {
+	assert := assert.New(t)
+	manifest := loadTestRoleManifest(assert, "kube-generators.yml")
+	if manifest == nil {
+		return
+	}
+	role := manifest.LookupRole("clustered-role")
+	if !assert.NotNil(role) {
+		return
+	}
+
+	podTemplate, err := NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+
+	job, err := NewBBRJob(role, podTemplate, model.BBRActionRestore)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal("clustered-role-restore", job.Name)
+	spec := job.Spec.Template.Spec
+	assert.Equal(apiv1.RestartPolicyNever, spec.RestartPolicy)
+	assert.NotContains(job.Spec.Template.Labels, RoleNameLabel)
+	if assert.Len(spec.Containers, 1) {
+		assert.Equal([]string{"--bbr", "restore"}, spec.Containers[0].Args)
+		assert.Nil(spec.Containers[0].ReadinessProbe)
+		assert.Contains(spec.Containers[0].VolumeMounts, apiv1.VolumeMount{
+			Name:      "bbr-artifacts",
+			MountPath: model.BBRArtifactsPath,
+		})
+	}
+	if assert.Len(spec.Volumes, 2) {
+		assert.Equal("clustered-role-bbr-artifacts", spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+		assert.Equal("persistent-volume-clustered-role-0", spec.Volumes[1].PersistentVolumeClaim.ClaimName)
+	}
+
+	_, err = NewBBRJob(role, podTemplate, "explode")
+	assert.EqualError(err, "Unknown backup action explode")
+
+	_, err = NewBBRJob(manifest.LookupRole("deployment-role"), podTemplate, model.BBRActionBackup)
+	assert.EqualError(err, "Role deployment-role does not have a backup")
+}
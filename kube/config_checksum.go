@@ -0,0 +1,88 @@
+package kube
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/hpcloud/fissile/model"
+)
+
+// ConfigChecksumAnnotation is the annotation of the pod templates of roles
+// with the checksum of the generated ConfigMaps and Secrets the pods read.
+// Pods only read those when they start, so the checksum changes the pod
+// template when they change, and Kubernetes replaces the pods.
+const ConfigChecksumAnnotation = "skiff-config-checksum"
+
+// getConfigChecksum returns the checksum of the generated ConfigMaps and
+// Secrets the pods of a role read: the connection info of the roles it links
+// to, and the certificates of its TLS ports that are not read from existing
+// secrets. Roles reading none get an empty checksum. The ConfigMaps and
+// Secrets managed outside of fissile are not included, nor are the ones of
+// kinds the settings don't write.
+func getConfigChecksum(role *model.Role, settings *ExportSettings) (string, error) {
+	if role.Run == nil {
+		return "", nil
+	}
+
+	objects := map[string]map[string]string{}
+	for _, link := range role.Run.Links {
+		if !settings.Kinds.Includes("ConfigMap") {
+			break
+		}
+		target := role.LinkedRole(link)
+		if target == nil || target.Run == nil || target.Run.ConnectionInfo == nil {
+			return "", fmt.Errorf("Role %s links to role %s, which has no connection info", role.Name, link.Role)
+		}
+		configMap, err := NewConnectionInfoConfigMap(target, settings)
+		if err != nil {
+			return "", err
+		}
+		objects["ConfigMap/"+configMap.Name] = configMap.Data
+	}
+
+	for _, port := range getTLSPorts(role) {
+		if !settings.Kinds.Includes("Secret") {
+			break
+		}
+		certificate := role.LookupVariable(port.TLS.Certificate)
+		if certificate != nil && certificate.Secret != nil {
+			continue
+		}
+		secret, err := NewTLSSecret(role, port, settings)
+		if err != nil {
+			return "", err
+		}
+		data := map[string]string{}
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+		objects["Secret/"+secret.Name] = data
+	}
+
+	if len(objects) == 0 {
+		return "", nil
+	}
+
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hasher := sha256.New()
+	for _, name := range names {
+		keys := make([]string, 0, len(objects[name]))
+		for key := range objects[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(hasher, "%s\n", name)
+		for _, key := range keys {
+			fmt.Fprintf(hasher, "%s=%q\n", key, objects[name][key])
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
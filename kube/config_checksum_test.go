@@ -0,0 +1,74 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigChecksum(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := serviceTestLoadRole(assert, "connection-info.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{})
+	if assert.NoError(err) {
+		assert.NotContains(pod.Annotations, ConfigChecksumAnnotation, "Checksums are off by default")
+	}
+
+	pod, err = NewPodTemplate(role, &ExportSettings{ConfigChecksums: true})
+	if !assert.NoError(err) {
+		return
+	}
+	checksum := pod.Annotations[ConfigChecksumAnnotation]
+	assert.Len(checksum, 64)
+
+	// The connection info of the linked role changes with the discovery strategy
+	discovery, err := NewDiscovery(DiscoveryConsul, "")
+	if !assert.NoError(err) {
+		return
+	}
+	pod, err = NewPodTemplate(role, &ExportSettings{ConfigChecksums: true, Discovery: discovery})
+	if assert.NoError(err) {
+		assert.NotEqual(checksum, pod.Annotations[ConfigChecksumAnnotation])
+	}
+
+	kinds, err := NewKindFilter(nil, []string{"ConfigMap"})
+	if !assert.NoError(err) {
+		return
+	}
+	pod, err = NewPodTemplate(role, &ExportSettings{ConfigChecksums: true, Kinds: kinds})
+	if assert.NoError(err) {
+		assert.NotContains(pod.Annotations, ConfigChecksumAnnotation, "ConfigMaps that aren't written aren't included")
+	}
+
+	pod, err = NewPodTemplate(manifest.LookupRole("mysql"), &ExportSettings{ConfigChecksums: true})
+	if assert.NoError(err) {
+		assert.NotContains(pod.Annotations, ConfigChecksumAnnotation, "Roles reading no generated configuration have no checksum")
+	}
+}
+
+func TestConfigChecksumTLS(t *testing.T) {
+	assert := assert.New(t)
+	_, role := statefulSetTestLoadManifest(assert, "tls.yml")
+	if role == nil {
+		return
+	}
+
+	checksumOf := func(certificate string) string {
+		pod, err := NewPodTemplate(role, &ExportSettings{
+			ConfigChecksums: true,
+			Defaults:        map[string]string{"HTTPS_CERT": certificate, "HTTPS_KEY": "key"},
+		})
+		if !assert.NoError(err) {
+			return ""
+		}
+		return pod.Annotations[ConfigChecksumAnnotation]
+	}
+
+	assert.NotEqual(checksumOf("certificate"), checksumOf("renewed certificate"))
+	assert.Equal(checksumOf("certificate"), checksumOf("certificate"))
+}
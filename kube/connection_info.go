@@ -0,0 +1,145 @@
+package kube
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hpcloud/fissile/model"
+
+	meta "k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+)
+
+// ConnectionInfoName returns the name of the ConfigMap with the connection
+// info of a role
+func ConnectionInfoName(role *model.Role) string {
+	return fmt.Sprintf("%s-connection-info", role.Name)
+}
+
+// NewConnectionInfoConfigMap creates the ConfigMap with the connection info of
+// a role: its host, as found by the discovery strategy of the settings, the
+// port of its service, its scheme, and for every credential the secret and key
+// holding it
+func NewConnectionInfoConfigMap(role *model.Role, settings *ExportSettings) (*v1.ConfigMap, error) {
+	info := role.Run.ConnectionInfo
+	port := role.ConnectionInfoPort()
+	if port == nil {
+		return nil, fmt.Errorf("Role %s: connection info uses unknown port %s", role.Name, info.Port)
+	}
+	portNumber, _, err := model.ParsePortRange(port.External, port.Name, "external")
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := discoveryOf(settings).RoleHost(role)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{
+		"host": host,
+		"port": strconv.Itoa(int(portNumber)),
+	}
+	if info.Scheme != "" {
+		data["scheme"] = info.Scheme
+	}
+	for _, name := range info.CredentialNames() {
+		variable := role.LookupVariable(info.Credentials[name])
+		if variable == nil || variable.Secret == nil {
+			return nil, fmt.Errorf("Role %s: connection info credential %s should read variable %s from a secret", role.Name, name, info.Credentials[name])
+		}
+		data[fmt.Sprintf("credentials.%s.secret", name)] = variable.Secret.Name
+		data[fmt.Sprintf("credentials.%s.key", name)] = variable.Secret.Key
+	}
+
+	return &v1.ConfigMap{
+		TypeMeta: meta.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name: ConnectionInfoName(role),
+			Labels: map[string]string{
+				RoleNameLabel: role.Name,
+			},
+		},
+		Data: data,
+	}, nil
+}
+
+// getLinkEnvVars returns the environment variables with the connection info
+// of the roles a role links to. The address is read from the ConfigMap of the
+// linked role, and the credentials from the secrets it references.
+func getLinkEnvVars(role *model.Role) ([]v1.EnvVar, error) {
+	if role.Run == nil {
+		return nil, nil
+	}
+
+	var result []v1.EnvVar
+	for _, link := range role.Run.Links {
+		target := role.LinkedRole(link)
+		if target == nil || target.Run == nil || target.Run.ConnectionInfo == nil {
+			return nil, fmt.Errorf("Role %s links to role %s, which has no connection info", role.Name, link.Role)
+		}
+		info := target.Run.ConnectionInfo
+		prefix := link.EnvPrefix()
+
+		keys := []string{"host", "port"}
+		if info.Scheme != "" {
+			keys = append(keys, "scheme")
+		}
+		for _, key := range keys {
+			result = append(result, v1.EnvVar{
+				Name: fmt.Sprintf("%s_%s", prefix, model.LinkEnvName(key)),
+				ValueFrom: &v1.EnvVarSource{
+					ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: ConnectionInfoName(target)},
+						Key:                  key,
+					},
+				},
+			})
+		}
+
+		for _, name := range info.CredentialNames() {
+			variable := target.LookupVariable(info.Credentials[name])
+			if variable == nil || variable.Secret == nil {
+				return nil, fmt.Errorf("Role %s: connection info credential %s should read variable %s from a secret", target.Name, name, info.Credentials[name])
+			}
+			result = append(result, v1.EnvVar{
+				Name: fmt.Sprintf("%s_%s", prefix, model.LinkEnvName(name)),
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: variable.Secret.Name},
+						Key:                  variable.Secret.Key,
+					},
+				},
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// connectionInfoGenerator creates the ConfigMaps with the connection info of
+// roles
+type connectionInfoGenerator struct{}
+
+// Kind implements Generator
+func (g *connectionInfoGenerator) Kind() string {
+	return "ConfigMap"
+}
+
+// Generate implements Generator
+func (g *connectionInfoGenerator) Generate(role *model.Role, settings *ExportSettings) ([]runtime.Object, error) {
+	if role.Run == nil || role.Run.ConnectionInfo == nil {
+		return nil, nil
+	}
+
+	configMap, err := NewConnectionInfoConfigMap(role, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return []runtime.Object{configMap}, nil
+}
@@ -0,0 +1,82 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestConnectionInfoConfigMap(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, _ := serviceTestLoadRole(assert, "connection-info.yml")
+	if manifest == nil {
+		return
+	}
+	mysql := manifest.LookupRole("mysql")
+	if !assert.NotNil(mysql) {
+		return
+	}
+
+	objects, err := (&connectionInfoGenerator{}).Generate(mysql, &ExportSettings{})
+	if !assert.NoError(err) || !assert.Len(objects, 1) {
+		return
+	}
+	configMap := objects[0].(*v1.ConfigMap)
+	assert.Equal("mysql-connection-info", configMap.Name)
+	assert.Equal(map[string]string{
+		"host":                        "mysql",
+		"port":                        "3306",
+		"scheme":                      "mysql",
+		"credentials.password.secret": "db-creds",
+		"credentials.password.key":    "password",
+		"credentials.username.secret": "db-creds",
+		"credentials.username.key":    "username",
+	}, configMap.Data)
+
+	discovery, err := NewDiscovery(DiscoveryConsul, "")
+	if !assert.NoError(err) {
+		return
+	}
+	configMap, err = NewConnectionInfoConfigMap(mysql, &ExportSettings{Discovery: discovery})
+	if assert.NoError(err) {
+		assert.Equal("mysql.service.consul", configMap.Data["host"])
+	}
+
+	objects, err = (&connectionInfoGenerator{}).Generate(manifest.LookupRole("myrole"), &ExportSettings{})
+	if assert.NoError(err) {
+		assert.Empty(objects)
+	}
+}
+
+func TestConnectionInfoLinkEnvVars(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := serviceTestLoadRole(assert, "connection-info.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+
+	env, err := getEnvVars(role, nil)
+	if !assert.NoError(err) {
+		return
+	}
+
+	byName := map[string]*v1.EnvVarSource{}
+	for _, envVar := range env {
+		byName[envVar.Name] = envVar.ValueFrom
+	}
+	for name, key := range map[string]string{"DB_HOST": "host", "DB_PORT": "port", "DB_SCHEME": "scheme"} {
+		if assert.NotNil(byName[name], name) && assert.NotNil(byName[name].ConfigMapKeyRef, name) {
+			assert.Equal("mysql-connection-info", byName[name].ConfigMapKeyRef.Name)
+			assert.Equal(key, byName[name].ConfigMapKeyRef.Key)
+		}
+	}
+	for name, key := range map[string]string{"DB_USERNAME": "username", "DB_PASSWORD": "password"} {
+		if assert.NotNil(byName[name], name) && assert.NotNil(byName[name].SecretKeyRef, name) {
+			assert.Equal("db-creds", byName[name].SecretKeyRef.Name)
+			assert.Equal(key, byName[name].SecretKeyRef.Key)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package kube
+
+import (
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+	meta "k8s.io/client-go/pkg/api/unversioned"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	extra "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/runtime"
+)
+
+// CronJob is a batch/v2alpha1 CronJob. The vendored Kubernetes client does
+// not have a versioned type for it, so this only has the fields we use.
+type CronJob struct {
+	meta.TypeMeta    `json:",inline"`
+	apiv1.ObjectMeta `json:"metadata,omitempty"`
+	Spec             CronJobSpec `json:"spec"`
+}
+
+// CronJobSpec describes when and how the jobs of a CronJob are created
+type CronJobSpec struct {
+	Schedule                   string          `json:"schedule"`
+	Suspend                    *bool           `json:"suspend,omitempty"`
+	ConcurrencyPolicy          string          `json:"concurrencyPolicy,omitempty"`
+	SuccessfulJobsHistoryLimit *int32          `json:"successfulJobsHistoryLimit,omitempty"`
+	FailedJobsHistoryLimit     *int32          `json:"failedJobsHistoryLimit,omitempty"`
+	JobTemplate                JobTemplateSpec `json:"jobTemplate"`
+}
+
+// JobTemplateSpec describes the jobs created by a CronJob
+type JobTemplateSpec struct {
+	apiv1.ObjectMeta `json:"metadata,omitempty"`
+	Spec             extra.JobSpec `json:"spec"`
+}
+
+// NewCronJob creates a new CronJob for the given scheduled bosh-task role
+func NewCronJob(role *model.Role, settings *ExportSettings) (*CronJob, error) {
+	job, err := NewJob(role, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	cronJob := newCronJob(role.Name, role.Run.Schedule, job.Spec)
+	pauseCronJob(cronJob, role, settings)
+	return cronJob, nil
+}
+
+// newCronJob creates a CronJob running jobs with the given spec on a schedule
+func newCronJob(name string, schedule *model.RoleRunSchedule, jobSpec extra.JobSpec) *CronJob {
+	// The API uses capitalized policy names, e.g. "Forbid"
+	concurrencyPolicy := strings.ToUpper(schedule.ConcurrencyPolicy[:1]) + schedule.ConcurrencyPolicy[1:]
+
+	return &CronJob{
+		TypeMeta: meta.TypeMeta{
+			APIVersion: "batch/v2alpha1",
+			Kind:       "CronJob",
+		},
+		ObjectMeta: apiv1.ObjectMeta{
+			Name: name,
+		},
+		Spec: CronJobSpec{
+			Schedule:                   schedule.Cron,
+			ConcurrencyPolicy:          concurrencyPolicy,
+			SuccessfulJobsHistoryLimit: schedule.SuccessfulJobsHistoryLimit,
+			FailedJobsHistoryLimit:     schedule.FailedJobsHistoryLimit,
+			JobTemplate: JobTemplateSpec{
+				ObjectMeta: apiv1.ObjectMeta{
+					Name: name,
+				},
+				Spec: jobSpec,
+			},
+		},
+	}
+}
+
+// cronJobGenerator creates the CronJobs for bosh-task roles with a schedule,
+// and for bosh roles with scheduled backups
+type cronJobGenerator struct{}
+
+// Kind implements Generator
+func (g *cronJobGenerator) Kind() string {
+	return "CronJob"
+}
+
+// Generate implements Generator
+func (g *cronJobGenerator) Generate(role *model.Role, settings *ExportSettings) ([]runtime.Object, error) {
+	switch {
+	case role.Type == model.RoleTypeBoshTask && role.Run.Schedule != nil:
+		cronJob, err := NewCronJob(role, settings)
+		if err != nil {
+			return nil, err
+		}
+		return []runtime.Object{cronJob}, nil
+
+	case role.Type == model.RoleTypeBosh && role.Run.Backup != nil && role.Run.Backup.Schedule != nil:
+		cronJob, err := NewBBRCronJob(role, settings)
+		if err != nil {
+			return nil, err
+		}
+		return []runtime.Object{cronJob}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,40 @@
+package kube
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+)
+
+const (
+	// DependsOnAnnotation lists the roles that are deployed before a role
+	DependsOnAnnotation = "skiff-depends-on"
+	// DeployWaveAnnotation is the wave of the deploy script a role is deployed
+	// in, numbered from 1 like the script does
+	DeployWaveAnnotation = "skiff-deploy-wave"
+)
+
+// getDeployOrderAnnotations returns the annotations of the objects of a role
+// telling tools other than the deploy script what the role has to be deployed
+// after. Roles of the first wave have none, nor do manual tasks, which are
+// not deployed.
+func getDeployOrderAnnotations(role *model.Role) (map[string]string, error) {
+	if role.IsManualTask() {
+		return nil, nil
+	}
+
+	wave, err := role.DeployWave()
+	if err != nil {
+		return nil, err
+	}
+	if wave == 0 {
+		return nil, nil
+	}
+
+	annotations := map[string]string{DeployWaveAnnotation: strconv.Itoa(wave + 1)}
+	if dependencies := role.RoleDependencies(); len(dependencies) > 0 {
+		annotations[DependsOnAnnotation] = strings.Join(dependencies, ",")
+	}
+	return annotations, nil
+}
@@ -0,0 +1,50 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeployOrderAnnotations(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	if !assert.NoError(err) {
+		return
+	}
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	release, err := model.NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+	if !assert.NoError(err) {
+		return
+	}
+	manifest, err := model.LoadRoleManifest(filepath.Join(workDir, "../test-assets/role-manifests/deploy-order.yml"), []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	deployment, _, err := NewDeployment(manifest.LookupRole("myrole"), &ExportSettings{})
+	if assert.NoError(err) {
+		assert.Empty(deployment.Annotations, "Roles without dependencies have no annotations")
+	}
+
+	deployment, _, err = NewDeployment(manifest.LookupRole("api"), &ExportSettings{})
+	if assert.NoError(err) {
+		assert.Equal(map[string]string{
+			DependsOnAnnotation:  "myrole,nats",
+			DeployWaveAnnotation: "2",
+		}, deployment.Annotations)
+	}
+
+	job, err := NewJob(manifest.LookupRole("smoke-tests"), &ExportSettings{})
+	if assert.NoError(err) {
+		assert.Equal(map[string]string{
+			DependsOnAnnotation:  "api",
+			DeployWaveAnnotation: "3",
+		}, job.Annotations)
+	}
+}
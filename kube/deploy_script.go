@@ -0,0 +1,166 @@
+package kube
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/hpcloud/fissile/model"
+)
+
+// DeployScriptName is the name of the script written next to the role
+// configurations that applies them in dependency order
+const DeployScriptName = "deploy.sh"
+
+// deployScriptTemplate applies the configurations of the roles wave by wave.
+// It first checks that the required ConfigMaps and Secrets mounted by the
+// roles exist, and creates the optional ones that don't. After each wave it
+// waits for the pods of its roles to be ready, and for its tasks to finish,
+// before moving on to the next one; tasks that stop on failure end the
+// deployment when they fail. Manual tasks are not in any wave, so they are
+// left for the operator to run.
+var deployScriptTemplate = template.Must(template.New("deploy").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+	"waits": func(wave []deployScriptRole) bool {
+		for _, role := range wave {
+			if role.Check != "" {
+				return true
+			}
+		}
+		return false
+	},
+	"join": strings.Join,
+}).Parse(`#!/bin/bash
+# Generated by fissile; applies the role configurations in dependency order.
+# Set KUBECTL, NAMESPACE and TIMEOUT (in seconds, per wave) to change them.
+
+set -o errexit -o nounset
+
+KUBECTL="${KUBECTL:-kubectl}"
+NAMESPACE="${NAMESPACE:-}"
+TIMEOUT="${TIMEOUT:-600}"
+
+cd "$(dirname "${0}")"
+
+kube() {
+    if [ -n "${NAMESPACE}" ]; then
+        "${KUBECTL}" --namespace "${NAMESPACE}" "$@"
+    else
+        "${KUBECTL}" "$@"
+    fi
+}
+
+# Wait until all given checks succeed, or the timeout expires
+wait_for() {
+    local deadline=$(( $(date +%s) + TIMEOUT ))
+    local check
+    for check in "$@"; do
+        until ${check}; do
+            if [ "$(date +%s)" -ge "${deadline}" ]; then
+                echo "Timed out waiting for ${check#* }" >&2
+                exit 1
+            fi
+            sleep 5
+        done
+    done
+}
+
+# The pods of a role are ready when there are some and none is not ready
+pods_ready() {
+    local ready
+    ready="$(kube get pods --selector {{ .roleLabel }}="${1}" --output 'jsonpath={.items[*].status.containerStatuses[*].ready}')"
+    [ -n "${ready}" ] && [[ " ${ready} " != *" false "* ]]
+}
+
+job_succeeded() {
+    local succeeded
+    succeeded="$(kube get job "${1}" --output 'jsonpath={.status.succeeded}')"
+    [ "${succeeded:-0}" -gt 0 ]
+}
+{{- if .stopOnFailure }}
+
+# Like job_succeeded, but ends the deployment once the job failed
+job_succeeded_or_stop() {
+    local failed
+    failed="$(kube get job "${1}" --output 'jsonpath={.status.failed}')"
+    if [ "${failed:-0}" -gt 0 ]; then
+        echo "Task ${1} failed" >&2
+        exit 1
+    fi
+    job_succeeded "${1}"
+}
+{{- end }}
+{{- if .externalObjects }}
+
+# ConfigMaps and Secrets managed outside of fissile, mounted by the roles
+{{- range .externalObjects }}
+{{- if .Optional }}
+kube get {{ .Kind }} {{ .Name }} >/dev/null 2>&1 || kube create {{ .Kind }}{{ if eq .Kind "secret" }} generic{{ end }} {{ .Name }}
+{{- else }}
+kube get {{ .Kind }} {{ .Name }} >/dev/null || { echo "Missing {{ .Kind }} {{ .Name }}, needed by {{ join .Roles ", " }}" >&2; exit 1; }
+{{- end }}
+{{- end }}
+{{- end }}
+{{ range $index, $wave := .waves }}
+echo "Deploying wave {{ inc $index }}:{{ range $wave }} {{ .Name }}{{ end }}"
+{{- range $wave }}
+kube apply --filename {{ .Path }}
+{{- end }}
+{{- if $wave | waits }}
+wait_for{{ range $wave }}{{ if .Check }} "{{ .Check }} {{ .Name }}"{{ end }}{{ end }}
+{{- end }}
+{{ end -}}
+`))
+
+// deployScriptRole is a role as applied by the deploy script
+type deployScriptRole struct {
+	Name  string
+	Path  string // Configuration file, relative to the script
+	Check string // Function telling if the role is up; empty to not wait
+}
+
+// WriteDeployScript writes a script that applies the configurations of the
+// roles wave by wave, as returned by model.RoleManifest.DeployWaves. Roles are
+// expected in <role type>/<role name>.yml next to the script. Paused roles are
+// applied without waiting for them.
+func WriteDeployScript(waves []model.Roles, paused map[string]bool, writer io.Writer) error {
+	var scriptWaves [][]deployScriptRole
+	var roles model.Roles
+	stopOnFailure := false
+	for _, wave := range waves {
+		roles = append(roles, wave...)
+		var scriptWave []deployScriptRole
+		for _, role := range wave {
+			scriptRole := deployScriptRole{
+				Name: role.Name,
+				Path: filepath.ToSlash(filepath.Join(string(role.Type), fmt.Sprintf("%s.yml", role.Name))),
+			}
+			switch {
+			case paused[role.Name]:
+				// Paused roles have no pods to wait for
+			case role.IsLongRunning():
+				scriptRole.Check = "pods_ready"
+			case role.Run != nil && role.Run.Schedule != nil:
+				// Scheduled tasks run later on their own
+			case role.HasTag(model.RoleTagStopOnFailure):
+				scriptRole.Check = "job_succeeded_or_stop"
+				stopOnFailure = true
+			default:
+				scriptRole.Check = "job_succeeded"
+			}
+			scriptWave = append(scriptWave, scriptRole)
+		}
+		if len(scriptWave) > 0 {
+			scriptWaves = append(scriptWaves, scriptWave)
+		}
+	}
+
+	return deployScriptTemplate.Execute(writer, map[string]interface{}{
+		"roleLabel":       RoleNameLabel,
+		"externalObjects": model.ExternalObjects(roles),
+		"stopOnFailure":   stopOnFailure,
+		"waves":           scriptWaves,
+	})
+}
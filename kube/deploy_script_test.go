@@ -0,0 +1,128 @@
+package kube
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDeployScript(t *testing.T) {
+	assert := assert.New(t)
+
+	waves := []model.Roles{
+		{
+			{Name: "mysql", Type: model.RoleTypeBosh},
+			{Name: "setup", Type: model.RoleTypeBoshTask, Run: &model.RoleRun{}},
+		},
+		{},
+		{
+			{Name: "api", Type: model.RoleTypeBosh},
+			{Name: "cleanup", Type: model.RoleTypeBoshTask, Run: &model.RoleRun{Schedule: &model.RoleRunSchedule{Cron: "@daily"}}},
+		},
+		{
+			{Name: "nightly", Type: model.RoleTypeBoshTask, Run: &model.RoleRun{Schedule: &model.RoleRunSchedule{Cron: "@daily"}}},
+		},
+	}
+
+	var script bytes.Buffer
+	if !assert.NoError(WriteDeployScript(waves, nil, &script)) {
+		return
+	}
+
+	assert.Contains(script.String(), `--selector skiff-role-name="${1}"`)
+	assert.Contains(script.String(), `
+echo "Deploying wave 1: mysql setup"
+kube apply --filename bosh/mysql.yml
+kube apply --filename bosh-task/setup.yml
+wait_for "pods_ready mysql" "job_succeeded setup"
+
+echo "Deploying wave 2: api cleanup"
+kube apply --filename bosh/api.yml
+kube apply --filename bosh-task/cleanup.yml
+wait_for "pods_ready api"
+
+echo "Deploying wave 3: nightly"
+kube apply --filename bosh-task/nightly.yml
+`)
+	assert.NotContains(script.String(), "wave 4")
+	assert.NotContains(script.String(), "managed outside of fissile")
+	assert.NotContains(script.String(), "job_succeeded_or_stop")
+}
+
+func TestWriteDeployScriptPausedRoles(t *testing.T) {
+	assert := assert.New(t)
+
+	waves := []model.Roles{
+		{
+			{Name: "mysql", Type: model.RoleTypeBosh},
+			{Name: "setup", Type: model.RoleTypeBoshTask, Run: &model.RoleRun{}},
+		},
+		{
+			{Name: "api", Type: model.RoleTypeBosh},
+		},
+	}
+
+	var script bytes.Buffer
+	if !assert.NoError(WriteDeployScript(waves, map[string]bool{"setup": true, "api": true}, &script)) {
+		return
+	}
+
+	assert.Contains(script.String(), `
+echo "Deploying wave 1: mysql setup"
+kube apply --filename bosh/mysql.yml
+kube apply --filename bosh-task/setup.yml
+wait_for "pods_ready mysql"
+
+echo "Deploying wave 2: api"
+kube apply --filename bosh/api.yml
+`)
+	assert.NotContains(script.String(), "job_succeeded setup")
+	assert.NotContains(script.String(), "pods_ready api")
+}
+
+func TestWriteDeployScriptExternalObjects(t *testing.T) {
+	assert := assert.New(t)
+
+	waves := []model.Roles{
+		{
+			{Name: "api", Type: model.RoleTypeBosh, Run: &model.RoleRun{ExternalMounts: []*model.RoleRunExternalMount{
+				{ConfigMap: "corporate-ca", Path: "/etc/ssl/corporate"},
+				{Secret: "ldap", Path: "/etc/ldap", Optional: true},
+			}}},
+		},
+	}
+
+	var script bytes.Buffer
+	if !assert.NoError(WriteDeployScript(waves, nil, &script)) {
+		return
+	}
+
+	assert.Contains(script.String(), `
+# ConfigMaps and Secrets managed outside of fissile, mounted by the roles
+kube get configmap corporate-ca >/dev/null || { echo "Missing configmap corporate-ca, needed by api" >&2; exit 1; }
+kube get secret ldap >/dev/null 2>&1 || kube create secret generic ldap
+
+echo "Deploying wave 1: api"
+`)
+}
+
+func TestWriteDeployScriptStopOnFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	waves := []model.Roles{
+		{
+			{Name: "migrate", Type: model.RoleTypeBoshTask, Tags: []string{model.RoleTagStopOnFailure}, Run: &model.RoleRun{}},
+		},
+	}
+
+	var script bytes.Buffer
+	if !assert.NoError(WriteDeployScript(waves, nil, &script)) {
+		return
+	}
+
+	assert.Contains(script.String(), "job_succeeded_or_stop() {")
+	assert.Contains(script.String(), `wait_for "job_succeeded_or_stop migrate"`)
+}
@@ -1,15 +1,18 @@
 package kube
 
 import (
+	"fmt"
+
 	"github.com/hpcloud/fissile/model"
 
 	meta "k8s.io/client-go/pkg/api/unversioned"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
 	extra "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/runtime"
 )
 
 // NewDeployment creates a Deployment for the given role, and its attached service
-func NewDeployment(role *model.Role, settings *ExportSettings) (*extra.Deployment, *apiv1.Service, error) {
+func NewDeployment(role *model.Role, settings *ExportSettings) (*extra.Deployment, *Service, error) {
 
 	podTemplate, err := NewPodTemplate(role, settings)
 	if err != nil {
@@ -21,6 +24,17 @@ func NewDeployment(role *model.Role, settings *ExportSettings) (*extra.Deploymen
 		return nil, nil, err
 	}
 
+	annotations, err := getDeployOrderAnnotations(role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	strategy, err := getDeploymentStrategy(role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replicas := getReplicas(role, settings)
 	return &extra.Deployment{
 		TypeMeta: meta.TypeMeta{
 			APIVersion: "extensions/v1beta1",
@@ -31,17 +45,58 @@ func NewDeployment(role *model.Role, settings *ExportSettings) (*extra.Deploymen
 			Labels: map[string]string{
 				RoleNameLabel: role.Name,
 			},
+			Annotations: addPausedAnnotation(annotations, role, settings),
 		},
 		Spec: extra.DeploymentSpec{
-			Replicas: &role.Run.Scaling.Min,
+			Replicas: &replicas,
 			Selector: &meta.LabelSelector{
 				MatchLabels: map[string]string{RoleNameLabel: role.Name},
 			},
 			Template: podTemplate,
+			Strategy: strategy,
 		},
 	}, svc, nil
 }
 
+// getDeploymentStrategy returns the strategy replacing the pods of the
+// Deployment of a role. Deployments can't pause after the first pod, so they
+// can't have canaries.
+func getDeploymentStrategy(role *model.Role) (extra.DeploymentStrategy, error) {
+	switch role.Run.UpdateStrategy {
+	case model.UpdateStrategyRolling:
+		return extra.DeploymentStrategy{Type: extra.RollingUpdateDeploymentStrategyType}, nil
+	case model.UpdateStrategyRecreate:
+		return extra.DeploymentStrategy{Type: extra.RecreateDeploymentStrategyType}, nil
+	case model.UpdateStrategyCanary:
+		return extra.DeploymentStrategy{}, fmt.Errorf("Role %s has the %s update strategy, but Deployments can't pause after the first pod; only roles with a StatefulSet can have it",
+			role.Name, model.UpdateStrategyCanary)
+	}
+	return extra.DeploymentStrategy{}, nil
+}
+
+// deploymentGenerator creates the Deployments for long running roles that do not need
+// a StatefulSet
+type deploymentGenerator struct{}
+
+// Kind implements Generator
+func (g *deploymentGenerator) Kind() string {
+	return "Deployment"
+}
+
+// Generate implements Generator
+func (g *deploymentGenerator) Generate(role *model.Role, settings *ExportSettings) ([]runtime.Object, error) {
+	if !role.IsLongRunning() || needsStatefulSet(role) {
+		return nil, nil
+	}
+
+	deployment, _, err := NewDeployment(role, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return []runtime.Object{deployment}, nil
+}
+
 //metadata:
 //  name: wordpress-mysql
 //  labels:
@@ -0,0 +1,55 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+	extra "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+func TestDeploymentStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := statefulSetTestLoadManifest(assert, "exposed-ports.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+
+	samples := []struct {
+		desc     string
+		strategy string
+		expected extra.DeploymentStrategy
+		err      string
+	}{
+		{
+			desc: "Kubernetes picks the strategy by default",
+		},
+		{
+			desc:     "Rolling updates",
+			strategy: model.UpdateStrategyRolling,
+			expected: extra.DeploymentStrategy{Type: extra.RollingUpdateDeploymentStrategyType},
+		},
+		{
+			desc:     "Recreate replaces all pods at once",
+			strategy: model.UpdateStrategyRecreate,
+			expected: extra.DeploymentStrategy{Type: extra.RecreateDeploymentStrategyType},
+		},
+		{
+			desc:     "Deployments can't pause for canaries",
+			strategy: model.UpdateStrategyCanary,
+			err:      "Role myrole has the canary update strategy, but Deployments can't pause after the first pod; only roles with a StatefulSet can have it",
+		},
+	}
+
+	for _, sample := range samples {
+		role.Run.UpdateStrategy = sample.strategy
+		deployment, _, err := NewDeployment(role, &ExportSettings{})
+		if sample.err != "" {
+			assert.EqualError(err, sample.err, sample.desc)
+		} else if assert.NoError(err, sample.desc) {
+			assert.Equal(sample.expected, deployment.Spec.Strategy, sample.desc)
+		}
+	}
+}
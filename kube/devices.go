@@ -0,0 +1,66 @@
+package kube
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+
+	"k8s.io/client-go/pkg/api/resource"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// deviceVolumeName returns the name of the volume for a host device node;
+// /dev/net/tun becomes dev-net-tun
+func deviceVolumeName(device *model.RoleRunDevice) string {
+	name := strings.ToLower(strings.Trim(device.Path, "/"))
+	return strings.NewReplacer("/", "-", "_", "-", ".", "-").Replace(name)
+}
+
+// getDeviceVolumes returns the host path volumes for the device nodes of a role
+func getDeviceVolumes(role *model.Role) []v1.Volume {
+	var result []v1.Volume
+	for _, device := range role.Run.Devices {
+		if device.IsDevicePlugin() {
+			continue
+		}
+		result = append(result, v1.Volume{
+			Name: deviceVolumeName(device),
+			VolumeSource: v1.VolumeSource{
+				HostPath: &v1.HostPathVolumeSource{
+					Path: device.Path,
+				},
+			},
+		})
+	}
+	return result
+}
+
+// getDeviceVolumeMounts returns the mounts of the device nodes of a role, at
+// the same path as on the host
+func getDeviceVolumeMounts(role *model.Role) []v1.VolumeMount {
+	var result []v1.VolumeMount
+	for _, device := range role.Run.Devices {
+		if device.IsDevicePlugin() {
+			continue
+		}
+		result = append(result, v1.VolumeMount{
+			Name:      deviceVolumeName(device),
+			MountPath: device.Path,
+		})
+	}
+	return result
+}
+
+// addExtendedResources adds the extended resources of a role, including
+// those of device plugins, to the resource limits of its container. Extended
+// resources can't be overcommitted, so Kubernetes only accepts them as limits
+// (the request defaults to the limit).
+func addExtendedResources(role *model.Role, resources *v1.ResourceRequirements) {
+	for name, count := range role.Run.GetExtendedResources() {
+		if resources.Limits == nil {
+			resources.Limits = v1.ResourceList{}
+		}
+		resources.Limits[v1.ResourceName(name)] = resource.MustParse(fmt.Sprintf("%d", count))
+	}
+}
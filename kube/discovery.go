@@ -0,0 +1,105 @@
+package kube
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+)
+
+// These are the service discovery strategies
+const (
+	DiscoveryKube   = "kube"   // The Kubernetes service of the role
+	DiscoveryConsul = "consul" // The Consul service of the role, <role>.service.consul
+	DiscoveryStatic = "static" // A fixed host per role, e.g. mysql=10.0.0.5
+)
+
+// DiscoveryStrategies lists the valid service discovery strategies
+var DiscoveryStrategies = []string{DiscoveryKube, DiscoveryConsul, DiscoveryStatic}
+
+// Discovery computes the addresses roles are reached at, for the connection
+// info of the roles and for the roles linking to them
+type Discovery interface {
+	// Name returns the name of the strategy
+	Name() string
+	// RoleHost returns the host clients of a role connect to
+	RoleHost(role *model.Role) (string, error)
+}
+
+// NewDiscovery returns the service discovery strategy with the given name;
+// the static strategy reads the hosts of the roles from a comma separated
+// list of role=host pairs
+func NewDiscovery(name, staticHosts string) (Discovery, error) {
+	switch name {
+	case DiscoveryKube, "":
+		return kubeDiscovery{}, nil
+	case DiscoveryConsul:
+		return consulDiscovery{}, nil
+	case DiscoveryStatic:
+		hosts := staticDiscovery{}
+		for _, pair := range strings.Split(staticHosts, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+				return nil, fmt.Errorf("Invalid static host '%s', expected <role>=<host>", pair)
+			}
+			hosts[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+		return hosts, nil
+	}
+	return nil, fmt.Errorf("Invalid discovery strategy '%s', expected one of %s", name, strings.Join(DiscoveryStrategies, ", "))
+}
+
+// discoveryOf returns the service discovery strategy of the settings
+func discoveryOf(settings *ExportSettings) Discovery {
+	if settings == nil || settings.Discovery == nil {
+		return kubeDiscovery{}
+	}
+	return settings.Discovery
+}
+
+// kubeDiscovery reaches roles through their Kubernetes service, which is named
+// after the role and resolves in the namespace of the clients
+type kubeDiscovery struct{}
+
+// Name implements Discovery
+func (d kubeDiscovery) Name() string {
+	return DiscoveryKube
+}
+
+// RoleHost implements Discovery
+func (d kubeDiscovery) RoleHost(role *model.Role) (string, error) {
+	return role.Name, nil
+}
+
+// consulDiscovery reaches roles through the Consul service of the same name
+type consulDiscovery struct{}
+
+// Name implements Discovery
+func (d consulDiscovery) Name() string {
+	return DiscoveryConsul
+}
+
+// RoleHost implements Discovery
+func (d consulDiscovery) RoleHost(role *model.Role) (string, error) {
+	return fmt.Sprintf("%s.service.consul", role.Name), nil
+}
+
+// staticDiscovery reaches roles at fixed hosts, by role name
+type staticDiscovery map[string]string
+
+// Name implements Discovery
+func (d staticDiscovery) Name() string {
+	return DiscoveryStatic
+}
+
+// RoleHost implements Discovery
+func (d staticDiscovery) RoleHost(role *model.Role) (string, error) {
+	host, ok := d[role.Name]
+	if !ok {
+		return "", fmt.Errorf("Role %s has no static host", role.Name)
+	}
+	return host, nil
+}
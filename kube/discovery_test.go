@@ -0,0 +1,51 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoveryRoleHost(t *testing.T) {
+	assert := assert.New(t)
+	role := &model.Role{Name: "mysql"}
+
+	samples := []struct {
+		name        string
+		staticHosts string
+		host        string
+		err         string
+	}{
+		{name: "", host: "mysql"},
+		{name: DiscoveryKube, host: "mysql"},
+		{name: DiscoveryConsul, host: "mysql.service.consul"},
+		{name: DiscoveryStatic, staticHosts: "nats=10.0.0.6, mysql=10.0.0.5", host: "10.0.0.5"},
+		{name: DiscoveryStatic, staticHosts: "nats=10.0.0.6", err: "Role mysql has no static host"},
+	}
+
+	for _, sample := range samples {
+		discovery, err := NewDiscovery(sample.name, sample.staticHosts)
+		if !assert.NoError(err, sample.name) {
+			continue
+		}
+		host, err := discovery.RoleHost(role)
+		if sample.err == "" {
+			assert.NoError(err, sample.name)
+			assert.Equal(sample.host, host, sample.name)
+		} else {
+			assert.EqualError(err, sample.err, sample.name)
+		}
+	}
+}
+
+func TestNewDiscoveryInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewDiscovery("etcd", "")
+	assert.EqualError(err, "Invalid discovery strategy 'etcd', expected one of kube, consul, static")
+
+	_, err = NewDiscovery(DiscoveryStatic, "mysql")
+	assert.EqualError(err, "Invalid static host 'mysql', expected <role>=<host>")
+}
@@ -7,4 +7,9 @@ type ExportSettings struct {
 	Registry        string
 	Organization    string
 	UseMemoryLimits bool
+	ConfigChecksums bool            // Annotate pod templates with the checksum of the configuration they read
+	Kinds           *KindFilter     // Kinds of objects to write; nil for all
+	Discovery       Discovery       // How roles find the roles they link to; nil for Kubernetes services
+	PausedRoles     map[string]bool // Roles generated without any pods; see PausedAnnotation
+	Platform        PlatformMapper  // Translates the platform hints of the roles; nil for none
 }
@@ -0,0 +1,49 @@
+package kube
+
+import (
+	"fmt"
+
+	"github.com/hpcloud/fissile/model"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// externalMountVolumeName returns the name of the volume for an external
+// mount of a role, by its position; object names may not be valid volume names
+func externalMountVolumeName(index int) string {
+	return fmt.Sprintf("external-mount-%d", index)
+}
+
+// getExternalMountVolumes returns the volumes for the ConfigMaps and Secrets
+// mounted by a role
+func getExternalMountVolumes(role *model.Role) []v1.Volume {
+	var result []v1.Volume
+	for i, mount := range role.Run.ExternalMounts {
+		volume := v1.Volume{Name: externalMountVolumeName(i)}
+		if mount.Kind() == model.ExternalMountSecret {
+			volume.VolumeSource.Secret = &v1.SecretVolumeSource{
+				SecretName: mount.Secret,
+			}
+		} else {
+			volume.VolumeSource.ConfigMap = &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: mount.ConfigMap},
+			}
+		}
+		result = append(result, volume)
+	}
+	return result
+}
+
+// getExternalMountVolumeMounts returns the read-only mounts of the ConfigMaps
+// and Secrets mounted by a role
+func getExternalMountVolumeMounts(role *model.Role) []v1.VolumeMount {
+	var result []v1.VolumeMount
+	for i, mount := range role.Run.ExternalMounts {
+		result = append(result, v1.VolumeMount{
+			Name:      externalMountVolumeName(i),
+			MountPath: mount.Path,
+			ReadOnly:  true,
+		})
+	}
+	return result
+}
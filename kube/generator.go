@@ -0,0 +1,66 @@
+package kube
+
+import (
+	"io"
+
+	"github.com/hpcloud/fissile/model"
+
+	"k8s.io/client-go/pkg/runtime"
+)
+
+// Generator creates the Kubernetes objects of a single kind for a role
+type Generator interface {
+	// Kind returns the kind of the objects the generator creates
+	Kind() string
+	// Generate returns the objects for the given role; roles that do not need
+	// objects of this kind get an empty list
+	Generate(role *model.Role, settings *ExportSettings) ([]runtime.Object, error)
+}
+
+// NewGenerators returns the generators for all supported kinds, in the order
+// their objects are written out
+func NewGenerators() []Generator {
+	return []Generator{
+		&tlsSecretGenerator{},
+		&connectionInfoGenerator{},
+		&jobGenerator{},
+		&cronJobGenerator{},
+		&statefulSetGenerator{},
+		&deploymentGenerator{},
+		&serviceGenerator{},
+		&bbrArtifactsClaimGenerator{},
+	}
+}
+
+// GenerateRoleObjects runs the generators on a role and returns all the
+// objects they created, of the kinds selected by the settings
+func GenerateRoleObjects(role *model.Role, settings *ExportSettings, generators []Generator) ([]runtime.Object, error) {
+	var result []runtime.Object
+
+	for _, generator := range generators {
+		objects, err := generator.Generate(role, settings)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, objects...)
+	}
+
+	return settings.Kinds.Filter(result), nil
+}
+
+// WriteRoleConfig generates all the objects for a role and writes them to a
+// specified writer
+func WriteRoleConfig(role *model.Role, settings *ExportSettings, generators []Generator, writer io.Writer) error {
+	objects, err := GenerateRoleObjects(role, settings, generators)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		if err := WriteYamlConfig(object, writer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
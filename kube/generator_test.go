@@ -0,0 +1,81 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/pkg/runtime"
+)
+
+func TestGeneratorKinds(t *testing.T) {
+	assert := assert.New(t)
+	manifest := loadTestRoleManifest(assert, "kube-generators.yml")
+	if manifest == nil {
+		return
+	}
+
+	settings := &ExportSettings{Repository: "fissile"}
+
+	expected := map[string]map[string]int{
+		"deployment-role": {"Deployment": 1, "Service": 1},
+		"clustered-role":  {"StatefulSet": 1, "Service": 2, "PersistentVolumeClaim": 1, "CronJob": 1},
+		"task-role":       {"Job": 1},
+		"scheduled-role":  {"CronJob": 1},
+	}
+
+	for _, role := range manifest.Roles {
+		for _, generator := range NewGenerators() {
+			objects, err := generator.Generate(role, settings)
+			if !assert.NoError(err, "%s generator for role %s", generator.Kind(), role.Name) {
+				continue
+			}
+			assert.Len(objects, expected[role.Name][generator.Kind()],
+				"Unexpected number of %s objects for role %s", generator.Kind(), role.Name)
+		}
+	}
+}
+
+func TestGeneratorKindFilter(t *testing.T) {
+	assert := assert.New(t)
+	manifest := loadTestRoleManifest(assert, "kube-generators.yml")
+	if manifest == nil {
+		return
+	}
+
+	role := manifest.LookupRole("clustered-role")
+	if !assert.NotNil(role) {
+		return
+	}
+
+	kinds := func(objects []runtime.Object) map[string]int {
+		result := map[string]int{}
+		for _, object := range objects {
+			result[object.GetObjectKind().GroupVersionKind().Kind]++
+		}
+		return result
+	}
+
+	filter, err := NewKindFilter([]string{"service"}, nil)
+	if !assert.NoError(err) {
+		return
+	}
+	objects, err := GenerateRoleObjects(role, &ExportSettings{Repository: "fissile", Kinds: filter}, NewGenerators())
+	if assert.NoError(err) {
+		assert.Equal(map[string]int{"Service": 2}, kinds(objects))
+	}
+
+	filter, err = NewKindFilter(nil, []string{"Service", "CronJob"})
+	if !assert.NoError(err) {
+		return
+	}
+	objects, err = GenerateRoleObjects(role, &ExportSettings{Repository: "fissile", Kinds: filter}, NewGenerators())
+	if assert.NoError(err) {
+		assert.Equal(map[string]int{"StatefulSet": 1, "PersistentVolumeClaim": 1}, kinds(objects))
+	}
+
+	_, err = NewKindFilter([]string{"Ingress"}, nil)
+	assert.EqualError(err, "Unknown kind Ingress, expected one of ConfigMap, CronJob, Deployment, Job, PersistentVolumeClaim, Secret, Service, StatefulSet")
+
+	_, err = NewKindFilter([]string{"Service"}, []string{"Job"})
+	assert.EqualError(err, "Kinds can either be selected or skipped, not both")
+}
@@ -7,9 +7,16 @@ import (
 	meta "k8s.io/client-go/pkg/api/unversioned"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
 	extra "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/runtime"
 )
 
-// NewJob creates a new Job for the given role, as well as any objects it depends on
+// ManualTaskRunPatch is the patch that runs the Job of a manual task, which is
+// written out suspended, e.g. kubectl patch job <role> --patch <patch>
+const ManualTaskRunPatch = `{"spec":{"parallelism":1}}`
+
+// NewJob creates a new Job for the given role, as well as any objects it
+// depends on. The Jobs of manual tasks are suspended, so that applying them
+// doesn't run them; see ManualTaskRunPatch.
 func NewJob(role *model.Role, settings *ExportSettings) (*extra.Job, error) {
 	podTemplate, err := NewPodTemplate(role, settings)
 	if err != nil {
@@ -29,17 +36,56 @@ func NewJob(role *model.Role, settings *ExportSettings) (*extra.Job, error) {
 	default:
 		return nil, fmt.Errorf("Role %s has unexpected flight stage %s", role.Name, role.Run.FlightStage)
 	}
+	if role.HasTag(model.RoleTagStopOnFailure) {
+		// Failed containers are not restarted; the vendored client has no
+		// backoff limit to also keep the job from creating new pods
+		podTemplate.Spec.RestartPolicy = apiv1.RestartPolicyNever
+	}
+
+	annotations, err := getDeployOrderAnnotations(role)
+	if err != nil {
+		return nil, err
+	}
 
-	return &extra.Job{
+	job := &extra.Job{
 		TypeMeta: meta.TypeMeta{
 			APIVersion: "extensions/v1beta1",
 			Kind:       "Job",
 		},
 		ObjectMeta: apiv1.ObjectMeta{
-			Name: role.Name,
+			Name:        role.Name,
+			Annotations: addPausedAnnotation(annotations, role, settings),
 		},
 		Spec: extra.JobSpec{
 			Template: podTemplate,
 		},
-	}, nil
+	}
+	if isPaused(role, settings) || role.IsManualTask() {
+		// Jobs without parallelism start no pods
+		parallelism := int32(0)
+		job.Spec.Parallelism = &parallelism
+	}
+	return job, nil
+}
+
+// jobGenerator creates the Jobs for bosh-task roles without a schedule
+type jobGenerator struct{}
+
+// Kind implements Generator
+func (g *jobGenerator) Kind() string {
+	return "Job"
+}
+
+// Generate implements Generator
+func (g *jobGenerator) Generate(role *model.Role, settings *ExportSettings) ([]runtime.Object, error) {
+	if role.Type != model.RoleTypeBoshTask || role.Run.Schedule != nil {
+		return nil, nil
+	}
+
+	job, err := NewJob(role, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return []runtime.Object{job}, nil
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/hpcloud/fissile/model"
 	"github.com/stretchr/testify/assert"
 	yaml "gopkg.in/yaml.v2"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
 )
 
 func jobTestLoadRole(assert *assert.Assertions, roleName string) *model.Role {
@@ -122,3 +123,37 @@ func TestJobPostFlight(t *testing.T) {
 	}
 	_ = isYAMLSubset(assert, expected, actual, []string{})
 }
+
+func TestJobManual(t *testing.T) {
+	assert := assert.New(t)
+	role := jobTestLoadRole(assert, "post-role")
+	if role == nil {
+		return
+	}
+
+	job, err := NewJob(role, &ExportSettings{})
+	if assert.NoError(err) {
+		assert.Nil(job.Spec.Parallelism, "Other tasks run once applied")
+	}
+
+	role.Run.FlightStage = model.FlightStageManual
+	job, err = NewJob(role, &ExportSettings{})
+	if assert.NoError(err) && assert.NotNil(job.Spec.Parallelism) {
+		assert.Equal(int32(0), *job.Spec.Parallelism, "Manual tasks are suspended")
+		assert.Equal(apiv1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+	}
+}
+
+func TestJobStopOnFailure(t *testing.T) {
+	assert := assert.New(t)
+	role := jobTestLoadRole(assert, "post-role")
+	if role == nil {
+		return
+	}
+	role.Tags = append(role.Tags, model.RoleTagStopOnFailure)
+
+	job, err := NewJob(role, &ExportSettings{})
+	if assert.NoError(err) {
+		assert.Equal(apiv1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+	}
+}
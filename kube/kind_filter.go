@@ -0,0 +1,94 @@
+package kube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/pkg/runtime"
+)
+
+// KindFilter selects the kinds of objects that are written out. If Only is
+// empty, all kinds but the ones in Skip are written.
+type KindFilter struct {
+	Only []string
+	Skip []string
+}
+
+// NewKindFilter creates a filter for the given kinds; kinds are matched
+// without regard to case, and must be kinds that fissile generates
+func NewKindFilter(only, skip []string) (*KindFilter, error) {
+	if len(only) > 0 && len(skip) > 0 {
+		return nil, fmt.Errorf("Kinds can either be selected or skipped, not both")
+	}
+
+	known := map[string]string{}
+	var knownNames []string
+	for _, generator := range NewGenerators() {
+		if _, ok := known[strings.ToLower(generator.Kind())]; !ok {
+			known[strings.ToLower(generator.Kind())] = generator.Kind()
+			knownNames = append(knownNames, generator.Kind())
+		}
+	}
+	sort.Strings(knownNames)
+
+	canonical := func(kinds []string) ([]string, error) {
+		result := make([]string, 0, len(kinds))
+		for _, kind := range kinds {
+			name, ok := known[strings.ToLower(kind)]
+			if !ok {
+				return nil, fmt.Errorf("Unknown kind %s, expected one of %s", kind, strings.Join(knownNames, ", "))
+			}
+			result = append(result, name)
+		}
+		return result, nil
+	}
+
+	var filter KindFilter
+	var err error
+	if filter.Only, err = canonical(only); err != nil {
+		return nil, err
+	}
+	if filter.Skip, err = canonical(skip); err != nil {
+		return nil, err
+	}
+
+	return &filter, nil
+}
+
+// Includes returns true if objects of the kind are written out
+func (f *KindFilter) Includes(kind string) bool {
+	if f == nil {
+		return true
+	}
+	for _, skipped := range f.Skip {
+		if strings.EqualFold(skipped, kind) {
+			return false
+		}
+	}
+	if len(f.Only) == 0 {
+		return true
+	}
+	for _, selected := range f.Only {
+		if strings.EqualFold(selected, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the objects of the included kinds
+func (f *KindFilter) Filter(objects []runtime.Object) []runtime.Object {
+	if f == nil {
+		return objects
+	}
+
+	var result []runtime.Object
+	for _, object := range objects {
+		if f.Includes(object.GetObjectKind().GroupVersionKind().Kind) {
+			result = append(result, object)
+		}
+	}
+
+	return result
+}
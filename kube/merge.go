@@ -0,0 +1,225 @@
+package kube
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/pkg/api/meta"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	extra "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/runtime"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// ManagedAnnotationsAnnotation lists the annotations fissile set on an
+	// object when it was merged into an existing configuration; the others
+	// were added by users, and are kept when it is generated again
+	ManagedAnnotationsAnnotation = "skiff-managed-annotations"
+	// ManagedLabelsAnnotation lists the labels fissile set on an object, as
+	// ManagedAnnotationsAnnotation does for annotations
+	ManagedLabelsAnnotation = "skiff-managed-labels"
+)
+
+// documentSeparator splits the YAML documents of a configuration file
+var documentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// ExistingMetadata is the metadata of the objects of a configuration file
+// written before, by kind and name (e.g. Deployment/myrole)
+type ExistingMetadata map[string]*existingObjectMetadata
+
+// existingObjectMetadata is the merged metadata of an existing object, and
+// of its pod template
+type existingObjectMetadata struct {
+	Annotations map[string]string
+	Labels      map[string]string
+	Template    *existingObjectMetadata
+}
+
+// existingObject is the part of an object in a configuration file that is
+// merged, or that is needed to find it
+type existingObject struct {
+	Kind     string             `yaml:"kind"`
+	Metadata existingMetadata   `yaml:"metadata"`
+	Items    []*existingObject  `yaml:"items"`
+	Spec     existingObjectSpec `yaml:"spec"`
+}
+
+type existingMetadata struct {
+	Name        string            `yaml:"name"`
+	Annotations map[string]string `yaml:"annotations"`
+	Labels      map[string]string `yaml:"labels"`
+}
+
+type existingObjectSpec struct {
+	Template *struct {
+		Metadata existingMetadata `yaml:"metadata"`
+	} `yaml:"template"`
+	JobTemplate *struct {
+		Spec existingObjectSpec `yaml:"spec"`
+	} `yaml:"jobTemplate"`
+}
+
+// ReadExistingMetadata reads the annotations and labels of the objects of a
+// configuration file, as written by WriteYamlConfig and possibly edited since
+func ReadExistingMetadata(reader io.Reader) (ExistingMetadata, error) {
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ExistingMetadata{}
+	var add func(object *existingObject)
+	add = func(object *existingObject) {
+		for _, item := range object.Items {
+			add(item)
+		}
+		if object.Kind == "" || object.Metadata.Name == "" {
+			return
+		}
+		metadata := &existingObjectMetadata{
+			Annotations: object.Metadata.Annotations,
+			Labels:      object.Metadata.Labels,
+		}
+		spec := object.Spec
+		if spec.JobTemplate != nil {
+			spec = spec.JobTemplate.Spec
+		}
+		if spec.Template != nil {
+			metadata.Template = &existingObjectMetadata{
+				Annotations: spec.Template.Metadata.Annotations,
+				Labels:      spec.Template.Metadata.Labels,
+			}
+		}
+		result[fmt.Sprintf("%s/%s", object.Kind, object.Metadata.Name)] = metadata
+	}
+
+	for _, document := range documentSeparator.Split(string(contents), -1) {
+		if strings.TrimSpace(document) == "" {
+			continue
+		}
+		var object existingObject
+		if err := yaml.Unmarshal([]byte(document), &object); err != nil {
+			return nil, err
+		}
+		add(&object)
+	}
+
+	return result, nil
+}
+
+// MergeExistingMetadata applies a three-way merge to the annotations and
+// labels of generated objects and of their pod templates: the values fissile
+// generates win, the ones it generated before but does not anymore are
+// dropped, and the ones users added to the existing objects are kept. What
+// fissile generated before is read from the ManagedAnnotationsAnnotation and
+// ManagedLabelsAnnotation annotations, which are set on the merged objects;
+// objects without them keep all the annotations and labels fissile does not
+// generate. Other fields are not merged.
+func MergeExistingMetadata(objects []runtime.Object, existing ExistingMetadata) error {
+	for _, object := range objects {
+		if list, ok := object.(*apiv1.List); ok {
+			for _, item := range list.Items {
+				if item.Object == nil {
+					continue
+				}
+				if err := MergeExistingMetadata([]runtime.Object{item.Object}, existing); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			return fmt.Errorf("Can't merge the metadata of a %s: %s", object.GetObjectKind().GroupVersionKind().Kind, err.Error())
+		}
+		previous := existing[fmt.Sprintf("%s/%s", object.GetObjectKind().GroupVersionKind().Kind, accessor.GetName())]
+		if previous == nil {
+			previous = &existingObjectMetadata{}
+		}
+		mergeObjectMetadata(accessor, previous)
+
+		if template := podTemplateOf(object); template != nil {
+			previousTemplate := previous.Template
+			if previousTemplate == nil {
+				previousTemplate = &existingObjectMetadata{}
+			}
+			mergeObjectMetadata(&template.ObjectMeta, previousTemplate)
+		}
+	}
+	return nil
+}
+
+// podTemplateOf returns the pod template of the objects that have one
+func podTemplateOf(object runtime.Object) *apiv1.PodTemplateSpec {
+	switch typed := object.(type) {
+	case *extra.Deployment:
+		return &typed.Spec.Template
+	case *StatefulSet:
+		return &typed.Spec.Template
+	case *extra.Job:
+		return &typed.Spec.Template
+	case *CronJob:
+		return &typed.Spec.JobTemplate.Spec.Template
+	}
+	return nil
+}
+
+// mergeObjectMetadata merges the existing annotations and labels of an object
+// into the generated ones, and records the generated ones
+func mergeObjectMetadata(object meta.Object, previous *existingObjectMetadata) {
+	annotations := object.GetAnnotations()
+	managedAnnotations := previous.Annotations[ManagedAnnotationsAnnotation]
+	managedLabels := previous.Annotations[ManagedLabelsAnnotation]
+
+	labels, labelKeys := mergeKeys(object.GetLabels(), previous.Labels, managedLabels)
+	annotations, annotationKeys := mergeKeys(annotations, previous.Annotations, managedAnnotations)
+	delete(annotations, ManagedAnnotationsAnnotation)
+	delete(annotations, ManagedLabelsAnnotation)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ManagedAnnotationsAnnotation] = strings.Join(annotationKeys, ",")
+	annotations[ManagedLabelsAnnotation] = strings.Join(labelKeys, ",")
+
+	object.SetAnnotations(annotations)
+	object.SetLabels(labels)
+}
+
+// mergeKeys returns the generated values along with the existing ones that
+// are not in the comma separated list of the ones fissile set before, and the
+// sorted keys of the generated values
+func mergeKeys(generated, existing map[string]string, managed string) (map[string]string, []string) {
+	var generatedKeys []string
+	for key := range generated {
+		if key != ManagedAnnotationsAnnotation && key != ManagedLabelsAnnotation {
+			generatedKeys = append(generatedKeys, key)
+		}
+	}
+	sort.Strings(generatedKeys)
+
+	wasManaged := map[string]bool{}
+	for _, key := range strings.Split(managed, ",") {
+		wasManaged[key] = true
+	}
+
+	var result map[string]string
+	if len(generated) > 0 || len(existing) > 0 {
+		result = map[string]string{}
+	}
+	for key, value := range existing {
+		if !wasManaged[key] {
+			result[key] = value
+		}
+	}
+	for key, value := range generated {
+		result[key] = value
+	}
+	return result, generatedKeys
+}
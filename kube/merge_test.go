@@ -0,0 +1,156 @@
+package kube
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	meta "k8s.io/client-go/pkg/api/unversioned"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	extra "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/runtime"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadExistingMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	existing, err := ReadExistingMetadata(strings.NewReader(`---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: myrole
+  annotations:
+    team: storage
+  labels:
+    skiff-role-name: myrole
+spec:
+  template:
+    metadata:
+      annotations:
+        restarted-at: today
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: myrole-public
+    labels:
+      exposed: "true"
+---
+apiVersion: batch/v2alpha1
+kind: CronJob
+metadata:
+  name: nightly
+spec:
+  jobTemplate:
+    spec:
+      template:
+        metadata:
+          labels:
+            owner: ops
+`))
+	if !assert.NoError(err) {
+		return
+	}
+
+	if assert.Contains(existing, "Deployment/myrole") {
+		deployment := existing["Deployment/myrole"]
+		assert.Equal(map[string]string{"team": "storage"}, deployment.Annotations)
+		assert.Equal(map[string]string{"skiff-role-name": "myrole"}, deployment.Labels)
+		if assert.NotNil(deployment.Template) {
+			assert.Equal(map[string]string{"restarted-at": "today"}, deployment.Template.Annotations)
+		}
+	}
+	if assert.Contains(existing, "Service/myrole-public") {
+		assert.Equal(map[string]string{"exposed": "true"}, existing["Service/myrole-public"].Labels)
+	}
+	if assert.Contains(existing, "CronJob/nightly") && assert.NotNil(existing["CronJob/nightly"].Template) {
+		assert.Equal(map[string]string{"owner": "ops"}, existing["CronJob/nightly"].Template.Labels)
+	}
+	assert.NotContains(existing, "List/")
+
+	_, err = ReadExistingMetadata(strings.NewReader("---\nkind: [\n"))
+	assert.Error(err)
+}
+
+func TestMergeExistingMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	newDeployment := func(annotations map[string]string) *extra.Deployment {
+		return &extra.Deployment{
+			TypeMeta: meta.TypeMeta{APIVersion: "extensions/v1beta1", Kind: "Deployment"},
+			ObjectMeta: apiv1.ObjectMeta{
+				Name:        "myrole",
+				Annotations: annotations,
+				Labels:      map[string]string{RoleNameLabel: "myrole"},
+			},
+			Spec: extra.DeploymentSpec{
+				Template: apiv1.PodTemplateSpec{
+					ObjectMeta: apiv1.ObjectMeta{Labels: map[string]string{RoleNameLabel: "myrole"}},
+				},
+			},
+		}
+	}
+
+	// The first merge keeps everything fissile does not generate
+	deployment := newDeployment(map[string]string{"generated": "new", "dropped": "old"})
+	existing := ExistingMetadata{
+		"Deployment/myrole": {
+			Annotations: map[string]string{"generated": "edited", "team": "storage"},
+			Labels:      map[string]string{RoleNameLabel: "myrole", "tier": "backend"},
+			Template: &existingObjectMetadata{
+				Annotations: map[string]string{"restarted-at": "today"},
+			},
+		},
+	}
+	if !assert.NoError(MergeExistingMetadata([]runtime.Object{deployment}, existing)) {
+		return
+	}
+	assert.Equal(map[string]string{
+		"generated":                  "new",
+		"dropped":                    "old",
+		"team":                       "storage",
+		ManagedAnnotationsAnnotation: "dropped,generated",
+		ManagedLabelsAnnotation:      RoleNameLabel,
+	}, deployment.Annotations)
+	assert.Equal(map[string]string{RoleNameLabel: "myrole", "tier": "backend"}, deployment.Labels)
+	assert.Equal("today", deployment.Spec.Template.Annotations["restarted-at"])
+
+	// Written out and read back, the next merge drops what fissile stopped generating
+	var written bytes.Buffer
+	if !assert.NoError(WriteYamlConfig(deployment, &written)) {
+		return
+	}
+	existing, err := ReadExistingMetadata(&written)
+	if !assert.NoError(err) {
+		return
+	}
+
+	deployment = newDeployment(map[string]string{"generated": "newer"})
+	if !assert.NoError(MergeExistingMetadata([]runtime.Object{deployment}, existing)) {
+		return
+	}
+	assert.Equal(map[string]string{
+		"generated":                  "newer",
+		"team":                       "storage",
+		ManagedAnnotationsAnnotation: "generated",
+		ManagedLabelsAnnotation:      RoleNameLabel,
+	}, deployment.Annotations)
+	assert.Equal("backend", deployment.Labels["tier"])
+	assert.Equal("today", deployment.Spec.Template.Annotations["restarted-at"])
+
+	// Objects in lists are merged too
+	service := &Service{
+		TypeMeta:   meta.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: apiv1.ObjectMeta{Name: "myrole-public"},
+	}
+	list := &apiv1.List{Items: []runtime.RawExtension{{Object: service}}}
+	existing = ExistingMetadata{"Service/myrole-public": {Labels: map[string]string{"exposed": "true"}}}
+	if assert.NoError(MergeExistingMetadata([]runtime.Object{list}, existing)) {
+		assert.Equal(map[string]string{"exposed": "true"}, service.Labels)
+	}
+}
@@ -0,0 +1,48 @@
+package kube
+
+import (
+	"github.com/hpcloud/fissile/model"
+)
+
+// PausedAnnotation marks the objects of roles generated dormant, without any
+// pods; scale the role up, or regenerate it without pausing it, to run it
+const PausedAnnotation = "skiff-paused"
+
+// isPaused tells if the objects of a role are generated without any pods
+func isPaused(role *model.Role, settings *ExportSettings) bool {
+	return settings.PausedRoles[role.Name]
+}
+
+// getReplicas returns the number of pods of the Deployment or StatefulSet of
+// a role; none for paused roles
+func getReplicas(role *model.Role, settings *ExportSettings) int32 {
+	if isPaused(role, settings) {
+		return 0
+	}
+	return role.Replicas()
+}
+
+// addPausedAnnotation marks the annotations of the objects of a role as
+// paused if it is
+func addPausedAnnotation(annotations map[string]string, role *model.Role, settings *ExportSettings) map[string]string {
+	if !isPaused(role, settings) {
+		return annotations
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[PausedAnnotation] = "true"
+	return annotations
+}
+
+// pauseCronJob suspends the CronJob of a paused role, so it creates no jobs.
+// The jobs it creates once it is resumed run as usual.
+func pauseCronJob(cronJob *CronJob, role *model.Role, settings *ExportSettings) {
+	if !isPaused(role, settings) {
+		return
+	}
+	suspend := true
+	cronJob.Spec.Suspend = &suspend
+	cronJob.Spec.JobTemplate.Spec.Parallelism = nil
+	cronJob.Annotations = addPausedAnnotation(cronJob.Annotations, role, settings)
+}
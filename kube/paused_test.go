@@ -0,0 +1,69 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPausedRoles(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	if !assert.NoError(err) {
+		return
+	}
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	release, err := model.NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+	if !assert.NoError(err) {
+		return
+	}
+	manifest, err := model.LoadRoleManifest(filepath.Join(workDir, "../test-assets/role-manifests/deploy-order.yml"), []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	settings := &ExportSettings{PausedRoles: map[string]bool{"myrole": true, "api": true, "smoke-tests": true}}
+
+	deployment, _, err := NewDeployment(manifest.LookupRole("nats"), settings)
+	if assert.NoError(err) {
+		assert.Equal(int32(1), *deployment.Spec.Replicas, "Roles not paused keep their replicas")
+		assert.Empty(deployment.Annotations)
+	}
+
+	deployment, _, err = NewDeployment(manifest.LookupRole("api"), settings)
+	if assert.NoError(err) {
+		assert.Equal(int32(0), *deployment.Spec.Replicas)
+		assert.Equal("true", deployment.Annotations[PausedAnnotation])
+		assert.Equal("myrole,nats", deployment.Annotations[DependsOnAnnotation], "Paused roles keep their other annotations")
+	}
+
+	statefulSet, _, err := NewStatefulSet(manifest.LookupRole("myrole"), settings)
+	if assert.NoError(err) {
+		assert.Equal(int32(0), *statefulSet.Spec.Replicas)
+		assert.Equal(map[string]string{PausedAnnotation: "true"}, statefulSet.Annotations)
+	}
+
+	task := manifest.LookupRole("smoke-tests")
+	job, err := NewJob(task, settings)
+	if assert.NoError(err) {
+		if assert.NotNil(job.Spec.Parallelism) {
+			assert.Equal(int32(0), *job.Spec.Parallelism)
+		}
+		assert.Equal("true", job.Annotations[PausedAnnotation])
+	}
+
+	task.Run.Schedule = &model.RoleRunSchedule{Cron: "@daily", ConcurrencyPolicy: "forbid"}
+	cronJob, err := NewCronJob(task, settings)
+	if assert.NoError(err) {
+		if assert.NotNil(cronJob.Spec.Suspend) {
+			assert.True(*cronJob.Spec.Suspend)
+		}
+		assert.Nil(cronJob.Spec.JobTemplate.Spec.Parallelism, "The jobs of resumed CronJobs run")
+		assert.Equal(map[string]string{PausedAnnotation: "true"}, cronJob.Annotations)
+	}
+}
@@ -0,0 +1,158 @@
+package kube
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+)
+
+// These are the platforms the platform hints of roles can be translated for
+const (
+	PlatformNone  = "none"  // The hints are left out
+	PlatformAWS   = "aws"   // Amazon Web Services
+	PlatformGCP   = "gcp"   // Google Cloud Platform
+	PlatformAzure = "azure" // Microsoft Azure
+)
+
+// Platforms lists the valid platforms
+var Platforms = []string{PlatformNone, PlatformAWS, PlatformGCP, PlatformAzure}
+
+// PlatformMapper translates the platform hints of roles into what their cloud
+// platform understands, so that the objects need no hand-edits per cloud
+type PlatformMapper interface {
+	// Name returns the name of the platform
+	Name() string
+	// ServiceAnnotations returns the annotations of the service of a role for
+	// its platform hints. Hints the platform has no equivalent for are left
+	// out, and returned as unsupported, as name=value.
+	ServiceAnnotations(role *model.Role) (annotations map[string]string, unsupported []string)
+}
+
+// NewPlatformMapper returns the platform mapper with the given name
+func NewPlatformMapper(name string) (PlatformMapper, error) {
+	switch name {
+	case PlatformNone, "":
+		return noPlatform{}, nil
+	case PlatformAWS:
+		return awsPlatform{}, nil
+	case PlatformGCP:
+		return gcpPlatform{}, nil
+	case PlatformAzure:
+		return azurePlatform{}, nil
+	}
+	return nil, fmt.Errorf("Invalid platform '%s', expected one of %s", name, strings.Join(Platforms, ", "))
+}
+
+// platformOf returns the platform mapper of the settings
+func platformOf(settings *ExportSettings) PlatformMapper {
+	if settings == nil || settings.Platform == nil {
+		return noPlatform{}
+	}
+	return settings.Platform
+}
+
+// platformHint returns a platform hint of a role, or "" if it has none
+func platformHint(role *model.Role, name string) string {
+	if role.Run == nil {
+		return ""
+	}
+	return role.Run.PlatformHints[name]
+}
+
+// unsupportedHint formats a platform hint of a role for the list of the hints
+// a platform doesn't support
+func unsupportedHint(role *model.Role, name string) string {
+	return fmt.Sprintf("%s=%s", name, platformHint(role, name))
+}
+
+// noPlatform leaves out the platform hints
+type noPlatform struct{}
+
+// Name implements PlatformMapper
+func (p noPlatform) Name() string {
+	return PlatformNone
+}
+
+// ServiceAnnotations implements PlatformMapper
+func (p noPlatform) ServiceAnnotations(role *model.Role) (map[string]string, []string) {
+	return nil, nil
+}
+
+// awsPlatform translates the hints for the AWS cloud provider
+type awsPlatform struct{}
+
+// Name implements PlatformMapper
+func (p awsPlatform) Name() string {
+	return PlatformAWS
+}
+
+// ServiceAnnotations implements PlatformMapper
+func (p awsPlatform) ServiceAnnotations(role *model.Role) (map[string]string, []string) {
+	// External classic load balancers are the default
+	annotations := map[string]string{}
+	if platformHint(role, model.PlatformHintLoadBalancer) == "internal" {
+		annotations["service.beta.kubernetes.io/aws-load-balancer-internal"] = "true"
+	}
+	if platformHint(role, model.PlatformHintLoadBalancerType) == "network" {
+		annotations["service.beta.kubernetes.io/aws-load-balancer-type"] = "nlb"
+	}
+	if timeout := platformHint(role, model.PlatformHintIdleTimeout); timeout != "" {
+		annotations["service.beta.kubernetes.io/aws-load-balancer-connection-idle-timeout"] = timeout
+	}
+	return annotations, nil
+}
+
+// gcpPlatform translates the hints for the GCE cloud provider; its service
+// load balancers are network load balancers, external by default, whose idle
+// timeout can't be set
+type gcpPlatform struct{}
+
+// Name implements PlatformMapper
+func (p gcpPlatform) Name() string {
+	return PlatformGCP
+}
+
+// ServiceAnnotations implements PlatformMapper
+func (p gcpPlatform) ServiceAnnotations(role *model.Role) (map[string]string, []string) {
+	annotations := map[string]string{}
+	var unsupported []string
+	if platformHint(role, model.PlatformHintLoadBalancer) == "internal" {
+		annotations["cloud.google.com/load-balancer-type"] = "Internal"
+	}
+	if platformHint(role, model.PlatformHintIdleTimeout) != "" {
+		unsupported = append(unsupported, unsupportedHint(role, model.PlatformHintIdleTimeout))
+	}
+	if platformHint(role, model.PlatformHintLoadBalancerType) == "classic" {
+		unsupported = append(unsupported, unsupportedHint(role, model.PlatformHintLoadBalancerType))
+	}
+	return annotations, unsupported
+}
+
+// azurePlatform translates the hints for the Azure cloud provider; its
+// service load balancers are network load balancers, external by default
+type azurePlatform struct{}
+
+// Name implements PlatformMapper
+func (p azurePlatform) Name() string {
+	return PlatformAzure
+}
+
+// ServiceAnnotations implements PlatformMapper
+func (p azurePlatform) ServiceAnnotations(role *model.Role) (map[string]string, []string) {
+	annotations := map[string]string{}
+	var unsupported []string
+	if platformHint(role, model.PlatformHintLoadBalancer) == "internal" {
+		annotations["service.beta.kubernetes.io/azure-load-balancer-internal"] = "true"
+	}
+	if timeout := platformHint(role, model.PlatformHintIdleTimeout); timeout != "" {
+		// Azure takes minutes
+		seconds, _ := strconv.Atoi(timeout)
+		annotations["service.beta.kubernetes.io/azure-load-balancer-tcp-idle-timeout"] = strconv.Itoa((seconds + 59) / 60)
+	}
+	if platformHint(role, model.PlatformHintLoadBalancerType) == "classic" {
+		unsupported = append(unsupported, unsupportedHint(role, model.PlatformHintLoadBalancerType))
+	}
+	return annotations, unsupported
+}
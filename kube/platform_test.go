@@ -0,0 +1,86 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlatformServiceAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	role := &model.Role{Name: "api", Run: &model.RoleRun{PlatformHints: map[string]string{
+		model.PlatformHintLoadBalancer:     "internal",
+		model.PlatformHintLoadBalancerType: "network",
+		model.PlatformHintIdleTimeout:      "90",
+	}}}
+
+	samples := []struct {
+		name        string
+		annotations map[string]string
+		unsupported []string
+	}{
+		{name: ""},
+		{name: PlatformNone},
+		{name: PlatformAWS, annotations: map[string]string{
+			"service.beta.kubernetes.io/aws-load-balancer-internal":                "true",
+			"service.beta.kubernetes.io/aws-load-balancer-type":                    "nlb",
+			"service.beta.kubernetes.io/aws-load-balancer-connection-idle-timeout": "90",
+		}},
+		{name: PlatformGCP, annotations: map[string]string{
+			"cloud.google.com/load-balancer-type": "Internal",
+		}, unsupported: []string{"idle-timeout=90"}},
+		{name: PlatformAzure, annotations: map[string]string{
+			"service.beta.kubernetes.io/azure-load-balancer-internal":         "true",
+			"service.beta.kubernetes.io/azure-load-balancer-tcp-idle-timeout": "2",
+		}},
+	}
+
+	for _, sample := range samples {
+		platform, err := NewPlatformMapper(sample.name)
+		if !assert.NoError(err, sample.name) {
+			continue
+		}
+		annotations, unsupported := platform.ServiceAnnotations(role)
+		if sample.annotations == nil {
+			assert.Empty(annotations, sample.name)
+		} else {
+			assert.Equal(sample.annotations, annotations, sample.name)
+		}
+		assert.Equal(sample.unsupported, unsupported, sample.name)
+	}
+
+	annotations, unsupported := awsPlatform{}.ServiceAnnotations(&model.Role{Name: "api", Run: &model.RoleRun{}})
+	assert.Empty(annotations, "Roles without hints get no annotations")
+	assert.Empty(unsupported)
+}
+
+func TestPlatformUnsupportedHints(t *testing.T) {
+	assert := assert.New(t)
+	role := &model.Role{Name: "api", Run: &model.RoleRun{PlatformHints: map[string]string{
+		model.PlatformHintLoadBalancer:     "external",
+		model.PlatformHintLoadBalancerType: "classic",
+		model.PlatformHintIdleTimeout:      "90",
+	}}}
+
+	annotations, unsupported := awsPlatform{}.ServiceAnnotations(role)
+	assert.Equal(map[string]string{
+		"service.beta.kubernetes.io/aws-load-balancer-connection-idle-timeout": "90",
+	}, annotations, "external classic load balancers are the AWS default")
+	assert.Empty(unsupported)
+
+	annotations, unsupported = gcpPlatform{}.ServiceAnnotations(role)
+	assert.Empty(annotations, "external load balancers are the GCP default")
+	assert.Equal([]string{"idle-timeout=90", "load-balancer-type=classic"}, unsupported)
+
+	_, unsupported = azurePlatform{}.ServiceAnnotations(role)
+	assert.Equal([]string{"load-balancer-type=classic"}, unsupported)
+}
+
+func TestNewPlatformMapperInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewPlatformMapper("openstack")
+	assert.EqualError(err, "Invalid platform 'openstack', expected one of none, aws, gcp, azure")
+}
@@ -2,17 +2,21 @@ package kube
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"hash/crc32"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/hpcloud/fissile/builder"
 	"github.com/hpcloud/fissile/model"
 
+	"k8s.io/client-go/pkg/api"
 	"k8s.io/client-go/pkg/api/resource"
+	meta "k8s.io/client-go/pkg/api/unversioned"
 	"k8s.io/client-go/pkg/api/v1"
 	"k8s.io/client-go/pkg/util/intstr"
 )
@@ -28,16 +32,14 @@ func NewPodTemplate(role *model.Role, settings *ExportSettings) (v1.PodTemplateS
 	if err != nil {
 		return v1.PodTemplateSpec{}, err
 	}
-
-	var resources v1.ResourceRequirements
-
-	if settings.UseMemoryLimits {
-		resources = v1.ResourceRequirements{
-			Requests: v1.ResourceList{
-				v1.ResourceMemory: resource.MustParse(fmt.Sprintf("%dMi", role.Run.Memory)),
-			},
-		}
+	runEnvVars, err := getRunEnvVars(role, settings)
+	if err != nil {
+		return v1.PodTemplateSpec{}, err
 	}
+	vars = append(vars, runEnvVars...)
+
+	resources := getContainerResources(role, settings)
+	addExtendedResources(role, &resources)
 
 	securityContext := getSecurityContext(role)
 
@@ -46,47 +48,253 @@ func NewPodTemplate(role *model.Role, settings *ExportSettings) (v1.PodTemplateS
 		return v1.PodTemplateSpec{}, err
 	}
 
+	annotations, err := getPodAnnotations(role)
+	if err != nil {
+		return v1.PodTemplateSpec{}, err
+	}
+	if settings.ConfigChecksums {
+		checksum, err := getConfigChecksum(role, settings)
+		if err != nil {
+			return v1.PodTemplateSpec{}, err
+		}
+		if checksum != "" {
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[ConfigChecksumAnnotation] = checksum
+		}
+	}
+
+	volumes := append(getDeviceVolumes(role), getTLSVolumes(role)...)
+	volumes = append(volumes, getExternalMountVolumes(role)...)
+	volumes = append(volumes, getSidecarVolumes(role)...)
+	volumes = append(volumes, getHugePagesVolumes(role)...)
+	volumes = append(volumes, getTmpfsVolumes(role)...)
+
 	podSpec := v1.PodTemplateSpec{
 		ObjectMeta: v1.ObjectMeta{
 			Name: role.Name,
 			Labels: map[string]string{
 				RoleNameLabel: role.Name,
 			},
+			Annotations: annotations,
 		},
 		Spec: v1.PodSpec{
 			Containers: []v1.Container{
 				v1.Container{
 					Name:            role.Name,
-					Image:           getContainerImageName(role, settings),
+					Image:           ContainerImageName(role, settings),
 					Ports:           ports,
 					VolumeMounts:    getVolumeMounts(role),
 					Env:             vars,
 					Resources:       resources,
 					SecurityContext: securityContext,
+					Lifecycle:       getContainerLifecycle(role),
 				},
 			},
+			Volumes:       volumes,
+			NodeSelector:  role.Run.NodeSelector,
 			RestartPolicy: v1.RestartPolicyAlways,
 			DNSPolicy:     v1.DNSClusterFirst,
 		},
 	}
+	if role.Run.GracePeriod > 0 {
+		gracePeriod := role.Run.GracePeriod
+		podSpec.Spec.TerminationGracePeriodSeconds = &gracePeriod
+	}
 
-	livenessProbe := getContainerLivenessProbe(role)
-	readinessProbe, err := getContainerReadinessProbe(role)
+	probes, err := ResolveProbes(role)
 	if err != nil {
 		return v1.PodTemplateSpec{}, err
 	}
-	if livenessProbe != nil || readinessProbe != nil {
+	if probes.Liveness != nil || probes.Readiness != nil {
 		for i := range podSpec.Spec.Containers {
-			podSpec.Spec.Containers[i].LivenessProbe = livenessProbe
-			podSpec.Spec.Containers[i].ReadinessProbe = readinessProbe
+			podSpec.Spec.Containers[i].LivenessProbe = probes.Liveness
+			podSpec.Spec.Containers[i].ReadinessProbe = probes.Readiness
 		}
 	}
 
+	// The probes are for the role container, not its sidecars
+	podSpec.Spec.Containers = append(podSpec.Spec.Containers, getSidecarContainers(role)...)
+
 	return podSpec, nil
 }
 
-// getContainerImageName returns the name of the docker image to use for a role
-func getContainerImageName(role *model.Role, settings *ExportSettings) string {
+// getContainerLifecycle returns the hooks of the container of a role; roles
+// whose jobs depend on each other stop them in order before the container is
+// sent SIGTERM
+func getContainerLifecycle(role *model.Role) *v1.Lifecycle {
+	if !role.HasJobDependencies() || !role.IsLongRunning() {
+		return nil
+	}
+	return &v1.Lifecycle{
+		PreStop: &v1.Handler{
+			Exec: &v1.ExecAction{Command: []string{model.PreStopScriptPath}},
+		},
+	}
+}
+
+// getContainerResources returns the memory, CPUs and huge pages the
+// containers of a role request. The memory is always requested; with memory
+// limits or the guaranteed qos, containers are also limited to it; CPUs are only
+// limited with the guaranteed qos, so other roles can use more when they are
+// idle.
+func getContainerResources(role *model.Role, settings *ExportSettings) v1.ResourceRequirements {
+	var resources v1.ResourceRequirements
+	if role.Run == nil {
+		return resources
+	}
+
+	guaranteed := role.Run.GetQoS() == model.QoSGuaranteed
+	// The files in tmpfs volumes use memory of the containers
+	memoryMB := role.Run.Memory + role.Run.GetTmpfsSize()
+	if role.Run.Memory > 0 {
+		memory := resource.MustParse(fmt.Sprintf("%dMi", memoryMB))
+		resources.Requests = v1.ResourceList{v1.ResourceMemory: memory}
+		if settings.UseMemoryLimits || guaranteed {
+			resources.Limits = v1.ResourceList{v1.ResourceMemory: memory}
+		}
+	}
+	if role.Run.VirtualCPUs > 0 {
+		if resources.Requests == nil {
+			resources.Requests = v1.ResourceList{}
+		}
+		cpus := *resource.NewQuantity(int64(role.Run.VirtualCPUs), resource.DecimalSI)
+		resources.Requests[v1.ResourceCPU] = cpus
+		// Guaranteed pods with whole CPUs get exclusive ones from the static CPU manager
+		if guaranteed {
+			resources.Limits[v1.ResourceCPU] = cpus
+		}
+	}
+	addHugePagesResources(role, &resources)
+
+	return resources
+}
+
+// getPodAnnotations returns the annotations for the pods of a role; this is
+// how sysctls, tolerations and affinities are requested in this version of
+// Kubernetes
+func getPodAnnotations(role *model.Role) (map[string]string, error) {
+	if role.Run == nil {
+		return nil, nil
+	}
+
+	annotations := map[string]string{}
+
+	tolerations := getTolerations(role)
+	if len(tolerations) > 0 {
+		data, err := json.Marshal(tolerations)
+		if err != nil {
+			return nil, err
+		}
+		annotations[api.TolerationsAnnotationKey] = string(data)
+	}
+
+	if affinity := getAffinity(role); affinity != nil {
+		data, err := json.Marshal(affinity)
+		if err != nil {
+			return nil, err
+		}
+		annotations[api.AffinityAnnotationKey] = string(data)
+	}
+
+	names := make([]string, 0, len(role.Run.Sysctls))
+	for name := range role.Run.Sysctls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var safe, unsafe []string
+	for _, name := range names {
+		sysctl := fmt.Sprintf("%s=%s", name, role.Run.Sysctls[name])
+		if model.IsSafeSysctl(name) {
+			safe = append(safe, sysctl)
+		} else {
+			unsafe = append(unsafe, sysctl)
+		}
+	}
+
+	if len(safe) > 0 {
+		annotations[api.SysctlsPodAnnotationKey] = strings.Join(safe, ",")
+	}
+	if len(unsafe) > 0 {
+		annotations[api.UnsafeSysctlsPodAnnotationKey] = strings.Join(unsafe, ",")
+	}
+
+	if len(annotations) == 0 {
+		return nil, nil
+	}
+	return annotations, nil
+}
+
+// getTolerations returns the tolerations of the pods of a role
+func getTolerations(role *model.Role) []v1.Toleration {
+	var result []v1.Toleration
+	for _, toleration := range role.Run.GetTolerations() {
+		result = append(result, v1.Toleration{
+			Key:      toleration.Key,
+			Operator: v1.TolerationOperator(toleration.Operator),
+			Value:    toleration.Value,
+			Effect:   v1.TaintEffect(toleration.Effect),
+		})
+	}
+	return result
+}
+
+// getAffinity returns the pod affinity and anti-affinity of the pods of a
+// role, selecting the pods of the other roles by their role name label
+func getAffinity(role *model.Role) *v1.Affinity {
+	var required, antiRequired []v1.PodAffinityTerm
+	var preferred, antiPreferred []v1.WeightedPodAffinityTerm
+	for _, roleAffinity := range role.Run.Affinity {
+		term := v1.PodAffinityTerm{
+			LabelSelector: &meta.LabelSelector{
+				MatchLabels: map[string]string{RoleNameLabel: roleAffinity.GetRole(role)},
+			},
+			TopologyKey: roleAffinity.GetTopology(),
+		}
+		weighted := v1.WeightedPodAffinityTerm{Weight: roleAffinity.Weight, PodAffinityTerm: term}
+
+		switch {
+		case roleAffinity.Anti && roleAffinity.IsRequired():
+			antiRequired = append(antiRequired, term)
+		case roleAffinity.Anti:
+			antiPreferred = append(antiPreferred, weighted)
+		case roleAffinity.IsRequired():
+			required = append(required, term)
+		default:
+			preferred = append(preferred, weighted)
+		}
+	}
+
+	affinity := &v1.Affinity{}
+	if len(required)+len(preferred) > 0 {
+		affinity.PodAffinity = &v1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution:  required,
+			PreferredDuringSchedulingIgnoredDuringExecution: preferred,
+		}
+	}
+	if len(antiRequired)+len(antiPreferred) > 0 {
+		affinity.PodAntiAffinity = &v1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution:  antiRequired,
+			PreferredDuringSchedulingIgnoredDuringExecution: antiPreferred,
+		}
+	}
+	if affinity.PodAffinity == nil && affinity.PodAntiAffinity == nil {
+		return nil
+	}
+	return affinity
+}
+
+// ContainerImageName returns the name of the docker image to use for a role,
+// in the registry and organization of the settings. Docker roles use their
+// image as it is.
+func ContainerImageName(role *model.Role, settings *ExportSettings) string {
+	if role.Type == model.RoleTypeDocker {
+		// The image is not built by fissile, nor pushed to the registry
+		return role.Image
+	}
+
 	devImageName := builder.GetRoleDevImageName(settings.Repository, role, role.GetRoleDevVersion())
 	imageName := devImageName
 
@@ -152,14 +360,14 @@ func getContainerPorts(role *model.Role) ([]v1.ContainerPort, error) {
 		}
 
 		// Convert port range specifications to port numbers
-		minInternalPort, maxInternalPort, err := parsePortRange(port.Internal, port.Name, "internal")
+		minInternalPort, maxInternalPort, err := model.ParsePortRange(port.Internal, port.Name, "internal")
 		if err != nil {
 			return nil, err
 		}
 		// The external port is optional here; we only need it if it's public
 		var minExternalPort, maxExternalPort int32
 		if port.External != "" {
-			minExternalPort, maxExternalPort, err = parsePortRange(port.External, port.Name, "external")
+			minExternalPort, maxExternalPort, err = model.ParsePortRange(port.External, port.Name, "external")
 			if err != nil {
 				return nil, err
 			}
@@ -198,7 +406,7 @@ func getContainerPorts(role *model.Role) ([]v1.ContainerPort, error) {
 
 // getVolumeMounts gets the list of volume mounts for a role
 func getVolumeMounts(role *model.Role) []v1.VolumeMount {
-	resultLen := len(role.Run.PersistentVolumes) + len(role.Run.SharedVolumes)
+	resultLen := len(role.Run.PersistentVolumes) + len(role.Run.SharedVolumes) + len(role.Run.Devices)
 	result := make([]v1.VolumeMount, 0, resultLen)
 
 	for _, volume := range role.Run.PersistentVolumes {
@@ -217,7 +425,12 @@ func getVolumeMounts(role *model.Role) []v1.VolumeMount {
 		})
 	}
 
-	return result
+	result = append(result, getDeviceVolumeMounts(role)...)
+	result = append(result, getTLSVolumeMounts(role)...)
+	result = append(result, getExternalMountVolumeMounts(role)...)
+	result = append(result, getHugePagesVolumeMounts(role)...)
+	result = append(result, getTmpfsVolumeMounts(role)...)
+	return append(result, getSidecarVolumeMounts(role)...)
 }
 
 func getEnvVars(role *model.Role, defaults map[string]string) ([]v1.EnvVar, error) {
@@ -230,26 +443,25 @@ func getEnvVars(role *model.Role, defaults map[string]string) ([]v1.EnvVar, erro
 	result := make([]v1.EnvVar, 0, len(configs))
 
 	for _, config := range configs {
-		var value interface{}
-
-		value = config.Default
-
-		if defaultValue, ok := defaults[config.Name]; ok {
-			value = defaultValue
-		}
-
-		if value == nil {
+		if config.Secret != nil {
+			// The value comes from a secret managed outside of fissile
+			result = append(result, v1.EnvVar{
+				Name: config.Name,
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: config.Secret.Name,
+						},
+						Key: config.Secret.Key,
+					},
+				},
+			})
 			continue
 		}
 
-		var stringifiedValue string
-		if valueAsString, ok := value.(string); ok {
-			stringifiedValue, err = strconv.Unquote(fmt.Sprintf(`"%s"`, valueAsString))
-			if err != nil {
-				stringifiedValue = valueAsString
-			}
-		} else {
-			stringifiedValue = fmt.Sprintf("%v", value)
+		stringifiedValue, ok := getVariableValue(config, defaults)
+		if !ok {
+			continue
 		}
 
 		result = append(result, v1.EnvVar{
@@ -258,6 +470,15 @@ func getEnvVars(role *model.Role, defaults map[string]string) ([]v1.EnvVar, erro
 		})
 	}
 
+	result = append(result, getTLSEnvVars(role)...)
+	result = append(result, getSecretReferenceEnvVars(role)...)
+
+	linkEnvVars, err := getLinkEnvVars(role)
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, linkEnvVars...)
+
 	result = append(result, v1.EnvVar{
 		Name: "KUBERNETES_NAMESPACE",
 		ValueFrom: &v1.EnvVarSource{
@@ -270,16 +491,68 @@ func getEnvVars(role *model.Role, defaults map[string]string) ([]v1.EnvVar, erro
 	return result, nil
 }
 
+// getRunEnvVars returns the environment variables the role sets in its
+// run.env. They come after the configuration variables they reference, so
+// that Kubernetes expands those.
+func getRunEnvVars(role *model.Role, settings *ExportSettings) ([]v1.EnvVar, error) {
+	if role.Run == nil {
+		return nil, nil
+	}
+
+	roleHost := func(roleName string) (string, error) {
+		return discoveryOf(settings).RoleHost(role.LookupRole(roleName))
+	}
+
+	result := make([]v1.EnvVar, 0, len(role.Run.Env))
+	for _, env := range role.Run.Env {
+		value, err := env.Expand(roleHost)
+		if err != nil {
+			return nil, fmt.Errorf("Role %s, environment variable %s: %s", role.Name, env.Name, err.Error())
+		}
+		result = append(result, v1.EnvVar{
+			Name:  env.Name,
+			Value: value,
+		})
+	}
+	return result, nil
+}
+
+// getVariableValue returns the value of a configuration variable, from the
+// defaults if set there; the value is false if the variable has no value
+func getVariableValue(config *model.ConfigurationVariable, defaults map[string]string) (string, bool) {
+	value := config.Default
+
+	if defaultValue, ok := defaults[config.Name]; ok {
+		value = defaultValue
+	}
+
+	if value == nil {
+		return "", false
+	}
+
+	valueAsString, ok := value.(string)
+	if !ok {
+		return fmt.Sprintf("%v", value), true
+	}
+
+	stringifiedValue, err := strconv.Unquote(fmt.Sprintf(`"%s"`, valueAsString))
+	if err != nil {
+		return valueAsString, true
+	}
+	return stringifiedValue, true
+}
+
 func getSecurityContext(role *model.Role) *v1.SecurityContext {
 	privileged := true
 
 	sc := &v1.SecurityContext{}
+	if role.Run.IsPrivileged() {
+		// Host device nodes can only be used by privileged containers
+		sc.Privileged = &privileged
+		return sc
+	}
 	for _, c := range role.Run.Capabilities {
 		c = strings.ToUpper(c)
-		if c == "ALL" {
-			sc.Privileged = &privileged
-			return sc
-		}
 		if sc.Capabilities == nil {
 			sc.Capabilities = &v1.Capabilities{}
 		}
@@ -292,10 +565,44 @@ func getSecurityContext(role *model.Role) *v1.SecurityContext {
 	return sc
 }
 
-func getContainerLivenessProbe(role *model.Role) *v1.Probe {
-	switch role.Type {
-	case model.RoleTypeBosh:
-		return &v1.Probe{
+// ProbeSource describes where a probe in the pod spec comes from
+type ProbeSource string
+
+// These are the probe sources, from highest to lowest precedence
+const (
+	ProbeSourceHealthCheck = ProbeSource("healthcheck")
+	ProbeSourceHealthShim  = ProbeSource("health-shim")
+	ProbeSourceMonit       = ProbeSource("monit")
+	ProbeSourceNone        = ProbeSource("none")
+)
+
+// monitReadyFile is created by post-start.sh once the templates of the role
+// rendered and monit reports all other processes of the role as running
+const monitReadyFile = "/var/vcap/monit/ready"
+
+// RoleProbes are the probes used for the containers of a role, and where
+// each of them comes from
+type RoleProbes struct {
+	Liveness        *v1.Probe
+	LivenessSource  ProbeSource
+	Readiness       *v1.Probe
+	ReadinessSource ProbeSource
+}
+
+// ResolveProbes determines the probes for the containers of a role. For each
+// probe, an explicit healthcheck in the role manifest wins over the health
+// shim, which wins over a probe derived from monit; both are only available
+// for bosh roles. Otherwise the container gets no probe. Health checks apply
+// to readiness, or to liveness with their liveness check; the health shim
+// only applies to readiness.
+func ResolveProbes(role *model.Role) (*RoleProbes, error) {
+	probes := &RoleProbes{
+		LivenessSource:  ProbeSourceNone,
+		ReadinessSource: ProbeSourceNone,
+	}
+
+	if role.Type == model.RoleTypeBosh {
+		probes.Liveness = &v1.Probe{
 			Handler: v1.Handler{
 				TCPSocket: &v1.TCPSocketAction{
 					Port: intstr.FromInt(monitPort),
@@ -304,70 +611,104 @@ func getContainerLivenessProbe(role *model.Role) *v1.Probe {
 			// TODO: make this configurable (figure out where the knob should live)
 			InitialDelaySeconds: 600,
 		}
-	default:
-		return nil
-	}
-}
+		probes.LivenessSource = ProbeSourceMonit
 
-func getContainerReadinessProbe(role *model.Role) (*v1.Probe, error) {
-	if role.Run == nil {
-		return nil, nil
-	}
-	if role.Run.HealthCheck != nil {
-		if role.Run.HealthCheck.URL != "" {
-			return getContainerURLReadinessProbe(role)
-		}
-		if role.Run.HealthCheck.Port != 0 {
-			return &v1.Probe{
-				Handler: v1.Handler{
-					TCPSocket: &v1.TCPSocketAction{
-						Port: intstr.FromInt(int(role.Run.HealthCheck.Port)),
-					},
+		probes.Readiness = &v1.Probe{
+			Handler: v1.Handler{
+				Exec: &v1.ExecAction{
+					Command: []string{"test", "-f", monitReadyFile},
 				},
-			}, nil
+			},
 		}
-		if len(role.Run.HealthCheck.Command) > 0 {
-			return &v1.Probe{
-				Handler: v1.Handler{
-					Exec: &v1.ExecAction{
-						Command: role.Run.HealthCheck.Command,
-					},
+		probes.ReadinessSource = ProbeSourceMonit
+	}
+
+	if port := role.HealthShimPort(); port != 0 {
+		probes.Readiness = &v1.Probe{
+			Handler: v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Port:   intstr.FromInt(int(port)),
+					Path:   model.HealthShimPath,
+					Scheme: v1.URISchemeHTTP,
 				},
-			}, nil
+			},
 		}
+		probes.ReadinessSource = ProbeSourceHealthShim
 	}
-	switch role.Type {
-	case model.RoleTypeBosh:
-		var readinessPort *model.RoleRunExposedPort
-		for _, port := range role.Run.ExposedPorts {
-			if strings.ToUpper(port.Protocol) != "TCP" {
-				continue
-			}
-			if readinessPort == nil {
-				readinessPort = port
-			}
+
+	if role.Run == nil || role.Run.HealthCheck == nil {
+		return probes, nil
+	}
+
+	readiness, err := getHealthCheckProbe(role, role.Run.HealthCheck)
+	if err != nil {
+		return nil, err
+	}
+	if readiness != nil {
+		probes.Readiness = readiness
+		probes.ReadinessSource = ProbeSourceHealthCheck
+	}
+
+	if role.Run.HealthCheck.Liveness != nil {
+		liveness, err := getHealthCheckProbe(role, role.Run.HealthCheck.Liveness)
+		if err != nil {
+			return nil, err
 		}
-		if readinessPort == nil {
-			return nil, nil
+		if liveness != nil {
+			probes.Liveness = liveness
+			probes.LivenessSource = ProbeSourceHealthCheck
 		}
-		probePort, _, err := parsePortRange(readinessPort.Internal, readinessPort.Name, "internal")
+	}
+
+	return probes, nil
+}
+
+func getContainerReadinessProbe(role *model.Role) (*v1.Probe, error) {
+	probes, err := ResolveProbes(role)
+	if err != nil {
+		return nil, err
+	}
+	return probes.Readiness, nil
+}
+
+// getHealthCheckProbe returns the probe for a health check of the role, if the
+// check checks anything itself
+func getHealthCheckProbe(role *model.Role, check *model.HealthCheck) (*v1.Probe, error) {
+	var probe *v1.Probe
+	switch {
+	case check.URL != "":
+		var err error
+		probe, err = getContainerURLProbe(role, check)
 		if err != nil {
 			return nil, err
 		}
-		return &v1.Probe{
+	case check.Port != 0:
+		probe = &v1.Probe{
 			Handler: v1.Handler{
 				TCPSocket: &v1.TCPSocketAction{
-					Port: intstr.FromInt(int(probePort)),
+					Port: intstr.FromInt(int(check.Port)),
 				},
 			},
-		}, nil
+		}
+	case len(check.Command) > 0:
+		probe = &v1.Probe{
+			Handler: v1.Handler{
+				Exec: &v1.ExecAction{
+					Command: check.Command,
+				},
+			},
+		}
 	default:
 		return nil, nil
 	}
+
+	probe.InitialDelaySeconds = check.InitialDelay
+	probe.TimeoutSeconds = check.Timeout
+	return probe, nil
 }
 
-func getContainerURLReadinessProbe(role *model.Role) (*v1.Probe, error) {
-	probeURL, err := url.Parse(role.Run.HealthCheck.URL)
+func getContainerURLProbe(role *model.Role, check *model.HealthCheck) (*v1.Probe, error) {
+	probeURL, err := url.Parse(check.URL)
 	if err != nil {
 		return nil, fmt.Errorf("Invalid URL health check for %s: %s", role.Name, err)
 	}
@@ -407,7 +748,7 @@ func getContainerURLReadinessProbe(role *model.Role) (*v1.Probe, error) {
 			Value: base64.StdEncoding.EncodeToString([]byte(probeURL.User.String())),
 		})
 	}
-	for key, value := range role.Run.HealthCheck.Headers {
+	for key, value := range check.Headers {
 		headers = append(headers, v1.HTTPHeader{
 			Name:  http.CanonicalHeaderKey(key),
 			Value: value,
@@ -127,6 +127,128 @@ func TestPodGetVolumeMounts(t *testing.T) {
 	assert.False(sharedMount.ReadOnly)
 }
 
+func TestPodDevices(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	role.Run.Devices = []*model.RoleRunDevice{
+		{Path: "/dev/net/tun"},
+		{Resource: "nvidia.com/gpu", Count: 2},
+	}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal([]v1.Volume{{
+		Name: "dev-net-tun",
+		VolumeSource: v1.VolumeSource{
+			HostPath: &v1.HostPathVolumeSource{Path: "/dev/net/tun"},
+		},
+	}}, pod.Spec.Volumes)
+
+	container := pod.Spec.Containers[0]
+	assert.Contains(container.VolumeMounts, v1.VolumeMount{Name: "dev-net-tun", MountPath: "/dev/net/tun"})
+	if assert.NotNil(container.SecurityContext) && assert.NotNil(container.SecurityContext.Privileged) {
+		assert.True(*container.SecurityContext.Privileged)
+	}
+	gpus := container.Resources.Limits[v1.ResourceName("nvidia.com/gpu")]
+	assert.Equal("2", gpus.String())
+}
+
+func TestPodSecurityContext(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	assert.Nil(getSecurityContext(role), "Roles without capabilities have no security context")
+
+	role.Run.Capabilities = []string{"NET_ADMIN", "SYS_ADMIN"}
+	sc := getSecurityContext(role)
+	if assert.NotNil(sc) && assert.NotNil(sc.Capabilities) {
+		assert.Nil(sc.Privileged)
+		assert.Equal([]v1.Capability{"NET_ADMIN", "SYS_ADMIN"}, sc.Capabilities.Add)
+	}
+
+	role.Run.Privileged = true
+	sc = getSecurityContext(role)
+	if assert.NotNil(sc) && assert.NotNil(sc.Privileged) {
+		assert.True(*sc.Privileged)
+		assert.Nil(sc.Capabilities, "Privileged containers have all capabilities")
+	}
+}
+
+func TestPodExternalMounts(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	role.Run.ExternalMounts = []*model.RoleRunExternalMount{
+		{ConfigMap: "corporate-ca", Path: "/etc/ssl/corporate"},
+		{Secret: "ldap", Path: "/etc/ldap", Optional: true},
+	}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal([]v1.Volume{
+		{
+			Name: "external-mount-0",
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: "corporate-ca"},
+				},
+			},
+		},
+		{
+			Name: "external-mount-1",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{SecretName: "ldap"},
+			},
+		},
+	}, pod.Spec.Volumes)
+
+	container := pod.Spec.Containers[0]
+	assert.Contains(container.VolumeMounts, v1.VolumeMount{Name: "external-mount-0", MountPath: "/etc/ssl/corporate", ReadOnly: true})
+	assert.Contains(container.VolumeMounts, v1.VolumeMount{Name: "external-mount-1", MountPath: "/etc/ldap", ReadOnly: true})
+}
+
+func TestPodDockerRole(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, _ := serviceTestLoadRole(assert, "non-bosh-roles.yml")
+	if manifest == nil {
+		return
+	}
+	role := manifest.LookupRole("dockerrole")
+	if !assert.NotNil(role) {
+		return
+	}
+
+	deployment, svc, err := NewDeployment(role, &ExportSettings{Registry: "docker.example.com", Organization: "cf"})
+	if !assert.NoError(err) {
+		return
+	}
+
+	if assert.Len(deployment.Spec.Template.Spec.Containers, 1) {
+		assert.Equal("mysql:5.7", deployment.Spec.Template.Spec.Containers[0].Image)
+	}
+	assert.Equal(int32(1), *deployment.Spec.Replicas)
+	if assert.NotNil(svc) && assert.Len(svc.Spec.Ports, 1) {
+		assert.Equal(int32(3306), svc.Spec.Ports[0].Port)
+	}
+}
+
 func TestPodGetEnvVars(t *testing.T) {
 	assert := assert.New(t)
 	role := podTestLoadRole(assert)
@@ -181,6 +303,383 @@ func TestPodGetEnvVars(t *testing.T) {
 	}
 }
 
+func TestPodGetEnvVarsFromSecret(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	if !assert.NoError(err) {
+		return
+	}
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	release, err := model.NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+	if !assert.NoError(err) {
+		return
+	}
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/secrets.yml")
+	manifest, err := model.LoadRoleManifest(manifestPath, []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	// Values from the defaults don't replace the secret
+	vars, err := getEnvVars(manifest.LookupRole("myrole"), map[string]string{"DB_PASSWORD": "hunter2"})
+	if !assert.NoError(err) {
+		return
+	}
+
+	found := false
+	for _, result := range vars {
+		if result.Name == "DB_PASSWORD" {
+			found = true
+			assert.Empty(result.Value)
+			if assert.NotNil(result.ValueFrom) && assert.NotNil(result.ValueFrom.SecretKeyRef) {
+				assert.Equal("db-creds", result.ValueFrom.SecretKeyRef.Name)
+				assert.Equal("password", result.ValueFrom.SecretKeyRef.Key)
+			}
+		}
+	}
+	assert.True(found, "failed to find expected variable")
+}
+
+func TestPodRunEnvVars(t *testing.T) {
+	assert := assert.New(t)
+
+	_, role := serviceTestLoadRole(assert, "run-env.yml")
+	if role == nil {
+		return
+	}
+
+	discovery, err := NewDiscovery(DiscoveryConsul, "")
+	if !assert.NoError(err) {
+		return
+	}
+	pod, err := NewPodTemplate(role, &ExportSettings{Discovery: discovery})
+	if !assert.NoError(err) {
+		return
+	}
+
+	vars := pod.Spec.Containers[0].Env
+	index := map[string]int{}
+	for i, envVar := range vars {
+		index[envVar.Name] = i
+	}
+	if assert.Contains(index, "DATABASE_URL") && assert.Contains(index, "DB_USER") {
+		assert.Equal("mysql://$(DB_USER)@mysql.service.consul:3306/app", vars[index["DATABASE_URL"]].Value)
+		// Kubernetes only expands the variables defined before
+		assert.True(index["DB_USER"] < index["DATABASE_URL"])
+	}
+	if assert.Contains(index, "LOG_FORMAT") {
+		assert.Equal("json", vars[index["LOG_FORMAT"]].Value)
+	}
+}
+
+func TestPodGetAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	annotations, err := getPodAnnotations(role)
+	assert.NoError(err)
+	assert.Nil(annotations)
+
+	role.Run.Sysctls = map[string]string{
+		"net.core.somaxconn":           "1024",
+		"net.ipv4.tcp_syncookies":      "1",
+		"kernel.shm_rmid_forced":       "1",
+		"net.ipv4.tcp_fin_timeout":     "30",
+		"net.ipv4.ip_local_port_range": "1024 65535",
+	}
+	annotations, err = getPodAnnotations(role)
+	assert.NoError(err)
+	assert.Equal(map[string]string{
+		"security.alpha.kubernetes.io/sysctls":        "kernel.shm_rmid_forced=1,net.ipv4.ip_local_port_range=1024 65535,net.ipv4.tcp_syncookies=1",
+		"security.alpha.kubernetes.io/unsafe-sysctls": "net.core.somaxconn=1024,net.ipv4.tcp_fin_timeout=30",
+	}, annotations)
+}
+
+func TestPodGetContainerResources(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+	role.Run.Memory = 256
+	role.Run.VirtualCPUs = 2
+
+	resources := getContainerResources(role, &ExportSettings{UseMemoryLimits: true})
+	memory := resources.Requests[v1.ResourceMemory]
+	assert.Equal("256Mi", memory.String())
+	memory = resources.Limits[v1.ResourceMemory]
+	assert.Equal("256Mi", memory.String())
+	cpu := resources.Requests[v1.ResourceCPU]
+	assert.Equal("2", cpu.String())
+	_, ok := resources.Limits[v1.ResourceCPU]
+	assert.False(ok, "CPUs should not be limited")
+
+	resources = getContainerResources(role, &ExportSettings{})
+	memory = resources.Requests[v1.ResourceMemory]
+	assert.Equal("256Mi", memory.String(), "Memory is requested without memory limits too")
+	assert.Empty(resources.Limits, "Memory is only limited with memory limits")
+	cpu = resources.Requests[v1.ResourceCPU]
+	assert.Equal("2", cpu.String())
+
+	role.Run.Memory = 0
+	role.Run.VirtualCPUs = 0
+	resources = getContainerResources(role, &ExportSettings{UseMemoryLimits: true})
+	assert.Empty(resources.Requests)
+	assert.Empty(resources.Limits)
+}
+
+func TestPodGuaranteedQoS(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+	role.Run.Memory = 256
+	role.Run.VirtualCPUs = 2
+	role.Run.CPUPinning = true
+
+	resources := getContainerResources(role, &ExportSettings{})
+	assert.Equal(resources.Requests, resources.Limits, "Guaranteed pods are limited to their requests, even without memory limits")
+	cpu := resources.Limits[v1.ResourceCPU]
+	assert.Equal("2", cpu.String())
+	memory := resources.Limits[v1.ResourceMemory]
+	assert.Equal("256Mi", memory.String())
+}
+
+func TestPodHugePages(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+	role.Run.Memory = 256
+	role.Run.HugePages = map[string]int{"2Mi": 512}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+
+	resources := pod.Spec.Containers[0].Resources
+	hugePages := resources.Limits["hugepages-2Mi"]
+	assert.Equal("512Mi", hugePages.String())
+	hugePages = resources.Requests["hugepages-2Mi"]
+	assert.Equal("512Mi", hugePages.String())
+	memory := resources.Requests[v1.ResourceMemory]
+	assert.Equal("256Mi", memory.String(), "Huge pages need a memory request")
+	_, ok := resources.Limits[v1.ResourceMemory]
+	assert.False(ok, "Memory should only be limited with memory limits")
+
+	assert.Contains(pod.Spec.Volumes, v1.Volume{
+		Name:         "hugepages",
+		VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{Medium: "HugePages"}},
+	})
+	assert.Contains(pod.Spec.Containers[0].VolumeMounts, v1.VolumeMount{Name: "hugepages", MountPath: "/dev/hugepages"})
+
+	role.Run.HugePages["1Gi"] = 2048
+	assert.Equal([]v1.VolumeMount{
+		{Name: "hugepages-1gi", MountPath: "/dev/hugepages-1Gi"},
+		{Name: "hugepages-2mi", MountPath: "/dev/hugepages-2Mi"},
+	}, getHugePagesVolumeMounts(role), "Several page sizes each get a mount")
+}
+
+func TestPodTmpfsVolumes(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+	role.Run.Memory = 256
+	role.Run.TmpfsVolumes = []*model.RoleRunTmpfsVolume{{Tag: "shm", Path: "/dev/shm", Size: 64}}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{UseMemoryLimits: true})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Contains(pod.Spec.Volumes, v1.Volume{
+		Name:         "shm",
+		VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{Medium: v1.StorageMediumMemory}},
+	})
+	container := pod.Spec.Containers[0]
+	assert.Contains(container.VolumeMounts, v1.VolumeMount{Name: "shm", MountPath: "/dev/shm"})
+	memory := container.Resources.Limits[v1.ResourceMemory]
+	assert.Equal("320Mi", memory.String(), "The tmpfs volumes count towards the memory of the role")
+
+	pod, err = NewPodTemplate(role, &ExportSettings{})
+	if assert.NoError(err) {
+		memory = pod.Spec.Containers[0].Resources.Requests[v1.ResourceMemory]
+		assert.Equal("320Mi", memory.String(), "The tmpfs volumes are requested without memory limits too")
+	}
+}
+
+func TestPodExtendedResources(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	role.Run.Resources = map[string]int{"nvidia.com/gpu": 1}
+	role.Run.NodeSelector = map[string]string{"cloud.google.com/gke-accelerator": "nvidia-tesla-k80"}
+	role.Run.Tolerations = []*model.RoleRunToleration{
+		{Key: "dedicated", Value: "ml", Effect: model.TolerationEffectNoSchedule},
+	}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+
+	gpus := pod.Spec.Containers[0].Resources.Limits[v1.ResourceName("nvidia.com/gpu")]
+	assert.Equal("1", gpus.String())
+	assert.Equal(map[string]string{"cloud.google.com/gke-accelerator": "nvidia-tesla-k80"}, pod.Spec.NodeSelector)
+	assert.Equal(
+		`[{"key":"dedicated","value":"ml","effect":"NoSchedule"},{"key":"nvidia.com/gpu","operator":"Exists","effect":"NoSchedule"}]`,
+		pod.ObjectMeta.Annotations["scheduler.alpha.kubernetes.io/tolerations"])
+}
+
+func TestPodAffinity(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.NotContains(pod.ObjectMeta.Annotations, "scheduler.alpha.kubernetes.io/affinity")
+
+	role.Run.Affinity = []*model.RoleRunAffinity{
+		{Anti: true},
+		{Role: "cache", Weight: 50},
+		{Role: "other", Anti: true, Topology: "failure-domain.beta.kubernetes.io/zone", Weight: 10},
+	}
+	pod, err = NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(
+		`{"podAffinity":{"preferredDuringSchedulingIgnoredDuringExecution":[`+
+			`{"weight":50,"podAffinityTerm":{"labelSelector":{"matchLabels":{"skiff-role-name":"cache"}},"namespaces":null,"topologyKey":"kubernetes.io/hostname"}}]},`+
+			`"podAntiAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":[`+
+			`{"labelSelector":{"matchLabels":{"skiff-role-name":"myrole"}},"namespaces":null,"topologyKey":"kubernetes.io/hostname"}],`+
+			`"preferredDuringSchedulingIgnoredDuringExecution":[`+
+			`{"weight":10,"podAffinityTerm":{"labelSelector":{"matchLabels":{"skiff-role-name":"other"}},"namespaces":null,"topologyKey":"failure-domain.beta.kubernetes.io/zone"}}]}}`,
+		pod.ObjectMeta.Annotations["scheduler.alpha.kubernetes.io/affinity"])
+}
+
+func TestPodPreStopHook(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Nil(pod.Spec.Containers[0].Lifecycle, "Roles without job dependencies have no hooks")
+
+	workDir, err := os.Getwd()
+	if !assert.NoError(err) {
+		return
+	}
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	release, err := model.NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+	if !assert.NoError(err) {
+		return
+	}
+	manifest, err := model.LoadRoleManifest(filepath.Join(workDir, "../test-assets/role-manifests/shutdown-order.yml"), []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	pod, err = NewPodTemplate(manifest.LookupRole("myrole"), &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+	lifecycle := pod.Spec.Containers[0].Lifecycle
+	if assert.NotNil(lifecycle) && assert.NotNil(lifecycle.PreStop) && assert.NotNil(lifecycle.PreStop.Exec) {
+		assert.Equal([]string{"/opt/hcf/pre-stop.sh"}, lifecycle.PreStop.Exec.Command)
+	}
+}
+
+func TestPodTerminationGracePeriod(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Nil(pod.Spec.TerminationGracePeriodSeconds, "Roles without a grace period use the kube default")
+
+	role.Run.GracePeriod = 600
+	pod, err = NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+	if assert.NotNil(pod.Spec.TerminationGracePeriodSeconds) {
+		assert.Equal(int64(600), *pod.Spec.TerminationGracePeriodSeconds)
+	}
+}
+
+func TestPodSidecars(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	role.Run.Sidecars = []*model.RoleRunSidecar{
+		{
+			Name:    "log-forwarder",
+			Image:   "fluent/fluent-bit",
+			Command: []string{"/fluent-bit/bin/fluent-bit"},
+			Mounts: []*model.RoleRunSidecarMount{
+				{Path: "/var/vcap/sys/log", MountPath: "/logs", ReadOnly: true},
+				{Path: "/mnt/persistent"},
+			},
+		},
+	}
+
+	pod, err := NewPodTemplate(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+	if !assert.Len(pod.Spec.Containers, 2) {
+		return
+	}
+
+	sidecar := pod.Spec.Containers[1]
+	assert.Equal("log-forwarder", sidecar.Name)
+	assert.Equal("fluent/fluent-bit", sidecar.Image)
+	assert.Equal([]string{"/fluent-bit/bin/fluent-bit"}, sidecar.Command)
+	assert.Equal([]v1.VolumeMount{
+		{Name: "sidecar-0", MountPath: "/logs", ReadOnly: true},
+		{Name: "persistent-volume", MountPath: "/mnt/persistent"},
+	}, sidecar.VolumeMounts)
+	assert.Nil(sidecar.LivenessProbe, "The probes are for the role container")
+	assert.Nil(sidecar.ReadinessProbe, "The probes are for the role container")
+
+	assert.Contains(pod.Spec.Containers[0].VolumeMounts, v1.VolumeMount{Name: "sidecar-0", MountPath: "/var/vcap/sys/log"})
+	assert.Contains(pod.Spec.Volumes, v1.Volume{
+		Name:         "sidecar-0",
+		VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+	})
+}
+
 func TestPodGetContainerPorts(t *testing.T) {
 	assert := assert.New(t)
 	role := podTestLoadRole(assert)
@@ -340,9 +839,15 @@ func TestPodGetContainerReadinessProbe(t *testing.T) {
 		err      string
 	}{
 		{
-			desc:     "No probe",
-			probe:    nil,
-			expected: nil,
+			desc:  "No probe falls back to monit",
+			probe: nil,
+			expected: &v1.Probe{
+				Handler: v1.Handler{
+					Exec: &v1.ExecAction{
+						Command: []string{"test", "-f", "/var/vcap/monit/ready"},
+					},
+				},
+			},
 		},
 		{
 			desc: "Port probe",
@@ -513,3 +1018,122 @@ func TestPodGetContainerReadinessProbe(t *testing.T) {
 		}
 	}
 }
+
+func TestPodResolveProbes(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	role.Run.HealthCheck = nil
+	probes, err := ResolveProbes(role)
+	if assert.NoError(err) {
+		assert.Equal(ProbeSourceMonit, probes.LivenessSource)
+		assert.Equal(ProbeSourceMonit, probes.ReadinessSource)
+		assert.Equal(intstr.FromInt(monitPort), probes.Liveness.TCPSocket.Port)
+	}
+
+	role.Run.HealthCheck = &model.HealthCheck{Port: 1234}
+	probes, err = ResolveProbes(role)
+	if assert.NoError(err) {
+		assert.Equal(ProbeSourceMonit, probes.LivenessSource)
+		assert.Equal(ProbeSourceHealthCheck, probes.ReadinessSource)
+		assert.Equal(intstr.FromInt(1234), probes.Readiness.TCPSocket.Port)
+	}
+
+	role.Type = model.RoleTypeBoshTask
+	role.Run.HealthCheck = nil
+	probes, err = ResolveProbes(role)
+	if assert.NoError(err) {
+		assert.Equal(ProbeSourceNone, probes.LivenessSource)
+		assert.Nil(probes.Liveness)
+		assert.Equal(ProbeSourceNone, probes.ReadinessSource)
+		assert.Nil(probes.Readiness)
+	}
+	role.Type = model.RoleTypeBosh
+}
+
+func TestPodResolveProbesHealthShim(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+
+	role.Run.HealthCheck = nil
+	role.Run.HealthShim = &model.RoleRunHealthShim{Port: 9000}
+	defer func() { role.Run.HealthShim = nil }()
+
+	probes, err := ResolveProbes(role)
+	if assert.NoError(err) {
+		assert.Equal(ProbeSourceMonit, probes.LivenessSource)
+		assert.Equal(ProbeSourceHealthShim, probes.ReadinessSource)
+		if assert.NotNil(probes.Readiness.HTTPGet) {
+			assert.Equal(intstr.FromInt(9000), probes.Readiness.HTTPGet.Port)
+			assert.Equal("/healthz", probes.Readiness.HTTPGet.Path)
+			assert.Equal(v1.URISchemeHTTP, probes.Readiness.HTTPGet.Scheme)
+		}
+	}
+}
+
+func TestPodResolveProbesLiveness(t *testing.T) {
+	assert := assert.New(t)
+	role := podTestLoadRole(assert)
+	if role == nil {
+		return
+	}
+	defer func() { role.Run.HealthCheck = nil }()
+
+	role.Run.HealthCheck = &model.HealthCheck{
+		Port:         1234,
+		InitialDelay: 10,
+		Timeout:      2,
+		Liveness: &model.HealthCheck{
+			Command:      []string{"pgrep", "tor"},
+			InitialDelay: 300,
+		},
+	}
+	probes, err := ResolveProbes(role)
+	if assert.NoError(err) {
+		assert.Equal(ProbeSourceHealthCheck, probes.ReadinessSource)
+		assert.Equal(int32(10), probes.Readiness.InitialDelaySeconds)
+		assert.Equal(int32(2), probes.Readiness.TimeoutSeconds)
+		assert.Equal(ProbeSourceHealthCheck, probes.LivenessSource)
+		if assert.NotNil(probes.Liveness.Exec) {
+			assert.Equal([]string{"pgrep", "tor"}, probes.Liveness.Exec.Command)
+		}
+		assert.Equal(int32(300), probes.Liveness.InitialDelaySeconds)
+		assert.Equal(int32(0), probes.Liveness.TimeoutSeconds)
+	}
+
+	// Without a readiness check, the readiness probe comes from monit
+	role.Run.HealthCheck = &model.HealthCheck{
+		Liveness: &model.HealthCheck{Port: 1234},
+	}
+	probes, err = ResolveProbes(role)
+	if assert.NoError(err) {
+		assert.Equal(ProbeSourceMonit, probes.ReadinessSource)
+		assert.Equal(ProbeSourceHealthCheck, probes.LivenessSource)
+		assert.Equal(intstr.FromInt(1234), probes.Liveness.TCPSocket.Port)
+	}
+}
+
+func TestPodDockerRoleWithoutRun(t *testing.T) {
+	assert := assert.New(t)
+
+	_, role := serviceTestLoadRole(assert, "docker-role-no-run.yml")
+	if role == nil {
+		return
+	}
+
+	deployment, svc, err := NewDeployment(role, &ExportSettings{Registry: "docker.example.com", Organization: "cf"})
+	if !assert.NoError(err) {
+		return
+	}
+	if assert.Len(deployment.Spec.Template.Spec.Containers, 1) {
+		assert.Equal("redis:4", deployment.Spec.Template.Spec.Containers[0].Image)
+	}
+	assert.Equal(int32(1), *deployment.Spec.Replicas)
+	assert.Nil(svc, "roles without exposed ports have no service")
+}
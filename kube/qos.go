@@ -0,0 +1,84 @@
+package kube
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+
+	"k8s.io/client-go/pkg/api/resource"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// hugePagesResourceName returns the name of the resource for huge pages of a size
+func hugePagesResourceName(size string) v1.ResourceName {
+	return v1.ResourceName(fmt.Sprintf("hugepages-%s", size))
+}
+
+// addHugePagesResources adds the huge pages of a role to the resources of its
+// containers; huge pages are requested and limited to the same amount
+func addHugePagesResources(role *model.Role, resources *v1.ResourceRequirements) {
+	for _, size := range role.Run.GetHugePageSizes() {
+		if resources.Requests == nil {
+			resources.Requests = v1.ResourceList{}
+		}
+		if resources.Limits == nil {
+			resources.Limits = v1.ResourceList{}
+		}
+		amount := resource.MustParse(fmt.Sprintf("%dMi", role.Run.HugePages[size]))
+		resources.Requests[hugePagesResourceName(size)] = amount
+		resources.Limits[hugePagesResourceName(size)] = amount
+	}
+}
+
+// hugePagesMount is the volume of the huge pages of a size, and where the
+// role container has it
+type hugePagesMount struct {
+	name   string
+	medium v1.StorageMedium
+	path   string
+}
+
+// getHugePagesMounts returns the volumes of the huge pages of a role. A single
+// page size is mounted at /dev/hugepages; several sizes each get their own
+// mount, e.g. /dev/hugepages-2Mi.
+func getHugePagesMounts(role *model.Role) []hugePagesMount {
+	sizes := role.Run.GetHugePageSizes()
+	if len(sizes) == 1 {
+		return []hugePagesMount{{name: "hugepages", medium: "HugePages", path: "/dev/hugepages"}}
+	}
+
+	var result []hugePagesMount
+	for _, size := range sizes {
+		result = append(result, hugePagesMount{
+			name:   fmt.Sprintf("hugepages-%s", strings.ToLower(size)),
+			medium: v1.StorageMedium(fmt.Sprintf("HugePages-%s", size)),
+			path:   fmt.Sprintf("/dev/hugepages-%s", size),
+		})
+	}
+	return result
+}
+
+// getHugePagesVolumes returns the volumes of the huge pages of a role
+func getHugePagesVolumes(role *model.Role) []v1.Volume {
+	var result []v1.Volume
+	for _, mount := range getHugePagesMounts(role) {
+		result = append(result, v1.Volume{
+			Name:         mount.name,
+			VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{Medium: mount.medium}},
+		})
+	}
+	return result
+}
+
+// getHugePagesVolumeMounts returns the mounts of the huge pages of a role
+func getHugePagesVolumeMounts(role *model.Role) []v1.VolumeMount {
+	var result []v1.VolumeMount
+	for _, mount := range getHugePagesMounts(role) {
+		result = append(result, v1.VolumeMount{
+			Name:      mount.name,
+			MountPath: mount.path,
+		})
+	}
+	return result
+}
@@ -0,0 +1,28 @@
+package kube
+
+import (
+	"github.com/hpcloud/fissile/model"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// getSecretReferenceEnvVars returns the environment variables the secret
+// references in the configuration templates of a role are read from, set
+// from the keys of the secrets
+func getSecretReferenceEnvVars(role *model.Role) []v1.EnvVar {
+	var result []v1.EnvVar
+	for _, reference := range role.GetSecretReferences() {
+		result = append(result, v1.EnvVar{
+			Name: reference.EnvName,
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: reference.Secret,
+					},
+					Key: reference.Key,
+				},
+			},
+		})
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpcloud/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodGetEnvVarsFromSecretReferences(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	if !assert.NoError(err) {
+		return
+	}
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	release, err := model.NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+	if !assert.NoError(err) {
+		return
+	}
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/secret-references.yml")
+	manifest, err := model.LoadRoleManifest(manifestPath, []*model.Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	vars, err := getEnvVars(manifest.LookupRole("myrole"), map[string]string{"FOO": "foo"})
+	if !assert.NoError(err) {
+		return
+	}
+
+	keys := map[string]string{}
+	for _, result := range vars {
+		if result.ValueFrom != nil && result.ValueFrom.SecretKeyRef != nil {
+			assert.Equal("tor-keys", result.ValueFrom.SecretKeyRef.Name)
+			keys[result.Name] = result.ValueFrom.SecretKeyRef.Key
+		}
+	}
+	assert.Equal(map[string]string{
+		"SECRET_TOR_KEYS_CONTROL_PASSWORD": "control-password",
+		"SECRET_TOR_KEYS_PRIVATE_KEY":      "private.key",
+	}, keys)
+}
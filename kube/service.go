@@ -7,45 +7,108 @@ import (
 	"github.com/hpcloud/fissile/model"
 	meta "k8s.io/client-go/pkg/api/unversioned"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
 	"k8s.io/client-go/pkg/util/intstr"
 )
 
-// NewClusterIPService creates a new k8s ClusterIP service
-func NewClusterIPService(role *model.Role, headless bool) (*apiv1.Service, error) {
+// Service is a v1 Service. The vendored Kubernetes client predates the session
+// affinity timeout and the traffic policies, so its spec has them added.
+type Service struct {
+	meta.TypeMeta    `json:",inline"`
+	apiv1.ObjectMeta `json:"metadata,omitempty"`
+	Spec             ServiceSpec         `json:"spec,omitempty"`
+	Status           apiv1.ServiceStatus `json:"status,omitempty"`
+}
+
+// ServiceSpec is a v1 ServiceSpec with the fields the vendored client lacks
+type ServiceSpec struct {
+	apiv1.ServiceSpec     `json:",inline"`
+	SessionAffinityConfig *SessionAffinityConfig `json:"sessionAffinityConfig,omitempty"`
+	ExternalTrafficPolicy string                 `json:"externalTrafficPolicy,omitempty"`
+	InternalTrafficPolicy string                 `json:"internalTrafficPolicy,omitempty"`
+}
+
+// SessionAffinityConfig configures the session affinity of a service
+type SessionAffinityConfig struct {
+	ClientIP *ClientIPConfig `json:"clientIP,omitempty"`
+}
+
+// ClientIPConfig configures the ClientIP session affinity of a service
+type ClientIPConfig struct {
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// NewClusterIPService creates a new k8s service for a role; it is a ClusterIP
+// service unless the service settings of the role give it another type
+func NewClusterIPService(role *model.Role, headless bool) (*Service, error) {
 	if len(role.Run.ExposedPorts) == 0 {
 		// Kubernetes refuses to create services with no ports, so we should
 		// not return anything at all in this case
 		return nil, nil
 	}
 
-	service := &apiv1.Service{
+	service := newService(role, role.Name)
+	if headless {
+		service.ObjectMeta.Name = fmt.Sprintf("%s-pod", role.Name)
+		service.Spec.ClusterIP = apiv1.ClusterIPNone
+	} else if role.HasTag(model.RoleTagHeadless) {
+		// The service of the role resolves to its pods
+		service.Spec.ClusterIP = apiv1.ClusterIPNone
+	}
+	if err := addServicePorts(service, role.Run.ExposedPorts, headless); err != nil {
+		return nil, err
+	}
+	if !headless {
+		// The headless service only resolves to the pods; traffic to it is
+		// not routed through the service settings
+		setServiceRouting(service, role.Run.Service)
+	}
+	return service, nil
+}
+
+// NewPortService creates the service of its own that an exposed port of a
+// role has when the port carries service settings, named <role>-<port>
+func NewPortService(role *model.Role, port *model.RoleRunExposedPort) (*Service, error) {
+	service := newService(role, fmt.Sprintf("%s-%s", role.Name, port.Name))
+	if err := addServicePorts(service, []*model.RoleRunExposedPort{port}, false); err != nil {
+		return nil, err
+	}
+	setServiceRouting(service, port.Service)
+	return service, nil
+}
+
+// newService creates an empty ClusterIP service selecting the pods of a role
+func newService(role *model.Role, name string) *Service {
+	return &Service{
 		TypeMeta: meta.TypeMeta{
 			APIVersion: "v1",
 			Kind:       "Service",
 		},
 		ObjectMeta: apiv1.ObjectMeta{
-			Name: role.Name,
+			Name: name,
 		},
-		Spec: apiv1.ServiceSpec{
-			Type: apiv1.ServiceTypeClusterIP,
-			Selector: map[string]string{
-				RoleNameLabel: role.Name,
+		Spec: ServiceSpec{
+			ServiceSpec: apiv1.ServiceSpec{
+				Type: apiv1.ServiceTypeClusterIP,
+				Selector: map[string]string{
+					RoleNameLabel: role.Name,
+				},
+				Ports: make([]apiv1.ServicePort, 0, len(role.Run.ExposedPorts)),
 			},
-			Ports: make([]apiv1.ServicePort, 0, len(role.Run.ExposedPorts)),
 		},
 	}
-	if headless {
-		service.ObjectMeta.Name = fmt.Sprintf("%s-pod", role.Name)
-		service.Spec.ClusterIP = apiv1.ClusterIPNone
-	}
-	for _, portDef := range role.Run.ExposedPorts {
+}
+
+// addServicePorts adds exposed ports to a service
+func addServicePorts(service *Service, ports []*model.RoleRunExposedPort, headless bool) error {
+	for _, portDef := range ports {
 		protocol := apiv1.ProtocolTCP
 		if strings.ToUpper(portDef.Protocol) == "UDP" {
 			protocol = apiv1.ProtocolUDP
 		}
-		minPort, maxPort, err := parsePortRange(portDef.External, portDef.Name, "external")
+		minPort, maxPort, err := model.ParsePortRange(portDef.External, portDef.Name, "external")
 		if err != nil {
-			return nil, err
+			return err
 		}
 		for portNum := minPort; portNum <= maxPort; portNum++ {
 			svcPort := apiv1.ServicePort{
@@ -62,5 +125,83 @@ func NewClusterIPService(role *model.Role, headless bool) (*apiv1.Service, error
 			service.Spec.ExternalIPs = []string{"192.168.77.77"} // TODO Make this work on not-vagrant
 		}
 	}
-	return service, nil
+	return nil
+}
+
+// setServiceRouting applies the type, session affinity and traffic policies
+// of a role or port to its service
+func setServiceRouting(service *Service, settings *model.RoleRunService) {
+	if settings == nil {
+		return
+	}
+
+	service.Spec.Type = apiv1.ServiceType(settings.GetType())
+	if settings.SessionAffinity == model.SessionAffinityClientIP {
+		service.Spec.SessionAffinity = apiv1.ServiceAffinityClientIP
+		if settings.SessionAffinityTimeout != 0 {
+			timeout := settings.SessionAffinityTimeout
+			service.Spec.SessionAffinityConfig = &SessionAffinityConfig{
+				ClientIP: &ClientIPConfig{TimeoutSeconds: &timeout},
+			}
+		}
+	}
+	if service.Spec.Type != apiv1.ServiceTypeClusterIP {
+		// Kubernetes rejects it for services only reachable in the cluster
+		service.Spec.ExternalTrafficPolicy = settings.ExternalTrafficPolicy
+	}
+	service.Spec.InternalTrafficPolicy = settings.InternalTrafficPolicy
+}
+
+// serviceGenerator creates the Services for bosh roles; roles backed by a
+// StatefulSet get an additional headless service to control the pods, and
+// ports with service settings get a service of their own
+type serviceGenerator struct{}
+
+// Kind implements Generator
+func (g *serviceGenerator) Kind() string {
+	return "Service"
+}
+
+// Generate implements Generator
+func (g *serviceGenerator) Generate(role *model.Role, settings *ExportSettings) ([]runtime.Object, error) {
+	if !role.IsLongRunning() {
+		return nil, nil
+	}
+
+	headlessOptions := []bool{false}
+	if needsStatefulSet(role) {
+		headlessOptions = append(headlessOptions, true)
+	}
+
+	var result []runtime.Object
+	for _, headless := range headlessOptions {
+		service, err := NewClusterIPService(role, headless)
+		if err != nil {
+			return nil, err
+		}
+		if service != nil {
+			if annotations, _ := platformOf(settings).ServiceAnnotations(role); !headless && len(annotations) > 0 {
+				if service.ObjectMeta.Annotations == nil {
+					service.ObjectMeta.Annotations = map[string]string{}
+				}
+				for key, value := range annotations {
+					service.ObjectMeta.Annotations[key] = value
+				}
+			}
+			result = append(result, service)
+		}
+	}
+
+	for _, port := range role.Run.ExposedPorts {
+		if port.Service == nil {
+			continue
+		}
+		service, err := NewPortService(role, port)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, service)
+	}
+
+	return result, nil
 }
@@ -2,6 +2,7 @@ package kube
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,7 +12,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	yaml "gopkg.in/yaml.v2"
-	//	apiv1 "k8s.io/client-go/pkg/api/v1"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
 )
 
 func serviceTestLoadRole(assert *assert.Assertions, manifestName string) (*model.RoleManifest, *model.Role) {
@@ -149,3 +150,139 @@ func TestHeadlessServiceOK(t *testing.T) {
 	}
 	_ = isYAMLSubset(assert, expected, actual, []string{})
 }
+
+func TestServiceHeadlessTag(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := serviceTestLoadRole(assert, "exposed-ports.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+	role.Tags = append(role.Tags, model.RoleTagHeadless)
+
+	objects, err := (&serviceGenerator{}).Generate(role, &ExportSettings{})
+	if !assert.NoError(err) || !assert.Len(objects, 1) {
+		return
+	}
+	service := objects[0].(*Service)
+	assert.Equal("myrole", service.Name)
+	assert.Equal("None", service.Spec.ClusterIP)
+}
+
+func TestServiceRouting(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := serviceTestLoadRole(assert, "exposed-ports.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+
+	role.Run.Service = &model.RoleRunService{
+		Type:                   model.ServiceTypeNodePort,
+		SessionAffinity:        model.SessionAffinityClientIP,
+		SessionAffinityTimeout: 600,
+		ExternalTrafficPolicy:  model.TrafficPolicyLocal,
+		InternalTrafficPolicy:  model.TrafficPolicyCluster,
+	}
+
+	service, err := NewClusterIPService(role, false)
+	if !assert.NoError(err) {
+		return
+	}
+
+	yamlConfig := bytes.Buffer{}
+	if err := WriteYamlConfig(service, &yamlConfig); !assert.NoError(err) {
+		return
+	}
+	var expected, actual interface{}
+	if !assert.NoError(yaml.Unmarshal(yamlConfig.Bytes(), &actual)) {
+		return
+	}
+	expectedYAML := strings.Replace(`---
+			metadata:
+				name: myrole
+			spec:
+				sessionAffinity: ClientIP
+				sessionAffinityConfig:
+					clientIP:
+						timeoutSeconds: 600
+				externalTrafficPolicy: Local
+				internalTrafficPolicy: Cluster
+				type: NodePort
+	`, "\t", "    ", -1)
+	if !assert.NoError(yaml.Unmarshal([]byte(expectedYAML), &expected)) {
+		return
+	}
+	_ = isYAMLSubset(assert, expected, actual, []string{})
+
+	// The headless service is not routed through, and doesn't get the settings
+	headless, err := NewClusterIPService(role, true)
+	if assert.NoError(err) {
+		assert.Equal("", string(headless.Spec.SessionAffinity))
+		assert.Nil(headless.Spec.SessionAffinityConfig)
+		assert.Empty(headless.Spec.ExternalTrafficPolicy)
+		assert.Empty(headless.Spec.InternalTrafficPolicy)
+		assert.Equal(apiv1.ServiceTypeClusterIP, headless.Spec.Type)
+	}
+
+	// Services only reachable in the cluster have no external traffic policy
+	role.Run.Service.Type = ""
+	service, err = NewClusterIPService(role, false)
+	if assert.NoError(err) {
+		assert.Equal(apiv1.ServiceTypeClusterIP, service.Spec.Type)
+		assert.Empty(service.Spec.ExternalTrafficPolicy)
+		assert.Equal(model.TrafficPolicyCluster, service.Spec.InternalTrafficPolicy)
+	}
+}
+
+func TestPortServices(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := serviceTestLoadRole(assert, "exposed-ports.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+	port := role.Run.ExposedPorts[0]
+	port.Service = &model.RoleRunService{
+		Type:                  model.ServiceTypeLoadBalancer,
+		ExternalTrafficPolicy: model.TrafficPolicyLocal,
+	}
+
+	objects, err := (&serviceGenerator{}).Generate(role, &ExportSettings{})
+	if !assert.NoError(err) || !assert.Len(objects, 2) {
+		return
+	}
+	assert.Equal(apiv1.ServiceTypeClusterIP, objects[0].(*Service).Spec.Type, "the service of the role keeps its settings")
+
+	service := objects[1].(*Service)
+	assert.Equal(fmt.Sprintf("%s-%s", role.Name, port.Name), service.ObjectMeta.Name)
+	assert.Equal(apiv1.ServiceTypeLoadBalancer, service.Spec.Type)
+	assert.Equal(model.TrafficPolicyLocal, service.Spec.ExternalTrafficPolicy)
+	assert.Equal(map[string]string{RoleNameLabel: role.Name}, service.Spec.Selector)
+	if assert.Len(service.Spec.Ports, 1) {
+		assert.Equal(port.Name, service.Spec.Ports[0].Name)
+	}
+}
+
+func TestServicePlatformHints(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := serviceTestLoadRole(assert, "exposed-ports.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+	role.Run.PlatformHints = map[string]string{model.PlatformHintLoadBalancer: "internal"}
+	role.Run.Service = &model.RoleRunService{Type: model.ServiceTypeLoadBalancer}
+
+	objects, err := (&serviceGenerator{}).Generate(role, &ExportSettings{Platform: gcpPlatform{}})
+	if assert.NoError(err) && assert.Len(objects, 1) {
+		service := objects[0].(*Service)
+		assert.Equal(apiv1.ServiceTypeLoadBalancer, service.Spec.Type)
+		assert.Equal(map[string]string{"cloud.google.com/load-balancer-type": "Internal"}, service.ObjectMeta.Annotations)
+	}
+
+	objects, err = (&serviceGenerator{}).Generate(role, &ExportSettings{})
+	if assert.NoError(err) && assert.Len(objects, 1) {
+		assert.Empty(objects[0].(*Service).ObjectMeta.Annotations, "The hints are left out without a platform")
+	}
+}
@@ -0,0 +1,76 @@
+package kube
+
+import (
+	"fmt"
+
+	"github.com/hpcloud/fissile/model"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// sidecarVolumeName returns the name of the volume for a directory the
+// sidecars of a role share with the role container, by its position
+func sidecarVolumeName(index int) string {
+	return fmt.Sprintf("sidecar-%d", index)
+}
+
+// getSidecarVolumes returns the empty volumes of the directories the sidecars
+// of a role share with the role container, other than its volumes
+func getSidecarVolumes(role *model.Role) []v1.Volume {
+	var result []v1.Volume
+	for i := range role.Run.GetSidecarPaths() {
+		result = append(result, v1.Volume{
+			Name:         sidecarVolumeName(i),
+			VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+		})
+	}
+	return result
+}
+
+// getSidecarVolumeMounts returns the mounts of the volumes from
+// getSidecarVolumes into the role container
+func getSidecarVolumeMounts(role *model.Role) []v1.VolumeMount {
+	var result []v1.VolumeMount
+	for i, path := range role.Run.GetSidecarPaths() {
+		result = append(result, v1.VolumeMount{
+			Name:      sidecarVolumeName(i),
+			MountPath: path,
+		})
+	}
+	return result
+}
+
+// getSidecarContainers returns the containers of the sidecars of a role,
+// mounting the volumes of the role they share
+func getSidecarContainers(role *model.Role) []v1.Container {
+	paths := map[string]string{}
+	for i, path := range role.Run.GetSidecarPaths() {
+		paths[path] = sidecarVolumeName(i)
+	}
+
+	var result []v1.Container
+	for _, sidecar := range role.Run.Sidecars {
+		var mounts []v1.VolumeMount
+		for _, mount := range sidecar.Mounts {
+			volumeName := paths[mount.Path]
+			if volume := role.Run.GetVolume(mount.Path); volume != nil {
+				volumeName = volume.Tag
+			}
+			if volume := role.Run.GetTmpfsVolume(mount.Path); volume != nil {
+				volumeName = volume.Tag
+			}
+			mounts = append(mounts, v1.VolumeMount{
+				Name:      volumeName,
+				MountPath: mount.GetMountPath(),
+				ReadOnly:  mount.ReadOnly,
+			})
+		}
+		result = append(result, v1.Container{
+			Name:         sidecar.Name,
+			Image:        sidecar.Image,
+			Command:      sidecar.Command,
+			VolumeMounts: mounts,
+		})
+	}
+	return result
+}
@@ -11,8 +11,35 @@ import (
 	"k8s.io/client-go/pkg/runtime"
 )
 
+// StatefulSet is an apps/v1beta1 StatefulSet. The vendored Kubernetes client
+// does not have its update strategy, so this adds it to the vendored spec.
+type StatefulSet struct {
+	meta.TypeMeta `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+	Spec          StatefulSetSpec           `json:"spec,omitempty"`
+	Status        v1beta1.StatefulSetStatus `json:"status,omitempty"`
+}
+
+// StatefulSetSpec is the vendored StatefulSet spec, with an update strategy
+type StatefulSetSpec struct {
+	v1beta1.StatefulSetSpec `json:",inline"`
+	UpdateStrategy          *StatefulSetUpdateStrategy `json:"updateStrategy,omitempty"`
+}
+
+// StatefulSetUpdateStrategy describes how the pods of a StatefulSet are
+// replaced when its pod template changes
+type StatefulSetUpdateStrategy struct {
+	Type          string                            `json:"type"` // RollingUpdate or OnDelete
+	RollingUpdate *RollingUpdateStatefulSetStrategy `json:"rollingUpdate,omitempty"`
+}
+
+// RollingUpdateStatefulSetStrategy describes a rolling update of a StatefulSet
+type RollingUpdateStatefulSetStrategy struct {
+	Partition *int32 `json:"partition,omitempty"` // Only the pods with this ordinal or higher are updated
+}
+
 // NewStatefulSet returns a k8s stateful set for the given role
-func NewStatefulSet(role *model.Role, settings *ExportSettings) (*v1beta1.StatefulSet, *v1.List, error) {
+func NewStatefulSet(role *model.Role, settings *ExportSettings) (*StatefulSet, *v1.List, error) {
 	// For each StatefulSet, we need two services -- one for the public (inside
 	// the namespace) endpoint, and one headless service to control the pods.
 	if role == nil {
@@ -36,7 +63,18 @@ func NewStatefulSet(role *model.Role, settings *ExportSettings) (*v1beta1.Statef
 		return nil, nil, err
 	}
 
-	return &v1beta1.StatefulSet{
+	annotations, err := getDeployOrderAnnotations(role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updateStrategy, err := getStatefulSetUpdateStrategy(role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replicas := getReplicas(role, settings)
+	return &StatefulSet{
 			TypeMeta: meta.TypeMeta{
 				APIVersion: "apps/v1beta1",
 				Kind:       "StatefulSet",
@@ -46,12 +84,16 @@ func NewStatefulSet(role *model.Role, settings *ExportSettings) (*v1beta1.Statef
 				Labels: map[string]string{
 					RoleNameLabel: role.Name,
 				},
+				Annotations: addPausedAnnotation(annotations, role, settings),
 			},
-			Spec: v1beta1.StatefulSetSpec{
-				Replicas:             &role.Run.Scaling.Min,
-				ServiceName:          fmt.Sprintf("%s-pod", role.Name),
-				Template:             podTemplate,
-				VolumeClaimTemplates: volumeClaimTemplates,
+			Spec: StatefulSetSpec{
+				StatefulSetSpec: v1beta1.StatefulSetSpec{
+					Replicas:             &replicas,
+					ServiceName:          fmt.Sprintf("%s-pod", role.Name),
+					Template:             podTemplate,
+					VolumeClaimTemplates: volumeClaimTemplates,
+				},
+				UpdateStrategy: updateStrategy,
 			},
 		}, &v1.List{
 			TypeMeta: meta.TypeMeta{
@@ -69,6 +111,27 @@ func NewStatefulSet(role *model.Role, settings *ExportSettings) (*v1beta1.Statef
 		}, nil
 }
 
+// getStatefulSetUpdateStrategy returns the update strategy of the
+// StatefulSet of a role. StatefulSets can't replace all pods at once, so they
+// can't recreate. Canaries update the last pod, until the partition is lowered
+// to update the others.
+func getStatefulSetUpdateStrategy(role *model.Role) (*StatefulSetUpdateStrategy, error) {
+	switch role.Run.UpdateStrategy {
+	case model.UpdateStrategyRolling:
+		return &StatefulSetUpdateStrategy{Type: "RollingUpdate"}, nil
+	case model.UpdateStrategyRecreate:
+		return nil, fmt.Errorf("Role %s has the %s update strategy, but StatefulSets can't replace all pods at once; only roles with a Deployment can have it",
+			role.Name, model.UpdateStrategyRecreate)
+	case model.UpdateStrategyCanary:
+		partition := role.Replicas() - 1
+		return &StatefulSetUpdateStrategy{
+			Type:          "RollingUpdate",
+			RollingUpdate: &RollingUpdateStatefulSetStrategy{Partition: &partition},
+		}, nil
+	}
+	return nil, nil
+}
+
 // getVolumeClaims returns the list of persistent volume claims from a role
 func getVolumeClaims(role *model.Role) []v1.PersistentVolumeClaim {
 	totalLength := len(role.Run.PersistentVolumes) + len(role.Run.SharedVolumes)
@@ -118,3 +181,34 @@ func getVolumeClaims(role *model.Role) []v1.PersistentVolumeClaim {
 
 	return claims
 }
+
+// needsStatefulSet returns true if the role must be deployed as a StatefulSet
+// rather than a Deployment. StatefulSets create their pods one at a time, so
+// roles starting up sequentially use one too.
+func needsStatefulSet(role *model.Role) bool {
+	needsStorage := len(role.Run.PersistentVolumes) != 0 || len(role.Run.SharedVolumes) != 0
+	return role.HasTag(model.RoleTagClustered) || role.HasTag(model.RoleTagSequentialStartup) || needsStorage
+}
+
+// statefulSetGenerator creates the StatefulSets for clustered roles, roles
+// starting up sequentially, and roles with volumes
+type statefulSetGenerator struct{}
+
+// Kind implements Generator
+func (g *statefulSetGenerator) Kind() string {
+	return "StatefulSet"
+}
+
+// Generate implements Generator
+func (g *statefulSetGenerator) Generate(role *model.Role, settings *ExportSettings) ([]runtime.Object, error) {
+	if !role.IsLongRunning() || !needsStatefulSet(role) {
+		return nil, nil
+	}
+
+	statefulSet, _, err := NewStatefulSet(role, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return []runtime.Object{statefulSet}, nil
+}
@@ -56,19 +56,31 @@ func isYAMLSubset(assert *assert.Assertions, expected, actual interface{}, prefi
 	return assert.Equal(expected, actual, "unexpected value at YAML path %s", yamlPath)
 }
 
-func statefulSetTestLoadManifest(assert *assert.Assertions, manifestName string) (*model.RoleManifest, *model.Role) {
+// loadTestRoleManifest loads the role manifest of the given name from the
+// test assets, with the tor release; nil when that fails
+func loadTestRoleManifest(assert *assert.Assertions, manifestName string) *model.RoleManifest {
 	workDir, err := os.Getwd()
-	assert.NoError(err)
+	if !assert.NoError(err) {
+		return nil
+	}
 
 	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests", manifestName)
 	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
 	releasePathBoshCache := filepath.Join(releasePath, "bosh-cache")
 	release, err := model.NewDevRelease(releasePath, "", "", releasePathBoshCache)
 	if !assert.NoError(err) {
-		return nil, nil
+		return nil
 	}
 	manifest, err := model.LoadRoleManifest(manifestPath, []*model.Release{release})
 	if !assert.NoError(err) {
+		return nil
+	}
+	return manifest
+}
+
+func statefulSetTestLoadManifest(assert *assert.Assertions, manifestName string) (*model.RoleManifest, *model.Role) {
+	manifest := loadTestRoleManifest(assert, manifestName)
+	if manifest == nil {
 		return nil, nil
 	}
 
@@ -103,11 +115,11 @@ func TestStatefulSetPorts(t *testing.T) {
 	if !assert.NoError(err) {
 		return
 	}
-	var endpointService, headlessService *apiv1.Service
+	var endpointService, headlessService *Service
 
 	if assert.Len(deps.Items, 2, "Should have two services per stateful role") {
 		for _, item := range deps.Items {
-			svc := item.Object.(*apiv1.Service)
+			svc := item.Object.(*Service)
 			if svc.Spec.ClusterIP == apiv1.ClusterIPNone {
 				headlessService = svc
 			} else {
@@ -205,6 +217,90 @@ func TestStatefulSetPorts(t *testing.T) {
 	_ = isYAMLSubset(assert, expected, actual, []string{})
 }
 
+func TestStatefulSetInstances(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := statefulSetTestLoadManifest(assert, "volumes.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+
+	statefulset, _, err := NewStatefulSet(role, &ExportSettings{})
+	if assert.NoError(err) {
+		assert.Equal(role.Run.Scaling.Min, *statefulset.Spec.Replicas)
+	}
+
+	role.Run.Scaling = nil
+	role.Run.Instances = 3
+	statefulset, _, err = NewStatefulSet(role, &ExportSettings{})
+	if assert.NoError(err) {
+		assert.Equal(int32(3), *statefulset.Spec.Replicas)
+	}
+}
+
+func TestStatefulSetUpdateStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := statefulSetTestLoadManifest(assert, "volumes.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+
+	statefulset, _, err := NewStatefulSet(role, &ExportSettings{})
+	if assert.NoError(err) {
+		assert.Nil(statefulset.Spec.UpdateStrategy, "Kubernetes picks the strategy by default")
+	}
+
+	role.Run.UpdateStrategy = model.UpdateStrategyRecreate
+	_, _, err = NewStatefulSet(role, &ExportSettings{})
+	assert.EqualError(err, "Role myrole has the recreate update strategy, but StatefulSets can't replace all pods at once; only roles with a Deployment can have it")
+
+	role.Run.UpdateStrategy = model.UpdateStrategyCanary
+	role.Run.Scaling = nil
+	role.Run.Instances = 3
+	statefulset, _, err = NewStatefulSet(role, &ExportSettings{})
+	if !assert.NoError(err) {
+		return
+	}
+
+	yamlConfig := bytes.Buffer{}
+	if !assert.NoError(WriteYamlConfig(statefulset, &yamlConfig)) {
+		return
+	}
+
+	var expected, actual interface{}
+	if !assert.NoError(yaml.Unmarshal(yamlConfig.Bytes(), &actual)) {
+		return
+	}
+	expectedYAML := strings.Replace(`---
+	spec:
+		replicas: 3
+		serviceName: myrole-pod
+		updateStrategy:
+			type: RollingUpdate
+			rollingUpdate:
+				# Only the last pod is updated
+				partition: 2
+	`, "\t", "    ", -1)
+	if !assert.NoError(yaml.Unmarshal([]byte(expectedYAML), &expected)) {
+		return
+	}
+	_ = isYAMLSubset(assert, expected, actual, []string{})
+}
+
+func TestStatefulSetSequentialStartup(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest, role := statefulSetTestLoadManifest(assert, "exposed-ports.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+	assert.False(needsStatefulSet(role))
+
+	role.Tags = append(role.Tags, model.RoleTagSequentialStartup)
+	assert.True(needsStatefulSet(role))
+}
+
 func TestStatefulSetVolumes(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,159 @@
+package kube
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hpcloud/fissile/model"
+
+	meta "k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+)
+
+// tlsVolumeName returns the name of the volume with the certificate of a TLS
+// port; the https port gets https-tls
+func tlsVolumeName(port *model.RoleRunExposedPort) string {
+	name := strings.ToLower(port.Name)
+	return strings.NewReplacer("_", "-", ".", "-").Replace(name) + "-tls"
+}
+
+// TLSSecretName returns the name of the secret generated for the certificate
+// of a TLS port of a role
+func TLSSecretName(role *model.Role, port *model.RoleRunExposedPort) string {
+	return fmt.Sprintf("%s-%s", role.Name, tlsVolumeName(port))
+}
+
+// getTLSPorts returns the TLS terminated ports of a role
+func getTLSPorts(role *model.Role) []*model.RoleRunExposedPort {
+	if role.Run == nil {
+		return nil
+	}
+
+	var result []*model.RoleRunExposedPort
+	for _, port := range role.Run.ExposedPorts {
+		if port.TLS != nil {
+			result = append(result, port)
+		}
+	}
+	return result
+}
+
+// getTLSVolumes returns the secret volumes with the certificates of the TLS
+// ports of a role. Certificates read from an existing secret use that secret;
+// the others use the secret generated for the port.
+func getTLSVolumes(role *model.Role) []v1.Volume {
+	var result []v1.Volume
+	for _, port := range getTLSPorts(role) {
+		source := &v1.SecretVolumeSource{
+			SecretName: TLSSecretName(role, port),
+		}
+		certificate := role.LookupVariable(port.TLS.Certificate)
+		key := role.LookupVariable(port.TLS.Key)
+		if certificate != nil && key != nil && certificate.Secret != nil {
+			source.SecretName = certificate.Secret.Name
+			source.Items = []v1.KeyToPath{
+				{Key: certificate.Secret.Key, Path: model.TLSCertificateFile},
+				{Key: key.Secret.Key, Path: model.TLSKeyFile},
+			}
+		}
+		result = append(result, v1.Volume{
+			Name:         tlsVolumeName(port),
+			VolumeSource: v1.VolumeSource{Secret: source},
+		})
+	}
+	return result
+}
+
+// getTLSVolumeMounts returns the read-only mounts of the certificates of the
+// TLS ports of a role
+func getTLSVolumeMounts(role *model.Role) []v1.VolumeMount {
+	var result []v1.VolumeMount
+	for _, port := range getTLSPorts(role) {
+		result = append(result, v1.VolumeMount{
+			Name:      tlsVolumeName(port),
+			MountPath: port.TLSPath(),
+			ReadOnly:  true,
+		})
+	}
+	return result
+}
+
+// getTLSEnvVars returns the environment variables pointing the role at the
+// certificate and key files of its TLS ports
+func getTLSEnvVars(role *model.Role) []v1.EnvVar {
+	var result []v1.EnvVar
+	for _, port := range getTLSPorts(role) {
+		certificateName, keyName := port.TLSEnvNames()
+		result = append(result,
+			v1.EnvVar{Name: certificateName, Value: path.Join(port.TLSPath(), model.TLSCertificateFile)},
+			v1.EnvVar{Name: keyName, Value: path.Join(port.TLSPath(), model.TLSKeyFile)},
+		)
+	}
+	return result
+}
+
+// NewTLSSecret creates the secret with the certificate and key of a TLS port,
+// from the values of the configuration variables
+func NewTLSSecret(role *model.Role, port *model.RoleRunExposedPort, settings *ExportSettings) (*v1.Secret, error) {
+	data := map[string][]byte{}
+	for _, file := range []struct {
+		name     string
+		variable string
+	}{
+		{model.TLSCertificateFile, port.TLS.Certificate},
+		{model.TLSKeyFile, port.TLS.Key},
+	} {
+		variable := role.LookupVariable(file.variable)
+		if variable == nil {
+			return nil, fmt.Errorf("Role %s: TLS port %s uses unknown variable %s", role.Name, port.Name, file.variable)
+		}
+		value, ok := getVariableValue(variable, settings.Defaults)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("Role %s: TLS port %s needs a value for %s", role.Name, port.Name, file.variable)
+		}
+		data[file.name] = []byte(value)
+	}
+
+	return &v1.Secret{
+		TypeMeta: meta.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name: TLSSecretName(role, port),
+			Labels: map[string]string{
+				RoleNameLabel: role.Name,
+			},
+		},
+		Type: v1.SecretTypeTLS,
+		Data: data,
+	}, nil
+}
+
+// tlsSecretGenerator creates the secrets for the certificates of TLS ports
+// that are not read from existing secrets
+type tlsSecretGenerator struct{}
+
+// Kind implements Generator
+func (g *tlsSecretGenerator) Kind() string {
+	return "Secret"
+}
+
+// Generate implements Generator
+func (g *tlsSecretGenerator) Generate(role *model.Role, settings *ExportSettings) ([]runtime.Object, error) {
+	var result []runtime.Object
+	for _, port := range getTLSPorts(role) {
+		if certificate := role.LookupVariable(port.TLS.Certificate); certificate != nil && certificate.Secret != nil {
+			continue
+		}
+		secret, err := NewTLSSecret(role, port, settings)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, secret)
+	}
+
+	return result, nil
+}
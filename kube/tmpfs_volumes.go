@@ -0,0 +1,35 @@
+package kube
+
+import (
+	"github.com/hpcloud/fissile/model"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// getTmpfsVolumes returns the memory-backed empty volumes of the tmpfs volumes
+// of a role. This version of Kubernetes has no size limit for them; their size
+// is added to the memory the containers request instead, which also limits
+// them with memory limits, see getContainerResources.
+func getTmpfsVolumes(role *model.Role) []v1.Volume {
+	var result []v1.Volume
+	for _, volume := range role.Run.TmpfsVolumes {
+		result = append(result, v1.Volume{
+			Name:         volume.Tag,
+			VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{Medium: v1.StorageMediumMemory}},
+		})
+	}
+	return result
+}
+
+// getTmpfsVolumeMounts returns the mounts of the volumes from getTmpfsVolumes
+// into the role container
+func getTmpfsVolumeMounts(role *model.Role) []v1.VolumeMount {
+	var result []v1.VolumeMount
+	for _, volume := range role.Run.TmpfsVolumes {
+		result = append(result, v1.VolumeMount{
+			Name:      volume.Tag,
+			MountPath: volume.Path,
+		})
+	}
+	return result
+}
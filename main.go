@@ -42,8 +42,8 @@ func main() {
 
 	f := app.NewFissileApplication(version, ui)
 
+	// Errors are printed by the output profile
 	if err := cmd.Execute(f, version); err != nil {
-		ui.Println(color.RedString("%v", err))
-		sigint.DefaultHandler.Exit(1)
+		sigint.DefaultHandler.Exit(app.ExitCode(err))
 	}
 }
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"fmt"
+)
+
+// RoleManifestAddon is a set of jobs colocated on every role it applies to,
+// like the addons of BOSH runtime configs, e.g. a logging agent
+type RoleManifestAddon struct {
+	Name    string                   `yaml:"name"`
+	Jobs    []*roleJob               `yaml:"jobs"`
+	Include *RoleManifestAddonFilter `yaml:"include"` // Roles the addon applies to; all roles with jobs when not set
+	Exclude *RoleManifestAddonFilter `yaml:"exclude"` // Roles the addon doesn't apply to, even if included
+}
+
+// RoleManifestAddonFilter selects roles for an addon. A role matches when it
+// matches all the lists that are set, as with BOSH addons.
+type RoleManifestAddonFilter struct {
+	RoleTypes []RoleType `yaml:"role-types"` // Types of the roles, e.g. bosh-task
+	Roles     []string   `yaml:"roles"`      // Names of the roles
+}
+
+// applyAddons appends the jobs of the addons to the job lists of the roles
+// they apply to, in the order of the addons. Docker roles have no jobs, and
+// never get addons. A role keeps its own definition of a job of the same
+// name, so addons can replace jobs added to the roles by hand.
+func (m *RoleManifest) applyAddons() error {
+	names := map[string]bool{}
+	for _, addon := range m.Addons {
+		if err := addon.validate(); err != nil {
+			return err
+		}
+		if names[addon.Name] {
+			return fmt.Errorf("Addon %s is defined more than once", addon.Name)
+		}
+		names[addon.Name] = true
+
+		for _, role := range m.Roles {
+			if role.Type == RoleTypeDocker || !addon.appliesTo(role) {
+				continue
+			}
+			for _, job := range addon.Jobs {
+				if role.lookupRoleJob(job.Name) == nil {
+					// Every role gets a copy, so roles can't change each
+					// other's jobs
+					role.JobNameList = append(role.JobNameList, job.copy())
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validate checks that the addon has a name, jobs with a release, and filters
+// selecting roles of known types
+func (a *RoleManifestAddon) validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("Addon without a name")
+	}
+	if len(a.Jobs) == 0 {
+		return fmt.Errorf("Addon %s has no jobs", a.Name)
+	}
+	for _, job := range a.Jobs {
+		if job.Name == "" {
+			return fmt.Errorf("Addon %s has a job without a name", a.Name)
+		}
+		if job.ReleaseName == "" {
+			return fmt.Errorf("Addon %s: job %s has no release_name", a.Name, job.Name)
+		}
+	}
+	for _, filter := range []*RoleManifestAddonFilter{a.Include, a.Exclude} {
+		if filter == nil {
+			continue
+		}
+		if len(filter.RoleTypes) == 0 && len(filter.Roles) == 0 {
+			return fmt.Errorf("Addon %s has a filter without role-types nor roles", a.Name)
+		}
+		for _, roleType := range filter.RoleTypes {
+			switch roleType {
+			case RoleTypeBosh, RoleTypeBoshTask:
+			default:
+				return fmt.Errorf("Addon %s filters on invalid role type %s, expected %s or %s",
+					a.Name, roleType, RoleTypeBosh, RoleTypeBoshTask)
+			}
+		}
+	}
+	return nil
+}
+
+// appliesTo reports whether the addon applies to the role: whether it is
+// included, and not excluded
+func (a *RoleManifestAddon) appliesTo(role *Role) bool {
+	if a.Include != nil && !a.Include.matches(role) {
+		return false
+	}
+	return a.Exclude == nil || !a.Exclude.matches(role)
+}
+
+// matches reports whether the role matches all the lists of the filter that
+// are set
+func (f *RoleManifestAddonFilter) matches(role *Role) bool {
+	if len(f.RoleTypes) > 0 {
+		found := false
+		for _, roleType := range f.RoleTypes {
+			found = found || roleType == role.Type
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Roles) > 0 {
+		found := false
+		for _, name := range f.Roles {
+			found = found || name == role.Name
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
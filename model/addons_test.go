@@ -0,0 +1,135 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestAddons(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	release, err := NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+	if !assert.NoError(err) {
+		return
+	}
+
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/addons.yml")
+	manifest, err := LoadRoleManifest(manifestPath, []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	jobNames := func(role *Role) []string {
+		var names []string
+		for _, job := range role.Jobs {
+			names = append(names, job.Name)
+		}
+		return names
+	}
+	assert.Equal([]string{"new_hostname", "tor"}, jobNames(manifest.LookupRole("myrole")), "addon jobs are appended")
+	assert.Equal([]string{"new_hostname"}, jobNames(manifest.LookupRole("mytask")), "excluded roles get no addons")
+	assert.Empty(jobNames(manifest.LookupRole("mydockerrole")), "docker roles get no addons")
+
+	otherRole := manifest.LookupRole("otherrole")
+	assert.Equal([]string{"new_hostname", "tor"}, jobNames(otherRole), "jobs of the roles are not added twice")
+	assert.Equal([]string{"monit"}, otherRole.lookupRoleJob("tor").ExcludeTemplates, "roles keep their own definition of a job")
+}
+
+func TestApplyAddons(t *testing.T) {
+	assert := assert.New(t)
+
+	newManifest := func(addon *RoleManifestAddon) *RoleManifest {
+		return &RoleManifest{
+			Roles: Roles{
+				{Name: "api", Type: RoleTypeBosh},
+				{Name: "worker", Type: RoleTypeBosh},
+				{Name: "migrate", Type: RoleTypeBoshTask},
+			},
+			Addons: []*RoleManifestAddon{addon},
+		}
+	}
+	agent := []*roleJob{{Name: "agent", ReleaseName: "logging"}}
+
+	samples := []struct {
+		desc     string
+		addon    *RoleManifestAddon
+		expected []string
+		err      string
+	}{
+		{
+			desc:     "addons apply to all roles by default",
+			addon:    &RoleManifestAddon{Name: "logging", Jobs: agent},
+			expected: []string{"api", "worker", "migrate"},
+		},
+		{
+			desc: "included role types",
+			addon: &RoleManifestAddon{Name: "logging", Jobs: agent,
+				Include: &RoleManifestAddonFilter{RoleTypes: []RoleType{RoleTypeBoshTask}}},
+			expected: []string{"migrate"},
+		},
+		{
+			desc: "filters match all their lists",
+			addon: &RoleManifestAddon{Name: "logging", Jobs: agent,
+				Include: &RoleManifestAddonFilter{RoleTypes: []RoleType{RoleTypeBosh}, Roles: []string{"worker", "migrate"}}},
+			expected: []string{"worker"},
+		},
+		{
+			desc: "excluded roles",
+			addon: &RoleManifestAddon{Name: "logging", Jobs: agent,
+				Exclude: &RoleManifestAddonFilter{Roles: []string{"api"}}},
+			expected: []string{"worker", "migrate"},
+		},
+		{
+			desc:  "an addon without jobs",
+			addon: &RoleManifestAddon{Name: "logging"},
+			err:   "Addon logging has no jobs",
+		},
+		{
+			desc:  "a job without a release",
+			addon: &RoleManifestAddon{Name: "logging", Jobs: []*roleJob{{Name: "agent"}}},
+			err:   "Addon logging: job agent has no release_name",
+		},
+		{
+			desc: "an empty filter",
+			addon: &RoleManifestAddon{Name: "logging", Jobs: agent,
+				Exclude: &RoleManifestAddonFilter{}},
+			err: "Addon logging has a filter without role-types nor roles",
+		},
+		{
+			desc: "an invalid role type",
+			addon: &RoleManifestAddon{Name: "logging", Jobs: agent,
+				Include: &RoleManifestAddonFilter{RoleTypes: []RoleType{RoleTypeDocker}}},
+			err: "Addon logging filters on invalid role type docker, expected bosh or bosh-task",
+		},
+	}
+
+	for _, sample := range samples {
+		manifest := newManifest(sample.addon)
+		err := manifest.applyAddons()
+		if sample.err != "" {
+			assert.EqualError(err, sample.err, sample.desc)
+			continue
+		}
+		if !assert.NoError(err, sample.desc) {
+			continue
+		}
+		var roles []string
+		for _, role := range manifest.Roles {
+			if role.lookupRoleJob("agent") != nil {
+				roles = append(roles, role.Name)
+			}
+		}
+		assert.Equal(sample.expected, roles, sample.desc)
+	}
+
+	manifest := newManifest(&RoleManifestAddon{Name: "logging", Jobs: agent})
+	manifest.Addons = append(manifest.Addons, manifest.Addons[0])
+	assert.EqualError(manifest.applyAddons(), "Addon logging is defined more than once")
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"fmt"
+)
+
+// RoleRunAffinity places the pods of a role on the same topology domain as
+// the pods of a role (affinity), or on a different one (anti-affinity). A
+// role with an anti-affinity to itself spreads its replicas, e.g. across
+// nodes.
+type RoleRunAffinity struct {
+	Role     string `yaml:"role"`     // The other role; the role itself when empty
+	Anti     bool   `yaml:"anti"`     // Keep the pods apart, instead of together
+	Topology string `yaml:"topology"` // The node label of the domains; kubernetes.io/hostname (each node) by default
+	Weight   int32  `yaml:"weight"`   // 1 to 100 to only prefer the placement; 0 requires it
+}
+
+// DefaultAffinityTopology is the topology of affinities without one; each node
+// is its own domain
+const DefaultAffinityTopology = "kubernetes.io/hostname"
+
+// MaxAffinityWeight is the weight of the strongest preferred placement
+const MaxAffinityWeight = 100
+
+// GetRole returns the role the pods of role are placed relative to
+func (a *RoleRunAffinity) GetRole(role *Role) string {
+	if a.Role == "" {
+		return role.Name
+	}
+	return a.Role
+}
+
+// GetTopology returns the node label of the domains of the affinity
+func (a *RoleRunAffinity) GetTopology() string {
+	if a.Topology == "" {
+		return DefaultAffinityTopology
+	}
+	return a.Topology
+}
+
+// IsRequired reports whether the pods can only be scheduled as the affinity
+// says, instead of only preferring to
+func (a *RoleRunAffinity) IsRequired() bool {
+	return a.Weight == 0
+}
+
+// validateAffinity checks that the affinities of the roles are to roles of
+// the manifest, with valid weights
+func (m *RoleManifest) validateAffinity() error {
+	for _, role := range m.Roles {
+		if role.Run == nil {
+			continue
+		}
+		for _, affinity := range role.Run.Affinity {
+			target := affinity.GetRole(role)
+			if m.LookupRole(target) == nil {
+				return fmt.Errorf("Role %s has an affinity to unknown role %s", role.Name, target)
+			}
+			if affinity.Weight < 0 || affinity.Weight > MaxAffinityWeight {
+				return fmt.Errorf("Role %s: affinity to role %s should have a weight between 1 and %d, or 0 to require it",
+					role.Name, target, MaxAffinityWeight)
+			}
+		}
+	}
+	return nil
+}
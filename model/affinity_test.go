@@ -0,0 +1,74 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestAffinity(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc     string
+		affinity string
+		err      string
+	}{
+		{
+			desc: "Affinities to roles and to the role itself are valid",
+			affinity: `
+      - anti: true
+      - role: cache
+        topology: failure-domain.beta.kubernetes.io/zone
+        weight: 100`,
+		},
+		{
+			desc:     "Affinities are to roles of the manifest",
+			affinity: "\n      - role: missing",
+			err:      "Role db has an affinity to unknown role missing",
+		},
+		{
+			desc:     "Weights are at most 100",
+			affinity: "\n      - role: cache\n        weight: 101",
+			err:      "Role db: affinity to role cache should have a weight between 1 and 100, or 0 to require it",
+		},
+		{
+			desc:     "Weights are not negative",
+			affinity: "\n      - weight: -1",
+			err:      "Role db: affinity to role db should have a weight between 1 and 100, or 0 to require it",
+		},
+	}
+
+	for _, sample := range samples {
+		dir, err := ioutil.TempDir("", "fissile-affinity-")
+		if !assert.NoError(err) {
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		manifestPath := filepath.Join(dir, "role-manifest.yml")
+		manifest := "roles:\n- name: cache\n  jobs: []\n- name: db\n  jobs: []\n  run:\n    affinity:" + sample.affinity + "\n"
+		assert.NoError(ioutil.WriteFile(manifestPath, []byte(manifest), 0644))
+
+		rolesManifest, err := LoadRoleManifest(manifestPath, nil)
+		if sample.err != "" {
+			assert.EqualError(err, sample.err, sample.desc)
+			continue
+		}
+		if !assert.NoError(err, sample.desc) {
+			continue
+		}
+		role := rolesManifest.LookupRole("db")
+		if assert.Len(role.Run.Affinity, 2, sample.desc) {
+			assert.Equal("db", role.Run.Affinity[0].GetRole(role))
+			assert.Equal(DefaultAffinityTopology, role.Run.Affinity[0].GetTopology())
+			assert.True(role.Run.Affinity[0].IsRequired())
+			assert.Equal("cache", role.Run.Affinity[1].GetRole(role))
+			assert.Equal("failure-domain.beta.kubernetes.io/zone", role.Run.Affinity[1].GetTopology())
+			assert.False(role.Run.Affinity[1].IsRequired())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archiveMirrorClient downloads archives from the mirrors; large archives take
+// a while, but a stalled mirror fails the download instead of hanging it
+var archiveMirrorClient = &http.Client{Timeout: 10 * time.Minute}
+
+// fetchMirroredArchive downloads an archive missing from the BOSH cache from
+// the first mirror that has it, checking it against its sha1 before putting
+// it into the cache. Archives already in the cache are left alone. Mirrors
+// are the URLs of HTTP(S) mirrors of the BOSH cache, consulted in order; a
+// mirror serves the archives by their sha1, as <mirror>/<sha1>.
+func fetchMirroredArchive(mirrors []string, archivePath, sha1sum string) error {
+	if len(mirrors) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(archivePath); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return err
+	}
+
+	var errors []string
+	for _, mirror := range mirrors {
+		url := fmt.Sprintf("%s/%s", strings.TrimSuffix(mirror, "/"), sha1sum)
+		if err := downloadArchive(url, archivePath, sha1sum); err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Error fetching archive %s from the archive mirrors: %s", sha1sum, strings.Join(errors, "; "))
+}
+
+// downloadArchive downloads an archive to archivePath, if its contents match
+// the sha1; the archive is written next to archivePath first, so that a
+// failed download never leaves a broken archive in the cache
+func downloadArchive(url, archivePath, sha1sum string) error {
+	response, err := archiveMirrorClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, response.Status)
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(archivePath), filepath.Base(archivePath)+".download")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	hash := sha1.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), response.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", url, err.Error())
+	}
+
+	if computed := hex.EncodeToString(hash.Sum(nil)); computed != sha1sum {
+		return fmt.Errorf("%s: computed sha1 (%s) is different than the expected sha1 (%s)", url, computed, sha1sum)
+	}
+
+	return os.Rename(file.Name(), archivePath)
+}
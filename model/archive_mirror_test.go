@@ -0,0 +1,87 @@
+package model
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchMirroredArchive(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := "job archive"
+	sum := sha1.Sum([]byte(contents))
+	archiveSHA1 := hex.EncodeToString(sum[:])
+
+	downloads := 0
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cache/" + archiveSHA1:
+			downloads++
+			fmt.Fprint(w, contents)
+		case "/corrupt/" + archiveSHA1:
+			fmt.Fprint(w, "corrupt archive")
+		case "/stalled/" + archiveSHA1:
+			time.Sleep(200 * time.Millisecond)
+			fmt.Fprint(w, contents)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mirror.Close()
+
+	cacheDir, err := ioutil.TempDir("", "fissile-archive-mirror")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(cacheDir)
+	archivePath := filepath.Join(cacheDir, archiveSHA1)
+
+	// Without mirrors, missing archives are left to fail later
+	assert.NoError(fetchMirroredArchive(nil, archivePath, archiveSHA1))
+
+	// Corrupt archives are never put into the cache
+	err = fetchMirroredArchive([]string{mirror.URL + "/corrupt"}, archivePath, archiveSHA1)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "is different than the expected sha1")
+	}
+	files, err := ioutil.ReadDir(cacheDir)
+	assert.NoError(err)
+	assert.Empty(files)
+
+	// Stalled mirrors time out
+	defer func(timeout time.Duration) { archiveMirrorClient.Timeout = timeout }(archiveMirrorClient.Timeout)
+	archiveMirrorClient.Timeout = 50 * time.Millisecond
+	err = fetchMirroredArchive([]string{mirror.URL + "/stalled"}, archivePath, archiveSHA1)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "Client.Timeout exceeded")
+	}
+	archiveMirrorClient.Timeout = time.Minute
+
+	// Mirrors are consulted in order, until one has the archive
+	mirrors := []string{mirror.URL + "/missing", mirror.URL + "/cache/"}
+	assert.NoError(fetchMirroredArchive(mirrors, archivePath, archiveSHA1))
+	downloaded, err := ioutil.ReadFile(archivePath)
+	assert.NoError(err)
+	assert.Equal(contents, string(downloaded))
+	assert.Equal(1, downloads)
+
+	// Archives in the cache are not downloaded again
+	assert.NoError(fetchMirroredArchive(mirrors, archivePath, archiveSHA1))
+	assert.Equal(1, downloads)
+
+	err = fetchMirroredArchive(mirrors, filepath.Join(cacheDir, "0000"), "0000")
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "Error fetching archive 0000 from the archive mirrors: ")
+		assert.Contains(err.Error(), "404 Not Found")
+	}
+}
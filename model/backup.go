@@ -0,0 +1,44 @@
+package model
+
+import (
+	"fmt"
+)
+
+// Backup and restore follow the BOSH Backup and Restore (BBR) convention: a
+// job takes part by shipping templates rendered to bin/bbr/backup and
+// bin/bbr/restore. The scripts read and write their artifacts in the
+// directory named by $BBR_ARTIFACT_DIRECTORY.
+const (
+	BBRActionBackup  = "backup"
+	BBRActionRestore = "restore"
+
+	// BBRArtifactsPath is where the artifacts volume is mounted; every job
+	// gets its own sub-directory
+	BBRArtifactsPath = "/var/vcap/store/bbr-artifacts"
+)
+
+// RoleRunBackup describes how the backup artifacts of a role are stored
+type RoleRunBackup struct {
+	Size     int              `yaml:"size"`               // Size of the artifacts volume, in GB
+	Schedule *RoleRunSchedule `yaml:"schedule,omitempty"` // Optional schedule for automatic backups
+}
+
+// validate checks the size and schedule of a backup
+func (b *RoleRunBackup) validate() error {
+	if b.Size <= 0 {
+		return fmt.Errorf("Backup must have a positive artifacts volume size")
+	}
+
+	if b.Schedule != nil {
+		if err := b.Schedule.validate(); err != nil {
+			return fmt.Errorf("Backup: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// IsBBRAction returns true if the action is one of the BBR actions
+func IsBBRAction(action string) bool {
+	return action == BBRActionBackup || action == BBRActionRestore
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	backup := &RoleRunBackup{Size: 10}
+	assert.NoError(backup.validate())
+
+	backup = &RoleRunBackup{}
+	assert.EqualError(backup.validate(), "Backup must have a positive artifacts volume size")
+
+	backup = &RoleRunBackup{Size: 10, Schedule: &RoleRunSchedule{Cron: "* *"}}
+	assert.EqualError(backup.validate(), `Backup: Schedule "* *" is not a five field cron expression`)
+
+	backup = &RoleRunBackup{Size: 10, Schedule: &RoleRunSchedule{Cron: "0 3 * * *"}}
+	if assert.NoError(backup.validate()) {
+		assert.Equal(ConcurrencyPolicyAllow, backup.Schedule.ConcurrencyPolicy)
+	}
+}
@@ -0,0 +1,256 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Blob is a source archive of a release, as listed in its config/blobs.yml,
+// e.g. the upstream tarball a package compiles
+type Blob struct {
+	Path     string `json:"path"` // Path of the blob in the blobs directory of the release
+	ObjectID string `json:"object_id"`
+	SHA1     string `json:"sha1"`
+	Size     int64  `json:"size"`
+	URL      string `json:"url,omitempty"` // Where the blobstore of the release keeps it; empty if not known
+}
+
+// releaseBlobstore is the blobstore section of config/final.yml
+type releaseBlobstore struct {
+	Provider string            `yaml:"provider"`
+	Options  map[string]string `yaml:"options"`
+}
+
+// loadBlobs reads the blobs of the release from config/blobs.yml, if it has
+// any, with their URLs in the blobstore of config/final.yml, and gives each
+// package the blobs matching the files of its spec
+func (r *Release) loadBlobs() error {
+	contents, err := ioutil.ReadFile(filepath.Join(r.getDevReleaseConfigDir(), "blobs.yml"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var entries map[string]struct {
+		ObjectID string `yaml:"object_id"`
+		SHA1     string `yaml:"sha"`
+		Size     int64  `yaml:"size"`
+	}
+	if err := yaml.Unmarshal(contents, &entries); err != nil {
+		return fmt.Errorf("Error reading the blobs of release %s: %s", r.Name, err.Error())
+	}
+
+	blobstore, err := r.loadBlobstore()
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(entries))
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		entry := entries[path]
+		r.Blobs = append(r.Blobs, &Blob{
+			Path:     path,
+			ObjectID: entry.ObjectID,
+			SHA1:     entry.SHA1,
+			Size:     entry.Size,
+			URL:      blobstore.objectURL(r.Path, entry.ObjectID),
+		})
+	}
+
+	for _, pkg := range r.Packages {
+		if err := pkg.loadBlobs(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadBlobstore reads the blobstore of the release from config/final.yml
+func (r *Release) loadBlobstore() (*releaseBlobstore, error) {
+	contents, err := ioutil.ReadFile(r.getDevReleaseFinalConfigFile())
+	if os.IsNotExist(err) {
+		return &releaseBlobstore{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var finalConfig struct {
+		Blobstore releaseBlobstore `yaml:"blobstore"`
+	}
+	if err := yaml.Unmarshal(contents, &finalConfig); err != nil {
+		return nil, fmt.Errorf("Error reading the blobstore of release %s: %s", r.Name, err.Error())
+	}
+	return &finalConfig.Blobstore, nil
+}
+
+// objectURL returns the URL of an object of the blobstore, or an empty string
+// for providers whose URLs can't be told from their options
+func (b *releaseBlobstore) objectURL(releasePath, objectID string) string {
+	if objectID == "" {
+		return ""
+	}
+	switch b.Provider {
+	case "s3":
+		bucket := b.Options["bucket_name"]
+		if bucket == "" {
+			return ""
+		}
+		if host := b.Options["host"]; host != "" {
+			return fmt.Sprintf("https://%s/%s/%s", host, bucket, objectID)
+		}
+		return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucket, objectID)
+	case "gcs":
+		if bucket := b.Options["bucket_name"]; bucket != "" {
+			return fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucket, objectID)
+		}
+	case "local":
+		if path := b.Options["blobstore_path"]; path != "" {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(releasePath, path)
+			}
+			return "file://" + filepath.ToSlash(filepath.Join(path, objectID))
+		}
+	}
+	return ""
+}
+
+// loadBlobs gives the package the blobs of its release matching the files of
+// its spec in the release directory
+func (p *Package) loadBlobs() error {
+	contents, err := ioutil.ReadFile(filepath.Join(p.Release.packagesDirPath(), p.Name, "spec"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var spec struct {
+		Files []string `yaml:"files"`
+	}
+	if err := yaml.Unmarshal(contents, &spec); err != nil {
+		return fmt.Errorf("Error reading the spec of package %s: %s", p.Name, err.Error())
+	}
+
+	var patterns []*regexp.Regexp
+	for _, file := range spec.Files {
+		patterns = append(patterns, specFilePattern(file))
+	}
+	for _, blob := range p.Release.Blobs {
+		for _, pattern := range patterns {
+			if pattern.MatchString(blob.Path) {
+				p.Blobs = append(p.Blobs, blob)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// specFilePattern returns the regexp matching the paths a glob of the files
+// of a package spec matches: * and ? within a path segment, ** across them,
+// and {a,b} alternatives
+func specFilePattern(glob string) *regexp.Regexp {
+	var pattern bytes.Buffer
+	pattern.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			pattern.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			pattern.WriteString(".*")
+			i++
+		case c == '*':
+			pattern.WriteString("[^/]*")
+		case c == '?':
+			pattern.WriteString("[^/]")
+		case c == '{':
+			pattern.WriteString("(")
+		case c == '}':
+			pattern.WriteString(")")
+		case c == ',':
+			pattern.WriteString("|")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	pattern.WriteString("$")
+
+	compiled, err := regexp.Compile(pattern.String())
+	if err != nil {
+		// Unbalanced braces; match the glob as written
+		return regexp.MustCompile("^" + regexp.QuoteMeta(glob) + "$")
+	}
+	return compiled
+}
+
+// PackageProvenancePath is where role images record the provenance of their
+// packages, relative to the root of the image
+const PackageProvenancePath = "opt/hcf/share/doc/packages.json"
+
+// PackageProvenance traces a package of a role image back to the source
+// archives it was compiled from
+type PackageProvenance struct {
+	Release     string  `json:"release"`
+	Name        string  `json:"name"`
+	Version     string  `json:"version"`
+	Fingerprint string  `json:"fingerprint"`
+	SHA1        string  `json:"sha1"`
+	Blobs       []*Blob `json:"blobs"`
+}
+
+// GetPackageProvenance returns the provenance of the packages the jobs of the
+// role use, and of the packages those depend on, sorted by release and name
+func (r *Role) GetPackageProvenance() []*PackageProvenance {
+	var packages Packages
+	seen := map[*Package]bool{}
+	var add func(pkgs Packages)
+	add = func(pkgs Packages) {
+		for _, pkg := range pkgs {
+			if seen[pkg] {
+				continue
+			}
+			seen[pkg] = true
+			packages = append(packages, pkg)
+			add(pkg.Dependencies)
+		}
+	}
+	for _, job := range r.Jobs {
+		add(job.Packages)
+	}
+	sort.Sort(packages)
+
+	result := make([]*PackageProvenance, 0, len(packages))
+	for _, pkg := range packages {
+		provenance := &PackageProvenance{
+			Name:        pkg.Name,
+			Version:     pkg.Version,
+			Fingerprint: pkg.Fingerprint,
+			SHA1:        pkg.SHA1,
+			Blobs:       pkg.Blobs,
+		}
+		if pkg.Release != nil {
+			provenance.Release = pkg.Release.Name
+		}
+		if provenance.Blobs == nil {
+			provenance.Blobs = []*Blob{}
+		}
+		result = append(result, provenance)
+	}
+	return result
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseBlobs(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	torBlob := &Blob{
+		Path:     "tor/tor-0.2.6.10.tar.gz",
+		ObjectID: "9cdb0228-9cad-4c05-80d3-b880910e1c0e",
+		SHA1:     "4bf9689b311efce70db96c88d1f466caa41e0277",
+		Size:     3587211,
+		URL:      "https://tor-boshrelease.s3.amazonaws.com/9cdb0228-9cad-4c05-80d3-b880910e1c0e",
+	}
+	if assert.Len(release.Blobs, 2) {
+		assert.Equal("libevent/libevent-2.0.22-stable.tar.gz", release.Blobs[0].Path)
+		assert.Equal(torBlob, release.Blobs[1])
+	}
+
+	pkg, err := release.LookupPackage("tor")
+	if assert.NoError(err) {
+		assert.Equal([]*Blob{torBlob}, pkg.Blobs)
+	}
+
+	ntpReleasePath := filepath.Join(workDir, "../test-assets/ntp-release")
+	ntpReleasePathBoshCache := filepath.Join(ntpReleasePath, "bosh-cache")
+	release, err = NewDevRelease(ntpReleasePath, "", "", ntpReleasePathBoshCache)
+	if assert.NoError(err) {
+		assert.Empty(release.Blobs)
+	}
+}
+
+func TestRoleGetPackageProvenance(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+	rolesManifest, err := LoadRoleManifest(filepath.Join(workDir, "../test-assets/role-manifests/tor-good.yml"), []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	provenance := rolesManifest.Roles[0].GetPackageProvenance()
+	if assert.Len(provenance, 2) {
+		assert.Equal("tor", provenance[0].Release)
+		assert.Equal("libevent", provenance[0].Name)
+		assert.Equal("tor", provenance[1].Name)
+		if assert.Len(provenance[1].Blobs, 1) {
+			assert.Equal("4bf9689b311efce70db96c88d1f466caa41e0277", provenance[1].Blobs[0].SHA1)
+		}
+	}
+}
+
+func TestBlobstoreObjectURL(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc      string
+		blobstore releaseBlobstore
+		expected  string
+	}{
+		{
+			desc:      "S3 buckets",
+			blobstore: releaseBlobstore{Provider: "s3", Options: map[string]string{"bucket_name": "blobs"}},
+			expected:  "https://blobs.s3.amazonaws.com/abc",
+		},
+		{
+			desc:      "S3 compatible hosts",
+			blobstore: releaseBlobstore{Provider: "s3", Options: map[string]string{"bucket_name": "blobs", "host": "s3.example.com"}},
+			expected:  "https://s3.example.com/blobs/abc",
+		},
+		{
+			desc:      "GCS buckets",
+			blobstore: releaseBlobstore{Provider: "gcs", Options: map[string]string{"bucket_name": "blobs"}},
+			expected:  "https://storage.googleapis.com/blobs/abc",
+		},
+		{
+			desc:      "Local blobstores are relative to the release",
+			blobstore: releaseBlobstore{Provider: "local", Options: map[string]string{"blobstore_path": "tmp/blobs"}},
+			expected:  "file:///release/tmp/blobs/abc",
+		},
+		{
+			desc:      "Other providers have no known URL",
+			blobstore: releaseBlobstore{Provider: "dav", Options: map[string]string{"endpoint": "https://blobs.example.com"}},
+		},
+	}
+
+	for _, sample := range samples {
+		assert.Equal(sample.expected, sample.blobstore.objectURL("/release", "abc"), sample.desc)
+	}
+}
+
+func TestSpecFilePattern(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		glob    string
+		path    string
+		matches bool
+	}{
+		{"tor/tor-*.tar.gz", "tor/tor-0.2.6.10.tar.gz", true},
+		{"tor/tor-*.tar.gz", "tor/patches/tor-1.tar.gz", false},
+		{"tor/**/*.patch", "tor/security/fix.patch", true},
+		{"tor/**/*.patch", "tor/fix.patch", true},
+		{"tor/**", "tor/a/b/c", true},
+		{"tor/{tor,libevent}-?.tgz", "tor/libevent-2.tgz", true},
+		{"tor/{tor,libevent}-?.tgz", "tor/openssl-2.tgz", false},
+		{"tor/tor.tar.gz", "tor/tor-tar-gz", false},
+	}
+
+	for _, sample := range samples {
+		assert.Equal(sample.matches, specFilePattern(sample.glob).MatchString(sample.path), "%s matching %s", sample.glob, sample.path)
+	}
+}
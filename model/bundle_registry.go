@@ -0,0 +1,140 @@
+package model
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hpcloud/fissile/registry"
+)
+
+// ociManifest is the part of an OCI image manifest used to pull bundles
+type ociManifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+// pullOCIArtifact downloads the layers of an OCI artifact, verifying it
+// against its digest, and extracts them into a new directory
+func pullOCIArtifact(ref, digest, dir string) error {
+	registryURL, repository, err := registry.ParseReference(ref)
+	if err != nil {
+		return err
+	}
+	client := &registry.Client{}
+
+	manifestData, err := client.Get(fmt.Sprintf("%s/v2/%s/manifests/%s", registryURL, repository, digest), ref, registry.ManifestMediaTypes)
+	if err != nil {
+		return err
+	}
+	if err := verifyDigest(manifestData, digest); err != nil {
+		return fmt.Errorf("Manifest of %s: %s", ref, err.Error())
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return fmt.Errorf("Error reading manifest of %s: %s", ref, err.Error())
+	}
+	if len(manifest.Layers) == 0 {
+		return fmt.Errorf("Artifact %s has no layers", ref)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	// Extract next to the final directory, so incomplete pulls are not used
+	tempDir, err := ioutil.TempDir(filepath.Dir(dir), "pull-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, layer := range manifest.Layers {
+		blob, err := client.Get(fmt.Sprintf("%s/v2/%s/blobs/%s", registryURL, repository, layer.Digest), ref, nil)
+		if err != nil {
+			return err
+		}
+		if err := verifyDigest(blob, layer.Digest); err != nil {
+			return fmt.Errorf("Layer of %s: %s", ref, err.Error())
+		}
+		if err := extractLayer(blob, tempDir); err != nil {
+			return fmt.Errorf("Error extracting layer %s of %s: %s", layer.Digest, ref, err.Error())
+		}
+	}
+
+	return os.Rename(tempDir, dir)
+}
+
+// verifyDigest checks that data has the given sha256 digest
+func verifyDigest(data []byte, digest string) error {
+	sum := sha256.Sum256(data)
+	if actual := "sha256:" + hex.EncodeToString(sum[:]); actual != digest {
+		return fmt.Errorf("digest %s does not match the expected %s", actual, digest)
+	}
+	return nil
+}
+
+// extractLayer extracts a tar layer, compressed with gzip or not, into a
+// directory. Entries other than directories and regular files are skipped.
+func extractLayer(data []byte, dir string) error {
+	var reader io.Reader = bytes.NewReader(data)
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "/"))
+		if name == "." {
+			continue
+		}
+		if strings.HasPrefix(name, "..") {
+			return fmt.Errorf("entry %s is outside of the layer", header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode)&0777)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tarReader)
+			file.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RoleManifestBundle is a bundle of role scripts and configuration templates
+// shared between role manifests, stored as an OCI artifact in a registry.
+// Roles list the bundles they use by name.
+type RoleManifestBundle struct {
+	Name   string `yaml:"name"`
+	Ref    string `yaml:"ref"`    // registry/repository[:tag]; the tag is informational
+	Digest string `yaml:"digest"` // sha256:<hex> of the artifact manifest, which pins its contents
+}
+
+// BundleContentsFile is the file at the root of a bundle listing its
+// contents. Script paths are relative to the root of the bundle.
+const BundleContentsFile = "bundle.yml"
+
+// BundleContents is what a bundle adds to the roles that use it
+type BundleContents struct {
+	EnvironScripts    []string       `yaml:"environment_scripts"`
+	Scripts           []string       `yaml:"scripts"`
+	PostConfigScripts []string       `yaml:"post_config_scripts"`
+	Configuration     *Configuration `yaml:"configuration"` // Only the templates are used
+}
+
+var (
+	bundleNamePattern   = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	bundleDigestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+)
+
+// bundleDir returns the directory the contents of a bundle are pulled into,
+// by digest, in the cache directory; bundles are kept in the system temporary
+// directory without one
+func (b *RoleManifestBundle) bundleDir(cacheDir string) string {
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.TempDir(), "fissile-bundles")
+	}
+	return filepath.Join(cacheDir, strings.TrimPrefix(b.Digest, "sha256:"))
+}
+
+// load pulls the bundle into its directory, unless it already has been, and
+// reads its contents
+func (b *RoleManifestBundle) load(dir string) (*BundleContents, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := pullOCIArtifact(b.Ref, b.Digest, dir); err != nil {
+			return nil, fmt.Errorf("Error pulling bundle %s: %s", b.Name, err.Error())
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	contentsYAML, err := ioutil.ReadFile(filepath.Join(dir, BundleContentsFile))
+	if err != nil {
+		return nil, fmt.Errorf("Bundle %s has no %s: %s", b.Name, BundleContentsFile, err.Error())
+	}
+	var contents BundleContents
+	if err := yaml.Unmarshal(contentsYAML, &contents); err != nil {
+		return nil, fmt.Errorf("Error reading %s of bundle %s: %s", BundleContentsFile, b.Name, err.Error())
+	}
+	if err := validateFileSchema(BundleContentsFile, contentsYAML, reflect.TypeOf(contents)); err != nil {
+		return nil, fmt.Errorf("Bundle %s: %s", b.Name, err.Error())
+	}
+
+	for _, scriptList := range [][]string{contents.EnvironScripts, contents.Scripts, contents.PostConfigScripts} {
+		for _, script := range scriptList {
+			if path.IsAbs(script) || strings.HasPrefix(path.Clean(script), "..") {
+				return nil, fmt.Errorf("Bundle %s: script %s is not inside the bundle", b.Name, script)
+			}
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(script))); err != nil {
+				return nil, fmt.Errorf("Bundle %s: missing script %s", b.Name, script)
+			}
+		}
+	}
+
+	return &contents, nil
+}
+
+// mergeBundles adds the scripts and configuration templates of the bundles
+// used by roles to the roles. Bundle scripts run before the scripts of the
+// role, and are named <bundle>/<script> in the image; templates of the role
+// take precedence over the ones of bundles. Pulled bundles are kept in the
+// bundle cache directory of the options of the manifest.
+func (m *RoleManifest) mergeBundles() error {
+	bundles := map[string]*RoleManifestBundle{}
+	for _, bundle := range m.Bundles {
+		if !bundleNamePattern.MatchString(bundle.Name) {
+			return fmt.Errorf("Invalid bundle name '%s'", bundle.Name)
+		}
+		if _, ok := bundles[bundle.Name]; ok {
+			return fmt.Errorf("Bundle %s is defined more than once", bundle.Name)
+		}
+		if bundle.Ref == "" {
+			return fmt.Errorf("Bundle %s has no ref", bundle.Name)
+		}
+		if !bundleDigestPattern.MatchString(bundle.Digest) {
+			return fmt.Errorf("Bundle %s: invalid digest '%s', expected sha256:<hex>", bundle.Name, bundle.Digest)
+		}
+		bundles[bundle.Name] = bundle
+	}
+
+	loaded := map[string]*BundleContents{}
+	for _, role := range m.Roles {
+		// Merge in reverse, so the first bundle's scripts run first
+		for i := len(role.Bundles) - 1; i >= 0; i-- {
+			name := role.Bundles[i]
+			bundle, ok := bundles[name]
+			if !ok {
+				return fmt.Errorf("Role %s uses unknown bundle %s", role.Name, name)
+			}
+
+			dir := bundle.bundleDir(m.options.BundleCacheDir)
+			contents, ok := loaded[name]
+			if !ok {
+				var err error
+				if contents, err = bundle.load(dir); err != nil {
+					return err
+				}
+				loaded[name] = contents
+			}
+
+			role.mergeBundle(bundle, contents, dir)
+		}
+	}
+
+	return nil
+}
+
+// mergeBundle adds the contents of a bundle, pulled into dir, to the role
+func (r *Role) mergeBundle(bundle *RoleManifestBundle, contents *BundleContents, dir string) {
+	if r.fetchedScripts == nil {
+		r.fetchedScripts = map[string]string{}
+	}
+	prefix := func(scripts []string) []string {
+		var result []string
+		for _, script := range scripts {
+			name := path.Join(bundle.Name, script)
+			r.fetchedScripts[name] = filepath.Join(dir, filepath.FromSlash(script))
+			result = append(result, name)
+		}
+		return result
+	}
+	r.EnvironScripts = append(prefix(contents.EnvironScripts), r.EnvironScripts...)
+	r.Scripts = append(prefix(contents.Scripts), r.Scripts...)
+	r.PostConfigScripts = append(prefix(contents.PostConfigScripts), r.PostConfigScripts...)
+
+	if contents.Configuration == nil || len(contents.Configuration.Templates) == 0 {
+		return
+	}
+	if r.Configuration == nil {
+		r.Configuration = &Configuration{}
+	}
+	if r.Configuration.Templates == nil {
+		r.Configuration.Templates = map[string]string{}
+	}
+	for key, value := range contents.Configuration.Templates {
+		if _, ok := r.Configuration.Templates[key]; !ok {
+			r.Configuration.Templates[key] = value
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeRegistry serves a single artifact with one gzipped layer holding the
+// given files, and requires a bearer token like public registries do
+func newFakeRegistry(files map[string]string) (*httptest.Server, string, *int) {
+	var layer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&layer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, contents := range files {
+		tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+		tarWriter.Write([]byte(contents))
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+
+	digestOf := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	layerDigest := digestOf(layer.Bytes())
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion": 2, "layers": [{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": "%s"}]}`, layerDigest))
+	manifestDigest := digestOf(manifest)
+
+	pulls := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"token": "anonymous"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="repository:platform/scripts:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/platform/scripts/manifests/" + manifestDigest:
+			pulls++
+			w.Write(manifest)
+		case "/v2/platform/scripts/blobs/" + layerDigest:
+			w.Write(layer.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	return server, manifestDigest, &pulls
+}
+
+func TestMergeBundles(t *testing.T) {
+	assert := assert.New(t)
+
+	cacheDir, err := ioutil.TempDir("", "fissile-bundles")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(cacheDir)
+
+	server, digest, pulls := newFakeRegistry(map[string]string{
+		"bundle.yml": `---
+environment_scripts: [env/proxy.sh]
+scripts: [setup.sh]
+configuration:
+  templates:
+    properties.shared.timeout: "30"
+    properties.tor.hostname: '((BUNDLE_HOSTNAME))'
+`,
+		"env/proxy.sh": "#!/bin/sh\n",
+		"setup.sh":     "#!/bin/sh\n",
+	})
+	defer server.Close()
+	ref := strings.TrimPrefix(server.URL, "http://") + "/platform/scripts:1.0"
+
+	newManifest := func() *RoleManifest {
+		return &RoleManifest{
+			Bundles: []*RoleManifestBundle{{Name: "shared", Ref: ref, Digest: digest}},
+			options: RoleManifestOptions{BundleCacheDir: cacheDir},
+			Roles: Roles{
+				{
+					Name:    "myrole",
+					Bundles: []string{"shared"},
+					Scripts: []string{"myrole.sh"},
+					Configuration: &Configuration{Templates: map[string]string{
+						"properties.tor.hostname": "((HOSTNAME))",
+					}},
+					rolesManifest: &RoleManifest{manifestFilePath: "/manifests/role-manifest.yml"},
+				},
+				{Name: "other"},
+			},
+		}
+	}
+
+	manifest := newManifest()
+	if !assert.NoError(manifest.mergeBundles()) {
+		return
+	}
+	role := manifest.Roles[0]
+	assert.Equal([]string{"shared/env/proxy.sh"}, role.EnvironScripts)
+	assert.Equal([]string{"shared/setup.sh", "myrole.sh"}, role.Scripts)
+	assert.Equal(map[string]string{
+		"properties.tor.hostname":   "((HOSTNAME))",
+		"properties.shared.timeout": "30",
+	}, role.Configuration.Templates)
+
+	bundleDir := filepath.Join(cacheDir, strings.TrimPrefix(digest, "sha256:"))
+	assert.Equal(map[string]string{
+		"shared/env/proxy.sh": filepath.Join(bundleDir, "env", "proxy.sh"),
+		"shared/setup.sh":     filepath.Join(bundleDir, "setup.sh"),
+		"myrole.sh":           filepath.Join("/manifests", "myrole.sh"),
+	}, role.GetScriptPaths())
+	assert.Empty(manifest.Roles[1].Scripts)
+
+	// Pulled bundles are reused
+	assert.NoError(newManifest().mergeBundles())
+	assert.Equal(1, *pulls)
+
+	manifest = newManifest()
+	manifest.Roles[1].Bundles = []string{"missing"}
+	assert.EqualError(manifest.mergeBundles(), "Role other uses unknown bundle missing")
+
+	manifest = newManifest()
+	manifest.Bundles[0].Digest = "latest"
+	assert.EqualError(manifest.mergeBundles(), "Bundle shared: invalid digest 'latest', expected sha256:<hex>")
+
+	manifest = newManifest()
+	manifest.Bundles[0].Digest = "sha256:" + strings.Repeat("0", 64)
+	err = manifest.mergeBundles()
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "Error pulling bundle shared: Error fetching")
+	}
+}
+
+func TestBundleContentsUnknownKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	cacheDir, err := ioutil.TempDir("", "fissile-bundles")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(cacheDir)
+
+	server, digest, _ := newFakeRegistry(map[string]string{
+		"bundle.yml": "---\nscirpts: [setup.sh]\n",
+		"setup.sh":   "#!/bin/sh\n",
+	})
+	defer server.Close()
+	ref := strings.TrimPrefix(server.URL, "http://") + "/platform/scripts:1.0"
+
+	manifest := &RoleManifest{
+		Bundles: []*RoleManifestBundle{{Name: "shared", Ref: ref, Digest: digest}},
+		Roles:   Roles{{Name: "myrole", Bundles: []string{"shared"}}},
+		options: RoleManifestOptions{BundleCacheDir: cacheDir},
+	}
+	assert.EqualError(manifest.mergeBundles(), "Bundle shared: bundle.yml:2: Unknown key scirpts, did you mean scripts?")
+}
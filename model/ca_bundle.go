@@ -0,0 +1,82 @@
+package model
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// loadCABundle reads the CA bundle of the role manifest, if it has one. The
+// bundle holds PEM encoded CA certificates, and nothing else, so a wrong file
+// is not silently ignored by the trust store of the images.
+func (m *RoleManifest) loadCABundle() error {
+	if m.CABundle == "" {
+		return nil
+	}
+
+	bundlePath := m.CABundle
+	if !filepath.IsAbs(bundlePath) {
+		bundlePath = filepath.Join(filepath.Dir(m.manifestFilePath), bundlePath)
+	}
+	contents, err := ioutil.ReadFile(bundlePath)
+	if err != nil {
+		return fmt.Errorf("Error reading CA bundle %s: %s", m.CABundle, err.Error())
+	}
+
+	m.caCertificates = nil
+	for {
+		var block *pem.Block
+		block, contents = pem.Decode(contents)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("CA bundle %s holds a %s; only certificates are allowed", m.CABundle, block.Type)
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("CA bundle %s has an invalid certificate: %s", m.CABundle, err.Error())
+		}
+		if !certificate.IsCA {
+			return fmt.Errorf("CA bundle %s has a certificate for %s that is not a CA", m.CABundle, certificate.Subject.CommonName)
+		}
+		m.caCertificates = append(m.caCertificates, pem.EncodeToMemory(block))
+	}
+
+	if strings.TrimSpace(string(contents)) != "" {
+		return fmt.Errorf("CA bundle %s has data that is not PEM encoded", m.CABundle)
+	}
+	if len(m.caCertificates) == 0 {
+		return fmt.Errorf("CA bundle %s has no certificates", m.CABundle)
+	}
+
+	return nil
+}
+
+// GetCACertificates returns the PEM encoded certificates of the CA bundle the
+// images of the role trust, one per certificate
+func (r *Role) GetCACertificates() [][]byte {
+	if r.rolesManifest == nil {
+		return nil
+	}
+	return r.rolesManifest.caCertificates
+}
+
+// caBundleSignature identifies the CA certificates trusted by the images of
+// the role, for the image version; empty without any
+func (r *Role) caBundleSignature() string {
+	certificates := r.GetCACertificates()
+	if len(certificates) == 0 {
+		return ""
+	}
+	hasher := sha1.New()
+	for _, certificate := range certificates {
+		hasher.Write(certificate)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
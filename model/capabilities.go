@@ -0,0 +1,86 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CapabilityAll stands for all capabilities; roles asking for it run
+// privileged
+const CapabilityAll = "ALL"
+
+// linuxCapabilities are the capabilities the containers of a role can be
+// given, as kube names them, without the CAP_ prefix
+var linuxCapabilities = map[string]bool{
+	"AUDIT_CONTROL":    true,
+	"AUDIT_READ":       true,
+	"AUDIT_WRITE":      true,
+	"BLOCK_SUSPEND":    true,
+	"CHOWN":            true,
+	"DAC_OVERRIDE":     true,
+	"DAC_READ_SEARCH":  true,
+	"FOWNER":           true,
+	"FSETID":           true,
+	"IPC_LOCK":         true,
+	"IPC_OWNER":        true,
+	"KILL":             true,
+	"LEASE":            true,
+	"LINUX_IMMUTABLE":  true,
+	"MAC_ADMIN":        true,
+	"MAC_OVERRIDE":     true,
+	"MKNOD":            true,
+	"NET_ADMIN":        true,
+	"NET_BIND_SERVICE": true,
+	"NET_BROADCAST":    true,
+	"NET_RAW":          true,
+	"SETFCAP":          true,
+	"SETGID":           true,
+	"SETPCAP":          true,
+	"SETUID":           true,
+	"SYSLOG":           true,
+	"SYS_ADMIN":        true,
+	"SYS_BOOT":         true,
+	"SYS_CHROOT":       true,
+	"SYS_MODULE":       true,
+	"SYS_NICE":         true,
+	"SYS_PACCT":        true,
+	"SYS_PTRACE":       true,
+	"SYS_RAWIO":        true,
+	"SYS_RESOURCE":     true,
+	"SYS_TIME":         true,
+	"SYS_TTY_CONFIG":   true,
+	"WAKE_ALARM":       true,
+}
+
+// IsPrivileged reports whether the containers of the role run privileged;
+// they do when the role asks for it, for all capabilities, or to mount host
+// device nodes
+func (r *RoleRun) IsPrivileged() bool {
+	if r.Privileged || r.NeedsPrivileged() {
+		return true
+	}
+	for _, capability := range r.Capabilities {
+		if capability == CapabilityAll {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCapabilities checks the capabilities of a role, and normalizes them
+// to the upper case names kube uses, without the CAP_ prefix
+func (r *RoleRun) validateCapabilities() error {
+	seen := map[string]bool{}
+	for i, capability := range r.Capabilities {
+		name := strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+		if name != CapabilityAll && !linuxCapabilities[name] {
+			return fmt.Errorf("Unknown capability %s", capability)
+		}
+		if seen[name] {
+			return fmt.Errorf("Capability %s is listed more than once", name)
+		}
+		seen[name] = true
+		r.Capabilities[i] = name
+	}
+	return nil
+}
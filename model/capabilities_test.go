@@ -0,0 +1,57 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc         string
+		capabilities []string
+		expected     []string
+		err          string
+	}{
+		{
+			desc: "Roles without capabilities are valid",
+		},
+		{
+			desc:         "Capabilities are normalized",
+			capabilities: []string{"net_admin", "CAP_SYS_ADMIN", "all"},
+			expected:     []string{"NET_ADMIN", "SYS_ADMIN", "ALL"},
+		},
+		{
+			desc:         "Capabilities are known",
+			capabilities: []string{"NET_ADMN"},
+			err:          "Unknown capability NET_ADMN",
+		},
+		{
+			desc:         "Capabilities are listed once",
+			capabilities: []string{"NET_ADMIN", "CAP_NET_ADMIN"},
+			err:          "Capability NET_ADMIN is listed more than once",
+		},
+	}
+
+	for _, sample := range samples {
+		run := RoleRun{Capabilities: sample.capabilities}
+		err := run.validateCapabilities()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+			assert.Equal(sample.expected, run.Capabilities, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestRoleRunIsPrivileged(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False((&RoleRun{Capabilities: []string{"NET_ADMIN"}}).IsPrivileged())
+	assert.True((&RoleRun{Privileged: true}).IsPrivileged())
+	assert.True((&RoleRun{Capabilities: []string{CapabilityAll}}).IsPrivileged())
+	assert.True((&RoleRun{Devices: []*RoleRunDevice{{Path: "/dev/fuse"}}}).IsPrivileged())
+}
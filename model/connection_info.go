@@ -0,0 +1,154 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RoleRunConnectionInfo is what clients need to connect to a role. It is
+// exported for the roles linking to it, so their templates get the address of
+// the role without knowing how its service is named.
+type RoleRunConnectionInfo struct {
+	Port        string            `yaml:"port"`        // Name of the exposed port clients use; the first one when not set
+	Scheme      string            `yaml:"scheme"`      // e.g. https or mysql
+	Credentials map[string]string `yaml:"credentials"` // Credential name, e.g. password, to the variable holding it
+}
+
+// RoleRunLink makes the connection info of another role available to a role,
+// as the environment variables <PREFIX>_HOST, <PREFIX>_PORT, <PREFIX>_SCHEME
+// and <PREFIX>_<CREDENTIAL>
+type RoleRunLink struct {
+	Role   string `yaml:"role"`
+	Prefix string `yaml:"prefix"` // The upper-cased role name when not set
+}
+
+var (
+	// connectionSchemePattern matches URL schemes
+	connectionSchemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+	// credentialNamePattern matches the names of credentials, which are used
+	// in the keys of the connection info
+	credentialNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	// envNamePattern matches the names of environment variables
+	envNamePattern = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+	// envNameInvalidChars matches what is replaced in role and credential
+	// names to make environment variable names of them
+	envNameInvalidChars = regexp.MustCompile(`[^A-Z0-9_]+`)
+)
+
+// ConnectionInfoPort returns the exposed port clients connect to
+func (r *Role) ConnectionInfoPort() *RoleRunExposedPort {
+	if r.Run == nil || r.Run.ConnectionInfo == nil || len(r.Run.ExposedPorts) == 0 {
+		return nil
+	}
+	if r.Run.ConnectionInfo.Port == "" {
+		return r.Run.ExposedPorts[0]
+	}
+	for _, port := range r.Run.ExposedPorts {
+		if port.Name == r.Run.ConnectionInfo.Port {
+			return port
+		}
+	}
+	return nil
+}
+
+// LinkedRole returns the role a link of the role is to
+func (r *Role) LinkedRole(link *RoleRunLink) *Role {
+	return r.rolesManifest.LookupRole(link.Role)
+}
+
+// CredentialNames returns the sorted names of the credentials of the
+// connection info
+func (c *RoleRunConnectionInfo) CredentialNames() []string {
+	names := make([]string, 0, len(c.Credentials))
+	for name := range c.Credentials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EnvPrefix returns the prefix of the environment variables of a link
+func (l *RoleRunLink) EnvPrefix() string {
+	if l.Prefix != "" {
+		return l.Prefix
+	}
+	return LinkEnvName(l.Role)
+}
+
+// LinkEnvName returns a name made fit for environment variables, upper-cased
+// and with underscores in place of other characters; my-db becomes MY_DB
+func LinkEnvName(name string) string {
+	return envNameInvalidChars.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// validateConnectionInfo checks the connection info of the roles, and the
+// links to it. Credentials must be read from existing secrets, so that the
+// connection info only references them.
+func (m *RoleManifest) validateConnectionInfo() error {
+	variables := map[string]*ConfigurationVariable{}
+	for _, variable := range m.Configuration.Variables {
+		variables[variable.Name] = variable
+	}
+
+	for _, role := range m.Roles {
+		if role.Run == nil || role.Run.ConnectionInfo == nil {
+			continue
+		}
+		info := role.Run.ConnectionInfo
+		if !role.IsLongRunning() {
+			return fmt.Errorf("Role %s has connection info, but is of type %s", role.Name, role.Type)
+		}
+		if len(role.Run.ExposedPorts) == 0 {
+			return fmt.Errorf("Role %s has connection info, but no exposed ports", role.Name)
+		}
+		if role.ConnectionInfoPort() == nil {
+			return fmt.Errorf("Role %s: connection info uses unknown port %s", role.Name, info.Port)
+		}
+		if info.Scheme != "" && !connectionSchemePattern.MatchString(info.Scheme) {
+			return fmt.Errorf("Role %s: connection info has an invalid scheme '%s'", role.Name, info.Scheme)
+		}
+		for _, name := range info.CredentialNames() {
+			if !credentialNamePattern.MatchString(name) {
+				return fmt.Errorf("Role %s: connection info has an invalid credential name '%s'", role.Name, name)
+			}
+			variable, ok := variables[info.Credentials[name]]
+			if !ok {
+				return fmt.Errorf("Role %s: connection info credential %s uses unknown variable '%s'", role.Name, name, info.Credentials[name])
+			}
+			if variable.Secret == nil {
+				return fmt.Errorf("Role %s: connection info credential %s should read variable %s from a secret", role.Name, name, variable.Name)
+			}
+		}
+	}
+
+	for _, role := range m.Roles {
+		if role.Run == nil {
+			continue
+		}
+		prefixes := map[string]string{}
+		for _, link := range role.Run.Links {
+			target := m.LookupRole(link.Role)
+			if target == nil {
+				return fmt.Errorf("Role %s links to unknown role %s", role.Name, link.Role)
+			}
+			if target == role {
+				return fmt.Errorf("Role %s links to itself", role.Name)
+			}
+			if target.Run == nil || target.Run.ConnectionInfo == nil {
+				return fmt.Errorf("Role %s links to role %s, which has no connection info", role.Name, link.Role)
+			}
+			prefix := link.EnvPrefix()
+			if !envNamePattern.MatchString(prefix) {
+				return fmt.Errorf("Role %s: link to role %s has an invalid prefix '%s'", role.Name, link.Role, prefix)
+			}
+			if other, ok := prefixes[prefix]; ok {
+				return fmt.Errorf("Role %s: links to roles %s and %s use the same prefix %s", role.Name, other, link.Role, prefix)
+			}
+			prefixes[prefix] = link.Role
+		}
+	}
+
+	return nil
+}
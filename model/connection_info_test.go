@@ -0,0 +1,105 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionInfoOK(t *testing.T) {
+	assert := assert.New(t)
+	rolesManifest := loadTestRoleManifest(assert, "connection-info.yml")
+	if rolesManifest == nil {
+		return
+	}
+
+	mysql := rolesManifest.LookupRole("mysql")
+	if assert.NotNil(mysql) {
+		assert.Equal("mysql", mysql.ConnectionInfoPort().Name)
+		assert.Equal([]string{"password", "username"}, mysql.Run.ConnectionInfo.CredentialNames())
+	}
+
+	role := rolesManifest.LookupRole("myrole")
+	if assert.NotNil(role) && assert.Len(role.Run.Links, 1) {
+		assert.Equal(mysql, role.LinkedRole(role.Run.Links[0]))
+		assert.Equal("DB", role.Run.Links[0].EnvPrefix())
+	}
+
+	// Linked roles come up first
+	waves, err := rolesManifest.DeployWaves()
+	if assert.NoError(err) && assert.Len(waves, 2) {
+		assert.Equal(Roles{mysql}, waves[0])
+		assert.Equal(Roles{role}, waves[1])
+	}
+
+	assert.Equal("MY_DB", (&RoleRunLink{Role: "my-db"}).EnvPrefix())
+}
+
+func TestConnectionInfoInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc   string
+		change func(mysql, role *Role)
+		err    string
+	}{
+		{
+			desc:   "Connection info needs exposed ports",
+			change: func(mysql, role *Role) { mysql.Run.ExposedPorts = nil },
+			err:    "Role mysql has connection info, but no exposed ports",
+		},
+		{
+			desc:   "Connection info uses an exposed port",
+			change: func(mysql, role *Role) { mysql.Run.ConnectionInfo.Port = "http" },
+			err:    "Role mysql: connection info uses unknown port http",
+		},
+		{
+			desc:   "Schemes are URL schemes",
+			change: func(mysql, role *Role) { mysql.Run.ConnectionInfo.Scheme = "MySQL://" },
+			err:    "Role mysql: connection info has an invalid scheme 'MySQL://'",
+		},
+		{
+			desc:   "Credentials use known variables",
+			change: func(mysql, role *Role) { mysql.Run.ConnectionInfo.Credentials["password"] = "DB_PASS" },
+			err:    "Role mysql: connection info credential password uses unknown variable 'DB_PASS'",
+		},
+		{
+			desc: "Credentials are read from secrets",
+			change: func(mysql, role *Role) {
+				mysql.rolesManifest.Configuration.Variables[0].Secret = nil
+			},
+			err: "Role mysql: connection info credential username should read variable DB_USER from a secret",
+		},
+		{
+			desc:   "Links are to known roles",
+			change: func(mysql, role *Role) { role.Run.Links[0].Role = "postgres" },
+			err:    "Role myrole links to unknown role postgres",
+		},
+		{
+			desc:   "Links are to roles with connection info",
+			change: func(mysql, role *Role) { mysql.Run.ConnectionInfo = nil },
+			err:    "Role myrole links to role mysql, which has no connection info",
+		},
+		{
+			desc:   "Link prefixes are environment variable names",
+			change: func(mysql, role *Role) { role.Run.Links[0].Prefix = "db" },
+			err:    "Role myrole: link to role mysql has an invalid prefix 'db'",
+		},
+		{
+			desc: "Link prefixes are unique",
+			change: func(mysql, role *Role) {
+				role.Run.Links = append(role.Run.Links, &RoleRunLink{Role: "mysql", Prefix: "DB"})
+			},
+			err: "Role myrole: links to roles mysql and mysql use the same prefix DB",
+		},
+	}
+
+	for _, sample := range samples {
+		rolesManifest := loadTestRoleManifest(assert, "connection-info.yml")
+		if rolesManifest == nil {
+			return
+		}
+		sample.change(rolesManifest.LookupRole("mysql"), rolesManifest.LookupRole("myrole"))
+		assert.EqualError(rolesManifest.validateConnectionInfo(), sample.err, sample.desc)
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"fmt"
+)
+
+// Inits the entrypoint of roles can run under
+const (
+	// RoleRunInitDumbInit makes dumb-init of the base image PID 1 of the
+	// containers: it reaps the zombies of processes orphaned by the jobs, and
+	// forwards the signals it gets to the entrypoint only, so the entrypoint
+	// stops the jobs through monit
+	RoleRunInitDumbInit = "dumb-init"
+	// RoleRunInitNone makes the entrypoint PID 1, for runtimes adding their
+	// own init, e.g. with docker run --init
+	RoleRunInitNone = "none"
+)
+
+// runScriptPath is the entrypoint of the images of roles
+const runScriptPath = "/opt/hcf/run.sh"
+
+// GetInit returns the init the entrypoint of the role runs under, dumb-init
+// unless the role says otherwise
+func (r *Role) GetInit() string {
+	if r.Run == nil || r.Run.Init == "" {
+		return RoleRunInitDumbInit
+	}
+	return r.Run.Init
+}
+
+// GetEntrypoint returns the entrypoint of the image of the role, the run
+// script under its init
+func (r *Role) GetEntrypoint() []string {
+	entrypoint := []string{"/bin/bash", runScriptPath}
+	if r.GetInit() == RoleRunInitDumbInit {
+		// Without --single-child dumb-init would send the signals to all the
+		// processes, and the jobs would be stopped behind monit's back
+		entrypoint = append([]string{"/usr/bin/dumb-init", "--single-child", "--"}, entrypoint...)
+	}
+	return entrypoint
+}
+
+// validateInit checks the init of a role. Docker roles run the entrypoint of
+// their image, so they can't have one.
+func (r *Role) validateInit() error {
+	if r.Run == nil || r.Run.Init == "" {
+		return nil
+	}
+	if r.Type == RoleTypeDocker {
+		return fmt.Errorf("Role %s is of type %s, which uses the entrypoint of its image; it can't have an init", r.Name, RoleTypeDocker)
+	}
+	switch r.Run.Init {
+	case RoleRunInitDumbInit, RoleRunInitNone:
+	default:
+		return fmt.Errorf("Role %s has an invalid init '%s', expected %s or %s", r.Name, r.Run.Init, RoleRunInitDumbInit, RoleRunInitNone)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateInit(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		role Role
+		err  string
+	}{
+		{
+			desc: "Roles without a run section are valid",
+			role: Role{Name: "api"},
+		},
+		{
+			desc: "Roles can run without an init",
+			role: Role{Name: "api", Type: RoleTypeBoshTask, Run: &RoleRun{Init: RoleRunInitNone}},
+		},
+		{
+			desc: "The init is known",
+			role: Role{Name: "api", Run: &RoleRun{Init: "tini"}},
+			err:  "Role api has an invalid init 'tini', expected dumb-init or none",
+		},
+		{
+			desc: "Docker roles have no init",
+			role: Role{Name: "db", Type: RoleTypeDocker, Run: &RoleRun{Init: RoleRunInitDumbInit}},
+			err:  "Role db is of type docker, which uses the entrypoint of its image; it can't have an init",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.role.validateInit()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestGetEntrypoint(t *testing.T) {
+	assert := assert.New(t)
+
+	role := &Role{Name: "api"}
+	assert.Equal(RoleRunInitDumbInit, role.GetInit())
+	assert.Equal([]string{"/usr/bin/dumb-init", "--single-child", "--", "/bin/bash", "/opt/hcf/run.sh"}, role.GetEntrypoint(),
+		"dumb-init is PID 1 by default")
+	defaultInputs := role.GetRoleDevVersionInputs()
+	defaultVersion := role.GetRoleDevVersion()
+	var entrypoint string
+	for _, input := range defaultInputs {
+		if input.Name == "entrypoint" {
+			entrypoint = input.Value
+		}
+	}
+	assert.Equal("entrypoint:/usr/bin/dumb-init --single-child -- /bin/bash /opt/hcf/run.sh", entrypoint,
+		"the default entrypoint is part of the version too, as images built before the init had another")
+
+	role.Run = &RoleRun{Init: RoleRunInitNone}
+	assert.Equal([]string{"/bin/bash", "/opt/hcf/run.sh"}, role.GetEntrypoint(), "the run script is PID 1 without an init")
+	assert.Len(role.GetRoleDevVersionInputs(), len(defaultInputs))
+	assert.NotEqual(defaultVersion, role.GetRoleDevVersion(), "the init is part of the image")
+}
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RoleDependencies returns the names of the roles deployed before this one:
+// the roles it depends on, and the roles it links to, so that their connection
+// info exists
+func (r *Role) RoleDependencies() []string {
+	if r.Run == nil {
+		return nil
+	}
+
+	var result []string
+	seen := map[string]bool{}
+	for _, name := range r.Run.DependsOn {
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	for _, link := range r.Run.Links {
+		if !seen[link.Role] {
+			seen[link.Role] = true
+			result = append(result, link.Role)
+		}
+	}
+	return result
+}
+
+// IsManualTask reports whether the role is a task only run by hand: a manual
+// bosh-task without a schedule. They are never deployed.
+func (r *Role) IsManualTask() bool {
+	return r.Type == RoleTypeBoshTask && r.Run != nil && r.Run.FlightStage == FlightStageManual && r.Run.Schedule == nil
+}
+
+// flightStages orders the flight stages roles are deployed in
+var flightStages = map[FlightStage]int{
+	FlightStagePreFlight:  0,
+	FlightStageFlight:     1,
+	FlightStagePostFlight: 2,
+}
+
+// getFlightStage returns the flight stage a role is deployed in; only tasks
+// have stages other than flight. Manual tasks with a schedule are deployed
+// with the flight roles, as their schedule runs them.
+func (r *Role) getFlightStage() FlightStage {
+	if r.Type != RoleTypeBoshTask || r.Run == nil {
+		return FlightStageFlight
+	}
+	switch r.Run.FlightStage {
+	case FlightStagePreFlight, FlightStagePostFlight:
+		return r.Run.FlightStage
+	}
+	return FlightStageFlight
+}
+
+// DeployWaves groups the roles of the manifest into waves for deployment. The
+// roles of a wave only depend on roles of earlier waves, so the waves can be
+// brought up one after the other with each waiting for the previous one to be
+// ready. Roles within a wave are sorted by name.
+//
+// Pre-flight tasks are deployed before all other roles, and post-flight tasks
+// after all roles but the other post-flight tasks. Manual tasks are in no
+// wave, as they are only run by hand.
+func (m *RoleManifest) DeployWaves() ([]Roles, error) {
+	byName := map[string]*Role{}
+	stages := map[FlightStage][]string{}
+	for _, role := range m.Roles {
+		byName[role.Name] = role
+		if !role.IsManualTask() {
+			stages[role.getFlightStage()] = append(stages[role.getFlightStage()], role.Name)
+		}
+	}
+
+	pending := map[string][]string{}
+	for _, role := range m.Roles {
+		dependencies := role.RoleDependencies()
+		for _, dependency := range dependencies {
+			dependencyRole, ok := byName[dependency]
+			if !ok {
+				return nil, fmt.Errorf("Role %s depends on unknown role %s", role.Name, dependency)
+			}
+			if dependency == role.Name {
+				return nil, fmt.Errorf("Role %s depends on itself", role.Name)
+			}
+			if dependencyRole.IsManualTask() {
+				return nil, fmt.Errorf("Role %s depends on manual task %s, which is never deployed", role.Name, dependency)
+			}
+			if !role.IsManualTask() && flightStages[dependencyRole.getFlightStage()] > flightStages[role.getFlightStage()] {
+				return nil, fmt.Errorf("Role %s of the %s stage depends on role %s of the later %s stage",
+					role.Name, role.getFlightStage(), dependency, dependencyRole.getFlightStage())
+			}
+		}
+		if role.IsManualTask() {
+			continue
+		}
+
+		// Roles come after the roles of the earlier stages
+		switch role.getFlightStage() {
+		case FlightStageFlight:
+			dependencies = append(dependencies, stages[FlightStagePreFlight]...)
+		case FlightStagePostFlight:
+			dependencies = append(dependencies, stages[FlightStagePreFlight]...)
+			dependencies = append(dependencies, stages[FlightStageFlight]...)
+		}
+		pending[role.Name] = dependencies
+	}
+
+	var waves []Roles
+	deployed := map[string]bool{}
+	for len(pending) > 0 {
+		var wave Roles
+		for name, dependencies := range pending {
+			ready := true
+			for _, dependency := range dependencies {
+				ready = ready && deployed[dependency]
+			}
+			if ready {
+				wave = append(wave, byName[name])
+			}
+		}
+
+		if len(wave) == 0 {
+			var names []string
+			for name := range pending {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("Circular dependencies between roles %s", strings.Join(names, ", "))
+		}
+
+		sort.Sort(wave)
+		for _, role := range wave {
+			deployed[role.Name] = true
+			delete(pending, role.Name)
+		}
+		waves = append(waves, wave)
+	}
+
+	return waves, nil
+}
+
+// DeployOrder returns the roles of the manifest in an order they can be
+// deployed in one at a time: every role comes after the roles it depends on
+func (m *RoleManifest) DeployOrder() (Roles, error) {
+	waves, err := m.DeployWaves()
+	if err != nil {
+		return nil, err
+	}
+
+	var result Roles
+	for _, wave := range waves {
+		result = append(result, wave...)
+	}
+	return result, nil
+}
+
+// DeployWave returns the index of the wave of DeployWaves the role is
+// deployed in; manual tasks are in none
+func (r *Role) DeployWave() (int, error) {
+	if r.IsManualTask() {
+		return 0, fmt.Errorf("Role %s is a manual task, which is not deployed", r.Name)
+	}
+	if r.rolesManifest == nil {
+		return 0, nil
+	}
+
+	waves, err := r.rolesManifest.DeployWaves()
+	if err != nil {
+		return 0, err
+	}
+	for i, wave := range waves {
+		for _, role := range wave {
+			if role.Name == r.Name {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("Role %s is not in its role manifest", r.Name)
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeployWaves(t *testing.T) {
+	assert := assert.New(t)
+
+	newRole := func(name string, dependencies ...string) *Role {
+		return &Role{Name: name, Run: &RoleRun{DependsOn: dependencies}}
+	}
+	waveNames := func(waves []Roles) [][]string {
+		var result [][]string
+		for _, wave := range waves {
+			var names []string
+			for _, role := range wave {
+				names = append(names, role.Name)
+			}
+			result = append(result, names)
+		}
+		return result
+	}
+
+	manifest := &RoleManifest{Roles: Roles{
+		newRole("api", "mysql", "nats"),
+		newRole("router", "nats"),
+		newRole("nats"),
+		newRole("mysql"),
+		newRole("smoke-tests", "api", "router"),
+		{Name: "no-run"},
+	}}
+	waves, err := manifest.DeployWaves()
+	if assert.NoError(err) {
+		assert.Equal([][]string{
+			{"mysql", "nats", "no-run"},
+			{"api", "router"},
+			{"smoke-tests"},
+		}, waveNames(waves))
+	}
+
+	manifest = &RoleManifest{Roles: Roles{newRole("api", "database")}}
+	_, err = manifest.DeployWaves()
+	assert.EqualError(err, "Role api depends on unknown role database")
+
+	manifest = &RoleManifest{Roles: Roles{newRole("api", "api")}}
+	_, err = manifest.DeployWaves()
+	assert.EqualError(err, "Role api depends on itself")
+
+	manifest = &RoleManifest{Roles: Roles{
+		newRole("nats"),
+		newRole("api", "router", "nats"),
+		newRole("router", "api"),
+	}}
+	_, err = manifest.DeployWaves()
+	assert.EqualError(err, "Circular dependencies between roles api, router")
+}
+
+func TestDeployOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest := &RoleManifest{Roles: Roles{
+		{Name: "api", Run: &RoleRun{DependsOn: []string{"nats"}, Links: []*RoleRunLink{{Role: "mysql"}, {Role: "nats"}}}},
+		{Name: "nats", Run: &RoleRun{}},
+		{Name: "mysql", Run: &RoleRun{}},
+		{Name: "smoke-tests", Run: &RoleRun{DependsOn: []string{"api"}}},
+	}}
+	for _, role := range manifest.Roles {
+		role.rolesManifest = manifest
+	}
+
+	assert.Equal([]string{"nats", "mysql"}, manifest.Roles[0].RoleDependencies(), "Links are dependencies too, listed once")
+
+	order, err := manifest.DeployOrder()
+	if assert.NoError(err) {
+		var names []string
+		for _, role := range order {
+			names = append(names, role.Name)
+		}
+		assert.Equal([]string{"mysql", "nats", "api", "smoke-tests"}, names)
+	}
+
+	for i, expected := range []int{1, 0, 0, 2} {
+		wave, err := manifest.Roles[i].DeployWave()
+		if assert.NoError(err) {
+			assert.Equal(expected, wave, manifest.Roles[i].Name)
+		}
+	}
+}
+
+func TestDeployWavesFlightStages(t *testing.T) {
+	assert := assert.New(t)
+
+	newTask := func(name string, stage FlightStage, dependencies ...string) *Role {
+		return &Role{Name: name, Type: RoleTypeBoshTask, Run: &RoleRun{FlightStage: stage, DependsOn: dependencies}}
+	}
+
+	manifest := &RoleManifest{Roles: Roles{
+		{Name: "api", Type: RoleTypeBosh, Run: &RoleRun{FlightStage: FlightStageFlight}},
+		newTask("migrate", FlightStagePreFlight),
+		newTask("smoke-tests", FlightStagePostFlight),
+		newTask("acceptance-tests", FlightStagePostFlight, "smoke-tests"),
+		newTask("backfill", FlightStageManual, "api"),
+		{Name: "nightly", Type: RoleTypeBoshTask, Run: &RoleRun{FlightStage: FlightStageManual, Schedule: &RoleRunSchedule{Cron: "0 2 * * *"}}},
+	}}
+	waves, err := manifest.DeployWaves()
+	if assert.NoError(err) {
+		var names [][]string
+		for _, wave := range waves {
+			var waveNames []string
+			for _, role := range wave {
+				waveNames = append(waveNames, role.Name)
+			}
+			names = append(names, waveNames)
+		}
+		assert.Equal([][]string{
+			{"migrate"},
+			{"api", "nightly"},
+			{"smoke-tests"},
+			{"acceptance-tests"},
+		}, names, "Manual tasks with a schedule are deployed, the others are not")
+	}
+
+	assert.True(manifest.Roles[4].IsManualTask())
+	_, err = manifest.Roles[4].DeployWave()
+	assert.EqualError(err, "Role backfill is a manual task, which is not deployed")
+
+	manifest = &RoleManifest{Roles: Roles{
+		newTask("migrate", FlightStagePreFlight, "api"),
+		{Name: "api", Type: RoleTypeBosh, Run: &RoleRun{FlightStage: FlightStageFlight}},
+	}}
+	_, err = manifest.DeployWaves()
+	assert.EqualError(err, "Role migrate of the pre-flight stage depends on role api of the later flight stage")
+
+	manifest = &RoleManifest{Roles: Roles{
+		newTask("backfill", FlightStageManual),
+		{Name: "api", Type: RoleTypeBosh, Run: &RoleRun{DependsOn: []string{"backfill"}}},
+	}}
+	_, err = manifest.DeployWaves()
+	assert.EqualError(err, "Role api depends on manual task backfill, which is never deployed")
+}
@@ -13,11 +13,18 @@ import (
 
 // NewDevRelease will create an instance of a BOSH development release
 func NewDevRelease(path, releaseName, version, boshCacheDir string) (*Release, error) {
+	return NewDevReleaseWithOptions(path, releaseName, version, boshCacheDir, ReleaseOptions{})
+}
+
+// NewDevReleaseWithOptions creates a BOSH development release like
+// NewDevRelease, loading it with the given options
+func NewDevReleaseWithOptions(path, releaseName, version, boshCacheDir string, options ReleaseOptions) (*Release, error) {
 	release := &Release{
 		Path:            path,
 		Name:            releaseName,
 		Version:         version,
 		DevBOSHCacheDir: boshCacheDir,
+		options:         options,
 	}
 
 	if err := release.validateDevPathStructure(); err != nil {
@@ -50,6 +57,10 @@ func NewDevRelease(path, releaseName, version, boshCacheDir string) (*Release, e
 		return nil, err
 	}
 
+	if err := release.loadStemcellConstraints(); err != nil {
+		return nil, err
+	}
+
 	if err := release.loadPackages(); err != nil {
 		return nil, err
 	}
@@ -58,6 +69,10 @@ func NewDevRelease(path, releaseName, version, boshCacheDir string) (*Release, e
 		return nil, err
 	}
 
+	if err := release.loadBlobs(); err != nil {
+		return nil, err
+	}
+
 	if err := release.loadJobs(); err != nil {
 		return nil, err
 	}
@@ -297,3 +297,30 @@ func TestDevReleaseJobExtractOk(t *testing.T) {
 
 	assert.Nil(util.ValidatePath(extractedPath, true, "extracted job dir"))
 }
+
+func TestDevReleaseScratchDir(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathBoshCache := filepath.Join(releasePath, "bosh-cache")
+
+	scratchDir, err := ioutil.TempDir("", "fissile-scratch")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(scratchDir)
+
+	_, err = NewDevReleaseWithOptions(releasePath, "", "", releasePathBoshCache, ReleaseOptions{ScratchDir: scratchDir})
+	assert.NoError(err)
+	files, err := ioutil.ReadDir(scratchDir)
+	assert.NoError(err)
+	assert.Empty(files, "the extracted jobs are cleaned up")
+
+	_, err = NewDevReleaseWithOptions(releasePath, "", "", releasePathBoshCache, ReleaseOptions{ScratchDir: filepath.Join(scratchDir, "missing")})
+	if assert.Error(err, "the jobs are extracted in the scratch directory") {
+		assert.Contains(err.Error(), filepath.Join(scratchDir, "missing"))
+	}
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RoleRunDevice describes a host device a role needs. Either Path names a
+// device node on the host, which is mounted into the (then privileged)
+// container, or Resource names an extended resource advertised by a device
+// plugin on the cluster nodes.
+type RoleRunDevice struct {
+	Path     string `yaml:"path"`     // Device node, e.g. /dev/fuse
+	Resource string `yaml:"resource"` // Device plugin resource, e.g. nvidia.com/gpu
+	Count    int    `yaml:"count"`    // Number of resource instances; defaults to 1
+}
+
+// extendedResourcePattern matches the fully qualified names of extended
+// resources, <domain>/<name>
+var extendedResourcePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)+/[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+
+// IsDevicePlugin returns true if the device is provided by a device plugin
+func (d *RoleRunDevice) IsDevicePlugin() bool {
+	return d.Resource != ""
+}
+
+// GetCount returns the number of instances requested of a device plugin
+// resource
+func (d *RoleRunDevice) GetCount() int {
+	if d.Count == 0 {
+		return 1
+	}
+	return d.Count
+}
+
+// String describes the device for reports
+func (d *RoleRunDevice) String() string {
+	if d.IsDevicePlugin() {
+		return fmt.Sprintf("%s: %d", d.Resource, d.GetCount())
+	}
+	return d.Path
+}
+
+func (d *RoleRunDevice) validate() error {
+	if (d.Path == "") == (d.Resource == "") {
+		return fmt.Errorf("Device should have exactly one of path or resource")
+	}
+
+	if d.Path != "" {
+		if !strings.HasPrefix(d.Path, "/dev/") || path.Clean(d.Path) != d.Path {
+			return fmt.Errorf("Invalid device path %s, expected a device below /dev", d.Path)
+		}
+		if d.Count != 0 {
+			return fmt.Errorf("Device %s has a count, but only device plugin resources can be counted", d.Path)
+		}
+		return nil
+	}
+
+	if !extendedResourcePattern.MatchString(d.Resource) {
+		return fmt.Errorf("Invalid device resource %s, expected <domain>/<name>", d.Resource)
+	}
+	if strings.HasSuffix(strings.SplitN(d.Resource, "/", 2)[0], "kubernetes.io") {
+		return fmt.Errorf("Device resource %s uses a reserved Kubernetes domain", d.Resource)
+	}
+	if d.Count < 0 {
+		return fmt.Errorf("Device resource %s has a negative count", d.Resource)
+	}
+
+	return nil
+}
+
+// validateDevices checks the devices of a role
+func (r *RoleRun) validateDevices() error {
+	seen := map[string]bool{}
+	for _, device := range r.Devices {
+		if err := device.validate(); err != nil {
+			return err
+		}
+
+		key := device.Path + device.Resource
+		if seen[key] {
+			return fmt.Errorf("Device %s is listed more than once", key)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// NeedsPrivileged returns true if the role mounts host device nodes, which
+// requires a privileged container
+func (r *RoleRun) NeedsPrivileged() bool {
+	for _, device := range r.Devices {
+		if !device.IsDevicePlugin() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDevices(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc    string
+		devices []*RoleRunDevice
+		err     string
+	}{
+		{
+			desc: "No devices are valid",
+		},
+		{
+			desc: "Device nodes and plugin resources are valid",
+			devices: []*RoleRunDevice{
+				{Path: "/dev/fuse"},
+				{Path: "/dev/net/tun"},
+				{Resource: "nvidia.com/gpu", Count: 2},
+			},
+		},
+		{
+			desc:    "Devices need a path or a resource",
+			devices: []*RoleRunDevice{{}},
+			err:     "Device should have exactly one of path or resource",
+		},
+		{
+			desc:    "Devices can't have both a path and a resource",
+			devices: []*RoleRunDevice{{Path: "/dev/fuse", Resource: "example.com/fuse"}},
+			err:     "Device should have exactly one of path or resource",
+		},
+		{
+			desc:    "Device paths must be below /dev",
+			devices: []*RoleRunDevice{{Path: "/dev/../etc/passwd"}},
+			err:     "Invalid device path /dev/../etc/passwd, expected a device below /dev",
+		},
+		{
+			desc:    "Device nodes can't be counted",
+			devices: []*RoleRunDevice{{Path: "/dev/fuse", Count: 2}},
+			err:     "Device /dev/fuse has a count, but only device plugin resources can be counted",
+		},
+		{
+			desc:    "Device resources need a domain",
+			devices: []*RoleRunDevice{{Resource: "gpu"}},
+			err:     "Invalid device resource gpu, expected <domain>/<name>",
+		},
+		{
+			desc:    "Kubernetes resources are not devices",
+			devices: []*RoleRunDevice{{Resource: "alpha.kubernetes.io/nvidia-gpu"}},
+			err:     "Device resource alpha.kubernetes.io/nvidia-gpu uses a reserved Kubernetes domain",
+		},
+		{
+			desc:    "Devices are only listed once",
+			devices: []*RoleRunDevice{{Path: "/dev/fuse"}, {Path: "/dev/fuse"}},
+			err:     "Device /dev/fuse is listed more than once",
+		},
+	}
+
+	for _, sample := range samples {
+		run := RoleRun{Devices: sample.devices}
+		err := run.validateDevices()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestRoleRunNeedsPrivileged(t *testing.T) {
+	assert := assert.New(t)
+
+	run := RoleRun{Devices: []*RoleRunDevice{{Resource: "nvidia.com/gpu"}}}
+	assert.False(run.NeedsPrivileged())
+	assert.Equal(1, run.Devices[0].GetCount())
+
+	run.Devices = append(run.Devices, &RoleRunDevice{Path: "/dev/fuse"})
+	assert.True(run.NeedsPrivileged())
+}
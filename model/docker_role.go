@@ -0,0 +1,52 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// dockerImagePattern matches image references, [registry/]repository[:tag][@digest]
+var dockerImagePattern = regexp.MustCompile(`^[a-z0-9]+([._:/@-][A-Za-z0-9_.-]+)*$`)
+
+// validateDockerRole checks a role of type docker. Its image is used as it
+// is, so it can't have anything fissile would add to images it builds: jobs,
+// role scripts, bundles, OS packages or Dockerfile snippets. The environment of its containers has
+// the variables used by the configuration templates of the role.
+func (r *Role) validateDockerRole() error {
+	if r.Image == "" {
+		return fmt.Errorf("Role %s is of type %s, but has no image", r.Name, RoleTypeDocker)
+	}
+	if !dockerImagePattern.MatchString(r.Image) {
+		return fmt.Errorf("Role %s has an invalid image '%s'", r.Name, r.Image)
+	}
+
+	var unsupported []string
+	if len(r.JobNameList) > 0 {
+		unsupported = append(unsupported, "jobs")
+	}
+	if len(r.EnvironScripts) > 0 || len(r.Scripts) > 0 || len(r.PostConfigScripts) > 0 {
+		unsupported = append(unsupported, "scripts")
+	}
+	if len(r.Bundles) > 0 {
+		unsupported = append(unsupported, "bundles")
+	}
+	if r.OSPackages != nil {
+		unsupported = append(unsupported, "os-packages")
+	}
+	if r.Dockerfile != "" {
+		unsupported = append(unsupported, "dockerfile")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("Role %s is of type %s, which uses a pre-built image; it can't have %s", r.Name, RoleTypeDocker, strings.Join(unsupported, ", "))
+	}
+
+	return nil
+}
+
+// IsLongRunning returns true if the containers of the role keep running, as
+// the ones of bosh and docker roles do; the ones of bosh-task roles exit once
+// they are done
+func (r *Role) IsLongRunning() bool {
+	return r.Type == RoleTypeBosh || r.Type == RoleTypeDocker
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDockerRole(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		role Role
+		err  string
+	}{
+		{
+			desc: "Docker roles with an image are valid",
+			role: Role{Name: "db", Image: "mysql:5.7"},
+		},
+		{
+			desc: "Images can be in other registries, and pinned by digest",
+			role: Role{Name: "db", Image: "registry.example.com:5000/library/mysql@sha256:0123abcd"},
+		},
+		{
+			desc: "Docker roles need an image",
+			role: Role{Name: "db"},
+			err:  "Role db is of type docker, but has no image",
+		},
+		{
+			desc: "Images are valid references",
+			role: Role{Name: "db", Image: "MySQL 5.7"},
+			err:  "Role db has an invalid image 'MySQL 5.7'",
+		},
+		{
+			desc: "Docker roles can't have jobs or scripts",
+			role: Role{
+				Name:        "db",
+				Image:       "mysql:5.7",
+				JobNameList: []*roleJob{{Name: "tor", ReleaseName: "tor"}},
+				Scripts:     []string{"setup.sh"},
+			},
+			err: "Role db is of type docker, which uses a pre-built image; it can't have jobs, scripts",
+		},
+	}
+
+	for _, sample := range samples {
+		sample.role.Type = RoleTypeDocker
+		err := sample.role.validateDockerRole()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package model
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RoleDockerfileContext is the directory of the build context of role images
+// with the files the COPY and ADD instructions of the Dockerfile snippet of
+// the role add, at their paths relative to the snippet
+const RoleDockerfileContext = "role-dockerfile"
+
+// forbiddenDockerfileInstructions are the instructions snippets can't use;
+// the role images are built from the base image, and run run.sh
+var forbiddenDockerfileInstructions = []string{"FROM", "ENTRYPOINT", "CMD"}
+
+// loadDockerfile reads the Dockerfile snippet of the role, if it has one, and
+// checks its instructions. Relative paths are relative to the role manifest.
+func (r *Role) loadDockerfile() error {
+	if r.Dockerfile == "" {
+		return nil
+	}
+	snippetPath := r.GetDockerfilePath()
+	contents, err := ioutil.ReadFile(snippetPath)
+	if err != nil {
+		return fmt.Errorf("Error reading dockerfile %s of role %s: %s", r.Dockerfile, r.Name, err.Error())
+	}
+
+	files := map[string]bool{}
+	for _, line := range dockerfileInstructions(string(contents)) {
+		fields := strings.Fields(line)
+		instruction := strings.ToUpper(fields[0])
+		for _, forbidden := range forbiddenDockerfileInstructions {
+			if instruction == forbidden {
+				return fmt.Errorf("Role %s: dockerfile %s uses %s, which role images can't change", r.Name, r.Dockerfile, forbidden)
+			}
+		}
+		if instruction != "COPY" && instruction != "ADD" {
+			continue
+		}
+
+		for _, source := range dockerfileSources(strings.TrimSpace(line[len(fields[0]):])) {
+			path := strings.TrimPrefix(source, RoleDockerfileContext+"/")
+			if source == RoleDockerfileContext {
+				path = "."
+			} else if path == source {
+				// Not one of the files of the snippet, e.g. a URL
+				continue
+			}
+			path = filepath.Clean(path)
+			if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("Role %s: dockerfile %s adds %s, which is outside of its directory", r.Name, r.Dockerfile, source)
+			}
+			matches, err := filepath.Glob(filepath.Join(filepath.Dir(snippetPath), path))
+			if err != nil {
+				return fmt.Errorf("Role %s: dockerfile %s adds %s: %s", r.Name, r.Dockerfile, source, err.Error())
+			}
+			if len(matches) == 0 {
+				return fmt.Errorf("Role %s: dockerfile %s adds %s, which is missing from its directory", r.Name, r.Dockerfile, source)
+			}
+			for _, match := range matches {
+				relativePath, _ := filepath.Rel(filepath.Dir(snippetPath), match)
+				files[relativePath] = true
+			}
+		}
+	}
+
+	r.dockerfile = string(contents)
+	r.dockerfileFiles = make([]string, 0, len(files))
+	for path := range files {
+		r.dockerfileFiles = append(r.dockerfileFiles, path)
+	}
+	sort.Strings(r.dockerfileFiles)
+	return nil
+}
+
+// dockerfileInstructions returns the instructions of a Dockerfile, with their
+// continued lines joined, and without comments and empty lines
+func dockerfileInstructions(contents string) []string {
+	var instructions []string
+	current := ""
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		continued := strings.HasSuffix(line, "\\")
+		current += " " + strings.TrimSuffix(line, "\\")
+		if !continued {
+			instructions = append(instructions, strings.TrimSpace(current))
+			current = ""
+		}
+	}
+	if current = strings.TrimSpace(current); current != "" {
+		instructions = append(instructions, current)
+	}
+	return instructions
+}
+
+// dockerfileSources returns the sources of the arguments of a COPY or ADD
+// instruction, in either the plain or the JSON form; nil for copies from
+// other images, which add no files of the build context
+func dockerfileSources(arguments string) []string {
+	var fields []string
+	for {
+		if !strings.HasPrefix(arguments, "--") {
+			break
+		}
+		flag := strings.Fields(arguments)[0]
+		if strings.HasPrefix(flag, "--from=") {
+			return nil
+		}
+		arguments = strings.TrimSpace(arguments[len(flag):])
+	}
+	if strings.HasPrefix(arguments, "[") {
+		if json.Unmarshal([]byte(arguments), &fields) != nil {
+			return nil
+		}
+	} else {
+		fields = strings.Fields(arguments)
+	}
+	if len(fields) < 2 {
+		return nil
+	}
+	return fields[:len(fields)-1]
+}
+
+// GetDockerfilePath returns the path of the Dockerfile snippet of the role;
+// empty without one
+func (r *Role) GetDockerfilePath() string {
+	if r.Dockerfile == "" || filepath.IsAbs(r.Dockerfile) || r.rolesManifest == nil {
+		return r.Dockerfile
+	}
+	return filepath.Join(filepath.Dir(r.rolesManifest.manifestFilePath), r.Dockerfile)
+}
+
+// GetDockerfileSnippet returns the Dockerfile snippet of the role, appended to
+// the Dockerfile of its image; empty without one
+func (r *Role) GetDockerfileSnippet() string {
+	return r.dockerfile
+}
+
+// GetDockerfileFiles returns the files and directories the Dockerfile snippet
+// of the role adds from role-dockerfile/, relative to the directory of the
+// snippet; nothing else next to it is part of the build context
+func (r *Role) GetDockerfileFiles() []string {
+	return r.dockerfileFiles
+}
+
+// dockerfileSignature identifies the Dockerfile snippet of the role and the
+// files it adds to the image; empty without a snippet
+func (r *Role) dockerfileSignature() string {
+	if r.dockerfile == "" {
+		return ""
+	}
+
+	hasher := sha1.New()
+	hasher.Write([]byte(r.dockerfile))
+	dir := filepath.Dir(r.GetDockerfilePath())
+	for _, path := range r.dockerfileFiles {
+		fmt.Fprintf(hasher, "\n%s\n%s", path, treeSignature(filepath.Join(dir, path), nil))
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestDockerfile(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	rolesManifest, err := LoadRoleManifest(filepath.Join(workDir, "../test-assets/role-manifests/dockerfile.yml"), []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	role := rolesManifest.Roles[0]
+	assert.Equal(filepath.Join(workDir, "../test-assets/role-manifests/dockerfile/Dockerfile.myrole"), role.GetDockerfilePath())
+	assert.Contains(role.GetDockerfileSnippet(), "COPY role-dockerfile/torrc.extra /etc/tor/torrc.extra")
+	assert.Equal([]string{"torrc.extra"}, role.GetDockerfileFiles())
+
+	// The snippet is part of the image version
+	versionWithSnippet := role.GetRoleDevVersion()
+	role.dockerfile = ""
+	assert.NotEqual(versionWithSnippet, role.GetRoleDevVersion())
+}
+
+func TestLoadDockerfile(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-dockerfile-tests")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, path := range []string{"foo", "conf/a.conf", "conf/b.conf"} {
+		path = filepath.Join(tempDir, path)
+		if !assert.NoError(os.MkdirAll(filepath.Dir(path), 0755)) {
+			return
+		}
+		if !assert.NoError(ioutil.WriteFile(path, []byte(path), 0644)) {
+			return
+		}
+	}
+
+	samples := []struct {
+		desc    string
+		snippet string
+		files   []string
+		err     string
+	}{
+		{
+			desc:    "RUN and COPY instructions are fine",
+			snippet: "RUN apt-get install -y curl\nCOPY role-dockerfile/foo /foo\n",
+			files:   []string{"foo"},
+		},
+		{
+			desc:    "Comments and continued lines aren't instructions",
+			snippet: "# FROM is not allowed\nRUN echo \\\n  cmd\n",
+			files:   []string{},
+		},
+		{
+			desc:    "Only the files COPY and ADD add are in the context, in any form",
+			snippet: "ADD --chown=vcap role-dockerfile/conf/*.conf \\\n  /etc/\nCOPY [\"role-dockerfile/foo\", \"/foo\"]\nADD https://example.com/x /x\nCOPY --from=busybox /bin/sh /bin/sh\n",
+			files:   []string{"conf/a.conf", "conf/b.conf", "foo"},
+		},
+		{
+			desc:    "Files must exist",
+			snippet: "COPY role-dockerfile/bar /bar\n",
+			err:     "Role myrole: dockerfile Dockerfile.snippet adds role-dockerfile/bar, which is missing from its directory",
+		},
+		{
+			desc:    "Files must be next to the snippet",
+			snippet: "COPY role-dockerfile/../foo /foo\n",
+			err:     "Role myrole: dockerfile Dockerfile.snippet adds role-dockerfile/../foo, which is outside of its directory",
+		},
+		{
+			desc:    "FROM is not allowed",
+			snippet: "RUN true\nfrom ubuntu\n",
+			err:     "Role myrole: dockerfile Dockerfile.snippet uses FROM, which role images can't change",
+		},
+		{
+			desc:    "ENTRYPOINT is not allowed",
+			snippet: "ENTRYPOINT [\"/bin/sh\"]\n",
+			err:     "Role myrole: dockerfile Dockerfile.snippet uses ENTRYPOINT, which role images can't change",
+		},
+		{
+			desc:    "CMD is not allowed",
+			snippet: "  CMD /bin/sh\n",
+			err:     "Role myrole: dockerfile Dockerfile.snippet uses CMD, which role images can't change",
+		},
+	}
+
+	for _, sample := range samples {
+		snippetPath := filepath.Join(tempDir, "Dockerfile.snippet")
+		if !assert.NoError(ioutil.WriteFile(snippetPath, []byte(sample.snippet), 0644)) {
+			return
+		}
+
+		role := &Role{
+			Name:          "myrole",
+			Dockerfile:    "Dockerfile.snippet",
+			rolesManifest: &RoleManifest{manifestFilePath: filepath.Join(tempDir, "role-manifest.yml")},
+		}
+		err := role.loadDockerfile()
+		if sample.err == "" {
+			if assert.NoError(err, sample.desc) {
+				assert.Equal(sample.snippet, role.GetDockerfileSnippet(), sample.desc)
+				assert.Equal(sample.files, role.GetDockerfileFiles(), sample.desc)
+			}
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+
+	// Other files next to the snippet are not part of the version
+	role := &Role{
+		Name:          "myrole",
+		Dockerfile:    "Dockerfile.snippet",
+		rolesManifest: &RoleManifest{manifestFilePath: filepath.Join(tempDir, "role-manifest.yml")},
+	}
+	if !assert.NoError(ioutil.WriteFile(filepath.Join(tempDir, "Dockerfile.snippet"), []byte("COPY role-dockerfile/foo /foo\n"), 0644)) {
+		return
+	}
+	if !assert.NoError(role.loadDockerfile()) {
+		return
+	}
+	signature := role.dockerfileSignature()
+	assert.NoError(ioutil.WriteFile(filepath.Join(tempDir, "conf/a.conf"), []byte("changed"), 0644))
+	assert.Equal(signature, role.dockerfileSignature(), "files the snippet doesn't add are left out")
+	assert.NoError(ioutil.WriteFile(filepath.Join(tempDir, "foo"), []byte("changed"), 0644))
+	assert.NotEqual(signature, role.dockerfileSignature(), "files the snippet adds are part of the signature")
+
+	role = &Role{
+		Name:          "myrole",
+		Dockerfile:    "missing/Dockerfile.snippet",
+		rolesManifest: &RoleManifest{manifestFilePath: filepath.Join(tempDir, "role-manifest.yml")},
+	}
+	assert.Error(role.loadDockerfile())
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+// environmentOverlay is the layout of an overlay file, patching the settings
+// of a role manifest that differ between environments
+type environmentOverlay struct {
+	Roles         []*environmentOverlayRole    `yaml:"roles"`
+	Configuration *environmentOverlayTemplates `yaml:"configuration"`
+}
+
+// environmentOverlayRole patches a role of the manifest, by its name
+type environmentOverlayRole struct {
+	Name          string                       `yaml:"name"`
+	Run           *environmentOverlayRun       `yaml:"run"`
+	Configuration *environmentOverlayTemplates `yaml:"configuration"`
+}
+
+// environmentOverlayRun holds the run settings overlays can set
+type environmentOverlayRun struct {
+	Instances    int32                 `yaml:"instances"`
+	Scaling      *RoleRunScaling       `yaml:"scaling"`
+	ExposedPorts []*RoleRunExposedPort `yaml:"exposed-ports"`
+}
+
+// environmentOverlayTemplates holds the configuration templates overlays set
+type environmentOverlayTemplates struct {
+	Templates map[string]string `yaml:"templates"`
+}
+
+// applyEnvironment patches the role manifest with the overlay the environments
+// section of the manifest names for the environment it is loaded for; no
+// overlay is applied without an environment. The overlay sets the instances, scaling and exposed ports of
+// the roles it lists, replacing the ones of the manifest, and adds to or
+// overrides their configuration templates and the global ones key by key.
+// Paths of overlays are relative to the manifest.
+func (m *RoleManifest) applyEnvironment() error {
+	environment := m.options.Environment
+	if environment == "" {
+		return nil
+	}
+	overlayPath, ok := m.Environments[environment]
+	if !ok {
+		return fmt.Errorf("The role manifest has no overlay for environment %s", environment)
+	}
+	if !filepath.IsAbs(overlayPath) {
+		overlayPath = filepath.Join(filepath.Dir(m.manifestFilePath), overlayPath)
+	}
+
+	contents, err := ioutil.ReadFile(overlayPath)
+	if err != nil {
+		return err
+	}
+	var overlay environmentOverlay
+	if err := yaml.Unmarshal(contents, &overlay); err != nil {
+		return fmt.Errorf("Error reading environment overlay %s: %s", overlayPath, err.Error())
+	}
+	if err := validateOverlaySchema(overlayPath, contents); err != nil {
+		return err
+	}
+
+	rolesByName := make(map[string]*Role, len(m.Roles))
+	for _, role := range m.Roles {
+		rolesByName[role.Name] = role
+	}
+	for _, overlayRole := range overlay.Roles {
+		role, ok := rolesByName[overlayRole.Name]
+		if !ok {
+			return fmt.Errorf("Environment overlay %s patches role %s, which is not in the role manifest", overlayPath, overlayRole.Name)
+		}
+
+		if run := overlayRole.Run; run != nil {
+			if role.Run == nil {
+				role.Run = &RoleRun{}
+			}
+			if run.Instances != 0 {
+				role.Run.Instances = run.Instances
+			}
+			if run.Scaling != nil {
+				role.Run.Scaling = run.Scaling
+			}
+			if run.ExposedPorts != nil {
+				role.Run.ExposedPorts = run.ExposedPorts
+			}
+		}
+
+		if overlayRole.Configuration != nil && len(overlayRole.Configuration.Templates) > 0 {
+			if role.Configuration == nil {
+				role.Configuration = &Configuration{}
+			}
+			role.Configuration.Templates = overlayTemplates(role.Configuration.Templates, overlayRole.Configuration.Templates)
+		}
+	}
+
+	if overlay.Configuration != nil && len(overlay.Configuration.Templates) > 0 {
+		if m.Configuration == nil {
+			m.Configuration = &Configuration{}
+		}
+		m.Configuration.Templates = overlayTemplates(m.Configuration.Templates, overlay.Configuration.Templates)
+	}
+
+	m.includedFiles = append(m.includedFiles, overlayPath)
+	return nil
+}
+
+// overlayTemplates returns the templates with the ones of an overlay added,
+// replacing the ones with the same key
+func overlayTemplates(templates, overrides map[string]string) map[string]string {
+	if templates == nil {
+		templates = map[string]string{}
+	}
+	for key, template := range overrides {
+		templates[key] = template
+	}
+	return templates
+}
+
+// validateOverlaySchema checks the contents of an environment overlay for keys
+// it can't set, or that fissile doesn't know; see validateManifestSchema
+func validateOverlaySchema(path string, contents []byte) error {
+	var overlay interface{}
+	if err := yaml.Unmarshal(contents, &overlay); err != nil {
+		return err
+	}
+
+	v := &manifestSchemaValidator{
+		path:  path,
+		lines: indexYAMLLines(contents),
+	}
+	if document, ok := overlay.(map[interface{}]interface{}); ok {
+		roles, _ := document["roles"].([]interface{})
+		for _, role := range roles {
+			fields, _ := role.(map[interface{}]interface{})
+			name, _ := fields["name"].(string)
+			v.roleNames = append(v.roleNames, name)
+		}
+	}
+	v.checkKeys(overlay, reflect.TypeOf(environmentOverlay{}), nil)
+
+	return v.err()
+}
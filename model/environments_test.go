@@ -0,0 +1,97 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestEnvironments(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/environments.yml")
+	rolesManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(int32(1), rolesManifest.LookupRole("myrole").Replicas(), "No overlay is applied without an environment")
+	assert.Empty(rolesManifest.IncludedFiles())
+
+	rolesManifest, err = LoadRoleManifestWithOptions(roleManifestPath, []*Release{release}, RoleManifestOptions{Environment: "prod"})
+	if !assert.NoError(err) {
+		return
+	}
+
+	myrole := rolesManifest.LookupRole("myrole")
+	assert.Equal(int32(3), myrole.Run.Instances)
+	assert.Equal(128, myrole.Run.Memory, "Settings the overlay leaves out are kept")
+	if assert.Len(myrole.Run.ExposedPorts, 1, "Exposed ports are replaced") {
+		assert.Equal("https", myrole.Run.ExposedPorts[0].Name)
+	}
+	assert.Equal("((PROD_KEY))", myrole.Configuration.Templates["properties.tor.private_key"])
+
+	tor := rolesManifest.LookupRole("tor")
+	if assert.NotNil(tor.Run.Scaling) {
+		assert.Equal(int32(2), tor.Replicas())
+	}
+
+	assert.Equal("example.com", rolesManifest.Configuration.Templates["properties.tor.hostname"])
+	assert.Equal("keys", rolesManifest.Configuration.Templates["properties.tor.client_keys"])
+	assert.Equal([]string{filepath.Join(workDir, "../test-assets/role-manifests/environments/prod.yml")}, rolesManifest.IncludedFiles())
+}
+
+func TestLoadRoleManifestEnvironmentErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/environments.yml")
+	overlaysDir := filepath.Join(workDir, "../test-assets/role-manifests/environments")
+	samples := []struct {
+		desc        string
+		environment string
+		err         string
+	}{
+		{
+			desc:        "Environments need an overlay",
+			environment: "staging",
+			err:         "The role manifest has no overlay for environment staging",
+		},
+		{
+			desc:        "Overlays can only set some keys",
+			environment: "typo",
+			err: overlaysDir + "/typo.yml:5: Role myrole: unknown key run.instance, did you mean instances?\n" +
+				overlaysDir + "/typo.yml:6: Role myrole: unknown key run.memory",
+		},
+		{
+			desc:        "Overlays patch existing roles",
+			environment: "unknown-role",
+			err:         "Environment overlay " + overlaysDir + "/unknown-role.yml patches role missing, which is not in the role manifest",
+		},
+	}
+
+	for _, sample := range samples {
+		_, err := LoadRoleManifestWithOptions(roleManifestPath, []*Release{release}, RoleManifestOptions{Environment: sample.environment})
+		assert.EqualError(err, sample.err, sample.desc)
+	}
+}
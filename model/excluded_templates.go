@@ -0,0 +1,66 @@
+package model
+
+import (
+	"fmt"
+)
+
+// JobMonitTemplate is the name leaving out the monit file of a job in the
+// excluded templates of a role job
+const JobMonitTemplate = "monit"
+
+// isTemplateExcluded reports whether a role leaves out a template of a job,
+// by its source or destination path
+func (r *Role) isTemplateExcluded(job *Job, name string) bool {
+	roleJob := r.lookupRoleJob(job.Name)
+	if roleJob == nil {
+		return false
+	}
+	for _, excluded := range roleJob.ExcludeTemplates {
+		if excluded == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetJobTemplates returns the templates of a job the role renders, without
+// the ones it leaves out
+func (r *Role) GetJobTemplates(job *Job) []*JobTemplate {
+	var result []*JobTemplate
+	for _, template := range job.Templates {
+		if r.isTemplateExcluded(job, template.SourcePath) || r.isTemplateExcluded(job, template.DestinationPath) {
+			continue
+		}
+		result = append(result, template)
+	}
+	return result
+}
+
+// HasJobMonit reports whether the role keeps the monit file of a job, so that
+// monit runs its processes
+func (r *Role) HasJobMonit(job *Job) bool {
+	return !r.isTemplateExcluded(job, JobMonitTemplate)
+}
+
+// validateExcludedTemplates checks that the templates the jobs of a role leave
+// out are templates of the jobs
+func (r *Role) validateExcludedTemplates() error {
+	for _, job := range r.Jobs {
+		roleJob := r.lookupRoleJob(job.Name)
+		if roleJob == nil {
+			continue
+		}
+		for _, excluded := range roleJob.ExcludeTemplates {
+			found := excluded == JobMonitTemplate
+			for _, template := range job.Templates {
+				if excluded == template.SourcePath || excluded == template.DestinationPath {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("Role %s, job %s: excluded template %s is not a template of the job", r.Name, roleJob.Name, excluded)
+			}
+		}
+	}
+	return nil
+}
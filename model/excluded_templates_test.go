@@ -0,0 +1,94 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestExcludeTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	rolesManifest, err := LoadRoleManifest(filepath.Join(workDir, "../test-assets/role-manifests/exclude-templates.yml"), []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	role := rolesManifest.Roles[0]
+	tor := role.Jobs[0]
+	var destinations []string
+	for _, template := range role.GetJobTemplates(tor) {
+		destinations = append(destinations, template.DestinationPath)
+	}
+	assert.Len(destinations, len(tor.Templates)-2)
+	assert.Contains(destinations, "bin/tor_ctl")
+	assert.NotContains(destinations, "bin/monit_debugger")
+	assert.NotContains(destinations, "hidden_service/hostname")
+	assert.True(role.HasJobMonit(tor))
+
+	newHostname := role.Jobs[1]
+	assert.Len(role.GetJobTemplates(newHostname), len(newHostname.Templates))
+	assert.False(role.HasJobMonit(newHostname))
+
+	// The excluded templates are part of the image version
+	versionWithExclusions := role.GetRoleDevVersion()
+	role.JobNameList[0].ExcludeTemplates = nil
+	assert.NotEqual(versionWithExclusions, role.GetRoleDevVersion())
+}
+
+func TestValidateExcludedTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	job := &Job{Name: "tor", Templates: []*JobTemplate{
+		{SourcePath: "config/torrc.erb", DestinationPath: "config/torrc"},
+	}}
+
+	samples := []struct {
+		desc     string
+		excluded []string
+		err      string
+	}{
+		{
+			desc: "No excluded templates are valid",
+		},
+		{
+			desc:     "Templates are excluded by source or destination path",
+			excluded: []string{"config/torrc.erb", "config/torrc"},
+		},
+		{
+			desc:     "The monit file can be excluded",
+			excluded: []string{"monit"},
+		},
+		{
+			desc:     "Excluded templates are templates of the job",
+			excluded: []string{"config/nginx.conf"},
+			err:      "Role myrole, job tor: excluded template config/nginx.conf is not a template of the job",
+		},
+	}
+
+	for _, sample := range samples {
+		role := &Role{
+			Name:        "myrole",
+			JobNameList: []*roleJob{{Name: "tor", ExcludeTemplates: sample.excluded}},
+			Jobs:        Jobs{job},
+		}
+		err := role.validateExcludedTemplates()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else if assert.Error(err, sample.desc) {
+			assert.Equal(sample.err, err.Error(), sample.desc)
+		}
+	}
+}
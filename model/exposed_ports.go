@@ -0,0 +1,118 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// portRange is a range of port numbers of an exposed port, both included
+type portRange struct {
+	min, max int
+}
+
+func (p portRange) overlaps(other portRange) bool {
+	return p.min <= other.max && other.min <= p.max
+}
+
+// parsePortRange parses a port number, or a range of them like 8000-8010, of
+// the given port; kind is internal or external
+func parsePortRange(value, name, kind string) (portRange, error) {
+	bounds := strings.SplitN(value, "-", 2)
+	var result []int
+	for _, bound := range bounds {
+		port, err := strconv.Atoi(strings.TrimSpace(bound))
+		if err != nil {
+			return portRange{}, fmt.Errorf("Port %s has an invalid %s port %s", name, kind, value)
+		}
+		if port < 1 || port > 65535 {
+			return portRange{}, fmt.Errorf("Port %s has %s port %d, which is out of range", name, kind, port)
+		}
+		result = append(result, port)
+	}
+	if len(result) == 1 {
+		return portRange{result[0], result[0]}, nil
+	}
+	if result[0] > result[1] {
+		return portRange{}, fmt.Errorf("Port %s has an invalid %s port range %s", name, kind, value)
+	}
+	return portRange{result[0], result[1]}, nil
+}
+
+// ParsePortRange parses a port number, or a range of them like 8000-8010, of
+// the given port, returning the first and the last port; kind is internal or
+// external, and only names the port in errors
+func ParsePortRange(value, name, kind string) (int32, int32, error) {
+	ports, err := parsePortRange(value, name, kind)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int32(ports.min), int32(ports.max), nil
+}
+
+// portProtocol returns the protocol of an exposed port, TCP by default
+func portProtocol(port *RoleRunExposedPort) string {
+	if port.Protocol == "" {
+		return "TCP"
+	}
+	return strings.ToUpper(port.Protocol)
+}
+
+// validateExposedPorts checks the exposed ports of a role: their names are
+// distinct, their ports are valid, and no two ports of a protocol share an
+// internal port, which the jobs of the role all listen on, nor an external
+// one, which the services of the role all listen on
+func (r *RoleRun) validateExposedPorts() error {
+	type claimedRange struct {
+		name     string
+		protocol string
+		ports    portRange
+	}
+	names := map[string]bool{}
+	var internal, external []claimedRange
+
+	for _, port := range r.ExposedPorts {
+		if port.Name == "" {
+			return fmt.Errorf("Exposed port %s has no name", port.Internal)
+		}
+		if names[port.Name] {
+			return fmt.Errorf("Port name %s is used more than once", port.Name)
+		}
+		names[port.Name] = true
+
+		protocol := portProtocol(port)
+		if protocol != "TCP" && protocol != "UDP" {
+			return fmt.Errorf("Port %s has an invalid protocol %s, expected TCP or UDP", port.Name, port.Protocol)
+		}
+
+		internalPorts, err := parsePortRange(port.Internal, port.Name, "internal")
+		if err != nil {
+			return err
+		}
+		for _, other := range internal {
+			if other.protocol == protocol && other.ports.overlaps(internalPorts) {
+				return fmt.Errorf("Port %s claims internal %s port %s, which port %s claims too", port.Name, protocol, port.Internal, other.name)
+			}
+		}
+		internal = append(internal, claimedRange{port.Name, protocol, internalPorts})
+
+		if port.External == "" {
+			continue
+		}
+		externalPorts, err := parsePortRange(port.External, port.Name, "external")
+		if err != nil {
+			return err
+		}
+		if externalPorts.max-externalPorts.min != internalPorts.max-internalPorts.min {
+			return fmt.Errorf("Port %s has mismatched internal and external port ranges %s and %s", port.Name, port.Internal, port.External)
+		}
+		for _, other := range external {
+			if other.protocol == protocol && other.ports.overlaps(externalPorts) {
+				return fmt.Errorf("Port %s claims external %s port %s, which port %s claims too", port.Name, protocol, port.External, other.name)
+			}
+		}
+		external = append(external, claimedRange{port.Name, protocol, externalPorts})
+	}
+
+	return nil
+}
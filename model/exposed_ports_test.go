@@ -0,0 +1,172 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateExposedPorts(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc  string
+		ports []*RoleRunExposedPort
+		err   string
+	}{
+		{
+			desc: "Roles without exposed ports are valid",
+		},
+		{
+			desc: "Ports and ranges are valid",
+			ports: []*RoleRunExposedPort{
+				{Name: "http", External: "80", Internal: "8080"},
+				{Name: "range", Protocol: "udp", External: "9000-9010", Internal: "10000-10010"},
+				{Name: "dns-tcp", Internal: "53"},
+				{Name: "dns-udp", Protocol: "UDP", Internal: "53"},
+			},
+		},
+		{
+			desc: "Ports have names",
+			ports: []*RoleRunExposedPort{
+				{Internal: "80"},
+			},
+			err: "Exposed port 80 has no name",
+		},
+		{
+			desc: "Names are unique",
+			ports: []*RoleRunExposedPort{
+				{Name: "http", Internal: "80"},
+				{Name: "http", Internal: "81"},
+			},
+			err: "Port name http is used more than once",
+		},
+		{
+			desc: "Protocols are TCP or UDP",
+			ports: []*RoleRunExposedPort{
+				{Name: "http", Protocol: "sctp", Internal: "80"},
+			},
+			err: "Port http has an invalid protocol sctp, expected TCP or UDP",
+		},
+		{
+			desc: "Port numbers are in range",
+			ports: []*RoleRunExposedPort{
+				{Name: "http", Internal: "65536"},
+			},
+			err: "Port http has internal port 65536, which is out of range",
+		},
+		{
+			desc: "Port numbers are numbers",
+			ports: []*RoleRunExposedPort{
+				{Name: "http", External: "http", Internal: "80"},
+			},
+			err: "Port http has an invalid external port http",
+		},
+		{
+			desc: "Ranges are ordered",
+			ports: []*RoleRunExposedPort{
+				{Name: "range", Internal: "9010-9000"},
+			},
+			err: "Port range has an invalid internal port range 9010-9000",
+		},
+		{
+			desc: "Ranges match",
+			ports: []*RoleRunExposedPort{
+				{Name: "range", External: "9000-9001", Internal: "9000-9010"},
+			},
+			err: "Port range has mismatched internal and external port ranges 9000-9010 and 9000-9001",
+		},
+		{
+			desc: "Internal ports of a protocol don't overlap",
+			ports: []*RoleRunExposedPort{
+				{Name: "range", Internal: "9000-9010"},
+				{Name: "api", Protocol: "tcp", Internal: "9005"},
+			},
+			err: "Port api claims internal TCP port 9005, which port range claims too",
+		},
+		{
+			desc: "External ports of a protocol don't overlap",
+			ports: []*RoleRunExposedPort{
+				{Name: "http", External: "80", Internal: "8080"},
+				{Name: "admin", External: "80", Internal: "8081"},
+			},
+			err: "Port admin claims external TCP port 80, which port http claims too",
+		},
+	}
+
+	for _, sample := range samples {
+		run := RoleRun{ExposedPorts: sample.ports}
+		err := run.validateExposedPorts()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc  string
+		input string
+		min   int32
+		max   int32
+		err   string
+	}{
+		{
+			desc:  "single port",
+			input: "1234",
+			min:   1234,
+			max:   1234,
+		},
+		{
+			desc:  "port range",
+			input: "1234-5678",
+			min:   1234,
+			max:   5678,
+		},
+		{
+			desc:  "ports above 32767",
+			input: "40000 - 65535",
+			min:   40000,
+			max:   65535,
+		},
+		{
+			desc:  "invalid number",
+			input: "garbage",
+			err:   "Port http has an invalid external port garbage",
+		},
+		{
+			desc:  "empty port range",
+			input: "",
+			err:   "Port http has an invalid external port ",
+		},
+		{
+			desc:  "invalid end port",
+			input: "1-junk",
+			err:   "Port http has an invalid external port 1-junk",
+		},
+		{
+			desc:  "out of range port",
+			input: "65536",
+			err:   "Port http has external port 65536, which is out of range",
+		},
+		{
+			desc:  "inverted port range",
+			input: "5678-1234",
+			err:   "Port http has an invalid external port range 5678-1234",
+		},
+	}
+
+	for _, sample := range samples {
+		min, max, err := ParsePortRange(sample.input, "http", "external")
+		if sample.err != "" {
+			assert.EqualError(err, sample.err, sample.desc)
+		} else if assert.NoError(err, sample.desc) {
+			assert.Equal(sample.min, min, sample.desc)
+			assert.Equal(sample.max, max, sample.desc)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// These are the kinds of cluster objects roles can mount
+const (
+	ExternalMountConfigMap = "configmap"
+	ExternalMountSecret    = "secret"
+)
+
+// RoleRunExternalMount describes a ConfigMap or Secret that is managed outside
+// of fissile, like a corporate CA bundle, and mounted read-only into the
+// containers of a role. Exactly one of ConfigMap and Secret names the object.
+// A required object must exist before the role is deployed; an optional one is
+// created empty when it does not.
+type RoleRunExternalMount struct {
+	ConfigMap string `yaml:"config-map"`
+	Secret    string `yaml:"secret"`
+	Path      string `yaml:"path"` // Directory the keys of the object show up in, as files
+	Optional  bool   `yaml:"optional"`
+}
+
+// Kind returns the kind of the object mounted, one of ExternalMountConfigMap
+// or ExternalMountSecret
+func (m *RoleRunExternalMount) Kind() string {
+	if m.Secret != "" {
+		return ExternalMountSecret
+	}
+	return ExternalMountConfigMap
+}
+
+// ObjectName returns the name of the object mounted
+func (m *RoleRunExternalMount) ObjectName() string {
+	return m.ConfigMap + m.Secret
+}
+
+// String describes the mount for reports
+func (m *RoleRunExternalMount) String() string {
+	return fmt.Sprintf("%s %s", m.Kind(), m.ObjectName())
+}
+
+func (m *RoleRunExternalMount) validate() error {
+	if (m.ConfigMap == "") == (m.Secret == "") {
+		return fmt.Errorf("External mount should have exactly one of config-map or secret")
+	}
+	if !secretNamePattern.MatchString(m.ObjectName()) {
+		return fmt.Errorf("Invalid name for external mount %s, expected a DNS subdomain", m)
+	}
+	if !path.IsAbs(m.Path) || path.Clean(m.Path) != m.Path {
+		return fmt.Errorf("External mount %s has an invalid path '%s', expected an absolute path", m, m.Path)
+	}
+	if m.Path == "/" {
+		return fmt.Errorf("External mount %s can't be mounted at /", m)
+	}
+	return nil
+}
+
+// validateExternalMounts checks the external mounts of a role, and that their
+// paths don't clash with each other or with the volumes of the role
+func (r *RoleRun) validateExternalMounts() error {
+	paths := map[string]string{}
+	for _, volume := range append(append([]*RoleRunVolume{}, r.PersistentVolumes...), r.SharedVolumes...) {
+		paths[volume.Path] = fmt.Sprintf("volume %s", volume.Tag)
+	}
+	for _, volume := range r.TmpfsVolumes {
+		paths[volume.Path] = fmt.Sprintf("volume %s", volume.Tag)
+	}
+
+	for _, mount := range r.ExternalMounts {
+		if err := mount.validate(); err != nil {
+			return err
+		}
+		if other, ok := paths[mount.Path]; ok {
+			return fmt.Errorf("External mount %s uses the path %s of %s", mount, mount.Path, other)
+		}
+		paths[mount.Path] = fmt.Sprintf("external mount %s", mount)
+	}
+
+	return nil
+}
+
+// ExternalObject is a ConfigMap or Secret that roles mount, and the roles
+// mounting it. It is optional only if it is optional for all of them.
+type ExternalObject struct {
+	Kind     string
+	Name     string
+	Optional bool
+	Roles    []string
+}
+
+// ExternalObjects lists the objects mounted by the roles, by kind and name
+func ExternalObjects(roles Roles) []*ExternalObject {
+	var result []*ExternalObject
+	objects := map[string]*ExternalObject{}
+	for _, role := range roles {
+		if role.Run == nil {
+			continue
+		}
+		for _, mount := range role.Run.ExternalMounts {
+			key := mount.String()
+			object, ok := objects[key]
+			if !ok {
+				object = &ExternalObject{
+					Kind:     mount.Kind(),
+					Name:     mount.ObjectName(),
+					Optional: true,
+				}
+				objects[key] = object
+				result = append(result, object)
+			}
+			object.Optional = object.Optional && mount.Optional
+			if len(object.Roles) == 0 || object.Roles[len(object.Roles)-1] != role.Name {
+				object.Roles = append(object.Roles, role.Name)
+			}
+		}
+	}
+
+	sort.Sort(externalObjectsByName(result))
+	return result
+}
+
+// externalObjectsByName sorts external objects by kind, then name
+type externalObjectsByName []*ExternalObject
+
+func (o externalObjectsByName) Len() int      { return len(o) }
+func (o externalObjectsByName) Swap(i, j int) { o[i], o[j] = o[j], o[i] }
+func (o externalObjectsByName) Less(i, j int) bool {
+	if o[i].Kind != o[j].Kind {
+		return o[i].Kind < o[j].Kind
+	}
+	return o[i].Name < o[j].Name
+}
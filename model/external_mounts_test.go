@@ -0,0 +1,107 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateExternalMounts(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc   string
+		mounts []*RoleRunExternalMount
+		err    string
+	}{
+		{
+			desc: "No external mounts are valid",
+		},
+		{
+			desc: "ConfigMaps and Secrets are valid",
+			mounts: []*RoleRunExternalMount{
+				{ConfigMap: "corporate-ca", Path: "/etc/ssl/corporate"},
+				{Secret: "ldap.credentials", Path: "/etc/ldap", Optional: true},
+			},
+		},
+		{
+			desc:   "External mounts need an object",
+			mounts: []*RoleRunExternalMount{{Path: "/etc/ssl/corporate"}},
+			err:    "External mount should have exactly one of config-map or secret",
+		},
+		{
+			desc:   "External mounts can't have both a ConfigMap and a Secret",
+			mounts: []*RoleRunExternalMount{{ConfigMap: "ca", Secret: "ca", Path: "/etc/ca"}},
+			err:    "External mount should have exactly one of config-map or secret",
+		},
+		{
+			desc:   "Object names are DNS subdomains",
+			mounts: []*RoleRunExternalMount{{ConfigMap: "Corporate_CA", Path: "/etc/ca"}},
+			err:    "Invalid name for external mount configmap Corporate_CA, expected a DNS subdomain",
+		},
+		{
+			desc:   "Paths are absolute",
+			mounts: []*RoleRunExternalMount{{Secret: "ca", Path: "etc/ca"}},
+			err:    "External mount secret ca has an invalid path 'etc/ca', expected an absolute path",
+		},
+		{
+			desc:   "Paths are clean",
+			mounts: []*RoleRunExternalMount{{Secret: "ca", Path: "/etc/../ca"}},
+			err:    "External mount secret ca has an invalid path '/etc/../ca', expected an absolute path",
+		},
+		{
+			desc:   "Paths are not the root",
+			mounts: []*RoleRunExternalMount{{Secret: "ca", Path: "/"}},
+			err:    "External mount secret ca can't be mounted at /",
+		},
+		{
+			desc: "Paths are not used twice",
+			mounts: []*RoleRunExternalMount{
+				{ConfigMap: "ca", Path: "/etc/ca"},
+				{Secret: "ca", Path: "/etc/ca"},
+			},
+			err: "External mount secret ca uses the path /etc/ca of external mount configmap ca",
+		},
+		{
+			desc:   "Paths are not the ones of volumes",
+			mounts: []*RoleRunExternalMount{{ConfigMap: "ca", Path: "/mnt/shared"}},
+			err:    "External mount configmap ca uses the path /mnt/shared of volume shared-volume",
+		},
+	}
+
+	for _, sample := range samples {
+		run := RoleRun{
+			SharedVolumes:  []*RoleRunVolume{{Path: "/mnt/shared", Tag: "shared-volume"}},
+			ExternalMounts: sample.mounts,
+		}
+		err := run.validateExternalMounts()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestExternalObjects(t *testing.T) {
+	assert := assert.New(t)
+
+	roles := Roles{
+		{Name: "api", Run: &RoleRun{ExternalMounts: []*RoleRunExternalMount{
+			{ConfigMap: "corporate-ca", Path: "/etc/ssl/corporate", Optional: true},
+			{Secret: "ldap", Path: "/etc/ldap"},
+		}}},
+		{Name: "setup"},
+		{Name: "router", Run: &RoleRun{ExternalMounts: []*RoleRunExternalMount{
+			{ConfigMap: "corporate-ca", Path: "/etc/ssl/corporate"},
+			{ConfigMap: "branding", Path: "/var/www/branding", Optional: true},
+			{ConfigMap: "branding", Path: "/var/www/legacy", Optional: true},
+		}}},
+	}
+
+	assert.Equal([]*ExternalObject{
+		{Kind: "configmap", Name: "branding", Optional: true, Roles: []string{"router"}},
+		{Kind: "configmap", Name: "corporate-ca", Optional: false, Roles: []string{"api", "router"}},
+		{Kind: "secret", Name: "ldap", Optional: false, Roles: []string{"api"}},
+	}, ExternalObjects(roles))
+}
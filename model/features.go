@@ -0,0 +1,53 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var featureNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// selectFeatureRoles drops the roles whose feature doesn't match the features
+// the role manifest is loaded for; a role with a feature is only part of the
+// role manifest when its feature is enabled, or, for a feature prefixed with
+// "!", when it is not
+func (m *RoleManifest) selectFeatureRoles() error {
+	enabled := enabledFeatures(m.options.Features)
+	roles := make(Roles, 0, len(m.Roles))
+	for _, role := range m.Roles {
+		if role.Feature == "" {
+			roles = append(roles, role)
+			continue
+		}
+
+		feature := strings.TrimPrefix(role.Feature, "!")
+		if !featureNamePattern.MatchString(feature) {
+			return fmt.Errorf("Role %s has an invalid feature %s", role.Name, role.Feature)
+		}
+		if isFeatureSelected(role.Feature, enabled) {
+			roles = append(roles, role)
+		}
+	}
+	m.Roles = roles
+
+	return nil
+}
+
+// enabledFeatures returns the set of the enabled features
+func enabledFeatures(features []string) map[string]bool {
+	enabled := map[string]bool{}
+	for _, feature := range features {
+		enabled[feature] = true
+	}
+	return enabled
+}
+
+// isFeatureSelected reports whether a role with the given feature is used,
+// which roles without one always are
+func isFeatureSelected(feature string, enabled map[string]bool) bool {
+	if feature == "" {
+		return true
+	}
+	return enabled[strings.TrimPrefix(feature, "!")] != strings.HasPrefix(feature, "!")
+}
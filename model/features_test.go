@@ -0,0 +1,54 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestFeatures(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/features.yml")
+	for _, sample := range []struct {
+		features []string
+		roles    []string
+	}{
+		{nil, []string{"myrole", "proxy"}},
+		{[]string{"ha"}, []string{"myrole", "ha-proxy"}},
+		{[]string{"autoscaler", "ha"}, []string{"myrole", "ha-proxy", "autoscaler"}},
+	} {
+		rolesManifest, err := LoadRoleManifestWithOptions(roleManifestPath, []*Release{release}, RoleManifestOptions{Features: sample.features})
+		if !assert.NoError(err) {
+			continue
+		}
+		var names []string
+		for _, role := range rolesManifest.Roles {
+			names = append(names, role.Name)
+		}
+		assert.Equal(sample.roles, names, "features %v", sample.features)
+	}
+}
+
+func TestSelectFeatureRolesInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	rolesManifest := &RoleManifest{
+		Roles: Roles{
+			&Role{Name: "myrole", Feature: "!"},
+		},
+	}
+	assert.EqualError(rolesManifest.selectFeatureRoles(), "Role myrole has an invalid feature !")
+}
@@ -0,0 +1,351 @@
+package model
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hpcloud/fissile/util"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FixtureSpec describes a minimal BOSH dev release to generate for tests, or
+// to reproduce bugs with small cases
+type FixtureSpec struct {
+	Name     string            `yaml:"name"`
+	Version  string            `yaml:"version"`
+	License  string            `yaml:"license"`
+	Packages []*FixturePackage `yaml:"packages"`
+	Jobs     []*FixtureJob     `yaml:"jobs"`
+}
+
+// FixturePackage describes a package of a generated release. Files maps
+// paths in the package archive to their contents.
+type FixturePackage struct {
+	Name         string            `yaml:"name"`
+	Fingerprint  string            `yaml:"fingerprint"`
+	Dependencies []string          `yaml:"dependencies"`
+	Packaging    string            `yaml:"packaging"`
+	Files        map[string]string `yaml:"files"`
+}
+
+// FixtureJob describes a job of a generated release. Templates maps template
+// sources to their destination and Properties is copied verbatim into the spec.
+type FixtureJob struct {
+	Name        string                 `yaml:"name"`
+	Fingerprint string                 `yaml:"fingerprint"`
+	Packages    []string               `yaml:"packages"`
+	Templates   []*FixtureTemplate     `yaml:"templates"`
+	Properties  map[string]interface{} `yaml:"properties"`
+	Monit       string                 `yaml:"monit"`
+}
+
+// FixtureTemplate is a template of a job in a generated release
+type FixtureTemplate struct {
+	Source      string `yaml:"source"`
+	Destination string `yaml:"destination"`
+	Content     string `yaml:"content"`
+}
+
+// DefaultFixtureVersion is the release version used when a fixture spec has none
+const DefaultFixtureVersion = "0+dev.1"
+
+// fixtureModTime is the time stamped on all archive entries, so that the
+// archives (and thus their SHA1s) only depend on the spec
+var fixtureModTime = time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// LoadFixtureSpec reads a fixture spec from a YAML file
+func LoadFixtureSpec(specPath string) (*FixtureSpec, error) {
+	specContents, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec FixtureSpec
+	if err := yaml.Unmarshal(specContents, &spec); err != nil {
+		return nil, fmt.Errorf("Error loading fixture spec %s: %s", specPath, err.Error())
+	}
+
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("Error loading fixture spec %s: %s", specPath, err.Error())
+	}
+
+	return &spec, nil
+}
+
+func (s *FixtureSpec) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("Release name is missing")
+	}
+
+	packages := map[string]bool{}
+	for _, pkg := range s.Packages {
+		if pkg.Name == "" {
+			return fmt.Errorf("Package without a name")
+		}
+		if packages[pkg.Name] {
+			return fmt.Errorf("Package %s is defined more than once", pkg.Name)
+		}
+		packages[pkg.Name] = true
+	}
+	for _, pkg := range s.Packages {
+		for _, dep := range pkg.Dependencies {
+			if !packages[dep] {
+				return fmt.Errorf("Package %s depends on unknown package %s", pkg.Name, dep)
+			}
+		}
+	}
+
+	jobs := map[string]bool{}
+	for _, job := range s.Jobs {
+		if job.Name == "" {
+			return fmt.Errorf("Job without a name")
+		}
+		if jobs[job.Name] {
+			return fmt.Errorf("Job %s is defined more than once", job.Name)
+		}
+		jobs[job.Name] = true
+		for _, pkg := range job.Packages {
+			if !packages[pkg] {
+				return fmt.Errorf("Job %s uses unknown package %s", job.Name, pkg)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Generate writes the dev release described by the spec into releaseDir. The
+// job and package archives are written to the bosh-cache directory inside of
+// it; use it as the cache directory when loading the release.
+func (s *FixtureSpec) Generate(releaseDir string) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	version := s.Version
+	if version == "" {
+		version = DefaultFixtureVersion
+	}
+
+	cacheDir := filepath.Join(releaseDir, "bosh-cache")
+	manifestsDir := filepath.Join(releaseDir, "dev_releases", s.Name)
+	for _, dir := range []string{cacheDir, manifestsDir, filepath.Join(releaseDir, "config")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	var packages []map[string]interface{}
+	for _, pkg := range s.Packages {
+		info, err := s.generatePackage(pkg, releaseDir, cacheDir)
+		if err != nil {
+			return fmt.Errorf("Error generating package %s: %s", pkg.Name, err.Error())
+		}
+		packages = append(packages, info)
+	}
+
+	var jobs []map[string]interface{}
+	for _, job := range s.Jobs {
+		info, err := s.generateJob(job, releaseDir, cacheDir)
+		if err != nil {
+			return fmt.Errorf("Error generating job %s: %s", job.Name, err.Error())
+		}
+		jobs = append(jobs, info)
+	}
+
+	if s.License != "" {
+		if err := ioutil.WriteFile(filepath.Join(releaseDir, "LICENSE"), []byte(s.License), 0644); err != nil {
+			return err
+		}
+	}
+
+	files := []struct {
+		path    string
+		content interface{}
+	}{
+		{filepath.Join(releaseDir, "config", "dev.yml"), map[string]string{"dev_name": s.Name}},
+		{filepath.Join(releaseDir, "config", "final.yml"), map[string]string{"final_name": s.Name}},
+		{filepath.Join(manifestsDir, "index.yml"), map[string]interface{}{
+			"builds": map[string]interface{}{
+				fixtureFingerprint(s.Name, version): map[string]string{"version": version},
+			},
+			"format-version": "2",
+		}},
+		{filepath.Join(manifestsDir, fmt.Sprintf("%s-%s.yml", s.Name, version)), map[string]interface{}{
+			"name":                s.Name,
+			"version":             version,
+			"commit_hash":         "00000000",
+			"uncommitted_changes": false,
+			"packages":            packages,
+			"jobs":                jobs,
+		}},
+	}
+	for _, file := range files {
+		if err := writeFixtureYAML(file.path, file.content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *FixtureSpec) generatePackage(pkg *FixturePackage, releaseDir, cacheDir string) (map[string]interface{}, error) {
+	dependencies := pkg.Dependencies
+	if dependencies == nil {
+		dependencies = []string{}
+	}
+
+	packageDir := filepath.Join(releaseDir, packagesDir, pkg.Name)
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := writeFixtureYAML(filepath.Join(packageDir, "spec"), map[string]interface{}{
+		"name":         pkg.Name,
+		"dependencies": dependencies,
+	}); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(packageDir, "packaging"), []byte(pkg.Packaging), 0644); err != nil {
+		return nil, err
+	}
+
+	contents := map[string][]byte{"packaging": []byte(pkg.Packaging)}
+	for name, content := range pkg.Files {
+		contents[name] = []byte(content)
+	}
+
+	sha1sum, err := writeFixtureArchive(cacheDir, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := pkg.Fingerprint
+	if fingerprint == "" {
+		fingerprint = fixtureFingerprint(pkg.Name, sha1sum)
+	}
+
+	return map[string]interface{}{
+		"name":         pkg.Name,
+		"version":      fingerprint,
+		"fingerprint":  fingerprint,
+		"sha1":         sha1sum,
+		"dependencies": dependencies,
+	}, nil
+}
+
+func (s *FixtureSpec) generateJob(job *FixtureJob, releaseDir, cacheDir string) (map[string]interface{}, error) {
+	templates := map[string]string{}
+	contents := map[string][]byte{"monit": []byte(job.Monit)}
+	for _, template := range job.Templates {
+		templates[template.Source] = template.Destination
+		contents[filepath.Join("templates", template.Source)] = []byte(template.Content)
+	}
+
+	jobSpec := map[string]interface{}{
+		"name":      job.Name,
+		"templates": templates,
+		"packages":  job.Packages,
+	}
+	if job.Properties != nil {
+		jobSpec["properties"] = job.Properties
+	}
+	jobSpecContents, err := yaml.Marshal(jobSpec)
+	if err != nil {
+		return nil, err
+	}
+	contents["job.MF"] = jobSpecContents
+
+	jobDir := filepath.Join(releaseDir, jobsDir, job.Name)
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(jobDir, "spec"), jobSpecContents, 0644); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(jobDir, "monit"), []byte(job.Monit), 0644); err != nil {
+		return nil, err
+	}
+
+	sha1sum, err := writeFixtureArchive(cacheDir, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := job.Fingerprint
+	if fingerprint == "" {
+		fingerprint = fixtureFingerprint(job.Name, sha1sum)
+	}
+
+	return map[string]interface{}{
+		"name":        job.Name,
+		"version":     fingerprint,
+		"fingerprint": fingerprint,
+		"sha1":        sha1sum,
+	}, nil
+}
+
+// writeFixtureArchive writes a tgz with the given contents into the cache
+// directory, named by its SHA1 like BOSH does, and returns the SHA1
+func writeFixtureArchive(cacheDir string, contents map[string][]byte) (string, error) {
+	var names []string
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range names {
+		header := tar.Header{
+			Name:    "./" + filepath.ToSlash(name),
+			ModTime: fixtureModTime,
+		}
+		if err := util.WriteToTarStream(tarWriter, contents[name], header); err != nil {
+			return "", err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return "", err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return "", err
+	}
+
+	hash := sha1.Sum(archive.Bytes())
+	sha1sum := hex.EncodeToString(hash[:])
+
+	if err := ioutil.WriteFile(filepath.Join(cacheDir, sha1sum), archive.Bytes(), 0644); err != nil {
+		return "", err
+	}
+
+	return sha1sum, nil
+}
+
+func writeFixtureYAML(path string, content interface{}) error {
+	data, err := yaml.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append([]byte("---\n"), data...), 0644)
+}
+
+// fixtureFingerprint derives a stable fingerprint from the given parts
+func fixtureFingerprint(parts ...string) string {
+	hash := sha1.New()
+	for _, part := range parts {
+		fmt.Fprintf(hash, "%s\x00", part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtureGenerate(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	spec, err := LoadFixtureSpec(filepath.Join(workDir, "../test-assets/fixtures/minimal.yml"))
+	if !assert.NoError(err) {
+		return
+	}
+
+	releaseDir, err := ioutil.TempDir("", "fissile-fixture-test")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(releaseDir)
+
+	if !assert.NoError(spec.Generate(releaseDir)) {
+		return
+	}
+
+	release, err := NewDevRelease(releaseDir, "", "", filepath.Join(releaseDir, "bosh-cache"))
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal("minimal", release.Name)
+	assert.Equal("1+dev.2", release.Version)
+	assert.Contains(string(release.License.Files["LICENSE"]), "minimal release")
+
+	if assert.Len(release.Packages, 2) {
+		assert.Equal("1111111111111111111111111111111111111111", release.Packages[0].Fingerprint)
+		assert.NoError(release.Packages[0].ValidateSHA1())
+		if assert.Len(release.Packages[1].Dependencies, 1) {
+			assert.Equal("libtiny", release.Packages[1].Dependencies[0].Name)
+		}
+	}
+
+	if assert.Len(release.Jobs, 1) {
+		job := release.Jobs[0]
+		assert.NoError(job.ValidateSHA1())
+		if assert.Len(job.Packages, 1) {
+			assert.Equal("tiny", job.Packages[0].Name)
+		}
+		if assert.Len(job.Templates, 1) {
+			assert.Equal("bin/ctl", job.Templates[0].DestinationPath)
+			assert.Contains(job.Templates[0].Content, `p("tiny.port")`)
+		}
+		if assert.Len(job.Properties, 1) {
+			assert.Equal(8080, job.Properties[0].Default)
+		}
+	}
+
+	// Generating again gives the same archives
+	otherDir, err := ioutil.TempDir("", "fissile-fixture-test")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(otherDir)
+
+	assert.NoError(spec.Generate(otherDir))
+	other, err := NewDevRelease(otherDir, "", "", filepath.Join(otherDir, "bosh-cache"))
+	if assert.NoError(err) {
+		assert.Equal(release.Jobs[0].SHA1, other.Jobs[0].SHA1)
+		assert.Equal(release.Packages[1].Fingerprint, other.Packages[1].Fingerprint)
+	}
+}
+
+func TestFixtureSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &FixtureSpec{
+		Name: "broken",
+		Packages: []*FixturePackage{
+			{Name: "foo", Dependencies: []string{"bar"}},
+		},
+	}
+	err := spec.Generate("")
+	assert.EqualError(err, "Package foo depends on unknown package bar")
+
+	spec = &FixtureSpec{
+		Name: "broken",
+		Jobs: []*FixtureJob{
+			{Name: "foo", Packages: []string{"bar"}},
+		},
+	}
+	err = spec.Generate("")
+	assert.EqualError(err, "Job foo uses unknown package bar")
+}
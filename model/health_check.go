@@ -0,0 +1,56 @@
+package model
+
+import (
+	"fmt"
+)
+
+// validate checks that the health check, and its liveness check, each use
+// exactly one way of checking, with timings that aren't negative. The
+// readiness check may be left out when there is a liveness check.
+func (c *HealthCheck) validate(roleName string) error {
+	if c.hasCheck() || c.Liveness == nil {
+		if err := c.validateCheck(); err != nil {
+			return fmt.Errorf("Health check for role %s %s", roleName, err.Error())
+		}
+	}
+
+	if c.Liveness != nil {
+		if c.Liveness.Liveness != nil {
+			return fmt.Errorf("Liveness check for role %s can't have a liveness check of its own", roleName)
+		}
+		if err := c.Liveness.validateCheck(); err != nil {
+			return fmt.Errorf("Liveness check for role %s %s", roleName, err.Error())
+		}
+	}
+	return nil
+}
+
+// hasCheck reports whether the health check checks anything itself, as
+// opposed to only having a liveness check
+func (c *HealthCheck) hasCheck() bool {
+	return c.URL != "" || len(c.Command) > 0 || c.Port != 0
+}
+
+// validateCheck checks the check itself, ignoring its liveness check
+func (c *HealthCheck) validateCheck() error {
+	checks := make([]string, 0, 3)
+	if c.URL != "" {
+		checks = append(checks, "url")
+	}
+	if len(c.Command) > 0 {
+		checks = append(checks, "command")
+	}
+	if c.Port != 0 {
+		checks = append(checks, "port")
+	}
+	if len(checks) != 1 {
+		return fmt.Errorf("should have exactly one of url, command, or port; got %v", checks)
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("has port %d out of range", c.Port)
+	}
+	if c.InitialDelay < 0 || c.Timeout < 0 {
+		return fmt.Errorf("should not have a negative initial-delay or timeout")
+	}
+	return nil
+}
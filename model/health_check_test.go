@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthCheckValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc  string
+		check *HealthCheck
+		err   string
+	}{
+		{
+			desc:  "A readiness check",
+			check: &HealthCheck{URL: "http://container-ip:8080/ready", InitialDelay: 10, Timeout: 2},
+		},
+		{
+			desc:  "Readiness and liveness checks",
+			check: &HealthCheck{Port: 8080, Liveness: &HealthCheck{Command: []string{"true"}, InitialDelay: 600}},
+		},
+		{
+			desc:  "Only a liveness check",
+			check: &HealthCheck{Liveness: &HealthCheck{Port: 8080}},
+		},
+		{
+			desc:  "Checks need a way of checking",
+			check: &HealthCheck{},
+			err:   "Health check for role myrole should have exactly one of url, command, or port; got []",
+		},
+		{
+			desc:  "Checks have a single way of checking",
+			check: &HealthCheck{Port: 8080, Liveness: &HealthCheck{Port: 8080, Command: []string{"true"}}},
+			err:   "Liveness check for role myrole should have exactly one of url, command, or port; got [command port]",
+		},
+		{
+			desc:  "Ports are in range",
+			check: &HealthCheck{Port: 70000},
+			err:   "Health check for role myrole has port 70000 out of range",
+		},
+		{
+			desc:  "Timings are not negative",
+			check: &HealthCheck{Liveness: &HealthCheck{Port: 8080, Timeout: -1}},
+			err:   "Liveness check for role myrole should not have a negative initial-delay or timeout",
+		},
+		{
+			desc:  "Liveness checks don't nest",
+			check: &HealthCheck{Liveness: &HealthCheck{Port: 8080, Liveness: &HealthCheck{Port: 8080}}},
+			err:   "Liveness check for role myrole can't have a liveness check of its own",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.check.validate("myrole")
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
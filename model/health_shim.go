@@ -0,0 +1,59 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	// HealthShimDefaultPort is the port the health shim listens on when the
+	// role manifest does not set one
+	HealthShimDefaultPort = 8099
+	// HealthShimPath is the path the health shim answers on
+	HealthShimPath = "/healthz"
+)
+
+// RoleRunHealthShim adds a small HTTP server to the image of a role, which
+// reports the status monit has for the processes of the role on
+// HealthShimPath. Roles without an HTTP health endpoint of their own get
+// meaningful readiness probes this way.
+type RoleRunHealthShim struct {
+	Port int32 `yaml:"port"` // HealthShimDefaultPort when not set
+}
+
+// HealthShimPort returns the port of the health shim of the role, or 0 if it
+// has none
+func (r *Role) HealthShimPort() int32 {
+	if r.Run == nil || r.Run.HealthShim == nil {
+		return 0
+	}
+	if r.Run.HealthShim.Port == 0 {
+		return HealthShimDefaultPort
+	}
+	return r.Run.HealthShim.Port
+}
+
+// validateHealthShim checks that the health shim of a role can be used; it
+// needs monit, replaces the readiness probe of an explicit health check,
+// though not its liveness check, and listens on a port no exposed port uses
+func (r *Role) validateHealthShim() error {
+	if r.Run == nil || r.Run.HealthShim == nil {
+		return nil
+	}
+	if r.Type != RoleTypeBosh {
+		return fmt.Errorf("Role %s has a health shim, but is not of type %s", r.Name, RoleTypeBosh)
+	}
+	if r.Run.HealthCheck != nil && r.Run.HealthCheck.hasCheck() {
+		return fmt.Errorf("Role %s has both a health shim and a health check", r.Name)
+	}
+	if port := r.Run.HealthShim.Port; port < 0 || port > 65535 {
+		return fmt.Errorf("Role %s: health shim port %d is out of range", r.Name, port)
+	}
+	shimPort := portRange{int(r.HealthShimPort()), int(r.HealthShimPort())}
+	for _, port := range r.Run.ExposedPorts {
+		// Invalid ports are reported by validateExposedPorts
+		if ports, err := parsePortRange(port.Internal, port.Name, "internal"); err == nil && portProtocol(port) == "TCP" && ports.overlaps(shimPort) {
+			return fmt.Errorf("Role %s: health shim port %d is an internal port of port %s too", r.Name, shimPort.min, port.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthShimPort(t *testing.T) {
+	assert := assert.New(t)
+
+	role := Role{Name: "api", Type: RoleTypeBosh}
+	assert.Equal(int32(0), role.HealthShimPort())
+
+	role.Run = &RoleRun{HealthShim: &RoleRunHealthShim{}}
+	assert.Equal(int32(HealthShimDefaultPort), role.HealthShimPort())
+
+	role.Run.HealthShim.Port = 9000
+	assert.Equal(int32(9000), role.HealthShimPort())
+}
+
+func TestValidateHealthShim(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		role Role
+		err  string
+	}{
+		{
+			desc: "Roles without a health shim are valid",
+			role: Role{Name: "api", Type: RoleTypeDocker, Run: &RoleRun{}},
+		},
+		{
+			desc: "Bosh roles can have a health shim",
+			role: Role{Name: "api", Type: RoleTypeBosh, Run: &RoleRun{HealthShim: &RoleRunHealthShim{}}},
+		},
+		{
+			desc: "The health shim needs monit",
+			role: Role{Name: "api", Type: RoleTypeDocker, Run: &RoleRun{HealthShim: &RoleRunHealthShim{}}},
+			err:  "Role api has a health shim, but is not of type bosh",
+		},
+		{
+			desc: "The health shim replaces a health check",
+			role: Role{Name: "api", Type: RoleTypeBosh, Run: &RoleRun{
+				HealthShim:  &RoleRunHealthShim{},
+				HealthCheck: &HealthCheck{Port: 80},
+			}},
+			err: "Role api has both a health shim and a health check",
+		},
+		{
+			desc: "The port must be valid",
+			role: Role{Name: "api", Type: RoleTypeBosh, Run: &RoleRun{HealthShim: &RoleRunHealthShim{Port: 70000}}},
+			err:  "Role api: health shim port 70000 is out of range",
+		},
+		{
+			desc: "The port isn't an exposed port of the role",
+			role: Role{Name: "api", Type: RoleTypeBosh, Run: &RoleRun{
+				HealthShim:   &RoleRunHealthShim{},
+				ExposedPorts: []*RoleRunExposedPort{{Name: "metrics", Internal: "8090-8100"}},
+			}},
+			err: "Role api: health shim port 8099 is an internal port of port metrics too",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.role.validateHealthShim()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
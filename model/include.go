@@ -0,0 +1,149 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// loadIncludes merges the files listed in the include section of the role
+// manifest into it. Entries are paths or globs relative to the manifest;
+// included files have the same layout as the manifest, and a schema version
+// of their own. Their roles, configuration variables, templates, variables,
+// bundles and addons are appended in the order of the entries, and of the
+// files matching each glob. Relative paths in included files, e.g. of role scripts,
+// are relative to the manifest too, except for those given to file() in their
+// templates and variable defaults, which are relative to the included file.
+func (m *RoleManifest) loadIncludes() error {
+	baseDir := filepath.Dir(m.manifestFilePath)
+	manifestPath, err := filepath.Abs(m.manifestFilePath)
+	if err != nil {
+		return err
+	}
+
+	roleFiles := map[string]string{}
+	for _, role := range m.Roles {
+		roleFiles[role.Name] = m.manifestFilePath
+	}
+	variableFiles := map[string]string{}
+	templateFiles := map[string]string{}
+	if m.Configuration != nil {
+		for _, variable := range m.Configuration.Variables {
+			variableFiles[variable.Name] = m.manifestFilePath
+		}
+		for key := range m.Configuration.Templates {
+			templateFiles[key] = m.manifestFilePath
+		}
+	}
+
+	included := map[string]bool{manifestPath: true}
+	for _, pattern := range m.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid include %s: %s", pattern, err.Error())
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("Include %s matches no files", pattern)
+		}
+
+		for _, path := range paths {
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			if included[absPath] {
+				continue
+			}
+			included[absPath] = true
+
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if contents, err = migrateManifestSchema(path, contents); err != nil {
+				return err
+			}
+			var include RoleManifest
+			if err := yaml.Unmarshal(contents, &include); err != nil {
+				return fmt.Errorf("Error reading included file %s: %s", path, err.Error())
+			}
+			if err := validateManifestSchema(path, contents, baseDir, m.options.Features); err != nil {
+				return err
+			}
+			if len(include.Include) > 0 {
+				return fmt.Errorf("Included file %s can't include other files", path)
+			}
+			if include.CABundle != "" {
+				return fmt.Errorf("Included file %s can't set the ca-bundle, only the role manifest can", path)
+			}
+			if len(include.Environments) > 0 {
+				return fmt.Errorf("Included file %s can't set environments, only the role manifest can", path)
+			}
+
+			includeDir := filepath.Dir(path)
+			include.Configuration.setSourceDir(includeDir)
+			for _, role := range include.Roles {
+				if other, ok := roleFiles[role.Name]; ok {
+					return fmt.Errorf("Role %s is defined in both %s and %s", role.Name, other, path)
+				}
+				roleFiles[role.Name] = path
+				role.Configuration.setSourceDir(includeDir)
+				for _, roleJob := range role.JobNameList {
+					roleJob.Configuration.setSourceDir(includeDir)
+				}
+			}
+			for _, addon := range include.Addons {
+				for _, addonJob := range addon.Jobs {
+					addonJob.Configuration.setSourceDir(includeDir)
+				}
+			}
+			m.Roles = append(m.Roles, include.Roles...)
+			m.Bundles = append(m.Bundles, include.Bundles...)
+			m.Variables = append(m.Variables, include.Variables...)
+			m.Addons = append(m.Addons, include.Addons...)
+
+			if include.Configuration != nil {
+				if m.Configuration == nil {
+					m.Configuration = &Configuration{}
+				}
+				for _, variable := range include.Configuration.Variables {
+					if other, ok := variableFiles[variable.Name]; ok {
+						return fmt.Errorf("Variable %s is defined in both %s and %s", variable.Name, other, path)
+					}
+					variableFiles[variable.Name] = path
+				}
+				m.Configuration.Variables = append(m.Configuration.Variables, include.Configuration.Variables...)
+
+				if len(include.Configuration.Templates) > 0 && m.Configuration.Templates == nil {
+					m.Configuration.Templates = map[string]string{}
+				}
+				for key, template := range include.Configuration.Templates {
+					if other, ok := templateFiles[key]; ok {
+						return fmt.Errorf("Template %s is defined in both %s and %s", key, other, path)
+					}
+					templateFiles[key] = path
+					m.Configuration.Templates[key] = template
+					if m.Configuration.templateDirs == nil {
+						m.Configuration.templateDirs = map[string]string{}
+					}
+					m.Configuration.templateDirs[key] = includeDir
+				}
+			}
+
+			m.includedFiles = append(m.includedFiles, path)
+		}
+	}
+
+	return nil
+}
+
+// IncludedFiles returns the paths of the files merged into the role manifest
+// by its include section, and of the overlay of the selected environment
+func (m *RoleManifest) IncludedFiles() []string {
+	return append([]string{}, m.includedFiles...)
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestIncludes(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	includeDir := filepath.Join(workDir, "../test-assets/role-manifests/include")
+	rolesManifest, err := LoadRoleManifest(filepath.Join(includeDir, "main.yml"), []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	var names []string
+	for _, role := range rolesManifest.Roles {
+		names = append(names, role.Name)
+	}
+	assert.Equal([]string{"myrole", "errand", "other"}, names)
+	assert.Equal("((BAR))", rolesManifest.Configuration.Templates["properties.tor.private_key"])
+	assert.Equal("client-keys", rolesManifest.Configuration.Templates["properties.tor.client_keys"], "file() is relative to the included file")
+	if other := rolesManifest.LookupRole("other"); assert.NotNil(other) {
+		assert.Equal("Y2xpZW50LWtleXM=", other.Configuration.Templates["properties.tor.hashed_control_password"])
+	}
+	if assert.Len(rolesManifest.Configuration.Variables, 2) {
+		assert.Equal("BAR", rolesManifest.Configuration.Variables[1].Name)
+	}
+	assert.Equal([]string{
+		filepath.Join(includeDir, "roles/a-errand.yml"),
+		filepath.Join(includeDir, "roles/b-other.yml"),
+		filepath.Join(includeDir, "variables.yml"),
+	}, rolesManifest.IncludedFiles())
+}
+
+func TestLoadRoleManifestIncludesInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc     string
+		manifest string
+		included string
+		err      string
+	}{
+		{
+			desc:     "Includes match files",
+			manifest: "include: [missing/*.yml]",
+			err:      "Include %s/missing/*.yml matches no files",
+		},
+		{
+			desc:     "Role names are unique",
+			manifest: "include: [included.yml]\nroles: [{name: myrole, jobs: []}]",
+			included: "roles: [{name: myrole, jobs: []}]",
+			err:      "Role myrole is defined in both %s/main.yml and %s/included.yml",
+		},
+		{
+			desc:     "Variable names are unique",
+			manifest: "include: [included.yml]\nconfiguration: {variables: [{name: FOO}]}",
+			included: "configuration: {variables: [{name: FOO}]}",
+			err:      "Variable FOO is defined in both %s/main.yml and %s/included.yml",
+		},
+		{
+			desc:     "Templates are unique",
+			manifest: "include: [included.yml]\nconfiguration: {templates: {properties.a: x}}",
+			included: "configuration: {templates: {properties.a: y}}",
+			err:      "Template properties.a is defined in both %s/main.yml and %s/included.yml",
+		},
+		{
+			desc:     "Includes don't nest",
+			manifest: "include: [included.yml]",
+			included: "include: [other.yml]",
+			err:      "Included file %s/included.yml can't include other files",
+		},
+	}
+
+	for _, sample := range samples {
+		dir, err := ioutil.TempDir("", "fissile-include-")
+		if !assert.NoError(err) {
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		manifestPath := filepath.Join(dir, "main.yml")
+		assert.NoError(ioutil.WriteFile(manifestPath, []byte(sample.manifest), 0644))
+		if sample.included != "" {
+			assert.NoError(ioutil.WriteFile(filepath.Join(dir, "included.yml"), []byte(sample.included), 0644))
+		}
+
+		_, err = LoadRoleManifest(manifestPath, nil)
+		assert.EqualError(err, strings.Replace(sample.err, "%s", dir, -1), sample.desc)
+	}
+}
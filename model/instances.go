@@ -0,0 +1,41 @@
+package model
+
+import "fmt"
+
+// validateInstances checks the number of instances of a role against its
+// scaling limits
+func (r *RoleRun) validateInstances() error {
+	if r.Instances < 0 {
+		return fmt.Errorf("Instances should not be negative, got %d", r.Instances)
+	}
+	if r.Instances == 0 || r.Scaling == nil {
+		return nil
+	}
+	if r.Instances < r.Scaling.Min || r.Instances > r.Scaling.Max {
+		return fmt.Errorf("Instances should be between the scaling min %d and max %d, got %d", r.Scaling.Min, r.Scaling.Max, r.Instances)
+	}
+	return nil
+}
+
+// Replicas returns the number of instances of the role to run: the instances
+// in its run section, or else the minimum of its scaling, or else one
+func (r *Role) Replicas() int32 {
+	switch {
+	case r.Run == nil:
+		return 1
+	case r.Run.Instances > 0:
+		return r.Run.Instances
+	case r.Run.Scaling != nil:
+		return r.Run.Scaling.Min
+	}
+	return 1
+}
+
+// scalingRange returns the scaling limits of the role; roles with instances
+// but without scaling always run that many instances
+func (r *RoleRun) scalingRange() *RoleRunScaling {
+	if r.Scaling == nil && r.Instances > 0 {
+		return &RoleRunScaling{Min: r.Instances, Max: r.Instances}
+	}
+	return r.Scaling
+}
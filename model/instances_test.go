@@ -0,0 +1,58 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateInstances(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		run  RoleRun
+		err  string
+	}{
+		{
+			desc: "Roles without instances are valid",
+		},
+		{
+			desc: "Instances without scaling are valid",
+			run:  RoleRun{Instances: 3},
+		},
+		{
+			desc: "Instances within the scaling limits are valid",
+			run:  RoleRun{Instances: 2, Scaling: &RoleRunScaling{Min: 1, Max: 3}},
+		},
+		{
+			desc: "Instances are not negative",
+			run:  RoleRun{Instances: -1},
+			err:  "Instances should not be negative, got -1",
+		},
+		{
+			desc: "Instances are within the scaling limits",
+			run:  RoleRun{Instances: 4, Scaling: &RoleRunScaling{Min: 1, Max: 3}},
+			err:  "Instances should be between the scaling min 1 and max 3, got 4",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.run.validateInstances()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestRoleReplicas(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(int32(1), (&Role{}).Replicas())
+	assert.Equal(int32(1), (&Role{Run: &RoleRun{}}).Replicas())
+	assert.Equal(int32(2), (&Role{Run: &RoleRun{Scaling: &RoleRunScaling{Min: 2, Max: 4}}}).Replicas())
+	assert.Equal(int32(3), (&Role{Run: &RoleRun{Instances: 3, Scaling: &RoleRunScaling{Min: 2, Max: 4}}}).Replicas())
+	assert.Equal(int32(5), (&Role{Run: &RoleRun{Instances: 5}}).Replicas())
+}
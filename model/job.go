@@ -11,6 +11,8 @@ import (
 	"reflect"
 	"sort"
 
+	"github.com/hpcloud/fissile/util"
+
 	"github.com/pivotal-golang/archiver/extractor"
 	"gopkg.in/yaml.v2"
 )
@@ -46,6 +48,10 @@ func newJob(release *Release, jobReleaseInfo map[interface{}]interface{}) (*Job,
 		return nil, err
 	}
 
+	if err := fetchMirroredArchive(release.options.ArchiveMirrors, job.Path, job.SHA1); err != nil {
+		return nil, fmt.Errorf("Job %s: %s", job.Name, err.Error())
+	}
+
 	if err := job.loadJobSpec(); err != nil {
 		return nil, err
 	}
@@ -128,7 +134,7 @@ func (j *Job) loadJobSpec() (err error) {
 		}
 	}()
 
-	tempJobDir, err := ioutil.TempDir("", "fissile-job-dir")
+	tempJobDir, err := util.TempDir(j.Release.options.ScratchDir, "fissile-job-dir")
 	defer func() {
 		if cleanupErr := os.RemoveAll(tempJobDir); cleanupErr != nil && err != nil {
 			err = fmt.Errorf("Error loading job spec: %v,  cleanup error: %v", err, cleanupErr)
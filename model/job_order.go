@@ -0,0 +1,99 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PreStopScriptPath is where the script stopping the jobs of a role in order
+// is in the images; it is the preStop hook of the containers, and run.sh runs
+// it on SIGTERM
+const PreStopScriptPath = "/opt/hcf/pre-stop.sh"
+
+// HasJobDependencies reports whether jobs of the role depend on other jobs of
+// the role, so they have to be started and stopped in order
+func (r *Role) HasJobDependencies() bool {
+	for _, roleJob := range r.JobNameList {
+		if len(roleJob.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// JobShutdownOrder returns the jobs of the role in the order they are
+// stopped: every job is stopped before the jobs it depends on, so that the
+// consumers are gone before their providers. Independent jobs are stopped in
+// the reverse order of the role manifest, as BOSH does.
+func (r *Role) JobShutdownOrder() (Jobs, error) {
+	jobsByName := make(map[string]*Job, len(r.Jobs))
+	for _, job := range r.Jobs {
+		jobsByName[job.Name] = job
+	}
+
+	// The jobs depending on every job, which have to be stopped first
+	dependents := map[string][]string{}
+	for _, roleJob := range r.JobNameList {
+		for _, dependency := range roleJob.DependsOn {
+			if _, ok := jobsByName[dependency]; !ok {
+				return nil, fmt.Errorf("Role %s: job %s depends on unknown job %s", r.Name, roleJob.Name, dependency)
+			}
+			if dependency == roleJob.Name {
+				return nil, fmt.Errorf("Role %s: job %s depends on itself", r.Name, roleJob.Name)
+			}
+			dependents[dependency] = append(dependents[dependency], roleJob.Name)
+		}
+	}
+
+	var order Jobs
+	stopped := map[string]bool{}
+	for len(order) < len(jobsByName) {
+		progress := false
+		for i := len(r.JobNameList) - 1; i >= 0; i-- {
+			name := r.JobNameList[i].Name
+			if stopped[name] {
+				continue
+			}
+			ready := true
+			for _, dependent := range dependents[name] {
+				ready = ready && stopped[dependent]
+			}
+			if ready {
+				order = append(order, jobsByName[name])
+				stopped[name] = true
+				progress = true
+			}
+		}
+
+		if !progress {
+			var names []string
+			for _, roleJob := range r.JobNameList {
+				if !stopped[roleJob.Name] {
+					names = append(names, roleJob.Name)
+				}
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("Role %s: circular dependencies between jobs %s", r.Name, strings.Join(names, ", "))
+		}
+	}
+
+	return order, nil
+}
+
+// JobStartupOrder returns the jobs of the role in the order they are started:
+// every job is started after the jobs it depends on. This is the reverse of
+// the shutdown order, so independent jobs start in the order of the role
+// manifest.
+func (r *Role) JobStartupOrder() (Jobs, error) {
+	shutdownOrder, err := r.JobShutdownOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	order := make(Jobs, 0, len(shutdownOrder))
+	for i := len(shutdownOrder) - 1; i >= 0; i-- {
+		order = append(order, shutdownOrder[i])
+	}
+	return order, nil
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleJobOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	newRole := func(dependencies map[string][]string, names ...string) *Role {
+		role := &Role{Name: "myrole"}
+		for _, name := range names {
+			role.JobNameList = append(role.JobNameList, &roleJob{Name: name, DependsOn: dependencies[name]})
+			role.Jobs = append(role.Jobs, &Job{Name: name})
+		}
+		return role
+	}
+
+	samples := []struct {
+		desc         string
+		dependencies map[string][]string
+		order        []string
+		err          string
+	}{
+		{
+			desc:  "Independent jobs stop in reverse order",
+			order: []string{"metrics", "db", "app"},
+		},
+		{
+			desc:         "Consumers stop before their providers",
+			dependencies: map[string][]string{"app": {"db"}, "metrics": {"db"}},
+			order:        []string{"metrics", "app", "db"},
+		},
+		{
+			desc:         "Dependencies are jobs of the role",
+			dependencies: map[string][]string{"app": {"cache"}},
+			err:          "Role myrole: job app depends on unknown job cache",
+		},
+		{
+			desc:         "Jobs don't depend on themselves",
+			dependencies: map[string][]string{"app": {"app"}},
+			err:          "Role myrole: job app depends on itself",
+		},
+		{
+			desc:         "Dependencies are not circular",
+			dependencies: map[string][]string{"db": {"app"}, "app": {"db"}},
+			err:          "Role myrole: circular dependencies between jobs app, db",
+		},
+	}
+
+	reversed := func(names []string) []string {
+		var result []string
+		for i := len(names) - 1; i >= 0; i-- {
+			result = append(result, names[i])
+		}
+		return result
+	}
+
+	jobNames := func(jobs Jobs) []string {
+		var names []string
+		for _, job := range jobs {
+			names = append(names, job.Name)
+		}
+		return names
+	}
+
+	for _, sample := range samples {
+		role := newRole(sample.dependencies, "app", "db", "metrics")
+		assert.Equal(len(sample.dependencies) > 0, role.HasJobDependencies(), sample.desc)
+
+		order, err := role.JobShutdownOrder()
+		if sample.err != "" {
+			assert.EqualError(err, sample.err, sample.desc)
+			continue
+		}
+		if assert.NoError(err, sample.desc) {
+			assert.Equal(sample.order, jobNames(order), sample.desc)
+		}
+
+		order, err = role.JobStartupOrder()
+		if assert.NoError(err, sample.desc) {
+			assert.Equal(reversed(sample.order), jobNames(order), sample.desc)
+		}
+	}
+}
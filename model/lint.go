@@ -0,0 +1,344 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LintSeverity is how bad breaking a lint rule is
+type LintSeverity string
+
+// These are the lint severities; rules that are off are not checked
+const (
+	LintSeverityError   = LintSeverity("error")
+	LintSeverityWarning = LintSeverity("warning")
+	LintSeverityInfo    = LintSeverity("info")
+	LintSeverityOff     = LintSeverity("off")
+)
+
+// These are the lint rules for role manifests
+const (
+	LintRuleRoleName         = "role-name"          // Role names must match a pattern
+	LintRulePortName         = "port-name"          // Exposed port names must match a pattern
+	LintRuleVariableName     = "variable-name"      // Configuration variable names must match a pattern
+	LintRuleForbiddenEnvName = "forbidden-env-name" // Variables must not clobber important environment variables
+	LintRuleReservedLabelKey = "reserved-label-key" // Node selectors must not use reserved label keys
+	LintRuleUnusedRelease    = "unused-release"     // Loaded releases must be used by a role
+	LintRuleUnusedJob        = "unused-job"         // Jobs of the used releases no role uses
+)
+
+// LintRuleConfig configures a lint rule. Pattern applies to the naming rules,
+// Names to the rules with a list of forbidden names.
+type LintRuleConfig struct {
+	Severity LintSeverity `yaml:"severity"`
+	Pattern  string       `yaml:"pattern"`
+	Names    []string     `yaml:"names"`
+}
+
+// LintConfig configures the lint rules for role manifests; rules not
+// mentioned keep their defaults
+type LintConfig struct {
+	Rules map[string]*LintRuleConfig `yaml:"rules"`
+}
+
+// LintFinding is a single rule broken by a role manifest. Fix is the name to
+// use instead, if the rename is mechanical.
+type LintFinding struct {
+	Rule     string       `json:"rule" yaml:"rule"`
+	Severity LintSeverity `json:"severity" yaml:"severity"`
+	Role     string       `json:"role,omitempty" yaml:"role,omitempty"`
+	Name     string       `json:"name" yaml:"name"`
+	Message  string       `json:"message" yaml:"message"`
+	Fix      string       `json:"fix,omitempty" yaml:"fix,omitempty"`
+}
+
+func (f *LintFinding) String() string {
+	if f.Role == "" {
+		return fmt.Sprintf("%s: %s [%s]", f.Severity, f.Message, f.Rule)
+	}
+	return fmt.Sprintf("%s: role %s: %s [%s]", f.Severity, f.Role, f.Message, f.Rule)
+}
+
+// DefaultLintConfig returns the default configuration of the lint rules
+func DefaultLintConfig() *LintConfig {
+	return &LintConfig{
+		Rules: map[string]*LintRuleConfig{
+			LintRuleRoleName: {
+				Severity: LintSeverityError,
+				Pattern:  `^[a-z]([a-z0-9-]*[a-z0-9])?$`,
+			},
+			LintRulePortName: {
+				Severity: LintSeverityWarning,
+				Pattern:  `^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`,
+			},
+			LintRuleVariableName: {
+				Severity: LintSeverityWarning,
+				Pattern:  `^[A-Z][A-Z0-9_]*$`,
+			},
+			LintRuleForbiddenEnvName: {
+				Severity: LintSeverityError,
+				Names: []string{
+					"HOME", "HOSTNAME", "KUBERNETES_NAMESPACE", "KUBERNETES_SERVICE_HOST",
+					"KUBERNETES_SERVICE_PORT", "LANG", "PATH", "PWD", "SHELL", "TERM", "TZ", "USER",
+				},
+			},
+			LintRuleReservedLabelKey: {
+				Severity: LintSeverityWarning,
+				Names: []string{
+					"kubernetes.io/", "k8s.io/", "skiff-",
+				},
+			},
+			LintRuleUnusedRelease: {
+				Severity: LintSeverityWarning,
+			},
+			LintRuleUnusedJob: {
+				Severity: LintSeverityInfo,
+			},
+		},
+	}
+}
+
+// wellKnownNodeLabels are the labels Kubernetes puts on nodes; node selectors
+// may use them despite their reserved prefixes
+var wellKnownNodeLabels = map[string]bool{
+	"kubernetes.io/hostname":                   true,
+	"beta.kubernetes.io/arch":                  true,
+	"beta.kubernetes.io/os":                    true,
+	"beta.kubernetes.io/instance-type":         true,
+	"failure-domain.beta.kubernetes.io/region": true,
+	"failure-domain.beta.kubernetes.io/zone":   true,
+}
+
+// LoadLintConfig reads a lint configuration and merges it into the defaults
+func LoadLintConfig(path string) (*LintConfig, error) {
+	config := DefaultLintConfig()
+	if path == "" {
+		return config, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides LintConfig
+	if err := yaml.Unmarshal(contents, &overrides); err != nil {
+		return nil, fmt.Errorf("Error loading lint config %s: %s", path, err.Error())
+	}
+	if err := validateFileSchema(path, contents, reflect.TypeOf(overrides)); err != nil {
+		return nil, err
+	}
+
+	for name, override := range overrides.Rules {
+		rule, ok := config.Rules[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown lint rule %s in %s", name, path)
+		}
+		if override == nil {
+			continue
+		}
+		switch override.Severity {
+		case "":
+		case LintSeverityError, LintSeverityWarning, LintSeverityInfo, LintSeverityOff:
+			rule.Severity = override.Severity
+		default:
+			return nil, fmt.Errorf("Invalid severity %s for lint rule %s", override.Severity, name)
+		}
+		if override.Pattern != "" {
+			rule.Pattern = override.Pattern
+		}
+		if override.Names != nil {
+			rule.Names = override.Names
+		}
+	}
+
+	return config, nil
+}
+
+// LintRoleManifest checks a role manifest, as patched for the environment, if
+// any, against the lint rules. Only the manifest itself is read, so this works
+// without the releases.
+func LintRoleManifest(manifestFilePath string, config *LintConfig, environment string) ([]*LintFinding, error) {
+	manifest, err := readLintedRoleManifest(manifestFilePath, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	linter := &roleManifestLinter{config: config, patterns: map[string]*regexp.Regexp{}}
+	for name, rule := range config.Rules {
+		if rule.Pattern == "" {
+			continue
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern for lint rule %s: %s", name, err.Error())
+		}
+		linter.patterns[name] = pattern
+	}
+
+	if manifest.Configuration != nil {
+		linter.lintVariables("", manifest.Configuration.Variables)
+	}
+
+	for _, role := range manifest.Roles {
+		linter.lintName(LintRuleRoleName, role.Name, role.Name, "role name", nil)
+
+		if role.Configuration != nil {
+			linter.lintVariables(role.Name, role.Configuration.Variables)
+		}
+
+		if role.Run == nil {
+			continue
+		}
+
+		for _, port := range role.Run.ExposedPorts {
+			linter.lintName(LintRulePortName, role.Name, port.Name, "port name", fixPortName)
+		}
+
+		var keys []string
+		for key := range role.Run.NodeSelector {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if wellKnownNodeLabels[key] {
+				continue
+			}
+			for _, reserved := range config.Rules[LintRuleReservedLabelKey].Names {
+				if strings.HasPrefix(key, reserved) || strings.Contains(key, "."+reserved) {
+					linter.add(LintRuleReservedLabelKey, role.Name, key, "",
+						"node selector label %s uses the reserved prefix %s", key, reserved)
+					break
+				}
+			}
+		}
+	}
+
+	return linter.findings, nil
+}
+
+// roleManifestLinter collects the findings of linting a role manifest
+type roleManifestLinter struct {
+	config   *LintConfig
+	patterns map[string]*regexp.Regexp
+	findings []*LintFinding
+}
+
+func (l *roleManifestLinter) add(rule, role, name, fix string, format string, args ...interface{}) {
+	severity := l.config.Rules[rule].Severity
+	if severity == LintSeverityOff {
+		return
+	}
+	l.findings = append(l.findings, &LintFinding{
+		Rule:     rule,
+		Severity: severity,
+		Role:     role,
+		Name:     name,
+		Message:  fmt.Sprintf(format, args...),
+		Fix:      fix,
+	})
+}
+
+// lintName checks a name against the pattern of a rule; fixer suggests a
+// replacement, and may be nil if renames are not safe
+func (l *roleManifestLinter) lintName(rule, role, name, what string, fixer func(string) string) {
+	pattern := l.patterns[rule]
+	if pattern == nil || pattern.MatchString(name) {
+		return
+	}
+
+	fix := ""
+	if fixer != nil {
+		if fixed := fixer(name); fixed != name && pattern.MatchString(fixed) {
+			fix = fixed
+		}
+	}
+
+	l.add(rule, role, name, fix, "%s %s does not match %s", what, name, pattern.String())
+}
+
+func (l *roleManifestLinter) lintVariables(role string, variables ConfigurationVariableSlice) {
+	forbidden := map[string]bool{}
+	for _, name := range l.config.Rules[LintRuleForbiddenEnvName].Names {
+		forbidden[name] = true
+	}
+
+	for _, variable := range variables {
+		if forbidden[variable.Name] {
+			l.add(LintRuleForbiddenEnvName, role, variable.Name, "",
+				"variable %s would replace an environment variable the containers need", variable.Name)
+			continue
+		}
+		// Variables are not renamed, as scripts and env files outside of the
+		// manifest use them too
+		l.lintName(LintRuleVariableName, role, variable.Name, "variable name", nil)
+	}
+}
+
+var portNameInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// fixPortName turns a port name into lower case words separated by hyphens
+func fixPortName(name string) string {
+	return strings.Trim(portNameInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// FixLintFindings applies the mechanical renames of the findings to the
+// contents of a role manifest. A name is only renamed if it is defined exactly
+// once in the manifest. The findings that could not be fixed are returned.
+func FixLintFindings(manifestContents []byte, findings []*LintFinding) ([]byte, []*LintFinding) {
+	var unfixed []*LintFinding
+
+	for _, finding := range findings {
+		if finding.Fix == "" {
+			unfixed = append(unfixed, finding)
+			continue
+		}
+
+		definition := regexp.MustCompile(`(?m)^(\s*-?\s*name:\s*["']?)` + regexp.QuoteMeta(finding.Name) + `(["']?\s*)$`)
+		if len(definition.FindAllIndex(manifestContents, -1)) != 1 {
+			unfixed = append(unfixed, finding)
+			continue
+		}
+		manifestContents = definition.ReplaceAll(manifestContents, []byte("${1}"+finding.Fix+"${2}"))
+	}
+
+	return manifestContents, unfixed
+}
+
+// readLintedRoleManifest reads the role manifest to lint, with its included
+// files, the overlay of the environment, if any, and the roles extending
+// others resolved, but without loading it: linting needs no releases. The
+// roles of all features are kept.
+func readLintedRoleManifest(manifestFilePath, environment string) (*RoleManifest, error) {
+	manifestContents, err := ioutil.ReadFile(manifestFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if manifestContents, err = migrateManifestSchema(manifestFilePath, manifestContents); err != nil {
+		return nil, err
+	}
+
+	manifest := &RoleManifest{
+		manifestFilePath: manifestFilePath,
+		options:          RoleManifestOptions{Environment: environment},
+	}
+	if err := yaml.Unmarshal(manifestContents, manifest); err != nil {
+		return nil, err
+	}
+	if err := manifest.loadIncludes(); err != nil {
+		return nil, err
+	}
+	if err := manifest.applyEnvironment(); err != nil {
+		return nil, err
+	}
+	if err := manifest.resolveExtends(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
@@ -0,0 +1,48 @@
+package model
+
+// LintReleases checks that the releases are used by the role manifest: it
+// reports the releases no role has jobs of, which usually means a release
+// was passed by mistake or a release_name is misspelled, and the jobs of the
+// used releases no role uses. Roles of included files count, as do roles of
+// all features and addons, so the manifest is read without being loaded; see
+// readLintedRoleManifest. The environment, if any, patches the manifest.
+func LintReleases(manifestFilePath string, releases []*Release, config *LintConfig, environment string) ([]*LintFinding, error) {
+	manifest, err := readLintedRoleManifest(manifestFilePath, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	usedJobs := map[string]map[string]bool{}
+	use := func(roleJobs []*roleJob) {
+		for _, roleJob := range roleJobs {
+			if usedJobs[roleJob.ReleaseName] == nil {
+				usedJobs[roleJob.ReleaseName] = map[string]bool{}
+			}
+			usedJobs[roleJob.ReleaseName][roleJob.Name] = true
+		}
+	}
+	for _, role := range manifest.Roles {
+		use(role.JobNameList)
+	}
+	for _, addon := range manifest.Addons {
+		use(addon.Jobs)
+	}
+
+	linter := &roleManifestLinter{config: config}
+	for _, release := range releases {
+		jobs, ok := usedJobs[release.Name]
+		if !ok {
+			linter.add(LintRuleUnusedRelease, "", release.Name, "",
+				"release %s is not used by any role", release.Name)
+			continue
+		}
+		for _, job := range release.Jobs {
+			if !jobs[job.Name] {
+				linter.add(LintRuleUnusedJob, "", job.Name, "",
+					"job %s of release %s is not used by any role", job.Name, release.Name)
+			}
+		}
+	}
+
+	return linter.findings, nil
+}
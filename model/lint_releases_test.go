@@ -0,0 +1,48 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintReleases(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	var releases []*Release
+	for _, name := range []string{"tor-boshrelease", "ntp-release"} {
+		releasePath := filepath.Join(workDir, "../test-assets", name)
+		release, err := NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+		if !assert.NoError(err) {
+			return
+		}
+		releases = append(releases, release)
+	}
+
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/lint-releases.yml")
+	findings, err := LintReleases(manifestPath, releases, DefaultLintConfig(), "")
+	if !assert.NoError(err) {
+		return
+	}
+
+	var messages []string
+	for _, finding := range findings {
+		messages = append(messages, finding.String())
+	}
+	assert.Equal([]string{
+		"info: job new_hostname of release tor is not used by any role [unused-job]",
+		"warning: release ntp is not used by any role [unused-release]",
+	}, messages)
+
+	config := DefaultLintConfig()
+	config.Rules[LintRuleUnusedJob].Severity = LintSeverityOff
+	findings, err = LintReleases(manifestPath, releases, config, "")
+	if assert.NoError(err) && assert.Len(findings, 1) {
+		assert.Equal(LintRuleUnusedRelease, findings[0].Rule)
+	}
+}
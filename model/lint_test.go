@@ -0,0 +1,144 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintRoleManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/lint.yml")
+	findings, err := LintRoleManifest(manifestPath, DefaultLintConfig(), "")
+	if !assert.NoError(err) {
+		return
+	}
+
+	var messages []string
+	for _, finding := range findings {
+		messages = append(messages, finding.String())
+	}
+	assert.Equal([]string{
+		"warning: variable name cluster-admin-password does not match ^[A-Z][A-Z0-9_]*$ [variable-name]",
+		"error: role myrole: variable PATH would replace an environment variable the containers need [forbidden-env-name]",
+		"warning: role myrole: port name HTTP_Admin does not match ^[a-z0-9]([a-z0-9-]*[a-z0-9])?$ [port-name]",
+		"warning: role myrole: node selector label node-role.kubernetes.io/ml uses the reserved prefix kubernetes.io/ [reserved-label-key]",
+		"error: role Bad_Role: role name Bad_Role does not match ^[a-z]([a-z0-9-]*[a-z0-9])?$ [role-name]",
+	}, messages)
+
+	// Only port names are renamed
+	for _, finding := range findings {
+		if finding.Rule == LintRulePortName {
+			assert.Equal("http-admin", finding.Fix)
+		} else {
+			assert.Empty(finding.Fix, finding.Rule)
+		}
+	}
+}
+
+func TestLintConfigOverrides(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	configFile, err := ioutil.TempFile("", "fissile-lint-config")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.Remove(configFile.Name())
+	_, err = configFile.WriteString(`---
+rules:
+  variable-name:
+    severity: error
+  port-name:
+    severity: "off"
+  reserved-label-key:
+    severity: "off"
+  forbidden-env-name:
+    names: [HOME]
+  role-name:
+    pattern: "^[A-Za-z_]+$"
+`)
+	assert.NoError(err)
+	assert.NoError(configFile.Close())
+
+	config, err := LoadLintConfig(configFile.Name())
+	if !assert.NoError(err) {
+		return
+	}
+
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/lint.yml")
+	findings, err := LintRoleManifest(manifestPath, config, "")
+	if !assert.NoError(err) {
+		return
+	}
+
+	// PATH is allowed now, and matches the variable pattern
+	if assert.Len(findings, 1) {
+		assert.Equal(LintRuleVariableName, findings[0].Rule)
+		assert.Equal(LintSeverityError, findings[0].Severity)
+		assert.Equal("cluster-admin-password", findings[0].Name)
+	}
+
+	assert.NoError(ioutil.WriteFile(configFile.Name(), []byte("rules: {tabs: {severity: error}}"), 0644))
+	_, err = LoadLintConfig(configFile.Name())
+	assert.EqualError(err, "Unknown lint rule tabs in "+configFile.Name())
+
+	assert.NoError(ioutil.WriteFile(configFile.Name(), []byte("rules:\n  role-name:\n    severty: error\n"), 0644))
+	_, err = LoadLintConfig(configFile.Name())
+	assert.EqualError(err, configFile.Name()+":3: Unknown key rules.role-name.severty, did you mean severity?")
+}
+
+func TestFixLintFindings(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := []byte(`roles:
+- name: myrole
+  run:
+    exposed-ports:
+    - name: HTTP_Admin
+      internal: 8080
+    - name: "Dup"
+    - name: Dup
+`)
+	findings := []*LintFinding{
+		{Rule: LintRulePortName, Name: "HTTP_Admin", Fix: "http-admin"},
+		{Rule: LintRulePortName, Name: "Dup", Fix: "dup"},
+		{Rule: LintRuleRoleName, Name: "myrole"},
+	}
+
+	fixed, unfixed := FixLintFindings(contents, findings)
+	assert.Contains(string(fixed), "    - name: http-admin\n")
+	assert.Contains(string(fixed), "    - name: Dup\n", "names defined more than once are not renamed")
+	assert.Equal(findings[1:], unfixed)
+}
+
+func TestLintRoleManifestIncludes(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/lint-include/main.yml")
+	findings, err := LintRoleManifest(manifestPath, DefaultLintConfig(), "")
+	if !assert.NoError(err) {
+		return
+	}
+
+	var messages []string
+	for _, finding := range findings {
+		messages = append(messages, finding.String())
+	}
+	assert.Equal([]string{
+		"error: role Bad_Role: role name Bad_Role does not match ^[a-z]([a-z0-9-]*[a-z0-9])?$ [role-name]",
+		"warning: role Bad_Role: port name HTTP_Admin does not match ^[a-z0-9]([a-z0-9-]*[a-z0-9])?$ [port-name]",
+	}, messages, "roles of included files are linted, with what they inherit")
+}
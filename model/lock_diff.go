@@ -0,0 +1,194 @@
+package model
+
+import (
+	"sort"
+)
+
+// LockDiff is what changed between two locks, e.g. the ones of two builds
+// about to be deployed one after the other
+type LockDiff struct {
+	Releases    []*LockedReleaseDiff // Releases that changed, by name
+	OldStemcell string               // Only set, like NewStemcell, when the stemcell changed
+	NewStemcell string
+}
+
+// LockedReleaseDiff is what changed in a release between two locks. A release
+// added by the newer lock has no old version, and one it removed no new one.
+type LockedReleaseDiff struct {
+	Name          string
+	OldVersion    string
+	NewVersion    string
+	OldCommitHash string
+	NewCommitHash string
+	Packages      []*FingerprintDiff
+	Jobs          []*FingerprintDiff
+}
+
+// FingerprintDiff is a package or job whose fingerprint changed between two
+// locks; it has no old fingerprint if it was added, and no new one if it was
+// removed
+type FingerprintDiff struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// Diff returns what changed from the lock to a newer one. The stemcell is only
+// compared if both locks know it, and the jobs of a release if both locks
+// record them.
+func (l *Lock) Diff(newer *Lock) *LockDiff {
+	diff := &LockDiff{}
+
+	oldReleases := make(map[string]*LockedRelease, len(l.Releases))
+	for _, release := range l.Releases {
+		oldReleases[release.Name] = release
+	}
+	newReleases := make(map[string]*LockedRelease, len(newer.Releases))
+	for _, release := range newer.Releases {
+		newReleases[release.Name] = release
+	}
+
+	names := make([]string, 0, len(oldReleases)+len(newReleases))
+	for name := range oldReleases {
+		names = append(names, name)
+	}
+	for name := range newReleases {
+		if _, ok := oldReleases[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldRelease, newRelease := oldReleases[name], newReleases[name]
+		releaseDiff := &LockedReleaseDiff{Name: name}
+		oldJobs, newJobs := map[string]string{}, map[string]string{}
+		if oldRelease != nil {
+			releaseDiff.OldVersion = oldRelease.Version
+			releaseDiff.OldCommitHash = oldRelease.CommitHash
+		}
+		if newRelease != nil {
+			releaseDiff.NewVersion = newRelease.Version
+			releaseDiff.NewCommitHash = newRelease.CommitHash
+		}
+		switch {
+		case oldRelease == nil:
+			releaseDiff.Packages = diffFingerprints(nil, newRelease.Packages)
+			newJobs = newRelease.Jobs
+		case newRelease == nil:
+			releaseDiff.Packages = diffFingerprints(oldRelease.Packages, nil)
+			oldJobs = oldRelease.Jobs
+		default:
+			releaseDiff.Packages = diffFingerprints(oldRelease.Packages, newRelease.Packages)
+			if oldRelease.Jobs != nil && newRelease.Jobs != nil {
+				oldJobs, newJobs = oldRelease.Jobs, newRelease.Jobs
+			}
+		}
+		releaseDiff.Jobs = diffFingerprints(oldJobs, newJobs)
+
+		if releaseDiff.OldVersion != releaseDiff.NewVersion || releaseDiff.OldCommitHash != releaseDiff.NewCommitHash ||
+			len(releaseDiff.Packages) > 0 || len(releaseDiff.Jobs) > 0 {
+			diff.Releases = append(diff.Releases, releaseDiff)
+		}
+	}
+
+	if l.Stemcell != "" && newer.Stemcell != "" && l.Stemcell != newer.Stemcell {
+		diff.OldStemcell = l.Stemcell
+		diff.NewStemcell = newer.Stemcell
+	}
+
+	return diff
+}
+
+// IsEmpty tells if nothing changed between the locks
+func (d *LockDiff) IsEmpty() bool {
+	return len(d.Releases) == 0 && d.NewStemcell == ""
+}
+
+// RoleChanges returns what changed in the images of a role, given its jobs:
+// the stemcell, and the jobs of the role and the packages they use, including
+// the packages those depend on, as "stemcell", "job <name>" and
+// "package <name>"; nothing if its images are the same
+func (d *LockDiff) RoleChanges(role *Role) []string {
+	var changes []string
+	if d.NewStemcell != "" && role.Type != RoleTypeDocker {
+		changes = append(changes, "stemcell")
+	}
+
+	releaseDiffs := make(map[string]*LockedReleaseDiff, len(d.Releases))
+	for _, releaseDiff := range d.Releases {
+		releaseDiffs[releaseDiff.Name] = releaseDiff
+	}
+
+	seen := map[string]bool{}
+	add := func(change string) {
+		if !seen[change] {
+			seen[change] = true
+			changes = append(changes, change)
+		}
+	}
+	var addPackages func(releaseDiff *LockedReleaseDiff, packages Packages)
+	addPackages = func(releaseDiff *LockedReleaseDiff, packages Packages) {
+		for _, pkg := range packages {
+			if releaseDiff.hasPackage(pkg.Name) {
+				add("package " + pkg.Name)
+			}
+			addPackages(releaseDiff, pkg.Dependencies)
+		}
+	}
+	for _, job := range role.Jobs {
+		releaseDiff, ok := releaseDiffs[job.Release.Name]
+		if !ok {
+			continue
+		}
+		if releaseDiff.hasJob(job.Name) {
+			add("job " + job.Name)
+		}
+		addPackages(releaseDiff, job.Packages)
+	}
+
+	return changes
+}
+
+// hasPackage tells if the package with the given name changed
+func (d *LockedReleaseDiff) hasPackage(name string) bool {
+	for _, change := range d.Packages {
+		if change.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasJob tells if the job with the given name changed
+func (d *LockedReleaseDiff) hasJob(name string) bool {
+	for _, change := range d.Jobs {
+		if change.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// diffFingerprints returns the names whose fingerprints differ between two
+// maps of names to fingerprints, sorted by name
+func diffFingerprints(oldFingerprints, newFingerprints map[string]string) []*FingerprintDiff {
+	names := make([]string, 0, len(oldFingerprints)+len(newFingerprints))
+	for name := range oldFingerprints {
+		names = append(names, name)
+	}
+	for name := range newFingerprints {
+		if _, ok := oldFingerprints[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []*FingerprintDiff
+	for _, name := range names {
+		if oldFingerprints[name] != newFingerprints[name] {
+			diffs = append(diffs, &FingerprintDiff{Name: name, Old: oldFingerprints[name], New: newFingerprints[name]})
+		}
+	}
+	return diffs
+}
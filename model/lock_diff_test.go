@@ -0,0 +1,138 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	older := &Lock{
+		Releases: []*LockedRelease{
+			{
+				Name:       "ntp",
+				Version:    "1",
+				CommitHash: "abc",
+				Packages:   map[string]string{"ntp": "aaa", "old": "bbb", "same": "eee"},
+				Jobs:       map[string]string{"ntpd": "fff"},
+			},
+			{
+				Name:     "legacy",
+				Version:  "1",
+				Packages: map[string]string{"legacy": "ggg"},
+			},
+			{
+				Name:     "unchanged",
+				Version:  "3",
+				Packages: map[string]string{"unchanged": "hhh"},
+			},
+			{
+				Name:    "gone",
+				Version: "2",
+			},
+		},
+		Stemcell: "sha256:1234",
+	}
+	newer := &Lock{
+		Releases: []*LockedRelease{
+			{
+				Name:       "ntp",
+				Version:    "2",
+				CommitHash: "def",
+				Packages:   map[string]string{"ntp": "ccc", "new": "ddd", "same": "eee"},
+				Jobs:       map[string]string{"ntpd": "iii"},
+			},
+			{
+				Name:     "legacy",
+				Version:  "1",
+				Packages: map[string]string{"legacy": "ggg"},
+				Jobs:     map[string]string{"legacy": "jjj"},
+			},
+			{
+				Name:     "unchanged",
+				Version:  "3",
+				Packages: map[string]string{"unchanged": "hhh"},
+			},
+			{
+				Name:    "extra",
+				Version: "1",
+				Jobs:    map[string]string{"extra": "kkk"},
+			},
+		},
+		Stemcell: "sha256:5678",
+	}
+
+	assert.Equal(&LockDiff{
+		Releases: []*LockedReleaseDiff{
+			{
+				Name:       "extra",
+				NewVersion: "1",
+				Jobs:       []*FingerprintDiff{{Name: "extra", New: "kkk"}},
+			},
+			{
+				Name:       "gone",
+				OldVersion: "2",
+			},
+			{
+				Name:          "ntp",
+				OldVersion:    "1",
+				NewVersion:    "2",
+				OldCommitHash: "abc",
+				NewCommitHash: "def",
+				Packages: []*FingerprintDiff{
+					{Name: "new", New: "ddd"},
+					{Name: "ntp", Old: "aaa", New: "ccc"},
+					{Name: "old", Old: "bbb"},
+				},
+				Jobs: []*FingerprintDiff{{Name: "ntpd", Old: "fff", New: "iii"}},
+			},
+		},
+		OldStemcell: "sha256:1234",
+		NewStemcell: "sha256:5678",
+	}, older.Diff(newer), "Jobs are only compared when both locks record them")
+
+	assert.True(older.Diff(older).IsEmpty())
+
+	newer.Stemcell = ""
+	assert.Empty(older.Diff(newer).NewStemcell, "The stemcell is only compared when both locks know it")
+}
+
+func TestLockDiffRoleChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+	rolesManifest, err := LoadRoleManifest(filepath.Join(workDir, "../test-assets/role-manifests/deploy-order.yml"), []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	// Both jobs of the tor release use its libevent package; myrole runs
+	// new_hostname, and api runs tor
+	diff := &LockDiff{
+		Releases: []*LockedReleaseDiff{
+			{
+				Name: "tor",
+				Jobs: []*FingerprintDiff{{Name: "tor", Old: "aaa", New: "bbb"}},
+			},
+		},
+	}
+	assert.Empty(diff.RoleChanges(rolesManifest.LookupRole("myrole")))
+	assert.Equal([]string{"job tor"}, diff.RoleChanges(rolesManifest.LookupRole("api")))
+
+	diff.Releases[0].Packages = []*FingerprintDiff{{Name: "libevent", Old: "ccc", New: "ddd"}}
+	diff.NewStemcell = "sha256:5678"
+	assert.Equal([]string{"stemcell", "package libevent"}, diff.RoleChanges(rolesManifest.LookupRole("myrole")))
+	assert.Equal([]string{"stemcell", "job tor", "package libevent"}, diff.RoleChanges(rolesManifest.LookupRole("api")))
+}
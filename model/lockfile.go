@@ -0,0 +1,183 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Lock captures the resolved inputs of a build, so that builds by different
+// people (or at different times) can be verified to use the same inputs
+type Lock struct {
+	Releases []*LockedRelease `yaml:"releases"`
+	Stemcell string           `yaml:"stemcell,omitempty"`
+}
+
+// LockedRelease is the locked information about a single release
+type LockedRelease struct {
+	Name       string            `yaml:"name"`
+	Version    string            `yaml:"version"`
+	CommitHash string            `yaml:"commit_hash,omitempty"`
+	Packages   map[string]string `yaml:"packages"`       // Package name to fingerprint
+	Jobs       map[string]string `yaml:"jobs,omitempty"` // Job name to fingerprint; not in locks of older fissile versions
+}
+
+// NewLock creates a lock for the given releases and stemcell image. The
+// stemcell may be empty if it is not known.
+func NewLock(releases []*Release, stemcell string) *Lock {
+	lock := &Lock{
+		Releases: make([]*LockedRelease, 0, len(releases)),
+		Stemcell: stemcell,
+	}
+
+	for _, release := range releases {
+		lockedRelease := &LockedRelease{
+			Name:       release.Name,
+			Version:    release.Version,
+			CommitHash: release.CommitHash,
+			Packages:   make(map[string]string, len(release.Packages)),
+			Jobs:       make(map[string]string, len(release.Jobs)),
+		}
+		for _, pkg := range release.Packages {
+			lockedRelease.Packages[pkg.Name] = pkg.Fingerprint
+		}
+		for _, job := range release.Jobs {
+			lockedRelease.Jobs[job.Name] = job.Fingerprint
+		}
+		lock.Releases = append(lock.Releases, lockedRelease)
+	}
+
+	sort.Sort(lockedReleasesByName(lock.Releases))
+
+	return lock
+}
+
+// LoadLock reads a lock file
+func LoadLock(path string) (*Lock, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{}
+	if err := yaml.Unmarshal(contents, lock); err != nil {
+		return nil, fmt.Errorf("Error parsing lock file %s: %s", path, err.Error())
+	}
+
+	return lock, nil
+}
+
+// Write saves the lock to a file. The comments of an existing lock file are
+// kept.
+func (l *Lock) Write(path string) error {
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var contents []byte
+	if len(existing) == 0 {
+		contents, err = yaml.Marshal(l)
+	} else {
+		var stemcell interface{}
+		if l.Stemcell != "" {
+			stemcell = l.Stemcell
+		}
+		contents, err = SetYAMLKeys(existing, yaml.MapSlice{
+			{Key: "releases", Value: l.Releases},
+			{Key: "stemcell", Value: stemcell},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, contents, 0644)
+}
+
+// Drift returns a description of every difference between the lock and the
+// current one. The stemcell is only compared if both locks know it, and the
+// jobs of a release if the lock records them.
+func (l *Lock) Drift(current *Lock) []string {
+	var drift []string
+
+	lockedReleases := make(map[string]*LockedRelease, len(l.Releases))
+	for _, release := range l.Releases {
+		lockedReleases[release.Name] = release
+	}
+	currentReleases := make(map[string]*LockedRelease, len(current.Releases))
+	for _, release := range current.Releases {
+		currentReleases[release.Name] = release
+	}
+
+	for _, locked := range l.Releases {
+		if _, ok := currentReleases[locked.Name]; !ok {
+			drift = append(drift, fmt.Sprintf("release %s is locked but not used", locked.Name))
+		}
+	}
+
+	for _, release := range current.Releases {
+		locked, ok := lockedReleases[release.Name]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("release %s is not locked", release.Name))
+			continue
+		}
+		if locked.Version != release.Version {
+			drift = append(drift, fmt.Sprintf("release %s: version %s, locked %s", release.Name, release.Version, locked.Version))
+		}
+		if locked.CommitHash != release.CommitHash {
+			drift = append(drift, fmt.Sprintf("release %s: commit %s, locked %s", release.Name, release.CommitHash, locked.CommitHash))
+		}
+
+		for _, change := range diffFingerprints(locked.Packages, release.Packages) {
+			switch {
+			case change.Old == "":
+				drift = append(drift, fmt.Sprintf("release %s: package %s is not locked", release.Name, change.Name))
+			case change.New == "":
+				drift = append(drift, fmt.Sprintf("release %s: package %s is locked but missing", release.Name, change.Name))
+			default:
+				drift = append(drift, fmt.Sprintf("release %s: package %s has fingerprint %s, locked %s", release.Name, change.Name, change.New, change.Old))
+			}
+		}
+		if locked.Jobs == nil {
+			continue
+		}
+		for _, change := range diffFingerprints(locked.Jobs, release.Jobs) {
+			switch {
+			case change.Old == "":
+				drift = append(drift, fmt.Sprintf("release %s: job %s is not locked", release.Name, change.Name))
+			case change.New == "":
+				drift = append(drift, fmt.Sprintf("release %s: job %s is locked but missing", release.Name, change.Name))
+			default:
+				drift = append(drift, fmt.Sprintf("release %s: job %s has fingerprint %s, locked %s", release.Name, change.Name, change.New, change.Old))
+			}
+		}
+	}
+
+	if l.Stemcell != "" && current.Stemcell != "" && l.Stemcell != current.Stemcell {
+		drift = append(drift, fmt.Sprintf("stemcell %s, locked %s", current.Stemcell, l.Stemcell))
+	}
+
+	return drift
+}
+
+// lockedReleasesByName sorts locked releases by name
+type lockedReleasesByName []*LockedRelease
+
+// Len is the number of locked releases in the slice
+func (r lockedReleasesByName) Len() int {
+	return len(r)
+}
+
+// Less reports whether the release at index i sorts before the one at index j
+func (r lockedReleasesByName) Less(i, j int) bool {
+	return r[i].Name < r[j].Name
+}
+
+// Swap exchanges the releases at index i and index j
+func (r lockedReleasesByName) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
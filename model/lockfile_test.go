@@ -0,0 +1,131 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	ntpReleasePath := filepath.Join(workDir, "../test-assets/ntp-release")
+	ntpReleasePathBoshCache := filepath.Join(ntpReleasePath, "bosh-cache")
+	release, err := NewDevRelease(ntpReleasePath, "", "", ntpReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	lock := NewLock([]*Release{release}, "sha256:1234")
+	if assert.Len(lock.Releases, 1) {
+		assert.Equal(release.Name, lock.Releases[0].Name)
+		assert.Equal(release.Version, lock.Releases[0].Version)
+		assert.Len(lock.Releases[0].Packages, len(release.Packages))
+	}
+
+	tempDir, err := ioutil.TempDir("", "fissile-lock")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	lockPath := filepath.Join(tempDir, "fissile.lock")
+	assert.NoError(lock.Write(lockPath))
+
+	loaded, err := LoadLock(lockPath)
+	if assert.NoError(err) {
+		assert.Equal(lock, loaded)
+		assert.Empty(loaded.Drift(lock))
+	}
+
+	// Rewriting the lock keeps the comments people added to it
+	contents, err := ioutil.ReadFile(lockPath)
+	if !assert.NoError(err) {
+		return
+	}
+	contents = append([]byte("# Reviewed by the release team\n"), contents...)
+	assert.NoError(ioutil.WriteFile(lockPath, contents, 0644))
+	lock.Releases[0].Version = "9.9.9"
+	lock.Stemcell = ""
+	assert.NoError(lock.Write(lockPath))
+
+	contents, err = ioutil.ReadFile(lockPath)
+	if assert.NoError(err) {
+		assert.Contains(string(contents), "# Reviewed by the release team\n")
+		assert.NotContains(string(contents), "stemcell")
+	}
+	loaded, err = LoadLock(lockPath)
+	if assert.NoError(err) {
+		assert.Equal(lock, loaded)
+	}
+}
+
+func TestLockDrift(t *testing.T) {
+	assert := assert.New(t)
+
+	locked := &Lock{
+		Releases: []*LockedRelease{
+			{
+				Name:     "ntp",
+				Version:  "1",
+				Packages: map[string]string{"ntp": "aaa", "old": "bbb"},
+			},
+			{
+				Name:    "gone",
+				Version: "2",
+			},
+		},
+		Stemcell: "sha256:1234",
+	}
+	current := &Lock{
+		Releases: []*LockedRelease{
+			{
+				Name:     "ntp",
+				Version:  "1+dev.2",
+				Packages: map[string]string{"ntp": "ccc", "new": "ddd"},
+			},
+			{
+				Name: "extra",
+			},
+		},
+		Stemcell: "sha256:5678",
+	}
+
+	assert.Equal([]string{
+		"release gone is locked but not used",
+		"release ntp: version 1+dev.2, locked 1",
+		"release ntp: package new is not locked",
+		"release ntp: package ntp has fingerprint ccc, locked aaa",
+		"release ntp: package old is locked but missing",
+		"release extra is not locked",
+		"stemcell sha256:5678, locked sha256:1234",
+	}, locked.Drift(current))
+
+	current.Stemcell = ""
+	assert.NotContains(locked.Drift(current), "stemcell sha256:5678, locked sha256:1234")
+}
+
+func TestLockDriftJobs(t *testing.T) {
+	assert := assert.New(t)
+
+	current := &Lock{
+		Releases: []*LockedRelease{
+			{Name: "ntp", Jobs: map[string]string{"ntpd": "bbb"}},
+		},
+	}
+
+	locked := &Lock{Releases: []*LockedRelease{{Name: "ntp"}}}
+	assert.Empty(locked.Drift(current), "Jobs are not compared for locks without them")
+
+	locked.Releases[0].Jobs = map[string]string{"ntpd": "aaa", "old": "ccc"}
+	assert.Equal([]string{
+		"release ntp: job ntpd has fingerprint bbb, locked aaa",
+		"release ntp: job old is locked but missing",
+	}, locked.Drift(current))
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ManifestVariableType is the type of a variable of the variables section of
+// the role manifest, which determines how its value is generated
+type ManifestVariableType string
+
+// These are the types of variables
+const (
+	ManifestVariableTypePassword    = ManifestVariableType("password")
+	ManifestVariableTypeCertificate = ManifestVariableType("certificate")
+	ManifestVariableTypeSSH         = ManifestVariableType("ssh")
+)
+
+// ManifestVariable is a variable of the variables section of the role
+// manifest: a credential of the deployment, like the variables of BOSH
+// deployment manifests stored in CredHub
+type ManifestVariable struct {
+	Name    string                   `yaml:"name"`
+	Type    ManifestVariableType     `yaml:"type"`
+	Options *ManifestVariableOptions `yaml:"options"`
+}
+
+// ManifestVariableOptions are the options generating the value of a variable;
+// which ones apply depends on its type
+type ManifestVariableOptions struct {
+	Length           int      `yaml:"length"`             // Of passwords, in characters; 0 for the default
+	IsCA             bool     `yaml:"is_ca"`              // Certificate of a certificate authority
+	CA               string   `yaml:"ca"`                 // Name of the CA certificate variable signing the certificate
+	CommonName       string   `yaml:"common_name"`        // Of certificates
+	AlternativeNames []string `yaml:"alternative_names"`  // Subject alternative names of certificates
+	ExtendedKeyUsage []string `yaml:"extended_key_usage"` // Of certificates: client_auth or server_auth
+	Duration         int      `yaml:"duration"`           // Validity of certificates, in days; 0 for the default
+}
+
+// manifestVariableNamePattern matches the names of variables, as BOSH allows
+// them in ((name)) references
+var manifestVariableNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// LookupManifestVariable returns the variable of the variables section with
+// the given name, or nil if there is none
+func (m *RoleManifest) LookupManifestVariable(name string) *ManifestVariable {
+	for _, variable := range m.Variables {
+		if variable.Name == name {
+			return variable
+		}
+	}
+	return nil
+}
+
+// validateManifestVariables checks the variables section of the role
+// manifest: variables have distinct valid names, a known type and only the
+// options of their type. Certificates are signed by a CA certificate variable
+// unless they are one.
+func (m *RoleManifest) validateManifestVariables() error {
+	configurationVariables := map[string]bool{}
+	if m.Configuration != nil {
+		for _, variable := range m.Configuration.Variables {
+			configurationVariables[variable.Name] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, variable := range m.Variables {
+		if !manifestVariableNamePattern.MatchString(variable.Name) {
+			return fmt.Errorf("Invalid variable name '%s'", variable.Name)
+		}
+		if seen[variable.Name] {
+			return fmt.Errorf("Variable %s is defined more than once", variable.Name)
+		}
+		seen[variable.Name] = true
+		if configurationVariables[variable.Name] {
+			return fmt.Errorf("Variable %s is defined both in variables and in the configuration variables", variable.Name)
+		}
+		if err := variable.validateOptions(m); err != nil {
+			return fmt.Errorf("Variable %s: %s", variable.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// validateOptions checks that the options of a variable apply to its type
+func (v *ManifestVariable) validateOptions(m *RoleManifest) error {
+	options := v.Options
+	if options == nil {
+		options = &ManifestVariableOptions{}
+	}
+	certificateOptions := options.IsCA || options.CA != "" || options.CommonName != "" ||
+		len(options.AlternativeNames) > 0 || len(options.ExtendedKeyUsage) > 0 || options.Duration != 0
+
+	switch v.Type {
+	case ManifestVariableTypePassword:
+		if certificateOptions {
+			return fmt.Errorf("only certificates have certificate options")
+		}
+		if options.Length < 0 {
+			return fmt.Errorf("invalid length %d", options.Length)
+		}
+
+	case ManifestVariableTypeCertificate:
+		if options.Length != 0 {
+			return fmt.Errorf("only passwords have a length")
+		}
+		if options.Duration < 0 {
+			return fmt.Errorf("invalid duration %d", options.Duration)
+		}
+		for _, usage := range options.ExtendedKeyUsage {
+			if usage != "client_auth" && usage != "server_auth" {
+				return fmt.Errorf("invalid extended key usage %s, expected client_auth or server_auth", usage)
+			}
+		}
+		if options.CA == "" {
+			if !options.IsCA {
+				return fmt.Errorf("certificate needs a ca, unless it is one")
+			}
+			break
+		}
+		ca := m.LookupManifestVariable(options.CA)
+		if ca == nil || ca.Type != ManifestVariableTypeCertificate || ca.Options == nil || !ca.Options.IsCA {
+			return fmt.Errorf("ca %s is not a CA certificate variable", options.CA)
+		}
+
+	case ManifestVariableTypeSSH:
+		if certificateOptions || options.Length != 0 {
+			return fmt.Errorf("ssh keys have no options")
+		}
+
+	default:
+		return fmt.Errorf("invalid type '%s', expected %s, %s or %s", v.Type,
+			ManifestVariableTypePassword, ManifestVariableTypeCertificate, ManifestVariableTypeSSH)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestVariables(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	release, err := NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+	if !assert.NoError(err) {
+		return
+	}
+
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/variables.yml")
+	manifest, err := LoadRoleManifest(manifestPath, []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	if assert.Len(manifest.Variables, 4) {
+		assert.Equal(&ManifestVariable{
+			Name: "admin_password",
+			Type: ManifestVariableTypePassword,
+			Options: &ManifestVariableOptions{
+				Length: 32,
+			},
+		}, manifest.Variables[0])
+		assert.Equal(ManifestVariableTypeSSH, manifest.Variables[3].Type)
+	}
+
+	certificate := manifest.LookupManifestVariable("tor_tls")
+	if assert.NotNil(certificate) {
+		assert.Equal(&ManifestVariableOptions{
+			CA:               "default_ca",
+			CommonName:       "tor.service.cf.internal",
+			AlternativeNames: []string{"tor"},
+			ExtendedKeyUsage: []string{"server_auth"},
+		}, certificate.Options)
+	}
+	assert.Nil(manifest.LookupManifestVariable("FOO"))
+}
+
+func TestValidateManifestVariables(t *testing.T) {
+	assert := assert.New(t)
+
+	ca := &ManifestVariable{Name: "ca", Type: ManifestVariableTypeCertificate, Options: &ManifestVariableOptions{IsCA: true}}
+
+	samples := []struct {
+		desc     string
+		variable *ManifestVariable
+		err      string
+	}{
+		{
+			desc:     "a password without options",
+			variable: &ManifestVariable{Name: "password", Type: ManifestVariableTypePassword},
+		},
+		{
+			desc:     "a certificate signed by a CA",
+			variable: &ManifestVariable{Name: "cert", Type: ManifestVariableTypeCertificate, Options: &ManifestVariableOptions{CA: "ca"}},
+		},
+		{
+			desc:     "an invalid name",
+			variable: &ManifestVariable{Name: "my password", Type: ManifestVariableTypePassword},
+			err:      "Invalid variable name 'my password'",
+		},
+		{
+			desc:     "a name used twice",
+			variable: &ManifestVariable{Name: "ca", Type: ManifestVariableTypePassword},
+			err:      "Variable ca is defined more than once",
+		},
+		{
+			desc:     "a name of a configuration variable",
+			variable: &ManifestVariable{Name: "FOO", Type: ManifestVariableTypePassword},
+			err:      "Variable FOO is defined both in variables and in the configuration variables",
+		},
+		{
+			desc:     "an unknown type",
+			variable: &ManifestVariable{Name: "key", Type: "rsa"},
+			err:      "Variable key: invalid type 'rsa', expected password, certificate or ssh",
+		},
+		{
+			desc:     "a password with certificate options",
+			variable: &ManifestVariable{Name: "password", Type: ManifestVariableTypePassword, Options: &ManifestVariableOptions{CommonName: "foo"}},
+			err:      "Variable password: only certificates have certificate options",
+		},
+		{
+			desc:     "a certificate without a CA",
+			variable: &ManifestVariable{Name: "cert", Type: ManifestVariableTypeCertificate},
+			err:      "Variable cert: certificate needs a ca, unless it is one",
+		},
+		{
+			desc:     "a certificate signed by a password",
+			variable: &ManifestVariable{Name: "cert", Type: ManifestVariableTypeCertificate, Options: &ManifestVariableOptions{CA: "FOO"}},
+			err:      "Variable cert: ca FOO is not a CA certificate variable",
+		},
+		{
+			desc:     "an invalid extended key usage",
+			variable: &ManifestVariable{Name: "cert", Type: ManifestVariableTypeCertificate, Options: &ManifestVariableOptions{CA: "ca", ExtendedKeyUsage: []string{"code_signing"}}},
+			err:      "Variable cert: invalid extended key usage code_signing, expected client_auth or server_auth",
+		},
+		{
+			desc:     "an ssh key with options",
+			variable: &ManifestVariable{Name: "ssh", Type: ManifestVariableTypeSSH, Options: &ManifestVariableOptions{Length: 2048}},
+			err:      "Variable ssh: ssh keys have no options",
+		},
+	}
+
+	for _, sample := range samples {
+		manifest := &RoleManifest{
+			Variables:     []*ManifestVariable{ca, sample.variable},
+			Configuration: &Configuration{Variables: ConfigurationVariableSlice{{Name: "FOO"}}},
+		}
+		err := manifest.validateManifestVariables()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// LoadRoleManifestVariants loads the role manifest without an environment and
+// for each of its environments, each time with all of its features enabled and
+// with none, so that every role of the manifest, as patched by each of the
+// environments, is in at least one of the manifests returned. The features
+// and environment of the options are replaced; the other options apply to all
+// of the manifests.
+func LoadRoleManifestVariants(manifestFilePath string, releases []*Release, options RoleManifestOptions) ([]*RoleManifest, error) {
+	raw, err := readLintedRoleManifest(manifestFilePath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	environments := []string{""}
+	for environment := range raw.Environments {
+		environments = append(environments, environment)
+	}
+	sort.Strings(environments)
+
+	featureSet := map[string]bool{}
+	var features []string
+	for _, role := range raw.Roles {
+		feature := strings.TrimPrefix(role.Feature, "!")
+		if feature != "" && !featureSet[feature] {
+			featureSet[feature] = true
+			features = append(features, feature)
+		}
+	}
+	sort.Strings(features)
+
+	featureSelections := [][]string{nil}
+	if len(features) > 0 {
+		featureSelections = append(featureSelections, features)
+	}
+
+	var manifests []*RoleManifest
+	for _, environment := range environments {
+		for _, selection := range featureSelections {
+			options.Features, options.Environment = selection, environment
+			manifest, err := LoadRoleManifestWithOptions(manifestFilePath, releases, options)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, manifest)
+		}
+	}
+	return manifests, nil
+}
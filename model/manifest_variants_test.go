@@ -0,0 +1,42 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestVariants(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/features.yml")
+	manifests, err := LoadRoleManifestVariants(roleManifestPath, []*Release{release}, RoleManifestOptions{Features: []string{"ha"}})
+	if !assert.NoError(err) {
+		return
+	}
+
+	var names [][]string
+	for _, manifest := range manifests {
+		var manifestNames []string
+		for _, role := range manifest.Roles {
+			manifestNames = append(manifestNames, role.Name)
+		}
+		names = append(names, manifestNames)
+	}
+	assert.Equal([][]string{
+		{"myrole", "proxy"},
+		{"myrole", "ha-proxy", "autoscaler"},
+	}, names, "the roles of every feature are loaded")
+}
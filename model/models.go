@@ -4,6 +4,9 @@ package model
 type ReleaseLicense struct {
 	// Files is a mapping of license file names to contents
 	Files map[string][]byte
+	// Hashes is a mapping of license file names to SHA1 hashes, for the files
+	// that were too large or binary to keep in Files
+	Hashes map[string]string
 	// Release this license belongs to
 	Release *Release
 }
@@ -56,6 +56,17 @@ func (r *Role) GetVariablesForRole() (ConfigurationVariableSlice, error) {
 		}
 	}
 
+	// The variables used by run.env are set for the role too
+	if r.Run != nil {
+		for _, env := range r.Run.Env {
+			for _, name := range env.EnvVariables() {
+				if confVar, ok := configsDictionary[name]; ok {
+					configs[confVar.Name] = confVar
+				}
+			}
+		}
+	}
+
 	result := make(ConfigurationVariableSlice, 0, len(configs))
 
 	for _, value := range configs {
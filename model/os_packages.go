@@ -0,0 +1,69 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// These are the package managers that can install OS packages into role images
+const (
+	OSPackageManagerApt = "apt"
+	OSPackageManagerYum = "yum"
+)
+
+// osPackagePattern matches package names, optionally pinned to a version
+// (libfuse2=2.9.4-1ubuntu3 for apt, fuse-libs-2.9.2 for yum)
+var osPackagePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9+._:~=-]*$`)
+
+// RoleOSPackages describes extra operating system packages to install into
+// the image of a role, on top of the stemcell
+type RoleOSPackages struct {
+	Manager  string   `yaml:"manager"` // apt (the default) or yum
+	Packages []string `yaml:"packages"`
+}
+
+func (p *RoleOSPackages) validate() error {
+	switch p.Manager {
+	case "":
+		p.Manager = OSPackageManagerApt
+	case OSPackageManagerApt, OSPackageManagerYum:
+	default:
+		return fmt.Errorf("Invalid OS package manager %s, expected %s or %s", p.Manager, OSPackageManagerApt, OSPackageManagerYum)
+	}
+
+	if len(p.Packages) == 0 {
+		return fmt.Errorf("No OS packages listed")
+	}
+	for _, pkg := range p.Packages {
+		if !osPackagePattern.MatchString(pkg) {
+			return fmt.Errorf("Invalid OS package name %q", pkg)
+		}
+	}
+
+	return nil
+}
+
+// GetSortedPackages returns the OS packages sorted by name
+func (p *RoleOSPackages) GetSortedPackages() []string {
+	packages := append([]string{}, p.Packages...)
+	sort.Strings(packages)
+	return packages
+}
+
+// InstallCommand returns the shell command that installs the packages in the
+// image, and cleans up the package manager caches afterwards
+func (p *RoleOSPackages) InstallCommand() string {
+	packages := strings.Join(p.GetSortedPackages(), " ")
+
+	if p.Manager == OSPackageManagerYum {
+		return fmt.Sprintf("yum install -y %s && yum clean all", packages)
+	}
+	return fmt.Sprintf("apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends %s && rm -rf /var/lib/apt/lists/*", packages)
+}
+
+// String describes the packages for reports and image labels
+func (p *RoleOSPackages) String() string {
+	return fmt.Sprintf("%s:%s", p.Manager, strings.Join(p.GetSortedPackages(), ","))
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleOSPackagesValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	packages := &RoleOSPackages{Packages: []string{"libfuse2=2.9.4-1ubuntu3"}}
+	assert.NoError(packages.validate())
+	assert.Equal(OSPackageManagerApt, packages.Manager, "apt should be the default")
+
+	packages = &RoleOSPackages{Manager: "dnf", Packages: []string{"fuse"}}
+	assert.EqualError(packages.validate(), "Invalid OS package manager dnf, expected apt or yum")
+
+	packages = &RoleOSPackages{Manager: OSPackageManagerYum}
+	assert.EqualError(packages.validate(), "No OS packages listed")
+
+	packages = &RoleOSPackages{Packages: []string{"fuse; rm -rf /"}}
+	assert.EqualError(packages.validate(), `Invalid OS package name "fuse; rm -rf /"`)
+}
+
+func TestRoleOSPackagesInstallCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	packages := &RoleOSPackages{Manager: OSPackageManagerYum, Packages: []string{"fuse-libs", "fuse"}}
+	assert.Equal("yum install -y fuse fuse-libs && yum clean all", packages.InstallCommand())
+	assert.Equal("yum:fuse,fuse-libs", packages.String())
+}
+
+func TestRoleOSPackagesChangeDevVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	role := &Role{Name: "myrole"}
+	withoutPackages := role.GetRoleDevVersion()
+
+	role.OSPackages = &RoleOSPackages{Manager: OSPackageManagerApt, Packages: []string{"fuse"}}
+	withPackages := role.GetRoleDevVersion()
+	assert.NotEqual(withoutPackages, withPackages)
+
+	role.OSPackages.Packages = append(role.OSPackages.Packages, "libfuse2")
+	assert.NotEqual(withPackages, role.GetRoleDevVersion())
+}
@@ -4,8 +4,13 @@ import (
 	"crypto/sha1"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hpcloud/fissile/util"
 
 	"github.com/pivotal-golang/archiver/extractor"
 )
@@ -19,8 +24,10 @@ type Package struct {
 	Release      *Release
 	Path         string
 	Dependencies Packages
+	Blobs        []*Blob // Blobs of the release matching the files of the package spec
 
 	packageReleaseInfo map[interface{}]interface{}
+	canonical          *Package // Package compiled for this one; see sharePackages
 }
 
 // Packages is an array of *Package
@@ -37,6 +44,10 @@ func newPackage(release *Release, packageReleaseInfo map[interface{}]interface{}
 		return nil, err
 	}
 
+	if err := fetchMirroredArchive(release.options.ArchiveMirrors, pkg.Path, pkg.SHA1); err != nil {
+		return nil, fmt.Errorf("Package %s: %s", pkg.Name, err.Error())
+	}
+
 	return pkg, nil
 }
 
@@ -123,7 +134,9 @@ func (p *Package) loadPackageInfo() (err error) {
 	return nil
 }
 
-func (p *Package) loadPackageDependencies() (err error) {
+// loadPackageDependencies resolves the dependencies of the package within its
+// release, returning the names of the ones the release doesn't have
+func (p *Package) loadPackageDependencies() (missing []string, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("Error trying to load package information: %s", r)
@@ -134,13 +147,14 @@ func (p *Package) loadPackageDependencies() (err error) {
 		pkgName := pkg.(string)
 		dependency, err := p.Release.LookupPackage(pkgName)
 		if err != nil {
-			return fmt.Errorf("Cannot find dependency for package %s: %v", p.Name, err.Error())
+			missing = append(missing, pkgName)
+			continue
 		}
 
 		p.Dependencies = append(p.Dependencies, dependency)
 	}
 
-	return nil
+	return missing, nil
 }
 
 func (p *Package) packageArchivePath() string {
@@ -164,3 +178,44 @@ func (p *Package) GetPackageCompiledTempDir(workDir string) string {
 func (p *Package) GetPackageCompiledDir(workDir string) string {
 	return filepath.Join(workDir, p.Fingerprint, "compiled")
 }
+
+// GetPackageCompiledSizePath returns the path to the file recording the size
+// of the build result of the package, underneath the main cache directory
+func (p *Package) GetPackageCompiledSizePath(workDir string) string {
+	return filepath.Join(workDir, p.Fingerprint, "compiled-size")
+}
+
+// RecordCompiledSize measures the build result of the package and records its
+// size, in bytes
+func (p *Package) RecordCompiledSize(workDir string) (int64, error) {
+	size, err := util.DirSize(p.GetPackageCompiledDir(workDir))
+	if err != nil {
+		return 0, fmt.Errorf("Error measuring compiled package %s: %s", p.Name, err.Error())
+	}
+
+	if err := ioutil.WriteFile(p.GetPackageCompiledSizePath(workDir), []byte(strconv.FormatInt(size, 10)), 0644); err != nil {
+		return 0, fmt.Errorf("Error recording size of compiled package %s: %s", p.Name, err.Error())
+	}
+
+	return size, nil
+}
+
+// GetCompiledSize returns the size, in bytes, of the build result of the
+// package. Packages compiled before sizes were recorded are measured, and
+// their size recorded.
+func (p *Package) GetCompiledSize(workDir string) (int64, error) {
+	contents, err := ioutil.ReadFile(p.GetPackageCompiledSizePath(workDir))
+	if os.IsNotExist(err) {
+		return p.RecordCompiledSize(workDir)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid recorded size of compiled package %s: %s", p.Name, err.Error())
+	}
+
+	return size, nil
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// loadDependenciesForPackages resolves the dependencies of the packages of
+// the release, and checks they form a DAG. All unknown dependencies are
+// reported at once, with the packages declaring them; a dependency cycle is
+// reported as the chain of packages around it.
+func (r *Release) loadDependenciesForPackages() error {
+	var unknown []string
+	for _, pkg := range r.Packages {
+		missing, err := pkg.loadPackageDependencies()
+		if err != nil {
+			return err
+		}
+		for _, name := range missing {
+			unknown = append(unknown, fmt.Sprintf("package %s depends on unknown package %s", pkg.Name, name))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("Release %s: %s", r.Name, strings.Join(unknown, ", "))
+	}
+
+	if cycle := r.Packages.findDependencyCycle(); cycle != nil {
+		return fmt.Errorf("Release %s: circular dependencies between packages %s", r.Name, strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
+
+// findDependencyCycle returns the names of the packages around a cycle of
+// their dependencies, starting and ending with the same package; nil if the
+// dependencies are acyclic
+func (packages Packages) findDependencyCycle() []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[*Package]int{}
+	var chain []string
+
+	var visit func(pkg *Package) []string
+	visit = func(pkg *Package) []string {
+		switch state[pkg] {
+		case visited:
+			return nil
+		case visiting:
+			for i, name := range chain {
+				if name == pkg.Name {
+					return append(append([]string{}, chain[i:]...), pkg.Name)
+				}
+			}
+		}
+
+		state[pkg] = visiting
+		chain = append(chain, pkg.Name)
+		for _, dependency := range pkg.Dependencies {
+			if cycle := visit(dependency); cycle != nil {
+				return cycle
+			}
+		}
+		chain = chain[:len(chain)-1]
+		state[pkg] = visited
+		return nil
+	}
+
+	for _, pkg := range packages {
+		if cycle := visit(pkg); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// DependencyOrder returns the packages so that every package comes after the
+// packages it depends on; packages are otherwise in their order. Dependencies
+// outside of the packages are left out. The dependencies of the packages of
+// releases are checked to be acyclic when the releases are loaded.
+func (packages Packages) DependencyOrder() Packages {
+	included := make(map[*Package]bool, len(packages))
+	for _, pkg := range packages {
+		included[pkg] = true
+	}
+
+	order := make(Packages, 0, len(packages))
+	added := make(map[*Package]bool, len(packages))
+	var add func(pkg *Package)
+	add = func(pkg *Package) {
+		if added[pkg] || !included[pkg] {
+			return
+		}
+		// Marked first, so a cycle can't recurse forever
+		added[pkg] = true
+		for _, dependency := range pkg.Dependencies {
+			add(dependency)
+		}
+		order = append(order, pkg)
+	}
+	for _, pkg := range packages {
+		add(pkg)
+	}
+	return order
+}
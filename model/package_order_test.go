@@ -0,0 +1,83 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDependenciesForPackages(t *testing.T) {
+	assert := assert.New(t)
+
+	newRelease := func(dependencies map[string][]string, names ...string) *Release {
+		release := &Release{Name: "myrelease"}
+		for _, name := range names {
+			var declared []interface{}
+			for _, dependency := range dependencies[name] {
+				declared = append(declared, dependency)
+			}
+			release.Packages = append(release.Packages, &Package{
+				Name:               name,
+				Release:            release,
+				packageReleaseInfo: map[interface{}]interface{}{"dependencies": declared},
+			})
+		}
+		return release
+	}
+
+	samples := []struct {
+		desc         string
+		names        []string
+		dependencies map[string][]string
+		err          string
+	}{
+		{
+			desc:         "acyclic dependencies",
+			names:        []string{"app", "ruby", "libyaml"},
+			dependencies: map[string][]string{"app": {"ruby", "libyaml"}, "ruby": {"libyaml"}},
+		},
+		{
+			desc:         "unknown dependencies are all reported",
+			names:        []string{"app", "ruby"},
+			dependencies: map[string][]string{"app": {"ruby", "golang"}, "ruby": {"libyaml"}},
+			err:          "Release myrelease: package app depends on unknown package golang, package ruby depends on unknown package libyaml",
+		},
+		{
+			desc:         "a cycle is reported as its chain",
+			names:        []string{"app", "ruby", "libyaml", "openssl"},
+			dependencies: map[string][]string{"app": {"ruby"}, "ruby": {"libyaml"}, "libyaml": {"openssl"}, "openssl": {"ruby"}},
+			err:          "Release myrelease: circular dependencies between packages ruby -> libyaml -> openssl -> ruby",
+		},
+		{
+			desc:         "a package depending on itself",
+			names:        []string{"app"},
+			dependencies: map[string][]string{"app": {"app"}},
+			err:          "Release myrelease: circular dependencies between packages app -> app",
+		},
+	}
+
+	for _, sample := range samples {
+		err := newRelease(sample.dependencies, sample.names...).loadDependenciesForPackages()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestPackagesDependencyOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	libyaml := &Package{Name: "libyaml"}
+	openssl := &Package{Name: "openssl"}
+	ruby := &Package{Name: "ruby", Dependencies: Packages{libyaml, openssl}}
+	app := &Package{Name: "app", Dependencies: Packages{ruby}}
+	golang := &Package{Name: "golang"}
+
+	var names []string
+	for _, pkg := range (Packages{app, golang, openssl, ruby}).DependencyOrder() {
+		names = append(names, pkg.Name)
+	}
+	assert.Equal([]string{"openssl", "ruby", "app", "golang"}, names, "dependencies come first, and only the given packages are included")
+}
@@ -0,0 +1,32 @@
+package model
+
+// sharePackages canonicalizes the packages of the releases by fingerprint:
+// when several releases ship the same package, the package of the first
+// release shipping it is the one compiled for all of them, and its compiled
+// output and image layer are shared by all roles using it, whichever release
+// their jobs come from. The jobs and packages of each release keep referring
+// to the packages of their own release, so the provenance of the images and
+// lookups by release still name the release that ships them.
+func sharePackages(releases []*Release) {
+	canonical := map[string]*Package{}
+	for _, release := range releases {
+		for _, pkg := range release.Packages {
+			shared, ok := canonical[pkg.Fingerprint]
+			if !ok {
+				canonical[pkg.Fingerprint] = pkg
+				shared = pkg
+			}
+			pkg.canonical = shared
+		}
+	}
+}
+
+// Canonical returns the package compiled for this one: the package with the
+// same fingerprint of the first release loaded that ships it; the package
+// itself when it is unshared
+func (p *Package) Canonical() *Package {
+	if p.canonical == nil {
+		return p
+	}
+	return p.canonical
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharePackages(t *testing.T) {
+	assert := assert.New(t)
+
+	first := &Release{Name: "first"}
+	second := &Release{Name: "second"}
+
+	libyaml := &Package{Name: "libyaml", Fingerprint: "aaa", Release: first}
+	ruby := &Package{Name: "ruby", Fingerprint: "bbb", Release: first, Dependencies: Packages{libyaml}}
+	first.Packages = Packages{libyaml, ruby}
+	first.Jobs = Jobs{{Name: "api", Release: first, Packages: Packages{ruby, libyaml}}}
+
+	secondLibyaml := &Package{Name: "libyaml", Fingerprint: "aaa", Release: second}
+	secondRuby := &Package{Name: "ruby", Fingerprint: "ccc", Release: second, Dependencies: Packages{secondLibyaml}}
+	renamed := &Package{Name: "yaml", Fingerprint: "aaa", Release: second}
+	second.Packages = Packages{secondLibyaml, secondRuby, renamed}
+	second.Jobs = Jobs{{Name: "worker", Release: second, Packages: Packages{secondRuby, secondLibyaml, renamed}}}
+
+	sharePackages([]*Release{first, second})
+
+	assert.True(libyaml.Canonical() == libyaml, "the packages of the first release are compiled for themselves")
+	assert.True(ruby.Canonical() == ruby, "the packages of the first release are compiled for themselves")
+	assert.True(secondLibyaml.Canonical() == libyaml, "packages with the same fingerprint are shared")
+	assert.True(renamed.Canonical() == libyaml, "packages are shared by fingerprint only")
+	assert.True(secondRuby.Canonical() == secondRuby, "packages with other fingerprints are not shared")
+
+	assert.True(second.Jobs[0].Packages[1] == secondLibyaml, "jobs keep the packages of their release")
+	assert.True(secondRuby.Dependencies[0] == secondLibyaml, "dependencies keep the packages of their release")
+	assert.Equal("second", second.Jobs[0].Packages[1].Release.Name, "shared packages keep their release")
+
+	unshared := &Package{Name: "unshared", Fingerprint: "ddd"}
+	assert.True(unshared.Canonical() == unshared)
+}
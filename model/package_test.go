@@ -97,3 +97,43 @@ func TestPackageExtractOk(t *testing.T) {
 	assert.Nil(util.ValidatePath(packageDir, true, ""))
 	assert.Nil(util.ValidatePath(filepath.Join(packageDir, "packaging"), false, ""))
 }
+
+func TestPackageCompiledSize(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := ioutil.TempDir("", "fissile-package-size")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	pkg := &Package{Name: "pkg", Fingerprint: "abc"}
+	compiledDir := pkg.GetPackageCompiledDir(workDir)
+	assert.NoError(os.MkdirAll(filepath.Join(compiledDir, "bin"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(compiledDir, "bin", "tool"), make([]byte, 1000), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(compiledDir, "README"), make([]byte, 24), 0644))
+
+	// Not recorded yet, so it gets measured
+	size, err := pkg.GetCompiledSize(workDir)
+	assert.NoError(err)
+	assert.Equal(int64(1024), size)
+
+	// Once recorded, the recorded size is used
+	assert.NoError(ioutil.WriteFile(pkg.GetPackageCompiledSizePath(workDir), []byte("2048"), 0644))
+	size, err = pkg.GetCompiledSize(workDir)
+	assert.NoError(err)
+	assert.Equal(int64(2048), size)
+
+	role := &Role{
+		Name: "myrole",
+		Jobs: Jobs{
+			&Job{Packages: Packages{pkg}},
+			&Job{Packages: Packages{pkg}},
+		},
+		Run: &RoleRun{ImageSizeBudget: 1},
+	}
+	assert.NoError(role.CheckImageSizeBudget(workDir), "Packages used by several jobs should count once")
+
+	assert.NoError(ioutil.WriteFile(pkg.GetPackageCompiledSizePath(workDir), []byte("2097152"), 0644))
+	assert.EqualError(role.CheckImageSizeBudget(workDir), "Role myrole: compiled packages use 2.0MB, over the image size budget of 1MB")
+}
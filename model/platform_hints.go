@@ -0,0 +1,69 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// These are the platform hints roles can give, in the spirit of the
+// vm_extensions of BOSH: what kind of load balancer their service needs, in
+// terms no cloud in particular uses. The kube platform mappers translate them
+// into the annotations of the clouds.
+const (
+	PlatformHintLoadBalancer     = "load-balancer"      // internal or external
+	PlatformHintLoadBalancerType = "load-balancer-type" // classic or network
+	PlatformHintIdleTimeout      = "idle-timeout"       // In seconds
+)
+
+// platformHintValues are the values of the platform hints; hints with no
+// values take a positive number
+var platformHintValues = map[string][]string{
+	PlatformHintLoadBalancer:     {"internal", "external"},
+	PlatformHintLoadBalancerType: {"classic", "network"},
+	PlatformHintIdleTimeout:      nil,
+}
+
+// validatePlatformHints checks the platform hints of a role; they are about
+// the load balancer of its service, so it needs exposed ports and the
+// LoadBalancer service type
+func (r *RoleRun) validatePlatformHints() error {
+	if len(r.PlatformHints) == 0 {
+		return nil
+	}
+	if len(r.ExposedPorts) == 0 {
+		return fmt.Errorf("Platform hints need exposed ports")
+	}
+	if r.Service.GetType() != ServiceTypeLoadBalancer {
+		return fmt.Errorf("Platform hints need the %s service type", ServiceTypeLoadBalancer)
+	}
+
+	names := make([]string, 0, len(r.PlatformHints))
+	for name := range r.PlatformHints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := r.PlatformHints[name]
+		values, ok := platformHintValues[name]
+		switch {
+		case !ok:
+			return fmt.Errorf("Unknown platform hint %s", name)
+		case values == nil:
+			if number, err := strconv.Atoi(value); err != nil || number <= 0 {
+				return fmt.Errorf("Platform hint %s should be a positive number, not '%s'", name, value)
+			}
+		default:
+			valid := false
+			for _, v := range values {
+				valid = valid || v == value
+			}
+			if !valid {
+				return fmt.Errorf("Invalid platform hint %s: %s, expected one of %v", name, value, values)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePlatformHints(t *testing.T) {
+	assert := assert.New(t)
+
+	ports := []*RoleRunExposedPort{{Name: "http", External: "80", Internal: "8080"}}
+	loadBalancer := &RoleRunService{Type: ServiceTypeLoadBalancer}
+	samples := []struct {
+		desc    string
+		hints   map[string]string
+		ports   []*RoleRunExposedPort
+		service *RoleRunService
+		err     string
+	}{
+		{
+			desc: "Roles without platform hints are valid",
+		},
+		{
+			desc: "Known hints are valid",
+			hints: map[string]string{
+				PlatformHintLoadBalancer:     "internal",
+				PlatformHintLoadBalancerType: "network",
+				PlatformHintIdleTimeout:      "300",
+			},
+			ports:   ports,
+			service: loadBalancer,
+		},
+		{
+			desc:  "Hints are about the service of the role",
+			hints: map[string]string{PlatformHintLoadBalancer: "internal"},
+			err:   "Platform hints need exposed ports",
+		},
+		{
+			desc:  "Hints are about load balancers",
+			hints: map[string]string{PlatformHintLoadBalancer: "internal"},
+			ports: ports,
+			err:   "Platform hints need the LoadBalancer service type",
+		},
+		{
+			desc:    "Hints are known",
+			hints:   map[string]string{"load-balancer-sku": "standard"},
+			ports:   ports,
+			service: loadBalancer,
+			err:     "Unknown platform hint load-balancer-sku",
+		},
+		{
+			desc:    "Hint values are known",
+			hints:   map[string]string{PlatformHintLoadBalancer: "private"},
+			ports:   ports,
+			service: loadBalancer,
+			err:     "Invalid platform hint load-balancer: private, expected one of [internal external]",
+		},
+		{
+			desc:    "Hints are numbers",
+			hints:   map[string]string{PlatformHintIdleTimeout: "5m"},
+			ports:   ports,
+			service: loadBalancer,
+			err:     "Platform hint idle-timeout should be a positive number, not '5m'",
+		},
+	}
+
+	for _, sample := range samples {
+		run := RoleRun{PlatformHints: sample.hints, ExposedPorts: sample.ports, Service: sample.service}
+		err := run.validatePlatformHints()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
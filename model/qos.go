@@ -0,0 +1,83 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Quality of service classes of the pods of roles
+const (
+	QoSBurstable  = "burstable"  // Requests, and limits only as configured
+	QoSGuaranteed = "guaranteed" // Limits equal to the requests
+)
+
+// HugePageSizes are the sizes of huge pages roles can use, in MB
+var HugePageSizes = map[string]int{
+	"2Mi": 2,
+	"1Gi": 1024,
+}
+
+// GetQoS returns the quality of service class of the pods of the role. Pinned
+// CPUs are only given to guaranteed pods.
+func (r *RoleRun) GetQoS() string {
+	if r.QoS == "" && r.CPUPinning {
+		return QoSGuaranteed
+	}
+	if r.QoS == "" {
+		return QoSBurstable
+	}
+	return r.QoS
+}
+
+// GetHugePageSizes returns the sizes of the huge pages the role uses, sorted
+func (r *RoleRun) GetHugePageSizes() []string {
+	sizes := make([]string, 0, len(r.HugePages))
+	for size := range r.HugePages {
+		sizes = append(sizes, size)
+	}
+	sort.Strings(sizes)
+	return sizes
+}
+
+// validateQoS checks the quality of service class, CPU pinning and huge pages
+// of a role, and normalizes the class
+func (r *RoleRun) validateQoS() error {
+	r.QoS = strings.ToLower(r.QoS)
+	switch r.QoS {
+	case "", QoSBurstable, QoSGuaranteed:
+	default:
+		return fmt.Errorf("Invalid qos %s, expected %s or %s", r.QoS, QoSBurstable, QoSGuaranteed)
+	}
+
+	if r.CPUPinning && r.GetQoS() != QoSGuaranteed {
+		return fmt.Errorf("CPU pinning needs the %s qos", QoSGuaranteed)
+	}
+	if r.CPUPinning && r.VirtualCPUs == 0 {
+		return fmt.Errorf("CPU pinning needs virtual-cpus")
+	}
+	if r.GetQoS() == QoSGuaranteed {
+		if r.Memory == 0 || r.VirtualCPUs == 0 {
+			return fmt.Errorf("The %s qos needs memory and virtual-cpus", QoSGuaranteed)
+		}
+		// Sidecars have no resources, which would make the pods burstable
+		if len(r.Sidecars) > 0 {
+			return fmt.Errorf("The %s qos can't be used with sidecars", QoSGuaranteed)
+		}
+	}
+
+	for _, size := range r.GetHugePageSizes() {
+		pageSize, ok := HugePageSizes[size]
+		if !ok {
+			return fmt.Errorf("Invalid huge page size %s, expected 2Mi or 1Gi", size)
+		}
+		if r.HugePages[size] <= 0 || r.HugePages[size]%pageSize != 0 {
+			return fmt.Errorf("Huge pages of size %s should be a positive multiple of %d MB", size, pageSize)
+		}
+	}
+	if len(r.HugePages) > 0 && r.Memory == 0 && r.VirtualCPUs == 0 {
+		return fmt.Errorf("Huge pages need memory or virtual-cpus")
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateQoS(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		run  RoleRun
+		qos  string
+		err  string
+	}{
+		{
+			desc: "Pods are burstable by default",
+			qos:  QoSBurstable,
+		},
+		{
+			desc: "Guaranteed pods have memory and CPUs",
+			run:  RoleRun{QoS: "Guaranteed", Memory: 256, VirtualCPUs: 2},
+			qos:  QoSGuaranteed,
+		},
+		{
+			desc: "Pinned CPUs make the pods guaranteed",
+			run:  RoleRun{CPUPinning: true, Memory: 256, VirtualCPUs: 2},
+			qos:  QoSGuaranteed,
+		},
+		{
+			desc: "Huge pages of both sizes",
+			run:  RoleRun{Memory: 256, HugePages: map[string]int{"2Mi": 512, "1Gi": 2048}},
+			qos:  QoSBurstable,
+		},
+		{
+			desc: "Unknown classes are invalid",
+			run:  RoleRun{QoS: "best-effort"},
+			err:  "Invalid qos best-effort, expected burstable or guaranteed",
+		},
+		{
+			desc: "Pinned CPUs need the guaranteed class",
+			run:  RoleRun{QoS: QoSBurstable, CPUPinning: true, Memory: 256, VirtualCPUs: 2},
+			err:  "CPU pinning needs the guaranteed qos",
+		},
+		{
+			desc: "Pinned CPUs need CPUs",
+			run:  RoleRun{CPUPinning: true, Memory: 256},
+			err:  "CPU pinning needs virtual-cpus",
+		},
+		{
+			desc: "Guaranteed pods need memory",
+			run:  RoleRun{QoS: QoSGuaranteed, VirtualCPUs: 2},
+			err:  "The guaranteed qos needs memory and virtual-cpus",
+		},
+		{
+			desc: "Guaranteed pods have no sidecars",
+			run:  RoleRun{QoS: QoSGuaranteed, Memory: 256, VirtualCPUs: 2, Sidecars: []*RoleRunSidecar{{Name: "metrics"}}},
+			err:  "The guaranteed qos can't be used with sidecars",
+		},
+		{
+			desc: "Huge pages have a known size",
+			run:  RoleRun{Memory: 256, HugePages: map[string]int{"4Ki": 512}},
+			err:  "Invalid huge page size 4Ki, expected 2Mi or 1Gi",
+		},
+		{
+			desc: "Huge pages are whole pages",
+			run:  RoleRun{Memory: 256, HugePages: map[string]int{"1Gi": 512}},
+			err:  "Huge pages of size 1Gi should be a positive multiple of 1024 MB",
+		},
+		{
+			desc: "Huge pages need memory or CPUs",
+			run:  RoleRun{HugePages: map[string]int{"2Mi": 512}},
+			err:  "Huge pages need memory or virtual-cpus",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.run.validateQoS()
+		if sample.err != "" {
+			assert.EqualError(err, sample.err, sample.desc)
+			continue
+		}
+		if assert.NoError(err, sample.desc) {
+			assert.Equal(sample.qos, sample.run.GetQoS(), sample.desc)
+		}
+	}
+}
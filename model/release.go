@@ -16,6 +16,7 @@ import (
 type Release struct {
 	Jobs               Jobs
 	Packages           Packages
+	Blobs              []*Blob // Source archives of the packages, from config/blobs.yml
 	License            ReleaseLicense
 	Name               string
 	UncommittedChanges bool
@@ -24,7 +25,12 @@ type Release struct {
 	Path               string
 	DevBOSHCacheDir    string
 
+	// StemcellConstraints are the stemcells the release says it works with;
+	// it is empty for most source releases
+	StemcellConstraints []*Stemcell
+
 	manifest map[interface{}]interface{}
+	options  ReleaseOptions
 }
 
 const (
@@ -33,6 +39,10 @@ const (
 	manifestFile = "release.MF"
 )
 
+// DefaultLicenseSizeLimit is the default license size limit of releases, 1MiB;
+// see ReleaseOptions
+const DefaultLicenseSizeLimit = 1024 * 1024
+
 // yamlBinaryRegexp is the regexp used to look for the "!binary" YAML tag; see
 // loadMetadata() where it is used.
 var yamlBinaryRegexp = regexp.MustCompile(`([^!])!binary \|-\n`)
@@ -156,18 +166,17 @@ func (r *Release) loadPackages() (err error) {
 	return nil
 }
 
-func (r *Release) loadDependenciesForPackages() error {
-	for _, pkg := range r.Packages {
-		if err := pkg.loadPackageDependencies(); err != nil {
-			return err
-		}
+// loadLicense reads the license file of the release. License files above the
+// license size limit of the release are not loaded into memory; only their
+// hashes are kept. Binary license files are never loaded.
+func (r *Release) loadLicense() error {
+	sizeLimit := r.options.LicenseSizeLimit
+	if sizeLimit == 0 {
+		sizeLimit = DefaultLicenseSizeLimit
 	}
 
-	return nil
-}
-
-func (r *Release) loadLicense() error {
 	r.License.Files = make(map[string][]byte)
+	r.License.Hashes = make(map[string]string)
 
 	licenseFile, err := os.Open(r.licensePath())
 	if os.IsNotExist(err) {
@@ -179,7 +188,7 @@ func (r *Release) loadLicense() error {
 	}
 	defer licenseFile.Close()
 
-	licenseContents, err := ioutil.ReadFile(r.licensePath())
+	licenseContents, licenseHash, err := util.ReadLicenseFile(licenseFile, sizeLimit)
 	if err != nil {
 		return err
 	}
@@ -189,7 +198,11 @@ func (r *Release) loadLicense() error {
 		return err
 	}
 
-	r.License.Files[licenseFilePath] = licenseContents
+	if licenseContents == nil {
+		r.License.Hashes[licenseFilePath] = licenseHash
+	} else {
+		r.License.Files[licenseFilePath] = licenseContents
+	}
 
 	return nil
 }
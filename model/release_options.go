@@ -0,0 +1,8 @@
+package model
+
+// ReleaseOptions are the options releases are loaded with
+type ReleaseOptions struct {
+	ArchiveMirrors   []string // Mirrors of the BOSH cache for the missing archives; see fetchMirroredArchive
+	LicenseSizeLimit int64    // In bytes, for the license files; DefaultLicenseSizeLimit when 0. See loadLicense
+	ScratchDir       string   // Directory the jobs are extracted in; the system temporary directory when empty
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// releaseVersionsKey is the key of the release pins in role manifests
+const releaseVersionsKey = "release-versions"
+
+// validateReleaseVersions checks that the loaded releases have the versions
+// the role manifest pins them to. Dev releases match the final version they
+// are based on, e.g. 12+dev.3 matches a pin to 12.
+func (m *RoleManifest) validateReleaseVersions(releases map[string]*Release) error {
+	names := make([]string, 0, len(m.ReleaseVersions))
+	for name := range m.ReleaseVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		release, ok := releases[name]
+		if !ok {
+			continue
+		}
+		pinned := m.ReleaseVersions[name]
+		if release.Version != pinned && finalReleaseVersion(release.Version) != pinned {
+			return fmt.Errorf("Release %s is pinned to version %s by the role manifest, but version %s is loaded",
+				name, pinned, release.Version)
+		}
+	}
+	return nil
+}
+
+// finalReleaseVersion returns the final version a dev version is based on
+func finalReleaseVersion(version string) string {
+	return strings.SplitN(version, "+", 2)[0]
+}
+
+// UpdateReleaseVersions sets the versions of the releases the role manifest
+// at manifestFilePath pins, keeping the rest of the file as it is. Releases
+// the manifest doesn't pin are left out; the names of the releases whose pins
+// changed are returned, sorted.
+func UpdateReleaseVersions(manifestFilePath string, versions map[string]string) ([]string, error) {
+	contents, err := ioutil.ReadFile(manifestFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest RoleManifest
+	if err := yaml.Unmarshal(contents, &manifest); err != nil {
+		return nil, err
+	}
+
+	pins := make(map[string]string, len(manifest.ReleaseVersions))
+	var updated []string
+	for name, pinned := range manifest.ReleaseVersions {
+		pins[name] = pinned
+		if version, ok := versions[name]; ok && version != pinned {
+			pins[name] = version
+			updated = append(updated, name)
+		}
+	}
+	if len(updated) == 0 {
+		return nil, nil
+	}
+	sort.Strings(updated)
+
+	contents, err = SetYAMLKeys(contents, yaml.MapSlice{{Key: releaseVersionsKey, Value: pins}})
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(manifestFilePath, contents, 0644); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
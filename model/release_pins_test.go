@@ -0,0 +1,78 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReleaseVersions(t *testing.T) {
+	assert := assert.New(t)
+
+	releases := map[string]*Release{
+		"cf":  {Name: "cf", Version: "250"},
+		"ntp": {Name: "ntp", Version: "4+dev.2"},
+	}
+
+	samples := []struct {
+		desc string
+		pins map[string]string
+		err  string
+	}{
+		{
+			desc: "releases need no pins",
+		},
+		{
+			desc: "dev releases match the version they are based on",
+			pins: map[string]string{"cf": "250", "ntp": "4", "other": "1"},
+		},
+		{
+			desc: "other versions are rejected",
+			pins: map[string]string{"cf": "251"},
+			err:  "Release cf is pinned to version 251 by the role manifest, but version 250 is loaded",
+		},
+	}
+
+	for _, sample := range samples {
+		err := (&RoleManifest{ReleaseVersions: sample.pins}).validateReleaseVersions(releases)
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestUpdateReleaseVersions(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-release-pins")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	manifestPath := filepath.Join(tempDir, "role-manifest.yml")
+	manifest := "roles: []\nrelease-versions:\n  # CF itself\n  cf: \"250\"\n  ntp: \"4\"\n"
+	if !assert.NoError(ioutil.WriteFile(manifestPath, []byte(manifest), 0644)) {
+		return
+	}
+
+	updated, err := UpdateReleaseVersions(manifestPath, map[string]string{"cf": "251", "ntp": "4", "nats": "2"})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal([]string{"cf"}, updated, "only the pins that change are updated, and releases without pins stay unpinned")
+
+	contents, err := ioutil.ReadFile(manifestPath)
+	if assert.NoError(err) {
+		assert.Equal("roles: []\nrelease-versions:\n  # CF itself\n  cf: \"251\"\n  ntp: \"4\"\n", string(contents))
+	}
+
+	updated, err = UpdateReleaseVersions(manifestPath, map[string]string{"nats": "2"})
+	assert.NoError(err)
+	assert.Empty(updated)
+}
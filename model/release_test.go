@@ -288,6 +288,24 @@ func TestReleaseExtractedLicense(t *testing.T) {
 	assert.Equal([]byte("LICENSE file contents"), release.License.Files["LICENSE"])
 }
 
+func TestReleaseLicenseSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/extracted-license")
+	releasePathBoshCache := filepath.Join(releasePath, "bosh-cache")
+	release, err := NewDevReleaseWithOptions(releasePath, "", "", releasePathBoshCache, ReleaseOptions{LicenseSizeLimit: 4})
+
+	assert.Nil(err, "Release with a large license should be valid")
+	assert.Empty(release.License.Files)
+	assert.Len(release.License.Hashes, 1)
+	assert.NotEmpty(release.License.Hashes["LICENSE"])
+}
+
 func TestReleaseMissingLicense(t *testing.T) {
 	t.Parallel()
 
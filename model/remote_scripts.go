@@ -0,0 +1,147 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RemoteScriptsDir is the directory remote scripts are named in, in the
+// image, as remote/<digest prefix>/<name>
+const RemoteScriptsDir = "remote"
+
+// remoteScriptClient downloads remote scripts, failing the download of a
+// stalled server instead of hanging it; tests replace it
+var remoteScriptClient = &http.Client{Timeout: time.Minute}
+
+var remoteScriptDigestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// isRemoteScript returns whether a role script is given by a URL
+func isRemoteScript(script string) bool {
+	return strings.HasPrefix(script, "https://") || strings.HasPrefix(script, "http://")
+}
+
+// remoteScriptPath returns the path a remote script with the given digest is
+// downloaded to, by digest, in the cache directory; scripts are kept in the
+// system temporary directory without one
+func remoteScriptPath(cacheDir, digest string) string {
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.TempDir(), "fissile-scripts")
+	}
+	return filepath.Join(cacheDir, strings.TrimPrefix(digest, "sha256:"))
+}
+
+// fetchRemoteScripts downloads the scripts of roles given as HTTPS URLs, and
+// names them remote/<digest prefix>/<name> in the scripts of the roles. A
+// remote script is pinned by the sha256 of its contents, as the fragment of
+// its URL, e.g. https://example.com/bootstrap.sh#sha256:<hex>; it is only
+// downloaded once, and kept in the remote script cache directory of the
+// options of the manifest as long as it matches.
+func (m *RoleManifest) fetchRemoteScripts() error {
+	for _, role := range m.Roles {
+		for _, scripts := range []*[]string{&role.EnvironScripts, &role.Scripts, &role.PostConfigScripts} {
+			// Roles may share the scripts of the role they extend, so the
+			// names go into a list of their own
+			var names []string
+			for _, script := range *scripts {
+				if isRemoteScript(script) {
+					var err error
+					if script, err = role.fetchRemoteScript(script, m.options.RemoteScriptCacheDir); err != nil {
+						return fmt.Errorf("Role %s: %s", role.Name, err.Error())
+					}
+				}
+				names = append(names, script)
+			}
+			*scripts = names
+		}
+	}
+	return nil
+}
+
+// fetchRemoteScript downloads a remote script into the cache directory, unless
+// it already has been, and returns its name in the image
+func (r *Role) fetchRemoteScript(script, cacheDir string) (string, error) {
+	scriptURL, err := url.Parse(script)
+	if err != nil {
+		return "", fmt.Errorf("Invalid remote script %s: %s", script, err.Error())
+	}
+	if scriptURL.Scheme != "https" {
+		return "", fmt.Errorf("Remote script %s is not downloaded over https", script)
+	}
+	digest := scriptURL.Fragment
+	if !remoteScriptDigestPattern.MatchString(digest) {
+		return "", fmt.Errorf("Remote script %s has no digest, expected <url>#sha256:<hex>", script)
+	}
+	scriptURL.Fragment = ""
+	base := path.Base(scriptURL.Path)
+	if base == "/" || base == "." {
+		return "", fmt.Errorf("Remote script %s has no file name", script)
+	}
+
+	// Cached scripts are checked against their digest too, so that a script
+	// changed in the cache is downloaded again instead of going into images
+	scriptPath := remoteScriptPath(cacheDir, digest)
+	cached, err := ioutil.ReadFile(scriptPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err != nil || verifyDigest(cached, digest) != nil {
+		if err := downloadRemoteScript(scriptURL.String(), scriptPath, digest); err != nil {
+			return "", fmt.Errorf("Error downloading script %s: %s", scriptURL.String(), err.Error())
+		}
+	}
+
+	name := path.Join(RemoteScriptsDir, strings.TrimPrefix(digest, "sha256:")[:12], base)
+	if r.fetchedScripts == nil {
+		r.fetchedScripts = map[string]string{}
+	}
+	r.fetchedScripts[name] = scriptPath
+	return name, nil
+}
+
+// downloadRemoteScript downloads a script to scriptPath, if its contents match
+// the digest; like the archives of the archive mirrors, it is written next to
+// scriptPath first, so a failed download never leaves a broken script behind
+func downloadRemoteScript(scriptURL, scriptPath, digest string) error {
+	response, err := remoteScriptClient.Get(scriptURL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", response.Status)
+	}
+	contents, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if err := verifyDigest(contents, digest); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(scriptPath), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(scriptPath), filepath.Base(scriptPath)+".download")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(contents)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), scriptPath)
+}
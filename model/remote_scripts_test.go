@@ -0,0 +1,127 @@
+package model
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchRemoteScripts(t *testing.T) {
+	assert := assert.New(t)
+
+	cacheDir, err := ioutil.TempDir("", "fissile-scripts")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(cacheDir)
+
+	script := "#!/bin/sh\necho bootstrap\n"
+	changed := "#!/bin/sh\necho changed\n"
+	sum := sha256.Sum256([]byte(script))
+	changedSum := sha256.Sum256([]byte(changed))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	downloads := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/scripts/bootstrap.sh":
+			downloads++
+			w.Write([]byte(script))
+		case "/scripts/changed.sh":
+			w.Write([]byte(changed))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	savedClient := remoteScriptClient
+	remoteScriptClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	defer func() { remoteScriptClient = savedClient }()
+
+	remote := server.URL + "/scripts/bootstrap.sh#" + digest
+	newManifest := func(scripts ...string) *RoleManifest {
+		base := &Role{
+			Name:          "base",
+			Scripts:       append([]string{"local.sh"}, scripts...),
+			rolesManifest: &RoleManifest{manifestFilePath: "/manifests/role-manifest.yml"},
+		}
+		derived := *base
+		derived.Name = "derived"
+		return &RoleManifest{
+			Roles:   Roles{base, &derived},
+			options: RoleManifestOptions{RemoteScriptCacheDir: cacheDir},
+		}
+	}
+
+	manifest := newManifest(remote)
+	if !assert.NoError(manifest.fetchRemoteScripts()) {
+		return
+	}
+	name := "remote/" + hex.EncodeToString(sum[:])[:12] + "/bootstrap.sh"
+	for _, role := range manifest.Roles {
+		assert.Equal([]string{"local.sh", name}, role.Scripts, role.Name)
+		assert.Equal(map[string]string{
+			"local.sh": filepath.Join("/manifests", "local.sh"),
+			name:       filepath.Join(cacheDir, hex.EncodeToString(sum[:])),
+		}, role.GetScriptPaths(), role.Name)
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(cacheDir, hex.EncodeToString(sum[:])))
+	assert.NoError(err)
+	assert.Equal(script, string(contents))
+	assert.Equal(1, downloads, "Remote scripts are downloaded once")
+
+	// Scripts changed in the cache are downloaded again
+	if !assert.NoError(ioutil.WriteFile(filepath.Join(cacheDir, hex.EncodeToString(sum[:])), []byte(changed), 0644)) {
+		return
+	}
+	if !assert.NoError(newManifest(remote).fetchRemoteScripts()) {
+		return
+	}
+	contents, err = ioutil.ReadFile(filepath.Join(cacheDir, hex.EncodeToString(sum[:])))
+	assert.NoError(err)
+	assert.Equal(script, string(contents))
+	assert.Equal(2, downloads)
+
+	// Not in the cache
+	missingDigest := "sha256:" + hex.EncodeToString(make([]byte, 32))
+	samples := []struct {
+		desc   string
+		script string
+		err    string
+	}{
+		{
+			desc:   "Remote scripts are pinned by their digest",
+			script: server.URL + "/scripts/bootstrap.sh",
+			err:    "Role base: Remote script " + server.URL + "/scripts/bootstrap.sh has no digest, expected <url>#sha256:<hex>",
+		},
+		{
+			desc:   "Remote scripts are downloaded over https",
+			script: "http://example.com/bootstrap.sh#" + digest,
+			err:    "Role base: Remote script http://example.com/bootstrap.sh#" + digest + " is not downloaded over https",
+		},
+		{
+			desc:   "Remote scripts have to match their digest",
+			script: server.URL + "/scripts/changed.sh#" + missingDigest,
+			err: "Role base: Error downloading script " + server.URL + "/scripts/changed.sh: digest sha256:" +
+				hex.EncodeToString(changedSum[:]) + " does not match the expected " + missingDigest,
+		},
+		{
+			desc:   "Remote scripts have to exist",
+			script: server.URL + "/scripts/missing.sh#" + missingDigest,
+			err:    "Role base: Error downloading script " + server.URL + "/scripts/missing.sh: 404 Not Found",
+		},
+	}
+
+	for _, sample := range samples {
+		err := newManifest(sample.script).fetchRemoteScripts()
+		assert.EqualError(err, sample.err, sample.desc)
+	}
+}
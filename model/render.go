@@ -0,0 +1,76 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hpcloud/fissile/mustache"
+
+	"gopkg.in/yaml.v2"
+)
+
+// templateVariablePattern matches the variables of configuration templates,
+// as opposed to their sections and comments
+var templateVariablePattern = regexp.MustCompile(`\(\(\s*([A-Za-z0-9_]+)\s*\)\)`)
+
+// JobProperties returns the properties a job of the role is configured with,
+// as configgin renders them at startup: the defaults of the job spec,
+// overridden by the light opinions unless they are dark, and by the
+// configuration templates of the role, rendered with the given values of the
+// configuration variables. Variables without a value render empty.
+func (r *Role) JobProperties(job *Job, lightOpinionsPath, darkOpinionsPath string, values map[string]string) (map[string]interface{}, error) {
+	opinions, err := newOpinions(lightOpinionsPath, darkOpinionsPath)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := job.getPropertiesForJob(opinions)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key := range r.Configuration.Templates {
+		if strings.HasPrefix(key, "properties.") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, "properties.")
+		if _, err := job.getProperty(name); err != nil {
+			continue
+		}
+
+		rendered, err := RenderConfigurationTemplate(r.Configuration.Templates[key], values)
+		if err != nil {
+			return nil, fmt.Errorf("Role %s: Error rendering configuration template %s: %s", r.Name, key, err.Error())
+		}
+
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(rendered), &value); err != nil {
+			value = rendered
+		}
+		if err := insertConfig(properties, name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return properties, nil
+}
+
+// RenderConfigurationTemplate renders a configuration template of the role
+// manifest with the given values of its variables. Unlike mustache does by
+// default, the values are not HTML escaped.
+func RenderConfigurationTemplate(template string, values map[string]string) (string, error) {
+	raw := templateVariablePattern.ReplaceAllString(template, "(({$1}))")
+
+	parsed, err := mustache.ParseString(fmt.Sprintf("{{=(( ))=}}%s", raw))
+	if err != nil {
+		return "", err
+	}
+
+	return parsed.Render(values), nil
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderConfigurationTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	values := map[string]string{"HOST": "a&b", "PORT": "80"}
+
+	rendered, err := RenderConfigurationTemplate("((HOST)):(( PORT ))", values)
+	assert.NoError(err)
+	assert.Equal("a&b:80", rendered)
+
+	rendered, err = RenderConfigurationTemplate("((#PORT))port ((PORT))((/PORT))((^USER))nobody((/USER))", values)
+	assert.NoError(err)
+	assert.Equal("port 80nobody", rendered)
+
+	_, err = RenderConfigurationTemplate("((#PORT))", values)
+	assert.Error(err)
+}
+
+func TestRoleJobProperties(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/render.yml")
+	rolesManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	role := rolesManifest.LookupRole("myrole")
+	lightOpinionsPath := filepath.Join(workDir, "../test-assets/tor-opinions/opinions.yml")
+	darkOpinionsPath := filepath.Join(workDir, "../test-assets/tor-opinions/dark-opinions.yml")
+
+	properties, err := role.JobProperties(role.Jobs[0], lightOpinionsPath, darkOpinionsPath, map[string]string{"TOR_HOSTNAME": "myrole"})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(map[string]interface{}{
+		"tor": map[string]interface{}{
+			"hostname":                "myrole.onion",
+			"private_key":             "none",
+			"client_keys":             nil,
+			"hashed_control_password": nil,
+		},
+	}, properties)
+}
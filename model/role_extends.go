@@ -0,0 +1,260 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// resolveExtends makes the roles that extend another role inherit its
+// definition, and drops the abstract roles, which only exist to be extended.
+// A role inherits the jobs of its base role, where jobs it lists itself
+// replace the inherited jobs of the same name, or are added after them; its
+// scripts, type, tags, OS packages, bundles and image, unless it sets them;
+// the configuration templates of its base role, which it overrides key by
+// key; and the run settings it doesn't set, even to their zero value. What a
+// role inherits is a copy, which can't change its base role. Base roles may
+// extend other roles in turn.
+func (m *RoleManifest) resolveExtends() error {
+	rolesByName := make(map[string]*Role, len(m.Roles))
+	for _, role := range m.Roles {
+		rolesByName[role.Name] = role
+	}
+
+	resolved := map[string]bool{}
+	var resolve func(role *Role, chain []string) error
+	resolve = func(role *Role, chain []string) error {
+		if resolved[role.Name] || role.Extends == "" {
+			resolved[role.Name] = true
+			return nil
+		}
+		for _, name := range chain {
+			if name == role.Name {
+				return fmt.Errorf("Role %s extends itself through %s", role.Name, strings.Join(append(chain, role.Name), " -> "))
+			}
+		}
+
+		base, ok := rolesByName[role.Extends]
+		if !ok {
+			return fmt.Errorf("Role %s extends unknown role %s", role.Name, role.Extends)
+		}
+		if err := resolve(base, append(chain, role.Name)); err != nil {
+			return err
+		}
+
+		role.inherit(base)
+		resolved[role.Name] = true
+		return nil
+	}
+
+	roles := make(Roles, 0, len(m.Roles))
+	for _, role := range m.Roles {
+		if err := resolve(role, nil); err != nil {
+			return err
+		}
+		if !role.Abstract {
+			roles = append(roles, role)
+		}
+	}
+	m.Roles = roles
+
+	return nil
+}
+
+// inherit fills in the definition of the role from its base role, which has
+// been resolved already; see resolveExtends
+func (r *Role) inherit(base *Role) {
+	var jobs []*roleJob
+	for _, baseJob := range base.JobNameList {
+		job := baseJob.copy()
+		for _, roleJob := range r.JobNameList {
+			if roleJob.Name == baseJob.Name {
+				job = roleJob
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	for _, roleJob := range r.JobNameList {
+		if base.lookupRoleJob(roleJob.Name) == nil {
+			jobs = append(jobs, roleJob)
+		}
+	}
+	r.JobNameList = jobs
+
+	if r.EnvironScripts == nil {
+		r.EnvironScripts = deepCopy(base.EnvironScripts).([]string)
+	}
+	if r.Scripts == nil {
+		r.Scripts = deepCopy(base.Scripts).([]string)
+	}
+	if r.PostConfigScripts == nil {
+		r.PostConfigScripts = deepCopy(base.PostConfigScripts).([]string)
+	}
+	if r.Type == "" {
+		r.Type = base.Type
+	}
+	if r.Tags == nil {
+		r.Tags = deepCopy(base.Tags).([]string)
+	}
+	if r.OSPackages == nil {
+		r.OSPackages = deepCopy(base.OSPackages).(*RoleOSPackages)
+	}
+	if r.Dockerfile == "" {
+		r.Dockerfile = base.Dockerfile
+	}
+	if r.Bundles == nil {
+		r.Bundles = deepCopy(base.Bundles).([]string)
+	}
+	if r.Image == "" {
+		r.Image = base.Image
+	}
+
+	if base.Configuration != nil {
+		configuration := base.Configuration.copy()
+		if r.Configuration != nil {
+			for key, template := range r.Configuration.Templates {
+				configuration.Templates[key] = template
+				if dir, ok := r.Configuration.templateDirs[key]; ok {
+					configuration.templateDirs[key] = dir
+				} else {
+					delete(configuration.templateDirs, key)
+				}
+			}
+			configuration.Variables = append(configuration.Variables, r.Configuration.Variables...)
+		}
+		r.Configuration = configuration
+	}
+
+	if base.Run != nil {
+		if r.Run == nil {
+			r.Run = &RoleRun{}
+		}
+		// Take the fields of the base role that the role doesn't set, either
+		// in the role manifest or to a value other than their zero value
+		run := reflect.ValueOf(r.Run).Elem()
+		baseRun := reflect.ValueOf(base.Run).Elem()
+		for i := 0; i < run.NumField(); i++ {
+			field := run.Field(i)
+			if !field.CanSet() || r.Run.setsField(i) {
+				continue
+			}
+			field.Set(deepCopyValue(baseRun.Field(i)))
+		}
+	}
+}
+
+// UnmarshalYAML reads the run settings of a role, recording which of them the
+// role manifest sets, so that a role extending another one can override them
+// with their zero value too
+func (r *RoleRun) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plainRoleRun RoleRun
+	if err := unmarshal((*plainRoleRun)(r)); err != nil {
+		return err
+	}
+	var keys map[string]interface{}
+	if err := unmarshal(&keys); err != nil {
+		return err
+	}
+	r.setKeys = make(map[string]bool, len(keys))
+	for key := range keys {
+		r.setKeys[key] = true
+	}
+	return nil
+}
+
+// setsField reports whether the run settings set the field with the given
+// index, in the role manifest or to a value other than its zero value
+func (r *RoleRun) setsField(i int) bool {
+	key := strings.Split(reflect.TypeOf(*r).Field(i).Tag.Get("yaml"), ",")[0]
+	if r.setKeys[key] {
+		return true
+	}
+	field := reflect.ValueOf(*r).Field(i)
+	return !reflect.DeepEqual(field.Interface(), reflect.Zero(field.Type()).Interface())
+}
+
+// lookupRoleJob returns the job of the role with the given name, as listed in
+// the role manifest, or nil if there is none
+func (r *Role) lookupRoleJob(name string) *roleJob {
+	for _, roleJob := range r.JobNameList {
+		if roleJob.Name == name {
+			return roleJob
+		}
+	}
+	return nil
+}
+
+// copy returns a copy of the job, so that a role extending another one can't
+// change the jobs of its base role
+func (j *roleJob) copy() *roleJob {
+	return deepCopy(j).(*roleJob)
+}
+
+// copy returns a copy of the configuration, with templates and variables of
+// its own
+func (c *Configuration) copy() *Configuration {
+	configuration := deepCopy(c).(*Configuration)
+	if configuration.Templates == nil {
+		configuration.Templates = map[string]string{}
+	}
+	configuration.templateDirs = map[string]string{}
+	for key, dir := range c.templateDirs {
+		configuration.templateDirs[key] = dir
+	}
+	return configuration
+}
+
+// deepCopy returns a copy of a value sharing no pointers, slices or maps with
+// it, so that the roles extending a base role can't change it
+func deepCopy(value interface{}) interface{} {
+	return deepCopyValue(reflect.ValueOf(value)).Interface()
+}
+
+// deepCopyValue is deepCopy for reflected values; unexported fields of
+// structs are copied as they are
+func deepCopyValue(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(deepCopyValue(value.Elem()))
+		return copied
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopyValue(value.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeMap(value.Type())
+		for _, key := range value.MapKeys() {
+			copied.SetMapIndex(key, deepCopyValue(value.MapIndex(key)))
+		}
+		return copied
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(deepCopyValue(value.Elem()))
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if field := copied.Field(i); field.CanSet() {
+				field.Set(deepCopyValue(value.Field(i)))
+			}
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,159 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLoadRoleManifestExtends(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/extends.yml")
+	rolesManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	// The abstract base role is dropped
+	if !assert.Len(rolesManifest.Roles, 2) {
+		return
+	}
+	assert.Nil(rolesManifest.LookupRole("base"))
+
+	myrole := rolesManifest.LookupRole("myrole")
+	if assert.NotNil(myrole) {
+		assert.Equal([]string{"myrole.sh"}, myrole.Scripts)
+		if assert.Len(myrole.Jobs, 1) {
+			assert.Equal("tor", myrole.Jobs[0].Name)
+		}
+		assert.Equal("myrole.example.com", myrole.Configuration.Templates["properties.tor.hostname"])
+		assert.Equal("((KEY))", myrole.Configuration.Templates["properties.tor.private_key"])
+		assert.Equal(256, myrole.Run.Memory)
+		assert.Equal(2, myrole.Run.VirtualCPUs)
+		assert.Equal(RoleTypeBosh, myrole.Type)
+	}
+
+	other := rolesManifest.LookupRole("other")
+	if assert.NotNil(other) {
+		if assert.Len(other.Jobs, 2) {
+			assert.Equal("tor", other.Jobs[0].Name)
+			assert.Equal("new_hostname", other.Jobs[1].Name)
+		}
+		assert.Equal("myrole.example.com", other.Configuration.Templates["properties.tor.hostname"])
+		assert.Equal(256, other.Run.Memory)
+	}
+}
+
+func TestResolveExtendsInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	rolesManifest := &RoleManifest{
+		Roles: Roles{
+			&Role{Name: "myrole", Extends: "missing"},
+		},
+	}
+	assert.EqualError(rolesManifest.resolveExtends(), "Role myrole extends unknown role missing")
+
+	rolesManifest = &RoleManifest{
+		Roles: Roles{
+			&Role{Name: "a", Extends: "b"},
+			&Role{Name: "b", Extends: "c"},
+			&Role{Name: "c", Extends: "a"},
+		},
+	}
+	assert.EqualError(rolesManifest.resolveExtends(), "Role a extends itself through a -> b -> c -> a")
+}
+
+func TestResolveExtendsKeepsBaseRole(t *testing.T) {
+	assert := assert.New(t)
+
+	base := &Role{
+		Name: "base",
+		JobNameList: []*roleJob{
+			{Name: "tor", ReleaseName: "tor", Configuration: &Configuration{Templates: map[string]string{"a": "base"}}},
+		},
+		Configuration: &Configuration{
+			Templates: map[string]string{"b": "base"},
+			Variables: ConfigurationVariableSlice{{Name: "KEY"}},
+		},
+		Tags: []string{"sequential-startup"},
+		Run: &RoleRun{
+			Memory:       128,
+			Scaling:      &RoleRunScaling{Min: 1, Max: 3},
+			ExposedPorts: []*RoleRunExposedPort{{Name: "http", Internal: "80"}},
+			Sysctls:      map[string]string{"net.core.somaxconn": "1024"},
+		},
+	}
+	role := &Role{
+		Name:          "myrole",
+		Extends:       "base",
+		Configuration: &Configuration{Templates: map[string]string{"b": "myrole"}},
+	}
+	rolesManifest := &RoleManifest{Roles: Roles{base, role}}
+	if !assert.NoError(rolesManifest.resolveExtends()) {
+		return
+	}
+
+	role.JobNameList[0].Configuration.Templates["a"] = "myrole"
+	role.Configuration.Variables[0].Name = "OTHER"
+	role.Tags[0] = "headless"
+	role.Run.Memory = 256
+	role.Run.Scaling.Max = 5
+	role.Run.ExposedPorts[0].Internal = "8080"
+	role.Run.Sysctls["net.core.somaxconn"] = "4096"
+
+	assert.Equal("base", base.JobNameList[0].Configuration.Templates["a"])
+	assert.Equal("base", base.Configuration.Templates["b"])
+	assert.Equal("KEY", base.Configuration.Variables[0].Name)
+	assert.Equal("sequential-startup", base.Tags[0])
+	assert.Equal(128, base.Run.Memory)
+	assert.Equal(int32(3), base.Run.Scaling.Max)
+	assert.Equal("80", base.Run.ExposedPorts[0].Internal)
+	assert.Equal("1024", base.Run.Sysctls["net.core.somaxconn"])
+	assert.Equal("myrole", role.Configuration.Templates["b"])
+}
+
+func TestResolveExtendsZeroValues(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest := `---
+roles:
+- name: base
+  abstract: true
+  run:
+    memory: 128
+    privileged: true
+    virtual-cpus: 2
+- name: myrole
+  extends: base
+  run:
+    memory: 0
+    privileged: false
+`
+	var rolesManifest RoleManifest
+	if !assert.NoError(yaml.Unmarshal([]byte(manifest), &rolesManifest)) {
+		return
+	}
+	if !assert.NoError(rolesManifest.resolveExtends()) || !assert.Len(rolesManifest.Roles, 1) {
+		return
+	}
+
+	run := rolesManifest.Roles[0].Run
+	assert.Equal(0, run.Memory, "the role can set a field to its zero value")
+	assert.False(run.Privileged, "the role can set a field to its zero value")
+	assert.Equal(2, run.VirtualCPUs, "fields the role doesn't set are inherited")
+}
@@ -0,0 +1,21 @@
+package model
+
+// RoleImageOptions are the options of image builds that change the images of
+// the roles, and so are part of their versions; images built with and without
+// them get different tags
+type RoleImageOptions struct {
+	TraceStartup bool // The images trace their startup scripts by default; see FISSILE_TRACE in run.sh
+	DevMounts    bool // The images leave out the jobs and role scripts, which come from `fissile dev sync`
+}
+
+// SetImageOptions sets the options the images of the roles are built with
+func (m *RoleManifest) SetImageOptions(options RoleImageOptions) {
+	for _, role := range m.Roles {
+		role.imageOptions = options
+	}
+}
+
+// GetImageOptions returns the options the image of the role is built with
+func (r *Role) GetImageOptions() RoleImageOptions {
+	return r.imageOptions
+}
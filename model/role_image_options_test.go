@@ -0,0 +1,25 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleImageOptionsVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest := &RoleManifest{Roles: Roles{{Name: "api"}}}
+	version := manifest.Roles[0].GetRoleDevVersion()
+
+	manifest.SetImageOptions(RoleImageOptions{TraceStartup: true})
+	assert.True(manifest.Roles[0].GetImageOptions().TraceStartup)
+	tracingVersion := manifest.Roles[0].GetRoleDevVersion()
+	assert.NotEqual(version, tracingVersion, "tracing images get their own tags")
+
+	manifest.SetImageOptions(RoleImageOptions{DevMounts: true})
+	assert.True(manifest.Roles[0].GetImageOptions().DevMounts)
+	devVersion := manifest.Roles[0].GetRoleDevVersion()
+	assert.NotEqual(version, devVersion, "dev mounts images get their own tags")
+	assert.NotEqual(tracingVersion, devVersion)
+}
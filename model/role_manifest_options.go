@@ -0,0 +1,12 @@
+package model
+
+// RoleManifestOptions are the options role manifests are loaded with; the
+// zero value loads them without features nor an environment, and without any
+// checks beyond the manifest itself
+type RoleManifestOptions struct {
+	Features             []string // Features roles are loaded for; see selectFeatureRoles
+	Environment          string   // Environment the manifest is loaded for, e.g. prod; see applyEnvironment
+	StrictProvenance     bool     // Fail on inputs not pinned by a digest or fingerprint; see validateProvenance
+	BundleCacheDir       string   // Directory pulled bundles are kept in; see mergeBundles
+	RemoteScriptCacheDir string   // Directory downloaded role scripts are kept in; see fetchRemoteScripts
+}
@@ -0,0 +1,35 @@
+package model
+
+import "fmt"
+
+// These are the tags fissile gives a meaning to. Other tags are allowed, for
+// the tools reading the role manifest.
+const (
+	RoleTagClustered         = "clustered"          // Deploy as a StatefulSet, so the pods know each other
+	RoleTagHeadless          = "headless"           // The service of the role resolves to its pods
+	RoleTagSequentialStartup = "sequential-startup" // Start pods one at a time, each once the previous one is ready
+	RoleTagStopOnFailure     = "stop-on-failure"    // Do not retry a task role that fails, and stop the deployment
+	RoleTagSmokeTest         = "smoke-test"         // A manual task run by fissile test deploy once the roles are ready
+)
+
+// validateTags checks that the tags fissile interprets fit the type of the
+// role
+func (r *Role) validateTags() error {
+	for _, tag := range r.Tags {
+		switch tag {
+		case RoleTagClustered, RoleTagHeadless, RoleTagSequentialStartup:
+			if !r.IsLongRunning() {
+				return fmt.Errorf("Role %s has the %s tag, but is of type %s", r.Name, tag, r.Type)
+			}
+		case RoleTagStopOnFailure:
+			if r.Type != RoleTypeBoshTask {
+				return fmt.Errorf("Role %s has the %s tag, but is not of type %s", r.Name, tag, RoleTypeBoshTask)
+			}
+		case RoleTagSmokeTest:
+			if !r.IsManualTask() {
+				return fmt.Errorf("Role %s has the %s tag, but is not a manual task", r.Name, tag)
+			}
+		}
+	}
+	return nil
+}
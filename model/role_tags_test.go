@@ -0,0 +1,62 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTags(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		role Role
+		err  string
+	}{
+		{
+			desc: "Roles without tags are valid",
+			role: Role{Name: "api", Type: RoleTypeBosh},
+		},
+		{
+			desc: "Other tags are not interpreted",
+			role: Role{Name: "api", Type: RoleTypeBoshTask, Tags: []string{"team-a"}},
+		},
+		{
+			desc: "Long running roles can be headless and start up sequentially",
+			role: Role{Name: "api", Type: RoleTypeDocker, Tags: []string{RoleTagHeadless, RoleTagSequentialStartup}},
+		},
+		{
+			desc: "Task roles can stop on failure",
+			role: Role{Name: "migrate", Type: RoleTypeBoshTask, Tags: []string{RoleTagStopOnFailure}},
+		},
+		{
+			desc: "Task roles have no services",
+			role: Role{Name: "migrate", Type: RoleTypeBoshTask, Tags: []string{RoleTagHeadless}},
+			err:  "Role migrate has the headless tag, but is of type bosh-task",
+		},
+		{
+			desc: "Only task roles stop on failure",
+			role: Role{Name: "api", Type: RoleTypeBosh, Tags: []string{RoleTagStopOnFailure}},
+			err:  "Role api has the stop-on-failure tag, but is not of type bosh-task",
+		},
+		{
+			desc: "Manual tasks can be smoke tests",
+			role: Role{Name: "smoke", Type: RoleTypeBoshTask, Tags: []string{RoleTagSmokeTest}, Run: &RoleRun{FlightStage: FlightStageManual}},
+		},
+		{
+			desc: "Smoke tests are only run by hand",
+			role: Role{Name: "migrate", Type: RoleTypeBoshTask, Tags: []string{RoleTagSmokeTest}, Run: &RoleRun{FlightStage: FlightStagePostFlight}},
+			err:  "Role migrate has the smoke-test tag, but is not a manual task",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.role.validateTags()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/hpcloud/fissile/util"
+
 	"gopkg.in/yaml.v2"
 )
 
@@ -19,7 +21,7 @@ type RoleType string
 const (
 	RoleTypeBoshTask = RoleType("bosh-task") // A role that is a BOSH task
 	RoleTypeBosh     = RoleType("bosh")      // A role that is a BOSH job
-	RoleTypeDocker   = RoleType("docker")    // A role that is a raw Docker image
+	RoleTypeDocker   = RoleType("docker")    // A role that runs a pre-built Docker image, see Role.Image
 )
 
 // FlightStage describes when a role should be executed
@@ -35,40 +37,98 @@ const (
 
 // RoleManifest represents a collection of roles
 type RoleManifest struct {
-	Roles         Roles          `yaml:"roles"`
-	Configuration *Configuration `yaml:"configuration"`
+	SchemaVersion   int                   `yaml:"schema_version"` // See CurrentManifestSchemaVersion; always current once loaded
+	Roles           Roles                 `yaml:"roles"`
+	Configuration   *Configuration        `yaml:"configuration"`
+	Variables       []*ManifestVariable   `yaml:"variables"` // Credentials of the deployment, as in BOSH deployment manifests
+	Bundles         []*RoleManifestBundle `yaml:"bundles"`
+	Addons          []*RoleManifestAddon  `yaml:"addons"`           // Jobs colocated on every role they apply to
+	CABundle        string                `yaml:"ca-bundle"`        // PEM file of CA certificates the images trust, relative to the manifest
+	Include         []string              `yaml:"include"`          // Files, or globs, merged into the manifest
+	Environments    map[string]string     `yaml:"environments"`     // Environment name to the overlay patching the manifest for it
+	ReleaseVersions map[string]string     `yaml:"release-versions"` // Versions the releases are pinned to, by release name
 
 	manifestFilePath string
+	options          RoleManifestOptions
+	includedFiles    []string
 	rolesByName      map[string]*Role
+	caCertificates   [][]byte
 }
 
 // Role represents a collection of jobs that are colocated on a container
 type Role struct {
-	Name              string         `yaml:"name"`
-	Jobs              Jobs           `yaml:"_,omitempty"`
-	EnvironScripts    []string       `yaml:"environment_scripts"`
-	Scripts           []string       `yaml:"scripts"`
-	PostConfigScripts []string       `yaml:"post_config_scripts"`
-	Type              RoleType       `yaml:"type,omitempty"`
-	JobNameList       []*roleJob     `yaml:"jobs"`
-	Configuration     *Configuration `yaml:"configuration"`
-	Run               *RoleRun       `yaml:"run"`
-	Tags              []string       `yaml:"tags"`
-
-	rolesManifest *RoleManifest
+	Name              string          `yaml:"name"`
+	Jobs              Jobs            `yaml:"_,omitempty"`
+	EnvironScripts    []string        `yaml:"environment_scripts"`
+	Scripts           []string        `yaml:"scripts"`
+	PostConfigScripts []string        `yaml:"post_config_scripts"`
+	Type              RoleType        `yaml:"type,omitempty"`
+	JobNameList       []*roleJob      `yaml:"jobs"`
+	Configuration     *Configuration  `yaml:"configuration"`
+	Run               *RoleRun        `yaml:"run"`
+	Tags              []string        `yaml:"tags"`
+	Dockerfile        string          `yaml:"dockerfile"` // Snippet appended to the Dockerfile of the role image
+	OSPackages        *RoleOSPackages `yaml:"os-packages,omitempty"`
+	Bundles           []string        `yaml:"bundles"`  // Names of the bundles the role uses
+	Image             string          `yaml:"image"`    // Image of docker roles, e.g. mysql:5.7
+	Extends           string          `yaml:"extends"`  // Role whose definition this one inherits
+	Abstract          bool            `yaml:"abstract"` // Only a base for other roles; not built nor deployed
+	Feature           string          `yaml:"feature"`  // Feature the role is only loaded for; see selectFeatureRoles
+
+	rolesManifest   *RoleManifest
+	templateOrigins map[string][]*ConfigurationTemplateOrigin
+	fetchedScripts  map[string]string // Script name to its path in a pulled bundle or the remote script cache
+	dockerfile      string            // Contents of the Dockerfile snippet
+	dockerfileFiles []string          // Files the snippet adds, relative to its directory
+
+	secretReferences []*SecretReference // In the configuration templates, by environment variable
+	imageOptions     RoleImageOptions   // Set by the build; see SetImageOptions
 }
 
 // RoleRun describes how a role should behave at runtime
 type RoleRun struct {
-	Scaling           *RoleRunScaling       `yaml:"scaling"`
-	Capabilities      []string              `yaml:"capabilities"`
-	PersistentVolumes []*RoleRunVolume      `yaml:"persistent-volumes"`
-	SharedVolumes     []*RoleRunVolume      `yaml:"shared-volumes"`
-	Memory            int                   `yaml:"memory"`
-	VirtualCPUs       int                   `yaml:"virtual-cpus"`
-	ExposedPorts      []*RoleRunExposedPort `yaml:"exposed-ports"`
-	FlightStage       FlightStage           `yaml:"flight-stage"`
-	HealthCheck       *HealthCheck          `yaml:"healthcheck,omitempty"`
+	Scaling           *RoleRunScaling         `yaml:"scaling"`
+	Instances         int32                   `yaml:"instances"`    // Number of replicas; the scaling min when not set
+	Capabilities      []string                `yaml:"capabilities"` // Added to the containers, e.g. NET_ADMIN; ALL runs them privileged
+	Privileged        bool                    `yaml:"privileged"`   // Runs the containers privileged
+	PersistentVolumes []*RoleRunVolume        `yaml:"persistent-volumes"`
+	SharedVolumes     []*RoleRunVolume        `yaml:"shared-volumes"`
+	TmpfsVolumes      []*RoleRunTmpfsVolume   `yaml:"tmpfs-volumes"`
+	Memory            int                     `yaml:"memory"`       // In MB; always requested, and also the limit with memory limits
+	VirtualCPUs       int                     `yaml:"virtual-cpus"` // Requested, not limited
+	ExposedPorts      []*RoleRunExposedPort   `yaml:"exposed-ports"`
+	FlightStage       FlightStage             `yaml:"flight-stage"`
+	HealthCheck       *HealthCheck            `yaml:"healthcheck,omitempty"`
+	HealthShim        *RoleRunHealthShim      `yaml:"health-shim,omitempty"`
+	Sysctls           map[string]string       `yaml:"sysctls"`
+	TimeZone          string                  `yaml:"timezone"`
+	Locale            string                  `yaml:"locale"`
+	Schedule          *RoleRunSchedule        `yaml:"schedule,omitempty"`
+	Backup            *RoleRunBackup          `yaml:"backup,omitempty"`
+	ImageSizeBudget   int                     `yaml:"image-size-budget"` // In MB; 0 for no budget
+	Devices           []*RoleRunDevice        `yaml:"devices"`
+	Resources         map[string]int          `yaml:"resources"` // Extended resources, e.g. nvidia.com/gpu: 1
+	NodeSelector      map[string]string       `yaml:"node-selector"`
+	Tolerations       []*RoleRunToleration    `yaml:"tolerations"`
+	Affinity          []*RoleRunAffinity      `yaml:"affinity"` // Placement relative to the pods of roles
+	Service           *RoleRunService         `yaml:"service,omitempty"`
+	DependsOn         []string                `yaml:"depends-on"` // Roles to deploy before this one
+	ExternalMounts    []*RoleRunExternalMount `yaml:"external-mounts"`
+	ConnectionInfo    *RoleRunConnectionInfo  `yaml:"connection-info,omitempty"`
+	Links             []*RoleRunLink          `yaml:"links"` // Roles whose connection info this one gets
+	Env               []*RoleRunEnv           `yaml:"env"`
+	Sidecars          []*RoleRunSidecar       `yaml:"sidecars"`                 // Additional containers in the pods of the role
+	UpdateStrategy    string                  `yaml:"update-strategy"`          // rolling, recreate (Deployments only) or canary (StatefulSets only)
+	QoS               string                  `yaml:"qos"`                      // burstable (the default) or guaranteed
+	CPUPinning        bool                    `yaml:"cpu-pinning"`              // Exclusive CPUs with the static CPU manager
+	HugePages         map[string]int          `yaml:"hugepages"`                // In MB, by page size, e.g. 2Mi: 512
+	PlatformHints     map[string]string       `yaml:"platform-hints"`           // Translated for the cloud platform, e.g. load-balancer: internal
+	GracePeriod       int64                   `yaml:"termination-grace-period"` // Seconds kube gives the pods to stop; the kube default when 0
+	StopTimeout       int                     `yaml:"stop-timeout"`             // Seconds monit has to stop the jobs before the stop signals are sent
+	StopSignals       []*RoleRunStopSignal    `yaml:"stop-signals"`             // Sent in order to the processes still running after the stop timeout
+	Init              string                  `yaml:"init"`                     // PID 1 of the containers: dumb-init (the default) or none
+
+	setKeys map[string]bool // Keys the role manifest sets; see UnmarshalYAML
 }
 
 // RoleRunScaling describes how a role should scale out at runtime
@@ -77,28 +137,42 @@ type RoleRunScaling struct {
 	Max int32 `yaml:"max"`
 }
 
+// RoleRunSchedule describes when a bosh-task role runs as a recurring errand
+type RoleRunSchedule struct {
+	Cron                       string `yaml:"cron"`                          // Standard five field cron expression
+	ConcurrencyPolicy          string `yaml:"concurrency-policy"`            // One of allow, forbid or replace
+	SuccessfulJobsHistoryLimit *int32 `yaml:"successful-jobs-history-limit"` // Number of finished jobs to keep
+	FailedJobsHistoryLimit     *int32 `yaml:"failed-jobs-history-limit"`     // Number of failed jobs to keep
+}
+
 // RoleRunVolume describes a volume to be attached at runtime
 type RoleRunVolume struct {
-	Path string `yaml:"path"`
-	Tag  string `yaml:"tag"`
-	Size int    `yaml:"size"`
+	Path string `yaml:"path"` // Where the volume is mounted
+	Tag  string `yaml:"tag"`  // Names the volume and its claim
+	Size int    `yaml:"size"` // In GB
 }
 
 // RoleRunExposedPort describes a port to be available to other roles, or the outside world
 type RoleRunExposedPort struct {
-	Name     string `yaml:"name"`
-	Protocol string `yaml:"protocol"`
-	External string `yaml:"external"`
-	Internal string `yaml:"internal"`
-	Public   bool   `yaml:"public"`
+	Name     string          `yaml:"name"`
+	Protocol string          `yaml:"protocol"`
+	External string          `yaml:"external"`
+	Internal string          `yaml:"internal"`
+	Public   bool            `yaml:"public"`
+	TLS      *RoleRunPortTLS `yaml:"tls,omitempty"`
+	Service  *RoleRunService `yaml:"service,omitempty"` // Settings of a service of its own, <role>-<port>
 }
 
-// HealthCheck describes a non-standard health check endpoint
+// HealthCheck describes a non-standard health check endpoint, telling when the
+// role is ready; its liveness check, if any, tells when it has to be restarted
 type HealthCheck struct {
-	URL     string            `yaml:"url"`     // URL for a HTTP GET to return 200~399. Cannot be used with other checks.
-	Headers map[string]string `yaml:"headers"` // Custom headers; only used for URL.
-	Command []string          `yaml:"command"` // Custom command. Cannot be used with other checks.
-	Port    int32             `yaml:"port"`    // Port for a TCP probe. Cannot be used with other checks.
+	URL          string            `yaml:"url"`                // URL for a HTTP GET to return 200~399. Cannot be used with other checks.
+	Headers      map[string]string `yaml:"headers"`            // Custom headers; only used for URL.
+	Command      []string          `yaml:"command"`            // Custom command. Cannot be used with other checks.
+	Port         int32             `yaml:"port"`               // Port for a TCP probe. Cannot be used with other checks.
+	InitialDelay int32             `yaml:"initial-delay"`      // Seconds after the container starts before the first check
+	Timeout      int32             `yaml:"timeout"`            // Seconds after which a check fails; the kube default when 0
+	Liveness     *HealthCheck      `yaml:"liveness,omitempty"` // Replaces the liveness check derived from monit
 }
 
 // Roles is an array of Role*
@@ -109,6 +183,8 @@ type Roles []*Role
 type Configuration struct {
 	Templates map[string]string          `yaml:"templates"`
 	Variables ConfigurationVariableSlice `yaml:"variables"`
+
+	templateDirs map[string]string // Directories of the included files the templates come from
 }
 
 // ConfigurationVariable is a configuration to be exposed to the IaaS
@@ -117,6 +193,9 @@ type ConfigurationVariable struct {
 	Default     interface{}                     `yaml:"default"`
 	Description string                          `yaml:"description"`
 	Generator   *ConfigurationVariableGenerator `yaml:"generator"`
+	Secret      *ConfigurationVariableSecret    `yaml:"secret"`
+
+	baseDir string // Directory of the included file the variable comes from
 }
 
 // ConfigurationVariableSlice is a sortable slice of ConfigurationVariables
@@ -145,9 +224,32 @@ type ConfigurationVariableGenerator struct {
 	ValueType string `yaml:"value_type"`
 }
 
+// ConfigurationTemplateLevel is the place a configuration template was defined at
+type ConfigurationTemplateLevel string
+
+// These are the configuration template levels, from lowest to highest precedence
+const (
+	ConfigurationTemplateLevelJobDefaults = ConfigurationTemplateLevel("job defaults")
+	ConfigurationTemplateLevelManifest    = ConfigurationTemplateLevel("manifest")
+	ConfigurationTemplateLevelRole        = ConfigurationTemplateLevel("role")
+)
+
+// ConfigurationTemplateOrigin records where a configuration template value came from
+type ConfigurationTemplateOrigin struct {
+	Level ConfigurationTemplateLevel
+	Job   string // Only set for job defaults
+	Value string
+}
+
 type roleJob struct {
-	Name        string `yaml:"name"`
-	ReleaseName string `yaml:"release_name"`
+	Name          string         `yaml:"name"`
+	ReleaseName   string         `yaml:"release_name"`
+	Configuration *Configuration `yaml:"configuration"`
+	DependsOn     []string       `yaml:"depends-on"` // Jobs of the role this one uses, started before it and stopped after it
+
+	// Templates of the job the role leaves out, by source or destination
+	// path; "monit" leaves out the monit file, so the job runs no processes
+	ExcludeTemplates []string `yaml:"exclude-templates"`
 }
 
 // Len is the number of roles in the slice
@@ -167,10 +269,20 @@ func (roles Roles) Swap(i, j int) {
 
 // LoadRoleManifest loads a yaml manifest that details how jobs get grouped into roles
 func LoadRoleManifest(manifestFilePath string, releases []*Release) (*RoleManifest, error) {
+	return LoadRoleManifestWithOptions(manifestFilePath, releases, RoleManifestOptions{})
+}
+
+// LoadRoleManifestWithOptions loads a role manifest like LoadRoleManifest,
+// with the given options
+func LoadRoleManifestWithOptions(manifestFilePath string, releases []*Release, options RoleManifestOptions) (*RoleManifest, error) {
 	manifestContents, err := ioutil.ReadFile(manifestFilePath)
 	if err != nil {
 		return nil, err
 	}
+	manifestContents, err = migrateManifestSchema(manifestFilePath, manifestContents)
+	if err != nil {
+		return nil, err
+	}
 
 	mappedReleases := map[string]*Release{}
 
@@ -183,12 +295,32 @@ func LoadRoleManifest(manifestFilePath string, releases []*Release) (*RoleManife
 
 		mappedReleases[release.Name] = release
 	}
+	sharePackages(releases)
 
 	rolesManifest := RoleManifest{}
 	rolesManifest.manifestFilePath = manifestFilePath
+	rolesManifest.options = options
 	if err := yaml.Unmarshal(manifestContents, &rolesManifest); err != nil {
 		return nil, err
 	}
+	if err := validateManifestSchema(manifestFilePath, manifestContents, filepath.Dir(manifestFilePath), options.Features); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.loadIncludes(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.applyEnvironment(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.resolveExtends(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.selectFeatureRoles(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.validateReleaseVersions(mappedReleases); err != nil {
+		return nil, err
+	}
 
 	for i := len(rolesManifest.Roles) - 1; i >= 0; i-- {
 		role := rolesManifest.Roles[i]
@@ -205,9 +337,111 @@ func LoadRoleManifest(manifestFilePath string, releases []*Release) (*RoleManife
 			default:
 				return nil, fmt.Errorf("Role %s has an invalid flight stage %s", role.Name, role.Run.FlightStage)
 			}
+			if role.Run.FlightStage != FlightStageFlight && role.Type != RoleTypeBoshTask {
+				return nil, fmt.Errorf("Role %s has flight stage %s, but is not of type %s", role.Name, role.Run.FlightStage, RoleTypeBoshTask)
+			}
+		}
+
+		if role.Run != nil {
+			if err := role.Run.validateSystemSettings(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+		}
+
+		if role.Run != nil {
+			if err := role.Run.validateInstances(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+			if err := role.Run.validateDevices(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+			if err := role.Run.validateScheduling(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+			if err := role.Run.validateService(role.HasTag(RoleTagHeadless)); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+			if err := role.Run.validateVolumes(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+			if err := role.Run.validateExternalMounts(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+			if err := role.Run.validateQoS(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+			if err := role.Run.validateExposedPorts(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+			if err := role.Run.validateCapabilities(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+			if err := role.Run.validatePlatformHints(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+		}
+
+		if role.OSPackages != nil {
+			if err := role.OSPackages.validate(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+		}
+
+		if role.Run != nil && role.Run.Schedule != nil {
+			if role.Type != RoleTypeBoshTask {
+				return nil, fmt.Errorf("Role %s has a schedule, but is not of type %s", role.Name, RoleTypeBoshTask)
+			}
+			if err := role.Run.Schedule.validate(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+		}
+
+		if role.Run != nil && role.Run.Instances > 0 && role.Type == RoleTypeBoshTask {
+			return nil, fmt.Errorf("Role %s has instances, but is of type %s", role.Name, RoleTypeBoshTask)
+		}
+
+		if role.Run != nil && role.Run.UpdateStrategy != "" {
+			if role.Type == RoleTypeBoshTask {
+				return nil, fmt.Errorf("Role %s has an update strategy, but is of type %s", role.Name, RoleTypeBoshTask)
+			}
+			if err := role.Run.validateUpdateStrategy(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+		}
+
+		// Jobs have no volume claims, so their volumes would have nothing to mount
+		if role.Run != nil && role.Type == RoleTypeBoshTask && len(role.Run.PersistentVolumes)+len(role.Run.SharedVolumes) > 0 {
+			return nil, fmt.Errorf("Role %s has volumes, but is of type %s", role.Name, RoleTypeBoshTask)
+		}
+
+		if role.Run != nil && (role.Run.Memory < 0 || role.Run.VirtualCPUs < 0) {
+			return nil, fmt.Errorf("Role %s should not have a negative memory or virtual-cpus", role.Name)
+		}
+
+		if role.Run != nil && role.Run.ImageSizeBudget < 0 {
+			return nil, fmt.Errorf("Role %s has a negative image size budget", role.Name)
+		}
+
+		if role.Run != nil && role.Run.Backup != nil {
+			if role.Type != "" && role.Type != RoleTypeBosh {
+				return nil, fmt.Errorf("Role %s has a backup, but is not of type %s", role.Name, RoleTypeBosh)
+			}
+			if err := role.Run.Backup.validate(); err != nil {
+				return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+			}
+		}
+
+		// Ensure that we don't have conflicting health checks
+		if role.Run != nil && role.Run.HealthCheck != nil {
+			if err := role.Run.HealthCheck.validate(role.Name); err != nil {
+				return nil, err
+			}
+		}
+
+		if role.Image != "" && role.Type != RoleTypeDocker {
+			return nil, fmt.Errorf("Role %s has an image, but is not of type %s", role.Name, RoleTypeDocker)
 		}
 
-		// Remove all roles that are not of the "bosh" or "bosh-task" type
 		// Default type is considered to be "bosh"
 		switch role.Type {
 		case "":
@@ -215,26 +449,15 @@ func LoadRoleManifest(manifestFilePath string, releases []*Release) (*RoleManife
 		case RoleTypeBosh, RoleTypeBoshTask:
 			continue
 		case RoleTypeDocker:
-			rolesManifest.Roles = append(rolesManifest.Roles[:i], rolesManifest.Roles[i+1:]...)
-		default:
-			return nil, fmt.Errorf("Role %s has an invalid type %s", role.Name, role.Type)
-		}
-
-		// Ensure that we don't have conflicting health checks
-		if role.Run != nil && role.Run.HealthCheck != nil {
-			checks := make([]string, 0, 3)
-			if role.Run.HealthCheck.URL != "" {
-				checks = append(checks, "url")
-			}
-			if len(role.Run.HealthCheck.Command) > 0 {
-				checks = append(checks, "command")
-			}
-			if role.Run.HealthCheck.Port != 0 {
-				checks = append(checks, "port")
+			if err := role.validateDockerRole(); err != nil {
+				return nil, err
 			}
-			if len(checks) != 1 {
-				return nil, fmt.Errorf("Health check for role %s should have exactly one of url, command, or port; got %v", role.Name, checks)
+			// Pre-built images need no settings to run; the defaults are fine
+			if role.Run == nil {
+				role.Run = &RoleRun{}
 			}
+		default:
+			return nil, fmt.Errorf("Role %s has an invalid type %s", role.Name, role.Type)
 		}
 	}
 
@@ -245,10 +468,42 @@ func LoadRoleManifest(manifestFilePath string, releases []*Release) (*RoleManife
 		rolesManifest.Configuration.Templates = map[string]string{}
 	}
 
+	if err := rolesManifest.applyAddons(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.Configuration.validateSecrets(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.validateManifestVariables(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.validateTLS(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.mergeBundles(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.fetchRemoteScripts(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.loadCABundle(); err != nil {
+		return nil, err
+	}
+
+	// Functions of templates and variables from included files resolve
+	// relative paths against the directories of those files instead
+	baseDir := filepath.Dir(manifestFilePath)
+	if err := rolesManifest.Configuration.expandConfigurationFunctions(baseDir); err != nil {
+		return nil, err
+	}
+
 	rolesManifest.rolesByName = make(map[string]*Role, len(rolesManifest.Roles))
 
 	for _, role := range rolesManifest.Roles {
 		role.rolesManifest = &rolesManifest
+		if err := role.loadDockerfile(); err != nil {
+			return nil, err
+		}
 		role.Jobs = make(Jobs, 0, len(role.JobNameList))
 
 		for _, roleJob := range role.JobNameList {
@@ -267,10 +522,67 @@ func LoadRoleManifest(manifestFilePath string, releases []*Release) (*RoleManife
 			role.Jobs = append(role.Jobs, job)
 		}
 
-		role.calculateRoleConfigurationTemplates()
+		if err := role.Configuration.expandConfigurationFunctions(baseDir); err != nil {
+			return nil, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+		}
+		for _, roleJob := range role.JobNameList {
+			if err := roleJob.Configuration.expandConfigurationFunctions(baseDir); err != nil {
+				return nil, fmt.Errorf("Role %s, job %s: %s", role.Name, roleJob.Name, err.Error())
+			}
+		}
+
+		if err := role.calculateRoleConfigurationTemplates(); err != nil {
+			return nil, err
+		}
+		if err := role.resolveSecretReferences(); err != nil {
+			return nil, err
+		}
 		rolesManifest.rolesByName[role.Name] = role
 	}
 
+	for _, role := range rolesManifest.Roles {
+		if err := role.validateTags(); err != nil {
+			return nil, err
+		}
+		if err := role.validateHealthShim(); err != nil {
+			return nil, err
+		}
+		if err := role.validateTermination(); err != nil {
+			return nil, err
+		}
+		if err := role.validateInit(); err != nil {
+			return nil, err
+		}
+		if err := role.validateRunEnv(); err != nil {
+			return nil, err
+		}
+		if _, err := role.JobShutdownOrder(); err != nil {
+			return nil, err
+		}
+		if err := role.validateExcludedTemplates(); err != nil {
+			return nil, err
+		}
+		if err := role.validateSidecars(); err != nil {
+			return nil, err
+		}
+		if options.StrictProvenance {
+			if err := role.validateProvenance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := rolesManifest.validateConnectionInfo(); err != nil {
+		return nil, err
+	}
+	if err := rolesManifest.validateAffinity(); err != nil {
+		return nil, err
+	}
+
+	// Check that the dependencies between roles can be ordered
+	if _, err := rolesManifest.DeployWaves(); err != nil {
+		return nil, err
+	}
+
 	return &rolesManifest, nil
 }
 
@@ -283,8 +595,8 @@ func (m *RoleManifest) GetRoleManifestDevPackageVersion(extra string) string {
 	hasher := sha1.New()
 	hasher.Write([]byte(extra))
 
-	for _, role := range roles {
-		hasher.Write([]byte(role.GetRoleDevVersion()))
+	for _, version := range getRoleDevVersions(roles) {
+		hasher.Write([]byte(version))
 	}
 
 	return hex.EncodeToString(hasher.Sum(nil))
@@ -295,6 +607,23 @@ func (m *RoleManifest) LookupRole(roleName string) *Role {
 	return m.rolesByName[roleName]
 }
 
+// LookupRole returns the role with the given name from the role manifest of
+// the role, or nil if there is none
+func (r *Role) LookupRole(roleName string) *Role {
+	return r.rolesManifest.LookupRole(roleName)
+}
+
+// LookupVariable returns the configuration variable with the given name from
+// the role manifest, or nil if there is none
+func (r *Role) LookupVariable(name string) *ConfigurationVariable {
+	for _, variable := range r.rolesManifest.Configuration.Variables {
+		if variable.Name == name {
+			return variable
+		}
+	}
+	return nil
+}
+
 // GetScriptPaths returns the paths to the startup / post configgin scripts for a role
 func (r *Role) GetScriptPaths() map[string]string {
 	result := map[string]string{}
@@ -305,6 +634,10 @@ func (r *Role) GetScriptPaths() map[string]string {
 				// Absolute paths _inside_ the container; there is nothing to copy
 				continue
 			}
+			if bundlePath, ok := r.fetchedScripts[script]; ok {
+				result[script] = bundlePath
+				continue
+			}
 			result[script] = filepath.Join(filepath.Dir(r.rolesManifest.manifestFilePath), script)
 		}
 	}
@@ -313,21 +646,105 @@ func (r *Role) GetScriptPaths() map[string]string {
 
 }
 
-// GetRoleDevVersion gets the aggregate signature of all jobs and packages
-func (r *Role) GetRoleDevVersion() string {
-	roleSignature := ""
+// RoleVersionInput is an input of the dev version of a role: a part of the
+// role image that needs the image rebuilt when it changes
+type RoleVersionInput struct {
+	Name  string `json:"name"` // What the input is, e.g. job tor or dockerfile
+	Value string `json:"value"`
+}
+
+// GetRoleDevVersionInputs returns the inputs of the dev version of the role,
+// in the order they are hashed in
+func (r *Role) GetRoleDevVersionInputs() []RoleVersionInput {
+	var inputs []RoleVersionInput
+	add := func(name, value string) {
+		inputs = append(inputs, RoleVersionInput{Name: name, Value: value})
+	}
 	var packages Packages
 
 	// Jobs are *not* sorted because they are an array and the order may be
 	// significant, in particular for bosh-task roles.
 	for _, job := range r.Jobs {
-		roleSignature = fmt.Sprintf("%s\n%s", roleSignature, job.SHA1)
+		add("job "+job.Name, job.SHA1)
 		packages = append(packages, job.Packages...)
 	}
 
 	sort.Sort(packages)
 	for _, pkg := range packages {
-		roleSignature = fmt.Sprintf("%s\n%s", roleSignature, pkg.SHA1)
+		add("package "+pkg.Name, pkg.SHA1)
+	}
+
+	// Leaving out templates changes the configuration the image renders
+	for _, roleJob := range r.JobNameList {
+		if len(roleJob.ExcludeTemplates) > 0 {
+			add("excluded templates of "+roleJob.Name, fmt.Sprintf("%s:%s", roleJob.Name, strings.Join(roleJob.ExcludeTemplates, ",")))
+		}
+	}
+
+	// OS packages are installed into the image, so changing them needs a rebuild
+	if r.OSPackages != nil {
+		add("os-packages", r.OSPackages.String())
+	}
+
+	// So are the Dockerfile snippet and the files it adds
+	if signature := r.dockerfileSignature(); signature != "" {
+		add("dockerfile", signature)
+	}
+
+	// So are the CA certificates
+	if signature := r.caBundleSignature(); signature != "" {
+		add("ca-bundle", signature)
+	}
+
+	// The time zone and locale are set in the environment of the image
+	if r.Run != nil && r.Run.TimeZone != "" {
+		add("timezone", "timezone:"+r.Run.TimeZone)
+	}
+	if r.Run != nil && r.Run.Locale != "" {
+		add("locale", "locale:"+r.Run.Locale)
+	}
+
+	// The health shim is added to the image
+	if port := r.HealthShimPort(); port != 0 {
+		add("health-shim", fmt.Sprintf("health-shim:%d", port))
+	}
+
+	// The stop signals are part of the entrypoint of the image
+	if r.Run != nil && len(r.Run.StopSignals) > 0 {
+		signals := make([]string, 0, len(r.Run.StopSignals))
+		for _, signal := range r.Run.StopSignals {
+			signals = append(signals, fmt.Sprintf("%s:%d", signal.Signal, signal.Wait))
+		}
+		add("stop-signals", fmt.Sprintf("stop-signals:%d:%s", r.Run.StopTimeout, strings.Join(signals, ",")))
+	}
+
+	// So is the init, which is PID 1 of the entrypoint
+	if r.Type != RoleTypeDocker {
+		add("entrypoint", "entrypoint:"+strings.Join(r.GetEntrypoint(), " "))
+	}
+
+	// So are the options of the build
+	if r.imageOptions.TraceStartup {
+		add("trace-startup", "trace-startup")
+	}
+	if r.imageOptions.DevMounts {
+		add("dev-mounts", "dev-mounts")
+	}
+
+	// Docker roles are the image they run
+	if r.Image != "" {
+		add("image", r.Image)
+	}
+
+	return inputs
+}
+
+// GetRoleDevVersion gets the aggregate signature of all jobs and packages,
+// and of the other inputs of the role image
+func (r *Role) GetRoleDevVersion() string {
+	roleSignature := ""
+	for _, input := range r.GetRoleDevVersionInputs() {
+		roleSignature = fmt.Sprintf("%s\n%s", roleSignature, input.Value)
 	}
 
 	hasher := sha1.New()
@@ -335,6 +752,59 @@ func (r *Role) GetRoleDevVersion() string {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
+// GetPackages returns the packages used by the jobs of the role, without
+// duplicates
+func (r *Role) GetPackages() Packages {
+	var result Packages
+	seen := map[string]bool{}
+
+	for _, job := range r.Jobs {
+		for _, pkg := range job.Packages {
+			if !seen[pkg.Fingerprint] {
+				seen[pkg.Fingerprint] = true
+				result = append(result, pkg)
+			}
+		}
+	}
+
+	return result
+}
+
+// GetCompiledPackagesSize returns the total size, in bytes, of the compiled
+// packages of the role
+func (r *Role) GetCompiledPackagesSize(compiledPackagesPath string) (int64, error) {
+	var total int64
+	for _, pkg := range r.GetPackages() {
+		size, err := pkg.GetCompiledSize(compiledPackagesPath)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// CheckImageSizeBudget fails if the compiled packages of the role are larger
+// than its image size budget
+func (r *Role) CheckImageSizeBudget(compiledPackagesPath string) error {
+	if r.Run == nil || r.Run.ImageSizeBudget == 0 {
+		return nil
+	}
+
+	size, err := r.GetCompiledPackagesSize(compiledPackagesPath)
+	if err != nil {
+		return err
+	}
+
+	budget := int64(r.Run.ImageSizeBudget) * 1024 * 1024
+	if size > budget {
+		return fmt.Errorf("Role %s: compiled packages use %s, over the image size budget of %dMB",
+			r.Name, util.FormatBytes(uint64(size)), r.Run.ImageSizeBudget)
+	}
+
+	return nil
+}
+
 // HasTag returns true if the role has a specific tag
 func (r *Role) HasTag(tag string) bool {
 	for _, t := range r.Tags {
@@ -346,7 +816,19 @@ func (r *Role) HasTag(tag string) bool {
 	return false
 }
 
-func (r *Role) calculateRoleConfigurationTemplates() {
+// ConfigurationTemplateOrigins returns every definition of the given
+// configuration template key for the role, ordered from lowest to highest
+// precedence; the last entry is the effective value
+func (r *Role) ConfigurationTemplateOrigins(key string) []*ConfigurationTemplateOrigin {
+	return r.templateOrigins[key]
+}
+
+// calculateRoleConfigurationTemplates merges the configuration templates of
+// the role's jobs, the role manifest and the role, in that order of
+// precedence. The templates of a job are defaults for the whole role, not only
+// for that job, so two jobs of the same role may not define a key with
+// different values.
+func (r *Role) calculateRoleConfigurationTemplates() error {
 	if r.Configuration == nil {
 		r.Configuration = &Configuration{}
 	}
@@ -355,13 +837,40 @@ func (r *Role) calculateRoleConfigurationTemplates() {
 	}
 
 	roleConfigs := map[string]string{}
-	for k, v := range r.rolesManifest.Configuration.Templates {
-		roleConfigs[k] = v
+	r.templateOrigins = map[string][]*ConfigurationTemplateOrigin{}
+
+	addTemplates := func(templates map[string]string, level ConfigurationTemplateLevel, jobName string) {
+		for k, v := range templates {
+			roleConfigs[k] = v
+			r.templateOrigins[k] = append(r.templateOrigins[k], &ConfigurationTemplateOrigin{
+				Level: level,
+				Job:   jobName,
+				Value: v,
+			})
+		}
 	}
 
-	for k, v := range r.Configuration.Templates {
-		roleConfigs[k] = v
+	jobConfigs := map[string]string{}
+	jobConfigOwners := map[string]string{}
+	for _, roleJob := range r.JobNameList {
+		if roleJob.Configuration == nil {
+			continue
+		}
+		for k, v := range roleJob.Configuration.Templates {
+			if previous, ok := jobConfigs[k]; ok && previous != v {
+				return fmt.Errorf("Role %s: jobs %s and %s define conflicting configuration templates for %s",
+					r.Name, jobConfigOwners[k], roleJob.Name, k)
+			}
+			jobConfigs[k] = v
+			jobConfigOwners[k] = roleJob.Name
+		}
+		addTemplates(roleJob.Configuration.Templates, ConfigurationTemplateLevelJobDefaults, roleJob.Name)
 	}
 
+	addTemplates(r.rolesManifest.Configuration.Templates, ConfigurationTemplateLevelManifest, "")
+	addTemplates(r.Configuration.Templates, ConfigurationTemplateLevelRole, "")
+
 	r.Configuration.Templates = roleConfigs
+
+	return nil
 }
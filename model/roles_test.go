@@ -9,6 +9,29 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// loadTestRoleManifest loads the role manifest of the given name from the
+// test assets, with the tor release; nil when that fails
+func loadTestRoleManifest(assert *assert.Assertions, manifestName string) *RoleManifest {
+	workDir, err := os.Getwd()
+	if !assert.NoError(err) {
+		return nil
+	}
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return nil
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests", manifestName)
+	rolesManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release})
+	if !assert.NoError(err) {
+		return nil
+	}
+	return rolesManifest
+}
+
 func TestLoadRoleManifestOK(t *testing.T) {
 	assert := assert.New(t)
 
@@ -157,7 +180,7 @@ func TestLoadRoleManifestMultipleReleasesNotOk(t *testing.T) {
 	assert.Contains(err.Error(), "release foo has not been loaded and is referenced by job ntpd in role foorole")
 }
 
-func TestNonBoshRolesAreIgnoredOK(t *testing.T) {
+func TestNonBoshRolesLoadOK(t *testing.T) {
 	assert := assert.New(t)
 
 	workDir, err := os.Getwd()
@@ -174,7 +197,131 @@ func TestNonBoshRolesAreIgnoredOK(t *testing.T) {
 	assert.NotNil(rolesManifest)
 
 	assert.Equal(roleManifestPath, rolesManifest.manifestFilePath)
-	assert.Len(rolesManifest.Roles, 2)
+	if assert.Len(rolesManifest.Roles, 3) {
+		dockerRole := rolesManifest.LookupRole("dockerrole")
+		if assert.NotNil(dockerRole) {
+			assert.Equal(RoleTypeDocker, dockerRole.Type)
+			assert.Equal("mysql:5.7", dockerRole.Image)
+			assert.True(dockerRole.IsLongRunning())
+		}
+		assert.False(rolesManifest.LookupRole("foorole").IsLongRunning())
+	}
+}
+
+func TestLoadRoleManifestAnchors(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/anchors.yml")
+	rolesManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	myrole := rolesManifest.LookupRole("myrole")
+	if assert.NotNil(myrole) {
+		assert.Equal([]string{"myrole.sh"}, myrole.Scripts)
+		if assert.Len(myrole.Jobs, 2) {
+			assert.Equal("tor", myrole.Jobs[0].Name)
+			assert.Equal("new_hostname", myrole.Jobs[1].Name)
+		}
+		// Keys next to a merge win over the merged ones, at every level
+		assert.Equal(256, myrole.Run.Memory)
+		assert.Equal(int32(1), myrole.Run.Scaling.Min)
+		assert.Equal(int32(5), myrole.Run.Scaling.Max)
+		if assert.Len(myrole.Run.ExposedPorts, 1) {
+			assert.Equal("http", myrole.Run.ExposedPorts[0].Name)
+		}
+		if assert.Len(myrole.Run.Env, 1) {
+			assert.Equal("LOG_FORMAT", myrole.Run.Env[0].Name)
+		}
+	}
+
+	foorole := rolesManifest.LookupRole("foorole")
+	if assert.NotNil(foorole) {
+		assert.Equal([]string{"myrole.sh"}, foorole.Scripts)
+		// The first of several merged mappings wins
+		assert.Equal(128, foorole.Run.Memory)
+		assert.Equal(int32(3), foorole.Run.Scaling.Max)
+		assert.Len(foorole.Run.ExposedPorts, 1)
+		if assert.Len(foorole.Run.Env, 1) {
+			assert.Equal("DEBUG", foorole.Run.Env[0].Name)
+		}
+		// Each use of an anchor is a copy of its own
+		assert.False(myrole.JobNameList[0] == foorole.JobNameList[0])
+	}
+
+	roleManifestPath = filepath.Join(workDir, "../test-assets/role-manifests/anchors-bad.yml")
+	_, err = LoadRoleManifest(roleManifestPath, []*Release{release})
+	if assert.Error(err) {
+		assert.Equal(roleManifestPath+":10: Role myrole: unknown key run.memroy, did you mean memory?", err.Error())
+	}
+}
+
+func TestConfigurationTemplateLevels(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	assert.NoError(err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/templates.yml")
+	rolesManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release})
+	if !assert.NoError(err) {
+		return
+	}
+
+	myrole := rolesManifest.LookupRole("myrole")
+	assert.Equal("((ROLE_HOSTNAME))", myrole.Configuration.Templates["properties.tor.hostname"])
+	assert.Equal("((ROLE_KEY))", myrole.Configuration.Templates["properties.tor.private_key"])
+	assert.Equal("((JOB_KEY))", myrole.Configuration.Templates["properties.tor.client_keys"])
+
+	origins := myrole.ConfigurationTemplateOrigins("properties.tor.private_key")
+	if assert.Len(origins, 3) {
+		assert.Equal(ConfigurationTemplateLevelJobDefaults, origins[0].Level)
+		assert.Equal("tor", origins[0].Job)
+		assert.Equal("((JOB_KEY))", origins[0].Value)
+		assert.Equal(ConfigurationTemplateLevelManifest, origins[1].Level)
+		assert.Equal(ConfigurationTemplateLevelRole, origins[2].Level)
+		assert.Equal("((ROLE_KEY))", origins[2].Value)
+	}
+
+	foorole := rolesManifest.LookupRole("foorole")
+	assert.Equal("((FOO))", foorole.Configuration.Templates["properties.tor.private_key"])
+	origins = foorole.ConfigurationTemplateOrigins("properties.tor.private_key")
+	if assert.Len(origins, 1) {
+		assert.Equal(ConfigurationTemplateLevelManifest, origins[0].Level)
+	}
+	assert.Empty(foorole.ConfigurationTemplateOrigins("properties.tor.missing"))
+}
+
+func TestConfigurationTemplateJobConflict(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	assert.NoError(err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/templates-conflict.yml")
+	_, err = LoadRoleManifest(roleManifestPath, []*Release{release})
+	assert.EqualError(err, "Role myrole: jobs new_hostname and tor define conflicting configuration templates for properties.tor.hostname")
 }
 
 func TestRolesSort(t *testing.T) {
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RoleRunEnv is an environment variable set for the containers of a role.
+// Its value can reference configuration variables as ((NAME)), which are
+// given to the role, and other roles as ((role.NAME)), which become the host
+// the role is reached at.
+type RoleRunEnv struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// runEnvReferencePattern matches the references in the values of run.env
+var runEnvReferencePattern = regexp.MustCompile(`\(\(\s*(role\.)?([A-Za-z0-9_-]+)\s*\)\)`)
+
+// runEnvRolePrefix marks the references to roles in the values of run.env
+const runEnvRolePrefix = "role."
+
+// EnvVariables returns the names of the configuration variables referenced
+// by the value
+func (e *RoleRunEnv) EnvVariables() []string {
+	var names []string
+	for _, match := range runEnvReferencePattern.FindAllStringSubmatch(e.Value, -1) {
+		if match[1] == "" {
+			names = append(names, match[2])
+		}
+	}
+	return names
+}
+
+// EnvRoles returns the names of the roles referenced by the value
+func (e *RoleRunEnv) EnvRoles() []string {
+	var names []string
+	for _, match := range runEnvReferencePattern.FindAllStringSubmatch(e.Value, -1) {
+		if match[1] == runEnvRolePrefix {
+			names = append(names, match[2])
+		}
+	}
+	return names
+}
+
+// Expand returns the value with the references replaced: variables with
+// $(NAME), which the container runtime expands from the environment, and
+// roles with their host as given by roleHost
+func (e *RoleRunEnv) Expand(roleHost func(roleName string) (string, error)) (string, error) {
+	var expandErr error
+	value := runEnvReferencePattern.ReplaceAllStringFunc(e.Value, func(reference string) string {
+		match := runEnvReferencePattern.FindStringSubmatch(reference)
+		if match[1] == "" {
+			return fmt.Sprintf("$(%s)", match[2])
+		}
+		host, err := roleHost(match[2])
+		if err != nil && expandErr == nil {
+			expandErr = err
+		}
+		return host
+	})
+	return value, expandErr
+}
+
+// validateRunEnv checks the environment variables of a role; the variables
+// and roles they reference must exist
+func (r *Role) validateRunEnv() error {
+	if r.Run == nil || len(r.Run.Env) == 0 {
+		return nil
+	}
+
+	names := map[string]bool{}
+	for _, env := range r.Run.Env {
+		if !envNamePattern.MatchString(env.Name) {
+			return fmt.Errorf("Role %s: invalid environment variable name '%s'", r.Name, env.Name)
+		}
+		if names[env.Name] {
+			return fmt.Errorf("Role %s: environment variable %s is set more than once", r.Name, env.Name)
+		}
+		names[env.Name] = true
+
+		if r.LookupVariable(env.Name) != nil {
+			return fmt.Errorf("Role %s: environment variable %s is a configuration variable", r.Name, env.Name)
+		}
+		if unmatched := runEnvReferencePattern.ReplaceAllString(env.Value, ""); strings.Contains(unmatched, "((") {
+			return fmt.Errorf("Role %s: environment variable %s has an invalid reference in '%s'", r.Name, env.Name, env.Value)
+		}
+		for _, name := range env.EnvVariables() {
+			if r.LookupVariable(name) == nil {
+				return fmt.Errorf("Role %s: environment variable %s uses unknown variable %s", r.Name, env.Name, name)
+			}
+		}
+		for _, name := range env.EnvRoles() {
+			other := r.LookupRole(name)
+			if other == nil {
+				return fmt.Errorf("Role %s: environment variable %s uses unknown role %s", r.Name, env.Name, name)
+			}
+			if !other.IsLongRunning() {
+				return fmt.Errorf("Role %s: environment variable %s uses role %s, which is of type %s", r.Name, env.Name, name, other.Type)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunEnvOK(t *testing.T) {
+	assert := assert.New(t)
+	rolesManifest := loadTestRoleManifest(assert, "run-env.yml")
+	if rolesManifest == nil {
+		return
+	}
+
+	role := rolesManifest.LookupRole("myrole")
+	if !assert.NotNil(role) || !assert.Len(role.Run.Env, 2) {
+		return
+	}
+	env := role.Run.Env[0]
+	assert.Equal([]string{"DB_USER"}, env.EnvVariables())
+	assert.Equal([]string{"mysql"}, env.EnvRoles())
+
+	value, err := env.Expand(func(roleName string) (string, error) {
+		return roleName + ".example.com", nil
+	})
+	if assert.NoError(err) {
+		assert.Equal("mysql://$(DB_USER)@mysql.example.com:3306/app", value)
+	}
+	_, err = env.Expand(func(roleName string) (string, error) {
+		return "", fmt.Errorf("Role %s has no host", roleName)
+	})
+	assert.EqualError(err, "Role mysql has no host")
+
+	// The variables used in run.env are given to the role
+	variables, err := role.GetVariablesForRole()
+	if assert.NoError(err) {
+		var names []string
+		for _, variable := range variables {
+			names = append(names, variable.Name)
+		}
+		assert.Contains(names, "DB_USER")
+	}
+}
+
+func TestRunEnvInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		env  RoleRunEnv
+		err  string
+	}{
+		{
+			desc: "Names are environment variable names",
+			env:  RoleRunEnv{Name: "log-format", Value: "json"},
+			err:  "Role myrole: invalid environment variable name 'log-format'",
+		},
+		{
+			desc: "Names are unique",
+			env:  RoleRunEnv{Name: "LOG_FORMAT", Value: "text"},
+			err:  "Role myrole: environment variable LOG_FORMAT is set more than once",
+		},
+		{
+			desc: "Configuration variables are set from the configuration",
+			env:  RoleRunEnv{Name: "DB_USER", Value: "root"},
+			err:  "Role myrole: environment variable DB_USER is a configuration variable",
+		},
+		{
+			desc: "References are to variables or roles",
+			env:  RoleRunEnv{Name: "HOSTS", Value: "((#DB_USER))"},
+			err:  "Role myrole: environment variable HOSTS has an invalid reference in '((#DB_USER))'",
+		},
+		{
+			desc: "Variables are known",
+			env:  RoleRunEnv{Name: "USER", Value: "((DB_USERNAME))"},
+			err:  "Role myrole: environment variable USER uses unknown variable DB_USERNAME",
+		},
+		{
+			desc: "Roles are known",
+			env:  RoleRunEnv{Name: "HOST", Value: "((role.postgres))"},
+			err:  "Role myrole: environment variable HOST uses unknown role postgres",
+		},
+	}
+
+	for _, sample := range samples {
+		rolesManifest := loadTestRoleManifest(assert, "run-env.yml")
+		if rolesManifest == nil {
+			return
+		}
+		role := rolesManifest.LookupRole("myrole")
+		env := sample.env
+		role.Run.Env = append(role.Run.Env, &env)
+		assert.EqualError(role.validateRunEnv(), sample.err, sample.desc)
+	}
+}
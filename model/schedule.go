@@ -0,0 +1,39 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Concurrency policies for scheduled roles
+const (
+	ConcurrencyPolicyAllow   = "allow"
+	ConcurrencyPolicyForbid  = "forbid"
+	ConcurrencyPolicyReplace = "replace"
+)
+
+// validate checks the cron expression, concurrency policy and history limits
+// of a schedule, and normalizes the concurrency policy
+func (s *RoleRunSchedule) validate() error {
+	if len(strings.Fields(s.Cron)) != 5 {
+		return fmt.Errorf("Schedule %q is not a five field cron expression", s.Cron)
+	}
+
+	s.ConcurrencyPolicy = strings.ToLower(s.ConcurrencyPolicy)
+	switch s.ConcurrencyPolicy {
+	case "":
+		s.ConcurrencyPolicy = ConcurrencyPolicyAllow
+	case ConcurrencyPolicyAllow, ConcurrencyPolicyForbid, ConcurrencyPolicyReplace:
+	default:
+		return fmt.Errorf("Schedule has an invalid concurrency policy %s", s.ConcurrencyPolicy)
+	}
+
+	if s.SuccessfulJobsHistoryLimit != nil && *s.SuccessfulJobsHistoryLimit < 0 {
+		return fmt.Errorf("Schedule has a negative successful jobs history limit")
+	}
+	if s.FailedJobsHistoryLimit != nil && *s.FailedJobsHistoryLimit < 0 {
+		return fmt.Errorf("Schedule has a negative failed jobs history limit")
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	schedule := &RoleRunSchedule{Cron: "0 2 * * *"}
+	if assert.NoError(schedule.validate()) {
+		assert.Equal(ConcurrencyPolicyAllow, schedule.ConcurrencyPolicy)
+	}
+
+	schedule = &RoleRunSchedule{Cron: "0 2 * * *", ConcurrencyPolicy: "Forbid"}
+	if assert.NoError(schedule.validate()) {
+		assert.Equal(ConcurrencyPolicyForbid, schedule.ConcurrencyPolicy)
+	}
+
+	schedule = &RoleRunSchedule{Cron: "@daily"}
+	assert.EqualError(schedule.validate(), `Schedule "@daily" is not a five field cron expression`)
+
+	schedule = &RoleRunSchedule{Cron: "0 2 * * *", ConcurrencyPolicy: "sometimes"}
+	assert.EqualError(schedule.validate(), "Schedule has an invalid concurrency policy sometimes")
+
+	limit := int32(-1)
+	schedule = &RoleRunSchedule{Cron: "0 2 * * *", FailedJobsHistoryLimit: &limit}
+	assert.EqualError(schedule.validate(), "Schedule has a negative failed jobs history limit")
+}
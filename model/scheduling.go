@@ -0,0 +1,129 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RoleRunToleration describes a node taint the pods of a role tolerate
+type RoleRunToleration struct {
+	Key      string `yaml:"key"`
+	Operator string `yaml:"operator"` // Equal (the default) or Exists
+	Value    string `yaml:"value"`
+	Effect   string `yaml:"effect"` // NoSchedule, PreferNoSchedule, or empty for any
+}
+
+// These are the valid toleration operators and effects
+const (
+	TolerationOperatorEqual          = "Equal"
+	TolerationOperatorExists         = "Exists"
+	TolerationEffectNoSchedule       = "NoSchedule"
+	TolerationEffectPreferNoSchedule = "PreferNoSchedule"
+)
+
+func (t *RoleRunToleration) validate() error {
+	switch t.Operator {
+	case "", TolerationOperatorEqual:
+		if t.Key == "" {
+			return fmt.Errorf("Toleration without a key must use the %s operator", TolerationOperatorExists)
+		}
+	case TolerationOperatorExists:
+		if t.Value != "" {
+			return fmt.Errorf("Toleration %s uses the %s operator, but has a value", t.Key, TolerationOperatorExists)
+		}
+	default:
+		return fmt.Errorf("Toleration %s has an invalid operator %s", t.Key, t.Operator)
+	}
+
+	switch t.Effect {
+	case "", TolerationEffectNoSchedule, TolerationEffectPreferNoSchedule:
+	default:
+		return fmt.Errorf("Toleration %s has an invalid effect %s", t.Key, t.Effect)
+	}
+
+	return nil
+}
+
+// validateScheduling checks the extended resources, node selector and
+// tolerations of a role
+func (r *RoleRun) validateScheduling() error {
+	for name, count := range r.Resources {
+		if !extendedResourcePattern.MatchString(name) {
+			return fmt.Errorf("Invalid resource %s, expected <domain>/<name>", name)
+		}
+		if strings.HasSuffix(strings.SplitN(name, "/", 2)[0], "kubernetes.io") {
+			return fmt.Errorf("Resource %s uses a reserved Kubernetes domain; use memory and virtual-cpus instead", name)
+		}
+		if count <= 0 {
+			return fmt.Errorf("Resource %s should have a positive count", name)
+		}
+		for _, device := range r.Devices {
+			if device.Resource == name {
+				return fmt.Errorf("Resource %s is also listed as a device", name)
+			}
+		}
+	}
+
+	for key := range r.NodeSelector {
+		if key == "" {
+			return fmt.Errorf("Node selector with an empty label")
+		}
+	}
+
+	for _, toleration := range r.Tolerations {
+		if err := toleration.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetExtendedResources returns the extended resources the role needs, both
+// from its resources and the device plugins of its devices
+func (r *RoleRun) GetExtendedResources() map[string]int {
+	result := map[string]int{}
+	for name, count := range r.Resources {
+		result[name] = count
+	}
+	for _, device := range r.Devices {
+		if device.IsDevicePlugin() {
+			result[device.Resource] = device.GetCount()
+		}
+	}
+	return result
+}
+
+// GetTolerations returns the tolerations of the role. Node pools with special
+// hardware (e.g. GPUs) are usually tainted with the name of the extended
+// resource, so pods requesting one also tolerate that taint unless the role
+// manifest says otherwise.
+func (r *RoleRun) GetTolerations() []*RoleRunToleration {
+	tolerations := append([]*RoleRunToleration{}, r.Tolerations...)
+
+	explicit := map[string]bool{}
+	for _, toleration := range r.Tolerations {
+		explicit[toleration.Key] = true
+	}
+
+	resources := r.GetExtendedResources()
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if explicit[name] {
+			continue
+		}
+		tolerations = append(tolerations, &RoleRunToleration{
+			Key:      name,
+			Operator: TolerationOperatorExists,
+			Effect:   TolerationEffectNoSchedule,
+		})
+	}
+
+	return tolerations
+}
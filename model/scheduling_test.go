@@ -0,0 +1,87 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateScheduling(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		run  RoleRun
+		err  string
+	}{
+		{
+			desc: "Empty scheduling settings are valid",
+		},
+		{
+			desc: "Extended resources, node selectors and tolerations are valid",
+			run: RoleRun{
+				Resources:    map[string]int{"nvidia.com/gpu": 1},
+				NodeSelector: map[string]string{"accelerator": "nvidia-tesla-k80"},
+				Tolerations: []*RoleRunToleration{
+					{Key: "dedicated", Value: "ml", Effect: TolerationEffectNoSchedule},
+					{Operator: TolerationOperatorExists},
+				},
+			},
+		},
+		{
+			desc: "Resources need a domain",
+			run:  RoleRun{Resources: map[string]int{"gpu": 1}},
+			err:  "Invalid resource gpu, expected <domain>/<name>",
+		},
+		{
+			desc: "Resource counts must be positive",
+			run:  RoleRun{Resources: map[string]int{"nvidia.com/gpu": 0}},
+			err:  "Resource nvidia.com/gpu should have a positive count",
+		},
+		{
+			desc: "Resources can't also be devices",
+			run: RoleRun{
+				Resources: map[string]int{"nvidia.com/gpu": 1},
+				Devices:   []*RoleRunDevice{{Resource: "nvidia.com/gpu"}},
+			},
+			err: "Resource nvidia.com/gpu is also listed as a device",
+		},
+		{
+			desc: "Tolerations without a key must match any value",
+			run:  RoleRun{Tolerations: []*RoleRunToleration{{Value: "ml"}}},
+			err:  "Toleration without a key must use the Exists operator",
+		},
+		{
+			desc: "Toleration effects are checked",
+			run:  RoleRun{Tolerations: []*RoleRunToleration{{Key: "dedicated", Effect: "NoExecute"}}},
+			err:  "Toleration dedicated has an invalid effect NoExecute",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.run.validateScheduling()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestRoleRunGetTolerations(t *testing.T) {
+	assert := assert.New(t)
+
+	run := RoleRun{
+		Resources: map[string]int{"nvidia.com/gpu": 1},
+		Devices:   []*RoleRunDevice{{Resource: "example.com/fpga"}},
+		Tolerations: []*RoleRunToleration{
+			{Key: "nvidia.com/gpu", Value: "k80"},
+		},
+	}
+
+	assert.Equal(map[string]int{"nvidia.com/gpu": 1, "example.com/fpga": 1}, run.GetExtendedResources())
+	assert.Equal([]*RoleRunToleration{
+		{Key: "nvidia.com/gpu", Value: "k80"},
+		{Key: "example.com/fpga", Operator: TolerationOperatorExists, Effect: TolerationEffectNoSchedule},
+	}, run.GetTolerations())
+}
@@ -0,0 +1,568 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// schemaProblem is a part of a role manifest that doesn't fit its schema
+type schemaProblem struct {
+	line    int
+	message string
+}
+
+// extensionKeyPrefix starts the keys fissile ignores, which hold blocks
+// shared through YAML anchors, e.g. x-shared-run: &run {memory: 128}
+const extensionKeyPrefix = "x-"
+
+// manifestSchemaValidator checks the contents of a role manifest file, as
+// written, before it is loaded; see validateManifestSchema
+type manifestSchemaValidator struct {
+	path      string
+	baseDir   string
+	lines     yamlLineIndex
+	roleNames []string
+	problems  []schemaProblem
+}
+
+// validateManifestSchema checks the contents of a role manifest file, or of a
+// file it includes, for keys fissile doesn't know, which would be ignored
+// otherwise, e.g. a misspelled jobs key. It also checks that the roles are
+// complete: that they have a name, a known type, a list of jobs with a name
+// and a release unless they are docker roles or inherit their jobs, and scripts that
+// exist relative to baseDir, the directory of the role manifest, unless they
+// may come from a bundle. Roles used with the enabled features have distinct
+// names. All problems are reported, each with its line.
+func validateManifestSchema(path string, contents []byte, baseDir string, features []string) error {
+	var manifest interface{}
+	if err := yaml.Unmarshal(contents, &manifest); err != nil {
+		return err
+	}
+
+	v := &manifestSchemaValidator{
+		path:    path,
+		baseDir: baseDir,
+		lines:   indexYAMLLines(contents),
+	}
+	var roles []interface{}
+	if document, ok := manifest.(map[interface{}]interface{}); ok {
+		roles, _ = document["roles"].([]interface{})
+	}
+	enabled := enabledFeatures(features)
+	roleLines := map[string]int{}
+	for i, role := range roles {
+		fields, _ := role.(map[interface{}]interface{})
+		name, _ := fields["name"].(string)
+		v.roleNames = append(v.roleNames, name)
+
+		// Alternatives of a role for a feature and its negation share its name
+		feature, _ := fields["feature"].(string)
+		if name == "" || !isFeatureSelected(feature, enabled) {
+			continue
+		}
+		keyPath := []interface{}{"roles", i, "name"}
+		if line, ok := roleLines[name]; ok {
+			v.add(keyPath, "Role %s is defined more than once, first at line %d", name, line)
+			continue
+		}
+		roleLines[name] = v.lines.lookup(keyPath)
+	}
+
+	v.checkKeys(manifest, reflect.TypeOf(RoleManifest{}), nil)
+	for i, role := range roles {
+		if fields, ok := role.(map[interface{}]interface{}); ok {
+			v.checkRole(fields, []interface{}{"roles", i})
+		}
+	}
+
+	return v.err()
+}
+
+// validateFileSchema checks the contents of a YAML file fissile loads into a
+// value of type t for keys that are not fields of it; see
+// validateManifestSchema
+func validateFileSchema(path string, contents []byte, t reflect.Type) error {
+	var document interface{}
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return err
+	}
+
+	v := &manifestSchemaValidator{
+		path:  path,
+		lines: indexYAMLLines(contents),
+	}
+	v.checkKeys(document, t, nil)
+
+	return v.err()
+}
+
+// err returns the problems found, each with its line, or nil if there are none
+func (v *manifestSchemaValidator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	sort.Stable(schemaProblemsByLine(v.problems))
+	messages := make([]string, 0, len(v.problems))
+	for _, problem := range v.problems {
+		messages = append(messages, fmt.Sprintf("%s:%d: %s", v.path, problem.line, problem.message))
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "\n"))
+}
+
+// add records a problem at the line of the given key path, or of the closest
+// enclosing key that can be located
+func (v *manifestSchemaValidator) add(keyPath []interface{}, format string, args ...interface{}) {
+	v.problems = append(v.problems, schemaProblem{
+		line:    v.lines.lookup(keyPath),
+		message: fmt.Sprintf(format, args...),
+	})
+}
+
+// checkKeys checks that the keys of the mappings in the value are fields of
+// the type it is loaded into, recursively
+func (v *manifestSchemaValidator) checkKeys(value interface{}, t reflect.Type, keyPath []interface{}) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != reflect.TypeOf(RoleRun{}) && reflect.PtrTo(t).Implements(reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()) {
+		// Types loading themselves have their own schema; the run settings of
+		// roles only record which keys they load
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		mapping, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		fields := yamlFields(t)
+		for _, key := range sortedMappingKeys(mapping) {
+			name := fmt.Sprintf("%v", key)
+			if strings.HasPrefix(name, extensionKeyPrefix) {
+				continue
+			}
+			field, ok := fields[name]
+			if !ok {
+				v.addUnknownKey(appendKey(keyPath, key), name, fields)
+				continue
+			}
+			v.checkKeys(mapping[key], field, appendKey(keyPath, key))
+		}
+
+	case reflect.Map:
+		if mapping, ok := value.(map[interface{}]interface{}); ok {
+			for _, key := range sortedMappingKeys(mapping) {
+				v.checkKeys(mapping[key], t.Elem(), appendKey(keyPath, key))
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		if items, ok := value.([]interface{}); ok {
+			for i, item := range items {
+				v.checkKeys(item, t.Elem(), appendKey(keyPath, i))
+			}
+		}
+	}
+}
+
+// addUnknownKey records a key that isn't a field, suggesting the field it is
+// likely a misspelling of
+func (v *manifestSchemaValidator) addUnknownKey(keyPath []interface{}, name string, fields map[string]reflect.Type) {
+	message := fmt.Sprintf("unknown key %s", describeKeyPath(keyPath))
+	if suggestion := closestFieldName(name, fields); suggestion != "" {
+		message = fmt.Sprintf("%s, did you mean %s?", message, suggestion)
+	}
+	if role := v.roleName(keyPath); role != "" {
+		v.add(keyPath, "Role %s: %s", role, message)
+	} else {
+		v.add(keyPath, "%s%s", strings.ToUpper(message[:1]), message[1:])
+	}
+}
+
+// roleName returns the name of the role a key path is in, if any
+func (v *manifestSchemaValidator) roleName(keyPath []interface{}) string {
+	if len(keyPath) < 2 || keyPath[0] != "roles" {
+		return ""
+	}
+	i, ok := keyPath[1].(int)
+	if !ok || i >= len(v.roleNames) {
+		return ""
+	}
+	return v.roleNames[i]
+}
+
+// checkRole checks that a role of the manifest is complete
+func (v *manifestSchemaValidator) checkRole(role map[interface{}]interface{}, keyPath []interface{}) {
+	name, _ := role["name"].(string)
+	if name == "" {
+		v.add(keyPath, "Role %d has no name", keyPath[1])
+		return
+	}
+
+	roleType, _ := role["type"].(string)
+	switch RoleType(roleType) {
+	case "", RoleTypeBosh, RoleTypeBoshTask, RoleTypeDocker:
+	default:
+		v.add(appendKey(keyPath, "type"), "Role %s has an invalid type %s", name, roleType)
+	}
+
+	// Roles without jobs have to say so with an empty list; the jobs key is
+	// more likely misspelled
+	jobs, _ := role["jobs"].([]interface{})
+	_, hasJobs := role["jobs"]
+	_, extends := role["extends"]
+	abstract, _ := role["abstract"].(bool)
+	if !hasJobs && RoleType(roleType) != RoleTypeDocker && !extends && !abstract {
+		v.add(keyPath, "Role %s has no jobs", name)
+	}
+	for i, job := range jobs {
+		jobPath := appendKey(appendKey(keyPath, "jobs"), i)
+		fields, ok := job.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		jobName, _ := fields["name"].(string)
+		if jobName == "" {
+			v.add(jobPath, "Role %s: job %d has no name", name, i)
+			continue
+		}
+		if releaseName, _ := fields["release_name"].(string); releaseName == "" {
+			v.add(jobPath, "Role %s: job %s has no release_name", name, jobName)
+		}
+	}
+
+	// Scripts of roles using bundles might come from those
+	if _, ok := role["bundles"]; ok {
+		return
+	}
+	for _, key := range []string{"environment_scripts", "scripts", "post_config_scripts"} {
+		scripts, _ := role[key].([]interface{})
+		for i, script := range scripts {
+			scriptPath, ok := script.(string)
+			if !ok || filepath.IsAbs(scriptPath) {
+				// Absolute paths are inside the container
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(v.baseDir, scriptPath)); err != nil {
+				v.add(appendKey(appendKey(keyPath, key), i), "Role %s: script %s not found", name, scriptPath)
+			}
+		}
+	}
+}
+
+// yamlFields returns the keys of the fields of a struct type, as yaml loads
+// them, with their types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		inline := false
+		for _, flag := range tag[1:] {
+			if flag == "inline" {
+				inline = true
+			}
+		}
+		if inline && field.Type.Kind() == reflect.Struct {
+			for key, fieldType := range yamlFields(field.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// closestFieldName returns the field name an unknown key is most likely a
+// misspelling of, or nothing if none is close
+func closestFieldName(name string, fields map[string]reflect.Type) string {
+	best := ""
+	bestDistance := 3
+	for _, field := range sortedFieldNames(fields) {
+		if distance := editDistance(name, field); distance < bestDistance && distance < len(field) {
+			best = field
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the number of single character insertions, deletions,
+// substitutions and transpositions turning a into b
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
+
+func sortedFieldNames(fields map[string]reflect.Type) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedMappingKeys(mapping map[interface{}]interface{}) []interface{} {
+	names := make([]string, 0, len(mapping))
+	keysByName := make(map[string]interface{}, len(mapping))
+	for key := range mapping {
+		name := fmt.Sprintf("%v", key)
+		names = append(names, name)
+		keysByName[name] = key
+	}
+	sort.Strings(names)
+
+	keys := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, keysByName[name])
+	}
+	return keys
+}
+
+// describeKeyPath formats a key path for messages, e.g. run.scaling.min or
+// jobs[0].name; key paths of roles are relative to the role
+func describeKeyPath(keyPath []interface{}) string {
+	if len(keyPath) > 2 && keyPath[0] == "roles" {
+		keyPath = keyPath[2:]
+	}
+	var description string
+	for _, key := range keyPath {
+		if i, ok := key.(int); ok {
+			description = fmt.Sprintf("%s[%d]", description, i)
+		} else if description == "" {
+			description = fmt.Sprintf("%v", key)
+		} else {
+			description = fmt.Sprintf("%s.%v", description, key)
+		}
+	}
+	return description
+}
+
+// schemaProblemsByLine sorts schema problems by their line
+type schemaProblemsByLine []schemaProblem
+
+// Len is the number of problems in the slice
+func (p schemaProblemsByLine) Len() int {
+	return len(p)
+}
+
+// Less reports whether the problem at i is on an earlier line than the one at j
+func (p schemaProblemsByLine) Less(i, j int) bool {
+	return p[i].line < p[j].line
+}
+
+// Swap exchanges the problems at i and j
+func (p schemaProblemsByLine) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// keySeparator joins the elements of key paths in a yamlLineIndex; keys of
+// templates contain dots, and could contain slashes
+const keySeparator = "\x00"
+
+// yamlKeyPattern matches a key starting a line of block YAML, and the value
+// after it
+var yamlKeyPattern = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|'[^']*'|[^\s#'"{\[&*!|>%@` + "`" + `-][^#]*?|-[^\s#][^#]*?)\s*:(?:\s+(.*))?$`)
+
+// yamlLineIndex locates the keys and sequence items of a YAML document by
+// their key paths. The yaml package doesn't tell where the values it loads
+// come from, so this reads the block style YAML of role manifests line by
+// line; keys in flow style collections are located at the line of their
+// collection.
+type yamlLineIndex map[string]int
+
+// yamlContainer is a mapping or a sequence enclosing the current line
+type yamlContainer struct {
+	indent   int
+	path     []interface{}
+	sequence bool
+	items    int
+}
+
+// indexYAMLLines indexes the lines of the keys of a YAML document
+func indexYAMLLines(contents []byte) yamlLineIndex {
+	index := yamlLineIndex{}
+
+	stack := []*yamlContainer{{indent: 0}}
+	var pending []interface{} // Path of the key whose value starts on the next line
+	pendingIndent := -1
+	blockIndent := -1 // Indent of the key of the block scalar being skipped
+
+	for i, text := range strings.Split(string(contents), "\n") {
+		lineNumber := i + 1
+		content := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		indent := len(text) - len(content)
+		if blockIndent >= 0 {
+			if indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if strings.HasPrefix(content, "#") {
+			continue
+		}
+		if indent == 0 && (strings.HasPrefix(content, "---") || strings.HasPrefix(content, "...")) {
+			stack = []*yamlContainer{{indent: 0}}
+			pending = nil
+			continue
+		}
+
+		for {
+			isItem := content == "-" || strings.HasPrefix(content, "- ")
+			if pending != nil {
+				if indent > pendingIndent || (indent == pendingIndent && isItem) {
+					stack = append(stack, &yamlContainer{indent: indent, path: pending, sequence: isItem})
+				}
+				pending = nil
+			}
+			for len(stack) > 1 {
+				top := stack[len(stack)-1]
+				if top.indent > indent || (top.indent == indent && top.sequence && !isItem) {
+					stack = stack[:len(stack)-1]
+					continue
+				}
+				break
+			}
+			top := stack[len(stack)-1]
+
+			if isItem {
+				if !top.sequence {
+					break
+				}
+				itemPath := appendKey(top.path, top.items)
+				top.items++
+				index[joinKeyPath(itemPath)] = lineNumber
+
+				rest := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+				if unanchored := stripYAMLAnchor(rest); unanchored == "" || strings.HasPrefix(unanchored, "#") {
+					pending = itemPath
+					pendingIndent = indent
+					break
+				}
+				if strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">") {
+					blockIndent = indent
+					break
+				}
+				itemIndent := len(content) - len(rest) + indent
+				stack = append(stack, &yamlContainer{
+					indent:   itemIndent,
+					path:     itemPath,
+					sequence: rest == "-" || strings.HasPrefix(rest, "- "),
+				})
+				// An anchor on the first key doesn't move the keys after it
+				content = stripYAMLAnchor(rest)
+				indent = itemIndent
+				continue
+			}
+
+			match := yamlKeyPattern.FindStringSubmatch(strings.TrimRight(content, " \r"))
+			if match == nil || top.sequence {
+				break
+			}
+			key := strings.TrimSpace(match[1])
+			if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') {
+				key = key[1 : len(key)-1]
+			}
+			keyPath := appendKey(top.path, key)
+			index[joinKeyPath(keyPath)] = lineNumber
+
+			value := stripYAMLAnchor(strings.TrimSpace(match[2]))
+			switch {
+			case value == "" || strings.HasPrefix(value, "#"):
+				pending = keyPath
+				pendingIndent = indent
+			case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+				blockIndent = indent
+			}
+			break
+		}
+	}
+
+	return index
+}
+
+// yamlAnchorPattern matches the anchor starting a value, e.g. &run in
+// run: &run, along with the spaces after it
+var yamlAnchorPattern = regexp.MustCompile(`^&[^\s]+\s*`)
+
+// stripYAMLAnchor drops the anchor starting a value or a key, so that what
+// it names is indexed like anything else
+func stripYAMLAnchor(value string) string {
+	return yamlAnchorPattern.ReplaceAllString(value, "")
+}
+
+// lookup returns the line of the key path, or of its closest enclosing key
+// that is indexed; 1 if none is
+func (index yamlLineIndex) lookup(keyPath []interface{}) int {
+	for n := len(keyPath); n > 0; n-- {
+		if line, ok := index[joinKeyPath(keyPath[:n])]; ok {
+			return line
+		}
+	}
+	return 1
+}
+
+// appendKey returns a copy of the key path with a key added
+func appendKey(keyPath []interface{}, key interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(keyPath)+1), keyPath...), key)
+}
+
+func joinKeyPath(keyPath []interface{}) string {
+	keys := make([]string, 0, len(keyPath))
+	for _, key := range keyPath {
+		keys = append(keys, fmt.Sprintf("%v", key))
+	}
+	return strings.Join(keys, keySeparator)
+}
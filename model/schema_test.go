@@ -0,0 +1,179 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoleManifestSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc     string
+		manifest string
+		err      string
+	}{
+		{
+			desc: "Misspelled role keys are reported with their line",
+			manifest: `---
+roles:
+- name: myrole
+  jbos:
+  - name: tor
+    release_name: tor
+`,
+			err: "%s:3: Role myrole has no jobs\n" +
+				"%s:4: Role myrole: unknown key jbos, did you mean jobs?",
+		},
+		{
+			desc: "Unknown keys are reported at any depth",
+			manifest: `---
+rolse: []
+roles:
+- name: myrole
+  jobs: []
+  run:
+    scaling:
+      mni: 1
+    exposed-ports:
+    - name: http
+      intenral: 80
+configuration:
+  templates:
+    properties.tor.hostname: |
+      not: a key
+  varaibles: []
+`,
+			err: "%s:2: Unknown key rolse, did you mean roles?\n" +
+				"%s:8: Role myrole: unknown key run.scaling.mni, did you mean min?\n" +
+				"%s:11: Role myrole: unknown key run.exposed-ports[0].intenral, did you mean internal?\n" +
+				"%s:16: Unknown key configuration.varaibles, did you mean variables?",
+		},
+		{
+			desc: "Roles are complete",
+			manifest: `---
+roles:
+- jobs: []
+- name: badtype
+  type: bosh-errand
+  jobs: []
+- name: badjobs
+  jobs:
+  - release_name: tor
+  - name: tor
+- name: badscript
+  jobs: []
+  scripts:
+  - /absolute/is/fine.sh
+  - missing.sh
+`,
+			err: "%s:3: Role 0 has no name\n" +
+				"%s:5: Role badtype has an invalid type bosh-errand\n" +
+				"%s:9: Role badjobs: job 0 has no name\n" +
+				"%s:10: Role badjobs: job tor has no release_name\n" +
+				"%s:15: Role badscript: script missing.sh not found",
+		},
+		{
+			desc: "Role names are distinct, unless the roles are alternatives for a feature",
+			manifest: `---
+roles:
+- name: myrole
+  jobs: []
+- name: proxy
+  feature: ha
+  jobs: []
+- name: proxy
+  feature: '!ha'
+  jobs: []
+- name: myrole
+  jobs: []
+`,
+			err: "%s:11: Role myrole is defined more than once, first at line 3",
+		},
+		{
+			desc:     "Keys in flow collections are reported at their collection",
+			manifest: "roles:\n- {name: myrole, jobs: [], tgas: [a]}\n",
+			err:      "%s:2: Role myrole: unknown key tgas, did you mean tags?",
+		},
+	}
+
+	for _, sample := range samples {
+		dir, err := ioutil.TempDir("", "fissile-schema-")
+		if !assert.NoError(err) {
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		manifestPath := filepath.Join(dir, "role-manifest.yml")
+		assert.NoError(ioutil.WriteFile(manifestPath, []byte(sample.manifest), 0644))
+
+		_, err = LoadRoleManifest(manifestPath, nil)
+		assert.EqualError(err, strings.Replace(sample.err, "%s", manifestPath, -1), sample.desc)
+	}
+}
+
+func TestIndexYAMLLines(t *testing.T) {
+	assert := assert.New(t)
+
+	index := indexYAMLLines([]byte(`---
+# A comment
+roles:
+- name: first
+  run:
+    sysctls:
+      "net.core.somaxconn": 1024
+-
+  name: second
+  jobs:
+    - name: tor
+      release_name: tor
+configuration:
+  templates:
+    properties.a: >
+      folded: text
+    properties.b: plain
+`))
+
+	assert.Equal(3, index.lookup([]interface{}{"roles"}))
+	assert.Equal(4, index.lookup([]interface{}{"roles", 0, "name"}))
+	assert.Equal(7, index.lookup([]interface{}{"roles", 0, "run", "sysctls", "net.core.somaxconn"}))
+	assert.Equal(8, index.lookup([]interface{}{"roles", 1}))
+	assert.Equal(9, index.lookup([]interface{}{"roles", 1, "name"}))
+	assert.Equal(12, index.lookup([]interface{}{"roles", 1, "jobs", 0, "release_name"}))
+	assert.Equal(15, index.lookup([]interface{}{"configuration", "templates", "properties.a"}))
+	assert.Equal(17, index.lookup([]interface{}{"configuration", "templates", "properties.b"}))
+	assert.Equal(14, index.lookup([]interface{}{"configuration", "templates", "folded"}), "Block scalars have no keys")
+	assert.Equal(1, index.lookup([]interface{}{"missing"}))
+}
+
+func TestIndexYAMLLinesAnchors(t *testing.T) {
+	assert := assert.New(t)
+
+	index := indexYAMLLines([]byte(`---
+x-shared:
+  run: &run
+    memory: 128
+roles:
+- &first
+  name: first
+  run:
+    <<: *run
+    scaling: &scaling
+      min: 1
+- &second name: second
+  jobs: []
+`))
+
+	assert.Equal(4, index.lookup([]interface{}{"x-shared", "run", "memory"}))
+	assert.Equal(6, index.lookup([]interface{}{"roles", 0}))
+	assert.Equal(7, index.lookup([]interface{}{"roles", 0, "name"}))
+	assert.Equal(11, index.lookup([]interface{}{"roles", 0, "run", "scaling", "min"}))
+	assert.Equal(8, index.lookup([]interface{}{"roles", 0, "run", "memory"}), "Merged keys are located at the key they are merged into")
+	assert.Equal(12, index.lookup([]interface{}{"roles", 1, "name"}))
+	assert.Equal(13, index.lookup([]interface{}{"roles", 1, "jobs"}))
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CurrentManifestSchemaVersion is the version of the role manifest format
+// this fissile reads. Manifests without a schema_version are of version 1,
+// the format before it was versioned.
+const CurrentManifestSchemaVersion = 1
+
+// manifestMigration upgrades the contents of a role manifest, or of a file
+// it includes, from the version before Version to Version
+type manifestMigration struct {
+	Version int
+	Migrate func(document yaml.MapSlice) (yaml.MapSlice, error)
+}
+
+// manifestMigrations are the migrations of the role manifest format, by
+// increasing version. A change to the format adds one, along with increasing
+// CurrentManifestSchemaVersion, so existing manifests keep loading.
+var manifestMigrations []manifestMigration
+
+// migrateManifestSchema upgrades the contents of a role manifest file to the
+// current schema version, in memory. Contents of the current version are
+// returned as they are; in others, the keys the migrations change are
+// rewritten with SetYAMLKeys, keeping the comments and lines of the rest, so
+// problems found in them afterwards are reported at lines of the migrated
+// contents.
+func migrateManifestSchema(path string, contents []byte) ([]byte, error) {
+	return migrateManifestContents(path, contents, CurrentManifestSchemaVersion, manifestMigrations)
+}
+
+// migrateManifestContents upgrades the contents of a role manifest file to
+// the given version with the migrations; see migrateManifestSchema
+func migrateManifestContents(path string, contents []byte, current int, migrations []manifestMigration) ([]byte, error) {
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if value, ok := lookupYAMLValue(document, "schema_version"); ok {
+		number, ok := value.(int)
+		if !ok || number < 1 {
+			return nil, fmt.Errorf("%s: invalid schema_version %v, expected a positive number", path, value)
+		}
+		version = number
+	}
+	if version > current {
+		return nil, fmt.Errorf("%s has schema version %d, newer than version %d this fissile reads; please upgrade fissile",
+			path, version, current)
+	}
+	if version == current {
+		return contents, nil
+	}
+
+	original := append(yaml.MapSlice{}, document...)
+	for _, migration := range migrations {
+		if migration.Version <= version || migration.Version > current {
+			continue
+		}
+		var err error
+		if document, err = migration.Migrate(document); err != nil {
+			return nil, fmt.Errorf("Error migrating %s to schema version %d: %s", path, migration.Version, err.Error())
+		}
+	}
+
+	// Only the keys the migrations changed are rewritten, so the comments and
+	// lines of the rest of the file stay
+	changes := yaml.MapSlice{{Key: "schema_version", Value: current}}
+	for _, item := range original {
+		key := fmt.Sprintf("%v", item.Key)
+		if _, ok := lookupYAMLValue(document, key); !ok && key != "schema_version" {
+			changes = append(changes, yaml.MapItem{Key: key, Value: nil})
+		}
+	}
+	for _, item := range document {
+		key := fmt.Sprintf("%v", item.Key)
+		value, ok := lookupYAMLValue(original, key)
+		if key != "schema_version" && (!ok || !reflect.DeepEqual(value, item.Value)) {
+			changes = append(changes, yaml.MapItem{Key: key, Value: item.Value})
+		}
+	}
+	return SetYAMLKeys(contents, changes)
+}
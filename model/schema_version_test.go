@@ -0,0 +1,80 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMigrateManifestSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	current := "---\nschema_version: 1\nroles: []\n"
+	migrated, err := migrateManifestSchema("role-manifest.yml", []byte(current))
+	assert.NoError(err)
+	assert.Equal(current, string(migrated), "Manifests of the current version are not rewritten")
+
+	migrated, err = migrateManifestSchema("role-manifest.yml", []byte("---\nroles: []\n"))
+	assert.NoError(err)
+	assert.Equal("---\nroles: []\n", string(migrated), "Manifests without a version are of version 1")
+
+	_, err = migrateManifestSchema("role-manifest.yml", []byte("schema_version: 2\n"))
+	assert.EqualError(err, "role-manifest.yml has schema version 2, newer than version 1 this fissile reads; please upgrade fissile")
+
+	_, err = migrateManifestSchema("role-manifest.yml", []byte("schema_version: latest\n"))
+	assert.EqualError(err, "role-manifest.yml: invalid schema_version latest, expected a positive number")
+
+	_, err = migrateManifestSchema("role-manifest.yml", []byte("schema_version: 0\n"))
+	assert.EqualError(err, "role-manifest.yml: invalid schema_version 0, expected a positive number")
+}
+
+func TestMigrateManifestContents(t *testing.T) {
+	assert := assert.New(t)
+
+	// Pretend that version 2 renamed the roles key, and version 3 dropped
+	// the include key
+	migrations := []manifestMigration{
+		{
+			Version: 2,
+			Migrate: func(document yaml.MapSlice) (yaml.MapSlice, error) {
+				for i := range document {
+					if document[i].Key == "groups" {
+						document[i].Key = "roles"
+					}
+				}
+				return document, nil
+			},
+		},
+		{
+			Version: 3,
+			Migrate: func(document yaml.MapSlice) (yaml.MapSlice, error) {
+				if _, ok := lookupYAMLValue(document, "include"); ok {
+					return nil, fmt.Errorf("includes are gone")
+				}
+				return document, nil
+			},
+		},
+	}
+
+	migrated, err := migrateManifestContents("role-manifest.yml", []byte("# Comment\ngroups:\n- name: myrole\n"), 3, migrations)
+	assert.NoError(err)
+	assert.Equal("# Comment\nschema_version: 3\nroles:\n- name: myrole\n", string(migrated))
+
+	migrated, err = migrateManifestContents("role-manifest.yml", []byte("# Releases\nreleases: [tor] # Pinned\ngroups: []\n"), 2, migrations)
+	assert.NoError(err)
+	assert.Equal("# Releases\nreleases: [tor] # Pinned\nschema_version: 2\nroles: []\n", string(migrated),
+		"the keys the migrations don't change keep their comments and formatting")
+
+	migrated, err = migrateManifestContents("role-manifest.yml", []byte("schema_version: 2\ngroups: []\n"), 3, migrations)
+	assert.NoError(err)
+	assert.Equal("schema_version: 3\ngroups: []\n", string(migrated), "Migrations of earlier versions are skipped")
+
+	migrated, err = migrateManifestContents("role-manifest.yml", []byte("groups: []\n"), 2, migrations)
+	assert.NoError(err)
+	assert.Equal("schema_version: 2\nroles: []\n", string(migrated), "Migrations of later versions are skipped")
+
+	_, err = migrateManifestContents("role-manifest.yml", []byte("include: [other.yml]\n"), 3, migrations)
+	assert.EqualError(err, "Error migrating role-manifest.yml to schema version 3: includes are gone")
+}
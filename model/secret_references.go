@@ -0,0 +1,88 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretReference is a reference to a key of a secret in the configuration
+// templates of a role, as ((secret:<name>/<key>)). Unlike configuration
+// variables, its value is never part of the image nor of the defaults: the
+// templates read it from an environment variable, which the deployment sets
+// from the secret.
+type SecretReference struct {
+	Secret  string // Name of the secret
+	Key     string // Key of the value in the secret
+	EnvName string // Environment variable the templates read the value from
+}
+
+// secretReferencePattern matches the secret references in templates
+var secretReferencePattern = regexp.MustCompile(`\(\(\s*secret:([^()\s]*)\s*\)\)`)
+
+// GetSecretReferences returns the secret references in the configuration
+// templates of the role, sorted by environment variable
+func (r *Role) GetSecretReferences() []*SecretReference {
+	return r.secretReferences
+}
+
+// resolveSecretReferences replaces the secret references in the configuration
+// templates of the role with the environment variables their values are read
+// from, and records them. Environment variables are named after the secret
+// and key, e.g. SECRET_DB_CREDS_PASSWORD for ((secret:db-creds/password)).
+func (r *Role) resolveSecretReferences() error {
+	variables := map[string]bool{}
+	for _, variable := range r.rolesManifest.Configuration.Variables {
+		variables[variable.Name] = true
+	}
+
+	references := map[string]*SecretReference{}
+	var err error
+	resolve := func(reference string) string {
+		name := secretReferencePattern.FindStringSubmatch(reference)[1]
+		parts := strings.Split(name, "/")
+		if len(parts) != 2 || !secretNamePattern.MatchString(parts[0]) || !secretKeyPattern.MatchString(parts[1]) {
+			err = fmt.Errorf("Role %s: invalid secret reference %s, expected ((secret:<name>/<key>))", r.Name, reference)
+			return reference
+		}
+
+		envName := strings.Trim(LinkEnvName(fmt.Sprintf("secret_%s_%s", parts[0], parts[1])), "_")
+		if other, ok := references[envName]; ok && (other.Secret != parts[0] || other.Key != parts[1]) {
+			err = fmt.Errorf("Role %s: secret references %s/%s and %s/%s are both read from %s",
+				r.Name, other.Secret, other.Key, parts[0], parts[1], envName)
+			return reference
+		}
+		if variables[envName] {
+			err = fmt.Errorf("Role %s: secret reference %s is read from %s, which is a configuration variable", r.Name, reference, envName)
+			return reference
+		}
+		references[envName] = &SecretReference{Secret: parts[0], Key: parts[1], EnvName: envName}
+		return fmt.Sprintf("((%s))", envName)
+	}
+
+	// Templates are resolved in order, so errors are reported consistently
+	properties := make([]string, 0, len(r.Configuration.Templates))
+	for property := range r.Configuration.Templates {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+	for _, property := range properties {
+		template := r.Configuration.Templates[property]
+		r.Configuration.Templates[property] = secretReferencePattern.ReplaceAllStringFunc(template, resolve)
+		if err != nil {
+			return err
+		}
+	}
+
+	envNames := make([]string, 0, len(references))
+	for envName := range references {
+		envNames = append(envNames, envName)
+	}
+	sort.Strings(envNames)
+	r.secretReferences = make([]*SecretReference, 0, len(references))
+	for _, envName := range envNames {
+		r.secretReferences = append(r.secretReferences, references[envName])
+	}
+	return nil
+}
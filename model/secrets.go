@@ -0,0 +1,56 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ConfigurationVariableSecret names a key of a Kubernetes Secret that already
+// exists in the cluster. Variables referencing one get their value from that
+// secret instead of from the defaults, and are never generated.
+type ConfigurationVariableSecret struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+var (
+	// secretNamePattern matches DNS subdomains, which Kubernetes requires for
+	// the names of secrets
+	secretNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	// secretKeyPattern matches the allowed keys of secret data
+	secretKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// validateSecrets checks the secret references of the configuration variables
+func (c *Configuration) validateSecrets() error {
+	if c == nil {
+		return nil
+	}
+
+	for _, variable := range c.Variables {
+		if variable.Secret == nil {
+			continue
+		}
+		if err := variable.Secret.validate(); err != nil {
+			return fmt.Errorf("Variable %s: %s", variable.Name, err.Error())
+		}
+		if variable.Generator != nil {
+			return fmt.Errorf("Variable %s is read from secret %s, and can't have a generator", variable.Name, variable.Secret.Name)
+		}
+		if variable.Default != nil {
+			return fmt.Errorf("Variable %s is read from secret %s, and can't have a default", variable.Name, variable.Secret.Name)
+		}
+	}
+
+	return nil
+}
+
+func (s *ConfigurationVariableSecret) validate() error {
+	if !secretNamePattern.MatchString(s.Name) || len(s.Name) > 253 {
+		return fmt.Errorf("Invalid secret name '%s'", s.Name)
+	}
+	if !secretKeyPattern.MatchString(s.Key) || len(s.Key) > 253 {
+		return fmt.Errorf("Invalid key '%s' for secret %s", s.Key, s.Name)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSecrets(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc     string
+		variable ConfigurationVariable
+		err      string
+	}{
+		{
+			desc:     "Variables without a secret are valid",
+			variable: ConfigurationVariable{Name: "FOO", Default: "foo"},
+		},
+		{
+			desc: "Secret references are valid",
+			variable: ConfigurationVariable{
+				Name:   "FOO",
+				Secret: &ConfigurationVariableSecret{Name: "vault.db-creds", Key: "password"},
+			},
+		},
+		{
+			desc: "Secret names must be DNS subdomains",
+			variable: ConfigurationVariable{
+				Name:   "FOO",
+				Secret: &ConfigurationVariableSecret{Name: "DB_creds", Key: "password"},
+			},
+			err: "Variable FOO: Invalid secret name 'DB_creds'",
+		},
+		{
+			desc: "Secret keys are required",
+			variable: ConfigurationVariable{
+				Name:   "FOO",
+				Secret: &ConfigurationVariableSecret{Name: "db-creds"},
+			},
+			err: "Variable FOO: Invalid key '' for secret db-creds",
+		},
+		{
+			desc: "Secret variables are not generated",
+			variable: ConfigurationVariable{
+				Name:      "FOO",
+				Secret:    &ConfigurationVariableSecret{Name: "db-creds", Key: "password"},
+				Generator: &ConfigurationVariableGenerator{Type: "Password"},
+			},
+			err: "Variable FOO is read from secret db-creds, and can't have a generator",
+		},
+		{
+			desc: "Secret variables have no defaults",
+			variable: ConfigurationVariable{
+				Name:    "FOO",
+				Secret:  &ConfigurationVariableSecret{Name: "db-creds", Key: "password"},
+				Default: "hunter2",
+			},
+			err: "Variable FOO is read from secret db-creds, and can't have a default",
+		},
+	}
+
+	for _, sample := range samples {
+		variable := sample.variable
+		configuration := &Configuration{Variables: ConfigurationVariableSlice{&variable}}
+		err := configuration.validateSecrets()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
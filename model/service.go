@@ -0,0 +1,118 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// RoleRunService describes how traffic to the service of a role, or to the
+// service of one of its ports, is routed
+type RoleRunService struct {
+	Type                   string `yaml:"type"`                     // ClusterIP (the default), NodePort or LoadBalancer
+	SessionAffinity        string `yaml:"session-affinity"`         // None (the default) or ClientIP
+	SessionAffinityTimeout int32  `yaml:"session-affinity-timeout"` // In seconds; only for ClientIP
+	ExternalTrafficPolicy  string `yaml:"external-traffic-policy"`  // Cluster or Local; only for NodePort and LoadBalancer
+	InternalTrafficPolicy  string `yaml:"internal-traffic-policy"`  // Cluster or Local
+}
+
+// These are the valid service types, session affinities and traffic policies
+const (
+	ServiceTypeClusterIP    = "ClusterIP"
+	ServiceTypeNodePort     = "NodePort"
+	ServiceTypeLoadBalancer = "LoadBalancer"
+
+	SessionAffinityNone     = "None"
+	SessionAffinityClientIP = "ClientIP"
+	TrafficPolicyCluster    = "Cluster"
+	TrafficPolicyLocal      = "Local"
+)
+
+// MaxSessionAffinityTimeout is the longest session affinity Kubernetes allows,
+// in seconds
+const MaxSessionAffinityTimeout = 86400
+
+// portServiceNamePattern matches the names of ports that can have a service
+// of their own, which is named after the role and the port
+var portServiceNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// GetType returns the type of the service, ClusterIP unless it says otherwise
+func (s *RoleRunService) GetType() string {
+	if s == nil || s.Type == "" {
+		return ServiceTypeClusterIP
+	}
+	return s.Type
+}
+
+// validateService checks the service settings of a role against its ports,
+// and the ones of the ports that have a service of their own. The service of a
+// headless role resolves to its pods, so it can't be exposed outside of the
+// cluster.
+func (r *RoleRun) validateService(headless bool) error {
+	if r.Service != nil {
+		if len(r.ExposedPorts) == 0 {
+			return fmt.Errorf("Service settings need exposed ports")
+		}
+		if headless && r.Service.GetType() != ServiceTypeClusterIP {
+			return fmt.Errorf("Headless roles can't have the %s service type", r.Service.Type)
+		}
+		if err := r.Service.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, port := range r.ExposedPorts {
+		if port.Service == nil {
+			continue
+		}
+		if !portServiceNamePattern.MatchString(port.Name) {
+			return fmt.Errorf("Port %s has service settings, but its name can't name a service", port.Name)
+		}
+		if err := port.Service.validate(); err != nil {
+			return fmt.Errorf("Port %s: %s", port.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// validate checks service settings
+func (s *RoleRunService) validate() error {
+	switch s.Type {
+	case "", ServiceTypeClusterIP, ServiceTypeNodePort, ServiceTypeLoadBalancer:
+	default:
+		return fmt.Errorf("Invalid service type %s, expected %s, %s or %s", s.Type, ServiceTypeClusterIP, ServiceTypeNodePort, ServiceTypeLoadBalancer)
+	}
+
+	switch s.SessionAffinity {
+	case "", SessionAffinityNone:
+		if s.SessionAffinityTimeout != 0 {
+			return fmt.Errorf("Session affinity timeout needs the %s session affinity", SessionAffinityClientIP)
+		}
+	case SessionAffinityClientIP:
+		if s.SessionAffinityTimeout < 0 || s.SessionAffinityTimeout > MaxSessionAffinityTimeout {
+			return fmt.Errorf("Session affinity timeout %d should be between 1 and %d seconds", s.SessionAffinityTimeout, MaxSessionAffinityTimeout)
+		}
+	default:
+		return fmt.Errorf("Invalid session affinity %s", s.SessionAffinity)
+	}
+
+	switch s.ExternalTrafficPolicy {
+	case "":
+	case TrafficPolicyCluster, TrafficPolicyLocal:
+		// Kubernetes rejects it for services that are not reachable from
+		// outside of the cluster
+		if s.GetType() == ServiceTypeClusterIP {
+			return fmt.Errorf("External traffic policy needs the %s or %s service type", ServiceTypeNodePort, ServiceTypeLoadBalancer)
+		}
+	default:
+		return fmt.Errorf("Invalid external traffic policy %s", s.ExternalTrafficPolicy)
+	}
+
+	switch s.InternalTrafficPolicy {
+	case "", TrafficPolicyCluster, TrafficPolicyLocal:
+	default:
+		return fmt.Errorf("Invalid internal traffic policy %s", s.InternalTrafficPolicy)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateService(t *testing.T) {
+	assert := assert.New(t)
+
+	ports := []*RoleRunExposedPort{{Name: "http", External: "80", Internal: "8080"}}
+
+	samples := []struct {
+		desc     string
+		ports    []*RoleRunExposedPort
+		service  RoleRunService
+		headless bool
+		err      string
+	}{
+		{
+			desc:    "Sticky sessions are valid",
+			ports:   ports,
+			service: RoleRunService{SessionAffinity: SessionAffinityClientIP, SessionAffinityTimeout: 3600},
+		},
+		{
+			desc:    "Traffic policies are valid",
+			ports:   ports,
+			service: RoleRunService{Type: ServiceTypeLoadBalancer, ExternalTrafficPolicy: TrafficPolicyLocal, InternalTrafficPolicy: TrafficPolicyLocal},
+		},
+		{
+			desc:    "Service types are checked",
+			ports:   ports,
+			service: RoleRunService{Type: "ExternalName"},
+			err:     "Invalid service type ExternalName, expected ClusterIP, NodePort or LoadBalancer",
+		},
+		{
+			desc:     "Headless roles are only reachable in the cluster",
+			ports:    ports,
+			service:  RoleRunService{Type: ServiceTypeNodePort},
+			headless: true,
+			err:      "Headless roles can't have the NodePort service type",
+		},
+		{
+			desc:    "Service settings need ports",
+			service: RoleRunService{SessionAffinity: SessionAffinityClientIP},
+			err:     "Service settings need exposed ports",
+		},
+		{
+			desc:    "Session affinities are checked",
+			ports:   ports,
+			service: RoleRunService{SessionAffinity: "Cookie"},
+			err:     "Invalid session affinity Cookie",
+		},
+		{
+			desc:    "Timeouts need ClientIP affinity",
+			ports:   ports,
+			service: RoleRunService{SessionAffinityTimeout: 60},
+			err:     "Session affinity timeout needs the ClientIP session affinity",
+		},
+		{
+			desc:    "Timeouts are limited",
+			ports:   ports,
+			service: RoleRunService{SessionAffinity: SessionAffinityClientIP, SessionAffinityTimeout: 86401},
+			err:     "Session affinity timeout 86401 should be between 1 and 86400 seconds",
+		},
+		{
+			desc:    "External traffic policy needs a service reachable from outside of the cluster",
+			ports:   ports,
+			service: RoleRunService{ExternalTrafficPolicy: TrafficPolicyLocal},
+			err:     "External traffic policy needs the NodePort or LoadBalancer service type",
+		},
+		{
+			desc:    "Internal traffic policies are checked",
+			ports:   ports,
+			service: RoleRunService{InternalTrafficPolicy: "OnlyLocal"},
+			err:     "Invalid internal traffic policy OnlyLocal",
+		},
+	}
+
+	for _, sample := range samples {
+		service := sample.service
+		run := &RoleRun{ExposedPorts: sample.ports, Service: &service}
+		err := run.validateService(sample.headless)
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestValidatePortServices(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		port RoleRunExposedPort
+		err  string
+	}{
+		{
+			desc: "Ports can have a service of their own",
+			port: RoleRunExposedPort{Name: "https", Service: &RoleRunService{Type: ServiceTypeNodePort, ExternalTrafficPolicy: TrafficPolicyLocal}},
+		},
+		{
+			desc: "The settings of port services are checked",
+			port: RoleRunExposedPort{Name: "https", Service: &RoleRunService{ExternalTrafficPolicy: TrafficPolicyLocal}},
+			err:  "Port https: External traffic policy needs the NodePort or LoadBalancer service type",
+		},
+		{
+			desc: "Port services are named after the port",
+			port: RoleRunExposedPort{Name: "HTTP_S", Service: &RoleRunService{}},
+			err:  "Port HTTP_S has service settings, but its name can't name a service",
+		},
+	}
+
+	for _, sample := range samples {
+		port := sample.port
+		run := &RoleRun{ExposedPorts: []*RoleRunExposedPort{&port}}
+		err := run.validateService(false)
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
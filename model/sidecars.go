@@ -0,0 +1,123 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RoleRunSidecar is an additional container in the pods of a role, like a log
+// forwarder or a metrics exporter. It runs its own image, and sees the
+// directories of the role container it mounts.
+type RoleRunSidecar struct {
+	Name    string                 `yaml:"name"`
+	Image   string                 `yaml:"image"`
+	Command []string               `yaml:"command"` // The entrypoint of the image when empty
+	Mounts  []*RoleRunSidecarMount `yaml:"mounts"`
+}
+
+// RoleRunSidecarMount shares a directory of the role container with a
+// sidecar. The persistent and shared volumes of the role are shared as they
+// are; other directories become a volume of the pod that starts out empty,
+// mounted into the role container too.
+type RoleRunSidecarMount struct {
+	Path      string `yaml:"path"`       // Directory of the role container, e.g. /var/vcap/sys/log
+	MountPath string `yaml:"mount-path"` // Where the sidecar sees the directory; Path by default
+	ReadOnly  bool   `yaml:"read-only"`
+}
+
+// GetMountPath returns where the sidecar sees the directory
+func (m *RoleRunSidecarMount) GetMountPath() string {
+	if m.MountPath == "" {
+		return m.Path
+	}
+	return m.MountPath
+}
+
+// GetVolume returns the persistent or shared volume of the role at a path, or
+// nil if there is none
+func (r *RoleRun) GetVolume(volumePath string) *RoleRunVolume {
+	for _, volume := range append(append([]*RoleRunVolume{}, r.PersistentVolumes...), r.SharedVolumes...) {
+		if volume.Path == volumePath {
+			return volume
+		}
+	}
+	return nil
+}
+
+// GetSidecarPaths returns the directories of the role container that the
+// sidecars mount, other than its volumes, in the order the sidecars mount them
+func (r *RoleRun) GetSidecarPaths() []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, sidecar := range r.Sidecars {
+		for _, mount := range sidecar.Mounts {
+			if seen[mount.Path] || r.GetVolume(mount.Path) != nil || r.GetTmpfsVolume(mount.Path) != nil {
+				continue
+			}
+			seen[mount.Path] = true
+			result = append(result, mount.Path)
+		}
+	}
+	return result
+}
+
+// isPathUnder reports whether a path is a directory under another one
+func isPathUnder(child, parent string) bool {
+	return strings.HasPrefix(child, strings.TrimSuffix(parent, "/")+"/")
+}
+
+// validateSidecars checks the sidecars of a role; their names are distinct
+// from each other and from the role container, and their mounts don't clash
+// with the other directories mounted into the role container
+func (r *Role) validateSidecars() error {
+	if r.Run == nil || len(r.Run.Sidecars) == 0 {
+		return nil
+	}
+	if !r.IsLongRunning() {
+		return fmt.Errorf("Role %s has sidecars, but is of type %s", r.Name, r.Type)
+	}
+
+	volumes := append(append([]*RoleRunVolume{}, r.Run.PersistentVolumes...), r.Run.SharedVolumes...)
+	names := map[string]bool{r.Name: true}
+	for _, sidecar := range r.Run.Sidecars {
+		if !volumeTagPattern.MatchString(sidecar.Name) {
+			return fmt.Errorf("Role %s: invalid sidecar name '%s', expected a DNS label", r.Name, sidecar.Name)
+		}
+		if names[sidecar.Name] {
+			return fmt.Errorf("Role %s: sidecar name %s is used more than once", r.Name, sidecar.Name)
+		}
+		names[sidecar.Name] = true
+
+		if sidecar.Image == "" {
+			return fmt.Errorf("Role %s: sidecar %s has no image", r.Name, sidecar.Name)
+		}
+
+		mountPaths := map[string]bool{}
+		for _, mount := range sidecar.Mounts {
+			for _, mountPath := range []string{mount.Path, mount.GetMountPath()} {
+				if !path.IsAbs(mountPath) || path.Clean(mountPath) != mountPath || mountPath == "/" {
+					return fmt.Errorf("Role %s: sidecar %s has an invalid mount path '%s', expected an absolute path", r.Name, sidecar.Name, mountPath)
+				}
+			}
+			if mountPaths[mount.GetMountPath()] {
+				return fmt.Errorf("Role %s: sidecar %s mounts %s more than once", r.Name, sidecar.Name, mount.GetMountPath())
+			}
+			mountPaths[mount.GetMountPath()] = true
+
+			for _, volume := range volumes {
+				if isPathUnder(mount.Path, volume.Path) || isPathUnder(volume.Path, mount.Path) {
+					return fmt.Errorf("Role %s: sidecar %s mounts %s, which overlaps volume %s; mount %s instead",
+						r.Name, sidecar.Name, mount.Path, volume.Tag, volume.Path)
+				}
+			}
+			for _, externalMount := range r.Run.ExternalMounts {
+				if mount.Path == externalMount.Path {
+					return fmt.Errorf("Role %s: sidecar %s mounts %s, the path of external mount %s", r.Name, sidecar.Name, mount.Path, externalMount)
+				}
+			}
+		}
+	}
+
+	return nil
+}
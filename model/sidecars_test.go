@@ -0,0 +1,119 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSidecars(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc     string
+		roleType RoleType
+		sidecars []*RoleRunSidecar
+		err      string
+	}{
+		{
+			desc: "No sidecars are valid",
+		},
+		{
+			desc: "Sidecars mounting directories and volumes are valid",
+			sidecars: []*RoleRunSidecar{
+				{Name: "log-forwarder", Image: "fluent/fluent-bit", Mounts: []*RoleRunSidecarMount{
+					{Path: "/var/vcap/sys/log", MountPath: "/logs", ReadOnly: true},
+					{Path: "/mnt/persistent"},
+				}},
+				{Name: "metrics", Image: "prom/statsd-exporter", Command: []string{"/bin/statsd_exporter"}},
+			},
+		},
+		{
+			desc:     "Only long running roles have sidecars",
+			roleType: RoleTypeBoshTask,
+			sidecars: []*RoleRunSidecar{{Name: "metrics", Image: "prom/statsd-exporter"}},
+			err:      "Role myrole has sidecars, but is of type bosh-task",
+		},
+		{
+			desc:     "Names are DNS labels",
+			sidecars: []*RoleRunSidecar{{Name: "log_forwarder", Image: "fluent/fluent-bit"}},
+			err:      "Role myrole: invalid sidecar name 'log_forwarder', expected a DNS label",
+		},
+		{
+			desc:     "Names are distinct from the role",
+			sidecars: []*RoleRunSidecar{{Name: "myrole", Image: "fluent/fluent-bit"}},
+			err:      "Role myrole: sidecar name myrole is used more than once",
+		},
+		{
+			desc:     "Sidecars have an image",
+			sidecars: []*RoleRunSidecar{{Name: "metrics"}},
+			err:      "Role myrole: sidecar metrics has no image",
+		},
+		{
+			desc: "Mount paths are absolute",
+			sidecars: []*RoleRunSidecar{{Name: "metrics", Image: "prom/statsd-exporter", Mounts: []*RoleRunSidecarMount{
+				{Path: "/var/vcap/sys/log", MountPath: "logs"},
+			}}},
+			err: "Role myrole: sidecar metrics has an invalid mount path 'logs', expected an absolute path",
+		},
+		{
+			desc: "Mount paths are distinct",
+			sidecars: []*RoleRunSidecar{{Name: "metrics", Image: "prom/statsd-exporter", Mounts: []*RoleRunSidecarMount{
+				{Path: "/var/vcap/sys/log", MountPath: "/data"},
+				{Path: "/var/vcap/data", MountPath: "/data"},
+			}}},
+			err: "Role myrole: sidecar metrics mounts /data more than once",
+		},
+		{
+			desc: "Mounts don't overlap volumes",
+			sidecars: []*RoleRunSidecar{{Name: "metrics", Image: "prom/statsd-exporter", Mounts: []*RoleRunSidecarMount{
+				{Path: "/mnt/persistent/metrics"},
+			}}},
+			err: "Role myrole: sidecar metrics mounts /mnt/persistent/metrics, which overlaps volume persistent-volume; mount /mnt/persistent instead",
+		},
+		{
+			desc: "Mounts don't override external mounts",
+			sidecars: []*RoleRunSidecar{{Name: "metrics", Image: "prom/statsd-exporter", Mounts: []*RoleRunSidecarMount{
+				{Path: "/etc/ssl/corporate"},
+			}}},
+			err: "Role myrole: sidecar metrics mounts /etc/ssl/corporate, the path of external mount configmap corporate-ca",
+		},
+	}
+
+	for _, sample := range samples {
+		role := &Role{
+			Name: "myrole",
+			Type: RoleTypeBosh,
+			Run: &RoleRun{
+				PersistentVolumes: []*RoleRunVolume{{Path: "/mnt/persistent", Tag: "persistent-volume"}},
+				ExternalMounts:    []*RoleRunExternalMount{{ConfigMap: "corporate-ca", Path: "/etc/ssl/corporate"}},
+				Sidecars:          sample.sidecars,
+			},
+		}
+		if sample.roleType != "" {
+			role.Type = sample.roleType
+		}
+
+		err := role.validateSidecars()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestRoleRunGetSidecarPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	run := &RoleRun{
+		SharedVolumes: []*RoleRunVolume{{Path: "/mnt/shared", Tag: "shared-volume"}},
+		Sidecars: []*RoleRunSidecar{
+			{Name: "log-forwarder", Mounts: []*RoleRunSidecarMount{{Path: "/var/vcap/sys/log"}, {Path: "/mnt/shared"}}},
+			{Name: "metrics", Mounts: []*RoleRunSidecarMount{{Path: "/var/vcap/data/metrics"}, {Path: "/var/vcap/sys/log", MountPath: "/logs"}}},
+		},
+	}
+	assert.Equal([]string{"/var/vcap/sys/log", "/var/vcap/data/metrics"}, run.GetSidecarPaths())
+	assert.Equal("shared-volume", run.GetVolume("/mnt/shared").Tag)
+	assert.Nil(run.GetVolume("/var/vcap/sys/log"))
+}
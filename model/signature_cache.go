@@ -0,0 +1,108 @@
+package model
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// signatureCache remembers the signatures of the file trees hashed into role
+// versions, by the size and modification time of their files, so the files
+// are only read again once they change. The versions of the roles are
+// computed many times by most commands, e.g. for every role image and every
+// kube resource referencing one.
+type signatureCache struct {
+	sync.Mutex
+	entries map[string]cachedSignature
+}
+
+// cachedSignature is the signature of a file tree, along with the sizes and
+// modification times of its files when it was computed
+type cachedSignature struct {
+	stamp     string
+	signature string
+}
+
+var treeSignatures = &signatureCache{entries: map[string]cachedSignature{}}
+
+// treeSignature returns the signature of the header and the regular files
+// below dir, by their path relative to it. Files that can't be read are
+// left out.
+func treeSignature(dir string, header []byte) string {
+	type treeFile struct {
+		path         string
+		relativePath string
+	}
+	var files []treeFile
+
+	headerSum := sha1.Sum(header)
+	key := fmt.Sprintf("%s\n%x", dir, headerSum)
+	stampHasher := sha1.New()
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		relativePath, _ := filepath.Rel(dir, path)
+		files = append(files, treeFile{path: path, relativePath: relativePath})
+		fmt.Fprintf(stampHasher, "%s\n%d\n%d\n", relativePath, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	stamp := hex.EncodeToString(stampHasher.Sum(nil))
+
+	treeSignatures.Lock()
+	cached, ok := treeSignatures.entries[key]
+	treeSignatures.Unlock()
+	if ok && cached.stamp == stamp {
+		return cached.signature
+	}
+
+	hasher := sha1.New()
+	hasher.Write(header)
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file.path)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(hasher, "\n%s\n%s", file.relativePath, contents)
+	}
+	signature := hex.EncodeToString(hasher.Sum(nil))
+
+	treeSignatures.Lock()
+	treeSignatures.entries[key] = cachedSignature{stamp: stamp, signature: signature}
+	treeSignatures.Unlock()
+	return signature
+}
+
+// getRoleDevVersions returns the dev versions of the roles, in their order,
+// computing them concurrently
+func getRoleDevVersions(roles Roles) []string {
+	versions := make([]string, len(roles))
+	indexes := make(chan int, len(roles))
+	for i := range roles {
+		indexes <- i
+	}
+	close(indexes)
+
+	workers := runtime.NumCPU()
+	if workers > len(roles) {
+		workers = len(roles)
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				versions[i] = roles[i].GetRoleDevVersion()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return versions
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "fissile-test")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "setup.sh")
+	assert.NoError(ioutil.WriteFile(path, []byte("echo one"), 0644))
+	info, err := os.Stat(path)
+	if !assert.NoError(err) {
+		return
+	}
+
+	signature := treeSignature(dir, []byte("RUN setup.sh"))
+	assert.Equal(signature, treeSignature(dir, []byte("RUN setup.sh")), "the signature is stable")
+	assert.NotEqual(signature, treeSignature(dir, []byte("RUN ./setup.sh")), "the header is part of the signature")
+
+	// A file of the same size and modification time is taken as unchanged,
+	// without reading it again
+	assert.NoError(ioutil.WriteFile(path, []byte("echo two"), 0644))
+	assert.NoError(os.Chtimes(path, info.ModTime(), info.ModTime()))
+	assert.Equal(signature, treeSignature(dir, []byte("RUN setup.sh")), "unchanged files are not read again")
+
+	assert.NoError(ioutil.WriteFile(path, []byte("echo three"), 0644))
+	changed := treeSignature(dir, []byte("RUN setup.sh"))
+	assert.NotEqual(signature, changed, "changed files are read again")
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "other.sh"), []byte("echo other"), 0644))
+	assert.NotEqual(changed, treeSignature(dir, []byte("RUN setup.sh")), "added files are part of the signature")
+}
+
+func TestGetRoleDevVersions(t *testing.T) {
+	assert := assert.New(t)
+
+	var roles Roles
+	for _, image := range []string{"alpine", "busybox", "ubuntu", "debian"} {
+		roles = append(roles, &Role{Name: image, Image: image})
+	}
+
+	versions := getRoleDevVersions(roles)
+	if assert.Len(versions, len(roles)) {
+		for i, role := range roles {
+			assert.Equal(role.GetRoleDevVersion(), versions[i], "the versions are in the order of the roles")
+		}
+	}
+	assert.Empty(getRoleDevVersions(nil))
+}
@@ -0,0 +1,75 @@
+package model
+
+// ManifestStats summarizes a role manifest
+type ManifestStats struct {
+	RolesByType    map[RoleType]int
+	Jobs           int            // Jobs of all roles, counted once per role
+	Packages       int            // Packages of all roles, counted once per role
+	UniquePackages int            // Packages of all roles, by fingerprint
+	Memory         ResourceTotals // In MB
+	VirtualCPUs    ResourceTotals
+	Resources      map[string]ResourceTotals // Extended resources
+	ExposedPorts   int
+	PublicPorts    int
+	Images         int // Role images fissile builds
+}
+
+// ResourceTotals is the amount of a resource requested by all roles, at their
+// minimum and maximum scale
+type ResourceTotals struct {
+	Min int
+	Max int
+}
+
+// add adds the requests of a role to the totals
+func (t *ResourceTotals) add(amount int, scaling *RoleRunScaling) {
+	min, max := 1, 1
+	if scaling != nil {
+		min, max = int(scaling.Min), int(scaling.Max)
+	}
+	t.Min += amount * min
+	t.Max += amount * max
+}
+
+// Stats returns the summary of the roles of the manifest. Port ranges are
+// counted as one port.
+func (m *RoleManifest) Stats() *ManifestStats {
+	stats := &ManifestStats{
+		RolesByType: map[RoleType]int{},
+		Resources:   map[string]ResourceTotals{},
+	}
+
+	fingerprints := map[string]bool{}
+	for _, role := range m.Roles {
+		stats.RolesByType[role.Type]++
+		stats.Images++
+		stats.Jobs += len(role.Jobs)
+
+		packages := role.GetPackages()
+		stats.Packages += len(packages)
+		for _, pkg := range packages {
+			fingerprints[pkg.Fingerprint] = true
+		}
+
+		if role.Run == nil {
+			continue
+		}
+		scaling := role.Run.scalingRange()
+		stats.Memory.add(role.Run.Memory, scaling)
+		stats.VirtualCPUs.add(role.Run.VirtualCPUs, scaling)
+		for name, amount := range role.Run.Resources {
+			totals := stats.Resources[name]
+			totals.add(amount, scaling)
+			stats.Resources[name] = totals
+		}
+		for _, port := range role.Run.ExposedPorts {
+			stats.ExposedPorts++
+			if port.Public {
+				stats.PublicPorts++
+			}
+		}
+	}
+	stats.UniquePackages = len(fingerprints)
+
+	return stats
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleManifestStats(t *testing.T) {
+	assert := assert.New(t)
+
+	pkgA := &Package{Name: "a", Fingerprint: "aaa"}
+	pkgB := &Package{Name: "b", Fingerprint: "bbb"}
+	manifest := &RoleManifest{Roles: Roles{
+		{
+			Name: "api",
+			Type: RoleTypeBosh,
+			Jobs: Jobs{
+				{Name: "api", Packages: Packages{pkgA, pkgB}},
+				{Name: "metron", Packages: Packages{pkgA}},
+			},
+			Run: &RoleRun{
+				Scaling:     &RoleRunScaling{Min: 2, Max: 4},
+				Memory:      256,
+				VirtualCPUs: 1,
+				Resources:   map[string]int{"nvidia.com/gpu": 1},
+				ExposedPorts: []*RoleRunExposedPort{
+					{Name: "http", External: "80", Internal: "8080", Public: true},
+					{Name: "admin", External: "9000", Internal: "9000"},
+				},
+			},
+		},
+		{
+			Name: "smoke-tests",
+			Type: RoleTypeBoshTask,
+			Jobs: Jobs{
+				{Name: "smoke-tests", Packages: Packages{pkgB}},
+			},
+			Run: &RoleRun{
+				Scaling: &RoleRunScaling{Min: 1, Max: 1},
+				Memory:  128,
+			},
+		},
+		{Name: "no-run", Type: RoleTypeBosh},
+	}}
+
+	stats := manifest.Stats()
+	assert.Equal(map[RoleType]int{RoleTypeBosh: 2, RoleTypeBoshTask: 1}, stats.RolesByType)
+	assert.Equal(3, stats.Jobs)
+	assert.Equal(3, stats.Packages)
+	assert.Equal(2, stats.UniquePackages)
+	assert.Equal(ResourceTotals{Min: 640, Max: 1152}, stats.Memory)
+	assert.Equal(ResourceTotals{Min: 2, Max: 4}, stats.VirtualCPUs)
+	assert.Equal(map[string]ResourceTotals{"nvidia.com/gpu": {Min: 2, Max: 4}}, stats.Resources)
+	assert.Equal(2, stats.ExposedPorts)
+	assert.Equal(1, stats.PublicPorts)
+	assert.Equal(3, stats.Images)
+}
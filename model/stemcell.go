@@ -0,0 +1,123 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cppforlife/go-semi-semantic/version"
+)
+
+// Stemcell identifies the OS and version of a stemcell, written as
+// <os>/<version> (e.g. ubuntu-trusty/3421.11). The version may be empty.
+type Stemcell struct {
+	OS      string
+	Version string
+}
+
+// ParseStemcell parses a stemcell written as <os>/<version> or <os>
+func ParseStemcell(stemcell string) (*Stemcell, error) {
+	parts := strings.SplitN(strings.TrimSpace(stemcell), "/", 2)
+	if parts[0] == "" {
+		return nil, fmt.Errorf("Invalid stemcell %q, expected <os>/<version>", stemcell)
+	}
+
+	result := &Stemcell{OS: parts[0]}
+	if len(parts) > 1 {
+		if _, err := version.NewVersionFromString(parts[1]); err != nil {
+			return nil, fmt.Errorf("Invalid version for stemcell %q: %s", stemcell, err.Error())
+		}
+		result.Version = parts[1]
+	}
+
+	return result, nil
+}
+
+func (s *Stemcell) String() string {
+	if s.Version == "" {
+		return s.OS
+	}
+	return fmt.Sprintf("%s/%s", s.OS, s.Version)
+}
+
+// SatisfiedBy returns true if the given stemcell meets this stemcell as a
+// constraint: the OS must be the same, and, like BOSH does for compiled
+// releases, the major version must match and the version be no older than
+// the one of the constraint.
+func (s *Stemcell) SatisfiedBy(stemcell *Stemcell) bool {
+	if s.OS != stemcell.OS {
+		return false
+	}
+	if s.Version == "" {
+		return true
+	}
+	if stemcell.Version == "" {
+		return false
+	}
+
+	major := func(v string) string {
+		return strings.SplitN(v, ".", 2)[0]
+	}
+	if major(s.Version) != major(stemcell.Version) {
+		return false
+	}
+
+	constraint, err := version.NewVersionFromString(s.Version)
+	if err != nil {
+		return false
+	}
+	actual, err := version.NewVersionFromString(stemcell.Version)
+	if err != nil {
+		return false
+	}
+
+	return !constraint.IsGt(actual)
+}
+
+// loadStemcellConstraints reads the stemcells the release was built for from
+// its manifest, if it names any. Releases can name one in a "stemcell" key,
+// either as <os>/<version> or as a map with "os" and "version"; compiled
+// releases name one for each of their compiled packages.
+func (r *Release) loadStemcellConstraints() error {
+	var stemcells []string
+
+	switch value := r.manifest["stemcell"].(type) {
+	case nil:
+	case string:
+		stemcells = append(stemcells, value)
+	case map[interface{}]interface{}:
+		osName, _ := value["os"].(string)
+		if stemcellVersion, ok := value["version"]; ok && stemcellVersion != nil {
+			stemcells = append(stemcells, fmt.Sprintf("%s/%v", osName, stemcellVersion))
+		} else {
+			stemcells = append(stemcells, osName)
+		}
+	default:
+		return fmt.Errorf("Invalid stemcell in release %s: %v", r.Name, value)
+	}
+
+	if compiledPackages, ok := r.manifest["compiled_packages"].([]interface{}); ok {
+		for _, pkg := range compiledPackages {
+			if pkgMap, ok := pkg.(map[interface{}]interface{}); ok {
+				if stemcell, ok := pkgMap["stemcell"].(string); ok {
+					stemcells = append(stemcells, stemcell)
+				}
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, stemcell := range stemcells {
+		if seen[stemcell] {
+			continue
+		}
+		seen[stemcell] = true
+
+		constraint, err := ParseStemcell(stemcell)
+		if err != nil {
+			return fmt.Errorf("Error loading stemcell of release %s: %s", r.Name, err.Error())
+		}
+		r.StemcellConstraints = append(r.StemcellConstraints, constraint)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStemcell(t *testing.T) {
+	assert := assert.New(t)
+
+	stemcell, err := ParseStemcell("ubuntu-trusty/3421.11")
+	if assert.NoError(err) {
+		assert.Equal(&Stemcell{OS: "ubuntu-trusty", Version: "3421.11"}, stemcell)
+		assert.Equal("ubuntu-trusty/3421.11", stemcell.String())
+	}
+
+	stemcell, err = ParseStemcell("opensuse-42")
+	if assert.NoError(err) {
+		assert.Equal(&Stemcell{OS: "opensuse-42"}, stemcell)
+	}
+
+	_, err = ParseStemcell("/3421")
+	assert.EqualError(err, `Invalid stemcell "/3421", expected <os>/<version>`)
+}
+
+func TestStemcellSatisfiedBy(t *testing.T) {
+	assert := assert.New(t)
+
+	constraint := &Stemcell{OS: "ubuntu-trusty", Version: "3421.11"}
+
+	assert.True(constraint.SatisfiedBy(&Stemcell{OS: "ubuntu-trusty", Version: "3421.11"}))
+	assert.True(constraint.SatisfiedBy(&Stemcell{OS: "ubuntu-trusty", Version: "3421.26"}))
+	assert.False(constraint.SatisfiedBy(&Stemcell{OS: "ubuntu-trusty", Version: "3421.3"}), "older minor versions are too old")
+	assert.False(constraint.SatisfiedBy(&Stemcell{OS: "ubuntu-trusty", Version: "3445.2"}), "major versions must match")
+	assert.False(constraint.SatisfiedBy(&Stemcell{OS: "ubuntu-trusty"}), "unknown versions don't satisfy a version")
+	assert.False(constraint.SatisfiedBy(&Stemcell{OS: "ubuntu-xenial", Version: "3421.11"}))
+
+	assert.True((&Stemcell{OS: "ubuntu-trusty"}).SatisfiedBy(&Stemcell{OS: "ubuntu-trusty", Version: "3445.2"}))
+}
+
+func TestReleaseLoadStemcellConstraints(t *testing.T) {
+	assert := assert.New(t)
+
+	release := &Release{Name: "compiled", manifest: map[interface{}]interface{}{
+		"stemcell": map[interface{}]interface{}{"os": "ubuntu-trusty", "version": 3421},
+		"compiled_packages": []interface{}{
+			map[interface{}]interface{}{"name": "foo", "stemcell": "ubuntu-trusty/3421.11"},
+			map[interface{}]interface{}{"name": "bar", "stemcell": "ubuntu-trusty/3421.11"},
+		},
+	}}
+	if assert.NoError(release.loadStemcellConstraints()) {
+		assert.Equal([]*Stemcell{
+			{OS: "ubuntu-trusty", Version: "3421"},
+			{OS: "ubuntu-trusty", Version: "3421.11"},
+		}, release.StemcellConstraints)
+	}
+
+	release = &Release{Name: "source", manifest: map[interface{}]interface{}{}}
+	if assert.NoError(release.loadStemcellConstraints()) {
+		assert.Empty(release.StemcellConstraints)
+	}
+}
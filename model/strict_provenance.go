@@ -0,0 +1,64 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var imageDigestPattern = regexp.MustCompile(`@sha256:[0-9a-f]{64}$`)
+
+// CheckImagePinned returns an error for an image that is referenced by tag
+// only; what names the image in the error
+func CheckImagePinned(what, image string) error {
+	if imageDigestPattern.MatchString(image) {
+		return nil
+	}
+	return fmt.Errorf("%s %s is not pinned by digest, expected <image>@sha256:<hex>", what, image)
+}
+
+// validateProvenance checks that the inputs of a role are pinned by a digest
+// or fingerprint, for strict provenance: images referenced by tag only, apt
+// packages without a version, release jobs and packages without a sha1, and
+// releases created with uncommitted changes, which their commit doesn't pin.
+// Yum packages carry their version in their name, so only apt packages are
+// checked for one.
+func (r *Role) validateProvenance() error {
+	if r.Image != "" {
+		if err := CheckImagePinned(fmt.Sprintf("Role %s: image", r.Name), r.Image); err != nil {
+			return err
+		}
+	}
+	if r.Run != nil {
+		for _, sidecar := range r.Run.Sidecars {
+			if err := CheckImagePinned(fmt.Sprintf("Role %s: sidecar %s image", r.Name, sidecar.Name), sidecar.Image); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.OSPackages != nil && r.OSPackages.Manager == OSPackageManagerApt {
+		for _, pkg := range r.OSPackages.Packages {
+			if !strings.Contains(pkg, "=") {
+				return fmt.Errorf("Role %s: OS package %s is not pinned to a version, expected <package>=<version>", r.Name, pkg)
+			}
+		}
+	}
+
+	for _, job := range r.Jobs {
+		if job.Release.UncommittedChanges {
+			return fmt.Errorf("Role %s: release %s of job %s has uncommitted changes, so its commit %s doesn't pin it",
+				r.Name, job.Release.Name, job.Name, job.Release.CommitHash)
+		}
+		if job.SHA1 == "" {
+			return fmt.Errorf("Role %s: job %s of release %s is not pinned by a sha1", r.Name, job.Name, job.Release.Name)
+		}
+		for _, pkg := range job.Packages {
+			if pkg.SHA1 == "" {
+				return fmt.Errorf("Role %s: package %s of release %s is not pinned by a sha1", r.Name, pkg.Name, pkg.Release.Name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateProvenance(t *testing.T) {
+	assert := assert.New(t)
+
+	digest := "@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	release := &Release{Name: "tor"}
+	pinnedJob := &Job{Name: "tor", SHA1: "abc", Release: release, Packages: Packages{
+		{Name: "libevent", SHA1: "def", Release: release},
+	}}
+
+	samples := []struct {
+		desc string
+		role *Role
+		err  string
+	}{
+		{
+			desc: "Pinned inputs are valid",
+			role: &Role{
+				Name:       "myrole",
+				Jobs:       Jobs{pinnedJob},
+				OSPackages: &RoleOSPackages{Manager: OSPackageManagerApt, Packages: []string{"libfuse2=2.9.4-1ubuntu3"}},
+				Run:        &RoleRun{Sidecars: []*RoleRunSidecar{{Name: "metrics", Image: "prom/statsd-exporter" + digest}}},
+			},
+		},
+		{
+			desc: "Docker role images are pinned by digest",
+			role: &Role{Name: "myrole", Image: "mysql:5.7"},
+			err:  "Role myrole: image mysql:5.7 is not pinned by digest, expected <image>@sha256:<hex>",
+		},
+		{
+			desc: "Sidecar images are pinned by digest",
+			role: &Role{Name: "myrole", Run: &RoleRun{Sidecars: []*RoleRunSidecar{{Name: "metrics", Image: "prom/statsd-exporter"}}}},
+			err:  "Role myrole: sidecar metrics image prom/statsd-exporter is not pinned by digest, expected <image>@sha256:<hex>",
+		},
+		{
+			desc: "Apt packages are pinned to a version",
+			role: &Role{Name: "myrole", OSPackages: &RoleOSPackages{Manager: OSPackageManagerApt, Packages: []string{"libfuse2"}}},
+			err:  "Role myrole: OS package libfuse2 is not pinned to a version, expected <package>=<version>",
+		},
+		{
+			desc: "Packages are pinned by a sha1",
+			role: &Role{Name: "myrole", Jobs: Jobs{{Name: "tor", SHA1: "abc", Release: release, Packages: Packages{
+				{Name: "libevent", Release: release},
+			}}}},
+			err: "Role myrole: package libevent of release tor is not pinned by a sha1",
+		},
+		{
+			desc: "Releases have no uncommitted changes",
+			role: &Role{Name: "myrole", Jobs: Jobs{{Name: "tor", SHA1: "abc", Release: &Release{
+				Name: "tor", CommitHash: "a1b2c3d", UncommittedChanges: true,
+			}}}},
+			err: "Role myrole: release tor of job tor has uncommitted changes, so its commit a1b2c3d doesn't pin it",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.role.validateProvenance()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else if assert.Error(err, sample.desc) {
+			assert.Equal(sample.err, err.Error(), sample.desc)
+		}
+	}
+
+	assert.NoError(CheckImagePinned("Base image", "ubuntu"+digest))
+	assert.EqualError(CheckImagePinned("Base image", "ubuntu:14.04"),
+		"Base image ubuntu:14.04 is not pinned by digest, expected <image>@sha256:<hex>")
+}
+
+func TestLoadRoleManifestStrictProvenance(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/docker-role-no-run.yml")
+
+	_, err = LoadRoleManifest(roleManifestPath, nil)
+	assert.NoError(err, "Unpinned inputs are fine without strict provenance")
+
+	_, err = LoadRoleManifestWithOptions(roleManifestPath, nil, RoleManifestOptions{StrictProvenance: true})
+	assert.EqualError(err, "Role myrole: image redis:4 is not pinned by digest, expected <image>@sha256:<hex>")
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// safeSysctls are the sysctls Kubernetes considers safe; they are namespaced
+// and allowed by every kubelet
+var safeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":       true,
+	"net.ipv4.ip_local_port_range": true,
+	"net.ipv4.tcp_syncookies":      true,
+}
+
+// namespacedSysctlPrefixes are the prefixes of sysctls that are namespaced
+// by the kernel, and can therefore be set per pod. Those that are not safe
+// need to be enabled explicitly on the kubelet.
+var namespacedSysctlPrefixes = []string{
+	"kernel.shm",
+	"kernel.msg",
+	"kernel.sem",
+	"fs.mqueue.",
+	"net.",
+}
+
+var (
+	sysctlNamePattern = regexp.MustCompile(`^[a-z0-9]+([._][a-z0-9_-]+)*$`)
+	timeZonePattern   = regexp.MustCompile(`^[A-Za-z0-9_+-]+(/[A-Za-z0-9_+-]+)*$`)
+	localePattern     = regexp.MustCompile(`^[A-Za-z]+(_[A-Za-z]+)?(\.[A-Za-z0-9-]+)?(@[A-Za-z0-9]+)?$`)
+)
+
+// IsSafeSysctl returns true if the sysctl is allowed by Kubernetes without
+// any special kubelet configuration
+func IsSafeSysctl(name string) bool {
+	return safeSysctls[name]
+}
+
+// isNamespacedSysctl returns true if the sysctl can be set for a single pod
+func isNamespacedSysctl(name string) bool {
+	for _, prefix := range namespacedSysctlPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSystemSettings checks the sysctls, time zone and locale of a role
+func (r *RoleRun) validateSystemSettings() error {
+	for name, value := range r.Sysctls {
+		if !sysctlNamePattern.MatchString(name) {
+			return fmt.Errorf("Invalid sysctl name %s", name)
+		}
+		if !isNamespacedSysctl(name) {
+			return fmt.Errorf("Sysctl %s is not namespaced and cannot be set for a role", name)
+		}
+		if value == "" || strings.ContainsAny(value, ",=") {
+			return fmt.Errorf("Invalid value %q for sysctl %s", value, name)
+		}
+	}
+
+	if r.TimeZone != "" && !timeZonePattern.MatchString(r.TimeZone) {
+		return fmt.Errorf("Invalid time zone %s", r.TimeZone)
+	}
+
+	if r.Locale != "" && !localePattern.MatchString(r.Locale) {
+		return fmt.Errorf("Invalid locale %s", r.Locale)
+	}
+
+	return nil
+}
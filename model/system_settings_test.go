@@ -0,0 +1,79 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSystemSettings(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		run  RoleRun
+		err  string
+	}{
+		{
+			desc: "Empty settings are valid",
+		},
+		{
+			desc: "Safe and namespaced sysctls are valid",
+			run: RoleRun{
+				Sysctls: map[string]string{
+					"net.core.somaxconn":      "1024",
+					"net.ipv4.tcp_syncookies": "1",
+					"kernel.msgmax":           "65536",
+				},
+				TimeZone: "Europe/Bucharest",
+				Locale:   "en_US.UTF-8",
+			},
+		},
+		{
+			desc: "Non-namespaced sysctls are rejected",
+			run:  RoleRun{Sysctls: map[string]string{"vm.swappiness": "10"}},
+			err:  "Sysctl vm.swappiness is not namespaced and cannot be set for a role",
+		},
+		{
+			desc: "Sysctl values cannot break the annotation format",
+			run:  RoleRun{Sysctls: map[string]string{"net.core.somaxconn": "1,2"}},
+			err:  `Invalid value "1,2" for sysctl net.core.somaxconn`,
+		},
+		{
+			desc: "Time zones are checked",
+			run:  RoleRun{TimeZone: "Europe Bucharest"},
+			err:  "Invalid time zone Europe Bucharest",
+		},
+		{
+			desc: "Locales are checked",
+			run:  RoleRun{Locale: "en US"},
+			err:  "Invalid locale en US",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.run.validateSystemSettings()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+
+	assert.True(IsSafeSysctl("kernel.shm_rmid_forced"))
+	assert.False(IsSafeSysctl("net.core.somaxconn"))
+}
+
+func TestSystemSettingsRoleVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	role := &Role{Name: "api", Run: &RoleRun{}}
+	version := role.GetRoleDevVersion()
+
+	role.Run.TimeZone = "Europe/Bucharest"
+	withTimeZone := role.GetRoleDevVersion()
+	assert.NotEqual(version, withTimeZone, "the time zone is part of the image")
+
+	role.Run.Locale = "en_US.UTF-8"
+	assert.NotEqual(withTimeZone, role.GetRoleDevVersion(), "the locale is part of the image")
+}
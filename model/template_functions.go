@@ -0,0 +1,194 @@
+package model
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// templateFunctionCallPattern matches the start of a generation-time function
+// call, up to its argument, e.g. "$(b64 " of $(b64 hello)
+var templateFunctionCallPattern = regexp.MustCompile(`^\$\((\w+)\s+`)
+
+// templateFunctions are the functions that can be used in configuration
+// templates and variable defaults; they are evaluated by fissile when the
+// role manifest is loaded, before anything is written out
+var templateFunctions = map[string]func(arg, baseDir string) (string, error){
+	"file":     templateFunctionFile,
+	"b64":      templateFunctionBase64,
+	"ipranges": templateFunctionIPRanges,
+}
+
+// expandTemplateFunctions evaluates all function calls in value, e.g.
+// $(b64 hello) or $(file certs/ca.pem), in a single pass over it. Calls can be
+// nested; the ones in the argument of a call are evaluated first. The results
+// of calls are not scanned for calls, so file() returns the contents of files
+// as they are. Calls of unknown functions are copied as they are, with the
+// calls in their argument evaluated. Relative paths given to file() are
+// resolved against baseDir.
+func expandTemplateFunctions(value, baseDir string) (string, error) {
+	expanded, _, _, err := expandTemplateFunctionCalls(value, baseDir, false)
+	return expanded, err
+}
+
+// expandTemplateFunctionCalls copies value, evaluating the calls in it. In
+// the argument of a call, it stops at the closing parenthesis, and returns what
+// follows it; closed is false when the argument isn't closed, or has other
+// parentheses, in which case the call is not one and is copied as it is.
+func expandTemplateFunctionCalls(value, baseDir string, inCall bool) (expanded, rest string, closed bool, err error) {
+	var result bytes.Buffer
+	for len(value) > 0 {
+		if inCall && value[0] == ')' {
+			return result.String(), value[1:], true, nil
+		}
+		if inCall && value[0] == '(' {
+			return "", "", false, nil
+		}
+
+		match := templateFunctionCallPattern.FindStringSubmatchIndex(value)
+		if match == nil {
+			result.WriteByte(value[0])
+			value = value[1:]
+			continue
+		}
+
+		arg, after, argClosed, err := expandTemplateFunctionCalls(value[match[1]:], baseDir, true)
+		if err != nil {
+			return "", "", false, err
+		}
+		if !argClosed {
+			result.WriteString(value[:match[1]])
+			value = value[match[1]:]
+			continue
+		}
+
+		name := value[match[2]:match[3]]
+		function, ok := templateFunctions[name]
+		if !ok {
+			// Not one of ours, e.g. $(upper x) of a shell command line
+			result.WriteString(value[:match[1]])
+			result.WriteString(arg)
+			result.WriteByte(')')
+			value = after
+			continue
+		}
+		output, err := function(strings.TrimSpace(arg), baseDir)
+		if err != nil {
+			return "", "", false, fmt.Errorf("Error evaluating template function %s: %s", name, err.Error())
+		}
+		result.WriteString(output)
+		value = after
+	}
+
+	return result.String(), "", !inCall, nil
+}
+
+// templateFunctionFile returns the contents of a file
+func templateFunctionFile(path, baseDir string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(contents), nil
+}
+
+// templateFunctionBase64 returns the base64 encoding of its argument
+func templateFunctionBase64(value, baseDir string) (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(value)), nil
+}
+
+// templateFunctionIPRanges converts a space separated list of CIDRs into a
+// comma separated list of first-last address ranges
+func templateFunctionIPRanges(cidrs, baseDir string) (string, error) {
+	var ranges []string
+
+	for _, cidr := range strings.Fields(cidrs) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return "", err
+		}
+
+		first := network.IP
+		last := make(net.IP, len(first))
+		for i := range first {
+			last[i] = first[i] | ^network.Mask[i]
+		}
+
+		ranges = append(ranges, fmt.Sprintf("%s-%s", first, last))
+	}
+
+	if len(ranges) == 0 {
+		return "", fmt.Errorf("No CIDR given")
+	}
+
+	return strings.Join(ranges, ","), nil
+}
+
+// setSourceDir records dir, the directory of the included file the
+// configuration comes from, as the one its template functions resolve
+// relative paths against
+func (c *Configuration) setSourceDir(dir string) {
+	if c == nil {
+		return
+	}
+
+	if c.templateDirs == nil {
+		c.templateDirs = map[string]string{}
+	}
+	for key := range c.Templates {
+		c.templateDirs[key] = dir
+	}
+	for _, variable := range c.Variables {
+		variable.baseDir = dir
+	}
+}
+
+// expandConfigurationFunctions evaluates the template functions in the
+// templates and the string variable defaults of a configuration. Relative
+// paths resolve against baseDir, unless the template or variable comes from
+// an included file; see setSourceDir.
+func (c *Configuration) expandConfigurationFunctions(baseDir string) error {
+	if c == nil {
+		return nil
+	}
+
+	for key, template := range c.Templates {
+		dir := baseDir
+		if templateDir, ok := c.templateDirs[key]; ok {
+			dir = templateDir
+		}
+		value, err := expandTemplateFunctions(template, dir)
+		if err != nil {
+			return fmt.Errorf("Template %s: %s", key, err.Error())
+		}
+		c.Templates[key] = value
+	}
+
+	for _, variable := range c.Variables {
+		defaultValue, ok := variable.Default.(string)
+		if !ok {
+			continue
+		}
+		dir := baseDir
+		if variable.baseDir != "" {
+			dir = variable.baseDir
+		}
+		value, err := expandTemplateFunctions(defaultValue, dir)
+		if err != nil {
+			return fmt.Errorf("Variable %s: %s", variable.Name, err.Error())
+		}
+		variable.Default = value
+	}
+
+	return nil
+}
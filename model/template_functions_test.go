@@ -0,0 +1,87 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTemplateFunctions(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-template-functions")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	err = ioutil.WriteFile(filepath.Join(tempDir, "key.pem"), []byte("secret"), 0644)
+	if !assert.NoError(err) {
+		return
+	}
+
+	value, err := expandTemplateFunctions("((FOO))", tempDir)
+	assert.NoError(err)
+	assert.Equal("((FOO))", value)
+
+	value, err = expandTemplateFunctions("key: $(file key.pem)", tempDir)
+	assert.NoError(err)
+	assert.Equal("key: secret", value)
+
+	value, err = expandTemplateFunctions("$(b64 $(file key.pem))", tempDir)
+	assert.NoError(err)
+	assert.Equal("c2VjcmV0", value)
+
+	value, err = expandTemplateFunctions("$(ipranges 10.0.0.0/24 192.168.1.128/25)", tempDir)
+	assert.NoError(err)
+	assert.Equal("10.0.0.0-10.0.0.255,192.168.1.128-192.168.1.255", value)
+
+	_, err = expandTemplateFunctions("$(ipranges 10.0.0.0)", tempDir)
+	assert.Error(err)
+
+	_, err = expandTemplateFunctions("$(file missing.pem)", tempDir)
+	assert.Error(err)
+
+	value, err = expandTemplateFunctions("$(upper foo) $(b64 $(upper $(b64 x)))", tempDir)
+	assert.NoError(err)
+	assert.Equal("$(upper foo) JCh1cHBlciBlQT09KQ==", value, "calls of unknown functions are left as they are")
+
+	// The contents of files are not expanded, even when a file includes itself
+	err = ioutil.WriteFile(filepath.Join(tempDir, "self.txt"), []byte("$(file self.txt) $(b64 x)"), 0644)
+	if !assert.NoError(err) {
+		return
+	}
+	value, err = expandTemplateFunctions("$(file self.txt)", tempDir)
+	assert.NoError(err)
+	assert.Equal("$(file self.txt) $(b64 x)", value)
+
+	value, err = expandTemplateFunctions("$(b64 $(file self.txt))", tempDir)
+	assert.NoError(err)
+	assert.Equal("JChmaWxlIHNlbGYudHh0KSAkKGI2NCB4KQ==", value, "only the calls of the original text are evaluated")
+
+	value, err = expandTemplateFunctions("$(b64 a(b)) $(b64 unclosed", tempDir)
+	assert.NoError(err)
+	assert.Equal("$(b64 a(b)) $(b64 unclosed", value, "text that is not a call is left as it is")
+}
+
+func TestExpandConfigurationFunctions(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Configuration{
+		Templates: map[string]string{
+			"properties.foo": "$(b64 foo)",
+		},
+		Variables: ConfigurationVariableSlice{
+			{Name: "BAR", Default: "$(b64 bar)"},
+			{Name: "PORT", Default: 8080},
+		},
+	}
+
+	assert.NoError(config.expandConfigurationFunctions("."))
+	assert.Equal("Zm9v", config.Templates["properties.foo"])
+	assert.Equal("YmFy", config.Variables[0].Default)
+	assert.Equal(8080, config.Variables[1].Default)
+}
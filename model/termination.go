@@ -0,0 +1,83 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stopSignalNames are the signals the processes of a role can be sent when
+// they don't stop in time, without their SIG prefix
+var stopSignalNames = map[string]bool{
+	"HUP":  true,
+	"INT":  true,
+	"QUIT": true,
+	"TERM": true,
+	"USR1": true,
+	"USR2": true,
+	"KILL": true,
+}
+
+// RoleRunStopSignal is a step of the escalation the entrypoint of a role goes
+// through when monit didn't stop the processes of its jobs in time
+type RoleRunStopSignal struct {
+	Signal string `yaml:"signal"` // e.g. INT or SIGKILL
+	Wait   int    `yaml:"wait"`   // Seconds the processes have to exit after the signal
+}
+
+// GetStopSignalsDuration returns how long, in seconds, the entrypoint of the
+// role may take to stop its jobs with the stop signals, or 0 if the role has
+// no stop signals and waits for monit
+func (r *RoleRun) GetStopSignalsDuration() int {
+	if len(r.StopSignals) == 0 {
+		return 0
+	}
+	duration := r.StopTimeout
+	for _, signal := range r.StopSignals {
+		duration += signal.Wait
+	}
+	return duration
+}
+
+// validateTermination checks how the pods of a role are stopped, and
+// normalizes the stop signals to their names without the SIG prefix. The stop
+// signals are only used by the entrypoint of roles of type bosh, and have to
+// fit in the termination grace period, when there is one, as kube kills the
+// containers after it.
+func (r *Role) validateTermination() error {
+	if r.Run == nil {
+		return nil
+	}
+	if r.Run.GracePeriod < 0 {
+		return fmt.Errorf("Role %s has a negative termination grace period", r.Name)
+	}
+	if len(r.Run.StopSignals) == 0 {
+		if r.Run.StopTimeout != 0 {
+			return fmt.Errorf("Role %s has a stop timeout, but no stop signals", r.Name)
+		}
+		return nil
+	}
+	if r.Type != "" && r.Type != RoleTypeBosh {
+		return fmt.Errorf("Role %s has stop signals, but is not of type %s", r.Name, RoleTypeBosh)
+	}
+	if r.Run.StopTimeout <= 0 {
+		return fmt.Errorf("Role %s has stop signals, but no positive stop timeout", r.Name)
+	}
+
+	for _, signal := range r.Run.StopSignals {
+		name := strings.TrimPrefix(strings.ToUpper(signal.Signal), "SIG")
+		if !stopSignalNames[name] {
+			return fmt.Errorf("Role %s has an invalid stop signal '%s'", r.Name, signal.Signal)
+		}
+		signal.Signal = name
+		if signal.Wait < 0 {
+			return fmt.Errorf("Role %s: stop signal %s has a negative wait", r.Name, signal.Signal)
+		}
+	}
+
+	if grace := r.Run.GracePeriod; grace > 0 && int64(r.Run.GetStopSignalsDuration()) > grace {
+		return fmt.Errorf("Role %s takes up to %ds to stop its jobs, longer than its termination grace period of %ds",
+			r.Name, r.Run.GetStopSignalsDuration(), grace)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTermination(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		role Role
+		err  string
+	}{
+		{
+			desc: "Roles without a run section are valid",
+			role: Role{Name: "api"},
+		},
+		{
+			desc: "Any role can have a termination grace period",
+			role: Role{Name: "db", Type: RoleTypeDocker, Run: &RoleRun{GracePeriod: 600}},
+		},
+		{
+			desc: "The termination grace period is positive",
+			role: Role{Name: "db", Run: &RoleRun{GracePeriod: -1}},
+			err:  "Role db has a negative termination grace period",
+		},
+		{
+			desc: "Stop signals fit in the termination grace period",
+			role: Role{Name: "db", Run: &RoleRun{
+				GracePeriod: 600,
+				StopTimeout: 300,
+				StopSignals: []*RoleRunStopSignal{{Signal: "SIGINT", Wait: 240}, {Signal: "kill"}},
+			}},
+		},
+		{
+			desc: "Stop signals longer than the termination grace period are killed first",
+			role: Role{Name: "db", Run: &RoleRun{
+				GracePeriod: 60,
+				StopTimeout: 30,
+				StopSignals: []*RoleRunStopSignal{{Signal: "KILL", Wait: 31}},
+			}},
+			err: "Role db takes up to 61s to stop its jobs, longer than its termination grace period of 60s",
+		},
+		{
+			desc: "Stop signals are known",
+			role: Role{Name: "db", Run: &RoleRun{StopTimeout: 10, StopSignals: []*RoleRunStopSignal{{Signal: "SIGSTOP"}}}},
+			err:  "Role db has an invalid stop signal 'SIGSTOP'",
+		},
+		{
+			desc: "Stop signals need a stop timeout",
+			role: Role{Name: "db", Run: &RoleRun{StopSignals: []*RoleRunStopSignal{{Signal: "KILL"}}}},
+			err:  "Role db has stop signals, but no positive stop timeout",
+		},
+		{
+			desc: "A stop timeout needs stop signals",
+			role: Role{Name: "db", Run: &RoleRun{StopTimeout: 10}},
+			err:  "Role db has a stop timeout, but no stop signals",
+		},
+		{
+			desc: "Stop signals need the entrypoint of bosh roles",
+			role: Role{Name: "db", Type: RoleTypeBoshTask, Run: &RoleRun{StopTimeout: 10, StopSignals: []*RoleRunStopSignal{{Signal: "KILL"}}}},
+			err:  "Role db has stop signals, but is not of type bosh",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.role.validateTermination()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
+
+func TestValidateTerminationNormalizesSignals(t *testing.T) {
+	assert := assert.New(t)
+
+	role := Role{Name: "db", Run: &RoleRun{
+		StopTimeout: 10,
+		StopSignals: []*RoleRunStopSignal{{Signal: "sigint", Wait: 5}, {Signal: "KILL"}},
+	}}
+	if assert.NoError(role.validateTermination()) {
+		assert.Equal("INT", role.Run.StopSignals[0].Signal)
+		assert.Equal("KILL", role.Run.StopSignals[1].Signal)
+		assert.Equal(15, role.Run.GetStopSignalsDuration())
+	}
+}
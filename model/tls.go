@@ -0,0 +1,78 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RoleRunPortTLS marks an exposed port as TLS terminated by the role. The
+// certificate and key are the names of the configuration variables with the
+// PEM encoded server certificate and its private key.
+type RoleRunPortTLS struct {
+	Certificate string `yaml:"certificate"`
+	Key         string `yaml:"key"`
+}
+
+// TLSCertificatesPath is the directory the certificates of TLS ports are
+// mounted below, in a directory per port
+const TLSCertificatesPath = "/etc/secrets/tls"
+
+// These are the names of the certificate and key files of a TLS port, the
+// same as in secrets of type kubernetes.io/tls
+const (
+	TLSCertificateFile = "tls.crt"
+	TLSKeyFile         = "tls.key"
+)
+
+var tlsEnvNameInvalidChars = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// TLSPath returns the directory the certificate and key of a TLS port are
+// mounted at
+func (p *RoleRunExposedPort) TLSPath() string {
+	return path.Join(TLSCertificatesPath, p.Name)
+}
+
+// TLSEnvNames returns the names of the environment variables pointing at the
+// certificate and key files of a TLS port; the https port gets
+// TLS_HTTPS_CERT_FILE and TLS_HTTPS_KEY_FILE
+func (p *RoleRunExposedPort) TLSEnvNames() (string, string) {
+	name := strings.Trim(tlsEnvNameInvalidChars.ReplaceAllString(strings.ToUpper(p.Name), "_"), "_")
+	return fmt.Sprintf("TLS_%s_CERT_FILE", name), fmt.Sprintf("TLS_%s_KEY_FILE", name)
+}
+
+// validateTLS checks that the TLS ports of all roles reference configuration
+// variables. Certificates read from existing secrets must be in one secret,
+// which is then used as is.
+func (m *RoleManifest) validateTLS() error {
+	variables := map[string]*ConfigurationVariable{}
+	for _, variable := range m.Configuration.Variables {
+		variables[variable.Name] = variable
+	}
+
+	for _, role := range m.Roles {
+		if role.Run == nil {
+			continue
+		}
+		for _, port := range role.Run.ExposedPorts {
+			if port.TLS == nil {
+				continue
+			}
+			certificate, ok := variables[port.TLS.Certificate]
+			if !ok {
+				return fmt.Errorf("Role %s: TLS port %s uses unknown certificate variable '%s'", role.Name, port.Name, port.TLS.Certificate)
+			}
+			key, ok := variables[port.TLS.Key]
+			if !ok {
+				return fmt.Errorf("Role %s: TLS port %s uses unknown key variable '%s'", role.Name, port.Name, port.TLS.Key)
+			}
+			if (certificate.Secret == nil) != (key.Secret == nil) ||
+				(certificate.Secret != nil && certificate.Secret.Name != key.Secret.Name) {
+				return fmt.Errorf("Role %s: TLS port %s should read both certificate and key from the same secret, or neither", role.Name, port.Name)
+			}
+		}
+	}
+
+	return nil
+}
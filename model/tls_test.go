@@ -0,0 +1,55 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSPortPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	port := &RoleRunExposedPort{Name: "admin-api"}
+	assert.Equal("/etc/secrets/tls/admin-api", port.TLSPath())
+
+	certificate, key := port.TLSEnvNames()
+	assert.Equal("TLS_ADMIN_API_CERT_FILE", certificate)
+	assert.Equal("TLS_ADMIN_API_KEY_FILE", key)
+}
+
+func TestValidateTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	newManifest := func(variables ...*ConfigurationVariable) *RoleManifest {
+		return &RoleManifest{
+			Roles: Roles{{
+				Name: "myrole",
+				Run: &RoleRun{
+					ExposedPorts: []*RoleRunExposedPort{{
+						Name: "https",
+						TLS:  &RoleRunPortTLS{Certificate: "CERT", Key: "KEY"},
+					}},
+				},
+			}},
+			Configuration: &Configuration{Variables: variables},
+		}
+	}
+
+	manifest := newManifest(&ConfigurationVariable{Name: "CERT"}, &ConfigurationVariable{Name: "KEY"})
+	assert.NoError(manifest.validateTLS())
+
+	manifest = newManifest(
+		&ConfigurationVariable{Name: "CERT", Secret: &ConfigurationVariableSecret{Name: "certs", Key: "crt"}},
+		&ConfigurationVariable{Name: "KEY", Secret: &ConfigurationVariableSecret{Name: "certs", Key: "key"}},
+	)
+	assert.NoError(manifest.validateTLS())
+
+	manifest = newManifest(&ConfigurationVariable{Name: "CERT"})
+	assert.EqualError(manifest.validateTLS(), "Role myrole: TLS port https uses unknown key variable 'KEY'")
+
+	manifest = newManifest(
+		&ConfigurationVariable{Name: "CERT", Secret: &ConfigurationVariableSecret{Name: "certs", Key: "crt"}},
+		&ConfigurationVariable{Name: "KEY"},
+	)
+	assert.EqualError(manifest.validateTLS(), "Role myrole: TLS port https should read both certificate and key from the same secret, or neither")
+}
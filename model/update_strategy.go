@@ -0,0 +1,25 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Update strategies of long running roles
+const (
+	UpdateStrategyRolling  = "rolling"  // Replace the pods one at a time
+	UpdateStrategyRecreate = "recreate" // Replace all pods at once, never running old and new ones together; Deployments only
+	UpdateStrategyCanary   = "canary"   // Replace a single pod first, before the others; StatefulSets only
+)
+
+// validateUpdateStrategy checks the update strategy of a role, and normalizes
+// it; an empty strategy leaves the update strategy to Kubernetes
+func (r *RoleRun) validateUpdateStrategy() error {
+	r.UpdateStrategy = strings.ToLower(r.UpdateStrategy)
+	switch r.UpdateStrategy {
+	case "", UpdateStrategyRolling, UpdateStrategyRecreate, UpdateStrategyCanary:
+		return nil
+	}
+	return fmt.Errorf("Invalid update strategy %s, expected one of %s, %s or %s",
+		r.UpdateStrategy, UpdateStrategyRolling, UpdateStrategyRecreate, UpdateStrategyCanary)
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUpdateStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	run := &RoleRun{}
+	if assert.NoError(run.validateUpdateStrategy()) {
+		assert.Equal("", run.UpdateStrategy)
+	}
+
+	run = &RoleRun{UpdateStrategy: "Recreate"}
+	if assert.NoError(run.validateUpdateStrategy()) {
+		assert.Equal(UpdateStrategyRecreate, run.UpdateStrategy)
+	}
+
+	run = &RoleRun{UpdateStrategy: "blue-green"}
+	assert.EqualError(run.validateUpdateStrategy(), "Invalid update strategy blue-green, expected one of rolling, recreate or canary")
+}
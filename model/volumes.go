@@ -0,0 +1,94 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// volumeTagPattern matches the tags of volumes, which name their persistent
+// volume claims and the volumes of the pods
+var volumeTagPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// RoleRunTmpfsVolume is memory-backed scratch space of the containers of a
+// role, e.g. for /dev/shm. Its size counts towards the memory of the role, so
+// roles with tmpfs volumes need a memory.
+type RoleRunTmpfsVolume struct {
+	Path string `yaml:"path"` // Where the volume is mounted
+	Tag  string `yaml:"tag"`  // Names the volume
+	Size int    `yaml:"size"` // In MB
+}
+
+// GetTmpfsSize returns the total size of the tmpfs volumes of a role, in MB
+func (r *RoleRun) GetTmpfsSize() int {
+	size := 0
+	for _, volume := range r.TmpfsVolumes {
+		size += volume.Size
+	}
+	return size
+}
+
+// GetTmpfsVolume returns the tmpfs volume of the role at a path, or nil if
+// there is none
+func (r *RoleRun) GetTmpfsVolume(volumePath string) *RoleRunTmpfsVolume {
+	for _, volume := range r.TmpfsVolumes {
+		if volume.Path == volumePath {
+			return volume
+		}
+	}
+	return nil
+}
+
+// validateVolumes checks the persistent, shared and tmpfs volumes of a role.
+// Their tags and paths must be unique across all kinds.
+func (r *RoleRun) validateVolumes() error {
+	tags := map[string]bool{}
+	paths := map[string]string{}
+	for _, volume := range append(append([]*RoleRunVolume{}, r.PersistentVolumes...), r.SharedVolumes...) {
+		if !volumeTagPattern.MatchString(volume.Tag) {
+			return fmt.Errorf("Invalid volume tag '%s', expected a DNS label", volume.Tag)
+		}
+		if tags[volume.Tag] {
+			return fmt.Errorf("Volume tag %s is used more than once", volume.Tag)
+		}
+		tags[volume.Tag] = true
+
+		if !path.IsAbs(volume.Path) || path.Clean(volume.Path) != volume.Path || volume.Path == "/" {
+			return fmt.Errorf("Volume %s has an invalid path '%s', expected an absolute path", volume.Tag, volume.Path)
+		}
+		if other, ok := paths[volume.Path]; ok {
+			return fmt.Errorf("Volume %s uses the path %s of volume %s", volume.Tag, volume.Path, other)
+		}
+		paths[volume.Path] = volume.Tag
+
+		if volume.Size <= 0 {
+			return fmt.Errorf("Volume %s should have a positive size, in GB", volume.Tag)
+		}
+	}
+	for _, volume := range r.TmpfsVolumes {
+		if !volumeTagPattern.MatchString(volume.Tag) {
+			return fmt.Errorf("Invalid volume tag '%s', expected a DNS label", volume.Tag)
+		}
+		if tags[volume.Tag] {
+			return fmt.Errorf("Volume tag %s is used more than once", volume.Tag)
+		}
+		tags[volume.Tag] = true
+
+		if !path.IsAbs(volume.Path) || path.Clean(volume.Path) != volume.Path || volume.Path == "/" {
+			return fmt.Errorf("Volume %s has an invalid path '%s', expected an absolute path", volume.Tag, volume.Path)
+		}
+		if other, ok := paths[volume.Path]; ok {
+			return fmt.Errorf("Volume %s uses the path %s of volume %s", volume.Tag, volume.Path, other)
+		}
+		paths[volume.Path] = volume.Tag
+
+		if volume.Size <= 0 {
+			return fmt.Errorf("Tmpfs volume %s should have a positive size, in MB", volume.Tag)
+		}
+	}
+	if len(r.TmpfsVolumes) > 0 && r.Memory <= 0 {
+		// Their size is only requested, and limited, as part of the memory
+		return fmt.Errorf("Tmpfs volumes need the memory of the role to be set, which their size is added to")
+	}
+	return nil
+}
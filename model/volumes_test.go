@@ -0,0 +1,102 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateVolumes(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc string
+		run  RoleRun
+		err  string
+	}{
+		{
+			desc: "Roles without volumes are valid",
+		},
+		{
+			desc: "Persistent and shared volumes are valid",
+			run: RoleRun{
+				PersistentVolumes: []*RoleRunVolume{{Tag: "data", Path: "/var/vcap/store", Size: 10}},
+				SharedVolumes:     []*RoleRunVolume{{Tag: "blobs", Path: "/var/vcap/blobs", Size: 100}},
+			},
+		},
+		{
+			desc: "Tags name claims",
+			run:  RoleRun{PersistentVolumes: []*RoleRunVolume{{Tag: "Data_1", Path: "/data", Size: 1}}},
+			err:  "Invalid volume tag 'Data_1', expected a DNS label",
+		},
+		{
+			desc: "Tags are unique across kinds",
+			run: RoleRun{
+				PersistentVolumes: []*RoleRunVolume{{Tag: "data", Path: "/data", Size: 1}},
+				SharedVolumes:     []*RoleRunVolume{{Tag: "data", Path: "/shared", Size: 1}},
+			},
+			err: "Volume tag data is used more than once",
+		},
+		{
+			desc: "Paths are absolute",
+			run:  RoleRun{PersistentVolumes: []*RoleRunVolume{{Tag: "data", Path: "data", Size: 1}}},
+			err:  "Volume data has an invalid path 'data', expected an absolute path",
+		},
+		{
+			desc: "Paths are unique",
+			run: RoleRun{
+				PersistentVolumes: []*RoleRunVolume{{Tag: "data", Path: "/data", Size: 1}},
+				SharedVolumes:     []*RoleRunVolume{{Tag: "shared", Path: "/data", Size: 1}},
+			},
+			err: "Volume shared uses the path /data of volume data",
+		},
+		{
+			desc: "Volumes have a size",
+			run:  RoleRun{SharedVolumes: []*RoleRunVolume{{Tag: "shared", Path: "/shared"}}},
+			err:  "Volume shared should have a positive size, in GB",
+		},
+		{
+			desc: "Tmpfs volumes are valid",
+			run: RoleRun{
+				PersistentVolumes: []*RoleRunVolume{{Tag: "data", Path: "/var/vcap/store", Size: 10}},
+				TmpfsVolumes:      []*RoleRunTmpfsVolume{{Tag: "shm", Path: "/dev/shm", Size: 64}},
+				Memory:            256,
+			},
+		},
+		{
+			desc: "Tmpfs volumes need memory",
+			run:  RoleRun{TmpfsVolumes: []*RoleRunTmpfsVolume{{Tag: "shm", Path: "/dev/shm", Size: 64}}},
+			err:  "Tmpfs volumes need the memory of the role to be set, which their size is added to",
+		},
+		{
+			desc: "Tmpfs volume tags are unique across kinds",
+			run: RoleRun{
+				SharedVolumes: []*RoleRunVolume{{Tag: "scratch", Path: "/shared", Size: 1}},
+				TmpfsVolumes:  []*RoleRunTmpfsVolume{{Tag: "scratch", Path: "/scratch", Size: 64}},
+			},
+			err: "Volume tag scratch is used more than once",
+		},
+		{
+			desc: "Tmpfs volume paths are unique across kinds",
+			run: RoleRun{
+				PersistentVolumes: []*RoleRunVolume{{Tag: "data", Path: "/data", Size: 1}},
+				TmpfsVolumes:      []*RoleRunTmpfsVolume{{Tag: "scratch", Path: "/data", Size: 64}},
+			},
+			err: "Volume scratch uses the path /data of volume data",
+		},
+		{
+			desc: "Tmpfs volumes have a size",
+			run:  RoleRun{TmpfsVolumes: []*RoleRunTmpfsVolume{{Tag: "shm", Path: "/dev/shm"}}},
+			err:  "Tmpfs volume shm should have a positive size, in MB",
+		},
+	}
+
+	for _, sample := range samples {
+		err := sample.run.validateVolumes()
+		if sample.err == "" {
+			assert.NoError(err, sample.desc)
+		} else {
+			assert.EqualError(err, sample.err, sample.desc)
+		}
+	}
+}
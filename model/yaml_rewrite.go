@@ -0,0 +1,254 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// yamlEntry is a key of a block YAML mapping, and the lines of its value
+type yamlEntry struct {
+	key   string
+	start int // Line of the key
+	end   int // Line after the last line of the value
+}
+
+// SetYAMLKeys returns the YAML document in contents with the top level keys
+// in values set to them, keeping the comments, formatting and order of the
+// rest of the document, so that files people review can be rewritten. A key
+// with a nil value is removed, and keys the document doesn't have are added
+// at its end. Mappings replace the block mappings they are set to key by key,
+// keeping the comments of their keys; other values replace the whole value.
+func SetYAMLKeys(contents []byte, values yaml.MapSlice) ([]byte, error) {
+	lines := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+	if len(contents) == 0 {
+		lines = nil
+	}
+
+	lines, err := setYAMLMappingKeys(lines, 0, values, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// setYAMLMappingKeys sets the keys of the block mapping in lines whose keys
+// are at the given indent; with replace set, the keys not in values are
+// removed too
+func setYAMLMappingKeys(lines []string, indent int, values yaml.MapSlice, replace bool) ([]string, error) {
+	entries := findYAMLEntries(lines, indent)
+
+	// Entries are rewritten from the last one, so that the lines of the ones
+	// before them don't move
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		value, ok := lookupYAMLValue(values, entry.key)
+		if !ok && !replace {
+			continue
+		}
+		var replacement []string
+		if value != nil {
+			var err error
+			if replacement, err = setYAMLEntry(lines[entry.start:entry.end], indent, entry.key, value); err != nil {
+				return nil, err
+			}
+		}
+		lines = append(append(append([]string{}, lines[:entry.start]...), replacement...), lines[entry.end:]...)
+	}
+
+	// New keys go after the last entry, before any comments ending the mapping
+	var appended []string
+	for _, item := range values {
+		key := fmt.Sprintf("%v", item.Key)
+		if item.Value == nil || lookupYAMLEntry(entries, key) != nil {
+			continue
+		}
+		block, err := marshalYAMLEntry(key, item.Value, indent, "")
+		if err != nil {
+			return nil, err
+		}
+		appended = append(appended, block...)
+	}
+	if len(appended) > 0 {
+		end := len(lines)
+		if remaining := findYAMLEntries(lines, indent); len(remaining) > 0 {
+			end = remaining[len(remaining)-1].end
+		}
+		lines = append(append(append([]string{}, lines[:end]...), appended...), lines[end:]...)
+	}
+
+	return lines, nil
+}
+
+// setYAMLEntry returns the lines of an entry with its value set, keeping the
+// comment after the key
+func setYAMLEntry(lines []string, indent int, key string, value interface{}) ([]string, error) {
+	keyValue, comment := splitYAMLComment(strings.TrimSpace(lines[0]))
+	if mapping, ok := toYAMLMapSlice(value); ok && strings.HasSuffix(keyValue, ":") && isYAMLBlockMapping(lines[1:], indent) {
+		childIndent := yamlLineIndent(firstYAMLContentLine(lines[1:]))
+		children, err := setYAMLMappingKeys(lines[1:], childIndent, mapping, true)
+		if err != nil {
+			return nil, err
+		}
+		return append([]string{lines[0]}, children...), nil
+	}
+
+	return marshalYAMLEntry(key, value, indent, comment)
+}
+
+// marshalYAMLEntry returns the lines of a key with its value, in block style
+func marshalYAMLEntry(key string, value interface{}, indent int, comment string) ([]string, error) {
+	contents, err := yaml.Marshal(yaml.MapSlice{{Key: key, Value: value}})
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+	prefix := strings.Repeat(" ", indent)
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	if comment != "" {
+		lines[0] = fmt.Sprintf("%s %s", lines[0], comment)
+	}
+	return lines, nil
+}
+
+// findYAMLEntries returns the keys of the block mapping at the given indent,
+// with the lines of their values. Comments and blank lines after a value are
+// left out of it, as they usually describe the next key.
+func findYAMLEntries(lines []string, indent int) []*yamlEntry {
+	var entries []*yamlEntry
+	var current *yamlEntry
+
+	for i, line := range lines {
+		if isYAMLContentLine(line) && yamlLineIndent(line) <= indent && !isYAMLSequenceItemAt(line, indent) {
+			if current != nil {
+				entries = append(entries, current)
+				current = nil
+			}
+			if yamlLineIndent(line) < indent {
+				continue
+			}
+			match := yamlKeyPattern.FindStringSubmatch(strings.TrimRight(strings.TrimSpace(line), " \r"))
+			if match == nil {
+				continue
+			}
+			key := strings.TrimSpace(match[1])
+			if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') {
+				key = key[1 : len(key)-1]
+			}
+			current = &yamlEntry{key: key, start: i, end: i + 1}
+			continue
+		}
+		if current != nil && isYAMLContentLine(line) {
+			current.end = i + 1
+		}
+	}
+	if current != nil {
+		entries = append(entries, current)
+	}
+
+	return entries
+}
+
+// isYAMLContentLine reports whether a line is neither blank nor a comment
+func isYAMLContentLine(line string) bool {
+	content := strings.TrimSpace(line)
+	return content != "" && !strings.HasPrefix(content, "#") && content != "---" && content != "..."
+}
+
+// isYAMLSequenceItemAt reports whether a line is an item of a sequence at the
+// given indent, which YAML allows as the value of a key at that indent
+func isYAMLSequenceItemAt(line string, indent int) bool {
+	content := strings.TrimLeft(line, " ")
+	return yamlLineIndent(line) == indent && (content == "-" || strings.HasPrefix(content, "- "))
+}
+
+// isYAMLBlockMapping reports whether the lines of a value are a block mapping
+// indented more than its key
+func isYAMLBlockMapping(lines []string, indent int) bool {
+	line := firstYAMLContentLine(lines)
+	if line == "" || yamlLineIndent(line) <= indent || isYAMLSequenceItemAt(line, yamlLineIndent(line)) {
+		return false
+	}
+	return yamlKeyPattern.MatchString(strings.TrimRight(strings.TrimSpace(line), " \r"))
+}
+
+func firstYAMLContentLine(lines []string) string {
+	for _, line := range lines {
+		if isYAMLContentLine(line) {
+			return line
+		}
+	}
+	return ""
+}
+
+func yamlLineIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// splitYAMLComment splits the comment off the end of a line of YAML; a # only
+// starts a comment after a space, outside of quotes
+func splitYAMLComment(line string) (string, string) {
+	quote := rune(0)
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return strings.TrimSpace(line[:i]), line[i:]
+		}
+	}
+	return line, ""
+}
+
+// toYAMLMapSlice returns a mapping value as a yaml.MapSlice, in the order
+// yaml would write it
+func toYAMLMapSlice(value interface{}) (yaml.MapSlice, bool) {
+	if mapping, ok := value.(yaml.MapSlice); ok {
+		return mapping, true
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map {
+		return nil, false
+	}
+	keys := v.MapKeys()
+	names := make([]string, len(keys))
+	byName := make(map[string]reflect.Value, len(keys))
+	for i, key := range keys {
+		names[i] = fmt.Sprintf("%v", key.Interface())
+		byName[names[i]] = key
+	}
+	sort.Strings(names)
+	mapping := make(yaml.MapSlice, 0, len(names))
+	for _, name := range names {
+		mapping = append(mapping, yaml.MapItem{Key: name, Value: v.MapIndex(byName[name]).Interface()})
+	}
+	return mapping, true
+}
+
+func lookupYAMLEntry(entries []*yamlEntry, key string) *yamlEntry {
+	for _, entry := range entries {
+		if entry.key == key {
+			return entry
+		}
+	}
+	return nil
+}
+
+func lookupYAMLValue(values yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range values {
+		if fmt.Sprintf("%v", item.Key) == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSetYAMLKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	samples := []struct {
+		desc     string
+		contents string
+		values   yaml.MapSlice
+		expected string
+	}{
+		{
+			desc:     "Empty documents get the keys",
+			values:   yaml.MapSlice{{Key: "channel", Value: "stable"}},
+			expected: "channel: stable\n",
+		},
+		{
+			desc: "Comments and the order of other keys are kept",
+			contents: `---
+# The release channel
+channel: beta # Set by fissile tag
+replicas: 3 # Not touched
+
+# Images of the roles
+images:
+  # The web frontend
+  web: docker.io/web:1
+  api: docker.io/api:1 # Pinned
+  old: docker.io/old:1
+`,
+			values: yaml.MapSlice{
+				{Key: "channel", Value: "stable"},
+				{Key: "images", Value: map[string]string{"api": "docker.io/api@sha256:abc", "web": "docker.io/web:2", "worker": "docker.io/worker:2"}},
+			},
+			expected: `---
+# The release channel
+channel: stable # Set by fissile tag
+replicas: 3 # Not touched
+
+# Images of the roles
+images:
+  # The web frontend
+  web: docker.io/web:2
+  api: docker.io/api@sha256:abc # Pinned
+  worker: docker.io/worker:2
+`,
+		},
+		{
+			desc: "Missing keys are added after the last key, nil values remove keys",
+			contents: `releases: []
+stemcell: old # Removed
+# The end
+`,
+			values: yaml.MapSlice{
+				{Key: "stemcell", Value: nil},
+				{Key: "releases", Value: []string{"tor"}},
+				{Key: "pinned", Value: true},
+			},
+			expected: `releases:
+- tor
+pinned: true
+# The end
+`,
+		},
+		{
+			desc: "Values other than block mappings are replaced",
+			contents: `images: {web: docker.io/web:1}
+jobs:
+- tor
+`,
+			values: yaml.MapSlice{
+				{Key: "images", Value: map[string]string{"web": "docker.io/web:2"}},
+				{Key: "jobs", Value: map[string]string{"tor": "abc"}},
+			},
+			expected: `images:
+  web: docker.io/web:2
+jobs:
+  tor: abc
+`,
+		},
+	}
+
+	for _, sample := range samples {
+		result, err := SetYAMLKeys([]byte(sample.contents), sample.values)
+		if assert.NoError(err, sample.desc) {
+			assert.Equal(sample.expected, string(result), sample.desc)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ManifestMediaTypes are the image manifests accepted from registries
+var ManifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// rgxNextLink matches the link to the next page of a paginated listing
+var rgxNextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// ParseReference splits a reference into the URL of its registry and the
+// repository. References without a registry host are on Docker Hub.
+// Registries on localhost are talked to without TLS.
+func ParseReference(ref string) (string, string, error) {
+	name := strings.SplitN(ref, "@", 2)[0]
+	if slash := strings.LastIndex(name, "/"); strings.LastIndex(name, ":") > slash {
+		name = name[:strings.LastIndex(name, ":")]
+	}
+
+	host := "registry-1.docker.io"
+	repository := name
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		host, repository = parts[0], parts[1]
+	} else if len(parts) == 1 {
+		repository = path.Join("library", name)
+	}
+	if repository == "" {
+		return "", "", fmt.Errorf("Invalid reference '%s'", ref)
+	}
+
+	scheme := "https"
+	if hostname := strings.Split(host, ":")[0]; hostname == "localhost" || hostname == "127.0.0.1" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s", scheme, host), repository, nil
+}
+
+// Client talks to an OCI registry, getting bearer tokens when the registry
+// asks for them. Without a username, the tokens are anonymous ones.
+type Client struct {
+	Username string
+	Password string
+	token    string
+}
+
+// Get fetches a URL from the registry; ref names what is fetched in errors
+func (c *Client) Get(requestURL, ref string, accept []string) ([]byte, error) {
+	data, _, err := c.fetch("GET", requestURL, ref, accept)
+	return data, err
+}
+
+// Catalog lists the repositories of a registry
+func (c *Client) Catalog(registry string) ([]string, error) {
+	var repositories []string
+	err := c.list(registry, "/v2/_catalog", func(data []byte) error {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		repositories = append(repositories, page.Repositories...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the repositories of %s: %s", registry, err.Error())
+	}
+	return repositories, nil
+}
+
+// Tags lists the tags of a repository
+func (c *Client) Tags(registry, repository string) ([]string, error) {
+	var tags []string
+	err := c.list(registry, fmt.Sprintf("/v2/%s/tags/list", repository), func(data []byte) error {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		tags = append(tags, page.Tags...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the tags of %s: %s", repository, err.Error())
+	}
+	return tags, nil
+}
+
+// ImageLabels returns the digest of the manifest of an image, and the labels
+// of its configuration
+func (c *Client) ImageLabels(registry, repository, tag string) (string, map[string]string, error) {
+	ref := fmt.Sprintf("%s:%s", repository, tag)
+	data, header, err := c.fetch("GET", fmt.Sprintf("%s/v2/%s/manifests/%s", registry, repository, tag), ref, ManifestMediaTypes)
+	if err != nil {
+		return "", nil, err
+	}
+	var manifest struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", nil, fmt.Errorf("Error reading manifest of %s: %s", ref, err.Error())
+	}
+	digest := header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", nil, fmt.Errorf("Manifest of %s has no digest", ref)
+	}
+	if manifest.Config.Digest == "" {
+		// Not an image, e.g. an artifact or an index
+		return digest, map[string]string{}, nil
+	}
+
+	data, err = c.Get(fmt.Sprintf("%s/v2/%s/blobs/%s", registry, repository, manifest.Config.Digest), ref, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	var config struct {
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", nil, fmt.Errorf("Error reading configuration of %s: %s", ref, err.Error())
+	}
+	if config.Config.Labels == nil {
+		return digest, map[string]string{}, nil
+	}
+	return digest, config.Config.Labels, nil
+}
+
+// DeleteManifest deletes the manifest of an image by digest, which removes
+// every tag of the image
+func (c *Client) DeleteManifest(registry, repository, digest string) error {
+	ref := fmt.Sprintf("%s@%s", repository, digest)
+	_, _, err := c.fetch("DELETE", fmt.Sprintf("%s/v2/%s/manifests/%s", registry, repository, digest), ref, nil)
+	return err
+}
+
+// list fetches every page of a listing of the registry
+func (c *Client) list(registry, listPath string, readPage func([]byte) error) error {
+	for listPath != "" {
+		data, header, err := c.fetch("GET", registry+listPath, listPath, nil)
+		if err != nil {
+			return err
+		}
+		if err := readPage(data); err != nil {
+			return err
+		}
+
+		listPath = ""
+		if match := rgxNextLink.FindStringSubmatch(header.Get("Link")); match != nil {
+			listPath = match[1]
+		}
+	}
+	return nil
+}
+
+// fetch sends a request to the registry, authenticating if it asks for it,
+// and returns the body and the headers of the response
+func (c *Client) fetch(method, requestURL, ref string, accept []string) ([]byte, http.Header, error) {
+	response, err := c.do(method, requestURL, accept)
+	if err != nil {
+		return nil, nil, err
+	}
+	if response.StatusCode == http.StatusUnauthorized {
+		// Tokens are scoped, so a request may need another one
+		challenge := response.Header.Get("Www-Authenticate")
+		response.Body.Close()
+		if err := c.authenticate(challenge); err != nil {
+			return nil, nil, fmt.Errorf("Error authenticating to the registry of %s: %s", ref, err.Error())
+		}
+		if response, err = c.do(method, requestURL, accept); err != nil {
+			return nil, nil, err
+		}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		action := "fetching"
+		if method == "DELETE" {
+			action = "deleting"
+		}
+		return nil, nil, fmt.Errorf("Error %s %s: %s", action, requestURL, response.Status)
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	return data, response.Header, err
+}
+
+// do sends a request to the registry
+func (c *Client) do(method, requestURL string, accept []string) (*http.Response, error) {
+	request, err := http.NewRequest(method, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		request.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.Username != "" {
+		request.SetBasicAuth(c.Username, c.Password)
+	}
+	return http.DefaultClient.Do(request)
+}
+
+// authenticate gets a token for the Bearer challenge of a registry
+func (c *Client) authenticate(challenge string) error {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return fmt.Errorf("unsupported authentication '%s'", challenge)
+	}
+
+	params := map[string]string{}
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		parts := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(parts) == 2 {
+			params[parts[0]] = strings.Trim(parts[1], `"`)
+		}
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("invalid realm in '%s'", challenge)
+	}
+	query := realm.Query()
+	for _, name := range []string{"service", "scope"} {
+		if params[name] != "" {
+			query.Set(name, params[name])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	request, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.Username != "" {
+		request.SetBasicAuth(c.Username, c.Password)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed: %s", response.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return err
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("no token in the response")
+	}
+	return nil
+}
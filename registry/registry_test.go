@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReference(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, sample := range []struct {
+		ref        string
+		registry   string
+		repository string
+	}{
+		{"registry.example.com/platform/scripts:1.2", "https://registry.example.com", "platform/scripts"},
+		{"localhost:5000/scripts", "http://localhost:5000", "scripts"},
+		{"platform/scripts", "https://registry-1.docker.io", "platform/scripts"},
+		{"scripts:latest", "https://registry-1.docker.io", "library/scripts"},
+	} {
+		registry, repository, err := ParseReference(sample.ref)
+		if assert.NoError(err, sample.ref) {
+			assert.Equal(sample.registry, registry, sample.ref)
+			assert.Equal(sample.repository, repository, sample.ref)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	assert := assert.New(t)
+
+	var deleted []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			user, password, _ := r.BasicAuth()
+			fmt.Fprintf(w, `{"token": "%s:%s:%s"}`, user, password, r.URL.Query().Get("scope"))
+			return
+		}
+		scope := "repository:org/app:pull"
+		if r.URL.Path == "/v2/_catalog" {
+			scope = "registry:catalog:*"
+		} else if r.Method == "DELETE" {
+			scope = "repository:org/app:delete"
+		}
+		if r.Header.Get("Authorization") != "Bearer user:secret:"+scope {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="%s"`, server.URL, scope))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/v2/_catalog" && r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/_catalog?last=org%2Fapp&n=1>; rel="next"`)
+			fmt.Fprint(w, `{"repositories": ["org/app"]}`)
+		case r.URL.Path == "/v2/_catalog":
+			fmt.Fprint(w, `{"repositories": ["other/app"]}`)
+		case r.URL.Path == "/v2/org/app/tags/list":
+			fmt.Fprint(w, `{"name": "org/app", "tags": ["v1", "v2"]}`)
+		case r.URL.Path == "/v2/org/app/manifests/v1" && r.Method == "GET":
+			w.Header().Set("Docker-Content-Digest", "sha256:manifest")
+			fmt.Fprint(w, `{"schemaVersion": 2, "config": {"digest": "sha256:config"}}`)
+		case r.URL.Path == "/v2/org/app/blobs/sha256:config":
+			fmt.Fprint(w, `{"config": {"Labels": {"role": "app"}}}`)
+		case r.URL.Path == "/v2/org/app/manifests/sha256:manifest" && r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	registry, _, err := ParseReference(strings.TrimPrefix(server.URL, "http://") + "/org/app")
+	if !assert.NoError(err) {
+		return
+	}
+	client := &Client{Username: "user", Password: "secret"}
+
+	repositories, err := client.Catalog(registry)
+	if assert.NoError(err) {
+		assert.Equal([]string{"org/app", "other/app"}, repositories, "Every page is listed")
+	}
+
+	tags, err := client.Tags(registry, "org/app")
+	if assert.NoError(err) {
+		assert.Equal([]string{"v1", "v2"}, tags)
+	}
+
+	digest, labels, err := client.ImageLabels(registry, "org/app", "v1")
+	if assert.NoError(err) {
+		assert.Equal("sha256:manifest", digest)
+		assert.Equal(map[string]string{"role": "app"}, labels)
+	}
+
+	_, _, err = client.ImageLabels(registry, "org/app", "missing")
+	assert.EqualError(err, fmt.Sprintf("Error fetching %s/v2/org/app/manifests/missing: 404 Not Found", registry))
+
+	if assert.NoError(client.DeleteManifest(registry, "org/app", "sha256:manifest")) {
+		assert.Equal([]string{"/v2/org/app/manifests/sha256:manifest"}, deleted)
+	}
+}
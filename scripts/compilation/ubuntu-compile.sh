@@ -32,3 +32,11 @@ cd $BOSH_COMPILE_TARGET
 bash ./packaging
 
 chown -R ${HOST_USERID}:${HOST_USERGID} /fissile-out 2>/dev/null || echo "Warning - could not change ownership of compiled artifacts" 1>&2
+
+# Report the peak memory usage of the compilation for the build report
+for peakFile in /sys/fs/cgroup/memory.peak /sys/fs/cgroup/memory/memory.max_usage_in_bytes ; do
+  if [ -r "${peakFile}" ] ; then
+    echo "fissile-peak-memory: $(cat "${peakFile}")"
+    break
+  fi
+done
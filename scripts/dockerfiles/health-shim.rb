@@ -0,0 +1,63 @@
+# Health shim for roles without an HTTP health endpoint of their own
+#
+# Answers GET /healthz with 200 once the role is ready (post-start.sh created
+# /var/vcap/monit/ready after the configuration rendered) and monit reports all
+# the processes as running, and with 503 otherwise. The body is the status of every process, for debugging.
+#
+# Usage: ruby health-shim.rb <port>
+
+require 'socket'
+
+READY_FILE = '/var/vcap/monit/ready'.freeze
+
+def monit_summary
+  output = `monit summary 2>&1`
+  return [false, output] unless $?.success?
+
+  # Same check as post-start.sh: skip the header and post-start itself
+  processes = output.lines.drop(2).reject { |line| line.include?('post-start') || line.strip.empty? }
+  healthy = processes.all? { |line| line =~ /Accessible|Running/ }
+  [healthy, processes.join]
+end
+
+def health
+  unless File.exist?(READY_FILE)
+    return [503, "not ready\n"]
+  end
+  healthy, summary = monit_summary
+  [healthy ? 200 : 503, summary]
+end
+
+def respond(client)
+  request_line = client.gets.to_s
+  # Skip the headers
+  loop do
+    line = client.gets
+    break if line.nil? || line.strip.empty?
+  end
+
+  method, path = request_line.split(' ')
+  status, body =
+    if %w(GET HEAD).include?(method) && path.to_s.split('?').first == '/healthz'
+      health
+    else
+      [404, "not found\n"]
+    end
+
+  reason = { 200 => 'OK', 404 => 'Not Found', 503 => 'Service Unavailable' }[status]
+  client.write("HTTP/1.0 #{status} #{reason}\r\n")
+  client.write("Content-Type: text/plain\r\n")
+  client.write("Content-Length: #{body.bytesize}\r\n")
+  client.write("Connection: close\r\n\r\n")
+  client.write(body) unless method == 'HEAD'
+rescue StandardError => e
+  STDERR.puts "health shim: #{e}"
+ensure
+  client.close
+end
+
+server = TCPServer.new('0.0.0.0', Integer(ARGV.fetch(0)))
+loop do
+  client = server.accept
+  Thread.new(client) { |c| respond(c) }
+end
@@ -13,6 +13,10 @@
 #   Nothing is done while they are not up yet. We know that we are
 #   `post-start`, important to exclude ourselves from the check
 #
+# * /var/vcap/monit/rendered is created by run.sh once configgin rendered
+#   the templates. Roles whose configuration failed to render never
+#   become ready.
+#
 # Doing our own dependency checking works around issues in monit.
 # This can be shifted to monit itself ('depends on') when we reach use
 # of monit v5.15+ where the issues are fixed.
@@ -21,7 +25,7 @@
   flock -n 9 || exit 1
 
   notyet=$(monit summary | tail -n+3 | grep -v post-start | grep -v 'Accessible\|Running')
-  if [ -z "$notyet" ]
+  if [ -z "$notyet" ] && [ -e /var/vcap/monit/rendered ]
   then
       scripts="$(find /var/vcap/jobs/*/bin -name post-start)"
       set -e
@@ -0,0 +1,60 @@
+#!/bin/bash
+
+# Stops the jobs of the role in order, the jobs using other jobs of the role
+# before those, so that consumers are gone before their providers. Each job
+# is drained before its processes are stopped.
+#
+# This is the preStop hook of the containers of the role, and run.sh runs it
+# on SIGTERM too; the jobs are only stopped once.
+
+STOPPED_MARKER=/var/vcap/monit/stopped
+
+# Runs the drain script of a job, if it has one, with the arguments BOSH
+# gives it on shutdown. The script prints how many seconds to wait for; a
+# negative number asks for the script to be run again after that long.
+function drain-job()
+{
+    local drain="/var/vcap/jobs/$1/bin/drain"
+    if [ ! -x "${drain}" ]; then
+        return 0
+    fi
+
+    echo "Draining job $1"
+    local wait=$("${drain}" job_shutdown hash_unchanged)
+    while [[ "${wait}" =~ ^-[0-9]+$ ]]; do
+        sleep $(( -wait ))
+        wait=$("${drain}" job_check_status hash_unchanged)
+    done
+    if [[ "${wait}" =~ ^[0-9]+$ ]]; then
+        sleep "${wait}"
+    fi
+}
+
+# Stops the monit processes of a job, and waits for them to be stopped
+function stop-job()
+{
+    local processes=$(awk '$1 == "check" && $2 == "process" { print $3 }' "/var/vcap/monit/$1.monitrc" 2>/dev/null)
+
+    for process in ${processes}; do
+        echo "Stopping process ${process} of job $1"
+        monit stop "${process}"
+    done
+    for process in ${processes}; do
+        until monit summary | grep "^Process '${process}'" | grep -q "Not monitored"; do
+            sleep 1
+        done
+    done
+}
+
+mkdir -p "$(dirname "${STOPPED_MARKER}")"
+(
+    flock 9
+    if [ -e "${STOPPED_MARKER}" ]; then
+        exit 0
+    fi
+{{ range $job := .jobs }}
+    drain-job {{ $job.Name }}
+    stop-job {{ $job.Name }}
+{{ end }}
+    touch "${STOPPED_MARKER}"
+) 9>"${STOPPED_MARKER}.lock"
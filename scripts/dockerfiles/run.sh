@@ -1,18 +1,67 @@
-#!/usr/bin/dumb-init --single-child /bin/bash
+#!/bin/bash
 
 set -e
 
 if [[ "$1" == "--help" ]]; then
 cat <<EOL
-Usage: run.sh
+Usage: run.sh [--bbr backup|restore]
+
+Set FISSILE_TRACE=true to trace the startup scripts with timestamps, and to
+print a timeline of the startup steps. The timeline is also written to
+/var/vcap/sys/log/fissile/startup-timeline.log.
 EOL
 exit 0
 fi
 
+# Startup tracing, see --help
+FISSILE_TIMELINE=/var/vcap/sys/log/fissile/startup-timeline.log
+
+function timeline()
+{
+    if [[ "${FISSILE_TRACE}" == "true" ]]; then
+        echo "$(date +%s.%N) $*" >> "${FISSILE_TIMELINE}"
+    fi
+}
+
+function timeline-report()
+{
+    if [[ "${FISSILE_TRACE}" != "true" ]]; then
+        return 0
+    fi
+    { set +x ; } 2>/dev/null
+    echo "Startup timeline (since start, since previous step):"
+    awk 'NR == 1 { start = $1 ; prev = $1 }
+         { step = $0 ; sub(/^[^ ]+ /, "", step)
+           printf "%9.3fs %9.3fs  %s\n", $1 - start, $1 - prev, step
+           prev = $1 }' "${FISSILE_TIMELINE}"
+    set -x
+}
+
+if [[ "${FISSILE_TRACE}" == "true" ]]; then
+    mkdir -p "$(dirname "${FISSILE_TIMELINE}")"
+    : > "${FISSILE_TIMELINE}"
+    export PS4='+ $(date "+%H:%M:%S.%N") ${BASH_SOURCE##*/}:${LINENO}: '
+    set -x
+    # Trace the scripts run from here too
+    export SHELLOPTS
+fi
+
+timeline "start"
+
+{{ if .dev_mounts }}
+# This image was built with dev mounts; wait for `fissile dev sync` to put the
+# jobs and role scripts in place
+until [ -e {{ .dev_synced_marker }} ]; do
+    echo "Waiting for fissile dev sync"
+    sleep 5
+done
+timeline "dev sync done"
+{{ end }}
+
 # Unmark the role. We may have this file from a previous run of the
 # role, i.e. this may be a restart. Ensure that we are not seen as
-# ready yet.
-rm -f /var/vcap/monit/ready /var/vcap/monit/ready.lock
+# ready yet, nor as having rendered the configuration, nor as stopped.
+rm -f /var/vcap/monit/ready /var/vcap/monit/ready.lock /var/vcap/monit/rendered /var/vcap/monit/stopped
 
 # When the container gets restarted, processes may end up with different pids
 find /run -name "*.pid" -delete
@@ -29,15 +78,52 @@ export MONIT_ADMIN_PASSWORD=$(cat /proc/sys/kernel/random/uuid)
 {{ range $script := .role.EnvironScripts }}
     source {{ if not (is_abs $script) }}/opt/hcf/startup/{{ end }}{{ $script }}
 {{ end }}
+timeline "environment scripts done"
+
 # Run custom role scripts
 {{ range $script := .role.Scripts}}
     bash {{ if not (is_abs $script) }}/opt/hcf/startup/{{ end }}{{ $script }}
 {{ end }}
 
+timeline "role scripts done"
+
 /opt/hcf/configgin/configgin \
 	--jobs /opt/hcf/job_config.json \
 	--env2conf /opt/hcf/env2conf.yml
 
+{{ if and .role.HasJobDependencies (ne .role.Type "bosh-task") }}
+# Have monit start the processes of the jobs after the processes of the jobs
+# they depend on are running, and stop them before those
+function monit-depends-on()
+{
+    local job=$1
+    shift
+    local processes=$(for dependency in "$@"; do
+        awk '$1 == "check" && $2 == "process" { print $3 }' "/var/vcap/monit/${dependency}.monitrc" 2>/dev/null
+    done | paste -sd, -)
+
+    if [ -z "${processes}" ]; then
+        return 0
+    fi
+    # The dependencies go at the end of every process check of the job
+    local monitrc="/var/vcap/monit/${job}.monitrc"
+    awk -v processes="${processes}" '
+        $1 == "check" { if (process) print "  depends on " processes ; process = ($2 == "process") }
+        { print }
+        END { if (process) print "  depends on " processes }' "${monitrc}" > "${monitrc}.tmp"
+    mv "${monitrc}.tmp" "${monitrc}"
+}
+{{ range $job := .role.JobNameList }}{{ if $job.DependsOn }}
+monit-depends-on {{ $job.Name }}{{ range $dependency := $job.DependsOn }} {{ $dependency }}{{ end }}
+{{ end }}{{ end }}
+timeline "job dependencies done"
+{{ end }}
+
+# The templates rendered; post-start.sh only marks the role as ready after this
+mkdir -p /var/vcap/monit
+touch /var/vcap/monit/rendered
+timeline "configgin done"
+
 if [ -e /etc/monitrc ]
 then
   chmod 0600 /etc/monitrc
@@ -48,6 +134,25 @@ mkdir -p /var/vcap/sys/run
 chown root:vcap /var/vcap/sys/run
 chmod 775 /var/vcap/sys/run
 
+{{ with .role.Run }}{{ if .Backup }}
+# Run the BBR scripts of the jobs instead of starting the role
+if [[ "$1" == "--bbr" ]]; then
+    case "$2" in
+        backup|restore) ;;
+        *) echo "Unknown BBR action $2" >&2 ; exit 1 ;;
+    esac
+{{ range $job := $.role.Jobs }}
+    if [ -x /var/vcap/jobs/{{ $job.Name }}/bin/bbr/$2 ]; then
+        export BBR_ARTIFACT_DIRECTORY={{ $.bbr_artifacts_path }}/{{ $job.Name }}/
+        mkdir -p "${BBR_ARTIFACT_DIRECTORY}"
+        echo "Running $2 script of job {{ $job.Name }}"
+        /var/vcap/jobs/{{ $job.Name }}/bin/bbr/$2
+    fi
+{{ end }}
+    exit 0
+fi
+{{ end }}{{ end }}
+
 {{ if eq .role.Type "bosh-task" }}
     # Start rsyslog and cron
     service rsyslog start
@@ -82,31 +187,77 @@ function sorted-pre-start-paths()
     echo ${fnames[*]}
 }
 
+timeline "post config scripts done"
+
 for fname in $(sorted-pre-start-paths) ; do
     echo bash $fname
     bash $fname
+    timeline "pre-start ${fname} done"
 done
 
 # Run
 {{ if eq .role.Type "bosh-task" }}
-    {{ range $job := .role.Jobs}}
+    timeline "running task"
+    {{ range $job := .start_jobs }}
         /var/vcap/jobs/{{ $job.Name }}/bin/run
+        timeline "task {{ $job.Name }} done"
     {{ end }}
+    timeline-report
 {{ else }}
 
+{{ if .stop_signals }}
+  # Prints the pids of the monit processes of the jobs that are running. The
+  # pidfile of a process follows its check, on the same line or the next one.
+  running-job-pids() {
+    for pidfile in $(awk '
+        $1 == "check" { process = ($2 == "process") }
+        process && $4 == "with" && $5 == "pidfile" { print $6; process = 0 }
+        process && $1 == "with" && $2 == "pidfile" { print $3; process = 0 }' /var/vcap/monit/*.monitrc 2>/dev/null); do
+      if [ -f "${pidfile}" ] && kill -0 "$(cat "${pidfile}")" 2>/dev/null; then
+        cat "${pidfile}"
+      fi
+    done | paste -sd' ' -
+  }
+{{ end }}
+
   killer() {
     # Wait for all monit services to be stopped
     echo "Received SIGTERM. Will run 'monit stop all'."
-
+{{ if .pre_stop_script }}
+    # Stop the jobs in order first, unless the preStop hook already did
+    {{ .pre_stop_script }}
+{{ end }}
     total_services=$(monit summary | grep -c "^Process")
 
     monit stop all
 
     echo "Ran 'monit stop all'."
 
+{{ if .stop_signals }}
+    # The processes still running after the stop timeout are sent the stop
+    # signals of the role, in order, each with time to exit
+    waited=0
+    while [ $total_services != $(monit summary | grep "^Process" | grep -c "Not monitored") ] && [ ${waited} -lt {{ .stop_timeout }} ] ; do
+       sleep 1
+       waited=$((waited + 1))
+    done
+{{ range $signal := .stop_signals }}
+    pids=$(running-job-pids)
+    if [ -n "${pids}" ] && [ $total_services != $(monit summary | grep "^Process" | grep -c "Not monitored") ] ; then
+       echo "Sending SIG{{ $signal.Signal }} to the processes still running: ${pids}"
+       kill -s {{ $signal.Signal }} ${pids} 2>/dev/null || true
+       waited=0
+       while [ -n "$(running-job-pids)" ] && [ ${waited} -lt {{ $signal.Wait }} ] ; do
+          sleep 1
+          waited=$((waited + 1))
+       done
+    fi
+{{ end }}
+{{ else }}
     while [ $total_services != $(monit summary | grep "^Process" | grep -c "Not monitored") ] ; do
        sleep 1
     done
+{{ end }}
 
     echo "All monit processes have been stopped."
     monit summary
@@ -114,6 +265,16 @@ done
   }
 
   trap killer SIGTERM
+{{ if .health_shim_port }}
+  # Serve the monit status on {{ .health_shim_path }}, for the readiness probe.
+  # It uses the ruby configgin comes with.
+  health_shim_ruby=/opt/hcf/configgin/lib/ruby/bin/ruby
+  if [ ! -x "${health_shim_ruby}" ]; then
+    health_shim_ruby=ruby
+  fi
+  "${health_shim_ruby}" /opt/hcf/health-shim.rb {{ .health_shim_port }} &
+  timeline "health shim started"
+{{ end }}
 
   if [[ "${LOG_LEVEL}" == "debug"* || -n "${LOG_DEBUG}" ]]; then
     # monit -v without the -I would fork a child, but then we can't wait on it,
@@ -123,5 +284,7 @@ done
     monit -I &
   fi
   child=$!
+  timeline "monit started"
+  timeline-report
   wait "$child"
 {{ end }}
@@ -0,0 +1,92 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrDiskSpaceUnknown is returned by FreeDiskSpace on platforms where the free
+// disk space can't be determined
+var ErrDiskSpaceUnknown = errors.New("Free disk space can't be determined on this platform")
+
+// FreeDiskSpace returns the number of bytes available to unprivileged users
+// on the filesystem holding path. If path doesn't exist yet, its closest
+// existing parent is used.
+func FreeDiskSpace(path string) (uint64, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return 0, err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+
+	return freeDiskSpace(path)
+}
+
+// CheckFreeDiskSpace fails if the filesystem holding path has less than
+// required bytes available. The purpose describes what the space is for, for
+// the error message. Platforms where the free space is unknown always pass.
+func CheckFreeDiskSpace(path string, required uint64, purpose string) error {
+	available, err := FreeDiskSpace(path)
+	if err == ErrDiskSpaceUnknown {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error checking free disk space in %s: %s", path, err.Error())
+	}
+
+	if available < required {
+		return fmt.Errorf("Not enough free disk space in %s for %s: %s needed, %s available",
+			path, purpose, FormatBytes(required), FormatBytes(available))
+	}
+
+	return nil
+}
+
+// DirSize returns the total size, in bytes, of the regular files below a
+// directory
+func DirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// FormatBytes formats a number of bytes for humans, e.g. 1.5GB
+func FormatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+
+	value := float64(bytes)
+	for _, suffix := range []string{"KB", "MB", "GB", "TB"} {
+		value /= unit
+		if value < unit || suffix == "TB" {
+			return fmt.Sprintf("%.1f%s", value, suffix)
+		}
+	}
+
+	// Not reached; the loop always returns on the last suffix
+	return fmt.Sprintf("%dB", bytes)
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeDiskSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-disk-space")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	available, err := FreeDiskSpace(tempDir)
+	if err == ErrDiskSpaceUnknown {
+		t.Skip("Free disk space is unknown on this platform")
+	}
+	assert.NoError(err)
+	assert.NotZero(available)
+
+	// Paths that don't exist yet use their closest parent
+	_, err = FreeDiskSpace(filepath.Join(tempDir, "does", "not", "exist"))
+	assert.NoError(err)
+
+	assert.NoError(CheckFreeDiskSpace(tempDir, 1, "testing"))
+	err = CheckFreeDiskSpace(tempDir, math.MaxUint64, "testing")
+	if assert.Error(err) {
+		assert.True(strings.HasPrefix(err.Error(), "Not enough free disk space in "+tempDir+" for testing: "), err.Error())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("512B", FormatBytes(512))
+	assert.Equal("1.5KB", FormatBytes(1536))
+	assert.Equal("2.0GB", FormatBytes(2*1024*1024*1024))
+}
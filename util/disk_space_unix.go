@@ -0,0 +1,15 @@
+// +build !windows
+
+package util
+
+import "syscall"
+
+// freeDiskSpace returns the bytes available on the filesystem of an existing path
+func freeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,6 @@
+package util
+
+// freeDiskSpace returns the bytes available on the filesystem of an existing path
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, ErrDiskSpaceUnknown
+}
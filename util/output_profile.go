@@ -0,0 +1,210 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// These are the output profiles of fissile
+const (
+	OutputProfileDefault = "default" // Colored output, as it happens
+	OutputProfileCI      = "ci"      // Output as it happens, without colors or other escape sequences
+	OutputProfileQuiet   = "quiet"   // Only the last line of output, and errors
+	OutputProfileJSON    = "json"    // A JSON event per line of output, and one for the result
+)
+
+// OutputProfiles lists the valid output profiles
+var OutputProfiles = []string{OutputProfileDefault, OutputProfileCI, OutputProfileQuiet, OutputProfileJSON}
+
+// ansiEscapePattern matches terminal escape sequences, like colors
+var ansiEscapePattern = regexp.MustCompile("\x1b\\[[0-9;?]*[A-Za-z]")
+
+// OutputWriter writes the output of a command for an output profile. Finish
+// is called once the command is done, with its error and exit code, and
+// writes its result.
+type OutputWriter interface {
+	io.Writer
+	Finish(err error, exitCode int) error
+}
+
+// OutputEvent is a line of output, or the result of a command, as written
+// with the json output profile
+type OutputEvent struct {
+	Event    string `json:"event"` // One of output, error or done
+	Message  string `json:"message,omitempty"`
+	ExitCode *int   `json:"exit-code,omitempty"`
+}
+
+// NewOutputWriter returns the writer for an output profile, writing to the
+// given writer
+func NewOutputWriter(profile string, writer io.Writer) (OutputWriter, error) {
+	switch profile {
+	case OutputProfileDefault, "":
+		return &plainOutputWriter{writer: writer}, nil
+	case OutputProfileCI:
+		return &plainOutputWriter{writer: writer, stripEscapes: true}, nil
+	case OutputProfileQuiet:
+		return &quietOutputWriter{writer: writer}, nil
+	case OutputProfileJSON:
+		return &jsonOutputWriter{encoder: json.NewEncoder(writer)}, nil
+	}
+	return nil, fmt.Errorf("Invalid output profile '%s', expected one of %s", profile, strings.Join(OutputProfiles, ", "))
+}
+
+// plainOutputWriter writes the output as it happens
+type plainOutputWriter struct {
+	writer       io.Writer
+	stripEscapes bool
+}
+
+// Write implements io.Writer
+func (w *plainOutputWriter) Write(data []byte) (int, error) {
+	if !w.stripEscapes {
+		return w.writer.Write(data)
+	}
+	if _, err := w.writer.Write(ansiEscapePattern.ReplaceAll(data, nil)); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Finish implements OutputWriter
+func (w *plainOutputWriter) Finish(err error, exitCode int) error {
+	if err == nil {
+		return nil
+	}
+	message := color.RedString("%v", err)
+	if w.stripEscapes {
+		message = fmt.Sprintf("%v", err)
+	}
+	_, writeErr := fmt.Fprintln(w.writer, message)
+	return writeErr
+}
+
+// lineBuffer splits output into lines; it is safe to write to from multiple
+// goroutines
+type lineBuffer struct {
+	sync.Mutex
+	partial bytes.Buffer
+}
+
+// lines adds data to the buffer, and returns the complete lines it ends,
+// without escape sequences and line endings
+func (b *lineBuffer) lines(data []byte) []string {
+	b.Lock()
+	defer b.Unlock()
+
+	b.partial.Write(data)
+	var result []string
+	for {
+		line, err := b.partial.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			b.partial.Reset()
+			b.partial.WriteString(line)
+			return result
+		}
+		result = append(result, cleanOutputLine(line))
+	}
+}
+
+// rest returns the incomplete line left in the buffer, if any
+func (b *lineBuffer) rest() (string, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.partial.Len() == 0 {
+		return "", false
+	}
+	line := cleanOutputLine(b.partial.String())
+	b.partial.Reset()
+	return line, true
+}
+
+// cleanOutputLine drops escape sequences and the line ending from a line
+func cleanOutputLine(line string) string {
+	return strings.TrimRight(ansiEscapePattern.ReplaceAllString(line, ""), "\r\n")
+}
+
+// quietOutputWriter only keeps the last line of output, as the summary of
+// the command
+type quietOutputWriter struct {
+	lineBuffer
+	writer io.Writer
+	last   string
+}
+
+// Write implements io.Writer
+func (w *quietOutputWriter) Write(data []byte) (int, error) {
+	lines := w.lines(data)
+
+	w.Lock()
+	defer w.Unlock()
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			w.last = line
+		}
+	}
+	return len(data), nil
+}
+
+// Finish implements OutputWriter
+func (w *quietOutputWriter) Finish(err error, exitCode int) error {
+	if line, ok := w.rest(); ok && strings.TrimSpace(line) != "" {
+		w.last = line
+	}
+	if w.last != "" {
+		if _, writeErr := fmt.Fprintln(w.writer, w.last); writeErr != nil {
+			return writeErr
+		}
+	}
+	if err != nil {
+		_, writeErr := fmt.Fprintln(w.writer, err.Error())
+		return writeErr
+	}
+	return nil
+}
+
+// jsonOutputWriter writes a JSON event per line of output
+type jsonOutputWriter struct {
+	lineBuffer
+	encodeLock sync.Mutex
+	encoder    *json.Encoder
+}
+
+// Write implements io.Writer
+func (w *jsonOutputWriter) Write(data []byte) (int, error) {
+	for _, line := range w.lines(data) {
+		if err := w.emit(OutputEvent{Event: "output", Message: line}); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// emit writes an event
+func (w *jsonOutputWriter) emit(event OutputEvent) error {
+	w.encodeLock.Lock()
+	defer w.encodeLock.Unlock()
+	return w.encoder.Encode(event)
+}
+
+// Finish implements OutputWriter
+func (w *jsonOutputWriter) Finish(err error, exitCode int) error {
+	if line, ok := w.rest(); ok {
+		if emitErr := w.emit(OutputEvent{Event: "output", Message: line}); emitErr != nil {
+			return emitErr
+		}
+	}
+	if err != nil {
+		return w.emit(OutputEvent{Event: "error", Message: err.Error(), ExitCode: &exitCode})
+	}
+	return w.emit(OutputEvent{Event: "done", ExitCode: &exitCode})
+}
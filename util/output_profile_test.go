@@ -0,0 +1,74 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputProfiles(t *testing.T) {
+	assert := assert.New(t)
+
+	colored := "\x1b[32mBuilding\x1b[0m image\n"
+
+	for _, sample := range []struct {
+		profile  string
+		err      error
+		expected string
+	}{
+		{
+			profile:  OutputProfileDefault,
+			expected: colored + "Done.\n",
+		},
+		{
+			profile:  OutputProfileCI,
+			err:      fmt.Errorf("Error building image"),
+			expected: "Building image\nDone.\nError building image\n",
+		},
+		{
+			profile:  OutputProfileQuiet,
+			expected: "Done.\n",
+		},
+		{
+			profile:  OutputProfileQuiet,
+			err:      fmt.Errorf("Error building image"),
+			expected: "Done.\nError building image\n",
+		},
+		{
+			profile: OutputProfileJSON,
+			expected: `{"event":"output","message":"Building image"}` + "\n" +
+				`{"event":"output","message":"Done."}` + "\n" +
+				`{"event":"done","exit-code":0}` + "\n",
+		},
+		{
+			profile: OutputProfileJSON,
+			err:     fmt.Errorf("Error building image"),
+			expected: `{"event":"output","message":"Building image"}` + "\n" +
+				`{"event":"output","message":"Done."}` + "\n" +
+				`{"event":"error","message":"Error building image","exit-code":5}` + "\n",
+		},
+	} {
+		var output bytes.Buffer
+		writer, err := NewOutputWriter(sample.profile, &output)
+		if !assert.NoError(err) {
+			continue
+		}
+
+		fmt.Fprint(writer, colored)
+		// Lines can be written in parts
+		fmt.Fprint(writer, "Do")
+		fmt.Fprint(writer, "ne.\n")
+
+		exitCode := 0
+		if sample.err != nil {
+			exitCode = 5
+		}
+		assert.NoError(writer.Finish(sample.err, exitCode))
+		assert.Equal(sample.expected, output.String(), sample.profile)
+	}
+
+	_, err := NewOutputWriter("fancy", &bytes.Buffer{})
+	assert.EqualError(err, "Invalid output profile 'fancy', expected one of default, ci, quiet, json")
+}
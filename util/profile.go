@@ -0,0 +1,49 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+)
+
+// StartProfiling starts writing a CPU profile to cpu.pprof in dir. The
+// returned function stops it, and writes a heap profile to heap.pprof; it must
+// be called before the program exits.
+func StartProfiling(dir string) (func() error, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating profile directory: %s", err.Error())
+	}
+
+	cpuFile, err := os.Create(filepath.Join(dir, "cpu.pprof"))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating CPU profile: %s", err.Error())
+	}
+
+	if err := pprof.StartCPUProfile(cpuFile); err != nil {
+		cpuFile.Close()
+		return nil, fmt.Errorf("Error starting CPU profile: %s", err.Error())
+	}
+
+	return func() error {
+		pprof.StopCPUProfile()
+		if err := cpuFile.Close(); err != nil {
+			return fmt.Errorf("Error writing CPU profile: %s", err.Error())
+		}
+
+		heapFile, err := os.Create(filepath.Join(dir, "heap.pprof"))
+		if err != nil {
+			return fmt.Errorf("Error creating heap profile: %s", err.Error())
+		}
+		defer heapFile.Close()
+
+		// Get up to date statistics on what is still in use
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(heapFile); err != nil {
+			return fmt.Errorf("Error writing heap profile: %s", err.Error())
+		}
+
+		return nil
+	}, nil
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartProfiling(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-profile")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	profileDir := filepath.Join(tempDir, "profiles")
+	stop, err := StartProfiling(profileDir)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.NoError(stop())
+
+	for _, name := range []string{"cpu.pprof", "heap.pprof"} {
+		info, err := os.Stat(filepath.Join(profileDir, name))
+		if assert.NoError(err, "Missing profile %s", name) {
+			assert.NotZero(info.Size(), "Empty profile %s", name)
+		}
+	}
+}
@@ -2,10 +2,12 @@ package util
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"path"
 )
 
@@ -14,10 +16,54 @@ var (
 	DefaultLicensePrefixFilters = []string{"LICENSE", "NOTICE"}
 )
 
+// binarySniffLength is how much of a file is checked for NUL bytes to tell
+// binary files from text files, like git does
+const binarySniffLength = 8000
+
+// ReadLicenseFile streams a license file and returns its contents and SHA1
+// hash. Files larger than maxSize bytes, and binary files, are not kept in
+// memory; for those the contents are nil and only the hash is returned.
+func ReadLicenseFile(reader io.Reader, maxSize int64) ([]byte, string, error) {
+	hash := sha1.New()
+	tee := io.TeeReader(reader, hash)
+
+	var contents bytes.Buffer
+	if _, err := io.CopyN(&contents, tee, maxSize+1); err != nil && err != io.EOF {
+		return nil, "", err
+	}
+
+	keep := int64(contents.Len()) <= maxSize
+
+	sniff := contents.Bytes()
+	if len(sniff) > binarySniffLength {
+		sniff = sniff[:binarySniffLength]
+	}
+	if bytes.IndexByte(sniff, 0) != -1 {
+		keep = false
+	}
+
+	if !keep {
+		// Only the hash of the rest of the file is needed
+		if _, err := io.Copy(hash, reader); err != nil {
+			return nil, "", err
+		}
+		return nil, hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	result := contents.Bytes()
+	if result == nil {
+		// Empty files are kept too; nil means the file was skipped
+		result = []byte{}
+	}
+	return result, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 // LoadLicenseFiles iterates through a tar.gz file looking for anything that matches
-// prefixFilters. Filename is for error generation only.
-func LoadLicenseFiles(filename string, targz io.Reader, prefixFilters ...string) (map[string][]byte, error) {
+// prefixFilters. Filename is for error generation only. Files that are larger
+// than maxSize bytes or binary are returned as hashes rather than contents.
+func LoadLicenseFiles(filename string, targz io.Reader, maxSize int64, prefixFilters ...string) (map[string][]byte, map[string]string, error) {
 	files := make(map[string][]byte)
+	hashes := make(map[string]string)
 
 	err := TargzIterate(filename, targz,
 		func(licenseFile *tar.Reader, header *tar.Header) error {
@@ -35,16 +81,20 @@ func LoadLicenseFiles(filename string, targz io.Reader, prefixFilters ...string)
 				return nil
 			}
 
-			buf, err := ioutil.ReadAll(licenseFile)
+			buf, hash, err := ReadLicenseFile(licenseFile, maxSize)
 			if err != nil {
 				return err
 			}
 
-			files[header.Name] = buf
+			if buf == nil {
+				hashes[header.Name] = hash
+			} else {
+				files[header.Name] = buf
+			}
 			return nil
 		})
 
-	return files, err
+	return files, hashes, err
 }
 
 // TargzIterate iterates over the files it finds in a tar.gz file and calls a
@@ -22,13 +22,36 @@ func TestLoadLicenseFiles(t *testing.T) {
 	f, err := os.Open(licenseTar)
 	assert.NoError(err)
 
-	files, err := LoadLicenseFiles(licenseTar, f, DefaultLicensePrefixFilters...)
+	files, hashes, err := LoadLicenseFiles(licenseTar, f, 1024, DefaultLicensePrefixFilters...)
 	assert.NoError(err)
 
 	assert.Len(files, 1)
+	assert.Empty(hashes)
 	assert.Equal(files["LICENSE"], []byte("license file\n"))
 }
 
+func TestReadLicenseFile(t *testing.T) {
+	assert := assert.New(t)
+
+	contents, hash, err := ReadLicenseFile(bytes.NewBufferString("license file\n"), 1024)
+	assert.NoError(err)
+	assert.Equal([]byte("license file\n"), contents)
+	assert.Equal("f1584265d87c9edee23267d28eb809c4a19a49d6", hash)
+
+	contents, hash, err = ReadLicenseFile(bytes.NewBufferString("license file\n"), 4)
+	assert.NoError(err)
+	assert.Nil(contents, "Files above the limit should not be kept")
+	assert.Equal("f1584265d87c9edee23267d28eb809c4a19a49d6", hash)
+
+	contents, _, err = ReadLicenseFile(bytes.NewBuffer([]byte{'P', 'K', 0, 3}), 1024)
+	assert.NoError(err)
+	assert.Nil(contents, "Binary files should not be kept")
+
+	contents, _, err = ReadLicenseFile(&bytes.Buffer{}, 1024)
+	assert.NoError(err)
+	assert.NotNil(contents, "Empty files should be kept")
+}
+
 func TestWriteToTarStream(t *testing.T) {
 	assert := assert.New(t)
 
@@ -9,13 +9,16 @@ import (
 // TempDir overrides the default TempDir, since Docker needs this
 // to be in your user folder. If this isn't in your user folder, then
 // docker cannot attach to the volume, and you get odd errors back
-// from docker.
+// from docker. An absolute dir, like a configured scratch directory, is
+// used as it is instead of below ~/tmp.
 func TempDir(dir, prefix string) (name string, err error) {
 	homeDir := os.Getenv("HOME")
 
 	var fullPath string
 
-	if dir != "" {
+	if path.IsAbs(dir) {
+		fullPath = dir
+	} else if dir != "" {
 		fullPath = path.Join(homeDir, "tmp", dir)
 	} else {
 		fullPath = path.Join(homeDir, "tmp")
@@ -0,0 +1,233 @@
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracing is the tracer of the current fissile run; it is nil, and records
+// nothing, unless traces are exported
+var Tracing *Tracer
+
+// exportClient sends traces to the OTLP endpoint; an endpoint that is down
+// fails the export instead of hanging the end of the run
+var exportClient = &http.Client{Timeout: 10 * time.Second}
+
+// Tracer records the phases of a fissile run, like compiling a package or
+// building an image, as the spans of an OpenTelemetry trace, and exports them
+// over OTLP/HTTP. A nil tracer records nothing, so that code can be traced
+// whether traces are exported or not.
+type Tracer struct {
+	traceID string
+	root    *Span
+
+	mutex  sync.Mutex
+	phases []*Span // Open phases, the innermost last
+	spans  []*Span
+}
+
+// Span is a timed phase of a fissile run
+type Span struct {
+	tracer     *Tracer
+	name       string
+	spanID     string
+	parentID   string
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	err        error
+}
+
+// NewTracer returns a tracer whose spans are all part of the span of the run
+func NewTracer(name string) *Tracer {
+	t := &Tracer{traceID: randomTraceID(16)}
+	t.root = &Span{tracer: t, name: name, spanID: randomTraceID(8), start: time.Now(), attributes: map[string]interface{}{}}
+	return t
+}
+
+// StartSpan starts a span, as part of the innermost phase that is open
+func (t *Tracer) StartSpan(name string) *Span {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	parent := t.root
+	if len(t.phases) > 0 {
+		parent = t.phases[len(t.phases)-1]
+	}
+	return &Span{
+		tracer:     t,
+		name:       name,
+		spanID:     randomTraceID(8),
+		parentID:   parent.spanID,
+		start:      time.Now(),
+		attributes: map[string]interface{}{},
+	}
+}
+
+// StartPhase starts a span the spans started until it ends are part of, e.g.
+// compiling the packages, for the spans of the packages
+func (t *Tracer) StartPhase(name string) *Span {
+	span := t.StartSpan(name)
+	if span != nil {
+		t.mutex.Lock()
+		t.phases = append(t.phases, span)
+		t.mutex.Unlock()
+	}
+	return span
+}
+
+// SetAttribute records an attribute of the span, e.g. whether a cache was hit
+// or a size. Values are strings, booleans, integers or floats.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.tracer.mutex.Lock()
+	s.attributes[key] = value
+	s.tracer.mutex.Unlock()
+}
+
+// End ends the span, as failed if err is set
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	t := s.tracer
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s.end = time.Now()
+	s.err = err
+	for i, phase := range t.phases {
+		if phase == s {
+			t.phases = append(t.phases[:i], t.phases[i+1:]...)
+			break
+		}
+	}
+	if s != t.root {
+		t.spans = append(t.spans, s)
+	}
+}
+
+// Export ends the span of the run, named after it, and sends the trace to an
+// OTLP/HTTP endpoint, e.g. http://localhost:4318
+func (t *Tracer) Export(endpoint, name string, err error) error {
+	if t == nil {
+		return nil
+	}
+
+	t.root.name = name
+	t.root.End(err)
+
+	contents, marshalErr := t.MarshalOTLP()
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	url := strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	response, postErr := exportClient.Post(url, "application/json", bytes.NewReader(contents))
+	if postErr != nil {
+		return fmt.Errorf("Error exporting traces: %s", postErr.Error())
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("Error exporting traces to %s: %s", url, response.Status)
+	}
+
+	return nil
+}
+
+// MarshalOTLP returns the spans that ended, including the span of the run
+// once it did, as an OTLP/JSON trace export request
+func (t *Tracer) MarshalOTLP() ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	spans := make([]map[string]interface{}, 0, len(t.spans)+1)
+	for _, span := range append(append([]*Span{}, t.spans...), t.root) {
+		if span.end.IsZero() {
+			continue
+		}
+		otlpSpan := map[string]interface{}{
+			"traceId":           t.traceID,
+			"spanId":            span.spanID,
+			"name":              span.name,
+			"kind":              1, // Internal
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.attributes),
+			"status":            map[string]interface{}{"code": 1}, // Ok
+		}
+		if span.parentID != "" {
+			otlpSpan["parentSpanId"] = span.parentID
+		}
+		if span.err != nil {
+			otlpSpan["status"] = map[string]interface{}{"code": 2, "message": span.err.Error()} // Error
+		}
+		spans = append(spans, otlpSpan)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]interface{}{"service.name": "fissile"}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "fissile"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	})
+}
+
+// otlpAttributes returns attributes as OTLP key values, sorted by key
+func otlpAttributes(attributes map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprintf("%v", v)}
+		}
+		result = append(result, map[string]interface{}{"key": key, "value": value})
+	}
+	return result
+}
+
+// randomTraceID returns a random ID of the given number of bytes, in hex
+func randomTraceID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,147 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type otlpTestSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Attributes   []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"attributes"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+func unmarshalOTLPSpans(contents []byte) (map[string]otlpTestSpan, error) {
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpTestSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(contents, &request); err != nil {
+		return nil, err
+	}
+
+	spans := map[string]otlpTestSpan{}
+	for _, resourceSpans := range request.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, span := range scopeSpans.Spans {
+				spans[span.Name] = span
+			}
+		}
+	}
+	return spans, nil
+}
+
+func TestTracerSpans(t *testing.T) {
+	assert := assert.New(t)
+
+	tracer := NewTracer("fissile")
+	phase := tracer.StartPhase("compile-packages")
+	span := tracer.StartSpan("compile-package")
+	span.SetAttribute("package", "ruby")
+	span.SetAttribute("cache-hit", true)
+	span.SetAttribute("compiled-size", int64(1024))
+	span.End(nil)
+	phase.End(fmt.Errorf("Compilation failed"))
+	after := tracer.StartSpan("create-role-images")
+	after.End(nil)
+
+	contents, err := tracer.MarshalOTLP()
+	if !assert.NoError(err) {
+		return
+	}
+	spans, err := unmarshalOTLPSpans(contents)
+	if !assert.NoError(err) {
+		return
+	}
+
+	// The span of the run only ends when the trace is exported
+	assert.Len(spans, 3)
+	assert.Equal(spans["compile-packages"].SpanID, spans["compile-package"].ParentSpanID)
+	assert.Equal(spans["compile-packages"].ParentSpanID, spans["create-role-images"].ParentSpanID)
+	assert.Equal(spans["compile-packages"].TraceID, spans["compile-package"].TraceID)
+
+	if assert.Len(spans["compile-package"].Attributes, 3) {
+		attributes := spans["compile-package"].Attributes
+		assert.Equal("cache-hit", attributes[0].Key)
+		assert.Equal(true, attributes[0].Value["boolValue"])
+		assert.Equal("compiled-size", attributes[1].Key)
+		assert.Equal("1024", attributes[1].Value["intValue"])
+		assert.Equal("package", attributes[2].Key)
+		assert.Equal("ruby", attributes[2].Value["stringValue"])
+	}
+
+	assert.Equal(1, spans["compile-package"].Status.Code)
+	assert.Equal(2, spans["compile-packages"].Status.Code)
+	assert.Equal("Compilation failed", spans["compile-packages"].Status.Message)
+}
+
+func TestTracerExport(t *testing.T) {
+	assert := assert.New(t)
+
+	var path string
+	var contents []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		contents, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("fissile")
+	tracer.StartSpan("build-role-image").End(nil)
+	if !assert.NoError(tracer.Export(server.URL+"/", "fissile build images", nil)) {
+		return
+	}
+
+	assert.Equal("/v1/traces", path)
+	spans, err := unmarshalOTLPSpans(contents)
+	if assert.NoError(err) && assert.Len(spans, 2) {
+		assert.Equal("", spans["fissile build images"].ParentSpanID)
+		assert.Equal(spans["fissile build images"].SpanID, spans["build-role-image"].ParentSpanID)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	assert.Error(NewTracer("fissile").Export(failing.URL, "fissile", nil))
+
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer stalled.Close()
+	defer func(timeout time.Duration) { exportClient.Timeout = timeout }(exportClient.Timeout)
+	exportClient.Timeout = 50 * time.Millisecond
+	err = NewTracer("fissile").Export(stalled.URL, "fissile", nil)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "Client.Timeout exceeded")
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	assert := assert.New(t)
+
+	var tracer *Tracer
+	span := tracer.StartPhase("compile-packages")
+	span.SetAttribute("cache-hit", false)
+	span.End(nil)
+	assert.NoError(tracer.Export("http://localhost:0", "fissile", nil))
+}